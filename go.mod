@@ -25,6 +25,8 @@ require (
 	github.com/stretchr/testify v1.5.1
 	github.com/tsenart/vegeta v12.7.1-0.20190725001342-b5f4fca92137+incompatible
 	github.com/wavesoftware/go-ensure v1.0.0
+	github.com/xitongsys/parquet-go v1.6.0
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
 	go.opencensus.io v0.22.4
 	go.opentelemetry.io/otel v0.2.3
 	go.uber.org/atomic v1.6.0
@@ -41,6 +43,7 @@ require (
 	k8s.io/utils v0.0.0-20200603063816-c1c6865ac451
 	knative.dev/pkg v0.0.0-20201008052621-c0496d5e1b2b
 	knative.dev/test-infra v0.0.0-20201008043522-20ae6ee1a359
+	modernc.org/sqlite v1.7.4
 	sigs.k8s.io/yaml v1.2.0
 )
 
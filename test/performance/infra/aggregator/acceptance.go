@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// acceptanceWindow counts the events sent during a time window, and how many
+// of them were accepted.
+type acceptanceWindow struct {
+	start    time.Time
+	sent     int
+	accepted int
+}
+
+// rate is the ratio of the events sent during the window which were accepted.
+func (w *acceptanceWindow) rate() float64 {
+	if w.sent == 0 {
+		return 0
+	}
+	return float64(w.accepted) / float64(w.sent)
+}
+
+// acceptanceRates counts the sent and accepted events by the window of their
+// sent time. The windows are sorted by start time, without gap between the
+// first and the last one.
+func acceptanceRates(events []event, window time.Duration) []acceptanceWindow {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var first, last time.Time
+	byStart := make(map[time.Time]*acceptanceWindow)
+	for i := range events {
+		e := &events[i]
+		start := e.sent.Truncate(window)
+		w, ok := byStart[start]
+		if !ok {
+			w = &acceptanceWindow{start: start}
+			byStart[start] = w
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if start.After(last) {
+				last = start
+			}
+		}
+		w.sent++
+		if e.isAccepted {
+			w.accepted++
+		}
+	}
+
+	var windows []acceptanceWindow
+	for start := first; !start.After(last); start = start.Add(window) {
+		if w, ok := byStart[start]; ok {
+			windows = append(windows, *w)
+		} else {
+			windows = append(windows, acceptanceWindow{start: start})
+		}
+	}
+	return windows
+}
+
+// reportAcceptanceRates logs the acceptance rate of each window.
+func reportAcceptanceRates(windows []acceptanceWindow) {
+	log.Printf("Acceptance rate over %d windows:", len(windows))
+	for i := range windows {
+		w := &windows[i]
+		log.Printf("  %s: %.1f%% (%d/%d)", w.start.Format(time.RFC3339Nano), w.rate()*100, w.accepted, w.sent)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAcceptanceRatesMidRunDrop(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	// 100 events per second for 6 seconds, only half of them being accepted
+	// during seconds 2 and 3
+	var events []event
+	for s := 0; s < 6; s++ {
+		for i := 0; i < 100; i++ {
+			sent := start.Add(time.Duration(s)*time.Second + time.Duration(i)*10*time.Millisecond)
+			events = append(events, event{
+				id:         fmt.Sprintf("%d-%d", s, i),
+				sent:       sent,
+				accepted:   sent.Add(time.Millisecond),
+				isAccepted: (s != 2 && s != 3) || i%2 == 0,
+			})
+		}
+	}
+
+	windows := acceptanceRates(events, time.Second)
+	wantRates := []float64{1, 1, 0.5, 0.5, 1, 1}
+	if len(windows) != len(wantRates) {
+		t.Fatalf("len(acceptanceRates()) = %d, want %d", len(windows), len(wantRates))
+	}
+	for i, w := range windows {
+		if want := start.Add(time.Duration(i) * time.Second); !w.start.Equal(want) {
+			t.Errorf("window %d starts at %v, want %v", i, w.start, want)
+		}
+		if w.sent != 100 {
+			t.Errorf("window %d sent = %d, want 100", i, w.sent)
+		}
+		if got := w.rate(); got != wantRates[i] {
+			t.Errorf("window %d acceptance rate = %v, want %v", i, got, wantRates[i])
+		}
+	}
+}
+
+func TestAcceptanceRatesEmptyWindows(t *testing.T) {
+	start := time.Unix(1000, 0)
+	events := []event{
+		{id: "1", sent: start, isAccepted: true},
+		{id: "2", sent: start.Add(2 * time.Second)},
+	}
+
+	windows := acceptanceRates(events, time.Second)
+	if len(windows) != 3 {
+		t.Fatalf("len(acceptanceRates()) = %d, want 3", len(windows))
+	}
+	if w := windows[1]; w.sent != 0 || w.rate() != 0 {
+		t.Errorf("empty window = %+v, want no events", w)
+	}
+	if windows[0].rate() != 1 || windows[2].rate() != 0 {
+		t.Errorf("acceptance rates = %v, %v, want 1, 0", windows[0].rate(), windows[2].rate())
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// aggregatesStore is the part of the Mako quickstore the aggregates are published to.
+type aggregatesStore interface {
+	AddRunAggregate(valueKey string, value float64) error
+	AddMetricAggregate(valueKey string, aggregateType string, value float64) error
+}
+
+// samplePointStore is the part of the Mako quickstore the sample points are published to.
+type samplePointStore interface {
+	AddSamplePoint(xval float64, valueKeyToYVals map[string]float64) error
+}
+
+// runAggregates are the aggregates of a run published to Mako.
+type runAggregates struct {
+	publishErrors    int
+	deliverErrors    int
+	oversizeRejected uint64
+	distinctSenders  int
+	// Gini coefficient of the events sent by each sender, nil if not reported
+	senderGini *float64
+	// results of each sender shard and their imbalance, nil if not reported
+	shards         []ShardSummary
+	shardImbalance *shardImbalance
+	// number of received events whose content differs from the sent one, nil if no content was compared
+	corruptions *int
+	// number of received events whose content didn't match the checksum computed by their sender
+	checksumMismatches int
+	// number of the events received after an event of their partition with a
+	// higher sequence number, nil if the ordering isn't verified
+	outOfOrder *int
+	// end to end latencies by payload size and their slope over the sizes, in
+	// microseconds per KiB, nil unless the senders vary the payload sizes
+	payloadSizes     []PayloadSizeSummary
+	latencySizeSlope *float64
+	// number of accepted or received events expired without sent event, nil if they don't expire
+	abandoned *uint64
+	// number of duplicates recorded of each type of event
+	duplicates duplicateTotals
+	// number of the expected events records missing from the results, nil if
+	// the run could neither time out nor be finished early
+	missingRecords *int
+
+	// sorted latencies of the accepted and of the received events
+	publishLatencies []time.Duration
+	e2eLatencies     []time.Duration
+	// histograms of the latencies in streaming aggregation, instead of the sorted ones
+	publishHistogram *hdrHistogram
+	e2eHistogram     *hdrHistogram
+
+	// percentiles of the publish and end to end latencies
+	latencyPercentiles map[string]time.Duration
+
+	queueingDelays map[string]time.Duration
+	// percentiles of the sender queueing delays and of the end to end
+	// latencies net of them
+	senderQueueing map[string]time.Duration
+	// percentiles of the publish latencies of the events accepted on their
+	// first attempt and of the retried ones
+	publishCohorts map[string]time.Duration
+	modes          *bimodality
+	memory         *memoryStats
+	// percentiles of the times spent merging the events record lists
+	recorderTimes map[string]time.Duration
+	// median difference between the wall clock and the monotonic end to end latencies
+	skewEstimate *time.Duration
+	// median skew between the clocks of the receivers and the senders of the
+	// delivered events measured by the clock sync, nil without clock sync
+	clockSkew *time.Duration
+	// whether the run violated its SLO thresholds, nil without thresholds
+	failedRun *bool
+	// p99 of the spreads between the earliest and the latest deliveries of the
+	// events delivered several times, nil without redelivered events
+	redeliverySpread *time.Duration
+	// Pearson correlation between the publish and the delivery latencies of the
+	// events, nil if undefined
+	stageCorrelation *float64
+	// dominant period of the windowed p99 latencies, nil if not detected
+	periodicity *latencyPeriodicity
+	// bounds of the confidence intervals of the percentiles of the end to end latencies
+	confidenceIntervals map[string]time.Duration
+	// additional percentiles of the end to end latencies, keyed by percentile
+	highPercentiles map[float64]time.Duration
+	// percentiles of the end to end latencies weighted by the inverse send rate, nil if not reported
+	timeWeighted map[string]time.Duration
+
+	minSamplesForPercentiles int
+	// number of delivered events the latencies are computed over, nil if not capped
+	sampleCount *int
+	// events delivered after the deadline, nil without deadline
+	deadline *deadlineOutcome
+	// fraction of the delivered events retried, nil if no delivery attempt is known
+	retryRate *float64
+	// stability score of the run from 0 to 100, nil without sent events
+	stability *float64
+	// whether no event was delivered, nil if degenerate runs aren't detected
+	degenerate *bool
+}
+
+// isDegenerate tells whether none of the events was delivered, in which case
+// their latencies are meaningless.
+func isDegenerate(events []event) bool {
+	for i := range events {
+		if events[i].isReceived {
+			return false
+		}
+	}
+	return true
+}
+
+// lowSample tells whether too few events were delivered for their percentiles to be meaningful.
+func (a *runAggregates) lowSample() bool {
+	if a.e2eHistogram != nil {
+		return a.e2eHistogram.count < int64(a.minSamplesForPercentiles)
+	}
+	return len(a.e2eLatencies) < a.minSamplesForPercentiles
+}
+
+// publish adds the aggregates to the store. In low sample runs, it publishes
+// no percentile, replacing the metric aggregates Mako would calculate from the
+// latency sample points with ones without percentiles. In streaming
+// aggregation, whose latencies have no sample points, it always publishes
+// these. In degenerate runs, it publishes no latency at all, but the
+// degenerate marker.
+func (a *runAggregates) publish(q aggregatesStore) {
+	q.AddRunAggregate("pe", float64(a.publishErrors))
+	q.AddRunAggregate("de", float64(a.deliverErrors))
+	q.AddRunAggregate("or", float64(a.oversizeRejected))
+	q.AddRunAggregate("ds", float64(a.distinctSenders))
+	if a.senderGini != nil {
+		q.AddRunAggregate("sg", *a.senderGini)
+	}
+	if a.shardImbalance != nil {
+		q.AddRunAggregate("sti", a.shardImbalance.throughput)
+		q.AddRunAggregate("sli", a.shardImbalance.latency)
+	}
+	if a.corruptions != nil {
+		q.AddRunAggregate("cc", float64(*a.corruptions))
+	}
+	q.AddRunAggregate("cm", float64(a.checksumMismatches))
+	q.AddRunAggregate("dups", float64(a.duplicates.sent))
+	q.AddRunAggregate("dupa", float64(a.duplicates.accepted))
+	q.AddRunAggregate("dup", float64(a.duplicates.received))
+	if a.outOfOrder != nil {
+		q.AddRunAggregate("oo", float64(*a.outOfOrder))
+	}
+	if a.latencySizeSlope != nil {
+		q.AddRunAggregate("lsz", *a.latencySizeSlope)
+	}
+	if a.abandoned != nil {
+		q.AddRunAggregate("ab", float64(*a.abandoned))
+	}
+	if a.missingRecords != nil {
+		q.AddRunAggregate("mr", float64(*a.missingRecords))
+	}
+	if a.sampleCount != nil {
+		q.AddRunAggregate("sc", float64(*a.sampleCount))
+	}
+	if a.retryRate != nil {
+		q.AddRunAggregate(retryRateKey, *a.retryRate)
+	}
+	if a.stability != nil {
+		q.AddRunAggregate(stabilityKey, *a.stability)
+	}
+	if a.deadline != nil {
+		q.AddRunAggregate("dm", float64(a.deadline.misses))
+		q.AddRunAggregate("otr", a.deadline.onTimeRate)
+	}
+	for k, v := range a.recorderTimes {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+
+	if a.memory != nil {
+		q.AddRunAggregate("ph", float64(a.memory.peakHeapBytes)/(1024*1024))
+		q.AddRunAggregate("gp", float64(a.memory.gcPause)/float64(time.Millisecond))
+	}
+
+	// clock measurements and the period of the latencies rather than latency
+	// percentiles, meaningful in low sample runs too
+	if a.skewEstimate != nil {
+		q.AddRunAggregate("sk", a.skewEstimate.Seconds())
+	}
+	if a.clockSkew != nil {
+		q.AddRunAggregate(clockSkewKey, a.clockSkew.Seconds())
+	}
+	if a.periodicity != nil {
+		q.AddRunAggregate("lps", a.periodicity.period.Seconds())
+		q.AddRunAggregate("lpc", a.periodicity.strength)
+	}
+
+	// whether the run failed, even when it failed for too few delivered events
+	if a.failedRun != nil {
+		if *a.failedRun {
+			q.AddRunAggregate(failedRunKey, 1)
+		} else {
+			q.AddRunAggregate(failedRunKey, 0)
+		}
+	}
+
+	if a.degenerate != nil {
+		if *a.degenerate {
+			q.AddRunAggregate("dg", 1)
+			return
+		}
+		q.AddRunAggregate("dg", 0)
+	}
+
+	if a.e2eHistogram != nil {
+		publishHistogramWithoutPercentiles(q, "pl", a.publishHistogram)
+		publishHistogramWithoutPercentiles(q, "dl", a.e2eHistogram)
+	}
+	if a.lowSample() {
+		q.AddRunAggregate("ls", 1)
+		if a.e2eHistogram == nil {
+			publishWithoutPercentiles(q, "pl", a.publishLatencies)
+			publishWithoutPercentiles(q, "dl", a.e2eLatencies)
+		}
+		return
+	}
+	q.AddRunAggregate("ls", 0)
+
+	for k, v := range a.latencyPercentiles {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+	for k, v := range a.queueingDelays {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+	for k, v := range a.senderQueueing {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+	for k, v := range a.publishCohorts {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+	for k, v := range a.confidenceIntervals {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+	for p, v := range a.highPercentiles {
+		// already published with the latency percentiles
+		if _, ok := a.latencyPercentiles[highPercentileKey(p)]; ok {
+			continue
+		}
+		q.AddRunAggregate(highPercentileKey(p), v.Seconds())
+	}
+	for k, v := range a.timeWeighted {
+		q.AddRunAggregate(k, v.Seconds())
+	}
+
+	if a.redeliverySpread != nil {
+		q.AddRunAggregate(redeliverySpreadKey, a.redeliverySpread.Seconds())
+	}
+	if a.stageCorrelation != nil {
+		q.AddRunAggregate(stageCorrelationKey, *a.stageCorrelation)
+	}
+
+	if a.modes != nil {
+		q.AddRunAggregate("m1", a.modes.lowCenter.Seconds())
+		q.AddRunAggregate("m1f", a.modes.lowFraction)
+		q.AddRunAggregate("m2", a.modes.highCenter.Seconds())
+		q.AddRunAggregate("m2f", a.modes.highFraction)
+	}
+}
+
+// publishWithoutPercentiles sets the count, min, max and mean metric aggregates
+// of the sorted samples, which prevents Mako from calculating their percentiles.
+func publishWithoutPercentiles(q aggregatesStore, valueKey string, sorted []time.Duration) {
+	aggregates := map[string]float64{"count": float64(len(sorted))}
+	if len(sorted) > 0 {
+		var sum time.Duration
+		for _, d := range sorted {
+			sum += d
+		}
+		aggregates["min"] = sorted[0].Seconds()
+		aggregates["max"] = sorted[len(sorted)-1].Seconds()
+		aggregates["mean"] = (sum / time.Duration(len(sorted))).Seconds()
+	}
+	publishMetricAggregates(q, valueKey, aggregates)
+}
+
+// publishHistogramWithoutPercentiles is publishWithoutPercentiles for the
+// histogram of the samples.
+func publishHistogramWithoutPercentiles(q aggregatesStore, valueKey string, h *hdrHistogram) {
+	aggregates := map[string]float64{"count": float64(h.count)}
+	if h.count > 0 {
+		aggregates["min"] = h.min.Seconds()
+		aggregates["max"] = h.max.Seconds()
+		aggregates["mean"] = h.mean().Seconds()
+	}
+	publishMetricAggregates(q, valueKey, aggregates)
+}
+
+// publishMetricAggregates sets the count, min, max and mean metric aggregates
+// among the given ones.
+func publishMetricAggregates(q aggregatesStore, valueKey string, aggregates map[string]float64) {
+	// in a fixed order, for reproducible outputs
+	for _, aggregateType := range []string{"count", "min", "max", "mean"} {
+		v, ok := aggregates[aggregateType]
+		if !ok {
+			continue
+		}
+		if err := q.AddMetricAggregate(valueKey, aggregateType, v); err != nil {
+			log.Printf("ERROR AddMetricAggregate for %s %s: %v", valueKey, aggregateType, err)
+		}
+	}
+}
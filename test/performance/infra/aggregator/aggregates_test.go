@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeAggregatesStore records the published aggregates and sample points.
+type fakeAggregatesStore struct {
+	run    map[string]float64
+	metric map[string]map[string]float64
+	// y values of the sample points of each value key, in publication order
+	samples map[string][]float64
+}
+
+func newFakeAggregatesStore() *fakeAggregatesStore {
+	return &fakeAggregatesStore{
+		run:     make(map[string]float64),
+		metric:  make(map[string]map[string]float64),
+		samples: make(map[string][]float64),
+	}
+}
+
+func (f *fakeAggregatesStore) AddSamplePoint(_ float64, valueKeyToYVals map[string]float64) error {
+	for k, v := range valueKeyToYVals {
+		f.samples[k] = append(f.samples[k], v)
+	}
+	return nil
+}
+
+func (f *fakeAggregatesStore) AddRunAggregate(valueKey string, value float64) error {
+	f.run[valueKey] = value
+	return nil
+}
+
+func (f *fakeAggregatesStore) AddMetricAggregate(valueKey string, aggregateType string, value float64) error {
+	if f.metric[valueKey] == nil {
+		f.metric[valueKey] = make(map[string]float64)
+	}
+	f.metric[valueKey][aggregateType] = value
+	return nil
+}
+
+// latencies returns n sorted latencies, from 1ms to n ms.
+func latencies(n int) []time.Duration {
+	l := make([]time.Duration, n)
+	for i := range l {
+		l[i] = time.Duration(i+1) * time.Millisecond
+	}
+	return l
+}
+
+func TestRunAggregatesLowSample(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		delivered     int
+		wantLowSample bool
+	}{
+		{"below the minimum", 5, true},
+		{"at the minimum", 10, false},
+		{"above the minimum", 50, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := runAggregates{
+				publishErrors:            3,
+				deliverErrors:            2,
+				oversizeRejected:         1,
+				publishLatencies:         latencies(tc.delivered + 2),
+				e2eLatencies:             latencies(tc.delivered),
+				queueingDelays:           map[string]time.Duration{"q99": time.Millisecond},
+				modes:                    &bimodality{},
+				minSamplesForPercentiles: 10,
+			}
+			q := newFakeAggregatesStore()
+			a.publish(q)
+
+			// counts are published whatever the number of samples
+			if q.run["pe"] != 3 || q.run["de"] != 2 || q.run["or"] != 1 {
+				t.Errorf("pe, de, or = %v, %v, %v, want 3, 2, 1", q.run["pe"], q.run["de"], q.run["or"])
+			}
+
+			wantFlag := 0.0
+			if tc.wantLowSample {
+				wantFlag = 1
+			}
+			if got, ok := q.run["ls"]; !ok || got != wantFlag {
+				t.Errorf("ls = %v (published: %v), want %v", got, ok, wantFlag)
+			}
+
+			_, qdPublished := q.run["q99"]
+			_, modesPublished := q.run["m1"]
+			if qdPublished == tc.wantLowSample || modesPublished == tc.wantLowSample {
+				t.Errorf("percentile aggregates published: %v and %v, want %v", qdPublished, modesPublished, !tc.wantLowSample)
+			}
+
+			if !tc.wantLowSample {
+				if len(q.metric) != 0 {
+					t.Errorf("metric aggregates = %v, want none so that Mako calculates them", q.metric)
+				}
+				return
+			}
+			for key, want := range map[string]int{"pl": tc.delivered + 2, "dl": tc.delivered} {
+				m := q.metric[key]
+				if m["count"] != float64(want) {
+					t.Errorf("%s count = %v, want %d", key, m["count"], want)
+				}
+				if m["min"] != 0.001 || m["max"] != float64(want)/1000 {
+					t.Errorf("%s min, max = %v, %v, want 0.001, %v", key, m["min"], m["max"], float64(want)/1000)
+				}
+			}
+		})
+	}
+}
+
+func TestRunAggregatesDegenerate(t *testing.T) {
+	sent := time.Now()
+	events := []event{
+		{id: "rejected", sent: sent},
+		{id: "lost", sent: sent, accepted: sent.Add(time.Millisecond), isAccepted: true},
+	}
+	degenerate := isDegenerate(events)
+	if !degenerate {
+		t.Fatal("isDegenerate() = false for an all-failure run, want true")
+	}
+
+	a := runAggregates{
+		publishErrors:            1,
+		deliverErrors:            1,
+		publishLatencies:         publishLatencies(events),
+		e2eLatencies:             e2eLatencies(events),
+		minSamplesForPercentiles: defaultMinSamplesForPercentiles,
+		degenerate:               &degenerate,
+	}
+	q := newFakeAggregatesStore()
+	a.publish(q)
+
+	if q.run["pe"] != 1 || q.run["de"] != 1 {
+		t.Errorf("pe, de = %v, %v, want 1, 1", q.run["pe"], q.run["de"])
+	}
+	if got, ok := q.run["dg"]; !ok || got != 1 {
+		t.Errorf("dg = %v (published: %v), want 1", got, ok)
+	}
+	if _, ok := q.run["ls"]; ok {
+		t.Error("ls published in a degenerate run, want no latency aggregate")
+	}
+	if len(q.metric) != 0 {
+		t.Errorf("metric aggregates = %v, want no latency published", q.metric)
+	}
+
+	events = append(events, event{id: "delivered", sent: sent, accepted: sent, received: sent.Add(time.Millisecond), isAccepted: true, isReceived: true})
+	if isDegenerate(events) {
+		t.Error("isDegenerate() = true with a delivered event, want false")
+	}
+}
@@ -18,16 +18,18 @@ package aggregator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/mako/go/quickstore"
-
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
@@ -43,7 +45,7 @@ import (
 )
 
 const (
-	maxRcvMsgSize         = 1024 * 1024 * 1024
+	defaultMaxRecvMsgSize = 1024 * 1024 * 1024
 	publishFailureMessage = "Publish failure"
 	deliverFailureMessage = "Delivery failure"
 )
@@ -52,11 +54,107 @@ const (
 type eventsRecord struct {
 	sync.RWMutex
 	*pb.EventsRecord
+
+	// source of the records which reported each event
+	sources map[string]string
+	// composite key of each event, when matching events on their composite key,
+	// or partition key and sequence number of each event numbered by its sender
+	keys map[string]*pb.EventKey
+	// sources of the records, e.g. the senders for the sent events record
+	producers map[string]struct{}
+	// monotonic clock of the readings of each event, when paired with its timestamp
+	clocks map[string]string
+	// number of events recorded, including the duplicates, and number of duplicates
+	attempts   uint64
+	duplicated uint64
+	// all the timestamps of each event recorded several times, when recording them
+	deliveries map[string][]*timestamp.Timestamp
+	// how to collapse the timestamps of the duplicates, and the timestamps of
+	// the duplicates of each event kept by the collapse policy
+	collapsePolicy string
+	duplicates     map[string][]*timestamp.Timestamp
+	// earliest and latest timestamps of each event recorded several times
+	spreads map[string]*timeSpread
+	// arrival time of each event not matched yet, when unmatched events expire
+	inserted map[string]time.Time
 }
 
-var (
-	fatalf = log.Fatalf
+// addResult tells how an event was recorded.
+type addResult int
+
+const (
+	// the event is a duplicate whose timestamp was dropped
+	eventIgnored addResult = iota
+	// the event was recorded for the first time
+	eventAdded
+	// the event is a duplicate whose timestamp replaced the recorded one, as
+	// per the collapse policy
+	eventReplaced
+)
+
+// add records the event with the given ID, collapsing the duplicates with the
+// collapse policy of the record, telling how it was recorded. It must be
+// called with the lock held.
+func (rec *eventsRecord) add(id string, t *timestamp.Timestamp, source string) addResult {
+	rec.attempts++
+	if recorded, exists := rec.Events[id]; exists {
+		rec.duplicated++
+		rec.trackSpread(id, recorded, t)
+		rec.trackDeliveries(id, recorded, t)
+		if rec.collapsePolicy == "" {
+			log.Printf("!! Found duplicate %s event ID %s", rec.Type, id)
+		}
+		if rec.collapse(id, t, source) {
+			return eventReplaced
+		}
+		return eventIgnored
+	}
+	rec.Events[id] = t
+	if source != "" {
+		rec.sources[id] = source
+	}
+	return eventAdded
+}
 
+// addMonotonic pairs the wall clock timestamp of the recorded event with the
+// given reading of the monotonic clock. It must be called with the lock held.
+func (rec *eventsRecord) addMonotonic(id string, reading int64, clock string) {
+	rec.Monotonic[id] = reading
+	rec.clocks[id] = clock
+}
+
+// addKeyed records the event with the given composite key, collapsing the
+// duplicates like add. It must be called with the lock held.
+func (rec *eventsRecord) addKeyed(key *pb.EventKey, t *timestamp.Timestamp, source string) (string, addResult) {
+	id := compositeID(key)
+	if _, exists := rec.keys[id]; !exists {
+		rec.keys[id] = key
+	}
+	return id, rec.add(id, t, source)
+}
+
+func newEventsRecord(t pb.EventsRecord_Type) *eventsRecord {
+	return &eventsRecord{
+		EventsRecord: &pb.EventsRecord{
+			Type:               t,
+			Events:             make(map[string]*timestamp.Timestamp),
+			Monotonic:          make(map[string]int64),
+			ContentHashes:      make(map[string]uint32),
+			ChecksumMismatches: make(map[string]bool),
+			Attempts:           make(map[string]uint32),
+			OnWire:             make(map[string]*timestamp.Timestamp),
+			PayloadSizes:       make(map[string]uint32),
+		},
+		sources:    make(map[string]string),
+		keys:       make(map[string]*pb.EventKey),
+		clocks:     make(map[string]string),
+		producers:  make(map[string]struct{}),
+		duplicates: make(map[string][]*timestamp.Timestamp),
+		spreads:    make(map[string]*timeSpread),
+	}
+}
+
+var (
 	pea = &tpb.ThresholdAnalyzerInput{
 		Name: ptr.String("Publish error throughput"),
 		Configs: []*tpb.ThresholdConfig{{
@@ -87,240 +185,1241 @@ type Aggregator struct {
 	acceptedEvents *eventsRecord
 	receivedEvents *eventsRecord
 
-	// channel to notify the main goroutine that an events record has been received
-	notifyEventsReceived chan struct{}
+	// counts the events records received, waking the main goroutine up
+	records *recordsCounter
+
+	// set once all the expected records have been received, after which records
+	// are rejected as late, and closed channel signaling it
+	completion      sync.RWMutex
+	completed       bool
+	recordsComplete chan struct{}
+
+	// senders and receivers which reported their final records
+	doneSources *doneSources
+	// senders and receivers which registered, and the records they uploaded
+	registered *registeredSources
+
+	// closed when an orchestrator requests to finish without waiting for the
+	// remaining expected records
+	finishRequested chan struct{}
+	finishOnce      sync.Once
+
+	// how long to wait for the expected records before computing the results
+	// with the ones received so far, 0 to wait indefinitely, and number of the
+	// expected records missing when the results are computed early
+	recordsTimeout time.Duration
+	missingRecords uint
+
+	// how to collapse the multiple received timestamps of an event
+	receivedCollapse string
+
+	// IDs of the submissions of records seen recently, and how long they are remembered
+	submissions   *submissionCache
+	submissionTTL time.Duration
+
+	// Mako tags reported by the senders and receivers
+	producerTags *producerTags
+
+	// hashes of the events record lists received recently, to ignore the
+	// identical ones, and how long they are remembered, 0 not to detect them
+	batches     *submissionCache
+	batchWindow time.Duration
+
+	// how long accepted and received events without sent event are kept, 0 to
+	// keep them, and number of events expired
+	unmatchedTTL time.Duration
+	abandoned    uint64
+
+	// number of events record lists whose recording panicked
+	recordPanics uint64
+
+	// clock of the aggregator, replaced in tests
+	now func() time.Time
+
+	// how to compute the published throughputs
+	throughputSmoothing throughputSmoothing
+	// decay factor of the exponentially weighted averages of the throughputs
+	// published alongside, if any
+	ewmaDecay float64
+
+	// whether to detect the gaps in the sequences of the event IDs, the pattern
+	// of the sequential IDs and their parser
+	detectSequenceGaps bool
+	sequenceIDPattern  string
+	sequenceParser     sequenceParser
+	// whether to verify the order in which the events numbered by their sender
+	// are received
+	verifyOrdering bool
+
+	// additional percentiles of the end to end latencies, e.g. 99.9
+	highPercentiles []float64
+
+	// sinks receiving the summary of the run, in addition to the exporter
+	resultSinks []ResultSink
+	// kind of the exporter the results are published with, and its
+	// destination for the InfluxDB and file exporters
+	exporter       string
+	exporterOutput string
+
+	// windows marked during the run, whose events are excluded from the results
+	windows *markedWindows
+	// durations at the beginning and the end of the run whose events are
+	// excluded from the results as warmup and cooldown, 0 not to exclude them
+	warmupExclusion, cooldownExclusion time.Duration
+
+	// artificial delay before handling each events record list, to simulate a slow aggregator
+	recordDelay time.Duration
+
+	// how long to keep accepting late records once all the expected ones have been received
+	linger time.Duration
+
+	// how long stopping the server waits for the RPCs in flight before
+	// stopping it forcibly, 0 to wait for them indefinitely
+	gracefulStopTimeout time.Duration
 
 	// GRPC server
-	listener net.Listener
-	server   *grpc.Server
+	listener       net.Listener
+	server         *grpc.Server
+	maxRecvMsgSize int
+	oversize       *oversizeDetector
 
 	publishResults bool
 	makoTags       []string
 	expectRecords  uint
+
+	// identifies the run in the published results and the exported files
+	runID string
+
+	// labels the results of the scenario this aggregator runs, if any, and
+	// suffixes it to the value keys, so that scenarios reporting to the same
+	// benchmark don't blend
+	scenario           string
+	scenarioSuffixKeys bool
+
+	reportWorstSender   bool
+	reportQueueingDelay bool
+	reportPhaseTable    bool
+	reportSenderTable   bool
+	reportSenderGini    bool
+	shardBreakdown      bool
+	detectBimodality    bool
+	reportGoodput       bool
+
+	// report the sender queueing delays and the end to end latencies net of them
+	reportSenderQueueing bool
+
+	// conditions on the aggregates which must hold for the run to pass, as
+	// configured and parsed
+	assertionSources []string
+	assertions       []*assertion
+	// limits of the results the run must stay within to pass
+	sloThresholds SLOThresholds
+
+	// weights of the components of the stability score
+	stabilityWeights stabilityWeights
+
+	// number of delivered events below which percentiles aren't published
+	minSamplesForPercentiles int
+
+	// skip publishing the latencies of the runs without any delivered event
+	skipDegenerate bool
+
+	// maximum number of delivered events the percentiles are computed over, 0
+	// for all of them
+	percentileSampleCap int
+
+	// stream the latencies of the events as they are matched, buffering up to
+	// latencyStreamBuffer samples for each subscriber, nil hub if not
+	streamLatencies     bool
+	latencyStreamBuffer int
+	latencies           *latencyHub
+
+	// statistics the events are folded into as they are recorded, nil unless
+	// aggregating them as a stream
+	streamingAggregation bool
+	streaming            *streamingStats
+
+	// address to serve the live metrics on, if any, and their server
+	liveMetricsAddr string
+	live            *liveMetrics
+
+	// counts and latencies of the events kept up to date as they're recorded,
+	// for the progress reports
+	progressTracker *progressTracker
+
+	// times spent merging the events record lists, nil if not measured
+	recorderTimes *recorderTimer
+
+	// end to end latency above which the delivered events are deadline misses,
+	// 0 to disable it
+	eventDeadline time.Duration
+
+	// number of senders expected to send records, 0 if unknown
+	expectSenders int
+
+	// number of bootstrap resamples of the confidence intervals of the percentiles, 0 to disable them
+	bootstrapResamples int
+
+	// number of the worst negative latencies of each type to detail
+	clockSkewCases int
+
+	// match events on their composite key rather than on their ID
+	compositeKeys bool
+
+	// path of the SQLite database of the joined events, if any
+	sqlitePath string
+
+	// path of the NDJSON export of the events, if any, ndjsonStdout for the standard output
+	ndjsonPath string
+
+	// path of the CSV export of the events, if any
+	csvPath string
+	// path of the Parquet export of the events, if any
+	parquetPath string
+	// path of the export of the deliveries of the events received several times, if any
+	duplicatesPath string
+	// path of the export of the events which were not accepted or not
+	// received, if any, failedEventsLog to log them
+	failedEventsPath string
+
+	// path of the plot data file, if any, and the duration of its windows
+	plotDataPath   string
+	plotDataWindow time.Duration
+
+	// path of the time-sorted stream of the steps and failures of the events, if any
+	timelinePath string
+
+	// path of the end to end latency CDF, if any
+	cdfPath string
+
+	// path of the HdrHistogram percentile distribution of the end to end
+	// latencies, if any
+	hdrHistogramPath string
+
+	// path of the binary dump of the publish and end to end latencies, if any
+	latencySamplesPath string
+
+	// path of the JSON summary of the run, if any
+	summaryPath string
+
+	// path of the tidy file of the throughput series, if any
+	throughputSeriesPath string
+
+	// barrier of the RecordEvents handlers in flight at the shutdown, nil if not enforced
+	barrier *recordBarrier
+
+	// format of the one-line summary of the run and the path it is written to, if any
+	shortSummaryFormat string
+	shortSummaryPath   string
+	shortSummaryFields []shortSummaryField
+	// one-line summary of the last run
+	shortSummary string
+
+	// report the end to end latency percentiles weighted by the inverse send rate
+	timeWeightedPercentiles bool
+
+	// directory of the exported files whose path isn't set, if any
+	outputDir string
+
+	// duration of the buckets of the time of day table, 0 not to report it, and their period
+	timeOfDayBucket time.Duration
+	timeOfDayPeriod time.Duration
+
+	// path of the end to end latency CDF of the reference run to compare with,
+	// if any, its loaded percentiles, and the relative delta above which a
+	// percentile is flagged as regressed or improved
+	referenceCDFPath      string
+	referenceCDF          []cdfPoint
+	referenceCDFTolerance float64
+
+	// path of the latency heatmap, if any, and the duration of its windows
+	heatmapPath   string
+	heatmapWindow time.Duration
+
+	// duration of the windows of the latency anomaly detection, 0 to disable it,
+	// number of windows of the baseline, and factor of the baseline above which
+	// the p99 latency of a window is anomalous
+	anomalyWindow          time.Duration
+	anomalyBaselineWindows int
+	anomalyFactor          float64
+
+	// duration of the windows of the latency periodicity detection, 0 to disable it
+	periodicityWindow time.Duration
+
+	// duration of the windows of the acceptance rate time series, 0 to disable it
+	acceptanceWindow time.Duration
+
+	// sample the memory statistics of the aggregator
+	reportMemory bool
+
+	// how often to log the duplicate rates while waiting for the records, 0 to disable it
+	duplicateRateInterval time.Duration
+
+	// certificate and key of the aggregator, to serve over TLS, and CAs of the
+	// client certificates the senders and receivers must present, if any
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+
+	// file the events record lists are checkpointed to, to resume the run
+	// after a restart, and the checkpoint
+	checkpointPath string
+	checkpoint     *checkpoint
+
+	// clock offsets of the sources of records, when anchoring latencies on the arrival time of the records
+	clockOffsets *clockOffsets
+
+	// number of pings of the clock sync of each sender and receiver, 0 not to
+	// sync the clocks, and the clock offsets it measured
+	clockSyncRounds int
+	clockSync       *clockSync
+
+	// fatalf reports an unrecoverable error, exiting the process by default
+	fatalf func(format string, args ...interface{})
+
+	// goroutines spawned by the aggregator and still running
+	goroutines      int32
+	checkTeardown   bool
+	teardownTimeout time.Duration
 }
 
-func NewAggregator(listenAddr string, expectRecords uint, makoTags []string, publishResults bool) (common.Executor, error) {
-	l, err := net.Listen("tcp", listenAddr)
+func NewAggregator(listenAddr string, expectRecords uint, makoTags []string, publishResults bool, opts ...AggregatorOption) (common.Executor, error) {
+	executor := &Aggregator{
+		records:                  newRecordsCounter(),
+		recordsComplete:          make(chan struct{}),
+		finishRequested:          make(chan struct{}),
+		progressTracker:          newProgressTracker(),
+		windows:                  newMarkedWindows(),
+		doneSources:              newDoneSources(expectRecords),
+		registered:               newRegisteredSources(),
+		makoTags:                 makoTags,
+		expectRecords:            expectRecords,
+		publishResults:           publishResults,
+		exporter:                 MakoExporter,
+		fatalf:                   log.Fatalf,
+		teardownTimeout:          defaultTeardownTimeout,
+		gracefulStopTimeout:      defaultGracefulStopTimeout,
+		clockSkewCases:           defaultClockSkewCases,
+		anomalyBaselineWindows:   defaultAnomalyBaselineWindows,
+		anomalyFactor:            defaultAnomalyFactor,
+		minSamplesForPercentiles: defaultMinSamplesForPercentiles,
+		maxRecvMsgSize:           defaultMaxRecvMsgSize,
+		submissionTTL:            defaultSubmissionTTL,
+		timeOfDayPeriod:          defaultTimeOfDayPeriod,
+		stabilityWeights:         defaultStabilityWeights,
+		now:                      time.Now,
+		referenceCDFTolerance:    defaultReferenceCDFTolerance,
+		latencyStreamBuffer:      defaultLatencyStreamBuffer,
+	}
+	for _, opt := range opts {
+		opt(executor)
+	}
+	if err := executor.applyOutputDir(); err != nil {
+		return nil, err
+	}
+	if executor.plotDataPath != "" && executor.plotDataWindow <= 0 {
+		return nil, fmt.Errorf("invalid plot data window %v, must be positive", executor.plotDataWindow)
+	}
+	if executor.heatmapPath != "" && executor.heatmapWindow <= 0 {
+		return nil, fmt.Errorf("invalid heatmap window %v, must be positive", executor.heatmapWindow)
+	}
+	if executor.recordsTimeout < 0 {
+		return nil, fmt.Errorf("invalid records timeout %v, must not be negative", executor.recordsTimeout)
+	}
+	if executor.warmupExclusion < 0 || executor.cooldownExclusion < 0 {
+		return nil, fmt.Errorf("invalid warmup and cooldown exclusions %v and %v, must not be negative",
+			executor.warmupExclusion, executor.cooldownExclusion)
+	}
+	if executor.streamingAggregation && (executor.warmupExclusion > 0 || executor.cooldownExclusion > 0) {
+		return nil, fmt.Errorf("the warmup and cooldown exclusions aren't supported in streaming aggregation")
+	}
+	if executor.streamingAggregation && executor.verifyOrdering {
+		return nil, fmt.Errorf("the ordering verification isn't supported in streaming aggregation")
+	}
+	if executor.streamingAggregation && executor.shardBreakdown {
+		return nil, fmt.Errorf("the shard breakdown isn't supported in streaming aggregation")
+	}
+	if executor.clockSyncRounds < 0 {
+		return nil, fmt.Errorf("invalid clock sync rounds %d, must not be negative", executor.clockSyncRounds)
+	}
+	if executor.streamingAggregation && executor.clockSyncRounds > 0 {
+		return nil, fmt.Errorf("the clock skew correction isn't supported in streaming aggregation")
+	}
+	if executor.clockSyncRounds > 0 {
+		executor.clockSync = newClockSync(executor.clockSyncRounds)
+	}
+	if executor.anomalyWindow < 0 {
+		return nil, fmt.Errorf("invalid anomaly window %v, must not be negative", executor.anomalyWindow)
+	}
+	if executor.anomalyWindow > 0 && (executor.anomalyBaselineWindows <= 0 || executor.anomalyFactor <= 1) {
+		return nil, fmt.Errorf("invalid anomaly baseline of %d windows and factor %v, must be positive and above 1",
+			executor.anomalyBaselineWindows, executor.anomalyFactor)
+	}
+	if executor.detectSequenceGaps {
+		pattern := executor.sequenceIDPattern
+		if pattern == "" {
+			pattern = defaultSequenceIDPattern
+		}
+		parser, err := newSequenceParser(pattern)
+		if err != nil {
+			return nil, err
+		}
+		executor.sequenceParser = parser
+	}
+	if executor.recordDelay < 0 {
+		return nil, fmt.Errorf("invalid record delay %v, must not be negative", executor.recordDelay)
+	}
+	if executor.acceptanceWindow < 0 {
+		return nil, fmt.Errorf("invalid acceptance rate window %v, must not be negative", executor.acceptanceWindow)
+	}
+	if executor.duplicateRateInterval < 0 {
+		return nil, fmt.Errorf("invalid duplicate rate interval %v, must not be negative", executor.duplicateRateInterval)
+	}
+	if executor.expectSenders < 0 {
+		return nil, fmt.Errorf("invalid number of expected senders %d, must not be negative", executor.expectSenders)
+	}
+	if executor.gracefulStopTimeout < 0 {
+		return nil, fmt.Errorf("invalid graceful stop timeout %v, must not be negative", executor.gracefulStopTimeout)
+	}
+	if executor.linger < 0 {
+		return nil, fmt.Errorf("invalid linger %v, must not be negative", executor.linger)
+	}
+	if executor.bootstrapResamples < 0 {
+		return nil, fmt.Errorf("invalid number of bootstrap resamples %d, must not be negative", executor.bootstrapResamples)
+	}
+	if executor.clockSkewCases < 0 {
+		return nil, fmt.Errorf("invalid number of clock skew cases %d, must not be negative", executor.clockSkewCases)
+	}
+	if err := validateScenario(executor.scenario); err != nil {
+		return nil, err
+	}
+	if executor.scenarioSuffixKeys && executor.scenario == "" {
+		return nil, errors.New("invalid scenario suffix of the value keys without scenario")
+	}
+	if executor.referenceCDFPath != "" {
+		if executor.referenceCDFTolerance < 0 {
+			return nil, fmt.Errorf("invalid reference CDF tolerance %v, must not be negative", executor.referenceCDFTolerance)
+		}
+		reference, err := loadCDF(executor.referenceCDFPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference CDF: %v", err)
+		}
+		executor.referenceCDF = reference
+	}
+	if executor.streamLatencies {
+		if executor.latencyStreamBuffer <= 0 {
+			return nil, fmt.Errorf("invalid latency stream buffer %d, must be positive", executor.latencyStreamBuffer)
+		}
+		executor.latencies = newLatencyHub(executor.latencyStreamBuffer)
+	}
+	if executor.periodicityWindow < 0 {
+		return nil, fmt.Errorf("invalid periodicity window %v, must not be negative", executor.periodicityWindow)
+	}
+	if executor.eventDeadline < 0 {
+		return nil, fmt.Errorf("invalid event deadline %v, must not be negative", executor.eventDeadline)
+	}
+	if executor.shortSummaryFormat == "" {
+		executor.shortSummaryFormat = DefaultShortSummaryFormat
+	}
+	fields, err := parseShortSummaryFormat(executor.shortSummaryFormat)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create listener: %v", err)
+		return nil, err
 	}
+	executor.shortSummaryFields = fields
+	if executor.percentileSampleCap < 0 {
+		return nil, fmt.Errorf("invalid percentile sample cap %d, must not be negative", executor.percentileSampleCap)
+	}
+	if executor.percentileSampleCap > 0 {
+		executor.makoTags = append(executor.makoTags, percentileSampleCapTag(executor.percentileSampleCap))
+	}
+	executor.applyScenario()
+	executor.applyRunID()
 
-	executor := &Aggregator{
-		listener:             l,
-		notifyEventsReceived: make(chan struct{}),
-		makoTags:             makoTags,
-		expectRecords:        expectRecords,
-		publishResults:       publishResults,
+	if err := validateHighPercentiles(executor.highPercentiles); err != nil {
+		return nil, err
+	}
+	if err := validateCollapse(executor.receivedCollapse); err != nil {
+		return nil, err
+	}
+	if err := executor.throughputSmoothing.validate(); err != nil {
+		return nil, err
+	}
+	if executor.ewmaDecay < 0 || executor.ewmaDecay >= 1 {
+		return nil, fmt.Errorf("invalid throughput decay factor %v, must be within (0, 1)", executor.ewmaDecay)
+	}
+	if executor.submissionTTL <= 0 {
+		return nil, fmt.Errorf("invalid submission TTL %v, must be positive", executor.submissionTTL)
+	}
+	executor.submissions = newSubmissionCache(executor.submissionTTL)
+	executor.producerTags = newProducerTags()
+	if err := validateTimeOfDayBuckets(executor.timeOfDayBucket, executor.timeOfDayPeriod); err != nil {
+		return nil, err
+	}
+	if executor.assertions, err = parseAssertions(executor.assertionSources); err != nil {
+		return nil, err
+	}
+	if err := executor.sloThresholds.validate(); err != nil {
+		return nil, err
+	}
+	if err := executor.stabilityWeights.validate(); err != nil {
+		return nil, err
+	}
+	if executor.batchWindow < 0 {
+		return nil, fmt.Errorf("invalid duplicate batch window %v, must not be negative", executor.batchWindow)
+	}
+	if executor.batchWindow > 0 {
+		executor.batches = newSubmissionCache(executor.batchWindow)
+	}
+	if executor.unmatchedTTL < 0 {
+		return nil, fmt.Errorf("invalid unmatched TTL %v, must not be negative", executor.unmatchedTTL)
+	}
+	switch executor.exporter {
+	case MakoExporter:
+	case InfluxExporter, FileExporter:
+		if executor.exporterOutput == "" {
+			return nil, fmt.Errorf("the %s exporter requires an output", executor.exporter)
+		}
+	default:
+		return nil, fmt.Errorf("invalid exporter %q, must be one of %s, %s or %s", executor.exporter, MakoExporter, InfluxExporter, FileExporter)
 	}
 
+	serverOpts := []grpc.ServerOption{grpc.MaxRecvMsgSize(executor.maxRecvMsgSize)}
+	if executor.tlsCertFile != "" || executor.tlsKeyFile != "" || executor.tlsClientCAFile != "" {
+		if executor.tlsCertFile == "" || executor.tlsKeyFile == "" {
+			return nil, errors.New("serving over TLS requires both a certificate and a key")
+		}
+		config, err := common.ServerTLSConfig(executor.tlsCertFile, executor.tlsKeyFile, executor.tlsClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create listener: %v", err)
+	}
+	executor.listener = l
+
 	// --- Create GRPC server
-	s := grpc.NewServer(grpc.MaxRecvMsgSize(maxRcvMsgSize))
+	executor.oversize = &oversizeDetector{maxRecvMsgSize: executor.maxRecvMsgSize, records: executor.records}
+	s := grpc.NewServer(append(serverOpts, grpc.StatsHandler(executor.oversize))...)
 	pb.RegisterEventsRecorderServer(s, executor)
 	executor.server = s
 
 	// --- Initialize records maps
-	executor.sentEvents = &eventsRecord{EventsRecord: &pb.EventsRecord{
-		Type:   pb.EventsRecord_SENT,
-		Events: make(map[string]*timestamp.Timestamp),
-	}}
-	executor.acceptedEvents = &eventsRecord{EventsRecord: &pb.EventsRecord{
-		Type:   pb.EventsRecord_ACCEPTED,
-		Events: make(map[string]*timestamp.Timestamp),
-	}}
-	executor.receivedEvents = &eventsRecord{EventsRecord: &pb.EventsRecord{
-		Type:   pb.EventsRecord_RECEIVED,
-		Events: make(map[string]*timestamp.Timestamp),
-	}}
+	executor.sentEvents = newEventsRecord(pb.EventsRecord_SENT)
+	executor.acceptedEvents = newEventsRecord(pb.EventsRecord_ACCEPTED)
+	executor.receivedEvents = newEventsRecord(pb.EventsRecord_RECEIVED)
+	executor.receivedEvents.collapsePolicy = executor.receivedCollapse
+	if executor.duplicatesPath != "" {
+		executor.receivedEvents.deliveries = make(map[string][]*timestamp.Timestamp)
+	}
+	if executor.unmatchedTTL > 0 {
+		executor.acceptedEvents.inserted = make(map[string]time.Time)
+		executor.receivedEvents.inserted = make(map[string]time.Time)
+		if executor.streamingAggregation {
+			// to fold the sent events which never complete as they expire
+			executor.sentEvents.inserted = make(map[string]time.Time)
+		}
+	}
+
+	if executor.streamingAggregation {
+		executor.streaming = newStreamingStats()
+	}
+
+	if executor.liveMetricsAddr != "" {
+		if executor.live, err = newLiveMetrics(executor, executor.liveMetricsAddr); err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+	}
 
 	return executor, nil
 }
 
+// withShutdown wraps the fatal handler so that it shuts the Mako sidecar down
+// first, or our sidecar would live forever.
+func withShutdown(fatalf func(string, ...interface{}), shutdown func(context.Context)) func(string, ...interface{}) {
+	return func(f string, args ...interface{}) {
+		shutdown(context.Background())
+		fatalf(f, args...)
+	}
+}
+
 func (ag *Aggregator) Run(ctx context.Context) {
 	var err error
-	var client *mako.Client
-	if ag.publishResults {
-		log.Printf("Configuring Mako")
+	var exporter Exporter
+	var store resultsStore
+	fatalf := ag.fatalf
+	log.Printf("==== Run ID: %s ====", ag.runID)
+	if ag.scenario != "" {
+		log.Printf("==== Scenario: %s ====", ag.scenario)
+	}
 
-		makoClientCtx, cancel := context.WithTimeout(ctx, time.Minute*10)
-		defer cancel()
+	if ag.publishResults {
+		log.Printf("Configuring the %s exporter", ag.exporter)
 
-		client, err = mako.Setup(makoClientCtx, ag.makoTags...)
+		exporter, err = ag.newExporter(ctx)
 		if err != nil {
-			fatalf("Failed to setup mako: %v", err)
+			fatalf("Failed to setup the %s exporter: %v", ag.exporter, err)
+			return
 		}
 
-		// Add Analyzers to detect performance regression.
-		client.Quickstore.Input.ThresholdInputs = append(
-			client.Quickstore.Input.ThresholdInputs,
-			pea,
-			dea)
+		store = exporterStore{exporter}
+		if ag.scenarioSuffixKeys {
+			// publish under the value keys of the scenario
+			store = scenarioStore{resultsStore: store, scenario: ag.scenario}
+		}
 
 		// Use a fresh context here so that our RPC to terminate the sidecar
 		// isn't subject to our timeout (or we won't shut it down when we time out)
-		defer client.ShutDownFunc(context.Background())
+		defer exporter.Close(context.Background())
 
-		// Wrap fatalf in a helper or our sidecar will live forever.
-		fatalf = func(f string, args ...interface{}) {
-			client.ShutDownFunc(context.Background())
-			log.Fatalf(f, args...)
-		}
+		fatalf = withShutdown(fatalf, exporter.Close)
 
 	} else {
-		log.Printf("Results won't be published to mako-stub")
+		log.Printf("Results won't be published")
+	}
+
+	var memStats *memStatsSampler
+	if ag.reportMemory {
+		memStats = ag.startMemStatsSampler(memStatsInterval)
+	}
+
+	// restored before serving, so that the records uploaded again after a
+	// restart are recognized
+	if ag.checkpointPath != "" {
+		if err := ag.restoreCheckpoint(); err != nil {
+			fatalf("Failed to restore the checkpoint: %v", err)
+			return
+		}
 	}
 
 	// --- Run GRPC events receiver
 	log.Printf("Starting events recorder server")
 
-	go func() {
+	ag.spawn(func() {
 		if err := ag.server.Serve(ag.listener); err != nil {
 			fatalf("Failed to serve: %v", err)
 		}
-	}()
-	go func() {
-		<-ctx.Done()
-		log.Printf("Terminating events recorder server")
-		ag.server.GracefulStop()
-	}()
+	})
+	if ag.unmatchedTTL > 0 {
+		ag.spawn(ag.expireUnmatchedPeriodically)
+	}
+	if ag.live != nil {
+		ag.spawn(ag.live.serve)
+	}
+
+	serverStopped := make(chan struct{})
+	ag.spawn(func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("Terminating events recorder server")
+			ag.stopServer()
+		case <-serverStopped:
+		}
+	})
 
 	// --- Wait for all records
 	log.Printf("Expecting %d events records", ag.expectRecords)
-	ag.waitForEvents()
-	log.Printf("Received all expected events records")
+	var stopDuplicateRates func()
+	if ag.duplicateRateInterval > 0 {
+		stopDuplicateRates = ag.startDuplicateRateReport(ag.duplicateRateInterval)
+	}
+	if ag.waitForEvents() {
+		log.Printf("Received all expected events records")
+	}
+	if ag.barrier != nil {
+		ag.barrier.closeAndWait()
+	}
+	ag.complete()
+	if stopDuplicateRates != nil {
+		stopDuplicateRates()
+	}
 
-	ag.server.GracefulStop()
+	stopServer := func() {
+		ag.stopServer()
+		close(serverStopped)
+	}
+	lingerEnd := time.Now().Add(ag.linger)
+	if ag.linger > 0 {
+		log.Printf("Replying to the late events records for %v", ag.linger)
+	} else {
+		stopServer()
+	}
+
+	// finish stops the servers once the linger is over, and fails the run if
+	// its teardown isn't clean, SLO thresholds were violated or assertions failed
+	finish := func(failures runFailures) {
+		if ag.linger > 0 {
+			select {
+			case <-time.After(time.Until(lingerEnd)):
+			case <-ctx.Done():
+			}
+			log.Printf("Stopping the events recorder server")
+			stopServer()
+		}
+		if ag.live != nil {
+			ag.live.shutdown(ag.gracefulStopTimeout)
+		}
+
+		if ag.checkTeardown {
+			if err := ag.verifyTeardown(); err != nil {
+				fatalf("Aggregator teardown is not clean: %v", err)
+			} else {
+				log.Printf("Aggregator teardown is clean")
+			}
+		}
+
+		if ag.outputDir != "" {
+			logOutputDir(ag.outputDir)
+		}
+
+		if ag.checkpoint != nil {
+			if err := ag.checkpoint.remove(); err != nil {
+				log.Printf("ERROR removing the checkpoint %s: %v", ag.checkpointPath, err)
+			}
+		}
+
+		if msg := failures.message(len(ag.assertions)); msg != "" {
+			fatalf("%s", msg)
+		}
+
+		log.Printf("Aggregation completed")
+	}
 
 	// --- Publish latencies
 	log.Printf("Sent count: %d", len(ag.sentEvents.Events))
 	log.Printf("Accepted count: %d", len(ag.acceptedEvents.Events))
 	log.Printf("Received count: %d", len(ag.receivedEvents.Events))
+	distinctSenders := len(ag.sentEvents.producers)
+	log.Printf("Distinct senders: %d", distinctSenders)
+	if ag.expectSenders > 0 && distinctSenders != ag.expectSenders {
+		log.Printf("!! Expected %d senders but received sent events records from %d, some senders may never have connected",
+			ag.expectSenders, distinctSenders)
+	}
+	ag.registered.reportUnderdelivered()
+	ag.reportRecordPanics()
+	if rejected := ag.oversize.count(); rejected > 0 {
+		log.Printf("!! Rejected %d events record lists larger than %d bytes, their events are missing from the results", rejected, ag.maxRecvMsgSize)
+	}
+
+	if ag.streaming != nil {
+		finish(ag.publishStreamingResults(ctx, exporter, store, fatalf, distinctSenders, memStats))
+		return
+	}
 
 	log.Printf("Calculating latencies")
 
 	// count errors
 	publishErrorTimestamps := make([]time.Time, 0)
 	deliverErrorTimestamps := make([]time.Time, 0)
+	// sent timestamps of the delivered events, for the goodput
+	deliveredSentTimestamps := make([]time.Time, 0)
+
+	if ag.detectSequenceGaps {
+		sequences, unparsed := ag.trackedSequences()
+		reportSequenceGaps(findSequenceGaps(sequences), unparsed)
+	}
+
+	events := ag.joinEvents()
+	// corrected first, so that all the results are computed over the
+	// corrected received timestamps
+	var clockSkew *time.Duration
+	if ag.clockSync != nil {
+		clockSkew = reportClockSkew(events, ag.clockSync)
+	}
+	ag.windows.add(exclusionWindows(events, ag.warmupExclusion, ag.cooldownExclusion)...)
+	// events excluded from the throughputs, sent during the warmup or cooldown
+	var excluded map[string]struct{}
+	if windows := ag.windows.closeAll(ag.now()); len(windows) > 0 {
+		var during map[string][]event
+		events, during = splitByWindows(events, windows)
+		reportWindows(windows, during)
+		excluded = excludedFromThroughputs(during)
+	}
 
-	for sentID := range ag.sentEvents.Events {
-		timestampSentProto := ag.sentEvents.Events[sentID]
-		timestampSent, _ := ptypes.Timestamp(timestampSentProto)
+	var ndjson *ndjsonWriter
+	if ag.ndjsonPath != "" {
+		log.Printf("Streaming the events as NDJSON to %s", ag.ndjsonPath)
+		if ndjson, err = createNDJSONWriter(ag.ndjsonPath, ag.scenario); err != nil {
+			log.Printf("ERROR streaming the NDJSON events: %v", err)
+		}
+	}
+	var csvEvents *csvEventWriter
+	if ag.csvPath != "" {
+		log.Printf("Writing the events as CSV to %s", ag.csvPath)
+		if csvEvents, err = createCSVEventWriter(ag.csvPath); err != nil {
+			log.Printf("ERROR writing the CSV events: %v", err)
+		}
+	}
+	var parquetEvents *parquetEventWriter
+	if ag.parquetPath != "" {
+		log.Printf("Writing the events as Parquet to %s", ag.parquetPath)
+		if parquetEvents, err = createParquetEventWriter(ag.parquetPath, ag.scenario); err != nil {
+			log.Printf("ERROR writing the Parquet events: %v", err)
+		}
+	}
 
-		timestampAcceptedProto, accepted := ag.acceptedEvents.Events[sentID]
-		timestampAccepted, _ := ptypes.Timestamp(timestampAcceptedProto)
+	degenerate := ag.skipDegenerate && isDegenerate(events)
+	if degenerate {
+		log.Printf("!! None of the %d events was delivered, the latencies won't be published", len(events))
+	}
 
-		timestampReceivedProto, received := ag.receivedEvents.Events[sentID]
-		timestampReceived, _ := ptypes.Timestamp(timestampReceivedProto)
+	// events the latencies are computed over
+	sample := events
+	var sampled map[string]struct{}
+	if ag.percentileSampleCap > 0 {
+		sample = percentileSample(events, ag.percentileSampleCap)
+		log.Printf("Computing the latencies over %d delivered events, capped at %d", len(sample), ag.percentileSampleCap)
+		sampled = make(map[string]struct{}, len(sample))
+		for i := range sample {
+			sampled[sample[i].id] = struct{}{}
+		}
+	}
 
-		if !accepted {
+	for i := range events {
+		e := &events[i]
+		timestampSent := e.sent
+		publishEvent := ag.publishResults && !degenerate
+		if sampled != nil {
+			_, inSample := sampled[e.id]
+			publishEvent = publishEvent && inSample
+		}
+
+		if ndjson != nil {
+			ndjson.write(e)
+		}
+		if csvEvents != nil {
+			csvEvents.write(e)
+		}
+		if parquetEvents != nil {
+			parquetEvents.write(e)
+		}
+
+		if !e.isAccepted {
 			publishErrorTimestamps = append(publishErrorTimestamps, timestampSent)
 			continue
 		}
 
-		if ag.publishResults {
-			sendLatency := timestampAccepted.Sub(timestampSent)
-			// Uncomment to get CSV directly from this container log
-			// TODO add a flag to control whether we need this.
-			// fmt.Printf("%f,%d,\n", mako.XTime(timestampSent), sendLatency.Nanoseconds())
+		if publishEvent {
+			sendLatency := e.publishLatency()
 			// TODO mako accepts float64, which imo could lead to losing some precision on local tests. It should accept int64
-			if qerr := client.Quickstore.AddSamplePoint(mako.XTime(timestampSent), map[string]float64{"pl": sendLatency.Seconds()}); qerr != nil {
+			if qerr := store.AddSamplePoint(mako.XTime(timestampSent), map[string]float64{"pl": sendLatency.Seconds()}); qerr != nil {
 				log.Printf("ERROR AddSamplePoint for publish-latency: %v", qerr)
 			}
 		}
 
-		if !received {
+		if !e.isReceived {
 			deliverErrorTimestamps = append(deliverErrorTimestamps, timestampSent)
 			continue
 		}
+		if ag.reportGoodput {
+			deliveredSentTimestamps = append(deliveredSentTimestamps, timestampSent)
+		}
 
-		if ag.publishResults {
-			e2eLatency := timestampReceived.Sub(timestampSent)
-			// Uncomment to get CSV directly from this container log
-			// TODO add a flag to control whether we need this.
-			// fmt.Printf("%f,,%d\n", mako.XTime(timestampSent), e2eLatency.Nanoseconds())
+		if publishEvent {
+			e2eLatency := e.e2eLatency()
 			// TODO mako accepts float64, which imo could lead to losing some precision on local tests. It should accept int64
-			if qerr := client.Quickstore.AddSamplePoint(mako.XTime(timestampSent), map[string]float64{"dl": e2eLatency.Seconds()}); qerr != nil {
+			if qerr := store.AddSamplePoint(mako.XTime(timestampSent), map[string]float64{"dl": e2eLatency.Seconds()}); qerr != nil {
 				log.Printf("ERROR AddSamplePoint for deliver-latency: %v", qerr)
 			}
+			for _, t := range e.redelivered {
+				if qerr := store.AddSamplePoint(mako.XTime(timestampSent), map[string]float64{"dl": t.Sub(e.sent).Seconds()}); qerr != nil {
+					log.Printf("ERROR AddSamplePoint for deliver-latency: %v", qerr)
+				}
+			}
+		}
+	}
+
+	if ndjson != nil {
+		if err := ndjson.close(); err != nil {
+			log.Printf("ERROR writing the NDJSON events: %v", err)
+		}
+	}
+	if csvEvents != nil {
+		if err := csvEvents.close(); err != nil {
+			log.Printf("ERROR writing the CSV events: %v", err)
+		}
+	}
+	if parquetEvents != nil {
+		if err := parquetEvents.close(); err != nil {
+			log.Printf("ERROR writing the Parquet events: %v", err)
 		}
 	}
 
 	log.Printf("Publish failure count: %d", len(publishErrorTimestamps))
 	log.Printf("Delivery failure count: %d", len(deliverErrorTimestamps))
 
+	aggregates := runAggregates{
+		publishErrors:            len(publishErrorTimestamps),
+		deliverErrors:            len(deliverErrorTimestamps),
+		oversizeRejected:         ag.oversize.count(),
+		distinctSenders:          distinctSenders,
+		publishLatencies:         publishLatencies(sample),
+		e2eLatencies:             e2eLatencies(sample),
+		minSamplesForPercentiles: ag.minSamplesForPercentiles,
+	}
+	if ag.percentileSampleCap > 0 {
+		n := len(sample)
+		aggregates.sampleCount = &n
+	}
+	aggregates.clockSkew = clockSkew
+	if ag.skipDegenerate {
+		aggregates.degenerate = &degenerate
+	}
+	if ag.unmatchedTTL > 0 {
+		abandoned := atomic.LoadUint64(&ag.abandoned)
+		log.Printf("Abandoned events: %d accepted or received events without sent event expired after %v", abandoned, ag.unmatchedTTL)
+		aggregates.abandoned = &abandoned
+	}
+	aggregates.missingRecords = ag.reportedMissingRecords()
+	aggregates.duplicates = ag.collectDuplicates()
+	if ag.eventDeadline > 0 {
+		o := classifyDeadline(events, ag.eventDeadline)
+		reportDeadline(o, len(events), ag.eventDeadline)
+		aggregates.deadline = &o
+	}
+	if aggregates.lowSample() {
+		log.Printf("!! Only %d events were delivered, less than the %d required to publish percentiles",
+			len(aggregates.e2eLatencies), ag.minSamplesForPercentiles)
+	}
+
+	if !aggregates.lowSample() {
+		aggregates.latencyPercentiles = reportLatencyPercentiles(aggregates.publishLatencies, aggregates.e2eLatencies)
+	}
+
+	if len(ag.highPercentiles) > 0 && !aggregates.lowSample() {
+		aggregates.highPercentiles = reportHighPercentiles(aggregates.e2eLatencies, ag.highPercentiles)
+	}
+
+	if ag.timeWeightedPercentiles && !aggregates.lowSample() {
+		aggregates.timeWeighted = reportTimeWeightedPercentiles(events, ag.throughputSmoothing)
+	}
+
+	if ag.bootstrapResamples > 0 && !aggregates.lowSample() {
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		aggregates.confidenceIntervals = reportConfidenceIntervals(aggregates.e2eLatencies, ag.bootstrapResamples, rnd)
+	}
+
+	if ag.referenceCDF != nil {
+		reportCDFDeltas(compareCDF(ag.referenceCDF, aggregates.e2eLatencies), ag.referenceCDFTolerance)
+	}
+
+	acceptedSkews, receivedSkews := findClockSkews(events, ag.clockSkewCases)
+	acceptedSkews.report()
+	receivedSkews.report()
+
+	if ag.sqlitePath != "" {
+		log.Printf("Writing the SQLite database of the events to %s", ag.sqlitePath)
+		if err := exportSQLite(ag.sqlitePath, ag.scenario, events); err != nil {
+			log.Printf("ERROR writing the SQLite database: %v", err)
+		}
+	}
+
+	if ag.timelinePath != "" {
+		log.Printf("Writing the timeline to %s", ag.timelinePath)
+		if err := exportTimeline(ag.timelinePath, ag.scenario, events); err != nil {
+			log.Printf("ERROR writing the timeline: %v", err)
+		}
+	}
+
+	if ag.latencySamplesPath != "" {
+		log.Printf("Writing the latency samples to %s", ag.latencySamplesPath)
+		if err := exportLatencySamples(ag.latencySamplesPath, aggregates.publishLatencies, aggregates.e2eLatencies); err != nil {
+			log.Printf("ERROR writing the latency samples: %v", err)
+		}
+	}
+
+	if ag.cdfPath != "" {
+		log.Printf("Writing the latency CDF to %s", ag.cdfPath)
+		if err := exportCDF(ag.cdfPath, ag.scenario, aggregates.e2eLatencies); err != nil {
+			log.Printf("ERROR writing the latency CDF: %v", err)
+		}
+	}
+
+	if ag.failedEventsPath != "" {
+		if err := exportFailedEvents(ag.failedEventsPath, events); err != nil {
+			log.Printf("ERROR writing the failed events: %v", err)
+		}
+	}
+
+	if ag.hdrHistogramPath != "" {
+		log.Printf("Writing the end to end latency histogram to %s", ag.hdrHistogramPath)
+		if err := exportHDRHistogram(ag.hdrHistogramPath, latencyHistogramOf(aggregates.e2eLatencies)); err != nil {
+			log.Printf("ERROR writing the end to end latency histogram: %v", err)
+		}
+	}
+
+	if ag.plotDataPath != "" {
+		log.Printf("Writing the plot data to %s", ag.plotDataPath)
+		if err := exportPlotData(ag.plotDataPath, ag.scenario, events, ag.plotDataWindow); err != nil {
+			log.Printf("ERROR writing the plot data: %v", err)
+		}
+	}
+
+	if ag.throughputSeriesPath != "" {
+		log.Printf("Writing the throughput series to %s", ag.throughputSeriesPath)
+		series := []throughputTimestamps{
+			{"send", eventsToTimestampsArray(&ag.sentEvents.Events, excluded)},
+			{"accept", eventsToTimestampsArray(&ag.acceptedEvents.Events, excluded)},
+			{"receive", eventsToTimestampsArray(&ag.receivedEvents.Events, excluded)},
+			{"publish-error", append([]time.Time(nil), publishErrorTimestamps...)},
+			{"deliver-error", append([]time.Time(nil), deliverErrorTimestamps...)},
+		}
+		if err := exportThroughputSeries(ag.throughputSeriesPath, series, ag.throughputSmoothing); err != nil {
+			log.Printf("ERROR writing the throughput series: %v", err)
+		}
+	}
+
+	if ag.heatmapPath != "" {
+		log.Printf("Writing the latency heatmap to %s", ag.heatmapPath)
+		if err := exportHeatmap(ag.heatmapPath, ag.scenario, events, ag.heatmapWindow); err != nil {
+			log.Printf("ERROR writing the latency heatmap: %v", err)
+		}
+	}
+
+	if ag.anomalyWindow > 0 {
+		windows := windowedP99(events, ag.anomalyWindow)
+		reportLatencyAnomalies(findLatencyAnomalies(windows, ag.anomalyBaselineWindows, ag.anomalyFactor), ag.anomalyFactor)
+	}
+
+	if ag.periodicityWindow > 0 {
+		aggregates.periodicity = reportLatencyPeriodicity(events, ag.periodicityWindow)
+	}
+
+	var acceptance []acceptanceWindow
+	if ag.acceptanceWindow > 0 {
+		acceptance = acceptanceRates(events, ag.acceptanceWindow)
+		reportAcceptanceRates(acceptance)
+	}
+
+	var worstSenderTags []string
+	if ag.reportWorstSender || ag.reportSenderTable || ag.reportSenderGini || ag.shardBreakdown {
+		stats := computeSenderStats(events)
+		if ag.reportSenderTable {
+			reportSenderTable(stats)
+		}
+		if ag.reportWorstSender {
+			worstSenderTags = reportWorstSenders(stats)
+		}
+		if ag.reportSenderGini {
+			aggregates.senderGini = reportSenderGini(stats)
+		}
+		if ag.shardBreakdown {
+			aggregates.shards, aggregates.shardImbalance = reportShards(stats)
+		}
+	}
+
+	if compared, corrupted := findCorruptions(events); compared > 0 {
+		reportCorruptions(compared, corrupted)
+		n := len(corrupted)
+		aggregates.corruptions = &n
+	}
+
+	mismatches := findChecksumMismatches(events)
+	reportChecksumMismatches(mismatches)
+	aggregates.checksumMismatches = len(mismatches)
+
+	if ag.verifyOrdering {
+		aggregates.outOfOrder = reportOrdering(verifyOrdering(events))
+	}
+
+	aggregates.payloadSizes, aggregates.latencySizeSlope = reportPayloadSizes(events)
+
+	if monotonic, discrepancies := monotonicLatencies(events); len(monotonic) > 0 {
+		aggregates.skewEstimate = reportMonotonicLatencies(monotonic, discrepancies, len(aggregates.e2eLatencies))
+	}
+
+	if ag.clockOffsets != nil {
+		reportAnchoredLatencies(events, ag.clockOffsets)
+	}
+
+	aggregates.redeliverySpread = reportRedeliverySpreads(redeliverySpreads(events))
+	aggregates.stageCorrelation = reportStageCorrelation(events)
+	aggregates.retryRate = reportRetryRate(events)
+	aggregates.publishCohorts = reportPublishLatencyCohorts(events)
+	aggregates.stability = reportStabilityScore(events, aggregates.e2eLatencies, ag.stabilityWeights)
+
+	if ag.recorderTimes != nil {
+		aggregates.recorderTimes = reportDistribution("Recorder processing time", "rp", ag.recorderTimes.sorted())
+	}
+
+	if ag.reportQueueingDelay {
+		aggregates.queueingDelays = reportDistribution("Queueing delay", "q", queueingDelays(events))
+	}
+
+	if ag.reportSenderQueueing {
+		aggregates.senderQueueing = reportSenderQueueing(events)
+	}
+
+	if ag.reportPhaseTable {
+		reportPhaseTable(events)
+	}
+
+	if ag.timeOfDayBucket > 0 {
+		reportTimeOfDayTable(events, ag.timeOfDayBucket, ag.timeOfDayPeriod)
+	}
+
+	if ag.detectBimodality {
+		if b, ok := splitInTwoClusters(aggregates.e2eLatencies); ok && b.isBimodal() {
+			log.Printf("End to end latency is bimodal: %v (%.1f%%) and %v (%.1f%%), separation %.2f",
+				b.lowCenter, b.lowFraction*100, b.highCenter, b.highFraction*100, b.separation)
+			aggregates.modes = &b
+		} else {
+			log.Printf("End to end latency is not bimodal")
+		}
+	}
+
+	if memStats != nil {
+		m := memStats.finish()
+		log.Printf("Aggregator memory: peak heap %.1fMiB, %d GCs paused %v",
+			float64(m.peakHeapBytes)/(1024*1024), m.gcCount, m.gcPause)
+		aggregates.memory = &m
+	}
+
+	summary := newRunSummary(ag.runID, ag.scenario, events, &aggregates)
+	failures := ag.summarize(ctx, &aggregates, summary)
+
 	if ag.publishResults {
 		log.Printf("Publishing errors")
 
 		for _, t := range publishErrorTimestamps {
-			if qerr := client.Quickstore.AddError(mako.XTime(t), publishFailureMessage); qerr != nil {
+			if qerr := exporter.AddError(mako.XTime(t), publishFailureMessage); qerr != nil {
 				log.Printf("ERROR AddError for publish-failure: %v", qerr)
 			}
 		}
 
 		for _, t := range deliverErrorTimestamps {
-			if qerr := client.Quickstore.AddError(mako.XTime(t), deliverFailureMessage); qerr != nil {
-				log.Printf("ERROR AddSamplePoint for deliver-failure: %v", qerr)
+			if qerr := exporter.AddError(mako.XTime(t), deliverFailureMessage); qerr != nil {
+				log.Printf("ERROR AddError for deliver-failure: %v", qerr)
 			}
 		}
 
 		log.Printf("Publishing throughputs")
 
-		sentTimestamps := eventsToTimestampsArray(&ag.sentEvents.Events)
-		err = publishThpt(sentTimestamps, client.Quickstore, "st")
+		sentTimestamps := eventsToTimestampsArray(&ag.sentEvents.Events, excluded)
+		err = publishThpt(sentTimestamps, store, "st", ag.throughputSmoothing)
 		if err != nil {
 			log.Printf("ERROR AddSamplePoint for send-throughput: %v", err)
 		}
 
-		receivedTimestamps := eventsToTimestampsArray(&ag.receivedEvents.Events)
-		err = publishThpt(receivedTimestamps, client.Quickstore, "dt")
+		receivedTimestamps := eventsToTimestampsArray(&ag.receivedEvents.Events, excluded)
+		err = publishThpt(receivedTimestamps, store, "dt", ag.throughputSmoothing)
 		if err != nil {
 			log.Printf("ERROR AddSamplePoint for deliver-throughput: %v", err)
 		}
 
-		err = publishThpt(publishErrorTimestamps, client.Quickstore, "pet")
+		if ag.ewmaDecay > 0 {
+			ewma := ewmaSmoothing(ag.ewmaDecay)
+			if err := publishThpt(sentTimestamps, store, "ste", ewma); err != nil {
+				log.Printf("ERROR AddSamplePoint for send-throughput-ewma: %v", err)
+			}
+			if err := publishThpt(receivedTimestamps, store, "dte", ewma); err != nil {
+				log.Printf("ERROR AddSamplePoint for deliver-throughput-ewma: %v", err)
+			}
+		}
+
+		if ag.reportGoodput {
+			err = publishThpt(deliveredSentTimestamps, store, "gt", ag.throughputSmoothing)
+			if err != nil {
+				log.Printf("ERROR AddSamplePoint for goodput-throughput: %v", err)
+			}
+		}
+
+		err = publishThpt(publishErrorTimestamps, store, "pet", ag.throughputSmoothing)
 		if err != nil {
 			log.Printf("ERROR AddSamplePoint for publish-failure-throughput: %v", err)
 		}
 
-		err = publishThpt(deliverErrorTimestamps, client.Quickstore, "det")
+		err = publishThpt(deliverErrorTimestamps, store, "det", ag.throughputSmoothing)
 		if err != nil {
 			log.Printf("ERROR AddSamplePoint for deliver-failure-throughput: %v", err)
 		}
 
+		for i := range acceptance {
+			w := &acceptance[i]
+			if w.sent == 0 {
+				continue
+			}
+			if qerr := store.AddSamplePoint(mako.XTime(w.start), map[string]float64{"ar": w.rate()}); qerr != nil {
+				log.Printf("ERROR AddSamplePoint for acceptance-rate: %v", qerr)
+			}
+		}
+
 		log.Printf("Publishing aggregates")
 
-		client.Quickstore.AddRunAggregate("pe", float64(len(publishErrorTimestamps)))
-		client.Quickstore.AddRunAggregate("de", float64(len(deliverErrorTimestamps)))
+		aggregates.publish(store)
+
+		if len(worstSenderTags) > 0 {
+			log.Printf("Publishing worst sender tags: %v", worstSenderTags)
+			exporter.AddTags(worstSenderTags...)
+		}
+		ag.producerTags.publishTo(exporter)
 
-		log.Printf("Store to mako")
+		log.Printf("Flushing the results to %s", exporter.Name())
 
-		if err := client.StoreAndHandleResult(); err != nil {
+		if err := exporter.Flush(ctx); err != nil {
 			fatalf("Failed to store data and handle the result: %v\n", err)
 		}
 	}
 
-	log.Printf("Aggregation completed")
+	finish(failures)
 }
 
-func eventsToTimestampsArray(events *map[string]*timestamp.Timestamp) []time.Time {
+// summarize checks the SLO thresholds and the assertions against the results
+// of the run, failing the summary if any doesn't hold, and writes and publishes
+// the summary to the result sinks. It returns the reasons the run failed.
+func (ag *Aggregator) summarize(ctx context.Context, aggregates *runAggregates, summary *RunSummary) runFailures {
+	var failures runFailures
+	if ag.sloThresholds.isSet() {
+		failures.sloViolations = ag.sloThresholds.violations(summary)
+		reportSLOViolations(failures.sloViolations)
+		failed := len(failures.sloViolations) > 0
+		aggregates.failedRun = &failed
+		summary.Passed = summary.Passed && !failed
+	}
+	if len(ag.assertions) > 0 {
+		failures.assertionFailures = checkAssertions(ag.assertions, newAggregateValues(aggregates, summary))
+		summary.Passed = summary.Passed && len(failures.assertionFailures) == 0
+	}
+	ag.shortSummary = formatShortSummary(summary, ag.shortSummaryFields)
+	log.Printf("Summary: %s", ag.shortSummary)
+	if ag.shortSummaryPath != "" {
+		if err := writeShortSummary(ag.shortSummaryPath, ag.shortSummary); err != nil {
+			log.Printf("ERROR writing the short summary of the run: %v", err)
+		}
+	}
+	if ag.summaryPath != "" {
+		log.Printf("Writing the summary of the run to %s", ag.summaryPath)
+		if err := exportRunSummary(ag.summaryPath, summary); err != nil {
+			log.Printf("ERROR writing the summary of the run: %v", err)
+		}
+	}
+	publishToSinks(ctx, ag.resultSinks, summary)
+	return failures
+}
+
+// eventsToTimestampsArray returns the timestamps of the recorded events, except
+// the ones of the excluded events.
+func eventsToTimestampsArray(events *map[string]*timestamp.Timestamp, excluded map[string]struct{}) []time.Time {
 	values := make([]time.Time, 0, len(*events))
-	for _, v := range *events {
+	for id, v := range *events {
+		if _, ok := excluded[id]; ok {
+			continue
+		}
 		t, _ := ptypes.Timestamp(v)
 		values = append(values, t)
 	}
 	return values
 }
 
-func publishThpt(timestamps []time.Time, q *quickstore.Quickstore, metricName string) error {
+func publishThpt(timestamps []time.Time, q samplePointStore, metricName string, smoothing throughputSmoothing) error {
 	if len(timestamps) >= 2 {
 		sort.Slice(timestamps, func(x, y int) bool { return timestamps[x].Before(timestamps[y]) })
-		var i, thpt int
-		for j, t := range timestamps[1:] {
-			thpt++
-			for i < j && t.Sub(timestamps[i]) > time.Second {
-				i++
-				thpt--
-			}
-			if qerr := q.AddSamplePoint(mako.XTime(t), map[string]float64{metricName: float64(thpt)}); qerr != nil {
+		for j, thpt := range smoothing.series(timestamps) {
+			if qerr := q.AddSamplePoint(mako.XTime(timestamps[j+1]), map[string]float64{metricName: thpt}); qerr != nil {
 				return qerr
 			}
 		}
@@ -336,19 +1435,204 @@ func publishThpt(timestamps []time.Time, q *quickstore.Quickstore, metricName st
 	return nil
 }
 
-// waitForEvents blocks until the expected number of events records has been received.
-func (ag *Aggregator) waitForEvents() {
-	for receivedRecords := uint(0); receivedRecords < ag.expectRecords; receivedRecords++ {
-		<-ag.notifyEventsReceived
+// waitForEvents blocks until either the expected number of events records has
+// been received, or as many senders and receivers reported their final
+// records, or all the registered ones did, whichever comes first, so that
+// completion doesn't depend only on the exact count of the records. An
+// orchestrator requesting to finish, the records timeout, or the registered
+// senders and receivers being done while fewer than expected registered, also
+// unblocks it, in which case the missing records are counted. It returns
+// whether all the expected records were received.
+func (ag *Aggregator) waitForEvents() bool {
+	stop := make(chan struct{})
+	defer close(stop)
+	var timeout <-chan time.Time
+	if ag.recordsTimeout > 0 {
+		timer := time.NewTimer(ag.recordsTimeout)
+		defer timer.Stop()
+		timeout = timer.C
 	}
+	timedOut := make(chan struct{})
+	ag.spawn(func() {
+		select {
+		case <-ag.registered.allDone:
+		case <-ag.doneSources.allDone:
+		case <-ag.finishRequested:
+		case <-timeout:
+			close(timedOut)
+		case <-stop:
+			return
+		}
+		ag.records.interrupt()
+	})
+
+	receivedRecords := ag.records.waitFor(ag.expectRecords)
+	if receivedRecords >= ag.expectRecords {
+		return true
+	}
+	select {
+	case <-ag.finishRequested:
+		log.Printf("!! Finishing after %d of the %d expected events records, the results are partial",
+			receivedRecords, ag.expectRecords)
+	case <-timedOut:
+		log.Printf("!! Timed out after %v waiting for the events records, with %d of the %d expected ones, the results are partial",
+			ag.recordsTimeout, receivedRecords, ag.expectRecords)
+	case <-ag.registered.allDone:
+		if registered := ag.registered.count(); registered < ag.expectRecords {
+			log.Printf("!! All the %d registered senders and receivers are done, but %d were expected, with %d of the %d expected events records, the results are partial",
+				registered, ag.expectRecords, receivedRecords, ag.expectRecords)
+			break
+		}
+		log.Printf("!! All the registered senders and receivers are done after only %d of the %d expected events records were counted",
+			receivedRecords, ag.expectRecords)
+		return true
+	default:
+		log.Printf("!! All the %d expected senders and receivers are done after only %d events records were counted",
+			ag.expectRecords, receivedRecords)
+		return true
+	}
+	ag.missingRecords = ag.expectRecords - receivedRecords
+	return false
+}
+
+// reportedMissingRecords returns the number of the expected records missing
+// from the results to publish, nil if the run could neither time out nor be
+// finished early.
+func (ag *Aggregator) reportedMissingRecords() *int {
+	if ag.recordsTimeout == 0 && ag.missingRecords == 0 {
+		return nil
+	}
+	missing := int(ag.missingRecords)
+	return &missing
+}
+
+// complete rejects the records received from now on as late, once the ones
+// being recorded are.
+func (ag *Aggregator) complete() {
+	ag.completion.Lock()
+	defer ag.completion.Unlock()
+	ag.completed = true
+	close(ag.recordsComplete)
+}
+
+// Finish implements event_state.EventsRecorder
+func (ag *Aggregator) Finish(_ context.Context, in *pb.FinishRequest) (*pb.FinishReply, error) {
+	ag.completion.RLock()
+	completed := ag.completed
+	ag.completion.RUnlock()
+	if completed {
+		log.Printf("Ignoring the finish request from %q received after all the expected events records", in.Source)
+	} else {
+		log.Printf("Finish requested by %q", in.Source)
+	}
+	if err := ag.writeCheckpoint(ag.now(), in); err != nil {
+		return nil, err
+	}
+	ag.requestFinish()
+	return &pb.FinishReply{AlreadyComplete: completed}, nil
+}
+
+// requestFinish wakes up the main goroutine to finish the run early.
+func (ag *Aggregator) requestFinish() {
+	ag.finishOnce.Do(func() {
+		close(ag.finishRequested)
+	})
 }
 
 // RecordSentEvents implements event_state.EventsRecorder
-func (ag *Aggregator) RecordEvents(_ context.Context, in *pb.EventsRecordList) (*pb.RecordReply, error) {
+func (ag *Aggregator) RecordEvents(ctx context.Context, in *pb.EventsRecordList) (reply *pb.RecordReply, err error) {
+	if ag.barrier != nil && ag.barrier.enter() {
+		defer ag.barrier.leave()
+	}
+	// deferred first after the barrier, so that it runs once the records are counted and the
+	// locks released
+	defer func() {
+		if r := recover(); r != nil {
+			reply, err = nil, ag.recordPanicked(in, r)
+		}
+	}()
+	arrived := ag.now()
+
+	if ag.recordDelay > 0 {
+		select {
+		case <-time.After(ag.recordDelay):
+		case <-ctx.Done():
+			log.Printf("!! Events records from %q abandoned during the record delay: %v", in.Source, ctx.Err())
+			return nil, ctx.Err()
+		}
+	}
+
+	ag.completion.RLock()
+	if ag.completed {
+		ag.completion.RUnlock()
+		log.Printf("!! Ignoring %d events records from %q received after all the expected ones", len(in.Items), in.Source)
+		return &pb.RecordReply{AfterCompletion: true}, nil
+	}
+	if in.SubmissionId != "" && ag.submissions.seen(in.SubmissionId) {
+		ag.completion.RUnlock()
+		log.Printf("!! Ignoring %d events records from %q of the already recorded submission %s", len(in.Items), in.Source, in.SubmissionId)
+		return &pb.RecordReply{DuplicateSubmission: true}, nil
+	}
+	hash, hashed := "", false
+	if ag.batches != nil {
+		if hash, hashed = batchHash(in); hashed && ag.batches.seen(hash) {
+			ag.completion.RUnlock()
+			log.Printf("!! Ignoring %d events records from %q identical to records received within %v", len(in.Items), in.Source, ag.batchWindow)
+			return &pb.RecordReply{DuplicateSubmission: true}, nil
+		}
+	}
+	// durable before the upload is acknowledged, or failed so that it's retried
+	// rather than ignored as a duplicate
+	if err := ag.writeCheckpoint(arrived, in); err != nil {
+		if in.SubmissionId != "" {
+			ag.submissions.forget(in.SubmissionId)
+		}
+		if hashed {
+			ag.batches.forget(hash)
+		}
+		ag.completion.RUnlock()
+		return nil, err
+	}
 	defer func() {
-		ag.notifyEventsReceived <- struct{}{}
+		ag.countRecords(in)
+		ag.completion.RUnlock()
 	}()
 
+	ag.mergeEvents(in, arrived)
+	return &pb.RecordReply{Count: uint32(len(in.Items))}, nil
+}
+
+// countRecords counts the given recorded events record list, marking its
+// source done if final. It's counted before marking its source done, so that
+// the count includes the final records when the main goroutine is woken up.
+func (ag *Aggregator) countRecords(in *pb.EventsRecordList) {
+	ag.records.add()
+	if in.Final {
+		ag.doneSources.markDone(in)
+	}
+	ag.registered.record(in)
+}
+
+// mergeEvents merges the events of the given events record list, arrived at
+// the given time, into the recorded ones.
+func (ag *Aggregator) mergeEvents(in *pb.EventsRecordList, arrived time.Time) {
+	ag.producerTags.add(in.Tags)
+
+	if ag.clockOffsets != nil {
+		if published, err := ptypes.Timestamp(in.PublishedAt); err == nil {
+			ag.clockOffsets.observe(in.Source, published, arrived)
+		} else {
+			log.Printf("!! Can't estimate the clock offset of %q from records without valid publish time: %v", in.Source, err)
+		}
+	}
+
+	if ag.recorderTimes != nil {
+		merging := time.Now()
+		defer func() {
+			ag.recorderTimes.observe(time.Since(merging))
+		}()
+	}
+
 	for _, recIn := range in.Items {
 		recType := recIn.GetType()
 
@@ -366,20 +1650,92 @@ func (ag *Aggregator) RecordEvents(_ context.Context, in *pb.EventsRecordList) (
 			continue
 		}
 
-		log.Printf("-> Recording %d %s events", uint64(len(recIn.Events)), recType)
+		if ag.compositeKeys {
+			log.Printf("-> Recording %d %s keyed events", uint64(len(recIn.KeyedEvents)), recType)
+			if len(recIn.Events) > 0 {
+				log.Printf("!! Ignoring %d %s events identified by ID, as events are matched on their composite key", len(recIn.Events), recType)
+			}
+		} else {
+			log.Printf("-> Recording %d %s events", uint64(len(recIn.Events)), recType)
+			if len(recIn.KeyedEvents) > 0 {
+				log.Printf("!! Ignoring %d %s keyed events, as events are matched on their ID: use --match-composite-keys", len(recIn.KeyedEvents), recType)
+			}
+		}
 
+		// newly recorded events, counted by the progress, whose latencies are
+		// streamed and observed by the live metrics and the progress once
+		// matched, and which are folded once complete in streaming aggregation
+		var added []matchedEvent
+		streamed := ag.latencies != nil && recType != pb.EventsRecord_ACCEPTED
 		func() {
 			rec.Lock()
 			defer rec.Unlock()
-			for id, t := range recIn.Events {
-				if _, exists := rec.Events[id]; exists {
-					log.Printf("!! Found duplicate %s event ID %s", recType, id)
-					continue
+			if in.Source != "" {
+				rec.producers[in.Source] = struct{}{}
+			}
+			if ag.compositeKeys {
+				withoutKey := 0
+				for _, e := range recIn.KeyedEvents {
+					if e.GetKey() == nil {
+						// they would all collide on the same ID
+						withoutKey++
+						continue
+					}
+					// a replaced timestamp is neither counted nor matched again
+					if id, result := rec.addKeyed(e.GetKey(), e.GetAt(), in.Source); result == eventAdded {
+						rec.markInserted(id, arrived)
+						added = append(added, matchedEvent{id: id, t: e.GetAt()})
+					}
+				}
+				if withoutKey > 0 {
+					log.Printf("!! Ignoring %d %s keyed events without key", withoutKey, recType)
+				}
+			} else {
+				for id, t := range recIn.Events {
+					result := rec.add(id, t, in.Source)
+					if result == eventIgnored {
+						continue
+					}
+					// a replaced timestamp takes the attributes of its record,
+					// but is neither counted nor matched again
+					if result == eventAdded {
+						rec.markInserted(id, arrived)
+						added = append(added, matchedEvent{id: id, t: t})
+					}
+					if hash, ok := recIn.ContentHashes[id]; ok {
+						rec.ContentHashes[id] = hash
+					}
+					if recIn.ChecksumMismatches[id] {
+						rec.ChecksumMismatches[id] = true
+					}
+					if attempt, ok := recIn.Attempts[id]; ok {
+						rec.Attempts[id] = attempt
+					}
+					if t, ok := recIn.OnWire[id]; ok {
+						rec.OnWire[id] = t
+					}
+					if key, ok := recIn.Sequences[id]; ok && key != nil {
+						rec.keys[id] = key
+					}
+					if size, ok := recIn.PayloadSizes[id]; ok {
+						rec.PayloadSizes[id] = size
+					}
+					if reading, ok := recIn.Monotonic[id]; ok && in.MonotonicClock != "" {
+						rec.addMonotonic(id, reading, in.MonotonicClock)
+					}
 				}
-				rec.Events[id] = t
 			}
 		}()
+		if streamed {
+			ag.streamMatched(recType, added)
+		}
+		if ag.live != nil {
+			ag.live.observe(recType, added)
+		}
+		// before folding them, which forgets them
+		ag.progressTracker.observe(ag, recType, added)
+		if ag.streaming != nil {
+			ag.foldComplete(added)
+		}
 	}
-
-	return &pb.RecordReply{Count: uint32(len(in.Items))}, nil
 }
@@ -0,0 +1,194 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// newTestAggregator creates an Aggregator listening on a random local port,
+// which doesn't publish its results.
+func newTestAggregator(t *testing.T, expectRecords uint, opts ...AggregatorOption) *Aggregator {
+	t.Helper()
+	executor, err := NewAggregator("127.0.0.1:0", expectRecords, nil, false, opts...)
+	if err != nil {
+		t.Fatal("Failed to create the aggregator:", err)
+	}
+	return executor.(*Aggregator)
+}
+
+// runAggregator runs the aggregator in the background, returning a channel
+// closed when Run returns.
+func runAggregator(ctx context.Context, ag *Aggregator) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ag.Run(ctx)
+	}()
+	return done
+}
+
+// publishRecords sends the given records to the aggregator through a GRPC client.
+func publishRecords(t *testing.T, ag *Aggregator, rl *pb.EventsRecordList) {
+	t.Helper()
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	if err := client.Publish(rl); err != nil {
+		t.Fatal("Failed to publish the events records:", err)
+	}
+}
+
+// newRecord creates an events record of the given type, with all the events at the given time.
+func newRecord(recType pb.EventsRecord_Type, at time.Time, ids ...string) *pb.EventsRecord {
+	ts, _ := ptypes.TimestampProto(at)
+	events := make(map[string]*timestamp.Timestamp, len(ids))
+	for _, id := range ids {
+		events[id] = ts
+	}
+	return &pb.EventsRecord{Type: recType, Events: events}
+}
+
+// waitForRun waits for the aggregator Run to return.
+func waitForRun(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timeout waiting for the aggregator to complete")
+	}
+}
+
+func TestRunTeardownIsClean(t *testing.T) {
+	ag := newTestAggregator(t, 1, WithTeardownCheck(true))
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1"),
+	}})
+	waitForRun(t, done)
+
+	if err := ag.verifyTeardown(); err != nil {
+		t.Error("verifyTeardown() =", err)
+	}
+}
+
+func TestVerifyTeardownDetectsLeakedGoroutine(t *testing.T) {
+	ag := newTestAggregator(t, 1)
+	ag.teardownTimeout = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	ag.spawn(func() {
+		<-release
+	})
+
+	done := runAggregator(context.Background(), ag)
+	publishRecords(t, ag, &pb.EventsRecordList{})
+	waitForRun(t, done)
+
+	if err := ag.verifyTeardown(); err == nil {
+		t.Error("verifyTeardown() = nil, want an error reporting the leaked goroutine")
+	}
+}
+
+// fatalRecorder is a fatal handler recording the reported errors instead of exiting.
+type fatalRecorder struct {
+	mu     sync.Mutex
+	errors []string
+}
+
+func (r *fatalRecorder) fatalf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *fatalRecorder) reported() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.errors...)
+}
+
+func TestConcurrentAggregatorsFatalHandlers(t *testing.T) {
+	var cleanFatal, leakyFatal fatalRecorder
+	clean := newTestAggregator(t, 1, WithTeardownCheck(true), WithFatalHandler(cleanFatal.fatalf))
+	leaky := newTestAggregator(t, 1, WithTeardownCheck(true), WithFatalHandler(leakyFatal.fatalf))
+	leaky.teardownTimeout = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	defer close(release)
+	leaky.spawn(func() {
+		<-release
+	})
+
+	cleanDone := runAggregator(context.Background(), clean)
+	leakyDone := runAggregator(context.Background(), leaky)
+
+	publishRecords(t, leaky, &pb.EventsRecordList{})
+	publishRecords(t, clean, &pb.EventsRecordList{})
+	waitForRun(t, cleanDone)
+	waitForRun(t, leakyDone)
+
+	if errs := cleanFatal.reported(); len(errs) != 0 {
+		t.Errorf("clean aggregator reported fatal errors %v, want none", errs)
+	}
+	if errs := leakyFatal.reported(); len(errs) != 1 || !strings.Contains(errs[0], "teardown is not clean") {
+		t.Errorf("leaky aggregator reported fatal errors %v, want the unclean teardown", errs)
+	}
+}
+
+func TestWithShutdown(t *testing.T) {
+	var fatal fatalRecorder
+	var shutdowns int
+	fatalf := withShutdown(fatal.fatalf, func(context.Context) {
+		if len(fatal.reported()) != 0 {
+			t.Error("the sidecar was shut down after the fatal error was reported")
+		}
+		shutdowns++
+	})
+
+	fatalf("Failed to %s", "store")
+	if shutdowns != 1 {
+		t.Errorf("sidecar shut down %d times, want 1", shutdowns)
+	}
+	if errs := fatal.reported(); len(errs) != 1 || errs[0] != "Failed to store" {
+		t.Errorf("reported fatal errors %v, want [Failed to store]", errs)
+	}
+}
+
+// recordEvents calls RecordEvents directly.
+func recordEvents(t *testing.T, ag *Aggregator, rl *pb.EventsRecordList) {
+	t.Helper()
+	if _, err := ag.RecordEvents(context.Background(), rl); err != nil {
+		t.Fatal("RecordEvents() =", err)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// clockOffsets estimates the offset of the clock of each source of records
+// from the clock of the aggregator, as the difference between the arrival time
+// of its records and their publish time. The estimate includes the transit
+// time of the records, so the smallest one is kept.
+type clockOffsets struct {
+	sync.Mutex
+	bySource map[string]time.Duration
+}
+
+func newClockOffsets() *clockOffsets {
+	return &clockOffsets{bySource: make(map[string]time.Duration)}
+}
+
+// observe records the offset of the source given the publish and arrival times of one of its records.
+func (o *clockOffsets) observe(source string, published, arrived time.Time) {
+	offset := arrived.Sub(published)
+	o.Lock()
+	defer o.Unlock()
+	if prev, ok := o.bySource[source]; !ok || offset < prev {
+		o.bySource[source] = offset
+	}
+}
+
+// get returns the offset of the given source, if known.
+func (o *clockOffsets) get(source string) (time.Duration, bool) {
+	o.Lock()
+	defer o.Unlock()
+	offset, ok := o.bySource[source]
+	return offset, ok
+}
+
+// report logs the offset of each source.
+func (o *clockOffsets) report() {
+	o.Lock()
+	defer o.Unlock()
+	sources := make([]string, 0, len(o.bySource))
+	for s := range o.bySource {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	log.Printf("Clock offsets of %d sources from the aggregator:", len(sources))
+	for _, s := range sources {
+		log.Printf("  %s: %v", s, o.bySource[s])
+	}
+}
+
+// anchoredLatencies returns the sorted end to end latencies of the received
+// events corrected by the clock offsets of their sender and receiver, i.e. as
+// measured by the clock of the aggregator, and the sorted differences from the
+// latencies computed from the raw timestamps. Events whose sender or receiver
+// offset is unknown are ignored.
+func anchoredLatencies(events []event, offsets *clockOffsets) (anchored []time.Duration, diffs []time.Duration) {
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived {
+			continue
+		}
+		sentOffset, ok := offsets.get(e.sender)
+		if !ok {
+			continue
+		}
+		receivedOffset, ok := offsets.get(e.receiver)
+		if !ok {
+			continue
+		}
+		latency := e.received.Add(receivedOffset).Sub(e.sent.Add(sentOffset))
+		anchored = append(anchored, latency)
+		diffs = append(diffs, latency-e.e2eLatency())
+	}
+	sortDurations(anchored)
+	sortDurations(diffs)
+	return anchored, diffs
+}
+
+// reportAnchoredLatencies logs the distribution of the arrival-anchored end to
+// end latencies, and of their differences from the sender timestamps based ones.
+func reportAnchoredLatencies(events []event, offsets *clockOffsets) {
+	offsets.report()
+	anchored, diffs := anchoredLatencies(events, offsets)
+	if skipped := len(e2eLatencies(events)) - len(anchored); skipped > 0 {
+		log.Printf("!! Ignoring %d delivered events whose sender or receiver clock offset is unknown", skipped)
+	}
+	reportDistribution("Arrival-anchored end to end latency", "", anchored)
+	reportDistribution("Arrival-anchored minus sender-based end to end latency", "", diffs)
+}
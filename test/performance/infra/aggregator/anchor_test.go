@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestArrivalAnchoredLatencies(t *testing.T) {
+	const (
+		skew    = time.Second
+		latency = 10 * time.Millisecond
+		// bounds the transit time of the records, included in the offset estimates
+		tolerance = 100 * time.Millisecond
+	)
+
+	ag := newTestAggregator(t, 2, WithArrivalAnchoredLatency(true))
+
+	now := time.Now()
+	sent := make(map[string]*timestamp.Timestamp)
+	received := make(map[string]*timestamp.Timestamp)
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("event-%d", i)
+		at := now.Add(-time.Duration(i) * time.Second)
+		// the clock of the sender is ahead by skew
+		sent[id], _ = ptypes.TimestampProto(at.Add(skew))
+		received[id], _ = ptypes.TimestampProto(at.Add(latency))
+	}
+
+	senderPublished, _ := ptypes.TimestampProto(time.Now().Add(skew))
+	recordEvents(t, ag, &pb.EventsRecordList{
+		Source:      "sender",
+		PublishedAt: senderPublished,
+		Items:       []*pb.EventsRecord{{Type: pb.EventsRecord_SENT, Events: sent}},
+	})
+	receiverPublished, _ := ptypes.TimestampProto(time.Now())
+	recordEvents(t, ag, &pb.EventsRecordList{
+		Source:      "receiver",
+		PublishedAt: receiverPublished,
+		Items:       []*pb.EventsRecord{{Type: pb.EventsRecord_RECEIVED, Events: received}},
+	})
+
+	events := ag.joinEvents()
+	for _, l := range e2eLatencies(events) {
+		if l != latency-skew {
+			t.Fatalf("sender-based latency = %v, want %v", l, latency-skew)
+		}
+	}
+
+	anchored, diffs := anchoredLatencies(events, ag.clockOffsets)
+	if len(anchored) != len(events) || len(diffs) != len(events) {
+		t.Fatalf("got %d anchored latencies and %d differences, want %d", len(anchored), len(diffs), len(events))
+	}
+	for i := range anchored {
+		if d := anchored[i] - latency; d < -tolerance || d > tolerance {
+			t.Errorf("anchored latency = %v, want %v (±%v)", anchored[i], latency, tolerance)
+		}
+		if d := diffs[i] - skew; d < -tolerance || d > tolerance {
+			t.Errorf("anchored minus sender-based latency = %v, want %v (±%v)", diffs[i], skew, tolerance)
+		}
+	}
+}
+
+func TestArrivalAnchoredLatenciesIgnoreUnknownOffsets(t *testing.T) {
+	offsets := newClockOffsets()
+	now := time.Now()
+	offsets.observe("sender", now, now.Add(time.Second))
+	// the receiver offset is a lower estimate, which must be kept
+	offsets.observe("receiver", now, now.Add(2*time.Millisecond))
+	offsets.observe("receiver", now, now.Add(time.Millisecond))
+
+	events := []event{
+		{id: "known", sender: "sender", receiver: "receiver", sent: now, received: now.Add(time.Second), isAccepted: true, isReceived: true},
+		{id: "unknown-receiver", sender: "sender", receiver: "other", sent: now, received: now, isAccepted: true, isReceived: true},
+		{id: "lost", sender: "sender", sent: now, isAccepted: true},
+	}
+	anchored, diffs := anchoredLatencies(events, offsets)
+	if len(anchored) != 1 {
+		t.Fatalf("got %d anchored latencies, want 1", len(anchored))
+	}
+	if want := time.Millisecond; anchored[0] != want {
+		t.Errorf("anchored latency = %v, want %v", anchored[0], want)
+	}
+	if want := time.Millisecond - time.Second; diffs[0] != want {
+		t.Errorf("anchored minus sender-based latency = %v, want %v", diffs[0], want)
+	}
+}
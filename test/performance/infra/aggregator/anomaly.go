@@ -0,0 +1,104 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Defaults of the latency anomaly detection.
+const (
+	defaultAnomalyBaselineWindows = 10
+	defaultAnomalyFactor          = 2
+)
+
+// latencyWindow is the p99 end to end latency of the events sent during a time window.
+type latencyWindow struct {
+	start time.Time
+	p99   time.Duration
+	count int
+}
+
+// windowedP99 computes the p99 end to end latency of the received events by
+// the window of their sent time. The windows are sorted by start time, and
+// only windows with received events are returned.
+func windowedP99(events []event, window time.Duration) []latencyWindow {
+	byStart := make(map[time.Time][]time.Duration)
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived {
+			continue
+		}
+		start := e.sent.Truncate(window)
+		byStart[start] = append(byStart[start], e.e2eLatency())
+	}
+
+	windows := make([]latencyWindow, 0, len(byStart))
+	for start, latencies := range byStart {
+		sortDurations(latencies)
+		windows = append(windows, latencyWindow{start: start, p99: percentile(latencies, 99), count: len(latencies)})
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].start.Before(windows[j].start) })
+	return windows
+}
+
+// latencyAnomaly is a window whose p99 latency exceeds its baseline.
+type latencyAnomaly struct {
+	latencyWindow
+	baseline time.Duration
+}
+
+// exceedance is the ratio of the p99 latency of the window to its baseline.
+func (a *latencyAnomaly) exceedance() float64 {
+	return float64(a.p99) / float64(a.baseline)
+}
+
+// findLatencyAnomalies flags the windows whose p99 latency exceeds by more than
+// factor their baseline: the median p99 latency of the baselineWindows windows
+// preceding them. The first baselineWindows windows, without a full baseline,
+// are never flagged.
+func findLatencyAnomalies(windows []latencyWindow, baselineWindows int, factor float64) []latencyAnomaly {
+	var anomalies []latencyAnomaly
+	recent := make([]time.Duration, baselineWindows)
+	for i := baselineWindows; i < len(windows); i++ {
+		for j := range recent {
+			recent[j] = windows[i-baselineWindows+j].p99
+		}
+		sortDurations(recent)
+		baseline := percentile(recent, 50)
+		if baseline > 0 && float64(windows[i].p99) > factor*float64(baseline) {
+			anomalies = append(anomalies, latencyAnomaly{latencyWindow: windows[i], baseline: baseline})
+		}
+	}
+	return anomalies
+}
+
+// reportLatencyAnomalies logs a marker for each anomalous window.
+func reportLatencyAnomalies(anomalies []latencyAnomaly, factor float64) {
+	if len(anomalies) == 0 {
+		log.Printf("No window with a p99 end to end latency above %v times its baseline", factor)
+		return
+	}
+	log.Printf("!! %d windows with a p99 end to end latency above %v times its baseline:", len(anomalies), factor)
+	for i := range anomalies {
+		a := &anomalies[i]
+		log.Printf("  ANOMALY %s: p99 %v is %.1f times the baseline %v (%d events)",
+			a.start.Format(time.RFC3339Nano), a.p99, a.exceedance(), a.baseline, a.count)
+	}
+}
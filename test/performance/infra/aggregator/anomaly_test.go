@@ -0,0 +1,83 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLatencyAnomalies(t *testing.T) {
+	start := time.Unix(1000, 0)
+	spike := start.Add(12 * time.Second)
+
+	var events []event
+	for s := 0; s < 20; s++ {
+		windowStart := start.Add(time.Duration(s) * time.Second)
+		latency := 10 * time.Millisecond
+		if windowStart.Equal(spike) {
+			latency = 50 * time.Millisecond
+		}
+		for i := 0; i < 10; i++ {
+			sent := windowStart.Add(time.Duration(i) * 50 * time.Millisecond)
+			events = append(events, event{
+				id:         fmt.Sprintf("%d-%d", s, i),
+				sent:       sent,
+				received:   sent.Add(latency),
+				isAccepted: true,
+				isReceived: true,
+			})
+		}
+	}
+	// lost events don't count
+	events = append(events, event{id: "lost", sent: start, isAccepted: true})
+
+	windows := windowedP99(events, time.Second)
+	if len(windows) != 20 {
+		t.Fatalf("got %d windows, want 20", len(windows))
+	}
+
+	anomalies := findLatencyAnomalies(windows, 5, 2)
+	if len(anomalies) != 1 {
+		t.Fatalf("got %d anomalies, want 1: %+v", len(anomalies), anomalies)
+	}
+	a := anomalies[0]
+	if !a.start.Equal(spike) || a.p99 != 50*time.Millisecond || a.baseline != 10*time.Millisecond {
+		t.Errorf("anomaly = %+v, want the window at %v with p99 50ms over a 10ms baseline", a, spike)
+	}
+	if a.exceedance() != 5 {
+		t.Errorf("exceedance = %v, want 5", a.exceedance())
+	}
+
+	// too few windows before the spike for a baseline
+	if anomalies := findLatencyAnomalies(windows, 15, 2); len(anomalies) != 0 {
+		t.Errorf("got %d anomalies without a full baseline, want none", len(anomalies))
+	}
+}
+
+func TestInvalidLatencyAnomalyDetectionIsRejected(t *testing.T) {
+	for _, opt := range []AggregatorOption{
+		WithLatencyAnomalyDetection(-time.Second, 10, 2),
+		WithLatencyAnomalyDetection(time.Second, 0, 2),
+		WithLatencyAnomalyDetection(time.Second, 10, 1),
+	} {
+		if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, opt); err == nil {
+			t.Error("NewAggregator() with an invalid latency anomaly detection succeeded")
+		}
+	}
+}
@@ -0,0 +1,382 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// aggregateValues collects the aggregates of the run the assertions are
+// evaluated against: the run aggregates published to Mako keyed by their value
+// key, e.g. "de", the metric aggregates keyed by value key and type, e.g.
+// "dl-count", the latency percentiles, e.g. "dl-p99", in seconds, and the
+// counts and rates of the summary of the run.
+type aggregateValues map[string]float64
+
+func (v aggregateValues) AddRunAggregate(valueKey string, value float64) error {
+	v[valueKey] = value
+	return nil
+}
+
+func (v aggregateValues) AddMetricAggregate(valueKey string, aggregateType string, value float64) error {
+	v[valueKey+"-"+aggregateType] = value
+	return nil
+}
+
+// newAggregateValues returns the values of the aggregates and of the summary
+// of the run.
+func newAggregateValues(aggregates *runAggregates, summary *RunSummary) aggregateValues {
+	v := make(aggregateValues)
+	aggregates.publish(v)
+	for p, d := range summary.PublishLatencies {
+		v[fmt.Sprintf("pl-p%v", p)] = d.Seconds()
+	}
+	for p, d := range summary.E2ELatencies {
+		v[fmt.Sprintf("dl-p%v", p)] = d.Seconds()
+	}
+	v["sent"] = float64(summary.Sent)
+	v["accepted"] = float64(summary.Accepted)
+	v["received"] = float64(summary.Received)
+	v["plr"] = summary.PublishFailureRate
+	v["dlr"] = summary.DeliveryFailureRate
+	v["thrpt"] = summary.Throughput
+	return v
+}
+
+// expr is an arithmetic expression over the aggregates.
+type expr interface {
+	eval(values aggregateValues) (float64, error)
+}
+
+type constant float64
+
+func (c constant) eval(aggregateValues) (float64, error) {
+	return float64(c), nil
+}
+
+// aggregateName refers to an aggregate.
+type aggregateName string
+
+func (n aggregateName) eval(values aggregateValues) (float64, error) {
+	v, ok := values[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("undefined aggregate %q", string(n))
+	}
+	return v, nil
+}
+
+type negation struct {
+	operand expr
+}
+
+func (n negation) eval(values aggregateValues) (float64, error) {
+	v, err := n.operand.eval(values)
+	return -v, err
+}
+
+type operation struct {
+	op          string
+	left, right expr
+}
+
+func (o operation) eval(values aggregateValues) (float64, error) {
+	l, err := o.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	r, err := o.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch o.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	default:
+		if r == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return l / r, nil
+	}
+}
+
+// comparisons are the operators of the assertions.
+var comparisons = map[string]func(l, r float64) bool{
+	"<":  func(l, r float64) bool { return l < r },
+	"<=": func(l, r float64) bool { return l <= r },
+	">":  func(l, r float64) bool { return l > r },
+	">=": func(l, r float64) bool { return l >= r },
+	"==": func(l, r float64) bool { return l == r },
+	"!=": func(l, r float64) bool { return l != r },
+}
+
+// assertion is a comparison between two expressions over the aggregates of
+// the run, which must hold for the run to pass, e.g. "dl-p99 < 0.05".
+type assertion struct {
+	source      string
+	op          string
+	left, right expr
+}
+
+// check evaluates the assertion, returning an error describing why it doesn't
+// hold, if it doesn't.
+func (a *assertion) check(values aggregateValues) error {
+	l, err := a.left.eval(values)
+	if err != nil {
+		return err
+	}
+	r, err := a.right.eval(values)
+	if err != nil {
+		return err
+	}
+	if !comparisons[a.op](l, r) {
+		return fmt.Errorf("%v %s %v is false", l, a.op, r)
+	}
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+)
+
+type assertionToken struct {
+	kind tokenKind
+	text string
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tokenize splits the assertion in numbers, names and operators. Names may
+// contain digits, dots, and dashes followed by a letter, e.g. "dl-p99.9", so
+// that subtracting a name needs spaces around the minus sign.
+func tokenize(s string) ([]assertionToken, error) {
+	var tokens []assertionToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isLetter(c):
+			j := i + 1
+			for j < len(s) && (isLetter(s[j]) || isDigit(s[j]) || s[j] == '.' ||
+				s[j] == '-' && j+1 < len(s) && isLetter(s[j+1])) {
+				j++
+			}
+			tokens = append(tokens, assertionToken{kind: tokenName, text: s[i:j]})
+			i = j
+		case isDigit(c) || c == '.':
+			j := i
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				j++
+				if j < len(s) && (s[j] == '+' || s[j] == '-') {
+					j++
+				}
+				for j < len(s) && isDigit(s[j]) {
+					j++
+				}
+			}
+			if _, err := strconv.ParseFloat(s[i:j], 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q", s[i:j])
+			}
+			tokens = append(tokens, assertionToken{kind: tokenNumber, text: s[i:j]})
+			i = j
+		case strings.IndexByte("+-*/()", c) >= 0:
+			tokens = append(tokens, assertionToken{kind: tokenOperator, text: s[i : i+1]})
+			i++
+		case strings.IndexByte("<>=!", c) >= 0:
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			if _, ok := comparisons[s[i:j]]; !ok {
+				return nil, fmt.Errorf("invalid operator %q", s[i:j])
+			}
+			tokens = append(tokens, assertionToken{kind: tokenOperator, text: s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return append(tokens, assertionToken{kind: tokenEnd}), nil
+}
+
+// assertionParser is a recursive descent parser of the assertions.
+type assertionParser struct {
+	tokens []assertionToken
+	pos    int
+}
+
+func (p *assertionParser) peek() assertionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *assertionParser) next() assertionToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *assertionParser) unexpected(t assertionToken) error {
+	if t.kind == tokenEnd {
+		return errors.New("unexpected end")
+	}
+	return fmt.Errorf("unexpected %q", t.text)
+}
+
+// expr parses a sum of terms.
+func (p *assertionParser) expr() (expr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokenOperator && (t.text == "+" || t.text == "-"); t = p.peek() {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = operation{op: t.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+// term parses a product of factors.
+func (p *assertionParser) term() (expr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokenOperator && (t.text == "*" || t.text == "/"); t = p.peek() {
+		p.next()
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = operation{op: t.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+// factor parses a number, a name, a negation or a parenthesized expression.
+func (p *assertionParser) factor() (expr, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenNumber:
+		v, _ := strconv.ParseFloat(t.text, 64)
+		return constant(v), nil
+	case t.kind == tokenName:
+		return aggregateName(t.text), nil
+	case t.kind == tokenOperator && t.text == "-":
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand: operand}, nil
+	case t.kind == tokenOperator && t.text == "(":
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.text != ")" {
+			return nil, p.unexpected(t)
+		}
+		return e, nil
+	default:
+		return nil, p.unexpected(t)
+	}
+}
+
+// parseAssertion parses an assertion made of two arithmetic expressions over
+// numbers and the names of the aggregates, with the +, -, * and / operators
+// and parentheses, compared with <, <=, >, >=, == or !=.
+func parseAssertion(s string) (*assertion, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion %q: %v", s, err)
+	}
+	p := &assertionParser{tokens: tokens}
+	a := &assertion{source: s}
+	if a.left, err = p.expr(); err != nil {
+		return nil, fmt.Errorf("invalid assertion %q: %v", s, err)
+	}
+	t := p.next()
+	if _, ok := comparisons[t.text]; !ok || t.kind != tokenOperator {
+		return nil, fmt.Errorf("invalid assertion %q: %v, want a comparison", s, p.unexpected(t))
+	}
+	a.op = t.text
+	if a.right, err = p.expr(); err != nil {
+		return nil, fmt.Errorf("invalid assertion %q: %v", s, err)
+	}
+	if t := p.next(); t.kind != tokenEnd {
+		return nil, fmt.Errorf("invalid assertion %q: %v", s, p.unexpected(t))
+	}
+	return a, nil
+}
+
+// parseAssertions parses the assertions, ignoring the blank ones.
+func parseAssertions(sources []string) ([]*assertion, error) {
+	var assertions []*assertion
+	for _, s := range sources {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		a, err := parseAssertion(s)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+// checkAssertions evaluates the assertions against the aggregates of the run,
+// logging their outcomes, and returns the descriptions of the failed ones.
+func checkAssertions(assertions []*assertion, values aggregateValues) []string {
+	var failures []string
+	for _, a := range assertions {
+		if err := a.check(values); err != nil {
+			log.Printf("Assertion %q failed: %v", a.source, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", a.source, err))
+		} else {
+			log.Printf("Assertion %q holds", a.source)
+		}
+	}
+	return failures
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestAssertions(t *testing.T) {
+	values := aggregateValues{"dl-p99": 0.04, "dl-p99.9": 0.2, "pl-p99": 0.01, "dlr": 0.002, "sent": 1000, "de": 2, "zero": 0}
+	for _, c := range []struct {
+		assertion string
+		holds     bool
+		// part of the reason of the failure
+		reason string
+	}{
+		{assertion: "dl-p99 < 0.05", holds: true},
+		{assertion: "dl-p99<=0.04", holds: true},
+		{assertion: "dlr <= 0.001", reason: "0.002 <= 0.001 is false"},
+		{assertion: "dl-p99.9 > 5 * dl-p99", reason: "0.2 > 0.2 is false"},
+		{assertion: "dl-p99 - pl-p99 < 0.05", holds: true},
+		{assertion: "de / sent == 2e-3", holds: true},
+		{assertion: "-(de - 3) * 2 >= 2", holds: true},
+		{assertion: "(dl-p99 + pl-p99) / 2 != 0.025", reason: "0.025 != 0.025 is false"},
+		{assertion: "de - 1 > 0", holds: true},
+		{assertion: "q99 < 1", reason: `undefined aggregate "q99"`},
+		{assertion: "de / zero < 1", reason: "division by zero"},
+	} {
+		a, err := parseAssertion(c.assertion)
+		if err != nil {
+			t.Errorf("parseAssertion(%q) = %v", c.assertion, err)
+			continue
+		}
+		err = a.check(values)
+		if c.holds && err != nil {
+			t.Errorf("%q failed: %v", c.assertion, err)
+		} else if !c.holds && (err == nil || !strings.Contains(err.Error(), c.reason)) {
+			t.Errorf("%q failed with %v, want %s", c.assertion, err, c.reason)
+		}
+	}
+}
+
+func TestMalformedAssertionsAreRejected(t *testing.T) {
+	for _, s := range []string{
+		"dl-p99",
+		"dl-p99 < ",
+		"< 0.05",
+		"dl-p99 = 0.05",
+		"dl-p99 =< 0.05",
+		"dl-p99 ! 0.05",
+		"dl-p99 < 0.05 < 1",
+		"dl-p99 < (0.05",
+		"dl-p99 < 0.05)",
+		"dl-p99 < 0.0.5",
+		"dl-p99 < 5ms",
+		"dl-p99 < 1e",
+		"dl-p99 < 0.05 && dlr < 0.01",
+		"dl-p99 * < 0.05",
+		"dl-p99 <> 0.05",
+		"()",
+	} {
+		if _, err := parseAssertion(s); err == nil {
+			t.Errorf("parseAssertion(%q) succeeded", s)
+		}
+	}
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithAssertions("dl-p99 < 0.05", "dlr <")); err == nil {
+		t.Error("NewAggregator() with a malformed assertion succeeded")
+	}
+}
+
+func TestFailedAssertionsFailTheRun(t *testing.T) {
+	var fatal fatalRecorder
+	ag := newTestAggregator(t, 2, WithMinSamplesForPercentiles(1), WithFatalHandler(fatal.fatalf),
+		WithAssertions("dl-p99 < 0.05", " ", "dlr <= 0.001", "sent == 2", "de == 0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "delivered", "lost"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "delivered", "lost"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "delivered"),
+	}})
+	waitForRun(t, done)
+
+	errs := fatal.reported()
+	if len(errs) != 1 {
+		t.Fatalf("reported fatal errors %v, want one", errs)
+	}
+	if want := "2 of 4 assertions failed"; !strings.HasPrefix(errs[0], want) ||
+		!strings.Contains(errs[0], "dlr <= 0.001: 0.5 <= 0.001 is false") ||
+		!strings.Contains(errs[0], "de == 0: 1 == 0 is false") || strings.Contains(errs[0], "dl-p99") {
+		t.Errorf("reported fatal error %q, want %s listing the dlr and de assertions", errs[0], want)
+	}
+	if !strings.Contains(ag.shortSummary, "status=FAIL") {
+		t.Errorf("short summary = %q, want it failed", ag.shortSummary)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sync"
+)
+
+// recordBarrier tracks the RecordEvents handlers in flight, so that the
+// results are computed only once all the handlers which started before the
+// shutdown merged their records, e.g. those still waiting for the record delay.
+type recordBarrier struct {
+	mu       sync.Mutex
+	closed   bool
+	inFlight int
+	handlers sync.WaitGroup
+}
+
+// enter tracks a handler, returning false once the barrier is closed, in which
+// case the handler isn't waited for.
+func (b *recordBarrier) enter() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.inFlight++
+	b.handlers.Add(1)
+	return true
+}
+
+// leave marks a tracked handler as done.
+func (b *recordBarrier) leave() {
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+	b.handlers.Done()
+}
+
+// closeAndWait stops tracking the handlers, and blocks until the tracked ones are done.
+func (b *recordBarrier) closeAndWait() {
+	b.mu.Lock()
+	b.closed = true
+	inFlight := b.inFlight
+	b.mu.Unlock()
+	if inFlight > 0 {
+		log.Printf("Waiting for %d events records in flight", inFlight)
+	}
+	b.handlers.Wait()
+}
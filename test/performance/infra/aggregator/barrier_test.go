@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRecordBarrierWaitsForInFlightRecords(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 3, WithRecordDelay(300*time.Millisecond), WithRecordBarrier(true),
+		WithRunID("barrier"), WithNDJSONExport(filepath.Join(dir, "events.ndjson")))
+	done := runAggregator(context.Background(), ag)
+
+	// a slow handler still waiting for the record delay when finish is requested
+	replied := make(chan *pb.RecordReply, 1)
+	go func() {
+		reply, err := ag.RecordEvents(context.Background(), &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_SENT, time.Now(), "slow"),
+		}})
+		if err != nil {
+			t.Error("RecordEvents() =", err)
+		}
+		replied <- reply
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := ag.Finish(context.Background(), &pb.FinishRequest{Source: "orchestrator"}); err != nil {
+		t.Fatal("Finish() =", err)
+	}
+	waitForRun(t, done)
+
+	if reply := <-replied; reply.GetAfterCompletion() {
+		t.Error("the record in flight at the shutdown was replied to as late")
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "events-barrier.ndjson"))
+	if err != nil {
+		t.Fatal("Failed to read the exported events:", err)
+	}
+	if !strings.Contains(string(content), `"id":"slow"`) {
+		t.Errorf("exported events = %q, want the event recorded in flight", content)
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"crypto/sha256"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// batchHash returns the hash of the deterministic serialization of the items,
+// source and final flag of the events record list, so that the same records
+// uploaded twice, e.g. uploaded again with a new submission ID, have the same
+// hash. The publication time and the submission ID, set anew on each upload,
+// are left out. It returns false if the list can't be serialized.
+func batchHash(in *pb.EventsRecordList) (string, bool) {
+	var b proto.Buffer
+	b.SetDeterministic(true)
+	if err := b.Marshal(&pb.EventsRecordList{Items: in.Items, Source: in.Source, Final: in.Final}); err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b.Bytes())
+	return string(sum[:]), true
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestDuplicateBatchIsCountedOnce(t *testing.T) {
+	ag := newTestAggregator(t, 0, WithDuplicateBatchWindow(time.Minute))
+	now := time.Now()
+	rl := &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "1", "2", "3")}}
+	recordEvents(t, ag, rl)
+
+	// the same list uploaded again without submission ID, as a new message
+	again := &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "1", "2", "3")}}
+	reply, err := ag.RecordEvents(context.Background(), again)
+	if err != nil {
+		t.Fatal("RecordEvents() of the duplicate batch =", err)
+	}
+	if !reply.GetDuplicateSubmission() {
+		t.Error("RecordEvents() of the duplicate batch replied without duplicate_submission")
+	}
+
+	// a list differing by a single event is recorded
+	other := &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "1", "2", "4")}}
+	recordEvents(t, ag, other)
+	if got := ag.records.value(); got != 2 {
+		t.Errorf("counted %d records, want 2", got)
+	}
+}
+
+func TestDuplicateBatchUploadedAgainIsCountedOnce(t *testing.T) {
+	ag := newTestAggregator(t, 2, WithDuplicateBatchWindow(time.Minute))
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	rl := &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "1", "2")}}
+	publishRecords(t, ag, rl)
+	// uploaded again through the client with a new submission ID, and a new
+	// publication time
+	again := proto.Clone(rl).(*pb.EventsRecordList)
+	again.SubmissionId = ""
+	time.Sleep(time.Millisecond)
+	publishRecords(t, ag, again)
+	if again.SubmissionId == rl.SubmissionId || proto.Equal(again.PublishedAt, rl.PublishedAt) {
+		t.Fatal("The list uploaded again has the same submission ID or publication time")
+	}
+	if got := ag.records.value(); got != 1 {
+		t.Errorf("counted %d records, want the list uploaded again counted once", got)
+	}
+
+	// the second expected list completes the run
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "receiver", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_RECEIVED, now, "1", "2")}})
+	waitForRun(t, done)
+}
+
+func TestDuplicateBatchDetectionDisabledByDefault(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	rl := &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1")}}
+	recordEvents(t, ag, rl)
+	recordEvents(t, ag, rl)
+	if got := ag.records.value(); got != 2 {
+		t.Errorf("counted %d records without duplicate batch detection, want 2", got)
+	}
+}
+
+func TestNegativeDuplicateBatchWindowIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithDuplicateBatchWindow(-time.Second)); err == nil {
+		t.Error("NewAggregator() with a negative duplicate batch window succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// minBimodalSeparation is the minimum Ashman's D between the two clusters
+	// for the distribution to be considered bimodal. D > 2 is the usual
+	// threshold for a clean separation of two modes, but splitting a single
+	// normal mode in two already yields D ~= 2.65, hence the higher value.
+	minBimodalSeparation = 3.0
+	// minModeFraction is the minimum fraction of the samples each cluster must
+	// hold, so that a few outliers are not reported as a mode.
+	minModeFraction = 0.05
+)
+
+// bimodality is the result of splitting a latency distribution into two clusters.
+type bimodality struct {
+	lowCenter    time.Duration
+	highCenter   time.Duration
+	lowFraction  float64
+	highFraction float64
+	// separation is the Ashman's D of the two clusters
+	separation float64
+}
+
+// isBimodal tells whether the two clusters are distinct modes of the distribution.
+func (b bimodality) isBimodal() bool {
+	return b.separation > minBimodalSeparation &&
+		b.lowFraction >= minModeFraction &&
+		b.highFraction >= minModeFraction
+}
+
+// splitInTwoClusters runs a two-cluster k-means on the given sorted durations.
+// In one dimension the optimal clusters are contiguous in the sorted order, so
+// the split minimizing the within-cluster sum of squares is found exactly by
+// scanning all the split points.
+func splitInTwoClusters(sorted []time.Duration) (bimodality, bool) {
+	n := len(sorted)
+	if n < 2 {
+		return bimodality{}, false
+	}
+
+	// prefix sums of the values and of their squares, in seconds to avoid overflows
+	sums := make([]float64, n+1)
+	squares := make([]float64, n+1)
+	for i, d := range sorted {
+		v := d.Seconds()
+		sums[i+1] = sums[i] + v
+		squares[i+1] = squares[i] + v*v
+	}
+	sse := func(from, to int) float64 {
+		count := float64(to - from)
+		sum := sums[to] - sums[from]
+		return squares[to] - squares[from] - sum*sum/count
+	}
+
+	best, bestSSE := 0, math.Inf(1)
+	for split := 1; split < n; split++ {
+		if s := sse(0, split) + sse(split, n); s < bestSSE {
+			best, bestSSE = split, s
+		}
+	}
+
+	lowCount, highCount := float64(best), float64(n-best)
+	lowMean := (sums[best] - sums[0]) / lowCount
+	highMean := (sums[n] - sums[best]) / highCount
+	lowVariance := math.Max(sse(0, best)/lowCount, 0)
+	highVariance := math.Max(sse(best, n)/highCount, 0)
+
+	separation := math.Inf(1)
+	if spread := lowVariance + highVariance; spread > 0 {
+		separation = math.Sqrt2 * (highMean - lowMean) / math.Sqrt(spread)
+	} else if highMean == lowMean {
+		separation = 0
+	}
+
+	return bimodality{
+		lowCenter:    time.Duration(lowMean * float64(time.Second)),
+		highCenter:   time.Duration(highMean * float64(time.Second)),
+		lowFraction:  lowCount / float64(n),
+		highFraction: highCount / float64(n),
+		separation:   separation,
+	}, true
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSplitInTwoClusters(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	jitter := func(center time.Duration) time.Duration {
+		return center + time.Duration(r.NormFloat64()*float64(time.Millisecond))
+	}
+
+	t.Run("bimodal", func(t *testing.T) {
+		// 80% fast path around 10ms, 20% retry path around 200ms
+		var latencies []time.Duration
+		for i := 0; i < 800; i++ {
+			latencies = append(latencies, jitter(10*time.Millisecond))
+		}
+		for i := 0; i < 200; i++ {
+			latencies = append(latencies, jitter(200*time.Millisecond))
+		}
+		sortDurations(latencies)
+
+		b, ok := splitInTwoClusters(latencies)
+		if !ok || !b.isBimodal() {
+			t.Fatalf("splitInTwoClusters() = %+v, want bimodal", b)
+		}
+		if d := b.lowCenter - 10*time.Millisecond; d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("low center = %v, want ~10ms", b.lowCenter)
+		}
+		if d := b.highCenter - 200*time.Millisecond; d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("high center = %v, want ~200ms", b.highCenter)
+		}
+		if b.lowFraction != 0.8 || b.highFraction != 0.2 {
+			t.Errorf("fractions = (%v, %v), want (0.8, 0.2)", b.lowFraction, b.highFraction)
+		}
+	})
+
+	t.Run("unimodal", func(t *testing.T) {
+		var latencies []time.Duration
+		for i := 0; i < 1000; i++ {
+			latencies = append(latencies, jitter(50*time.Millisecond))
+		}
+		sortDurations(latencies)
+
+		if b, ok := splitInTwoClusters(latencies); !ok || b.isBimodal() {
+			t.Errorf("splitInTwoClusters() = %+v, want not bimodal", b)
+		}
+	})
+
+	t.Run("too few samples", func(t *testing.T) {
+		if _, ok := splitInTwoClusters([]time.Duration{time.Second}); ok {
+			t.Error("splitInTwoClusters() with one sample succeeded, want failure")
+		}
+	})
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// confidenceLevel is the level of the bootstrap confidence intervals.
+const confidenceLevel = 0.95
+
+// confidenceInterval bounds a percentile estimate.
+type confidenceInterval struct {
+	low, high time.Duration
+}
+
+// bootstrapPercentiles returns the confidence interval of each given percentile
+// of the sorted durations, estimated with the percentile bootstrap over the
+// given number of resamples.
+func bootstrapPercentiles(sorted []time.Duration, percentiles []float64, resamples int, rnd *rand.Rand) []confidenceInterval {
+	n := len(sorted)
+	if n == 0 || resamples <= 0 {
+		return nil
+	}
+
+	// resampling a sorted slice amounts to drawing how many times each of its
+	// elements is picked, which avoids sorting every resample
+	counts := make([]int, n)
+	estimates := make([][]time.Duration, len(percentiles))
+	for i := range estimates {
+		estimates[i] = make([]time.Duration, resamples)
+	}
+	for r := 0; r < resamples; r++ {
+		for i := range counts {
+			counts[i] = 0
+		}
+		for j := 0; j < n; j++ {
+			counts[rnd.Intn(n)]++
+		}
+		for i, p := range percentiles {
+			estimates[i][r] = sorted[nthPicked(counts, percentileRank(n, p))]
+		}
+	}
+
+	intervals := make([]confidenceInterval, len(percentiles))
+	tail := (1 - confidenceLevel) / 2 * 100
+	for i := range estimates {
+		sortDurations(estimates[i])
+		intervals[i] = confidenceInterval{
+			low:  percentile(estimates[i], tail),
+			high: percentile(estimates[i], 100-tail),
+		}
+	}
+	return intervals
+}
+
+// nthPicked returns the index of the element holding the given 1-based rank,
+// each element being picked counts[index] times.
+func nthPicked(counts []int, rank int) int {
+	for i, c := range counts {
+		if rank <= c {
+			return i
+		}
+		rank -= c
+	}
+	return len(counts) - 1
+}
+
+// reportConfidenceIntervals logs the bootstrap confidence intervals of the
+// reported percentiles of the end to end latencies, and returns their bounds
+// keyed by "c<percentile>l" and "c<percentile>h".
+func reportConfidenceIntervals(sorted []time.Duration, resamples int, rnd *rand.Rand) map[string]time.Duration {
+	intervals := bootstrapPercentiles(sorted, reportedPercentiles, resamples, rnd)
+	if intervals == nil {
+		return nil
+	}
+	log.Printf("End to end latency %.0f%% confidence intervals over %d resamples:", confidenceLevel*100, resamples)
+	aggregates := make(map[string]time.Duration, 2*len(intervals))
+	for i, p := range reportedPercentiles {
+		ci := intervals[i]
+		log.Printf("  p%v: %v [%v, %v]", p, percentile(sorted, p), ci.low, ci.high)
+		aggregates[fmt.Sprintf("c%vl", p)] = ci.low
+		aggregates[fmt.Sprintf("c%vh", p)] = ci.high
+	}
+	return aggregates
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// uniformLatencies returns n sorted latencies drawn uniformly between 0 and 100ms.
+func uniformLatencies(n int, rnd *rand.Rand) []time.Duration {
+	l := make([]time.Duration, n)
+	for i := range l {
+		l[i] = time.Duration(rnd.Int63n(int64(100 * time.Millisecond)))
+	}
+	sortDurations(l)
+	return l
+}
+
+func TestBootstrapPercentiles(t *testing.T) {
+	const resamples = 200
+	rnd := rand.New(rand.NewSource(1))
+	small := uniformLatencies(200, rnd)
+	large := uniformLatencies(20000, rnd)
+
+	smallCIs := bootstrapPercentiles(small, reportedPercentiles, resamples, rnd)
+	largeCIs := bootstrapPercentiles(large, reportedPercentiles, resamples, rnd)
+
+	for i, p := range reportedPercentiles {
+		for _, tc := range []struct {
+			sorted []time.Duration
+			ci     confidenceInterval
+		}{{small, smallCIs[i]}, {large, largeCIs[i]}} {
+			if estimate := percentile(tc.sorted, p); estimate < tc.ci.low || estimate > tc.ci.high {
+				t.Errorf("p%v of %d samples = %v, outside of its confidence interval [%v, %v]",
+					p, len(tc.sorted), estimate, tc.ci.low, tc.ci.high)
+			}
+		}
+		smallWidth := smallCIs[i].high - smallCIs[i].low
+		largeWidth := largeCIs[i].high - largeCIs[i].low
+		if largeWidth >= smallWidth {
+			t.Errorf("p%v confidence interval width = %v with %d samples, want narrower than %v with %d samples",
+				p, largeWidth, len(large), smallWidth, len(small))
+		}
+	}
+}
+
+func TestNthPicked(t *testing.T) {
+	counts := []int{0, 2, 0, 1, 3}
+	for rank, want := range map[int]int{1: 1, 2: 1, 3: 3, 4: 4, 6: 4} {
+		if got := nthPicked(counts, rank); got != want {
+			t.Errorf("nthPicked(%v, %d) = %d, want %d", counts, rank, got, want)
+		}
+	}
+}
+
+func TestNegativeBootstrapResamplesAreRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithPercentileConfidenceIntervals(-1)); err == nil {
+		t.Error("NewAggregator() with a negative number of bootstrap resamples succeeded")
+	}
+}
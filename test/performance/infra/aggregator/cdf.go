@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cdfHeader names the columns of the latency CDF file.
+const cdfHeader = "# percentile e2e_ms"
+
+// defaultReferenceCDFTolerance is the default relative delta against the
+// reference run above which a percentile is flagged as regressed or improved.
+const defaultReferenceCDFTolerance = 0.1
+
+// cdfPercentiles are the percentiles of the exported latency CDF.
+var cdfPercentiles = func() []float64 {
+	percentiles := make([]float64, 0, 101)
+	for p := 1; p <= 99; p++ {
+		percentiles = append(percentiles, float64(p))
+	}
+	return append(percentiles, 99.9, 99.99)
+}()
+
+// cdfPoint is the end to end latency at a percentile.
+type cdfPoint struct {
+	percentile float64
+	latency    time.Duration
+}
+
+// computeCDF returns the CDF of the given sorted end to end latencies at the
+// cdfPercentiles, nil without latencies.
+func computeCDF(sorted []time.Duration) []cdfPoint {
+	if len(sorted) == 0 {
+		return nil
+	}
+	points := make([]cdfPoint, len(cdfPercentiles))
+	for i, p := range cdfPercentiles {
+		points[i] = cdfPoint{percentile: p, latency: percentile(sorted, p)}
+	}
+	return points
+}
+
+// writeCDF writes the CDF as whitespace-delimited columns with a header row:
+// the percentile and its end to end latency in milliseconds. The scenario, if
+// any, is written as a comment before the header row. The file can be loaded
+// back as the reference of another run.
+func writeCDF(w io.Writer, scenario string, points []cdfPoint) error {
+	bw := bufio.NewWriter(w)
+	if scenario != "" {
+		fmt.Fprintln(bw, "# scenario:", scenario)
+	}
+	fmt.Fprintln(bw, cdfHeader)
+	for _, p := range points {
+		fmt.Fprintf(bw, "%g %g\n", p.percentile, millis(p.latency))
+	}
+	return bw.Flush()
+}
+
+// exportCDF writes the CDF of the given sorted end to end latencies to the file
+// at the given path.
+func exportCDF(path string, scenario string, sorted []time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := writeCDF(f, scenario, computeCDF(sorted)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// readCDF parses a CDF written by writeCDF, skipping the comments and the
+// blank lines. The percentiles must be increasing.
+func readCDF(r io.Reader) ([]cdfPoint, error) {
+	var points []cdfPoint
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want a percentile and a latency, got %q", line, text)
+		}
+		p, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("line %d: invalid percentile %q", line, fields[0])
+		}
+		ms, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("line %d: invalid latency %q", line, fields[1])
+		}
+		if len(points) > 0 && p <= points[len(points)-1].percentile {
+			return nil, fmt.Errorf("line %d: percentile %v not above the previous one", line, p)
+		}
+		points = append(points, cdfPoint{percentile: p, latency: time.Duration(ms * float64(time.Millisecond))})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no percentile")
+	}
+	return points, nil
+}
+
+// loadCDF reads the CDF in the file at the given path.
+func loadCDF(path string) ([]cdfPoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	points, err := readCDF(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return points, nil
+}
+
+// cdfDelta compares the end to end latency of the run at a percentile with the
+// one of the reference run.
+type cdfDelta struct {
+	percentile float64
+	reference  time.Duration
+	current    time.Duration
+}
+
+// delta is the latency of the run minus the one of the reference, positive
+// when the run is slower.
+func (d cdfDelta) delta() time.Duration {
+	return d.current - d.reference
+}
+
+// relative is the delta relative to the latency of the reference, 0 if the
+// latency of the reference is 0.
+func (d cdfDelta) relative() float64 {
+	if d.reference == 0 {
+		return 0
+	}
+	return float64(d.delta()) / float64(d.reference)
+}
+
+// compareCDF returns the deltas of the given sorted end to end latencies
+// against the reference CDF, at each of its percentiles.
+func compareCDF(reference []cdfPoint, sorted []time.Duration) []cdfDelta {
+	if len(sorted) == 0 {
+		return nil
+	}
+	deltas := make([]cdfDelta, len(reference))
+	for i, p := range reference {
+		deltas[i] = cdfDelta{percentile: p.percentile, reference: p.latency, current: percentile(sorted, p.percentile)}
+	}
+	return deltas
+}
+
+// reportCDFDeltas logs the deltas against the reference run, flagging the
+// percentiles slower or faster than the reference by more than the given
+// relative tolerance as regressions or improvements.
+func reportCDFDeltas(deltas []cdfDelta, tolerance float64) {
+	if len(deltas) == 0 {
+		log.Printf("No delivered event to compare with the reference run")
+		return
+	}
+	var regressions, improvements int
+	log.Printf("End to end latency against the reference run, tolerance %.0f%%:", tolerance*100)
+	for _, d := range deltas {
+		flag := ""
+		if r := d.relative(); r > tolerance {
+			flag = "  REGRESSION"
+			regressions++
+		} else if r < -tolerance {
+			flag = "  IMPROVEMENT"
+			improvements++
+		}
+		log.Printf("  p%v: %v vs %v, delta %v (%+.1f%%)%s",
+			d.percentile, d.current, d.reference, d.delta(), d.relative()*100, flag)
+	}
+	if regressions > 0 {
+		log.Printf("!! %d of the %d percentiles regressed against the reference run, %d improved",
+			regressions, len(deltas), improvements)
+	} else {
+		log.Printf("No percentile regressed against the reference run, %d of %d improved", improvements, len(deltas))
+	}
+}
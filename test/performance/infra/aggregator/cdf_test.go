@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareWithReferenceCDF(t *testing.T) {
+	reference, err := readCDF(strings.NewReader(`# scenario: 1kb
+# percentile e2e_ms
+50 10
+90 20
+
+99 40
+`))
+	if err != nil {
+		t.Fatal("Failed to read the reference CDF:", err)
+	}
+
+	// 1ms to 100ms, the p50 being 50ms
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	deltas := compareCDF(reference, latencies)
+	want := []cdfDelta{
+		{percentile: 50, reference: 10 * time.Millisecond, current: 50 * time.Millisecond},
+		{percentile: 90, reference: 20 * time.Millisecond, current: 90 * time.Millisecond},
+		{percentile: 99, reference: 40 * time.Millisecond, current: 99 * time.Millisecond},
+	}
+	if len(deltas) != len(want) {
+		t.Fatalf("deltas = %+v, want %+v", deltas, want)
+	}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("delta at p%v = %+v, want %+v", want[i].percentile, deltas[i], want[i])
+		}
+	}
+	if got, want := deltas[0].delta(), 40*time.Millisecond; got != want {
+		t.Errorf("p50 delta = %v, want %v", got, want)
+	}
+	if got, want := deltas[0].relative(), 4.0; got != want {
+		t.Errorf("p50 relative delta = %v, want %v", got, want)
+	}
+
+	faster := compareCDF([]cdfPoint{{percentile: 50, latency: 100 * time.Millisecond}}, latencies)
+	if got, want := faster[0].relative(), -0.5; got != want {
+		t.Errorf("relative delta of a faster run = %v, want %v", got, want)
+	}
+}
+
+func TestCDFRoundTrip(t *testing.T) {
+	latencies := []time.Duration{time.Millisecond, 2 * time.Millisecond, 1500 * time.Microsecond}
+	sortDurations(latencies)
+	var b bytes.Buffer
+	if err := writeCDF(&b, "1kb", computeCDF(latencies)); err != nil {
+		t.Fatal("Failed to write the CDF:", err)
+	}
+	points, err := readCDF(&b)
+	if err != nil {
+		t.Fatal("Failed to read back the CDF:", err)
+	}
+	if len(points) != len(cdfPercentiles) {
+		t.Fatalf("read %d percentiles, want %d", len(points), len(cdfPercentiles))
+	}
+	for _, d := range compareCDF(points, latencies) {
+		if d.delta() != 0 {
+			t.Errorf("delta at p%v against its own CDF = %v, want 0", d.percentile, d.delta())
+		}
+	}
+}
+
+func TestInvalidReferenceCDF(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"50",
+		"50 ten",
+		"0 10",
+		"90 10\n50 20",
+	} {
+		if _, err := readCDF(strings.NewReader(in)); err == nil {
+			t.Errorf("readCDF(%q) = nil error, want it rejected", in)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// Kinds of the entries of a checkpoint.
+const (
+	// an events record list, *pb.EventsRecordList
+	eventsEntry byte = iota + 1
+	// a sender or receiver registering, *pb.RegisterRequest
+	registerEntry
+	// an edge of a window, *pb.WindowRequest with its time set
+	windowEntry
+	// a clock offset measured by the clock sync, clockObservation
+	clockEntry
+	// a request to finish the run early, *pb.FinishRequest
+	finishEntry
+)
+
+// checkpoint is a journal of the state the aggregator received during a run,
+// persisted to a file, e.g. on a mounted volume, so that an aggregator
+// restarted in the middle of the run recovers it by replaying the entries: the
+// events record lists, the registrations of the senders and receivers, the
+// marked windows, the clock offsets and the finish requests. Each entry is
+// appended as its size, a varint, followed by its kind, the time it was
+// received, a varint of Unix nanoseconds, and its content. Each entry is
+// written to disk before its request is acknowledged, so that a crash loses
+// nothing its sender or receiver considers recorded.
+type checkpoint struct {
+	sync.Mutex
+	path string
+	file *os.File
+	// size of the entries written so far, which a failed write is truncated to
+	size int64
+}
+
+// checkpointEntry is an entry of a checkpoint, holding a value of its kind.
+type checkpointEntry struct {
+	at    time.Time
+	value interface{}
+}
+
+// clockObservation is the clock offset of a source measured by the clock sync.
+type clockObservation struct {
+	source string
+	sample clockSample
+}
+
+// openCheckpoint opens the checkpoint at the given path, created if needed,
+// returning the entries it holds. An entry partially written by a crash ends
+// the checkpoint, so it's truncated before appending.
+func openCheckpoint(path string) (*checkpoint, []checkpointEntry, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, size, err := readCheckpoint(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	if info, err := file.Stat(); err == nil && info.Size() > size {
+		log.Printf("!! Truncating the entry partially written at the end of the checkpoint %s", path)
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return &checkpoint{path: path, file: file, size: size}, entries, nil
+}
+
+// readCheckpoint reads the entries of a checkpoint, returning them and the
+// size of the complete ones, ignoring the last one if partially written.
+func readCheckpoint(r *bufio.Reader) ([]checkpointEntry, int64, error) {
+	var entries []checkpointEntry
+	var size int64
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return entries, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err == io.ErrUnexpectedEOF || err == io.EOF {
+			return entries, size, nil
+		} else if err != nil {
+			return nil, 0, err
+		}
+		e, err := decodeCheckpointEntry(b)
+		if err != nil {
+			return nil, 0, fmt.Errorf("entry %d: %v", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+		size += int64(uvarintSize(n)) + int64(n)
+	}
+}
+
+// uvarintSize returns the number of bytes of the varint encoding of n.
+func uvarintSize(n uint64) int {
+	var b [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(b[:], n)
+}
+
+// encodeCheckpointEntry returns the encoding of an entry holding the given
+// value, received at the given time, without its size.
+func encodeCheckpointEntry(at time.Time, value interface{}) ([]byte, error) {
+	var kind byte
+	var content []byte
+	var err error
+	switch v := value.(type) {
+	case *pb.EventsRecordList:
+		kind = eventsEntry
+		content, err = proto.Marshal(v)
+	case *pb.RegisterRequest:
+		kind = registerEntry
+		content, err = proto.Marshal(v)
+	case *pb.WindowRequest:
+		kind = windowEntry
+		content, err = proto.Marshal(v)
+	case clockObservation:
+		kind = clockEntry
+		content = make([]byte, 2*binary.MaxVarintLen64, 2*binary.MaxVarintLen64+len(v.source))
+		n := binary.PutVarint(content, int64(v.sample.offset))
+		n += binary.PutVarint(content[n:], int64(v.sample.rtt))
+		content = append(content[:n], v.source...)
+	case *pb.FinishRequest:
+		kind = finishEntry
+		content, err = proto.Marshal(v)
+	default:
+		return nil, fmt.Errorf("can't checkpoint a %T", value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(content))
+	b[0] = kind
+	return append(b[:1+binary.PutVarint(b[1:], at.UnixNano())], content...), nil
+}
+
+// decodeCheckpointEntry decodes an entry encoded by encodeCheckpointEntry.
+func decodeCheckpointEntry(b []byte) (checkpointEntry, error) {
+	if len(b) == 0 {
+		return checkpointEntry{}, fmt.Errorf("empty entry")
+	}
+	at, n := binary.Varint(b[1:])
+	if n <= 0 {
+		return checkpointEntry{}, fmt.Errorf("invalid time")
+	}
+	e := checkpointEntry{at: time.Unix(0, at)}
+	content := b[1+n:]
+	var msg proto.Message
+	switch b[0] {
+	case eventsEntry:
+		msg = &pb.EventsRecordList{}
+	case registerEntry:
+		msg = &pb.RegisterRequest{}
+	case windowEntry:
+		msg = &pb.WindowRequest{}
+	case clockEntry:
+		offset, n := binary.Varint(content)
+		if n <= 0 {
+			return checkpointEntry{}, fmt.Errorf("invalid clock offset")
+		}
+		rtt, m := binary.Varint(content[n:])
+		if m <= 0 {
+			return checkpointEntry{}, fmt.Errorf("invalid clock round trip")
+		}
+		e.value = clockObservation{
+			source: string(content[n+m:]),
+			sample: clockSample{offset: time.Duration(offset), rtt: time.Duration(rtt)},
+		}
+		return e, nil
+	case finishEntry:
+		msg = &pb.FinishRequest{}
+	default:
+		return checkpointEntry{}, fmt.Errorf("unknown kind %d", b[0])
+	}
+	if err := proto.Unmarshal(content, msg); err != nil {
+		return checkpointEntry{}, err
+	}
+	e.value = msg
+	return e, nil
+}
+
+// append writes an entry holding the given value, received at the given time,
+// to the checkpoint and syncs it to disk, returning once it's durable.
+func (c *checkpoint) append(at time.Time, value interface{}) error {
+	b, err := encodeCheckpointEntry(at, value)
+	if err != nil {
+		return err
+	}
+	entry := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(b))
+	entry = append(entry[:binary.PutUvarint(entry, uint64(len(b)))], b...)
+	c.Lock()
+	defer c.Unlock()
+	if c.file == nil {
+		return fmt.Errorf("checkpoint %s already removed", c.path)
+	}
+	if _, err := c.file.Write(entry); err != nil {
+		c.rollback()
+		return err
+	}
+	if err := c.file.Sync(); err != nil {
+		c.rollback()
+		return err
+	}
+	c.size += int64(len(entry))
+	return nil
+}
+
+// rollback drops what a failed write left of an entry at the end of the
+// checkpoint, so that the next entries follow the complete ones. It must be
+// called with the lock held.
+func (c *checkpoint) rollback() {
+	if err := c.file.Truncate(c.size); err != nil {
+		log.Printf("ERROR truncating the checkpoint %s after a failed write: %v", c.path, err)
+	}
+	if _, err := c.file.Seek(c.size, io.SeekStart); err != nil {
+		log.Printf("ERROR seeking the end of the checkpoint %s after a failed write: %v", c.path, err)
+	}
+}
+
+// remove closes and deletes the checkpoint, once the results of the run are
+// published, so that the next run doesn't resume it.
+func (c *checkpoint) remove() error {
+	c.Lock()
+	defer c.Unlock()
+	c.file.Close()
+	c.file = nil
+	return os.Remove(c.path)
+}
+
+// writeCheckpoint appends an entry holding the given value, received at the
+// given time, to the checkpoint if any, returning an unavailable status error
+// if it failed, so that the request is retried.
+func (ag *Aggregator) writeCheckpoint(at time.Time, value interface{}) error {
+	if ag.checkpoint == nil {
+		return nil
+	}
+	if err := ag.checkpoint.append(at, value); err != nil {
+		log.Printf("ERROR checkpointing a %T: %v", value, err)
+		return status.Errorf(codes.Unavailable, "failed to checkpoint: %v", err)
+	}
+	return nil
+}
+
+// restoreCheckpoint opens the checkpoint and replays the entries it holds,
+// recovering the state of the aggregator before a restart, then appends the
+// entries received from then on to it.
+func (ag *Aggregator) restoreCheckpoint() error {
+	c, entries, err := openCheckpoint(ag.checkpointPath)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		lists := 0
+		for _, e := range entries {
+			if _, ok := e.value.(*pb.EventsRecordList); ok {
+				lists++
+			}
+		}
+		log.Printf("Resuming from the %d entries of the checkpoint %s, %d of them events record lists",
+			len(entries), ag.checkpointPath, lists)
+		for _, e := range entries {
+			ag.replayCheckpointEntry(e)
+		}
+	}
+	ag.checkpoint = c
+	return nil
+}
+
+// replayCheckpointEntry applies again an entry of the checkpoint, as the
+// request it was written for did, but without the record delay nor the checks
+// the request already passed.
+func (ag *Aggregator) replayCheckpointEntry(e checkpointEntry) {
+	switch v := e.value.(type) {
+	case *pb.EventsRecordList:
+		ag.replayEvents(v, e.at)
+	case *pb.RegisterRequest:
+		ag.registered.register(v.Source, v.ExpectedBatches)
+	case *pb.WindowRequest:
+		at, err := ptypes.Timestamp(v.At)
+		if err == nil {
+			err = ag.windows.mark(v.Kind, v.Edge, at, nil)
+		}
+		if err != nil {
+			log.Printf("!! Ignoring the checkpointed %s of the %s window from %q: %v", v.Edge, v.Kind, v.Source, err)
+		}
+	case clockObservation:
+		if ag.clockSync != nil {
+			ag.clockSync.keep(v.source, v.sample)
+		}
+	case *pb.FinishRequest:
+		log.Printf("Finish requested by %q before the restart", v.Source)
+		ag.requestFinish()
+	}
+}
+
+// replayEvents records again an events record list of the checkpoint, arrived
+// at the given time, as RecordEvents recorded it, but without the record delay
+// nor the duplicate checks it already passed. Its submission is remembered, so
+// that its upload retried against the restarted aggregator is ignored.
+func (ag *Aggregator) replayEvents(in *pb.EventsRecordList, arrived time.Time) {
+	if in.SubmissionId != "" {
+		ag.submissions.seen(in.SubmissionId)
+	}
+	if ag.batches != nil {
+		if hash, ok := batchHash(in); ok {
+			ag.batches.seen(hash)
+		}
+	}
+	ag.mergeEvents(in, arrived)
+	ag.countRecords(in)
+}
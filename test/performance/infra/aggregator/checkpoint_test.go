@@ -0,0 +1,267 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestCheckpointResumesRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	now := time.Now()
+
+	// the first aggregator records the sent events, then crashes
+	crashed := newTestAggregator(t, 2, WithCheckpoint(path))
+	crashed.listener.Close()
+	if err := crashed.restoreCheckpoint(); err != nil {
+		t.Fatal("restoreCheckpoint() of a new checkpoint =", err)
+	}
+	recordEvents(t, crashed, &pb.EventsRecordList{Source: "sender", SubmissionId: "sent", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1", "2"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1", "2"),
+	}})
+
+	// the restarted one resumes from the checkpoint
+	ag := newTestAggregator(t, 2, WithCheckpoint(path))
+	done := runAggregator(context.Background(), ag)
+	// uploaded again by the sender retrying, ignored
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "sender", SubmissionId: "sent", Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1", "2"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "receiver", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "1", "2"),
+	}})
+	waitForRun(t, done)
+
+	if got := ag.records.value(); got != 2 {
+		t.Errorf("records count = %d, want 2", got)
+	}
+	if sent, received := len(ag.sentEvents.Events), len(ag.receivedEvents.Events); sent != 2 || received != 2 {
+		t.Errorf("got %d sent and %d received events, want 2 of each", sent, received)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint kept once the results are published: %v", err)
+	}
+}
+
+func TestCheckpointReplayedWithoutRecordDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	c, _, err := openCheckpoint(path)
+	if err != nil {
+		t.Fatal("openCheckpoint() of a new checkpoint =", err)
+	}
+	if err := c.append(time.Now(), &pb.EventsRecordList{Source: "sender", SubmissionId: "sent", Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, time.Now(), "1", "2"),
+	}}); err != nil {
+		t.Fatal("append() =", err)
+	}
+	c.file.Close()
+
+	ag := newTestAggregator(t, 2, WithCheckpoint(path), WithRecordDelay(time.Hour))
+	ag.listener.Close()
+	restored := make(chan error, 1)
+	go func() {
+		restored <- ag.restoreCheckpoint()
+	}()
+	select {
+	case err := <-restored:
+		if err != nil {
+			t.Fatal("restoreCheckpoint() =", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout restoring the checkpoint, the record delay was applied")
+	}
+	defer ag.checkpoint.file.Close()
+	if got := ag.records.value(); got != 1 || len(ag.sentEvents.Events) != 2 {
+		t.Errorf("restored %d lists and %d sent events, want 1 and 2", got, len(ag.sentEvents.Events))
+	}
+	if !ag.submissions.seen("sent") {
+		t.Error("submission of the restored list not remembered")
+	}
+}
+
+func TestCheckpointRestoresState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	windowStart := time.Now().Add(-time.Minute).Round(0)
+	arrived := time.Now().Add(-time.Second).Round(0)
+
+	// the first aggregator is told about the senders, windows, clocks and the
+	// finish of the run, then crashes
+	crashed := newTestAggregator(t, 2, WithCheckpoint(path), WithClockSync(2))
+	crashed.now = func() time.Time { return arrived }
+	if err := crashed.restoreCheckpoint(); err != nil {
+		t.Fatal("restoreCheckpoint() of a new checkpoint =", err)
+	}
+	go crashed.server.Serve(crashed.listener)
+	client, err := pb.NewAggregatorClient(crashed.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	if err := client.Register("sender", 1); err != nil {
+		t.Fatal("Register() =", err)
+	}
+	if err := client.MarkWindowAt("maintenance", pb.WindowRequest_START, "operator", windowStart); err != nil {
+		t.Fatal("MarkWindowAt() =", err)
+	}
+	if err := client.SyncClock("sender"); err != nil {
+		t.Fatal("SyncClock() =", err)
+	}
+	if err := client.Finish("operator"); err != nil {
+		t.Fatal("Finish() =", err)
+	}
+	publishRecords(t, crashed, &pb.EventsRecordList{Source: "sender", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, time.Now(), "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, time.Now(), "1"),
+	}})
+	client.Close()
+	crashed.server.Stop()
+	crashed.checkpoint.file.Close()
+
+	// the restarted one recovers them
+	// remembering when the events were inserted, to check their arrival time
+	ag := newTestAggregator(t, 2, WithCheckpoint(path), WithClockSync(2), WithUnmatchedTTL(time.Hour))
+	ag.listener.Close()
+	if err := ag.restoreCheckpoint(); err != nil {
+		t.Fatal("restoreCheckpoint() =", err)
+	}
+	defer ag.checkpoint.file.Close()
+
+	if under := ag.registered.underdelivered(); len(under) != 0 {
+		t.Errorf("underdelivered sources = %v, want the registered sender done", under)
+	}
+	if len(ag.registered.sources) != 1 {
+		t.Errorf("registered sources = %v, want the sender", ag.registered.sources)
+	}
+	if start, ok := ag.windows.open["maintenance"]; !ok || !start.Equal(windowStart) {
+		t.Errorf("maintenance window open since %v, %t, want since %v", start, ok, windowStart)
+	}
+	want, _ := crashed.clockSync.get("sender")
+	if offset, ok := ag.clockSync.get("sender"); !ok || offset != want {
+		t.Errorf("clock offset of the sender = %v, %t, want %v", offset, ok, want)
+	}
+	select {
+	case <-ag.finishRequested:
+	default:
+		t.Error("finish request not restored")
+	}
+	if inserted := ag.acceptedEvents.inserted["1"]; !inserted.Equal(arrived) {
+		t.Errorf("accepted event inserted at %v, want its arrival before the restart at %v", inserted, arrived)
+	}
+}
+
+func TestCheckpointTruncatesPartialList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	c, entries, err := openCheckpoint(path)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("openCheckpoint() of a new checkpoint = %d entries, %v", len(entries), err)
+	}
+	first := &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1")}}
+	if err := c.append(time.Now(), first); err != nil {
+		t.Fatal("append() =", err)
+	}
+	c.file.Close()
+
+	// a crash in the middle of writing the size then the content of an entry
+	for _, partial := range [][]byte{{0x80}, {0x10, 0x0a, 0x01}} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(partial)
+		f.Close()
+
+		c, entries, err = openCheckpoint(path)
+		if err != nil {
+			t.Fatalf("openCheckpoint() with the partial entry %v = %v", partial, err)
+		}
+		if len(entries) != 1 || !proto.Equal(entries[0].value.(proto.Message), first) {
+			t.Fatalf("openCheckpoint() with the partial entry %v = %v, want the first list", partial, entries)
+		}
+		c.file.Close()
+	}
+
+	c, _, err = openCheckpoint(path)
+	if err != nil {
+		t.Fatal("openCheckpoint() =", err)
+	}
+	second := &pb.EventsRecordList{Source: "receiver", Final: true}
+	if err := c.append(time.Now(), second); err != nil {
+		t.Fatal("append() =", err)
+	}
+	c.file.Close()
+	if _, entries, err = openCheckpoint(path); err != nil || len(entries) != 2 || !proto.Equal(entries[1].value.(proto.Message), second) {
+		t.Errorf("openCheckpoint() after appending to the truncated checkpoint = %v, %v, want both lists", entries, err)
+	}
+}
+
+func TestCheckpointRejectsCorruptedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	// a complete entry of an unknown kind
+	if err := ioutil.WriteFile(path, []byte{0x02, 0xff, 0xff}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openCheckpoint(path); err == nil {
+		t.Error("openCheckpoint() of a corrupted checkpoint succeeded")
+	}
+}
+
+func TestFailedCheckpointFailsUpload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	ag := newTestAggregator(t, 1, WithCheckpoint(path))
+	ag.listener.Close()
+	if err := ag.restoreCheckpoint(); err != nil {
+		t.Fatal("restoreCheckpoint() of a new checkpoint =", err)
+	}
+	rl := &pb.EventsRecordList{Source: "sender", SubmissionId: "sent", Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, time.Now(), "1"),
+	}}
+
+	// e.g. the volume of the checkpoint failing
+	ag.checkpoint.file.Close()
+	if _, err := ag.RecordEvents(context.Background(), rl); status.Code(err) != codes.Unavailable {
+		t.Fatalf("RecordEvents() with a failed checkpoint = %v, want unavailable", err)
+	}
+	if got := ag.records.value(); got != 0 || len(ag.sentEvents.Events) != 0 {
+		t.Fatalf("recorded %d lists and %d events of the failed upload, want none", got, len(ag.sentEvents.Events))
+	}
+
+	// the retry is recorded rather than ignored as a duplicate
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag.checkpoint.file = file
+	reply, err := ag.RecordEvents(context.Background(), rl)
+	if err != nil || reply.DuplicateSubmission {
+		t.Fatalf("RecordEvents() of the retry = %v, %v, want it recorded", reply, err)
+	}
+	file.Close()
+	if _, entries, err := openCheckpoint(path); err != nil || len(entries) != 1 || !proto.Equal(entries[0].value.(proto.Message), rl) {
+		t.Errorf("openCheckpoint() after the retry = %v, %v, want the retried list", entries, err)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"io"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// RecordEventsChunks implements event_state.EventsRecorder, recording the
+// events record list uploaded in chunks once all of them are received, as a
+// single list.
+func (ag *Aggregator) RecordEventsChunks(stream pb.EventsRecorder_RecordEventsChunksServer) error {
+	var chunks []*pb.EventsRecordList
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("!! Failed to receive the chunks of an events record list after %d of them: %v", len(chunks), err)
+			return err
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	in, err := pb.MergeChunks(chunks)
+	if err != nil {
+		log.Printf("!! Ignoring the chunked events records: %v", err)
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	log.Printf("Received the events records from %q in %d chunks", in.Source, len(chunks))
+	reply, err := ag.RecordEvents(stream.Context(), in)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(reply)
+}
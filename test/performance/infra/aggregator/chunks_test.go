@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// newLargeRecordList returns a list of sent events and of the received keyed
+// events with the given number of events each.
+func newLargeRecordList(n int) *pb.EventsRecordList {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("event-%d", i)
+	}
+	now := time.Now()
+	sent := newRecord(pb.EventsRecord_SENT, now, ids...)
+	sent.Monotonic = make(map[string]int64, n)
+	sent.PayloadSizes = make(map[string]uint32, n)
+	received := &pb.EventsRecord{Type: pb.EventsRecord_RECEIVED}
+	for i, id := range ids {
+		sent.Monotonic[id] = int64(i)
+		sent.PayloadSizes[id] = uint32(100 + i)
+		received.KeyedEvents = append(received.KeyedEvents, &pb.KeyedEvent{
+			Key: &pb.EventKey{Partition: "p", Sequence: uint64(i)},
+			At:  sent.Events[id],
+		})
+	}
+	return &pb.EventsRecordList{Items: []*pb.EventsRecord{sent, received}, Source: "sender-1", SubmissionId: "submission", Final: true, Tags: []string{"encoding=binary"}}
+}
+
+func TestSplitAndMergeChunks(t *testing.T) {
+	rl := newLargeRecordList(100)
+	size := proto.Size(rl)
+
+	if chunks := pb.SplitChunks(rl, size); len(chunks) != 1 || chunks[0] != rl {
+		t.Errorf("SplitChunks() of a list within the size = %d chunks, want the list itself", len(chunks))
+	}
+
+	chunks := pb.SplitChunks(rl, size/4)
+	if len(chunks) < 4 {
+		t.Fatalf("SplitChunks() = %d chunks, want at least 4", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if got := proto.Size(chunk); got > size/2 {
+			t.Errorf("chunk %d size = %d bytes, want about %d", i, got, size/4)
+		}
+	}
+
+	merged, err := pb.MergeChunks(chunks)
+	if err != nil {
+		t.Fatal("MergeChunks() =", err)
+	}
+	if !proto.Equal(merged, rl) {
+		t.Error("MergeChunks() differs from the split list")
+	}
+
+	chunks = pb.SplitChunks(rl, size/4)
+	chunks[1].Items = chunks[1].Items[:1]
+	if _, err := pb.MergeChunks(chunks); err == nil {
+		t.Error("MergeChunks() of chunks with different items succeeded")
+	}
+}
+
+func TestChunkedCompressedUpload(t *testing.T) {
+	// the list is larger than the maximum size, but not its chunks
+	ag := newTestAggregator(t, 1, WithMaxRecvMsgSize(4096))
+	done := runAggregator(context.Background(), ag)
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	client.SetUploadOptions(pb.UploadOptions{Compress: true, ChunkSize: 2048})
+	if err := client.Publish(newLargeRecordList(200)); err != nil {
+		t.Fatal("Publish() in chunks =", err)
+	}
+	waitForRun(t, done)
+
+	if got := ag.oversize.count(); got != 0 {
+		t.Errorf("oversize records count = %d, want 0", got)
+	}
+	if got := len(ag.sentEvents.Events); got != 200 {
+		t.Errorf("sent events count = %d, want 200", got)
+	}
+	if got := ag.records.value(); got != 1 {
+		t.Errorf("records count = %d, want the chunks counted as 1 list", got)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// defaultClockSkewCases is how many of the worst negative latencies of each
+// type are detailed by default.
+const defaultClockSkewCases = 10
+
+// clockSkew is an event recorded as accepted or received before it was sent,
+// which can only be caused by the clocks of the sender and receiver drifting apart.
+type clockSkew struct {
+	id string
+
+	sent time.Time
+	// at is when the event was accepted or received
+	at time.Time
+
+	sender string
+	// observer is the identity of the sender or receiver which recorded at
+	observer string
+}
+
+// skew is how long before being sent the event was accepted or received.
+func (c *clockSkew) skew() time.Duration {
+	return c.sent.Sub(c.at)
+}
+
+// clockSkews is the set of negative latencies of one type of events record.
+type clockSkews struct {
+	// recordType is either accepted or received
+	recordType string
+	count      int
+	// worst are the cases with the largest skew, in decreasing order
+	worst []clockSkew
+}
+
+// findClockSkews returns the accepted and received negative latencies of the
+// events, keeping only the n worst cases of each.
+func findClockSkews(events []event, n int) (accepted clockSkews, received clockSkews) {
+	accepted.recordType = "accepted"
+	received.recordType = "received"
+	for i := range events {
+		e := &events[i]
+		if e.isAccepted && e.accepted.Before(e.sent) {
+			accepted.count++
+			accepted.worst = append(accepted.worst, clockSkew{id: e.id, sent: e.sent, at: e.accepted, sender: e.sender, observer: e.sender})
+		}
+		if e.isReceived && e.received.Before(e.sent) {
+			received.count++
+			received.worst = append(received.worst, clockSkew{id: e.id, sent: e.sent, at: e.received, sender: e.sender, observer: e.receiver})
+		}
+	}
+	accepted.keepWorst(n)
+	received.keepWorst(n)
+	return accepted, received
+}
+
+func (cs *clockSkews) keepWorst(n int) {
+	sort.SliceStable(cs.worst, func(i, j int) bool { return cs.worst[i].skew() > cs.worst[j].skew() })
+	if len(cs.worst) > n {
+		cs.worst = cs.worst[:n]
+	}
+}
+
+// report logs the number of negative latencies and details the worst cases.
+func (cs *clockSkews) report() {
+	if cs.count == 0 {
+		return
+	}
+	log.Printf("!! %d events were %s before being sent, worst %d:", cs.count, cs.recordType, len(cs.worst))
+	for _, c := range cs.worst {
+		log.Printf("   event %s: sent at %s on %s, %s at %s on %s, skew %v",
+			c.id, c.sent.Format(time.RFC3339Nano), identity(c.sender), cs.recordType,
+			c.at.Format(time.RFC3339Nano), identity(c.observer), c.skew())
+	}
+}
+
+// identity returns the given sender or receiver identity, or unknownSender
+// if the records didn't carry one.
+func identity(source string) string {
+	if source == "" {
+		return unknownSender
+	}
+	return source
+}
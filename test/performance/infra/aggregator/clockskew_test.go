@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFindClockSkews(t *testing.T) {
+	sent := time.Unix(1000, 0)
+
+	var events []event
+	// received up to 50ms before being sent
+	for i := 0; i < 5; i++ {
+		events = append(events, event{
+			id:         fmt.Sprintf("skewed-%d", i),
+			sender:     "sender-a",
+			receiver:   "receiver-b",
+			sent:       sent,
+			accepted:   sent.Add(time.Millisecond),
+			received:   sent.Add(-time.Duration(i*10) * time.Millisecond),
+			isAccepted: true,
+			isReceived: true,
+		})
+	}
+	// accepted before being sent, and never received
+	events = append(events, event{
+		id:         "accepted-early",
+		sender:     "sender-c",
+		sent:       sent,
+		accepted:   sent.Add(-340 * time.Millisecond),
+		isAccepted: true,
+	})
+	// never received, so ignored whatever its received timestamp
+	events = append(events, event{id: "lost", sent: sent, received: sent.Add(-time.Hour)})
+
+	accepted, received := findClockSkews(events, 3)
+
+	if accepted.count != 1 || len(accepted.worst) != 1 {
+		t.Fatalf("accepted skews = %d (%d detailed), want 1", accepted.count, len(accepted.worst))
+	}
+	if c := accepted.worst[0]; c.id != "accepted-early" || c.observer != "sender-c" || c.skew() != 340*time.Millisecond {
+		t.Errorf("accepted skew = %+v, want accepted-early on sender-c with skew 340ms", c)
+	}
+
+	// skewed-0 has a zero latency, which is not negative
+	if received.count != 4 {
+		t.Errorf("received skews = %d, want 4", received.count)
+	}
+	wantIDs := []string{"skewed-4", "skewed-3", "skewed-2"}
+	if len(received.worst) != len(wantIDs) {
+		t.Fatalf("received detailed skews = %d, want %d", len(received.worst), len(wantIDs))
+	}
+	for i, c := range received.worst {
+		if c.id != wantIDs[i] {
+			t.Errorf("worst received skew %d = %s, want %s", i, c.id, wantIDs[i])
+		}
+		if c.sender != "sender-a" || c.observer != "receiver-b" {
+			t.Errorf("worst received skew %d identities = %s, %s, want sender-a, receiver-b", i, c.sender, c.observer)
+		}
+	}
+}
+
+func TestNegativeClockSkewCasesAreRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, WithClockSkewCases(-1)); err == nil {
+		t.Error("NewAggregator() with -1 clock skew cases succeeded, want an error")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// clockSkewKey is the key of the estimated skew between the clocks of the
+// receivers and the senders of the delivered events.
+const clockSkewKey = "csk"
+
+// clockSync holds the offset of the clock of each sender and receiver from the
+// clock of the aggregator, measured by pinging them over the SyncClock stream.
+// The offset of the ping with the shortest round trip is kept, as the least
+// distorted by the transit times.
+type clockSync struct {
+	// number of pings of each handshake
+	rounds int
+
+	sync.Mutex
+	bySource map[string]clockSample
+}
+
+// clockSample is the offset of a clock measured by a ping, and its round trip.
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+func newClockSync(rounds int) *clockSync {
+	return &clockSync{rounds: rounds, bySource: make(map[string]clockSample)}
+}
+
+// observe records the offset of the clock of the source from a ping sent and
+// answered at the given times by the clock of the aggregator, the source
+// reading its clock at the given time. The answer is assumed to take as long
+// as the ping, so that the source read its clock halfway through the round trip.
+// It returns the measured sample.
+func (c *clockSync) observe(source string, sent, answered, arrived time.Time) clockSample {
+	rtt := arrived.Sub(sent)
+	s := clockSample{offset: answered.Sub(sent.Add(rtt / 2)), rtt: rtt}
+	c.keep(source, s)
+	return s
+}
+
+// keep records the given sample of the clock of the source, unless one with a
+// shorter round trip was already recorded.
+func (c *clockSync) keep(source string, s clockSample) {
+	c.Lock()
+	defer c.Unlock()
+	if prev, ok := c.bySource[source]; !ok || s.rtt < prev.rtt {
+		c.bySource[source] = s
+	}
+}
+
+// get returns the offset of the clock of the given source, if measured.
+func (c *clockSync) get(source string) (time.Duration, bool) {
+	c.Lock()
+	defer c.Unlock()
+	s, ok := c.bySource[source]
+	return s.offset, ok
+}
+
+// report logs the offset of each source and the round trip it was measured over.
+func (c *clockSync) report() {
+	c.Lock()
+	defer c.Unlock()
+	sources := make([]string, 0, len(c.bySource))
+	for s := range c.bySource {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+	log.Printf("Clock offsets of %d sources measured by the clock sync:", len(sources))
+	for _, s := range sources {
+		log.Printf("  %s: %v (round trip %v)", s, c.bySource[s].offset, c.bySource[s].rtt)
+	}
+}
+
+// correctClockSkew moves the received timestamps of the received events to
+// the clock of their sender, by the difference between the offsets of the
+// clocks of their receiver and their sender, returning the number of corrected
+// events and the median of the differences. Events whose sender or receiver
+// offset is unknown are left unchanged.
+func correctClockSkew(events []event, offsets *clockSync) (int, time.Duration) {
+	var skews []time.Duration
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived {
+			continue
+		}
+		sentOffset, ok := offsets.get(e.sender)
+		if !ok {
+			continue
+		}
+		receivedOffset, ok := offsets.get(e.receiver)
+		if !ok {
+			continue
+		}
+		skew := receivedOffset - sentOffset
+		e.received = e.received.Add(-skew)
+		for j := range e.redelivered {
+			e.redelivered[j] = e.redelivered[j].Add(-skew)
+		}
+		skews = append(skews, skew)
+	}
+	if len(skews) == 0 {
+		return 0, 0
+	}
+	sortDurations(skews)
+	return len(skews), percentile(skews, 50)
+}
+
+// reportClockSkew corrects the received timestamps of the events by the clock
+// offsets measured by the clock sync, logging the offsets and the estimated
+// skew, and returns the skew, nil if no event was corrected.
+func reportClockSkew(events []event, offsets *clockSync) *time.Duration {
+	offsets.report()
+	corrected, skew := correctClockSkew(events, offsets)
+	if skipped := len(e2eLatencies(events)) - corrected; skipped > 0 {
+		log.Printf("!! Not correcting %d delivered events whose sender or receiver clock offset is unknown", skipped)
+	}
+	if corrected == 0 {
+		return nil
+	}
+	log.Printf("Corrected the deliver latencies of %d events by a median clock skew of %v between their receiver and their sender",
+		corrected, skew)
+	return &skew
+}
+
+// SyncClock implements event_state.EventsRecorder, pinging the sender or
+// receiver identified by the first pong to measure the offset of its clock,
+// or ending the stream right away when the clock sync is disabled.
+func (ag *Aggregator) SyncClock(stream pb.EventsRecorder_SyncClockServer) error {
+	hello, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if ag.clockSync == nil {
+		return nil
+	}
+	for seq := uint64(1); seq <= uint64(ag.clockSync.rounds); seq++ {
+		sent := ag.now()
+		if err := stream.Send(&pb.ClockPing{Seq: seq}); err != nil {
+			return err
+		}
+		pong, err := stream.Recv()
+		if err != nil {
+			log.Printf("!! Clock sync of %q interrupted after %d pings: %v", hello.Source, seq-1, err)
+			return err
+		}
+		arrived := ag.now()
+		if pong.Seq != seq {
+			return status.Errorf(codes.InvalidArgument, "pong %d doesn't answer the ping %d", pong.Seq, seq)
+		}
+		answered, err := ptypes.Timestamp(pong.At)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid time of the pong %d: %v", seq, err)
+		}
+		s := ag.clockSync.observe(hello.Source, sent, answered, arrived)
+		if err := ag.writeCheckpoint(arrived, clockObservation{source: hello.Source, sample: s}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestClockSyncKeepsShortestRoundTrip(t *testing.T) {
+	offsets := newClockSync(3)
+	now := time.Now()
+	// answered 1s ahead, halfway through a round trip of 100ms
+	offsets.observe("sender", now, now.Add(time.Second+50*time.Millisecond), now.Add(100*time.Millisecond))
+	// a shorter round trip, more accurate
+	offsets.observe("sender", now, now.Add(time.Second+10*time.Millisecond+5*time.Millisecond), now.Add(20*time.Millisecond))
+	// a longer one, ignored
+	offsets.observe("sender", now, now.Add(3*time.Second), now.Add(time.Second))
+
+	if got, ok := offsets.get("sender"); !ok || got != time.Second+5*time.Millisecond {
+		t.Errorf("sender offset = %v, %t, want %v", got, ok, time.Second+5*time.Millisecond)
+	}
+	if _, ok := offsets.get("receiver"); ok {
+		t.Error("got an offset for the receiver which never synced its clock")
+	}
+}
+
+func TestCorrectClockSkew(t *testing.T) {
+	const latency = 10 * time.Millisecond
+	offsets := newClockSync(1)
+	now := time.Now()
+	// the clock of the receiver is 2s ahead of the aggregator's, the sender's 500ms behind
+	offsets.observe("sender", now, now.Add(-500*time.Millisecond), now)
+	offsets.observe("receiver", now, now.Add(2*time.Second), now)
+
+	events := []event{
+		{id: "a", sender: "sender", receiver: "receiver", sent: now, received: now.Add(latency + 2500*time.Millisecond),
+			redelivered: []time.Time{now.Add(2 * latency)}, isAccepted: true, isReceived: true},
+		{id: "b", sender: "sender", receiver: "receiver", sent: now, received: now.Add(latency + 2500*time.Millisecond), isAccepted: true, isReceived: true},
+		{id: "unknown-receiver", sender: "sender", receiver: "other", sent: now, received: now.Add(latency), isAccepted: true, isReceived: true},
+		{id: "lost", sender: "sender", sent: now, isAccepted: true},
+	}
+	corrected, skew := correctClockSkew(events, offsets)
+	if corrected != 2 || skew != 2500*time.Millisecond {
+		t.Errorf("correctClockSkew() = %d, %v, want 2, %v", corrected, skew, 2500*time.Millisecond)
+	}
+	for _, e := range events[:3] {
+		if got := e.e2eLatency(); got != latency {
+			t.Errorf("latency of %s = %v, want %v", e.id, got, latency)
+		}
+	}
+	if want := now.Add(2*latency - 2500*time.Millisecond); !events[0].redelivered[0].Equal(want) {
+		t.Errorf("redelivery = %v, want %v", events[0].redelivered[0], want)
+	}
+}
+
+func TestClockSkewPublishedInLowSampleRun(t *testing.T) {
+	skew := 2500 * time.Millisecond
+	degenerate := true
+	for _, a := range []runAggregates{
+		{minSamplesForPercentiles: defaultMinSamplesForPercentiles, e2eLatencies: []time.Duration{time.Millisecond},
+			clockSkew: &skew, skewEstimate: &skew, periodicity: &latencyPeriodicity{period: time.Minute, strength: 0.5}},
+		{degenerate: &degenerate, clockSkew: &skew, skewEstimate: &skew, periodicity: &latencyPeriodicity{period: time.Minute, strength: 0.5}},
+	} {
+		q := newFakeAggregatesStore()
+		a.publish(q)
+		if q.run[clockSkewKey] != 2.5 || q.run["sk"] != 2.5 || q.run["lps"] != 60 || q.run["lpc"] != 0.5 {
+			t.Errorf("published %s %v, sk %v, lps %v and lpc %v, want 2.5, 2.5, 60 and 0.5",
+				clockSkewKey, q.run[clockSkewKey], q.run["sk"], q.run["lps"], q.run["lpc"])
+		}
+	}
+}
+
+func TestSyncClockOverGRPC(t *testing.T) {
+	for _, rounds := range []int{0, 4} {
+		var opts []AggregatorOption
+		if rounds > 0 {
+			opts = append(opts, WithClockSync(rounds))
+		}
+		ag := newTestAggregator(t, 1, opts...)
+		done := runAggregator(context.Background(), ag)
+
+		client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+		if err != nil {
+			t.Fatal("Failed to create the aggregator client:", err)
+		}
+		if err := client.SyncClock("sender"); err != nil {
+			t.Errorf("SyncClock() with %d rounds = %v", rounds, err)
+		}
+		client.Close()
+
+		if rounds > 0 {
+			// same clock, the offset is bounded by the round trip
+			offset, ok := ag.clockSync.get("sender")
+			if !ok {
+				t.Error("no clock offset measured for the sender")
+			} else if offset < -100*time.Millisecond || offset > 100*time.Millisecond {
+				t.Errorf("clock offset = %v, want about 0", offset)
+			}
+		} else if ag.clockSync != nil {
+			t.Error("clock sync enabled without rounds")
+		}
+
+		publishRecords(t, ag, &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1")}})
+		waitForRun(t, done)
+	}
+}
+
+func TestClockSyncValidation(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, WithClockSync(-1)); err == nil {
+		t.Error("NewAggregator() with negative clock sync rounds succeeded")
+	}
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, WithClockSync(3), WithStreamingAggregation(true)); err == nil {
+		t.Error("NewAggregator() with clock sync and streaming aggregation succeeded")
+	}
+}
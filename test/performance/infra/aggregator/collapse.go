@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// Policies collapsing the multiple timestamps of an event recorded several
+// times, e.g. received by several subscribers which aren't tracked separately.
+// By default, the duplicates of the first recorded timestamp are dropped.
+const (
+	// keep the earliest timestamp
+	collapseFirst = "first"
+	// keep the latest timestamp
+	collapseLast = "last"
+	// keep all the timestamps, each counting as a delivery of the event
+	collapseAll = "all"
+)
+
+// validateCollapse returns an error if the collapse policy is unknown.
+func validateCollapse(policy string) error {
+	switch policy {
+	case "", collapseFirst, collapseLast, collapseAll:
+		return nil
+	default:
+		return fmt.Errorf("unknown collapse policy %q, must be one of %s, %s or %s", policy, collapseFirst, collapseLast, collapseAll)
+	}
+}
+
+// collapse applies the collapse policy of the record to the duplicate
+// timestamp t of the event with the given ID, telling whether it replaced the
+// recorded one. It must be called with the lock held.
+func (rec *eventsRecord) collapse(id string, t *timestamp.Timestamp, source string) bool {
+	switch rec.collapsePolicy {
+	case collapseFirst, collapseLast:
+		recorded, err := ptypes.Timestamp(rec.Events[id])
+		if err != nil {
+			break
+		}
+		duplicate, err := ptypes.Timestamp(t)
+		if err != nil {
+			return false
+		}
+		if (rec.collapsePolicy == collapseFirst && !duplicate.Before(recorded)) ||
+			(rec.collapsePolicy == collapseLast && !duplicate.After(recorded)) {
+			return false
+		}
+	case collapseAll:
+		rec.duplicates[id] = append(rec.duplicates[id], t)
+		return false
+	default:
+		return false
+	}
+
+	rec.Events[id] = t
+	if source != "" {
+		rec.sources[id] = source
+	} else {
+		delete(rec.sources, id)
+	}
+	// the readings paired with the replaced timestamp
+	delete(rec.Monotonic, id)
+	delete(rec.clocks, id)
+	delete(rec.ContentHashes, id)
+	delete(rec.ChecksumMismatches, id)
+	delete(rec.OnWire, id)
+	delete(rec.Attempts, id)
+	delete(rec.PayloadSizes, id)
+	return true
+}
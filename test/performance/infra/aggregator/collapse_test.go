@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestReceivedCollapse(t *testing.T) {
+	sent := time.Now()
+	// received by three subscribers, the earliest delivery being recorded second
+	deliveries := []time.Duration{20 * time.Millisecond, 10 * time.Millisecond, 30 * time.Millisecond}
+
+	for _, tc := range []struct {
+		policy string
+		want   []time.Duration
+	}{
+		{policy: "", want: []time.Duration{20 * time.Millisecond}},
+		{policy: collapseFirst, want: []time.Duration{10 * time.Millisecond}},
+		{policy: collapseLast, want: []time.Duration{30 * time.Millisecond}},
+		{policy: collapseAll, want: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			ag := newTestAggregator(t, 0, WithReceivedCollapse(tc.policy))
+			recordEvents(t, ag, &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{
+				newRecord(pb.EventsRecord_SENT, sent, "fanned-out"),
+				newRecord(pb.EventsRecord_ACCEPTED, sent, "fanned-out"),
+			}})
+			for _, d := range deliveries {
+				recordEvents(t, ag, &pb.EventsRecordList{Source: "receiver", Items: []*pb.EventsRecord{
+					newRecord(pb.EventsRecord_RECEIVED, sent.Add(d), "fanned-out"),
+				}})
+			}
+
+			if got := e2eLatencies(ag.joinEvents()); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("e2eLatencies() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnknownReceivedCollapseIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithReceivedCollapse("median")); err == nil {
+		t.Error("NewAggregator() with an unknown collapse policy succeeded")
+	}
+}
+
+func TestReplacedDuplicateIsNotCountedAgain(t *testing.T) {
+	sent := time.Now()
+	ag := newTestAggregator(t, 0, WithReceivedCollapse(collapseLast))
+	recordEvents(t, ag, &pb.EventsRecordList{Source: "sender", Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, sent, "fanned-out"),
+	}})
+	first := newRecord(pb.EventsRecord_RECEIVED, sent.Add(10*time.Millisecond), "fanned-out")
+	first.Attempts = map[string]uint32{"fanned-out": 1}
+	first.PayloadSizes = map[string]uint32{"fanned-out": 100}
+	recordEvents(t, ag, &pb.EventsRecordList{Source: "receiver", Items: []*pb.EventsRecord{first}})
+	// the replacing record knows neither the attempt nor the payload size
+	recordEvents(t, ag, &pb.EventsRecordList{Source: "receiver", Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, sent.Add(20*time.Millisecond), "fanned-out"),
+	}})
+
+	if p := ag.progress(false); p.Received != 1 {
+		t.Errorf("progress() received %d events, want 1", p.Received)
+	}
+	if got := e2eLatencies(ag.joinEvents()); !reflect.DeepEqual(got, []time.Duration{20 * time.Millisecond}) {
+		t.Errorf("e2eLatencies() = %v, want [20ms]", got)
+	}
+	received := ag.receivedEvents
+	received.Lock()
+	defer received.Unlock()
+	if _, ok := received.Attempts["fanned-out"]; ok {
+		t.Error("the attempt of the replaced delivery was kept")
+	}
+	if _, ok := received.PayloadSizes["fanned-out"]; ok {
+		t.Error("the payload size of the replaced delivery was kept")
+	}
+}
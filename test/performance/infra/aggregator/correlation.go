@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"math"
+)
+
+// stageCorrelationKey is the value key of the correlation between the publish
+// and the delivery latencies.
+const stageCorrelationKey = "cr"
+
+// stageCorrelation returns the Pearson correlation coefficient between the
+// publish latencies and the delivery latencies (accept to receive) of the
+// events with all three timestamps, and the number of such events. It returns
+// false with less than two of them or if either latency is constant, the
+// coefficient being undefined.
+func stageCorrelation(events []event) (float64, int, bool) {
+	// Welford's single-pass update of the means and the co-moments, which
+	// doesn't lose precision on large sums of squares
+	var n int
+	var meanX, meanY, m2X, m2Y, coXY float64
+	for i := range events {
+		e := &events[i]
+		if !e.isAccepted || !e.isReceived {
+			continue
+		}
+		x, y := e.publishLatency().Seconds(), e.deliveryLatency().Seconds()
+		n++
+		dx, dy := x-meanX, y-meanY
+		meanX += dx / float64(n)
+		meanY += dy / float64(n)
+		m2X += dx * (x - meanX)
+		m2Y += dy * (y - meanY)
+		coXY += dx * (y - meanY)
+	}
+	if n < 2 || m2X == 0 || m2Y == 0 {
+		return 0, n, false
+	}
+	return coXY / math.Sqrt(m2X*m2Y), n, true
+}
+
+// reportStageCorrelation logs the correlation between the publish and the
+// delivery latencies of the events, returning it if defined.
+func reportStageCorrelation(events []event) *float64 {
+	r, n, ok := stageCorrelation(events)
+	if !ok {
+		log.Printf("Correlation between the publish and delivery latencies undefined over %d events", n)
+		return nil
+	}
+	log.Printf("Correlation between the publish and delivery latencies: %.3f over %d events", r, n)
+	return &r
+}
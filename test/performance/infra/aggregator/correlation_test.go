@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// stagedEvent returns a delivered event with the given publish and delivery latencies.
+func stagedEvent(publish, delivery time.Duration) event {
+	sent := time.Unix(0, 0)
+	return event{
+		sent:       sent,
+		accepted:   sent.Add(publish),
+		received:   sent.Add(publish + delivery),
+		isAccepted: true,
+		isReceived: true,
+	}
+}
+
+func TestStageCorrelation(t *testing.T) {
+	ms := time.Millisecond
+	for _, tc := range []struct {
+		name   string
+		events []event
+		want   float64
+		n      int
+	}{{
+		name:   "shared bottleneck",
+		events: []event{stagedEvent(1*ms, 10*ms), stagedEvent(2*ms, 20*ms), stagedEvent(3*ms, 30*ms)},
+		want:   1,
+		n:      3,
+	}, {
+		name:   "opposite",
+		events: []event{stagedEvent(1*ms, 30*ms), stagedEvent(2*ms, 20*ms), stagedEvent(3*ms, 10*ms)},
+		want:   -1,
+		n:      3,
+	}, {
+		name: "independent",
+		events: []event{stagedEvent(1*ms, 10*ms), stagedEvent(1*ms, 20*ms),
+			stagedEvent(2*ms, 10*ms), stagedEvent(2*ms, 20*ms),
+			// lost events don't count
+			{sent: time.Unix(0, 0), isAccepted: true}},
+		want: 0,
+		n:    4,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, n, ok := stageCorrelation(tc.events)
+			if !ok || n != tc.n || math.Abs(r-tc.want) > 1e-9 {
+				t.Errorf("stageCorrelation() = %v, %d, %v, want %v, %d, true", r, n, ok, tc.want, tc.n)
+			}
+		})
+	}
+}
+
+func TestStageCorrelationUndefined(t *testing.T) {
+	ms := time.Millisecond
+	for _, events := range [][]event{
+		nil,
+		{stagedEvent(1*ms, 10*ms)},
+		// constant publish latency
+		{stagedEvent(1*ms, 10*ms), stagedEvent(1*ms, 20*ms)},
+	} {
+		if r := reportStageCorrelation(events); r != nil {
+			t.Errorf("correlation over %d events = %v, want undefined", len(events), *r)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+)
+
+// corruptionCases is the number of corrupted events whose ID is logged.
+const corruptionCases = 10
+
+// findCorruptions compares the hashes of the content of the received events
+// with the ones of the sent events, returning how many were compared and the
+// sorted IDs of the corrupted ones.
+func findCorruptions(events []event) (compared int, corrupted []string) {
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived || !e.hashesCompared {
+			continue
+		}
+		compared++
+		if e.corrupted {
+			corrupted = append(corrupted, e.id)
+		}
+	}
+	sort.Strings(corrupted)
+	return compared, corrupted
+}
+
+// reportCorruptions logs the number of corrupted events, detailing the first ones.
+func reportCorruptions(compared int, corrupted []string) {
+	if len(corrupted) == 0 {
+		log.Printf("Content of the %d compared events is intact", compared)
+		return
+	}
+	log.Printf("!! Content of %d of the %d compared events was corrupted", len(corrupted), compared)
+	for i, id := range corrupted {
+		if i == corruptionCases {
+			log.Printf("  ... and %d more", len(corrupted)-corruptionCases)
+			break
+		}
+		log.Printf("  %s", id)
+	}
+}
+
+// findChecksumMismatches returns the sorted IDs of the received events whose
+// content didn't match the checksum computed by their sender, as reported by
+// their receiver.
+func findChecksumMismatches(events []event) []string {
+	var mismatches []string
+	for i := range events {
+		if events[i].isReceived && events[i].checksumMismatch {
+			mismatches = append(mismatches, events[i].id)
+		}
+	}
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+// reportChecksumMismatches logs the number of events whose checksum didn't
+// match, detailing the first ones.
+func reportChecksumMismatches(mismatches []string) {
+	if len(mismatches) == 0 {
+		return
+	}
+	log.Printf("!! Content of %d received events didn't match the checksum computed by their sender", len(mismatches))
+	for i, id := range mismatches {
+		if i == corruptionCases {
+			log.Printf("  ... and %d more", len(mismatches)-corruptionCases)
+			break
+		}
+		log.Printf("  %s", id)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestCorruptionDetection(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+
+	sent := newRecord(pb.EventsRecord_SENT, now, "intact", "corrupted", "unhashed", "lost")
+	sent.ContentHashes = map[string]uint32{"intact": 1, "corrupted": 2, "unhashed": 3, "lost": 4}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+
+	received := newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "intact", "corrupted", "unhashed")
+	received.ContentHashes = map[string]uint32{"intact": 1, "corrupted": 20}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{received}})
+
+	compared, corrupted := findCorruptions(ag.joinEvents())
+	if compared != 2 {
+		t.Errorf("compared events = %d, want 2", compared)
+	}
+	if len(corrupted) != 1 || corrupted[0] != "corrupted" {
+		t.Fatalf("corrupted events = %v, want [corrupted]", corrupted)
+	}
+
+	n := len(corrupted)
+	q := newFakeAggregatesStore()
+	(&runAggregates{corruptions: &n}).publish(q)
+	if got := q.run["cc"]; got != 1 {
+		t.Errorf("published corruption count = %v, want 1", got)
+	}
+}
+
+func TestChecksumMismatchDetection(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+
+	sent := newRecord(pb.EventsRecord_SENT, now, "intact", "mismatched-1", "mismatched-2", "lost")
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+
+	received := newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "intact", "mismatched-2", "mismatched-1")
+	received.ChecksumMismatches = map[string]bool{"mismatched-1": true, "mismatched-2": true, "unknown": true}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{received}})
+
+	mismatches := findChecksumMismatches(ag.joinEvents())
+	if len(mismatches) != 2 || mismatches[0] != "mismatched-1" || mismatches[1] != "mismatched-2" {
+		t.Fatalf("checksum mismatches = %v, want [mismatched-1 mismatched-2]", mismatches)
+	}
+
+	q := newFakeAggregatesStore()
+	(&runAggregates{checksumMismatches: len(mismatches)}).publish(q)
+	if got := q.run["cm"]; got != 2 {
+		t.Errorf("published corrupted count = %v, want 2", got)
+	}
+}
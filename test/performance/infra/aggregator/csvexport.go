@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns of the CSV export of the events.
+var csvHeader = []string{"id", "sender", "receiver", "sent", "accepted", "received",
+	"publish_latency_ns", "e2e_latency_ns", "publish_failed", "delivery_failed"}
+
+// csvRecord returns the columns of the event. Timestamps and latencies are
+// empty when the event didn't reach the corresponding step.
+func csvRecord(e *event) []string {
+	timestamp := func(t time.Time, ok bool) string {
+		if !ok {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	latency := func(d time.Duration, ok bool) string {
+		if !ok {
+			return ""
+		}
+		return strconv.FormatInt(d.Nanoseconds(), 10)
+	}
+	return []string{
+		e.id,
+		e.sender,
+		e.receiver,
+		timestamp(e.sent, true),
+		timestamp(e.accepted, e.isAccepted),
+		timestamp(e.received, e.isReceived),
+		latency(e.publishLatency(), e.isAccepted),
+		latency(e.e2eLatency(), e.isReceived),
+		strconv.FormatBool(e.outcome() == outcomePublishFailure),
+		strconv.FormatBool(e.outcome() == outcomeDeliveryFailure),
+	}
+}
+
+// csvEventWriter writes one row per event it is given, after a header row.
+// After the first error, it logs it and ignores the following events.
+type csvEventWriter struct {
+	w      *csv.Writer
+	closer io.Closer
+	err    error
+}
+
+func newCSVEventWriter(w io.Writer) *csvEventWriter {
+	cw := &csvEventWriter{w: csv.NewWriter(w)}
+	cw.err = cw.w.Write(csvHeader)
+	return cw
+}
+
+// createCSVEventWriter opens the CSV export at the given path.
+func createCSVEventWriter(path string) (*csvEventWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	w := newCSVEventWriter(f)
+	w.closer = f
+	return w, nil
+}
+
+// write writes the row of the given event, unless a previous write failed.
+func (w *csvEventWriter) write(e *event) {
+	if w.err != nil {
+		return
+	}
+	if w.err = w.w.Write(csvRecord(e)); w.err != nil {
+		log.Printf("ERROR writing the CSV events: %v", w.err)
+	}
+}
+
+// close flushes the buffered rows and closes the file of the export, if any.
+func (w *csvEventWriter) close() error {
+	if w.err == nil {
+		w.w.Flush()
+		w.err = w.w.Error()
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestCSVEventWriter(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	events := []event{
+		{id: "delivered", sender: "s", receiver: "r", sent: sent, accepted: sent.Add(time.Millisecond), received: sent.Add(5 * time.Millisecond), isAccepted: true, isReceived: true},
+		{id: "lost", sender: "s", sent: sent, accepted: sent.Add(2 * time.Millisecond), isAccepted: true},
+		{id: "rejected, quoted", sender: "s", sent: sent},
+	}
+
+	var buf bytes.Buffer
+	w := newCSVEventWriter(&buf)
+	for i := range events {
+		w.write(&events[i])
+	}
+	if err := w.close(); err != nil {
+		t.Fatal("close() =", err)
+	}
+
+	got, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal("The export is not valid CSV:", err)
+	}
+	want := [][]string{
+		csvHeader,
+		{"delivered", "s", "r", "1970-01-01T00:16:40Z", "1970-01-01T00:16:40.001Z", "1970-01-01T00:16:40.005Z", "1000000", "5000000", "false", "false"},
+		{"lost", "s", "", "1970-01-01T00:16:40Z", "1970-01-01T00:16:40.002Z", "", "2000000", "", "false", "true"},
+		{"rejected, quoted", "s", "", "1970-01-01T00:16:40Z", "", "", "", "", "true", "false"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CSV export = %v, want %v", got, want)
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 2, WithRunID("csv"), WithCSVExport(filepath.Join(dir, "events.csv")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a", "b"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "a", "b"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "a"),
+	}})
+	waitForRun(t, done)
+
+	f, err := os.Open(filepath.Join(dir, "events-csv.csv"))
+	if err != nil {
+		t.Fatal("Failed to open the CSV export:", err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal("Failed to read the CSV export:", err)
+	}
+	if len(rows) != 3 {
+		t.Errorf("CSV export has %d rows, want a header and 2 events: %v", len(rows), rows)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// deadlineOutcome counts the events delivered after the deadline.
+type deadlineOutcome struct {
+	// events delivered more than the deadline after being sent
+	misses int
+	// ratio of the sent events delivered within the deadline
+	onTimeRate float64
+}
+
+// classifyDeadline counts the delivered events whose end to end latency
+// exceeds the deadline as misses rather than successes. The events which were
+// not delivered are failures, not misses, but they aren't on time either.
+func classifyDeadline(events []event, deadline time.Duration) deadlineOutcome {
+	var o deadlineOutcome
+	onTime := 0
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived {
+			continue
+		}
+		if e.e2eLatency() > deadline {
+			o.misses++
+		} else {
+			onTime++
+		}
+	}
+	if len(events) > 0 {
+		o.onTimeRate = float64(onTime) / float64(len(events))
+	}
+	return o
+}
+
+// reportDeadline logs the events delivered after the deadline and the on-time rate.
+func reportDeadline(o deadlineOutcome, sent int, deadline time.Duration) {
+	if o.misses > 0 {
+		log.Printf("!! %d events were delivered more than %v after being sent, on-time rate %.4f over %d sent events",
+			o.misses, deadline, o.onTimeRate, sent)
+		return
+	}
+	log.Printf("No event delivered more than %v after being sent, on-time rate %.4f over %d sent events",
+		deadline, o.onTimeRate, sent)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClassifyDeadline(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	delivered := func(latency time.Duration) event {
+		return event{sent: sent, accepted: sent, received: sent.Add(latency), isAccepted: true, isReceived: true}
+	}
+	events := []event{
+		delivered(time.Second),
+		// exactly at the deadline is on time
+		delivered(30 * time.Second),
+		delivered(31 * time.Second),
+		delivered(time.Minute),
+		// lost events are failures, not misses
+		{sent: sent, accepted: sent, isAccepted: true},
+	}
+
+	o := classifyDeadline(events, 30*time.Second)
+	if want := (deadlineOutcome{misses: 2, onTimeRate: 0.4}); o != want {
+		t.Errorf("classifyDeadline() = %+v, want %+v", o, want)
+	}
+
+	a := runAggregates{minSamplesForPercentiles: defaultMinSamplesForPercentiles, deadline: &o}
+	q := newFakeAggregatesStore()
+	a.publish(q)
+	if q.run["dm"] != 2 || q.run["otr"] != 0.4 {
+		t.Errorf("published deadline misses %v and on-time rate %v, want 2 and 0.4", q.run["dm"], q.run["otr"])
+	}
+}
+
+func TestNoDeadlineByDefault(t *testing.T) {
+	a := runAggregates{minSamplesForPercentiles: defaultMinSamplesForPercentiles}
+	q := newFakeAggregatesStore()
+	a.publish(q)
+	if _, ok := q.run["dm"]; ok {
+		t.Error("deadline misses published without deadline")
+	}
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, WithEventDeadline(-time.Second)); err == nil {
+		t.Error("NewAggregator() with a negative event deadline = nil error, want it rejected")
+	}
+}
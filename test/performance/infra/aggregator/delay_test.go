@@ -0,0 +1,55 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRecordDelay(t *testing.T) {
+	const delay = 200 * time.Millisecond
+	ag := newTestAggregator(t, 0, WithRecordDelay(delay))
+
+	start := time.Now()
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, start, "1")}})
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("RecordEvents() replied after %v, want at least %v", elapsed, delay)
+	}
+	if _, recorded := ag.sentEvents.Events["1"]; !recorded {
+		t.Error("the event wasn't recorded after the delay")
+	}
+
+	// a client giving up during the delay
+	ctx, cancel := context.WithTimeout(context.Background(), delay/4)
+	defer cancel()
+	if _, err := ag.RecordEvents(ctx, &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, start, "2")}}); err == nil {
+		t.Error("RecordEvents() with a context done during the delay succeeded")
+	}
+	if _, recorded := ag.sentEvents.Events["2"]; recorded {
+		t.Error("the event abandoned during the delay was recorded")
+	}
+}
+
+func TestNegativeRecordDelayIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithRecordDelay(-time.Second)); err == nil {
+		t.Error("NewAggregator() with a negative record delay succeeded")
+	}
+}
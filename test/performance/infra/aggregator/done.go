@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sync"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// Roles of the sources of records, a same source being possibly both.
+const (
+	roleSender   = "sender"
+	roleReceiver = "receiver"
+)
+
+// doneSources tracks the senders and receivers which reported their final
+// records, closing allDone once as many as the expected records did.
+type doneSources struct {
+	sync.Mutex
+	expected uint
+	done     map[string]struct{}
+	allDone  chan struct{}
+}
+
+func newDoneSources(expected uint) *doneSources {
+	return &doneSources{
+		expected: expected,
+		done:     make(map[string]struct{}),
+		allDone:  make(chan struct{}),
+	}
+}
+
+// markDone records that the source of the given final records is done, in
+// the roles of the records it contains.
+func (d *doneSources) markDone(in *pb.EventsRecordList) {
+	if in.Source == "" {
+		log.Printf("!! Can't tell which source is done from final records without source")
+		return
+	}
+	roles := make(map[string]struct{}, 2)
+	for _, rec := range in.Items {
+		switch rec.GetType() {
+		case pb.EventsRecord_SENT, pb.EventsRecord_ACCEPTED:
+			roles[roleSender] = struct{}{}
+		case pb.EventsRecord_RECEIVED:
+			roles[roleReceiver] = struct{}{}
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	wasDone := uint(len(d.done)) >= d.expected
+	for role := range roles {
+		d.done[in.Source+"/"+role] = struct{}{}
+		log.Printf("The %s %q is done", role, in.Source)
+	}
+	if !wasDone && uint(len(d.done)) >= d.expected {
+		close(d.allDone)
+	}
+}
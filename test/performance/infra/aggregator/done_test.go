@@ -0,0 +1,73 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestCompletesWhenAllSourcesAreDone(t *testing.T) {
+	// a pod both sender and receiver counts as two expected records
+	ag := newTestAggregator(t, 3)
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "sender-a", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1"),
+	}})
+	select {
+	case <-ag.recordsComplete:
+		t.Fatal("completed before all the sources were done")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// uploads the records of both its roles at once, so that only 2 of the 3
+	// expected records are counted
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "pod-b", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "2"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "2"),
+		newRecord(pb.EventsRecord_RECEIVED, now, "1", "2"),
+	}})
+	waitForRun(t, done)
+
+	if n := len(ag.receivedEvents.Events); n != 2 {
+		t.Errorf("recorded %d received events, want 2", n)
+	}
+}
+
+func TestDoneSourcesIgnoreRecordsWithoutSource(t *testing.T) {
+	d := newDoneSources(1)
+	d.markDone(&pb.EventsRecordList{Final: true, Items: []*pb.EventsRecord{{Type: pb.EventsRecord_SENT}}})
+	select {
+	case <-d.allDone:
+		t.Error("all done after final records without source")
+	default:
+	}
+	d.markDone(&pb.EventsRecordList{Source: "sender", Final: true, Items: []*pb.EventsRecord{{Type: pb.EventsRecord_SENT}}})
+	// marking a source twice doesn't close allDone twice
+	d.markDone(&pb.EventsRecordList{Source: "sender", Final: true, Items: []*pb.EventsRecord{{Type: pb.EventsRecord_SENT}}})
+	select {
+	case <-d.allDone:
+	default:
+		t.Error("not all done after the expected source reported its final records")
+	}
+}
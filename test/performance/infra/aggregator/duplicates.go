@@ -0,0 +1,179 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// duplicateCounts are the number of events recorded so far, and how many of them were unique.
+type duplicateCounts struct {
+	attempts uint64
+	unique   uint64
+}
+
+// rate is the ratio of the recorded events which were duplicates.
+func (c duplicateCounts) rate() float64 {
+	if c.attempts == 0 {
+		return 0
+	}
+	return float64(c.attempts-c.unique) / float64(c.attempts)
+}
+
+// duplicateCounts returns a consistent snapshot of the counts of the record.
+func (rec *eventsRecord) duplicateCounts() duplicateCounts {
+	rec.RLock()
+	defer rec.RUnlock()
+	return duplicateCounts{attempts: rec.attempts, unique: uint64(len(rec.Events))}
+}
+
+// DuplicateRates returns the current ratio of the recorded events of each type
+// which were duplicates. It's safe to call while records are being recorded.
+func (ag *Aggregator) DuplicateRates() map[pb.EventsRecord_Type]float64 {
+	rates := make(map[pb.EventsRecord_Type]float64, 3)
+	for _, rec := range []*eventsRecord{ag.sentEvents, ag.acceptedEvents, ag.receivedEvents} {
+		rates[rec.Type] = rec.duplicateCounts().rate()
+	}
+	return rates
+}
+
+// startDuplicateRateReport logs the duplicate rates every interval, in a
+// goroutine spawned by the aggregator, until the returned function is called.
+func (ag *Aggregator) startDuplicateRateReport(interval time.Duration) func() {
+	stop := make(chan struct{})
+	ag.spawn(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rates := ag.DuplicateRates()
+				log.Printf("Duplicate rates so far: sent %.2f%%, accepted %.2f%%, received %.2f%%",
+					rates[pb.EventsRecord_SENT]*100, rates[pb.EventsRecord_ACCEPTED]*100, rates[pb.EventsRecord_RECEIVED]*100)
+			case <-stop:
+				return
+			}
+		}
+	})
+	return func() {
+		close(stop)
+	}
+}
+
+// duplicateTotals are the number of duplicates recorded of each type of event.
+type duplicateTotals struct {
+	sent     uint64
+	accepted uint64
+	received uint64
+}
+
+// reportDuplicates logs the number of duplicates recorded of each type of
+// event, returning them.
+func (ag *Aggregator) reportDuplicates() duplicateTotals {
+	count := func(rec *eventsRecord) uint64 {
+		rec.RLock()
+		defer rec.RUnlock()
+		return rec.duplicated
+	}
+	d := duplicateTotals{sent: count(ag.sentEvents), accepted: count(ag.acceptedEvents), received: count(ag.receivedEvents)}
+	if d.sent+d.accepted+d.received == 0 {
+		log.Printf("No duplicate event")
+	} else {
+		log.Printf("!! Duplicate events: %d sent, %d accepted, %d received", d.sent, d.accepted, d.received)
+	}
+	return d
+}
+
+// trackDeliveries records the duplicate timestamp t of the event with the given
+// ID, along with its recorded one the first time, when recording the
+// timestamps of the duplicates. It must be called with the lock held.
+func (rec *eventsRecord) trackDeliveries(id string, recorded, t *timestamp.Timestamp) {
+	if rec.deliveries == nil {
+		return
+	}
+	if _, ok := rec.deliveries[id]; !ok {
+		rec.deliveries[id] = []*timestamp.Timestamp{recorded}
+	}
+	rec.deliveries[id] = append(rec.deliveries[id], t)
+}
+
+// duplicateDeliveriesHeader names the columns of the export of the deliveries
+// of the events received several times.
+var duplicateDeliveriesHeader = []string{"id", "received", "after_first_ns"}
+
+// exportDuplicateDeliveries writes to the file at the given path, as CSV, a
+// row for each delivery of the events received several times, sorted by ID and
+// time, with its delay after the first delivery of the event.
+func exportDuplicateDeliveries(path string, deliveries map[string][]*timestamp.Timestamp) error {
+	ids := make([]string, 0, len(deliveries))
+	for id := range deliveries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write(duplicateDeliveriesHeader)
+	for _, id := range ids {
+		times := make([]time.Time, 0, len(deliveries[id]))
+		for _, ts := range deliveries[id] {
+			if t, err := ptypes.Timestamp(ts); err == nil {
+				times = append(times, t)
+			}
+		}
+		sort.Slice(times, func(x, y int) bool { return times[x].Before(times[y]) })
+		for _, t := range times {
+			_ = w.Write([]string{id, t.UTC().Format(time.RFC3339Nano), strconv.FormatInt(t.Sub(times[0]).Nanoseconds(), 10)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// collectDuplicates reports the duplicates recorded of each type of event, and
+// exports the deliveries of the events received several times if requested.
+func (ag *Aggregator) collectDuplicates() duplicateTotals {
+	d := ag.reportDuplicates()
+	if ag.duplicatesPath != "" {
+		log.Printf("Writing the duplicate deliveries to %s", ag.duplicatesPath)
+		ag.receivedEvents.RLock()
+		err := exportDuplicateDeliveries(ag.duplicatesPath, ag.receivedEvents.deliveries)
+		ag.receivedEvents.RUnlock()
+		if err != nil {
+			log.Printf("ERROR writing the duplicate deliveries: %v", err)
+		}
+	}
+	return d
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestDuplicateRatesWhileRecording(t *testing.T) {
+	const (
+		recorders = 4
+		lists     = 50
+		perList   = 20
+	)
+	ag := newTestAggregator(t, 0)
+
+	// each event is recorded twice, by two different recorders, so at any
+	// time at most half of the recorded events are duplicates
+	var wg sync.WaitGroup
+	for r := 0; r < recorders; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for l := 0; l < lists; l++ {
+				ids := make([]string, 0, perList)
+				for i := 0; i < perList; i++ {
+					ids = append(ids, fmt.Sprintf("event-%d-%d-%d", r/2, l, i))
+				}
+				rl := &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), ids...)}}
+				if _, err := ag.RecordEvents(context.Background(), rl); err != nil {
+					t.Error("RecordEvents() =", err)
+				}
+			}
+		}(r)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for polling := true; polling; {
+		select {
+		case <-done:
+			polling = false
+		default:
+		}
+		for recType, rate := range ag.DuplicateRates() {
+			if rate < 0 || rate > 0.5 {
+				t.Fatalf("%s duplicate rate = %v, want between 0 and 0.5", recType, rate)
+			}
+		}
+	}
+
+	rates := ag.DuplicateRates()
+	if rate := rates[pb.EventsRecord_SENT]; rate != 0.5 {
+		t.Errorf("final sent duplicate rate = %v, want 0.5", rate)
+	}
+	if rate := rates[pb.EventsRecord_RECEIVED]; rate != 0 {
+		t.Errorf("received duplicate rate without received events = %v, want 0", rate)
+	}
+}
+
+func TestNegativeDuplicateRateIntervalIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithDuplicateRateReport(-time.Second)); err == nil {
+		t.Error("NewAggregator() with a negative duplicate rate interval succeeded")
+	}
+}
+
+func TestDuplicatesAreCountedAndExported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "duplicates.csv")
+	ag := newTestAggregator(t, 0, WithDuplicateDeliveriesExport(path))
+
+	now := time.Unix(1000, 0)
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a", "b"),
+		newRecord(pb.EventsRecord_SENT, now, "a"),
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(3*time.Millisecond), "a", "b"),
+	}})
+	// redelivered out of order
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "a"),
+	}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(7*time.Millisecond), "a"),
+	}})
+
+	d := ag.collectDuplicates()
+	if want := (duplicateTotals{sent: 1, received: 2}); d != want {
+		t.Errorf("collectDuplicates() = %+v, want %+v", d, want)
+	}
+
+	// the run ID is added to the path of the export
+	content, err := ioutil.ReadFile(ag.duplicatesPath)
+	if err != nil {
+		t.Fatal("Failed to read the duplicate deliveries:", err)
+	}
+	want := strings.Join([]string{
+		"id,received,after_first_ns",
+		"a,1970-01-01T00:16:40.001Z,0",
+		"a,1970-01-01T00:16:40.003Z,2000000",
+		"a,1970-01-01T00:16:40.007Z,6000000",
+	}, "\n") + "\n"
+	if string(content) != want {
+		t.Errorf("duplicate deliveries =\n%s\nwant\n%s", content, want)
+	}
+
+	q := newFakeAggregatesStore()
+	(&runAggregates{duplicates: d}).publish(q)
+	if q.run["dups"] != 1 || q.run["dupa"] != 0 || q.run["dup"] != 2 {
+		t.Errorf("published duplicates = %v sent, %v accepted, %v received, want 1, 0 and 2", q.run["dups"], q.run["dupa"], q.run["dup"])
+	}
+}
+
+func TestDuplicateDeliveriesAreOnlyKeptWhenExported(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Duration(i)*time.Millisecond), "a"),
+		}})
+	}
+	if ag.receivedEvents.deliveries != nil {
+		t.Errorf("deliveries kept without export: %v", ag.receivedEvents.deliveries)
+	}
+	if d := ag.reportDuplicates(); d.received != 1 {
+		t.Errorf("%d received duplicates, want 1", d.received)
+	}
+}
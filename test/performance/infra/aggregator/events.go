@@ -0,0 +1,222 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// compositeID returns the ID used to match the events identified by the given composite key.
+func compositeID(k *pb.EventKey) string {
+	return strconv.Quote(k.GetPartition()) + "/" + strconv.FormatUint(k.GetSequence(), 10)
+}
+
+// event is the result of joining the sent, accepted and received records of a single event.
+type event struct {
+	id       string
+	sender   string
+	receiver string
+	// composite key of the event, or its partition key and sequence number
+	// when numbered by its sender, nil otherwise
+	key *pb.EventKey
+
+	sent     time.Time
+	accepted time.Time
+	received time.Time
+
+	isAccepted bool
+	isReceived bool
+
+	// time the event actually went on the wire, after waiting in its sender
+	// since it was sent, when reported
+	onWire    time.Time
+	hasOnWire bool
+
+	// size in bytes of the payload of the event, when its sender varies them,
+	// 0 otherwise
+	payloadSize uint32
+
+	// times of the other deliveries of the event, when keeping all its received
+	// timestamps
+	redelivered []time.Time
+	// spread between the earliest and the latest deliveries of the event, nil
+	// unless it was delivered several times
+	redeliverySpread *time.Duration
+
+	// readings of the monotonic clock, when the event was sent and received
+	// with the same one
+	sentMonotonic     int64
+	receivedMonotonic int64
+	hasMonotonic      bool
+
+	// whether the hashes of the content of the event as sent and as received
+	// were compared, and differ
+	hashesCompared bool
+	corrupted      bool
+
+	// whether the receiver reported that the content of the event didn't match
+	// the checksum computed by its sender
+	checksumMismatch bool
+
+	// publish attempt of the accepted event and delivery attempt of the
+	// received event, counting from 1, 0 if unknown
+	publishAttempt  uint32
+	deliveryAttempt uint32
+}
+
+// Outcomes of an event.
+const (
+	outcomeDelivered       = "delivered"
+	outcomePublishFailure  = "publish-failure"
+	outcomeDeliveryFailure = "delivery-failure"
+)
+
+// outcome tells whether the event was delivered, or at which step it failed.
+func (e *event) outcome() string {
+	switch {
+	case !e.isAccepted:
+		return outcomePublishFailure
+	case !e.isReceived:
+		return outcomeDeliveryFailure
+	default:
+		return outcomeDelivered
+	}
+}
+
+// publishLatency is the time between the event being sent and being accepted.
+func (e *event) publishLatency() time.Duration {
+	return e.accepted.Sub(e.sent)
+}
+
+// e2eLatency is the time between the event being sent and being received.
+func (e *event) e2eLatency() time.Duration {
+	return e.received.Sub(e.sent)
+}
+
+// deliveryLatency is the time between the event being accepted and being received,
+// i.e. the time the event spent queued in the system under test before being delivered.
+func (e *event) deliveryLatency() time.Duration {
+	return e.received.Sub(e.accepted)
+}
+
+// publishLatencies returns the sorted publish latencies of the accepted events.
+func publishLatencies(events []event) []time.Duration {
+	latencies := make([]time.Duration, 0, len(events))
+	for i := range events {
+		if e := &events[i]; e.isAccepted {
+			latencies = append(latencies, e.publishLatency())
+		}
+	}
+	sortDurations(latencies)
+	return latencies
+}
+
+// e2eLatencies returns the sorted end to end latencies of the received events.
+func e2eLatencies(events []event) []time.Duration {
+	latencies := make([]time.Duration, 0, len(events))
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived {
+			continue
+		}
+		latencies = append(latencies, e.e2eLatency())
+		for _, t := range e.redelivered {
+			latencies = append(latencies, t.Sub(e.sent))
+		}
+	}
+	sortDurations(latencies)
+	return latencies
+}
+
+// queueingDelays returns the sorted delivery latencies of the events which were
+// both accepted and received.
+func queueingDelays(events []event) []time.Duration {
+	delays := make([]time.Duration, 0, len(events))
+	for i := range events {
+		if e := &events[i]; e.isAccepted && e.isReceived {
+			delays = append(delays, e.deliveryLatency())
+		}
+	}
+	sortDurations(delays)
+	return delays
+}
+
+// joinEvents joins the sent events with the matching accepted and received events.
+// Accepted and received events without a matching sent event are ignored.
+func (ag *Aggregator) joinEvents() []event {
+	events := make([]event, 0, len(ag.sentEvents.Events))
+
+	for sentID, timestampSentProto := range ag.sentEvents.Events {
+		e := event{
+			id:     sentID,
+			sender: ag.sentEvents.sources[sentID],
+			key:    ag.sentEvents.keys[sentID],
+
+			payloadSize: ag.sentEvents.PayloadSizes[sentID],
+		}
+		e.sent, _ = ptypes.Timestamp(timestampSentProto)
+		if ts, ok := ag.sentEvents.OnWire[sentID]; ok {
+			if t, err := ptypes.Timestamp(ts); err == nil {
+				e.onWire, e.hasOnWire = t, true
+			}
+		}
+
+		if timestampAcceptedProto, accepted := ag.acceptedEvents.Events[sentID]; accepted {
+			e.accepted, _ = ptypes.Timestamp(timestampAcceptedProto)
+			e.isAccepted = true
+			e.publishAttempt = ag.acceptedEvents.Attempts[sentID]
+		}
+
+		if timestampReceivedProto, received := ag.receivedEvents.Events[sentID]; received {
+			e.received, _ = ptypes.Timestamp(timestampReceivedProto)
+			e.receiver = ag.receivedEvents.sources[sentID]
+			e.isReceived = true
+			if s, ok := ag.receivedEvents.spreads[sentID]; ok {
+				spread := s.latest.Sub(s.earliest)
+				e.redeliverySpread = &spread
+			}
+			for _, ts := range ag.receivedEvents.duplicates[sentID] {
+				if t, err := ptypes.Timestamp(ts); err == nil {
+					e.redelivered = append(e.redelivered, t)
+				}
+			}
+
+			if sentHash, ok := ag.sentEvents.ContentHashes[sentID]; ok {
+				if receivedHash, ok := ag.receivedEvents.ContentHashes[sentID]; ok {
+					e.hashesCompared = true
+					e.corrupted = sentHash != receivedHash
+				}
+			}
+			e.checksumMismatch = ag.receivedEvents.ChecksumMismatches[sentID]
+			e.deliveryAttempt = ag.receivedEvents.Attempts[sentID]
+			if clock := ag.sentEvents.clocks[sentID]; clock != "" && clock == ag.receivedEvents.clocks[sentID] {
+				e.sentMonotonic = ag.sentEvents.Monotonic[sentID]
+				e.receivedMonotonic = ag.receivedEvents.Monotonic[sentID]
+				e.hasMonotonic = true
+			}
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestQueueingDelays(t *testing.T) {
+	start := time.Now()
+
+	// inject gaps of 1ms..100ms between accepted and received
+	var events []event
+	for i := 1; i <= 100; i++ {
+		accepted := start.Add(time.Duration(i) * time.Millisecond)
+		events = append(events, event{
+			id:         strconv.Itoa(i),
+			sent:       start,
+			accepted:   accepted,
+			received:   accepted.Add(time.Duration(i) * time.Millisecond),
+			isAccepted: true,
+			isReceived: true,
+		})
+	}
+	// events not accepted or not received have no queueing delay
+	events = append(events,
+		event{id: "lost", sent: start, accepted: start, isAccepted: true},
+		event{id: "rejected", sent: start},
+	)
+
+	delays := queueingDelays(events)
+	if len(delays) != 100 {
+		t.Fatalf("len(queueingDelays()) = %d, want 100", len(delays))
+	}
+	for i, d := range delays {
+		if want := time.Duration(i+1) * time.Millisecond; d != want {
+			t.Errorf("queueingDelays()[%d] = %v, want %v", i, d, want)
+		}
+	}
+
+	aggregates := reportDistribution("Queueing delay", "q", delays)
+	for k, want := range map[string]time.Duration{
+		"q50": 50 * time.Millisecond,
+		"q90": 90 * time.Millisecond,
+		"q99": 99 * time.Millisecond,
+	} {
+		if got := aggregates[k]; got != want {
+			t.Errorf("aggregate %s = %v, want %v", k, got, want)
+		}
+	}
+
+	h := newHistogram(latencyBuckets)
+	for _, d := range delays {
+		h.add(d)
+	}
+	// buckets: <=1ms, <=2ms, <=5ms, <=10ms, <=20ms, <=50ms, <=100ms, then empty ones
+	wantCounts := []uint64{1, 1, 3, 5, 10, 30, 50, 0, 0, 0, 0, 0, 0, 0}
+	for i, want := range wantCounts {
+		if h.counts[i] != want {
+			t.Errorf("histogram bucket %s = %d, want %d", h.bucketLabel(i), h.counts[i], want)
+		}
+	}
+}
+
+func TestCompositeKeyMatching(t *testing.T) {
+	ag := newTestAggregator(t, 0, WithCompositeKeyMatching(true))
+	start := time.Now()
+
+	// both partitions use the same sequence numbers, with different latencies
+	partitionLatencies := map[string]time.Duration{"p0": 10 * time.Millisecond, "p1": 20 * time.Millisecond}
+	keyedRecord := func(recType pb.EventsRecord_Type, at func(partition string, seq uint64) time.Time) *pb.EventsRecord {
+		rec := &pb.EventsRecord{Type: recType}
+		for partition := range partitionLatencies {
+			for seq := uint64(1); seq <= 3; seq++ {
+				ts, _ := ptypes.TimestampProto(at(partition, seq))
+				rec.KeyedEvents = append(rec.KeyedEvents, &pb.KeyedEvent{
+					Key: &pb.EventKey{Partition: partition, Sequence: seq},
+					At:  ts,
+				})
+			}
+		}
+		return rec
+	}
+	sentAt := func(_ string, seq uint64) time.Time {
+		return start.Add(time.Duration(seq) * time.Second)
+	}
+
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		keyedRecord(pb.EventsRecord_SENT, sentAt),
+		keyedRecord(pb.EventsRecord_ACCEPTED, func(partition string, seq uint64) time.Time {
+			return sentAt(partition, seq).Add(time.Millisecond)
+		}),
+	}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		keyedRecord(pb.EventsRecord_RECEIVED, func(partition string, seq uint64) time.Time {
+			return sentAt(partition, seq).Add(partitionLatencies[partition])
+		}),
+	}})
+
+	// keyed events without key are ignored
+	ts, _ := ptypes.TimestampProto(start)
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{{
+		Type:        pb.EventsRecord_SENT,
+		KeyedEvents: []*pb.KeyedEvent{{At: ts}, {At: ts}},
+	}}})
+
+	events := ag.joinEvents()
+	if len(events) != 6 {
+		t.Fatalf("len(joinEvents()) = %d, want 6", len(events))
+	}
+	for _, e := range events {
+		if e.key == nil || compositeID(e.key) != e.id {
+			t.Errorf("event %s: key = %v, want the key it was recorded with", e.id, e.key)
+		}
+		if !e.isAccepted || !e.isReceived {
+			t.Errorf("event %s: accepted = %t, received = %t, want both", e.id, e.isAccepted, e.isReceived)
+			continue
+		}
+		if got := e.publishLatency(); got != time.Millisecond {
+			t.Errorf("event %s: publish latency = %v, want 1ms", e.id, got)
+		}
+		partition := "p0"
+		if strings.HasPrefix(e.id, `"p1"`) {
+			partition = "p1"
+		}
+		if got, want := e.e2eLatency(), partitionLatencies[partition]; got != want {
+			t.Errorf("event %s: e2e latency = %v, want %v", e.id, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"time"
+
+	"knative.dev/eventing/test/performance/infra/common"
+)
+
+// exclusionWindows returns the warmup and cooldown windows of the given
+// durations at the beginning and the end of the run, from the first to the
+// last event sent, both included. A zero duration has no window.
+func exclusionWindows(events []event, warmup, cooldown time.Duration) []markedWindow {
+	if len(events) == 0 || (warmup <= 0 && cooldown <= 0) {
+		return nil
+	}
+	first, last := events[0].sent, events[0].sent
+	for i := range events {
+		if events[i].sent.Before(first) {
+			first = events[i].sent
+		}
+		if events[i].sent.After(last) {
+			last = events[i].sent
+		}
+	}
+	// the windows exclude their stop
+	end := last.Add(time.Nanosecond)
+
+	var windows []markedWindow
+	if warmup > 0 {
+		stop := first.Add(warmup)
+		if stop.After(end) {
+			stop = end
+		}
+		windows = append(windows, markedWindow{kind: common.WarmupWindowKind, start: first, stop: stop})
+	}
+	if cooldown > 0 {
+		start := end.Add(-cooldown)
+		if start.Before(first) {
+			start = first
+		}
+		windows = append(windows, markedWindow{kind: common.CooldownWindowKind, start: start, stop: end})
+	}
+	return windows
+}
+
+// excludedFromThroughputs returns the IDs of the events sent during the warmup
+// and cooldown windows, which are excluded from the throughputs as well.
+func excludedFromThroughputs(during map[string][]event) map[string]struct{} {
+	var excluded map[string]struct{}
+	for _, kind := range []string{common.WarmupWindowKind, common.CooldownWindowKind} {
+		for i := range during[kind] {
+			if excluded == nil {
+				excluded = make(map[string]struct{})
+			}
+			excluded[during[kind][i].id] = struct{}{}
+		}
+	}
+	return excluded
+}
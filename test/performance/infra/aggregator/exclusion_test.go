@@ -0,0 +1,115 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"knative.dev/eventing/test/performance/infra/common"
+)
+
+func TestExclusionWindows(t *testing.T) {
+	start := time.Unix(1000, 0)
+	// one event sent every second for 10s
+	events := make([]event, 11)
+	for i := range events {
+		events[i] = event{id: fmt.Sprint(i), sent: start.Add(time.Duration(i) * time.Second)}
+	}
+
+	windows := exclusionWindows(events, 2*time.Second, 3*time.Second)
+	outside, during := splitByWindows(events, windows)
+	ids := func(events []event) string {
+		var ids []string
+		for _, e := range events {
+			ids = append(ids, e.id)
+		}
+		return fmt.Sprint(ids)
+	}
+	if got, want := ids(outside), "[2 3 4 5 6 7]"; got != want {
+		t.Errorf("events outside the windows = %s, want %s", got, want)
+	}
+	if got, want := ids(during[common.WarmupWindowKind]), "[0 1]"; got != want {
+		t.Errorf("events during the warmup = %s, want %s", got, want)
+	}
+	if got, want := ids(during[common.CooldownWindowKind]), "[8 9 10]"; got != want {
+		t.Errorf("events during the cooldown = %s, want %s", got, want)
+	}
+
+	excluded := excludedFromThroughputs(during)
+	if len(excluded) != 5 {
+		t.Errorf("%d events excluded from the throughputs, want 5", len(excluded))
+	}
+}
+
+func TestExclusionWindowsLongerThanRun(t *testing.T) {
+	start := time.Unix(1000, 0)
+	events := []event{{id: "1", sent: start}, {id: "2", sent: start.Add(time.Second)}}
+
+	windows := exclusionWindows(events, time.Minute, time.Minute)
+	for _, w := range windows {
+		if w.start != start || w.stop != start.Add(time.Second+time.Nanosecond) {
+			t.Errorf("%s window = %v to %v, want the whole run", w.kind, w.start, w.stop)
+		}
+	}
+	if got := exclusionWindows(events, 0, 0); got != nil {
+		t.Errorf("exclusionWindows() without durations = %v, want none", got)
+	}
+	if got := exclusionWindows(nil, time.Minute, 0); got != nil {
+		t.Errorf("exclusionWindows() without events = %v, want none", got)
+	}
+}
+
+func TestMaintenanceWindowsAreNotExcludedFromThroughputs(t *testing.T) {
+	during := map[string][]event{"maintenance": {{id: "m"}}, common.WarmupWindowKind: {{id: "w"}}}
+	if got, want := excludedFromThroughputs(during), map[string]struct{}{"w": {}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("excludedFromThroughputs() = %v, want %v", got, want)
+	}
+}
+
+func TestEventsToTimestampsArrayExcludes(t *testing.T) {
+	start := time.Unix(1000, 0)
+	events := make(map[string]*timestamp.Timestamp)
+	for i := 0; i < 3; i++ {
+		events[fmt.Sprint(i)], _ = ptypes.TimestampProto(start.Add(time.Duration(i) * time.Second))
+	}
+
+	var got []int64
+	for _, ts := range eventsToTimestampsArray(&events, map[string]struct{}{"1": {}}) {
+		got = append(got, ts.Unix())
+	}
+	sort.Slice(got, func(x, y int) bool { return got[x] < got[y] })
+	if want := []int64{1000, 1002}; !reflect.DeepEqual(got, want) {
+		t.Errorf("eventsToTimestampsArray() = %v, want %v", got, want)
+	}
+}
+
+func TestExclusionWindowsAreRejectedInStreamingAggregation(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithStreamingAggregation(true),
+		WithExclusionWindows(time.Second, 0)); err == nil {
+		t.Error("NewAggregator() with exclusion windows in streaming aggregation succeeded")
+	}
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithExclusionWindows(-time.Second, 0)); err == nil {
+		t.Error("NewAggregator() with a negative warmup exclusion succeeded")
+	}
+}
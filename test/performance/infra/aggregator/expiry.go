@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// markInserted records when the event with the given ID was first recorded,
+// if the record tracks it. It must be called with the lock held.
+func (rec *eventsRecord) markInserted(id string, at time.Time) {
+	if rec.inserted == nil {
+		return
+	}
+	if _, ok := rec.inserted[id]; !ok {
+		rec.inserted[id] = at
+	}
+}
+
+// remove forgets the event with the given ID. It must be called with the lock held.
+func (rec *eventsRecord) remove(id string) {
+	delete(rec.Events, id)
+	delete(rec.Monotonic, id)
+	delete(rec.ContentHashes, id)
+	delete(rec.ChecksumMismatches, id)
+	delete(rec.Attempts, id)
+	delete(rec.OnWire, id)
+	delete(rec.PayloadSizes, id)
+	delete(rec.sources, id)
+	delete(rec.keys, id)
+	delete(rec.clocks, id)
+	delete(rec.duplicates, id)
+	delete(rec.spreads, id)
+	delete(rec.inserted, id)
+}
+
+// expireUnmatched forgets the accepted and received events recorded for longer
+// than the unmatched TTL without a sent event, e.g. because their sender
+// crashed before uploading its records, counting them as abandoned. It returns
+// the number of events expired.
+func (ag *Aggregator) expireUnmatched() int {
+	now := ag.now()
+	ag.sentEvents.RLock()
+	defer ag.sentEvents.RUnlock()
+
+	expired := 0
+	for _, rec := range []*eventsRecord{ag.acceptedEvents, ag.receivedEvents} {
+		rec.Lock()
+		for id, at := range rec.inserted {
+			if _, sent := ag.sentEvents.Events[id]; sent {
+				// matched, no need to track it anymore
+				delete(rec.inserted, id)
+				continue
+			}
+			if now.Sub(at) >= ag.unmatchedTTL {
+				rec.remove(id)
+				expired++
+			}
+		}
+		rec.Unlock()
+	}
+	atomic.AddUint64(&ag.abandoned, uint64(expired))
+	return expired
+}
+
+// expireUnmatchedPeriodically expires the unmatched events every half TTL, and
+// folds the expired sent events in streaming aggregation, until all the
+// expected records are received.
+func (ag *Aggregator) expireUnmatchedPeriodically() {
+	ticker := time.NewTicker(ag.unmatchedTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if expired := ag.expireUnmatched(); expired > 0 {
+				log.Printf("!! Abandoned %d accepted or received events without sent event after %v", expired, ag.unmatchedTTL)
+			}
+			if ag.streaming != nil {
+				if folded := ag.foldExpired(); folded > 0 {
+					log.Printf("!! Folded %d sent events not accepted or received after %v as failed", folded, ag.unmatchedTTL)
+				}
+			}
+		case <-ag.recordsComplete:
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestUnmatchedEventsExpire(t *testing.T) {
+	ag := newTestAggregator(t, 0, WithUnmatchedTTL(time.Minute))
+	clock := time.Now()
+	ag.now = func() time.Time { return clock }
+
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, clock, "orphan", "matched"),
+	}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, clock, "matched"),
+	}})
+
+	clock = clock.Add(30 * time.Second)
+	if expired := ag.expireUnmatched(); expired != 0 {
+		t.Errorf("expired %d events before the TTL, want 0", expired)
+	}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_ACCEPTED, clock, "late-orphan"),
+	}})
+
+	clock = clock.Add(31 * time.Second)
+	if expired := ag.expireUnmatched(); expired != 1 {
+		t.Errorf("expired %d events past the TTL, want 1", expired)
+	}
+	if _, ok := ag.receivedEvents.Events["orphan"]; ok {
+		t.Error("the unmatched received event wasn't expired")
+	}
+	if _, ok := ag.receivedEvents.Events["matched"]; !ok {
+		t.Error("the matched received event was expired")
+	}
+	if _, ok := ag.acceptedEvents.Events["late-orphan"]; !ok {
+		t.Error("the unmatched accepted event was expired before its TTL")
+	}
+	if ag.abandoned != 1 {
+		t.Errorf("abandoned count = %d, want 1", ag.abandoned)
+	}
+
+	n := ag.abandoned
+	q := newFakeAggregatesStore()
+	(&runAggregates{abandoned: &n}).publish(q)
+	if got := q.run["ab"]; got != 1 {
+		t.Errorf("published abandoned count = %v, want 1", got)
+	}
+}
+
+func TestUnmatchedEventsDontExpireByDefault(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, time.Now(), "orphan"),
+	}})
+	if ag.receivedEvents.inserted != nil {
+		t.Error("tracking the arrival of the events without unmatched TTL")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tpb "github.com/google/mako/clients/proto/analyzers/threshold_analyzer_go_proto"
+)
+
+// Kinds of exporters the results of the run are published with.
+const (
+	// to the Mako sidecar, checked by the threshold analyzers
+	MakoExporter = "mako"
+	// as InfluxDB line protocol, to a file or posted to a URL
+	InfluxExporter = "influxdb"
+	// as CSV files in a directory
+	FileExporter = "file"
+)
+
+// Exporter publishes the results of the run: the sample points, the errors
+// and the aggregates, stored once flushed. The x values are times in
+// milliseconds since the Unix epoch, as in Mako.
+type Exporter interface {
+	// Name identifies the exporter in the logs.
+	Name() string
+	// AddSample adds the values of the given keys at a point of the run, e.g.
+	// the latency of an event at the time it was sent.
+	AddSample(xval float64, values map[string]float64) error
+	// AddError adds an error at a point of the run.
+	AddError(xval float64, message string) error
+	// AddAggregate adds an aggregate of the run or, if aggregateType is set,
+	// an aggregate of the metric of the value key, e.g. its max.
+	AddAggregate(valueKey string, aggregateType string, value float64) error
+	// AddTags adds tags to the run.
+	AddTags(tags ...string)
+	// Flush stores the results added, failing if they don't pass the checks
+	// of the exporter, if any.
+	Flush(ctx context.Context) error
+	// Close releases the resources of the exporter.
+	Close(ctx context.Context)
+}
+
+// exporterStore publishes the results to an exporter.
+type exporterStore struct {
+	Exporter
+}
+
+func (s exporterStore) AddSamplePoint(xval float64, valueKeyToYVals map[string]float64) error {
+	return s.AddSample(xval, valueKeyToYVals)
+}
+
+func (s exporterStore) AddRunAggregate(valueKey string, value float64) error {
+	return s.AddAggregate(valueKey, "", value)
+}
+
+func (s exporterStore) AddMetricAggregate(valueKey string, aggregateType string, value float64) error {
+	return s.AddAggregate(valueKey, aggregateType, value)
+}
+
+// newExporter returns the exporter of the results of the run, of the
+// configured kind.
+func (ag *Aggregator) newExporter(ctx context.Context) (Exporter, error) {
+	switch ag.exporter {
+	case MakoExporter:
+		makoCtx, cancel := context.WithTimeout(ctx, time.Minute*10)
+		defer cancel()
+		analyzers := []*tpb.ThresholdAnalyzerInput{pea, dea}
+		if ag.scenarioSuffixKeys {
+			// watch the value keys of the scenario
+			analyzers = []*tpb.ThresholdAnalyzerInput{scenarioAnalyzer(pea, ag.scenario), scenarioAnalyzer(dea, ag.scenario)}
+		}
+		return newMakoExporter(makoCtx, ag.makoTags, analyzers)
+	case InfluxExporter:
+		return newInfluxExporter(ag.exporterOutput, ag.makoTags), nil
+	case FileExporter:
+		return newFileExporter(ag.exporterOutput, ag.makoTags), nil
+	}
+	return nil, fmt.Errorf("unknown exporter %q", ag.exporter)
+}
+
+// publishedResults are the results added to an exporter which renders them
+// once flushed, with all the tags of the run.
+type publishedResults struct {
+	tags       []string
+	samples    []publishedSample
+	errors     []publishedError
+	aggregates []publishedAggregate
+}
+
+type publishedSample struct {
+	xval   float64
+	values map[string]float64
+}
+
+type publishedError struct {
+	xval    float64
+	message string
+}
+
+type publishedAggregate struct {
+	valueKey      string
+	aggregateType string
+	value         float64
+}
+
+func (r *publishedResults) AddSample(xval float64, values map[string]float64) error {
+	copied := make(map[string]float64, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	r.samples = append(r.samples, publishedSample{xval: xval, values: copied})
+	return nil
+}
+
+func (r *publishedResults) AddError(xval float64, message string) error {
+	r.errors = append(r.errors, publishedError{xval: xval, message: message})
+	return nil
+}
+
+func (r *publishedResults) AddAggregate(valueKey string, aggregateType string, value float64) error {
+	r.aggregates = append(r.aggregates, publishedAggregate{valueKey: valueKey, aggregateType: aggregateType, value: value})
+	return nil
+}
+
+func (r *publishedResults) AddTags(tags ...string) {
+	r.tags = append(r.tags, tags...)
+}
+
+func (r *publishedResults) Close(context.Context) {}
+
+// xTime returns the time of an x value, in milliseconds since the Unix epoch.
+func xTime(xval float64) time.Time {
+	return time.Unix(0, int64(xval*float64(time.Millisecond)))
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// failedEventsLog is the failed events export path logging them instead.
+const failedEventsLog = "-"
+
+// failedEventsHeader names the columns of the export of the failed events.
+var failedEventsHeader = []string{"id", "sender", "outcome", "sent", "accepted"}
+
+// failedEvents returns the events which were not accepted or not received,
+// sorted by sent time and ID.
+func failedEvents(events []event) []*event {
+	var failed []*event
+	for i := range events {
+		if e := &events[i]; !e.isAccepted || !e.isReceived {
+			failed = append(failed, e)
+		}
+	}
+	sort.Slice(failed, func(x, y int) bool {
+		if !failed[x].sent.Equal(failed[y].sent) {
+			return failed[x].sent.Before(failed[y].sent)
+		}
+		return failed[x].id < failed[y].id
+	})
+	return failed
+}
+
+// writeFailedEvents writes the failed events as CSV, one row per event with
+// its ID, sender, outcome and sent and accepted times, after a header row.
+func writeFailedEvents(w io.Writer, failed []*event) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(failedEventsHeader)
+	for _, e := range failed {
+		accepted := ""
+		if e.isAccepted {
+			accepted = e.accepted.UTC().Format(time.RFC3339Nano)
+		}
+		_ = cw.Write([]string{e.id, e.sender, e.outcome(), e.sent.UTC().Format(time.RFC3339Nano), accepted})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportFailedEvents writes the events which were not accepted or not received
+// to the file at the given path, or logs them for failedEventsLog, so that the
+// drops can be correlated with the logs of the data plane.
+func exportFailedEvents(path string, events []event) error {
+	failed := failedEvents(events)
+	if path == failedEventsLog {
+		var buf bytes.Buffer
+		if err := writeFailedEvents(&buf, failed); err != nil {
+			return err
+		}
+		log.Printf("%d failed events:", len(failed))
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			log.Printf("  %s", line)
+		}
+		return nil
+	}
+
+	log.Printf("Writing the %d failed events to %s", len(failed), path)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := writeFailedEvents(f, failed); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// failedTestEvents returns a delivered event and two failed ones, sent in
+// the reverse order of their IDs.
+func failedTestEvents() []event {
+	start := time.Unix(1000, 0)
+	return []event{
+		{id: "a", sender: "s", sent: start.Add(2 * time.Millisecond), accepted: start.Add(3 * time.Millisecond),
+			received: start.Add(4 * time.Millisecond), isAccepted: true, isReceived: true},
+		{id: "b", sender: "s", sent: start.Add(time.Millisecond), accepted: start.Add(3 * time.Millisecond), isAccepted: true},
+		{id: "c", sender: "s", sent: start},
+	}
+}
+
+func TestExportFailedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.csv")
+	if err := exportFailedEvents(path, failedTestEvents()); err != nil {
+		t.Fatal("exportFailedEvents() =", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Failed to read the failed events:", err)
+	}
+	want := strings.Join([]string{
+		"id,sender,outcome,sent,accepted",
+		"c,s,publish-failure,1970-01-01T00:16:40Z,",
+		"b,s,delivery-failure,1970-01-01T00:16:40.001Z,1970-01-01T00:16:40.003Z",
+	}, "\n") + "\n"
+	if string(content) != want {
+		t.Errorf("failed events =\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestLogFailedEvents(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	if err := exportFailedEvents(failedEventsLog, failedTestEvents()); err != nil {
+		t.Fatal("exportFailedEvents() =", err)
+	}
+	for _, want := range []string{"2 failed events:", "  c,s,publish-failure,", "  b,s,delivery-failure,"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestFailedEventsExportedByRun(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		ag := newTestAggregator(t, 1, WithStreamingAggregation(streaming),
+			WithFailedEventsExport(filepath.Join(t.TempDir(), "failed.csv")))
+		done := runAggregator(context.Background(), ag)
+		now := time.Now()
+		publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_SENT, now, "delivered", "lost"),
+			newRecord(pb.EventsRecord_ACCEPTED, now.Add(time.Millisecond), "delivered", "lost"),
+			newRecord(pb.EventsRecord_RECEIVED, now.Add(2*time.Millisecond), "delivered"),
+		}})
+		waitForRun(t, done)
+
+		// the run ID is added to the path of the export
+		content, err := ioutil.ReadFile(ag.failedEventsPath)
+		if err != nil {
+			t.Fatalf("streaming %v: failed to read the failed events: %v", streaming, err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[1], "lost,") {
+			t.Errorf("streaming %v: failed events = %q, want the lost event only", streaming, lines)
+		}
+	}
+}
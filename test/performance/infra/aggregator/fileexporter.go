@@ -0,0 +1,124 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files the file exporter writes in its directory.
+const (
+	exportedSamplesFile    = "samples.csv"
+	exportedErrorsFile     = "errors.csv"
+	exportedAggregatesFile = "aggregates.csv"
+	exportedTagsFile       = "tags.txt"
+)
+
+// fileExporter writes the results as CSV files in a directory, with a header
+// row each, and the tags of the run one per line.
+type fileExporter struct {
+	publishedResults
+	dir string
+}
+
+var _ Exporter = (*fileExporter)(nil)
+
+// newFileExporter returns an exporter writing the results in the given
+// directory, created if needed.
+func newFileExporter(dir string, tags []string) *fileExporter {
+	e := &fileExporter{dir: dir}
+	e.AddTags(tags...)
+	return e
+}
+
+func (e *fileExporter) Name() string {
+	return "files in " + e.dir
+}
+
+func (e *fileExporter) Flush(context.Context) error {
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create the directory %s: %v", e.dir, err)
+	}
+
+	// a row per value of each sample point, in the order they were added
+	samples := [][]string{{"timestamp", "key", "value"}}
+	for _, s := range e.samples {
+		keys := make([]string, 0, len(s.values))
+		for k := range s.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			samples = append(samples, []string{exportedTime(s.xval), k, strconv.FormatFloat(s.values[k], 'g', -1, 64)})
+		}
+	}
+	errorRows := [][]string{{"timestamp", "message"}}
+	for _, r := range e.errors {
+		errorRows = append(errorRows, []string{exportedTime(r.xval), r.message})
+	}
+	aggregates := [][]string{{"key", "type", "value"}}
+	for _, a := range e.aggregates {
+		aggregates = append(aggregates, []string{a.valueKey, a.aggregateType, strconv.FormatFloat(a.value, 'g', -1, 64)})
+	}
+
+	for name, rows := range map[string][][]string{
+		exportedSamplesFile:    samples,
+		exportedErrorsFile:     errorRows,
+		exportedAggregatesFile: aggregates,
+	} {
+		if err := writeCSVFile(filepath.Join(e.dir, name), rows); err != nil {
+			return err
+		}
+	}
+	var tags strings.Builder
+	for _, t := range e.tags {
+		tags.WriteString(t + "\n")
+	}
+	path := filepath.Join(e.dir, exportedTagsFile)
+	if err := ioutil.WriteFile(path, []byte(tags.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// exportedTime formats the time of an x value in RFC 3339, in UTC.
+func exportedTime(xval float64) string {
+	return xTime(xval).UTC().Format(time.RFC3339Nano)
+}
+
+// writeCSVFile writes the rows in the CSV file at the given path.
+func writeCSVFile(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
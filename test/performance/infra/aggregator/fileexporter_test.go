@@ -0,0 +1,104 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestFileExporterWritesCSVFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "results")
+	e := newFileExporter(dir, []string{"run-id=1"})
+	addTestResults(t, e)
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal("Flush() =", err)
+	}
+
+	for name, want := range map[string]string{
+		exportedSamplesFile: "timestamp,key,value\n" +
+			"2020-09-13T12:26:40.5Z,dl,1.5\n" +
+			"2020-09-13T12:26:40.5Z,pl,0.25\n",
+		exportedErrorsFile:     "timestamp,message\n2020-09-13T12:26:40.5Z,Publish failure\n",
+		exportedAggregatesFile: "key,type,value\npe,,2\npet,max,3\n",
+		exportedTagsFile:       "run-id=1\nencoding=binary\nsmoke\n",
+	} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s =\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
+
+func TestRunPublishesWithExporter(t *testing.T) {
+	dir := t.TempDir()
+	executor, err := NewAggregator("127.0.0.1:0", 1, []string{"run-id=exported"}, true, WithExporter(FileExporter, dir))
+	if err != nil {
+		t.Fatal("Failed to create the aggregator:", err)
+	}
+	ag := executor.(*Aggregator)
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1", "2"),
+		newRecord(pb.EventsRecord_ACCEPTED, now.Add(time.Millisecond), "1", "2"),
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(2*time.Millisecond), "1"),
+	}, Tags: []string{"encoding=structured"}})
+	waitForRun(t, done)
+
+	aggregates, err := ioutil.ReadFile(filepath.Join(dir, exportedAggregatesFile))
+	if err != nil {
+		t.Fatal("Failed to read the exported aggregates:", err)
+	}
+	if !strings.Contains(string(aggregates), "\nde,,1\n") {
+		t.Errorf("exported aggregates =\n%s\nwant the delivery error", aggregates)
+	}
+	samples, err := ioutil.ReadFile(filepath.Join(dir, exportedSamplesFile))
+	if err != nil {
+		t.Fatal("Failed to read the exported samples:", err)
+	}
+	if !strings.Contains(string(samples), ",dl,") {
+		t.Errorf("exported samples =\n%s\nwant the deliver latencies", samples)
+	}
+	tags, err := ioutil.ReadFile(filepath.Join(dir, exportedTagsFile))
+	if err != nil {
+		t.Fatal("Failed to read the exported tags:", err)
+	}
+	// the tags of the run, then those of the senders
+	if got := string(tags); !strings.HasPrefix(got, "run-id=exported\n") || !strings.HasSuffix(got, "\nencoding=structured\n") {
+		t.Errorf("exported tags = %q, want the run ID and the encoding", got)
+	}
+}
+
+func TestExporterRequiresOutput(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, true, WithExporter(InfluxExporter, "")); err == nil {
+		t.Error("NewAggregator() with the influxdb exporter without output succeeded")
+	}
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, true, WithExporter("prometheus", "")); err == nil {
+		t.Error("NewAggregator() with an unknown exporter succeeded")
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestFinishPublishesPartialResults(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 3, WithRunID("finish"), WithNDJSONExport(filepath.Join(dir, "events.ndjson")))
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "partial"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "partial"),
+	}})
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	if err := client.Finish("orchestrator"); err != nil {
+		t.Fatal("Finish() =", err)
+	}
+	waitForRun(t, done)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "events-finish.ndjson"))
+	if err != nil {
+		t.Fatal("Failed to read the exported events:", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"id":"partial"`) {
+		t.Errorf("exported events = %q, want only the partial event", content)
+	}
+}
+
+func TestFinishAfterCompletion(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	if !ag.waitForEvents() {
+		t.Fatal("waitForEvents() without expected records = false, want true")
+	}
+	ag.complete()
+
+	for i := 0; i < 2; i++ {
+		reply, err := ag.Finish(context.Background(), &pb.FinishRequest{Source: "orchestrator"})
+		if err != nil {
+			t.Fatal("Finish() =", err)
+		}
+		if !reply.GetAlreadyComplete() {
+			t.Error("Finish() after completion replied without already_complete")
+		}
+	}
+}
+
+func TestRecordsTimeoutPublishesPartialResults(t *testing.T) {
+	ag := newTestAggregator(t, 3, WithRecordsTimeout(200*time.Millisecond),
+		WithSummaryExport(filepath.Join(t.TempDir(), "summary.json")))
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1"),
+	}})
+	waitForRun(t, done)
+
+	summary, err := loadRunSummary(ag.summaryPath)
+	if err != nil {
+		t.Fatal("loadRunSummary() =", err)
+	}
+	if !summary.Partial || summary.MissingRecords != 2 {
+		t.Errorf("summary partial = %v with %d missing records, want partial with 2", summary.Partial, summary.MissingRecords)
+	}
+	if summary.Sent != 1 {
+		t.Errorf("summary counts %d sent events, want the one of the received records", summary.Sent)
+	}
+}
+
+func TestRecordsTimeoutAfterAllRecords(t *testing.T) {
+	ag := newTestAggregator(t, 1, WithRecordsTimeout(time.Minute))
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, time.Now(), "1"),
+	}})
+	if !ag.waitForEvents() {
+		t.Fatal("waitForEvents() with all the records = false, want true")
+	}
+
+	// published as 0 so that the runs which can time out are comparable
+	q := newFakeAggregatesStore()
+	(&runAggregates{missingRecords: ag.reportedMissingRecords(), minSamplesForPercentiles: 1}).publish(q)
+	if got, ok := q.run["mr"]; !ok || got != 0 {
+		t.Errorf("run aggregate mr = %v (published: %v), want 0", got, ok)
+	}
+}
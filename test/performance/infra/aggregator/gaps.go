@@ -0,0 +1,158 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// defaultSequenceIDPattern matches the IDs made of a sequence number,
+// optionally prefixed by a partition, e.g. "sender-a-42".
+const defaultSequenceIDPattern = `^(?:(?P<partition>.*)-)?(?P<sequence>[0-9]+)$`
+
+// sequenceGapCases is the number of missing ranges logged.
+const sequenceGapCases = 10
+
+// sequenceParser extracts the partition and the sequence number of an event
+// ID, telling whether the ID is sequential.
+type sequenceParser func(id string) (partition string, sequence uint64, ok bool)
+
+// newSequenceParser returns the parser of the IDs matching the given pattern,
+// which must have a "sequence" named group and can have a "partition" one.
+func newSequenceParser(pattern string) (sequenceParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sequence ID pattern: %v", err)
+	}
+	sequenceGroup, partitionGroup := -1, -1
+	for i, name := range re.SubexpNames() {
+		switch name {
+		case "sequence":
+			sequenceGroup = i
+		case "partition":
+			partitionGroup = i
+		}
+	}
+	if sequenceGroup < 0 {
+		return nil, fmt.Errorf("the sequence ID pattern %q has no \"sequence\" group", pattern)
+	}
+	return func(id string) (string, uint64, bool) {
+		m := re.FindStringSubmatch(id)
+		if m == nil {
+			return "", 0, false
+		}
+		sequence, err := strconv.ParseUint(m[sequenceGroup], 10, 64)
+		if err != nil {
+			return "", 0, false
+		}
+		var partition string
+		if partitionGroup >= 0 {
+			partition = m[partitionGroup]
+		}
+		return partition, sequence, true
+	}, nil
+}
+
+// sequenceGap is a range of missing sequence numbers of a partition, bounds included.
+type sequenceGap struct {
+	partition   string
+	first, last uint64
+}
+
+// size is the number of sequence numbers missing in the gap.
+func (g sequenceGap) size() uint64 {
+	return g.last - g.first + 1
+}
+
+// findSequenceGaps returns the ranges of sequence numbers missing between the
+// lowest and the highest one of each partition, sorted by partition and
+// sequence number.
+func findSequenceGaps(sequences map[string][]uint64) []sequenceGap {
+	partitions := make([]string, 0, len(sequences))
+	for p := range sequences {
+		partitions = append(partitions, p)
+	}
+	sort.Strings(partitions)
+
+	var gaps []sequenceGap
+	for _, p := range partitions {
+		seqs := sequences[p]
+		sort.Slice(seqs, func(x, y int) bool { return seqs[x] < seqs[y] })
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] > seqs[i-1]+1 {
+				gaps = append(gaps, sequenceGap{partition: p, first: seqs[i-1] + 1, last: seqs[i] - 1})
+			}
+		}
+	}
+	return gaps
+}
+
+// trackedSequences returns the sequence numbers of the events either sent or
+// received, by partition, from their composite key or their parsed ID, and the
+// number of events whose ID isn't sequential.
+func (ag *Aggregator) trackedSequences() (sequences map[string][]uint64, unparsed int) {
+	sequences = make(map[string][]uint64)
+	seen := make(map[string]struct{})
+	for _, rec := range []*eventsRecord{ag.sentEvents, ag.receivedEvents} {
+		for id := range rec.Events {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			if key, ok := rec.keys[id]; ok {
+				sequences[key.GetPartition()] = append(sequences[key.GetPartition()], key.GetSequence())
+				continue
+			}
+			partition, sequence, ok := ag.sequenceParser(id)
+			if !ok {
+				unparsed++
+				continue
+			}
+			sequences[partition] = append(sequences[partition], sequence)
+		}
+	}
+	return sequences, unparsed
+}
+
+// reportSequenceGaps logs the number of events missing from the sequences,
+// and the largest missing ranges.
+func reportSequenceGaps(gaps []sequenceGap, unparsed int) {
+	if unparsed > 0 {
+		log.Printf("!! Ignoring %d events whose ID isn't sequential for the sequence gap detection", unparsed)
+	}
+	var missing uint64
+	for _, g := range gaps {
+		missing += g.size()
+	}
+	if len(gaps) == 0 {
+		log.Printf("No gap in the event sequences")
+		return
+	}
+	log.Printf("!! %d events missing from the event sequences, in %d gaps", missing, len(gaps))
+	largest := append([]sequenceGap(nil), gaps...)
+	sort.SliceStable(largest, func(x, y int) bool { return largest[x].size() > largest[y].size() })
+	if len(largest) > sequenceGapCases {
+		largest = largest[:sequenceGapCases]
+	}
+	for _, g := range largest {
+		log.Printf("  partition %q: %d to %d (%d events)", g.partition, g.first, g.last, g.size())
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestSequenceGaps(t *testing.T) {
+	ag := newTestAggregator(t, 0, WithSequenceGapDetection(true, ""))
+	now := time.Now()
+
+	// sender-a sent 1 to 10 but 4 to 6, whose records were lost, and 8, which was
+	// only received; sender-b sent 1 to 3
+	var sent, received []string
+	for i := 1; i <= 10; i++ {
+		switch {
+		case i >= 4 && i <= 6:
+		case i == 8:
+			received = append(received, fmt.Sprintf("sender-a-%d", i))
+		default:
+			sent = append(sent, fmt.Sprintf("sender-a-%d", i))
+		}
+	}
+	sent = append(sent, "sender-b-1", "sender-b-2", "sender-b-3", "not-sequential")
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, sent...),
+		newRecord(pb.EventsRecord_RECEIVED, now, received...),
+	}})
+
+	sequences, unparsed := ag.trackedSequences()
+	if unparsed != 1 {
+		t.Errorf("got %d non sequential IDs, want 1", unparsed)
+	}
+	gaps := findSequenceGaps(sequences)
+	want := []sequenceGap{{partition: "sender-a", first: 4, last: 6}}
+	if !reflect.DeepEqual(gaps, want) {
+		t.Errorf("findSequenceGaps() = %+v, want %+v", gaps, want)
+	}
+	reportSequenceGaps(gaps, unparsed)
+}
+
+func TestSequenceGapsOfCompositeKeys(t *testing.T) {
+	ag := newTestAggregator(t, 0, WithCompositeKeyMatching(true), WithSequenceGapDetection(true, ""))
+	ts := newRecord(pb.EventsRecord_SENT, time.Now(), "x").Events["x"]
+	var keyed []*pb.KeyedEvent
+	for _, seq := range []uint64{0, 1, 2, 5, 9} {
+		keyed = append(keyed, &pb.KeyedEvent{Key: &pb.EventKey{Partition: "p0", Sequence: seq}, At: ts})
+	}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{{Type: pb.EventsRecord_SENT, KeyedEvents: keyed}}})
+
+	sequences, unparsed := ag.trackedSequences()
+	if unparsed != 0 {
+		t.Errorf("got %d non sequential IDs, want 0", unparsed)
+	}
+	want := []sequenceGap{{partition: "p0", first: 3, last: 4}, {partition: "p0", first: 6, last: 8}}
+	if gaps := findSequenceGaps(sequences); !reflect.DeepEqual(gaps, want) {
+		t.Errorf("findSequenceGaps() = %+v, want %+v", gaps, want)
+	}
+}
+
+func TestSequenceIDPattern(t *testing.T) {
+	parse, err := newSequenceParser(`^evt(?P<sequence>[0-9]+)$`)
+	if err != nil {
+		t.Fatal("newSequenceParser() =", err)
+	}
+	if p, seq, ok := parse("evt42"); !ok || p != "" || seq != 42 {
+		t.Errorf("parse(evt42) = %q, %d, %v, want \"\", 42, true", p, seq, ok)
+	}
+	if _, _, ok := parse("42"); ok {
+		t.Error("parse(42) = true for an ID not matching the pattern")
+	}
+
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithSequenceGapDetection(true, `^(?P<seq>[0-9]+)$`)); err == nil {
+		t.Error("NewAggregator() with a pattern without sequence group succeeded")
+	}
+}
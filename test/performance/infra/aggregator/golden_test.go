@@ -0,0 +1,166 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// update rewrites the golden files with the current output, to be reviewed
+// before committing: go test -run Golden -update
+var update = flag.Bool("update", false, "update the golden files")
+
+// capturingStore captures the sample points and aggregates, in publication order.
+type capturingStore struct {
+	buf bytes.Buffer
+}
+
+func (c *capturingStore) AddSamplePoint(xval float64, valueKeyToYVals map[string]float64) error {
+	keys := make([]string, 0, len(valueKeyToYVals))
+	for k := range valueKeyToYVals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&c.buf, "sample %s %.3f %g\n", k, xval, valueKeyToYVals[k])
+	}
+	return nil
+}
+
+func (c *capturingStore) AddRunAggregate(valueKey string, value float64) error {
+	fmt.Fprintf(&c.buf, "run %s %g\n", valueKey, value)
+	return nil
+}
+
+func (c *capturingStore) AddMetricAggregate(valueKey string, aggregateType string, value float64) error {
+	fmt.Fprintf(&c.buf, "metric %s %s %g\n", valueKey, aggregateType, value)
+	return nil
+}
+
+// timestampFixtures are representative patterns of event timestamps, relative
+// to a fixed start.
+var timestampFixtures = map[string][]time.Duration{
+	"single": {0},
+	// 10 events per second for 3 seconds
+	"uniform": stepped(0, 30, 100*time.Millisecond),
+	// 50 events in 50ms, then nothing for 2 seconds and 50 events again
+	"bursts": append(stepped(0, 50, time.Millisecond), stepped(2*time.Second, 50, time.Millisecond)...),
+	// 5 events per second, then 50 events per second
+	"step": append(stepped(0, 10, 200*time.Millisecond), stepped(2*time.Second, 100, 20*time.Millisecond)...),
+}
+
+// stepped returns n durations from start, separated by step.
+func stepped(start time.Duration, n int, step time.Duration) []time.Duration {
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		durations[i] = start + time.Duration(i)*step
+	}
+	return durations
+}
+
+// checkGolden compares the output with the golden file of the given name in
+// testdata, or rewrites it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal("Failed to update the golden file:", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Failed to read the golden file, run with -update to create it:", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s, run with -update and review the diff:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestGoldenThroughput(t *testing.T) {
+	start := time.Unix(1000, 0)
+	smoothings := []throughputSmoothing{
+		{},
+		{mode: throughputRolling, lookback: time.Second},
+		{mode: throughputEWMA, lookback: time.Second},
+	}
+	for name, offsets := range timestampFixtures {
+		t.Run(name, func(t *testing.T) {
+			var c capturingStore
+			for _, s := range smoothings {
+				timestamps := make([]time.Time, len(offsets))
+				for i, o := range offsets {
+					timestamps[i] = start.Add(o)
+				}
+				fmt.Fprintf(&c.buf, "# mode %q lookback %v\n", s.mode, s.lookback)
+				if err := publishThpt(timestamps, &c, "st", s); err != nil {
+					t.Fatal("publishThpt() =", err)
+				}
+			}
+			checkGolden(t, "throughput-"+name, c.buf.Bytes())
+		})
+	}
+}
+
+func TestGoldenLatencies(t *testing.T) {
+	start := time.Unix(1000, 0)
+	for name, offsets := range timestampFixtures {
+		t.Run(name, func(t *testing.T) {
+			// every third event is lost, the others take longer and longer
+			var events []event
+			for i, o := range offsets {
+				sent := start.Add(o)
+				e := event{id: fmt.Sprint(i), sent: sent, accepted: sent.Add(time.Duration(i%7) * time.Millisecond), isAccepted: true}
+				if i%3 != 2 {
+					e.received = sent.Add(time.Duration(i) * time.Millisecond)
+					e.isReceived = true
+				}
+				events = append(events, e)
+			}
+
+			var c capturingStore
+			for _, d := range []struct {
+				prefix    string
+				latencies []time.Duration
+			}{
+				{"p", publishLatencies(events)},
+				{"d", e2eLatencies(events)},
+				{"q", queueingDelays(events)},
+			} {
+				percentiles := reportDistribution(d.prefix, d.prefix, d.latencies)
+				keys := make([]string, 0, len(percentiles))
+				for k := range percentiles {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					fmt.Fprintf(&c.buf, "percentile %s %v\n", k, percentiles[k])
+				}
+				publishWithoutPercentiles(&c, d.prefix+"l", d.latencies)
+			}
+			checkGolden(t, "latencies-"+name, c.buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestGracefulStopTimeout(t *testing.T) {
+	ag := newTestAggregator(t, 2, WithRecordDelay(time.Minute), WithGracefulStopTimeout(100*time.Millisecond))
+	done := runAggregator(context.Background(), ag)
+
+	conn, err := grpc.Dial(ag.listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("Failed to connect to the aggregator:", err)
+	}
+	defer conn.Close()
+	// an upload wedged in the record delay, holding the graceful stop
+	wedged := make(chan error, 1)
+	go func() {
+		_, err := pb.NewEventsRecorderClient(conn).RecordEvents(context.Background(),
+			&pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1")}})
+		wedged <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := ag.Finish(context.Background(), &pb.FinishRequest{Source: "orchestrator"}); err != nil {
+		t.Fatal("Finish() =", err)
+	}
+	waitForRun(t, done)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run returned %v after finish was requested, want shortly after the graceful stop timeout", elapsed)
+	}
+	if err := <-wedged; err == nil {
+		t.Error("the upload in flight at the forced stop succeeded")
+	}
+}
+
+func TestNegativeGracefulStopTimeoutIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithGracefulStopTimeout(-time.Second)); err == nil {
+		t.Error("NewAggregator() with a negative graceful stop timeout succeeded")
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Range and precision of the latency histograms: from 1ns to 1h, within 0.1%.
+const (
+	hdrLowestLatency    = time.Nanosecond
+	hdrHighestLatency   = time.Hour
+	hdrSignificantDigit = 3
+)
+
+// hdrHistogram counts the latencies in buckets whose width is proportional to
+// their values, following the HdrHistogram layout: the values are recorded
+// with a fixed number of significant digits, in a fixed amount of memory,
+// whatever the number of latencies. The negative values are recorded as 0, and
+// the ones above the highest trackable one as it. The exact min, max and sum
+// are tracked as well.
+type hdrHistogram struct {
+	lowest, highest int64
+
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	bucketCount                 int
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+
+	counts []int64
+	count  int64
+
+	min, max time.Duration
+	sum      float64
+}
+
+// newHDRHistogram returns an histogram of the values from lowest to highest,
+// recorded with the given number of significant digits, from 1 to 5.
+func newHDRHistogram(lowest, highest time.Duration, digits int) *hdrHistogram {
+	h := &hdrHistogram{lowest: int64(lowest), highest: int64(highest)}
+	// the sub-buckets must discern the values with a single unit resolution up
+	// to 2 * 10^digits
+	singleUnitResolution := 2 * math.Pow10(digits)
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(singleUnitResolution)))
+	if subBucketCountMagnitude < 1 {
+		subBucketCountMagnitude = 1
+	}
+	h.subBucketHalfCountMagnitude = subBucketCountMagnitude - 1
+	h.unitMagnitude = uint(bits.Len64(uint64(h.lowest))) - 1
+	h.subBucketCount = 1 << (h.subBucketHalfCountMagnitude + 1)
+	h.subBucketHalfCount = h.subBucketCount / 2
+	h.subBucketMask = int64(h.subBucketCount-1) << h.unitMagnitude
+
+	// each bucket covers twice the range of the previous one
+	smallestUntrackable := int64(h.subBucketCount) << h.unitMagnitude
+	h.bucketCount = 1
+	for smallestUntrackable <= h.highest {
+		smallestUntrackable <<= 1
+		h.bucketCount++
+	}
+	h.counts = make([]int64, (h.bucketCount+1)*h.subBucketHalfCount)
+	return h
+}
+
+// newLatencyHistogram returns an histogram of the latencies from 1ns to 1h,
+// taking about 270KB.
+func newLatencyHistogram() *hdrHistogram {
+	return newHDRHistogram(hdrLowestLatency, hdrHighestLatency, hdrSignificantDigit)
+}
+
+func (h *hdrHistogram) bucketIndex(v int64) int {
+	return bits.Len64(uint64(v|h.subBucketMask)) - int(h.unitMagnitude) - int(h.subBucketHalfCountMagnitude+1)
+}
+
+func (h *hdrHistogram) countsIndex(v int64) int {
+	bucket := h.bucketIndex(v)
+	subBucket := int(v >> (uint(bucket) + h.unitMagnitude))
+	return (bucket+1)<<h.subBucketHalfCountMagnitude + subBucket - h.subBucketHalfCount
+}
+
+// valueAt returns the lowest value counted at the given index.
+func (h *hdrHistogram) valueAt(index int) int64 {
+	bucket := index>>h.subBucketHalfCountMagnitude - 1
+	subBucket := index&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= h.subBucketHalfCount
+		bucket = 0
+	}
+	return int64(subBucket) << (uint(bucket) + h.unitMagnitude)
+}
+
+// highestEquivalentValue returns the highest value counted at the given index.
+func (h *hdrHistogram) highestEquivalentValue(index int) int64 {
+	if index+1 < len(h.counts) {
+		return h.valueAt(index+1) - 1
+	}
+	return h.highest
+}
+
+// reportedValue returns the highest value counted at the given index, capped
+// at the highest trackable value and at the exact max.
+func (h *hdrHistogram) reportedValue(index int) time.Duration {
+	v := h.highestEquivalentValue(index)
+	if v > h.highest {
+		v = h.highest
+	}
+	if time.Duration(v) > h.max {
+		return h.max
+	}
+	return time.Duration(v)
+}
+
+// record counts the given latency.
+func (h *hdrHistogram) record(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if h.count == 0 || d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += float64(d)
+
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	} else if v > h.highest {
+		v = h.highest
+	}
+	h.counts[h.countsIndex(v)]++
+}
+
+// mean returns the exact mean of the latencies, 0 without latencies.
+func (h *hdrHistogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.count))
+}
+
+// percentile returns the highest latency equivalent to the given percentile,
+// within the precision of the histogram and capped at the highest trackable
+// value and at the exact max, 0 without latencies.
+func (h *hdrHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	// the same nearest rank as the percentiles of the sorted latencies
+	rank := int64(percentileRank(int(h.count), p))
+	var total int64
+	for i, c := range h.counts {
+		total += c
+		if total < rank {
+			continue
+		}
+		return h.reportedValue(i)
+	}
+	return h.max
+}
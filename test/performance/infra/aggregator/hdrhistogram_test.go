@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHDRHistogramPercentiles(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	h := newLatencyHistogram()
+	sorted := make([]time.Duration, 100000)
+	for i := range sorted {
+		// log-uniform from 10µs to 10s
+		sorted[i] = time.Duration(float64(10*time.Microsecond) * math.Pow(10, 6*rnd.Float64()))
+		h.record(sorted[i])
+	}
+	sortDurations(sorted)
+
+	for _, p := range []float64{1, 50, 90, 99, 99.9, 99.99, 100} {
+		want := percentile(sorted, p)
+		got := h.percentile(p)
+		// within the 3 significant digits of the histogram
+		if diff := got - want; diff < 0 || float64(diff) > float64(want)*0.001 {
+			t.Errorf("p%v = %v, want %v within 0.1%%", p, got, want)
+		}
+	}
+	if h.count != int64(len(sorted)) || h.min != sorted[0] || h.max != sorted[len(sorted)-1] {
+		t.Errorf("count, min and max = %d, %v and %v, want %d, %v and %v",
+			h.count, h.min, h.max, len(sorted), sorted[0], sorted[len(sorted)-1])
+	}
+}
+
+func TestHDRHistogramOutOfRange(t *testing.T) {
+	h := newLatencyHistogram()
+	h.record(-time.Millisecond)
+	h.record(200 * time.Nanosecond)
+	h.record(2 * time.Hour)
+
+	if got := h.percentile(30); got != 0 {
+		t.Errorf("p30 = %v, want the negative latency counted as 0", got)
+	}
+	if got := h.percentile(50); got != 200*time.Nanosecond {
+		t.Errorf("p50 = %v, want 200ns", got)
+	}
+	// the values above the highest trackable one are counted as it, but the max is exact
+	if got := h.percentile(100); got != hdrHighestLatency {
+		t.Errorf("p100 = %v, want %v", got, hdrHighestLatency)
+	}
+	if h.min != -time.Millisecond || h.max != 2*time.Hour {
+		t.Errorf("min and max = %v and %v, want -1ms and 2h", h.min, h.max)
+	}
+	if got, want := h.mean(), (2*time.Hour+200*time.Nanosecond-time.Millisecond)/3; got != want {
+		t.Errorf("mean = %v, want %v", got, want)
+	}
+}
+
+func TestHDRHistogramEmpty(t *testing.T) {
+	h := newLatencyHistogram()
+	if h.percentile(99) != 0 || h.mean() != 0 {
+		t.Errorf("empty histogram p99 and mean = %v and %v, want 0", h.percentile(99), h.mean())
+	}
+}
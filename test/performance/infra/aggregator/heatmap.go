@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// heatmap holds, for each time window, the histogram of the end to end
+// latencies of the events sent during that window.
+type heatmap struct {
+	// Scenario labels the events of the heatmap, if any.
+	Scenario string `json:"scenario,omitempty"`
+	// WindowSeconds is the duration of each window.
+	WindowSeconds float64 `json:"windowSeconds"`
+	// Bounds are the upper bounds in seconds of the histogram buckets. Each
+	// window has one more count, for the latencies above the last bound.
+	Bounds  []float64       `json:"bounds"`
+	Windows []heatmapWindow `json:"windows"`
+}
+
+type heatmapWindow struct {
+	Start  time.Time `json:"start"`
+	Counts []uint64  `json:"counts"`
+}
+
+// computeHeatmap buckets the end to end latencies of the received events by
+// the window of their sent time. The windows are sorted by start time, without
+// gap between the first and the last one: windows without events have zero counts.
+func computeHeatmap(events []event, window time.Duration) *heatmap {
+	var first, last time.Time
+	histograms := make(map[time.Time]*histogram)
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived {
+			continue
+		}
+		start := e.sent.Truncate(window)
+		h, ok := histograms[start]
+		if !ok {
+			h = newHistogram(latencyBuckets)
+			histograms[start] = h
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if start.After(last) {
+				last = start
+			}
+		}
+		h.add(e.e2eLatency())
+	}
+
+	hm := &heatmap{
+		WindowSeconds: window.Seconds(),
+		Bounds:        make([]float64, len(latencyBuckets)),
+		Windows:       make([]heatmapWindow, 0, len(histograms)),
+	}
+	for i, b := range latencyBuckets {
+		hm.Bounds[i] = b.Seconds()
+	}
+	if len(histograms) == 0 {
+		return hm
+	}
+	for start := first; !start.After(last); start = start.Add(window) {
+		h, ok := histograms[start]
+		if !ok {
+			h = newHistogram(latencyBuckets)
+		}
+		hm.Windows = append(hm.Windows, heatmapWindow{Start: start, Counts: h.counts})
+	}
+	return hm
+}
+
+// exportHeatmap writes the latency heatmap of the events of the scenario, if
+// any, as JSON to the file at the given path.
+func exportHeatmap(path string, scenario string, events []event, window time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	hm := computeHeatmap(events, window)
+	hm.Scenario = scenario
+	if err := json.NewEncoder(f).Encode(hm); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestComputeHeatmap(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	// 10 events per window in window 0, 20 in window 1, none in window 2, 5 in window 3
+	eventsPerWindow := []int{10, 20, 0, 5}
+	var events []event
+	for w, n := range eventsPerWindow {
+		for i := 0; i < n; i++ {
+			sent := start.Add(time.Duration(w)*time.Second + time.Duration(i)*time.Millisecond)
+			events = append(events, event{
+				id:         fmt.Sprintf("%d-%d", w, i),
+				sent:       sent,
+				accepted:   sent,
+				received:   sent.Add(time.Duration(i*w) * time.Millisecond),
+				isAccepted: true,
+				isReceived: true,
+			})
+		}
+		// lost events don't count in the heatmap
+		events = append(events, event{id: fmt.Sprintf("%d-lost", w), sent: start.Add(time.Duration(w) * time.Second)})
+	}
+
+	hm := computeHeatmap(events, time.Second)
+	if len(hm.Bounds) != len(latencyBuckets) {
+		t.Errorf("len(Bounds) = %d, want %d", len(hm.Bounds), len(latencyBuckets))
+	}
+
+	wantWindows := map[time.Time]int{
+		start:                      10,
+		start.Add(time.Second):     20,
+		start.Add(2 * time.Second): 0,
+		start.Add(3 * time.Second): 5,
+	}
+	if len(hm.Windows) != len(wantWindows) {
+		t.Fatalf("len(Windows) = %d, want %d", len(hm.Windows), len(wantWindows))
+	}
+	for i, w := range hm.Windows {
+		if i > 0 && w.Start.Sub(hm.Windows[i-1].Start) != time.Second {
+			t.Errorf("window %d starts at %v, not right after the previous window", i, w.Start)
+		}
+		if len(w.Counts) != len(hm.Bounds)+1 {
+			t.Errorf("window %v has %d buckets, want %d", w.Start, len(w.Counts), len(hm.Bounds)+1)
+		}
+		var sum uint64
+		for _, c := range w.Counts {
+			sum += c
+		}
+		if want := wantWindows[w.Start]; sum != uint64(want) {
+			t.Errorf("window %v bucket counts sum = %d, want %d", w.Start, sum, want)
+		}
+	}
+
+	// all latencies of the first window are 0
+	if got := hm.Windows[0].Counts[0]; got != 10 {
+		t.Errorf("first window lowest bucket count = %d, want 10", got)
+	}
+}
+
+func TestNonPositiveHeatmapWindowIsRejected(t *testing.T) {
+	for _, window := range []time.Duration{0, -time.Second} {
+		if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, WithHeatmap("heatmap.json", window)); err == nil {
+			t.Errorf("NewAggregator() with a %v heatmap window succeeded, want an error", window)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// hgrmTicksPerHalfDistance is the number of rows of the percentile
+// distribution written for each halving of the distance to the 100th
+// percentile, the default of HdrHistogram.
+const hgrmTicksPerHalfDistance = 5
+
+// latencyHistogramOf returns the histogram of the given latencies.
+func latencyHistogramOf(latencies []time.Duration) *hdrHistogram {
+	h := newLatencyHistogram()
+	for _, l := range latencies {
+		h.record(l)
+	}
+	return h
+}
+
+// writePercentileDistribution writes the percentile distribution of the
+// histogram in the text format of HdrHistogram, with the values in
+// milliseconds, so that it can be plotted with its tools, e.g.
+// https://hdrhistogram.github.io/HdrHistogram/plotFiles.html. The rows get
+// denser towards the 100th percentile, which is the last one.
+func (h *hdrHistogram) writePercentileDistribution(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+	var total int64
+	next := 0.0
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		total += c
+		for reached := 100 * float64(total) / float64(h.count); next <= reached; {
+			fmt.Fprintf(bw, "%12.3f %2.12f %10d %14.2f\n", millis(h.reportedValue(i)), next/100, total, 100/(100-next))
+			ticks := float64(hgrmTicksPerHalfDistance) * math.Pow(2, math.Floor(math.Log2(100/(100-next)))+1)
+			next += 100 / ticks
+			// a single row at the highest value, before the 100th percentile
+			if total == h.count {
+				break
+			}
+		}
+	}
+	if h.count > 0 {
+		fmt.Fprintf(bw, "%12.3f %2.12f %10d\n", millis(h.max), 1.0, h.count)
+	}
+
+	fmt.Fprintf(bw, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", millis(h.mean()), millis(h.stdDev()))
+	fmt.Fprintf(bw, "#[Max     = %12.3f, Total count    = %12d]\n", millis(h.max), h.count)
+	fmt.Fprintf(bw, "#[Buckets = %12d, SubBuckets     = %12d]\n", h.bucketCount, h.subBucketCount)
+	return bw.Flush()
+}
+
+// stdDev returns the standard deviation of the latencies, estimated from the
+// middle of the range of their counts, 0 without latencies.
+func (h *hdrHistogram) stdDev() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	mean := float64(h.mean())
+	var squares float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		low := h.valueAt(i)
+		middle := float64(low) + float64(h.highestEquivalentValue(i)-low)/2
+		squares += float64(c) * (middle - mean) * (middle - mean)
+	}
+	return time.Duration(math.Sqrt(squares / float64(h.count)))
+}
+
+// exportHDRHistogram writes the percentile distribution of the histogram to
+// the file at the given path.
+func exportHDRHistogram(path string, h *hdrHistogram) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := h.writePercentileDistribution(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
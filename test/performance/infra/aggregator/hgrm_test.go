@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHDRPercentileDistribution(t *testing.T) {
+	// 1 to 1000ms
+	h := latencyHistogramOf(latencies(1000))
+	var buf bytes.Buffer
+	if err := h.writePercentileDistribution(&buf); err != nil {
+		t.Fatal("writePercentileDistribution() =", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "Value Percentile TotalCount 1/(1-Percentile)" || lines[1] != "" {
+		t.Fatalf("header = %q, want the HdrHistogram columns followed by a blank line", lines[:2])
+	}
+	footer := lines[len(lines)-3:]
+	for i, want := range []string{
+		"#[Mean    =      500.500, StdDeviation   =",
+		"#[Max     =     1000.000, Total count    =         1000]",
+		"#[Buckets =           32, SubBuckets     =         2048]",
+	} {
+		if !strings.HasPrefix(footer[i], want) {
+			t.Errorf("footer line %d = %q, want it to start with %q", i, footer[i], want)
+		}
+	}
+
+	rows := lines[2 : len(lines)-3]
+	if got, want := strings.Fields(rows[0]), []string{"1.000", "0.000000000000", "1", "1.00"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("first row = %v, want %v", got, want)
+	}
+	if got, want := strings.Fields(rows[len(rows)-1]), []string{"1000.000", "1.000000000000", "1000"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("last row = %v, want %v", got, want)
+	}
+	var previous float64
+	for _, row := range rows[1:] {
+		fields := strings.Fields(row)
+		value, _ := strconv.ParseFloat(fields[0], 64)
+		p, _ := strconv.ParseFloat(fields[1], 64)
+		count, _ := strconv.Atoi(fields[2])
+		if p <= previous {
+			t.Errorf("row %q: percentile not above the previous one %v", row, previous)
+		}
+		previous = p
+		// the value of the nearest rank, within the 3 significant digits of the histogram
+		if want := float64(count); value < want || value > want*1.001 {
+			t.Errorf("row %q: value not within 0.1%% of %vms", row, want)
+		}
+	}
+	if len(rows) < 50 {
+		t.Errorf("%d rows, want them denser towards the 100th percentile", len(rows))
+	}
+}
+
+func TestHDRPercentileDistributionEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newLatencyHistogram().writePercentileDistribution(&buf); err != nil {
+		t.Fatal("writePercentileDistribution() =", err)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 5 {
+		t.Errorf("empty distribution has %d lines, want the header and the footer only:\n%s", got, buf.String())
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// minSamplesForPercentile is the number of samples below which the given
+// percentile can't be estimated meaningfully: fewer than one sample above it,
+// e.g. 1000 samples for the 99.9th percentile.
+func minSamplesForPercentile(p float64) int {
+	return int(math.Ceil(100/(100-p) - 1e-9))
+}
+
+// highPercentileKey is the Mako value key of the given percentile of the end
+// to end latencies: its "dl" key if it's among the latencyAggregatePercentiles,
+// e.g. "dl999" for the 99.9th, so that it has a single key, or else "d"
+// followed by its digits, e.g. "d9999" for the 99.99th.
+func highPercentileKey(p float64) string {
+	for _, q := range latencyAggregatePercentiles {
+		if p == q {
+			return percentileKey("dl", p)
+		}
+	}
+	return percentileKey("d", p)
+}
+
+// validateHighPercentiles returns an error if a percentile isn't within (0, 100).
+func validateHighPercentiles(percentiles []float64) error {
+	for _, p := range percentiles {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("invalid percentile %v, must be within (0, 100)", p)
+		}
+	}
+	return nil
+}
+
+// reportHighPercentiles logs the given percentiles of the sorted end to end
+// latencies, warning about the ones estimated from too few samples, and
+// returns them keyed by percentile.
+func reportHighPercentiles(sorted []time.Duration, percentiles []float64) map[float64]time.Duration {
+	return reportHighPercentilesOf(len(sorted), func(p float64) time.Duration { return percentile(sorted, p) }, percentiles)
+}
+
+// reportHighPercentilesOf logs the given percentiles of the given number of
+// end to end latencies, as returned by percentileOf, e.g. from their
+// histogram, and returns them keyed by percentile.
+func reportHighPercentilesOf(count int, percentileOf func(float64) time.Duration, percentiles []float64) map[float64]time.Duration {
+	values := make(map[float64]time.Duration, len(percentiles))
+	for _, p := range percentiles {
+		values[p] = percentileOf(p)
+		if min := minSamplesForPercentile(p); count < min {
+			log.Printf("!! End to end latency p%v: %v, estimated from %d samples, fewer than the %d needed to be meaningful",
+				p, values[p], count, min)
+		} else {
+			log.Printf("End to end latency p%v: %v", p, values[p])
+		}
+	}
+	return values
+}
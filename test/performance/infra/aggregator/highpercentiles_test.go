@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHighPercentiles(t *testing.T) {
+	// 10000 latencies of 1 to 10000µs
+	latencies := make([]time.Duration, 10000)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Microsecond
+	}
+
+	values := reportHighPercentiles(latencies, []float64{99.9, 99.99})
+	if want := 9990 * time.Microsecond; values[99.9] != want {
+		t.Errorf("p99.9 = %v, want %v", values[99.9], want)
+	}
+	if want := 9999 * time.Microsecond; values[99.99] != want {
+		t.Errorf("p99.99 = %v, want %v", values[99.99], want)
+	}
+
+	a := runAggregates{
+		e2eLatencies:             latencies,
+		latencyPercentiles:       reportLatencyPercentiles(nil, latencies),
+		highPercentiles:          values,
+		minSamplesForPercentiles: defaultMinSamplesForPercentiles,
+	}
+	q := &countingAggregatesStore{fakeAggregatesStore: newFakeAggregatesStore(), published: make(map[string]int)}
+	a.publish(q)
+	for key, want := range map[string]time.Duration{"dl999": 9990 * time.Microsecond, "d9999": 9999 * time.Microsecond} {
+		if got, ok := q.run[key]; !ok || got != want.Seconds() {
+			t.Errorf("run aggregate %s = %v (published: %v), want %v", key, got, ok, want.Seconds())
+		}
+	}
+	// a single key for the 99.9th percentile
+	if n := q.published["dl999"]; n != 1 {
+		t.Errorf("dl999 published %d times, want once", n)
+	}
+	if _, ok := q.run["d999"]; ok {
+		t.Error("99.9th percentile published as d999 too")
+	}
+}
+
+// countingAggregatesStore counts how many times each run aggregate is published.
+type countingAggregatesStore struct {
+	*fakeAggregatesStore
+	published map[string]int
+}
+
+func (c *countingAggregatesStore) AddRunAggregate(valueKey string, value float64) error {
+	c.published[valueKey]++
+	return c.fakeAggregatesStore.AddRunAggregate(valueKey, value)
+}
+
+func TestMinSamplesForPercentile(t *testing.T) {
+	for p, want := range map[float64]int{99: 100, 99.9: 1000, 99.99: 10000, 50: 2} {
+		if got := minSamplesForPercentile(p); got != want {
+			t.Errorf("minSamplesForPercentile(%v) = %d, want %d", p, got, want)
+		}
+	}
+}
+
+func TestInvalidHighPercentileIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithHighPercentiles(99.9, 100)); err == nil {
+		t.Error("NewAggregator() with the 100th percentile succeeded")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultInfluxTimeout bounds the post of the results to InfluxDB.
+const defaultInfluxTimeout = time.Minute
+
+// Measurements of the InfluxDB line protocol the results are written to.
+const (
+	influxSamples    = "eventing_perf_samples"
+	influxErrors     = "eventing_perf_errors"
+	influxAggregates = "eventing_perf_aggregates"
+)
+
+// influxExporter writes the results as InfluxDB line protocol, tagged with
+// the tags of the run, to a file or posted to the write endpoint of InfluxDB.
+type influxExporter struct {
+	publishedResults
+	output string
+	client *http.Client
+	// time of the aggregates, set when flushed
+	now func() time.Time
+}
+
+var _ Exporter = (*influxExporter)(nil)
+
+// newInfluxExporter returns an exporter writing the results to the given file,
+// or posting them if it's an http(s) URL, e.g.
+// http://influxdb:8086/write?db=eventing.
+func newInfluxExporter(output string, tags []string) *influxExporter {
+	e := &influxExporter{output: output, client: &http.Client{Timeout: defaultInfluxTimeout}, now: time.Now}
+	e.AddTags(tags...)
+	return e
+}
+
+func (e *influxExporter) Name() string {
+	return "InfluxDB at " + e.output
+}
+
+func (e *influxExporter) Flush(ctx context.Context) error {
+	var buf bytes.Buffer
+	if err := e.writeLines(&buf); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(e.output, "http://") && !strings.HasPrefix(e.output, "https://") {
+		return ioutil.WriteFile(e.output, buf.Bytes(), 0644)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.output, &buf)
+	if err != nil {
+		return fmt.Errorf("invalid InfluxDB request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %v", e.output, err)
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("InfluxDB at %s answered %s", e.output, resp.Status)
+	}
+	return nil
+}
+
+// writeLines writes a line per sample point with its values as fields, a line
+// per error and a line per aggregate, at the time of the flush.
+func (e *influxExporter) writeLines(w io.Writer) error {
+	tags := influxTags(e.tags)
+	for _, s := range e.samples {
+		keys := make([]string, 0, len(s.values))
+		for k := range s.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, len(keys))
+		for i, k := range keys {
+			fields[i] = influxEscape(k) + "=" + strconv.FormatFloat(s.values[k], 'g', -1, 64)
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s %d\n", influxSamples, tags, strings.Join(fields, ","), xTime(s.xval).UnixNano()); err != nil {
+			return err
+		}
+	}
+	for _, r := range e.errors {
+		if _, err := fmt.Fprintf(w, "%s%s message=\"%s\" %d\n", influxErrors, tags, influxStringEscape(r.message), xTime(r.xval).UnixNano()); err != nil {
+			return err
+		}
+	}
+	now := e.now().UnixNano()
+	for _, a := range e.aggregates {
+		aggregateTags := tags
+		if a.aggregateType != "" {
+			aggregateTags += ",type=" + influxEscape(a.aggregateType)
+		}
+		if _, err := fmt.Fprintf(w, "%s%s %s=%s %d\n", influxAggregates, aggregateTags, influxEscape(a.valueKey),
+			strconv.FormatFloat(a.value, 'g', -1, 64), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// influxTags returns the tags of the run as InfluxDB tags, sorted by key and
+// prefixed by a comma, those without value valued true.
+func influxTags(tags []string) string {
+	values := make(map[string]string, len(tags))
+	for _, t := range tags {
+		if k, v := splitTag(t); k != "" {
+			values[k] = v
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, ",%s=%s", influxEscape(k), influxEscape(values[k]))
+	}
+	return b.String()
+}
+
+// splitTag splits a tag of the run into its key and value, true if it has
+// none.
+func splitTag(tag string) (string, string) {
+	if i := strings.Index(tag, "="); i >= 0 && i+1 < len(tag) {
+		return tag[:i], tag[i+1:]
+	}
+	return strings.TrimSuffix(tag, "="), "true"
+}
+
+// influxEscape escapes the commas, equal signs and spaces of a key or a tag
+// value of the InfluxDB line protocol.
+func influxEscape(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+// influxStringEscape escapes the double quotes and backslashes of a string
+// field value of the InfluxDB line protocol.
+func influxStringEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/test/mako"
+)
+
+// addTestResults adds a sample point, an error, a run and a metric aggregate,
+// and tags.
+func addTestResults(t *testing.T, e Exporter) {
+	t.Helper()
+	at := time.Unix(1600000000, 500000000)
+	if err := e.AddSample(mako.XTime(at), map[string]float64{"pl": 0.25, "dl": 1.5}); err != nil {
+		t.Fatal("AddSample() =", err)
+	}
+	if err := e.AddError(mako.XTime(at), publishFailureMessage); err != nil {
+		t.Fatal("AddError() =", err)
+	}
+	if err := e.AddAggregate("pe", "", 2); err != nil {
+		t.Fatal("AddAggregate() =", err)
+	}
+	if err := e.AddAggregate("pet", "max", 3); err != nil {
+		t.Fatal("AddAggregate() =", err)
+	}
+	e.AddTags("encoding=binary", "smoke")
+}
+
+const wantInfluxLines = `eventing_perf_samples,encoding=binary,run-id=1,smoke=true dl=1.5,pl=0.25 1600000000500000000
+eventing_perf_errors,encoding=binary,run-id=1,smoke=true message="Publish failure" 1600000000500000000
+eventing_perf_aggregates,encoding=binary,run-id=1,smoke=true pe=2 1700000000000000000
+eventing_perf_aggregates,encoding=binary,run-id=1,smoke=true,type=max pet=3 1700000000000000000
+`
+
+func TestInfluxExporterWritesLineProtocol(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.lp")
+	e := newInfluxExporter(path, []string{"run-id=1"})
+	e.now = func() time.Time { return time.Unix(1700000000, 0) }
+	addTestResults(t, e)
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal("Flush() =", err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Failed to read the exported results:", err)
+	}
+	if string(got) != wantInfluxLines {
+		t.Errorf("exported lines =\n%s\nwant\n%s", got, wantInfluxLines)
+	}
+}
+
+func TestInfluxExporterPostsToURL(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("db") != "eventing" {
+			http.Error(w, "database not found", http.StatusNotFound)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	e := newInfluxExporter(server.URL+"/write?db=eventing", []string{"run-id=1"})
+	e.now = func() time.Time { return time.Unix(1700000000, 0) }
+	addTestResults(t, e)
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal("Flush() =", err)
+	}
+	if string(body) != wantInfluxLines {
+		t.Errorf("posted lines =\n%s\nwant\n%s", body, wantInfluxLines)
+	}
+
+	if err := newInfluxExporter(server.URL+"/write?db=missing", nil).Flush(context.Background()); err == nil {
+		t.Error("Flush() to a missing database succeeded")
+	}
+}
+
+func TestInfluxEscaping(t *testing.T) {
+	if got, want := influxTags([]string{"scenario=a b", "k=v,w", "empty=", ""}), `,empty=true,k=v\,w,scenario=a\ b`; got != want {
+		t.Errorf("influxTags() = %q, want %q", got, want)
+	}
+	if got, want := influxStringEscape(`say "hi" \o/`), `say \"hi\" \\o/`; got != want {
+		t.Errorf("influxStringEscape() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// latencyAggregatePercentiles are the percentiles of the publish and end to
+// end latencies published as run aggregates.
+var latencyAggregatePercentiles = []float64{50, 90, 99, 99.9}
+
+// percentileKey is the Mako value key of the given percentile, the prefix
+// followed by its digits, e.g. "dl999" for the 99.9th percentile of "dl".
+func percentileKey(prefix string, p float64) string {
+	return prefix + strings.Replace(fmt.Sprint(p), ".", "", 1)
+}
+
+// latencyDistribution is a distribution of latencies whose percentiles are
+// published with the given prefix.
+type latencyDistribution struct {
+	name, prefix string
+	count        int
+	percentile   func(p float64) time.Duration
+}
+
+// reportLatencyPercentiles logs the latencyAggregatePercentiles of the sorted
+// publish and end to end latencies, one line for each, and returns them keyed
+// by percentileKey with the "pl" and "dl" prefixes.
+func reportLatencyPercentiles(publish, e2e []time.Duration) map[string]time.Duration {
+	return reportPercentiles(
+		latencyDistribution{"Publish latency", "pl", len(publish), func(p float64) time.Duration { return percentile(publish, p) }},
+		latencyDistribution{"End to end latency", "dl", len(e2e), func(p float64) time.Duration { return percentile(e2e, p) }},
+	)
+}
+
+// reportHistogramPercentiles is reportLatencyPercentiles for the histograms of
+// the latencies.
+func reportHistogramPercentiles(publish, e2e *hdrHistogram) map[string]time.Duration {
+	return reportPercentiles(
+		latencyDistribution{"Publish latency", "pl", int(publish.count), publish.percentile},
+		latencyDistribution{"End to end latency", "dl", int(e2e.count), e2e.percentile},
+	)
+}
+
+func reportPercentiles(distributions ...latencyDistribution) map[string]time.Duration {
+	values := make(map[string]time.Duration, len(distributions)*len(latencyAggregatePercentiles))
+	for _, d := range distributions {
+		if d.count == 0 {
+			continue
+		}
+		parts := make([]string, 0, len(latencyAggregatePercentiles))
+		for _, p := range latencyAggregatePercentiles {
+			v := d.percentile(p)
+			values[percentileKey(d.prefix, p)] = v
+			parts = append(parts, fmt.Sprintf("p%v=%v", p, v))
+		}
+		log.Printf("%s over %d samples: %s", d.name, d.count, strings.Join(parts, " "))
+	}
+	return values
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReportLatencyPercentiles(t *testing.T) {
+	ms := time.Millisecond
+	got := reportLatencyPercentiles(latencies(100), latencies(1000))
+	want := map[string]time.Duration{
+		"pl50": 50 * ms, "pl90": 90 * ms, "pl99": 99 * ms, "pl999": 100 * ms,
+		"dl50": 500 * ms, "dl90": 900 * ms, "dl99": 990 * ms, "dl999": 999 * ms,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reportLatencyPercentiles() = %v, want %v", got, want)
+	}
+
+	if got := reportLatencyPercentiles(nil, latencies(10)); len(got) != 4 || got["dl50"] != 5*ms {
+		t.Errorf("reportLatencyPercentiles() without publish latencies = %v, want the end to end ones only", got)
+	}
+}
+
+func TestLatencyPercentilesArePublished(t *testing.T) {
+	a := &runAggregates{
+		publishLatencies:         latencies(200),
+		e2eLatencies:             latencies(200),
+		minSamplesForPercentiles: 100,
+	}
+	a.latencyPercentiles = reportLatencyPercentiles(a.publishLatencies, a.e2eLatencies)
+	q := newFakeAggregatesStore()
+	a.publish(q)
+	if got, want := q.run["dl99"], (198 * time.Millisecond).Seconds(); got != want {
+		t.Errorf("published dl99 = %v, want %v", got, want)
+	}
+	if got, want := q.run["pl999"], (200 * time.Millisecond).Seconds(); got != want {
+		t.Errorf("published pl999 = %v, want %v", got, want)
+	}
+}
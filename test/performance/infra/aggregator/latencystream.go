@@ -0,0 +1,203 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// defaultLatencyStreamBuffer is the default number of latency samples buffered
+// for each subscriber, beyond which they are dropped.
+const defaultLatencyStreamBuffer = 1024
+
+// streamedTTL is how long the IDs of the streamed events are remembered: a
+// duplicate comes from the counterpart of the event recorded concurrently,
+// within the same uploads.
+const streamedTTL = time.Minute
+
+// latencySubscriber buffers the latency samples streamed to a subscriber.
+type latencySubscriber struct {
+	samples chan *pb.LatencySample
+	// samples dropped since the last buffered one, guarded by the hub
+	dropped uint64
+}
+
+// latencyHub fans the latencies of the matched events out to the subscribers,
+// dropping the samples of those which don't keep up rather than blocking the
+// recording of the events.
+type latencyHub struct {
+	sync.Mutex
+	buffer      int
+	subscribers map[*latencySubscriber]struct{}
+	// events recently streamed, as both their sent and received timestamps may
+	// be recorded concurrently, each then matching the other
+	streamed *submissionCache
+}
+
+func newLatencyHub(buffer int) *latencyHub {
+	return &latencyHub{
+		buffer:      buffer,
+		subscribers: make(map[*latencySubscriber]struct{}),
+		streamed:    newSubmissionCache(streamedTTL),
+	}
+}
+
+func (h *latencyHub) subscribe() *latencySubscriber {
+	h.Lock()
+	defer h.Unlock()
+	s := &latencySubscriber{samples: make(chan *pb.LatencySample, h.buffer)}
+	h.subscribers[s] = struct{}{}
+	return s
+}
+
+func (h *latencyHub) unsubscribe(s *latencySubscriber) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.subscribers, s)
+}
+
+// publish streams the latency of the matched event to the subscribers, unless
+// it was already streamed. An event is matched at most twice, when its sent
+// and received timestamps are recorded concurrently, so its ID is forgotten
+// once its duplicate is ignored.
+func (h *latencyHub) publish(id string, sent, received time.Time) {
+	if h.streamed.seen(id) {
+		h.streamed.forget(id)
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	if len(h.subscribers) == 0 {
+		return
+	}
+	sentProto, err := ptypes.TimestampProto(sent)
+	if err != nil {
+		return
+	}
+	latency := ptypes.DurationProto(received.Sub(sent))
+	for s := range h.subscribers {
+		select {
+		case s.samples <- &pb.LatencySample{Id: id, Sent: sentProto, Latency: latency, Dropped: s.dropped}:
+			s.dropped = 0
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// matchedEvent is a newly recorded event.
+type matchedEvent struct {
+	id string
+	t  *timestamp.Timestamp
+}
+
+// latencyMatch is an event recorded at both ends of a latency.
+type latencyMatch struct {
+	id       string
+	from, to time.Time
+}
+
+// matchAdded returns the given newly recorded events whose counterpart is
+// already recorded in the given record, the events being the start of the
+// latencies if addedFirst, their end otherwise. It must be called without
+// holding the lock of the counterparts.
+func matchAdded(counterparts *eventsRecord, added []matchedEvent, addedFirst bool) []latencyMatch {
+	type match struct {
+		id       string
+		from, to *timestamp.Timestamp
+	}
+	var matches []match
+	counterparts.Lock()
+	for _, e := range added {
+		other, ok := counterparts.Events[e.id]
+		if !ok {
+			continue
+		}
+		if addedFirst {
+			matches = append(matches, match{id: e.id, from: e.t, to: other})
+		} else {
+			matches = append(matches, match{id: e.id, from: other, to: e.t})
+		}
+	}
+	counterparts.Unlock()
+
+	latencies := make([]latencyMatch, 0, len(matches))
+	for _, m := range matches {
+		from, err := ptypes.Timestamp(m.from)
+		if err != nil {
+			continue
+		}
+		to, err := ptypes.Timestamp(m.to)
+		if err != nil {
+			continue
+		}
+		latencies = append(latencies, latencyMatch{id: m.id, from: from, to: to})
+	}
+	return latencies
+}
+
+// streamMatched streams the latencies of the given events newly recorded with
+// the given type whose counterpart, received or sent, is already recorded.
+// It must be called without holding the lock of the events records.
+func (ag *Aggregator) streamMatched(recType pb.EventsRecord_Type, added []matchedEvent) {
+	if len(added) == 0 {
+		return
+	}
+	var matches []latencyMatch
+	if recType == pb.EventsRecord_RECEIVED {
+		matches = matchAdded(ag.sentEvents, added, false)
+	} else {
+		matches = matchAdded(ag.receivedEvents, added, true)
+	}
+	for _, m := range matches {
+		ag.latencies.publish(m.id, m.from, m.to)
+	}
+}
+
+// StreamLatencies implements event_state.EventsRecorder
+func (ag *Aggregator) StreamLatencies(in *pb.StreamRequest, stream pb.EventsRecorder_StreamLatenciesServer) error {
+	if ag.latencies == nil {
+		return status.Error(codes.FailedPrecondition, "the aggregator doesn't stream latencies, see --stream-latencies")
+	}
+	s := ag.latencies.subscribe()
+	defer ag.latencies.unsubscribe(s)
+	log.Printf("Streaming the latencies to %q", in.Source)
+	for {
+		select {
+		case sample := <-s.samples:
+			if err := stream.Send(sample); err != nil {
+				log.Printf("!! Stopped streaming the latencies to %q: %v", in.Source, err)
+				return err
+			}
+		case <-stream.Context().Done():
+			log.Printf("%q stopped streaming the latencies", in.Source)
+			return nil
+		case <-ag.recordsComplete:
+			log.Printf("Received all the records, ending the stream of latencies to %q", in.Source)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestStreamLatencies(t *testing.T) {
+	ag := newTestAggregator(t, 3, WithLatencyStream(true, 16))
+	done := runAggregator(context.Background(), ag)
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	samples := make(chan *pb.LatencySample, 16)
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- client.StreamLatencies(context.Background(), "dashboard", func(s *pb.LatencySample) {
+			samples <- s
+		})
+	}()
+	// the subscription isn't acknowledged, wait for it to be registered
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		ag.latencies.Lock()
+		subscribed := len(ag.latencies.subscribers) > 0
+		ag.latencies.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for the subscription")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	now := time.Now()
+	// received before sent, matched when the sent event is recorded
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(30*time.Millisecond), "1"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1", "2"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "2"),
+	}})
+
+	for _, want := range []struct {
+		id      string
+		latency time.Duration
+	}{{"1", 30 * time.Millisecond}, {"2", 10 * time.Millisecond}} {
+		select {
+		case s := <-samples:
+			latency, err := ptypes.Duration(s.Latency)
+			if err != nil {
+				t.Fatal("Invalid latency:", err)
+			}
+			if s.Id != want.id || latency != want.latency || s.Dropped != 0 {
+				t.Errorf("sample = %s %v dropped %d, want %s %v dropped 0", s.Id, latency, s.Dropped, want.id, want.latency)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for the latency of %s", want.id)
+		}
+	}
+
+	waitForRun(t, done)
+	select {
+	case err := <-streamed:
+		if err != nil {
+			t.Error("StreamLatencies() =", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Timeout waiting for the end of the stream once all the records were received")
+	}
+}
+
+func TestLatencyHubDropsForSlowSubscribers(t *testing.T) {
+	h := newLatencyHub(1)
+	s := h.subscribe()
+	sent := time.Now()
+	for _, id := range []string{"1", "2", "3", "1"} {
+		h.publish(id, sent, sent.Add(time.Millisecond))
+	}
+	if got := (<-s.samples).Id; got != "1" {
+		t.Errorf("first sample = %s, want 1", got)
+	}
+	// "1" is streamed once, "2" and "3" dropped
+	h.publish("4", sent, sent.Add(time.Millisecond))
+	sample := <-s.samples
+	if sample.Id != "4" || sample.Dropped != 2 {
+		t.Errorf("sample = %s dropped %d, want 4 dropped 2", sample.Id, sample.Dropped)
+	}
+}
+
+func TestLatencyHubForgetsStreamedEvents(t *testing.T) {
+	h := newLatencyHub(16)
+	now := time.Now()
+	h.streamed.now = func() time.Time { return now }
+	s := h.subscribe()
+
+	// matched twice, when recorded concurrently
+	h.publish("1", now, now.Add(time.Millisecond))
+	h.publish("1", now, now.Add(time.Millisecond))
+	h.publish("2", now, now.Add(time.Millisecond))
+	if n := len(s.samples); n != 2 {
+		t.Fatalf("streamed %d samples, want 2", n)
+	}
+	if _, ok := h.streamed.current["1"]; ok {
+		t.Error("event 1 still remembered once its duplicate was ignored")
+	}
+
+	// the events matched once are forgotten after twice the TTL
+	now = now.Add(2 * streamedTTL)
+	h.publish("3", now, now.Add(time.Millisecond))
+	if remembered := len(h.streamed.current) + len(h.streamed.previous); remembered != 1 {
+		t.Errorf("remembered %d streamed events, want only the last one", remembered)
+	}
+}
+
+func TestStreamLatenciesDisabled(t *testing.T) {
+	ag := newTestAggregator(t, 1)
+	if err := ag.StreamLatencies(&pb.StreamRequest{}, nil); err == nil {
+		t.Error("StreamLatencies() = nil error without streaming latencies, want it rejected")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestLateRecordsDuringLinger(t *testing.T) {
+	var fatal fatalRecorder
+	ag := newTestAggregator(t, 1, WithLinger(time.Minute), WithTeardownCheck(true), WithFatalHandler(fatal.fatalf))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "on-time")}})
+	<-ag.recordsComplete
+
+	conn, err := grpc.Dial(ag.listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("Failed to connect to the aggregator:", err)
+	}
+	defer conn.Close()
+	reply, err := pb.NewEventsRecorderClient(conn).RecordEvents(context.Background(),
+		&pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "late")}})
+	if err != nil {
+		t.Fatal("RecordEvents() during the linger =", err)
+	}
+	if !reply.GetAfterCompletion() {
+		t.Error("RecordEvents() during the linger replied without after_completion")
+	}
+
+	select {
+	case <-done:
+		t.Fatal("Run returned before the end of the linger")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// ends the linger
+	cancel()
+	waitForRun(t, done)
+
+	if _, counted := ag.sentEvents.Events["late"]; counted {
+		t.Error("the late event was recorded")
+	}
+	if _, counted := ag.sentEvents.Events["on-time"]; !counted {
+		t.Error("the on-time event wasn't recorded")
+	}
+	if errs := fatal.reported(); len(errs) != 0 {
+		t.Errorf("reported fatal errors %v, want none", errs)
+	}
+}
+
+func TestNegativeLingerIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithLinger(-time.Second)); err == nil {
+		t.Error("NewAggregator() with a negative linger succeeded")
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// liveMetricsPath is the path the live metrics are served at.
+const liveMetricsPath = "/metrics"
+
+// liveLatencyBuckets are the upper bounds of the buckets of the live latency
+// histograms, in seconds, from 1ms to about 16s.
+var liveLatencyBuckets = func() []float64 {
+	buckets := make([]float64, 15)
+	for i := range buckets {
+		buckets[i] = 0.001 * float64(uint(1)<<uint(i))
+	}
+	return buckets
+}()
+
+// liveHistogram counts the latencies in each bucket of liveLatencyBuckets.
+type liveHistogram struct {
+	// buckets[i] counts the latencies in (liveLatencyBuckets[i-1], liveLatencyBuckets[i]]
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func newLiveHistogram() *liveHistogram {
+	return &liveHistogram{buckets: make([]uint64, len(liveLatencyBuckets))}
+}
+
+func (h *liveHistogram) observe(v float64) {
+	for i, le := range liveLatencyBuckets {
+		if v <= le {
+			h.buckets[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// liveMetrics exposes the counts and latencies of the events as they are
+// recorded in the Prometheus text format, to be scraped while the run is in
+// progress. It writes the format itself, as the image doesn't embed the
+// Prometheus client.
+type liveMetrics struct {
+	ag *Aggregator
+
+	sync.Mutex
+	// distinct events recorded, by type of record
+	recorded map[string]uint64
+	// histograms of the publish and end to end latencies
+	latencies map[string]*liveHistogram
+	// events whose latencies were recently observed, by latency, as both their
+	// ends may be recorded concurrently, each then matching the other
+	observed map[string]*submissionCache
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// newLiveMetrics listens for the scrapes of the metrics of the aggregator on
+// the given address.
+func newLiveMetrics(ag *Aggregator, addr string) (*liveMetrics, error) {
+	m := &liveMetrics{
+		ag:        ag,
+		recorded:  make(map[string]uint64),
+		latencies: map[string]*liveHistogram{"publish": newLiveHistogram(), "e2e": newLiveHistogram()},
+		observed:  map[string]*submissionCache{"publish": newSubmissionCache(streamedTTL), "e2e": newSubmissionCache(streamedTTL)},
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the live metrics listener: %v", err)
+	}
+	m.listener = l
+	mux := http.NewServeMux()
+	mux.HandleFunc(liveMetricsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := m.write(w); err != nil {
+			log.Printf("ERROR writing the live metrics: %v", err)
+		}
+	})
+	m.server = &http.Server{Handler: mux}
+	return m, nil
+}
+
+// observe counts the given newly recorded events of the given type, and
+// observes the latencies of those whose counterparts are already recorded. It
+// must be called without holding the lock of the events records.
+func (m *liveMetrics) observe(recType pb.EventsRecord_Type, added []matchedEvent) {
+	if len(added) == 0 {
+		return
+	}
+	m.Lock()
+	m.recorded[strings.ToLower(recType.String())] += uint64(len(added))
+	m.Unlock()
+
+	ag := m.ag
+	var publish, e2e []latencyMatch
+	switch recType {
+	case pb.EventsRecord_SENT:
+		publish = matchAdded(ag.acceptedEvents, added, true)
+		e2e = matchAdded(ag.receivedEvents, added, true)
+	case pb.EventsRecord_ACCEPTED:
+		publish = matchAdded(ag.sentEvents, added, false)
+	case pb.EventsRecord_RECEIVED:
+		e2e = matchAdded(ag.sentEvents, added, false)
+	}
+	m.observeLatencies("publish", publish)
+	m.observeLatencies("e2e", e2e)
+}
+
+func (m *liveMetrics) observeLatencies(latency string, matches []latencyMatch) {
+	if len(matches) == 0 {
+		return
+	}
+	h := m.latencies[latency]
+	m.Lock()
+	defer m.Unlock()
+	observed := m.observed[latency]
+	for _, match := range matches {
+		// matched at most twice, so forgotten once its duplicate is ignored
+		if observed.seen(match.id) {
+			observed.forget(match.id)
+			continue
+		}
+		h.observe(match.to.Sub(match.from).Seconds())
+	}
+}
+
+// pendingEvents returns the number of events recorded in from but not in to yet.
+func pendingEvents(from, to *eventsRecord) int {
+	from.RLock()
+	n := len(from.Events)
+	from.RUnlock()
+	to.RLock()
+	n -= len(to.Events)
+	to.RUnlock()
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// write writes the metrics in the Prometheus text format.
+func (m *liveMetrics) write(w io.Writer) error {
+	ag := m.ag
+	bw := bufio.NewWriter(w)
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	m.Lock()
+	metric("aggregator_events_recorded_total", "counter", "Number of distinct events recorded, by type of record.")
+	for _, t := range []string{"sent", "accepted", "received"} {
+		fmt.Fprintf(bw, "aggregator_events_recorded_total{type=%q} %d\n", t, m.recorded[t])
+	}
+	metric("aggregator_latency_seconds", "histogram", "Publish and end to end latencies of the events matched so far.")
+	for _, latency := range []string{"publish", "e2e"} {
+		h := m.latencies[latency]
+		var cumulative uint64
+		for i, le := range liveLatencyBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(bw, "aggregator_latency_seconds_bucket{latency=%q,le=\"%g\"} %d\n", latency, le, cumulative)
+		}
+		fmt.Fprintf(bw, "aggregator_latency_seconds_bucket{latency=%q,le=\"+Inf\"} %d\n", latency, h.count)
+		fmt.Fprintf(bw, "aggregator_latency_seconds_sum{latency=%q} %g\n", latency, h.sum)
+		fmt.Fprintf(bw, "aggregator_latency_seconds_count{latency=%q} %d\n", latency, h.count)
+	}
+	m.Unlock()
+
+	metric("aggregator_unaccepted_events", "gauge", "Number of sent events not accepted yet, the publish failures once the run is complete.")
+	fmt.Fprintf(bw, "aggregator_unaccepted_events %d\n", pendingEvents(ag.sentEvents, ag.acceptedEvents))
+	metric("aggregator_undelivered_events", "gauge", "Number of accepted events not received yet, the delivery failures once the run is complete.")
+	fmt.Fprintf(bw, "aggregator_undelivered_events %d\n", pendingEvents(ag.acceptedEvents, ag.receivedEvents))
+	metric("aggregator_abandoned_events_total", "counter", "Number of accepted or received events without sent event expired after the unmatched TTL.")
+	fmt.Fprintf(bw, "aggregator_abandoned_events_total %d\n", atomic.LoadUint64(&ag.abandoned))
+	complete := 0
+	select {
+	case <-ag.recordsComplete:
+		complete = 1
+	default:
+	}
+	metric("aggregator_records_complete", "gauge", "Whether all the expected events records were received.")
+	fmt.Fprintf(bw, "aggregator_records_complete %d\n", complete)
+	return bw.Flush()
+}
+
+// serve serves the live metrics until shutdown.
+func (m *liveMetrics) serve() {
+	log.Printf("Serving the live metrics on http://%s%s", m.listener.Addr(), liveMetricsPath)
+	if err := m.server.Serve(m.listener); err != nil && err != http.ErrServerClosed {
+		log.Printf("ERROR serving the live metrics: %v", err)
+	}
+}
+
+// shutdown stops serving the live metrics, waiting for the scrapes in flight
+// for up to the given timeout.
+func (m *liveMetrics) shutdown(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := m.server.Shutdown(ctx); err != nil {
+		log.Printf("ERROR stopping the live metrics server: %v", err)
+	}
+}
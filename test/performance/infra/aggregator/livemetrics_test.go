@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// scrapeLiveMetrics returns the live metrics of the aggregator.
+func scrapeLiveMetrics(t *testing.T, ag *Aggregator) string {
+	t.Helper()
+	resp, err := http.Get("http://" + ag.live.listener.Addr().String() + liveMetricsPath)
+	if err != nil {
+		t.Fatal("Failed to scrape the live metrics:", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("Failed to read the live metrics:", err)
+	}
+	return string(body)
+}
+
+func TestLiveMetrics(t *testing.T) {
+	ag := newTestAggregator(t, 3, WithLiveMetrics("127.0.0.1:0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a", "b", "c"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_ACCEPTED, now.Add(3*time.Millisecond), "a", "b"),
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(20*time.Millisecond), "a"),
+	}})
+
+	metrics := scrapeLiveMetrics(t, ag)
+	for _, want := range []string{
+		`aggregator_events_recorded_total{type="sent"} 3`,
+		`aggregator_events_recorded_total{type="accepted"} 2`,
+		`aggregator_events_recorded_total{type="received"} 1`,
+		`aggregator_latency_seconds_bucket{latency="publish",le="0.002"} 0`,
+		`aggregator_latency_seconds_bucket{latency="publish",le="0.004"} 2`,
+		`aggregator_latency_seconds_count{latency="publish"} 2`,
+		`aggregator_latency_seconds_bucket{latency="e2e",le="0.016"} 0`,
+		`aggregator_latency_seconds_bucket{latency="e2e",le="0.032"} 1`,
+		`aggregator_latency_seconds_bucket{latency="e2e",le="+Inf"} 1`,
+		"aggregator_unaccepted_events 1",
+		"aggregator_undelivered_events 1",
+		"aggregator_records_complete 0",
+		"# TYPE aggregator_latency_seconds histogram",
+	} {
+		if !strings.Contains(metrics, want+"\n") {
+			t.Errorf("live metrics lack %q:\n%s", want, metrics)
+		}
+	}
+
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(20*time.Millisecond), "a"),
+	}})
+	waitForRun(t, done)
+
+	if _, err := http.Get("http://" + ag.live.listener.Addr().String() + liveMetricsPath); err == nil {
+		t.Error("The live metrics are still served once the run is complete")
+	}
+}
+
+func TestLiveMetricsForgetObservedEvents(t *testing.T) {
+	m := &liveMetrics{
+		latencies: map[string]*liveHistogram{"e2e": newLiveHistogram()},
+		observed:  map[string]*submissionCache{"e2e": newSubmissionCache(streamedTTL)},
+	}
+	observed := m.observed["e2e"]
+	now := time.Now()
+	observed.now = func() time.Time { return now }
+
+	// matched twice, when recorded concurrently
+	match := func(id string) latencyMatch {
+		return latencyMatch{id: id, from: now, to: now.Add(time.Millisecond)}
+	}
+	m.observeLatencies("e2e", []latencyMatch{match("1"), match("2")})
+	m.observeLatencies("e2e", []latencyMatch{match("1")})
+	if n := m.latencies["e2e"].count; n != 2 {
+		t.Fatalf("observed %d latencies, want 2", n)
+	}
+	if _, ok := observed.current["1"]; ok {
+		t.Error("event 1 still remembered once its duplicate was ignored")
+	}
+
+	// the events matched once are forgotten after twice the TTL
+	now = now.Add(2 * streamedTTL)
+	m.observeLatencies("e2e", []latencyMatch{match("3")})
+	if remembered := len(observed.current) + len(observed.previous); remembered != 1 {
+		t.Errorf("remembered %d observed events, want only the last one", remembered)
+	}
+}
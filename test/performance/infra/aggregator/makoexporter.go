@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+
+	tpb "github.com/google/mako/clients/proto/analyzers/threshold_analyzer_go_proto"
+	"knative.dev/pkg/test/mako"
+)
+
+// makoExporter publishes the results to the Mako sidecar.
+type makoExporter struct {
+	client *mako.Client
+}
+
+var _ Exporter = (*makoExporter)(nil)
+
+// newMakoExporter connects to the Mako sidecar, tagging the run with the given
+// tags, and adds the threshold analyzers detecting performance regressions.
+func newMakoExporter(ctx context.Context, tags []string, analyzers []*tpb.ThresholdAnalyzerInput) (*makoExporter, error) {
+	client, err := mako.Setup(ctx, tags...)
+	if err != nil {
+		return nil, err
+	}
+	client.Quickstore.Input.ThresholdInputs = append(client.Quickstore.Input.ThresholdInputs, analyzers...)
+	return &makoExporter{client: client}, nil
+}
+
+func (e *makoExporter) Name() string {
+	return "mako"
+}
+
+func (e *makoExporter) AddSample(xval float64, values map[string]float64) error {
+	return e.client.Quickstore.AddSamplePoint(xval, values)
+}
+
+func (e *makoExporter) AddError(xval float64, message string) error {
+	return e.client.Quickstore.AddError(xval, message)
+}
+
+func (e *makoExporter) AddAggregate(valueKey string, aggregateType string, value float64) error {
+	if aggregateType == "" {
+		return e.client.Quickstore.AddRunAggregate(valueKey, value)
+	}
+	return e.client.Quickstore.AddMetricAggregate(valueKey, aggregateType, value)
+}
+
+func (e *makoExporter) AddTags(tags ...string) {
+	e.client.Quickstore.Input.Tags = append(e.client.Quickstore.Input.Tags, tags...)
+}
+
+// Flush stores the results and fails if the threshold analyzers detected a
+// regression.
+func (e *makoExporter) Flush(context.Context) error {
+	return e.client.StoreAndHandleResult()
+}
+
+// Close terminates the sidecar.
+func (e *makoExporter) Close(ctx context.Context) {
+	e.client.ShutDownFunc(ctx)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"runtime"
+	"time"
+)
+
+// memStatsInterval is how often the memory statistics of the aggregator are sampled.
+const memStatsInterval = time.Second
+
+// memoryStats are the memory statistics of the aggregator process during the run.
+type memoryStats struct {
+	peakHeapBytes uint64
+	gcPause       time.Duration
+	gcCount       uint32
+}
+
+// memStatsSampler periodically samples runtime.MemStats, tracking the peak heap
+// and the GC pauses since it was started.
+type memStatsSampler struct {
+	interval time.Duration
+	stop     chan struct{}
+	done     chan memoryStats
+}
+
+// startMemStatsSampler starts sampling the memory statistics every interval,
+// in a goroutine spawned by the aggregator.
+func (ag *Aggregator) startMemStatsSampler(interval time.Duration) *memStatsSampler {
+	s := &memStatsSampler{
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan memoryStats, 1),
+	}
+
+	var start runtime.MemStats
+	runtime.ReadMemStats(&start)
+
+	ag.spawn(func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		var m runtime.MemStats
+		peak := start.HeapAlloc
+		for {
+			select {
+			case <-ticker.C:
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > peak {
+					peak = m.HeapAlloc
+				}
+			case <-s.stop:
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > peak {
+					peak = m.HeapAlloc
+				}
+				s.done <- memoryStats{
+					peakHeapBytes: peak,
+					gcPause:       time.Duration(m.PauseTotalNs - start.PauseTotalNs),
+					gcCount:       m.NumGC - start.NumGC,
+				}
+				return
+			}
+		}
+	})
+	return s
+}
+
+// finish stops the sampling and returns the memory statistics of the run.
+func (s *memStatsSampler) finish() memoryStats {
+	close(s.stop)
+	return <-s.done
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+var memStatsSink [][]byte
+
+func TestMemStatsSampler(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	ag.listener.Close()
+
+	s := ag.startMemStatsSampler(time.Millisecond)
+
+	// allocate 64MiB, kept alive until the sampler is stopped
+	for i := 0; i < 64; i++ {
+		memStatsSink = append(memStatsSink, make([]byte, 1<<20))
+	}
+	time.Sleep(10 * time.Millisecond)
+	runtime.GC()
+
+	stats := s.finish()
+	memStatsSink = nil
+
+	if stats.peakHeapBytes < 64<<20 {
+		t.Errorf("peak heap = %d bytes, want at least 64MiB", stats.peakHeapBytes)
+	}
+	if stats.gcCount == 0 || stats.gcPause <= 0 {
+		t.Errorf("GC count, pause = %d, %v, want at least the forced GC", stats.gcCount, stats.gcPause)
+	}
+
+	// the sampling goroutine is terminated
+	if err := ag.verifyTeardown(); err != nil {
+		t.Error("verifyTeardown() =", err)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// isLatencySamplesFile tells whether the file at the given path is a latency
+// samples file rather than a summary.
+func isLatencySamplesFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	magic := make([]byte, len(latencySamplesMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		// too short for a latency samples file
+		return false, nil
+	}
+	return string(magic) == latencySamplesMagic, nil
+}
+
+// MergeSummaries combines the results of several aggregator shards, each
+// collecting the records of a subset of the senders, from their summary files,
+// written with WithSummaryExport, and their latency samples files, written with
+// WithLatencySamplesExport, given in any order. The counts are summed and the
+// failure rates recomputed from them. Percentiles can't be merged from the
+// percentiles of the shards, so the global ones are computed over the latency
+// samples of all the shards, and left unset unless each shard's are given.
+// The throughputs of the shards, running concurrently, are summed.
+func MergeSummaries(paths []string) (RunSummary, error) {
+	var summaries []*RunSummary
+	var publish, e2e []time.Duration
+	samplesFiles := 0
+	for _, path := range paths {
+		samples, err := isLatencySamplesFile(path)
+		if err != nil {
+			return RunSummary{}, err
+		}
+		if samples {
+			p, e, err := LoadLatencySamples(path)
+			if err != nil {
+				return RunSummary{}, err
+			}
+			publish, e2e = append(publish, p...), append(e2e, e...)
+			samplesFiles++
+			continue
+		}
+		s, err := loadRunSummary(path)
+		if err != nil {
+			return RunSummary{}, err
+		}
+		summaries = append(summaries, s)
+	}
+	if len(summaries) == 0 {
+		return RunSummary{}, fmt.Errorf("no summary among %d files", len(paths))
+	}
+
+	merged := RunSummary{Scenario: summaries[0].Scenario, Passed: true}
+	runIDs := make(map[string]struct{})
+	// the percentiles reported by any of the shards
+	percentiles := make(map[float64]struct{})
+	for _, p := range reportedPercentiles {
+		percentiles[p] = struct{}{}
+	}
+	for _, s := range summaries {
+		if s.Scenario != merged.Scenario {
+			return RunSummary{}, fmt.Errorf("can't merge the summaries of the scenarios %q and %q", merged.Scenario, s.Scenario)
+		}
+		runIDs[s.RunID] = struct{}{}
+		merged.Sent += s.Sent
+		merged.Accepted += s.Accepted
+		merged.Received += s.Received
+		merged.PublishFailures += s.PublishFailures
+		merged.DeliveryFailures += s.DeliveryFailures
+		// the shards collect the records of concurrent senders
+		merged.Throughput += s.Throughput
+		merged.Passed = merged.Passed && s.Passed
+		merged.Partial = merged.Partial || s.Partial
+		merged.MissingRecords += s.MissingRecords
+		merged.Shards = append(merged.Shards, s.Shards...)
+		// the latencies by payload size of the shards can't be merged into
+		// global percentiles, and are left out
+		for p := range s.E2ELatencies {
+			percentiles[p] = struct{}{}
+		}
+	}
+	ids := make([]string, 0, len(runIDs))
+	for id := range runIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	merged.RunID = strings.Join(ids, "+")
+	if merged.Sent > 0 {
+		merged.PublishFailureRate = float64(merged.PublishFailures) / float64(merged.Sent)
+	}
+	if merged.Accepted > 0 {
+		merged.DeliveryFailureRate = float64(merged.DeliveryFailures) / float64(merged.Accepted)
+	}
+
+	if samplesFiles != len(summaries) {
+		log.Printf("!! Got the latency samples of %d of the %d shards, the global percentiles can't be computed",
+			samplesFiles, len(summaries))
+		merged.LowSample = merged.Received < defaultMinSamplesForPercentiles
+		return merged, nil
+	}
+	sortDurations(publish)
+	sortDurations(e2e)
+	merged.LowSample = len(e2e) < defaultMinSamplesForPercentiles
+	if !merged.LowSample {
+		merged.PublishLatencies = make(map[float64]time.Duration, len(percentiles))
+		merged.E2ELatencies = make(map[float64]time.Duration, len(percentiles))
+		for p := range percentiles {
+			if len(publish) > 0 {
+				merged.PublishLatencies[p] = percentile(publish, p)
+			}
+			merged.E2ELatencies[p] = percentile(e2e, p)
+		}
+	}
+	return merged, nil
+}
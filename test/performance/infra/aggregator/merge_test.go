@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeShard exports the summary of a shard whose events were all delivered
+// with the given end to end latency, and their latency samples if asked.
+func writeShard(t *testing.T, dir string, name string, scenario string, n int, latency time.Duration, samples bool) []string {
+	t.Helper()
+	e2e := make([]time.Duration, n)
+	for i := range e2e {
+		e2e[i] = latency
+	}
+	summary := &RunSummary{
+		RunID:            name,
+		Scenario:         scenario,
+		Passed:           true,
+		Sent:             n,
+		Accepted:         n,
+		Received:         n,
+		PublishLatencies: map[float64]time.Duration{50: time.Millisecond, 99: time.Millisecond},
+		E2ELatencies:     map[float64]time.Duration{50: latency, 99: latency},
+	}
+	paths := []string{filepath.Join(dir, name+".json")}
+	if err := exportRunSummary(paths[0], summary); err != nil {
+		t.Fatal("Failed to export the summary:", err)
+	}
+	if samples {
+		paths = append(paths, filepath.Join(dir, name+".bin"))
+		if err := exportLatencySamples(paths[1], e2e, e2e); err != nil {
+			t.Fatal("Failed to export the latency samples:", err)
+		}
+	}
+	return paths
+}
+
+func TestMergeSummaries(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeShard(t, dir, "a", "s", 150, time.Millisecond, true)
+	paths = append(paths, writeShard(t, dir, "b", "s", 50, 100*time.Millisecond, true)...)
+
+	merged, err := MergeSummaries(paths)
+	if err != nil {
+		t.Fatal("Failed to merge the summaries:", err)
+	}
+	if merged.RunID != "a+b" || merged.Scenario != "s" || !merged.Passed {
+		t.Errorf("merged run %q of scenario %q, passed %v, want a+b of s, passed", merged.RunID, merged.Scenario, merged.Passed)
+	}
+	if merged.Sent != 200 || merged.Received != 200 || merged.LowSample {
+		t.Errorf("merged %d sent and %d received, low sample %v, want 200, 200 and false", merged.Sent, merged.Received, merged.LowSample)
+	}
+	// averaging the p50 of the shards would give 50.5ms
+	if got, want := merged.E2ELatencies[50], time.Millisecond; got != want {
+		t.Errorf("global p50 = %v, want %v", got, want)
+	}
+	if got, want := merged.E2ELatencies[99], 100*time.Millisecond; got != want {
+		t.Errorf("global p99 = %v, want %v", got, want)
+	}
+}
+
+func TestMergeSummariesWithoutSamples(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeShard(t, dir, "a", "s", 150, time.Millisecond, true)
+	paths = append(paths, writeShard(t, dir, "b", "s", 50, 100*time.Millisecond, false)...)
+
+	merged, err := MergeSummaries(paths)
+	if err != nil {
+		t.Fatal("Failed to merge the summaries:", err)
+	}
+	if merged.Received != 200 {
+		t.Errorf("merged %d received, want 200", merged.Received)
+	}
+	if merged.E2ELatencies != nil || merged.PublishLatencies != nil {
+		t.Errorf("merged percentiles %v and %v without the samples of each shard, want none",
+			merged.PublishLatencies, merged.E2ELatencies)
+	}
+}
+
+func TestMergeSummariesScenarioMismatch(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeShard(t, dir, "a", "s1", 10, time.Millisecond, false)
+	paths = append(paths, writeShard(t, dir, "b", "s2", 10, time.Millisecond, false)...)
+
+	if _, err := MergeSummaries(paths); err == nil {
+		t.Error("merged the summaries of different scenarios, want an error")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// monotonicLatency is the time between the event being sent and being
+// received, by the monotonic clock, which isn't affected by clock adjustments.
+func (e *event) monotonicLatency() time.Duration {
+	return time.Duration(e.receivedMonotonic - e.sentMonotonic)
+}
+
+// monotonicLatencies returns the sorted monotonic end to end latencies of the
+// received events sent and received with the same monotonic clock, and the
+// sorted discrepancies of their wall clock latencies from them.
+func monotonicLatencies(events []event) (monotonic []time.Duration, discrepancies []time.Duration) {
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived || !e.hasMonotonic {
+			continue
+		}
+		monotonic = append(monotonic, e.monotonicLatency())
+		discrepancies = append(discrepancies, e.e2eLatency()-e.monotonicLatency())
+	}
+	sortDurations(monotonic)
+	sortDurations(discrepancies)
+	return monotonic, discrepancies
+}
+
+// reportMonotonicLatencies logs the distributions of the monotonic end to end
+// latencies and of the discrepancies of the wall clock ones, and returns the
+// median discrepancy as the estimate of the clock skew.
+func reportMonotonicLatencies(monotonic, discrepancies []time.Duration, delivered int) *time.Duration {
+	log.Printf("%d of the %d delivered events were sent and received with the same monotonic clock", len(monotonic), delivered)
+	reportDistribution("Monotonic end to end latency", "", monotonic)
+	reportDistribution("Wall clock minus monotonic end to end latency", "", discrepancies)
+	skew := percentile(discrepancies, 50)
+	log.Printf("Clock skew estimate: %v", skew)
+	return &skew
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestMonotonicLatencies(t *testing.T) {
+	const (
+		latency = 10 * time.Millisecond
+		// the wall clock of the sender is ahead by skew
+		skew = time.Second
+	)
+	ag := newTestAggregator(t, 0)
+	start := time.Now()
+
+	sent := newRecord(pb.EventsRecord_SENT, start.Add(skew), "paired", "other-clock", "unpaired")
+	sent.Monotonic = map[string]int64{"paired": 1000, "other-clock": 1000}
+	recordEvents(t, ag, &pb.EventsRecordList{MonotonicClock: "pod-a", Items: []*pb.EventsRecord{sent}})
+
+	received := newRecord(pb.EventsRecord_RECEIVED, start.Add(latency), "paired", "unpaired")
+	received.Monotonic = map[string]int64{"paired": 1000 + int64(latency), "unpaired": 1000 + int64(latency)}
+	recordEvents(t, ag, &pb.EventsRecordList{MonotonicClock: "pod-a", Items: []*pb.EventsRecord{received}})
+
+	otherClock := newRecord(pb.EventsRecord_RECEIVED, start.Add(latency), "other-clock")
+	otherClock.Monotonic = map[string]int64{"other-clock": 5}
+	recordEvents(t, ag, &pb.EventsRecordList{MonotonicClock: "pod-b", Items: []*pb.EventsRecord{otherClock}})
+
+	monotonic, discrepancies := monotonicLatencies(ag.joinEvents())
+	if len(monotonic) != 1 || monotonic[0] != latency {
+		t.Fatalf("monotonic latencies = %v, want [%v]", monotonic, latency)
+	}
+	if want := -skew; discrepancies[0] != want {
+		t.Errorf("wall clock minus monotonic latency = %v, want %v", discrepancies[0], want)
+	}
+
+	a := runAggregates{
+		e2eLatencies: latencies(1),
+		skewEstimate: reportMonotonicLatencies(monotonic, discrepancies, 3),
+	}
+	q := newFakeAggregatesStore()
+	a.publish(q)
+	if got, want := q.run["sk"], (-skew).Seconds(); got != want {
+		t.Errorf("published skew estimate = %v, want %v", got, want)
+	}
+}
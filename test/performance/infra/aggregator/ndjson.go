@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// ndjsonStdout is the NDJSON export path writing to the standard output.
+const ndjsonStdout = "-"
+
+// ndjsonEvent is the JSON object written for each event. Timestamps and
+// latencies are omitted when the event didn't reach the corresponding step.
+type ndjsonEvent struct {
+	ID        string  `json:"id"`
+	Scenario  string  `json:"scenario,omitempty"`
+	Partition *string `json:"partition,omitempty"`
+	Sequence  *uint64 `json:"sequence,omitempty"`
+	Sender    string  `json:"sender,omitempty"`
+	Receiver  string  `json:"receiver,omitempty"`
+
+	Sent     time.Time  `json:"sent"`
+	Accepted *time.Time `json:"accepted,omitempty"`
+	Received *time.Time `json:"received,omitempty"`
+
+	PublishLatencyNs *int64 `json:"publishLatencyNs,omitempty"`
+	E2ELatencyNs     *int64 `json:"e2eLatencyNs,omitempty"`
+
+	PublishFailed  bool `json:"publishFailed"`
+	DeliveryFailed bool `json:"deliveryFailed"`
+}
+
+func newNDJSONEvent(e *event) *ndjsonEvent {
+	out := &ndjsonEvent{
+		ID:             e.id,
+		Sender:         e.sender,
+		Receiver:       e.receiver,
+		Sent:           e.sent,
+		PublishFailed:  e.outcome() == outcomePublishFailure,
+		DeliveryFailed: e.outcome() == outcomeDeliveryFailure,
+	}
+	if e.key != nil {
+		partition, sequence := e.key.GetPartition(), e.key.GetSequence()
+		out.Partition, out.Sequence = &partition, &sequence
+	}
+	if e.isAccepted {
+		accepted, latency := e.accepted, e.publishLatency().Nanoseconds()
+		out.Accepted, out.PublishLatencyNs = &accepted, &latency
+	}
+	if e.isReceived {
+		received, latency := e.received, e.e2eLatency().Nanoseconds()
+		out.Received, out.E2ELatencyNs = &received, &latency
+	}
+	return out
+}
+
+// ndjsonWriter streams one JSON object per line for each event it is given.
+// After the first error, it logs it and ignores the following events.
+type ndjsonWriter struct {
+	bw     *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+	err    error
+
+	// scenario of the events, if any
+	scenario string
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+// createNDJSONWriter opens the NDJSON export at the given path, or the
+// standard output for ndjsonStdout, labelling the events with the scenario, if any.
+func createNDJSONWriter(path string, scenario string) (*ndjsonWriter, error) {
+	var w *ndjsonWriter
+	if path == ndjsonStdout {
+		w = newNDJSONWriter(os.Stdout)
+	} else {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", path, err)
+		}
+		w = newNDJSONWriter(f)
+		w.closer = f
+	}
+	w.scenario = scenario
+	return w, nil
+}
+
+// write streams the given event, unless a previous write failed.
+func (w *ndjsonWriter) write(e *event) {
+	if w.err != nil {
+		return
+	}
+	out := newNDJSONEvent(e)
+	out.Scenario = w.scenario
+	if w.err = w.enc.Encode(out); w.err != nil {
+		log.Printf("ERROR writing the NDJSON events: %v", w.err)
+	}
+}
+
+// close flushes the buffered events, and closes the file of the export, if any.
+func (w *ndjsonWriter) close() error {
+	if w.err == nil {
+		w.err = w.bw.Flush()
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	sent := time.Unix(1000, 0).UTC()
+	events := []event{
+		{id: "delivered", sender: "s", receiver: "r", sent: sent, accepted: sent.Add(time.Millisecond), received: sent.Add(5 * time.Millisecond), isAccepted: true, isReceived: true},
+		{id: "lost", sender: "s", sent: sent, accepted: sent.Add(2 * time.Millisecond), isAccepted: true},
+		{id: "rejected", sender: "s", sent: sent, key: &pb.EventKey{Partition: "p0", Sequence: 7}},
+	}
+
+	var buf bytes.Buffer
+	w := newNDJSONWriter(&buf)
+	for i := range events {
+		w.write(&events[i])
+	}
+	if err := w.close(); err != nil {
+		t.Fatal("close() =", err)
+	}
+
+	want := []map[string]interface{}{{
+		"id": "delivered", "sender": "s", "receiver": "r",
+		"sent": "1970-01-01T00:16:40Z", "accepted": "1970-01-01T00:16:40.001Z", "received": "1970-01-01T00:16:40.005Z",
+		"publishLatencyNs": 1e6, "e2eLatencyNs": 5e6, "publishFailed": false, "deliveryFailed": false,
+	}, {
+		"id": "lost", "sender": "s",
+		"sent": "1970-01-01T00:16:40Z", "accepted": "1970-01-01T00:16:40.002Z",
+		"publishLatencyNs": 2e6, "publishFailed": false, "deliveryFailed": true,
+	}, {
+		"id": "rejected", "partition": "p0", "sequence": 7.0, "sender": "s",
+		"sent": "1970-01-01T00:16:40Z", "publishFailed": true, "deliveryFailed": false,
+	}}
+
+	scanner := bufio.NewScanner(&buf)
+	var line int
+	for ; scanner.Scan(); line++ {
+		var got map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v: %s", line, err, scanner.Text())
+		}
+		if line >= len(want) {
+			continue
+		}
+		if len(got) != len(want[line]) {
+			t.Errorf("line %d has fields %v, want %v", line, got, want[line])
+		}
+		for k, v := range want[line] {
+			if got[k] != v {
+				t.Errorf("line %d field %s = %v, want %v", line, k, got[k], v)
+			}
+		}
+	}
+	if line != len(want) {
+		t.Errorf("got %d lines, want %d", line, len(want))
+	}
+}
@@ -0,0 +1,780 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import "time"
+
+// AggregatorOption configures an optional behavior of the Aggregator.
+type AggregatorOption func(*Aggregator)
+
+// WithWorstSenderReport enables the report of the senders with the most
+// failures and the highest tail latency.
+func WithWorstSenderReport(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportWorstSender = enabled
+	}
+}
+
+// WithQueueingDelayReport enables the report of the distribution of the delays
+// between events being accepted and being received.
+func WithQueueingDelayReport(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportQueueingDelay = enabled
+	}
+}
+
+// WithSenderQueueingReport enables the report of the distributions of the
+// delays the events waited in their senders before going on the wire, and of
+// the end to end latencies net of them, for the senders reporting it.
+func WithSenderQueueingReport(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportSenderQueueing = enabled
+	}
+}
+
+// WithBimodalityDetection enables the detection of bimodal end to end latency
+// distributions, publishing the two modes when one is detected.
+func WithBimodalityDetection(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.detectBimodality = enabled
+	}
+}
+
+// WithTeardownCheck makes the aggregator verify, once done, that the GRPC
+// server is stopped, its listener closed and all its goroutines terminated,
+// failing otherwise.
+func WithTeardownCheck(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.checkTeardown = enabled
+	}
+}
+
+// WithMaxRecvMsgSize sets the maximum size in bytes of the events record lists
+// the aggregator accepts.
+func WithMaxRecvMsgSize(size int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.maxRecvMsgSize = size
+	}
+}
+
+// WithCompositeKeyMatching makes the aggregator match the sent, accepted and
+// received events on their (partition, sequence) composite key rather than
+// on their ID.
+func WithCompositeKeyMatching(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.compositeKeys = enabled
+	}
+}
+
+// WithSQLite makes the aggregator write the joined events to the indexed
+// events table of a SQLite database created at the given path, for ad-hoc
+// querying. It's only supported on Linux, the only platform of the driver.
+func WithSQLite(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.sqlitePath = path
+	}
+}
+
+// WithHeatmap makes the aggregator write to the given path the histograms of
+// the end to end latencies of the events sent in each window of the given duration.
+func WithHeatmap(path string, window time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.heatmapPath = path
+		ag.heatmapWindow = window
+	}
+}
+
+// WithClockSkewCases sets how many of the events accepted or received before
+// being sent are detailed, for each of the two types of records.
+func WithClockSkewCases(n int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.clockSkewCases = n
+	}
+}
+
+// WithFatalHandler replaces log.Fatalf as the handler of the unrecoverable errors
+// of the aggregator. When publishing results, the Mako sidecar is shut down before
+// calling it.
+func WithFatalHandler(fatalf func(format string, args ...interface{})) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.fatalf = fatalf
+	}
+}
+
+// WithMinSamplesForPercentiles sets the number of delivered events below which the
+// aggregator doesn't publish percentiles, flagging the run with the low-sample aggregate instead.
+func WithMinSamplesForPercentiles(n int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.minSamplesForPercentiles = n
+	}
+}
+
+// WithRunID tags the results published to Mako with the given run ID, and inserts
+// it in the names of the exported files, so that the results of the runs of a
+// campaign don't collide. A run ID is generated if none is given.
+func WithRunID(runID string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.runID = runID
+	}
+}
+
+// WithAcceptanceRateReport enables the time series of the ratio of the events
+// sent during each window of the given duration which were accepted.
+func WithAcceptanceRateReport(window time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.acceptanceWindow = window
+	}
+}
+
+// WithMemoryReport enables the sampling of the memory statistics of the aggregator,
+// to report its peak heap and total GC pause time.
+func WithMemoryReport(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportMemory = enabled
+	}
+}
+
+// WithArrivalAnchoredLatency enables the computation of the end to end latencies
+// by the clock of the aggregator, correcting the timestamps of each sender and
+// receiver by the offset of its clock estimated from the arrival time of its
+// records, and the report of their difference from the uncorrected latencies.
+func WithArrivalAnchoredLatency(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		if enabled {
+			ag.clockOffsets = newClockOffsets()
+		} else {
+			ag.clockOffsets = nil
+		}
+	}
+}
+
+// WithCheckpoint checkpoints the events record lists recorded by the aggregator,
+// the registrations, the marked windows, the clock offsets and the finish
+// requests to the given file, e.g. on a mounted volume, writing each to disk
+// before acknowledging it, so that an aggregator restarted in the middle of a
+// run resumes it from the checkpoint. The checkpoint is removed once
+// the results are published. No checkpoint is kept if the path is empty.
+func WithCheckpoint(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.checkpointPath = path
+	}
+}
+
+// WithClockSync enables the correction of the clock skew between the senders
+// and the receivers: each of them is pinged the given number of times when it
+// syncs its clock, measuring the offset of its clock, and the received
+// timestamps of the events are moved to the clock of their sender before
+// computing the latencies. 0 disables it.
+func WithClockSync(rounds int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.clockSyncRounds = rounds
+	}
+}
+
+// WithTLS serves the events recorder over TLS with the given certificate and key.
+// If clientCAFile is set, the senders and receivers must present a client
+// certificate signed by one of the CAs it contains (mutual TLS).
+func WithTLS(certFile, keyFile, clientCAFile string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.tlsCertFile = certFile
+		ag.tlsKeyFile = keyFile
+		ag.tlsClientCAFile = clientCAFile
+	}
+}
+
+// WithDuplicateRateReport logs the duplicate rate of each type of events every
+// interval while waiting for the records, 0 disabling it.
+func WithDuplicateRateReport(interval time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.duplicateRateInterval = interval
+	}
+}
+
+// WithNDJSONExport streams one JSON object per event to the file at the given
+// path, or to the standard output for "-", as the events are processed.
+func WithNDJSONExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.ndjsonPath = path
+	}
+}
+
+// WithCSVExport writes one row per event, with its timestamps and latencies, to
+// the CSV file at the given path, as the events are processed.
+func WithCSVExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.csvPath = path
+	}
+}
+
+// WithParquetExport writes one row per event, with its timestamps, latencies
+// and failure flags, to the Parquet file at the given path, as the events are
+// processed.
+func WithParquetExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.parquetPath = path
+	}
+}
+
+// WithPercentileConfidenceIntervals enables the bootstrap confidence intervals
+// of the percentiles of the end to end latencies, estimated over the given
+// number of resamples, 0 disabling them.
+func WithPercentileConfidenceIntervals(resamples int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.bootstrapResamples = resamples
+	}
+}
+
+// WithLinger keeps the server running for the given duration once all the
+// expected records have been received, replying to the late records that they
+// arrived after completion and aren't counted.
+func WithLinger(linger time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.linger = linger
+	}
+}
+
+// WithExpectedSenders logs a warning when the number of distinct senders of
+// the sent events records differs from the given one, 0 disabling it.
+func WithExpectedSenders(n int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.expectSenders = n
+	}
+}
+
+// WithGoodputReport enables the publication of the throughput of the delivered
+// events by their sent time, next to the send throughput of all the events.
+func WithGoodputReport(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportGoodput = enabled
+	}
+}
+
+// WithLatencyAnomalyDetection flags the windows of the given duration whose p99
+// end to end latency exceeds by more than factor the median p99 latency of the
+// baselineWindows preceding windows.
+func WithLatencyAnomalyDetection(window time.Duration, baselineWindows int, factor float64) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.anomalyWindow = window
+		ag.anomalyBaselineWindows = baselineWindows
+		ag.anomalyFactor = factor
+	}
+}
+
+// WithPlotData writes the throughputs, latency percentiles and loss rate of
+// each window of the given duration as a whitespace-delimited data file to the
+// given path, ready to be plotted with gnuplot or matplotlib.
+func WithPlotData(path string, window time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.plotDataPath = path
+		ag.plotDataWindow = window
+	}
+}
+
+// WithReceivedCollapse sets how to collapse the multiple received timestamps of
+// an event, e.g. fanned out to several subscribers which aren't tracked
+// separately: keep the "first" or the "last" one, or keep "all" of them, each
+// counting in the end to end latencies. By default, the duplicates of the first
+// recorded timestamp are dropped.
+func WithReceivedCollapse(policy string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.receivedCollapse = policy
+	}
+}
+
+// WithSubmissionTTL sets how long the IDs of the submissions of records are
+// remembered to record the retries of an upload once. A retry arriving later
+// is recorded again, so the TTL must exceed the retry window of the uploads.
+func WithSubmissionTTL(ttl time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.submissionTTL = ttl
+	}
+}
+
+// WithDuplicateBatchWindow sets how long the events record lists are
+// remembered to ignore the ones whose records are identical to a list received
+// meanwhile, e.g. uploaded twice with different submission IDs, rather than
+// counting them twice towards the expected records. 0, the default, disables
+// the detection.
+func WithDuplicateBatchWindow(window time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.batchWindow = window
+	}
+}
+
+// WithThroughputSmoothing computes the throughput published at the time of each
+// event as a "rolling" average over the given lookback, or as an exponentially
+// weighted average ("ewma") with the lookback as time constant, instead of the
+// number of events during the preceding second, for a smoother series.
+func WithThroughputSmoothing(mode string, lookback time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.throughputSmoothing = throughputSmoothing{mode: mode, lookback: lookback}
+	}
+}
+
+// WithPhaseTable logs a table comparing the p50, p90 and p99 latencies of the
+// publish, delivery and end to end phases side by side.
+func WithPhaseTable(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportPhaseTable = enabled
+	}
+}
+
+// WithRecordDelay delays the handling of each events record list, and so the
+// reply, by the given duration, to simulate a slow aggregator when testing the
+// resilience of the senders and receivers.
+func WithRecordDelay(delay time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.recordDelay = delay
+	}
+}
+
+// WithSenderTable logs a table breaking down the sent and received counts, the
+// loss rate and the p50 and p99 end to end latencies by sender.
+func WithSenderTable(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportSenderTable = enabled
+	}
+}
+
+// WithDuplicateDeliveriesExport writes to the CSV file at the given path a
+// row for each delivery of the events received several times, with its time
+// and its delay after the first delivery of the event, to characterize the
+// redeliveries of at-least-once implementations. The number of duplicates of
+// each type of event is published regardless, as "dups", "dupa" and "dup" for
+// the sent, accepted and received ones.
+func WithDuplicateDeliveriesExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.duplicatesPath = path
+	}
+}
+
+// WithFailedEventsExport writes to the CSV file at the given path the events
+// which were not accepted or not received, with their sender, outcome and sent
+// and accepted times, so that the drops can be correlated with the logs of the
+// data plane. With "-", they are logged instead.
+func WithFailedEventsExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.failedEventsPath = path
+	}
+}
+
+// WithSequenceGapDetection reports the sequence numbers missing from the IDs of
+// the events sent or received, revealing events lost before being recorded. The
+// IDs must match the given pattern, with a "sequence" named group and
+// optionally a "partition" one, by default a sequence number optionally
+// prefixed by a partition and a dash. Events matched on their composite key
+// use its partition and sequence.
+func WithSequenceGapDetection(enabled bool, idPattern string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.detectSequenceGaps = enabled
+		ag.sequenceIDPattern = idPattern
+	}
+}
+
+// WithOrderingVerification verifies, for each partition, that the received
+// events were received in the order of their sequence numbers, publishing as
+// "oo" the number of out of order deliveries: the events received after an
+// event of their partition with a higher sequence number. The partition key
+// and the sequence number of the events are their composite key, or are set
+// by their sender in the sequences of the records.
+func WithOrderingVerification(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.verifyOrdering = enabled
+	}
+}
+
+// WithResultSink publishes the summary of the run to the given sink, in
+// addition to the exporter.
+func WithResultSink(sink ResultSink) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.resultSinks = append(ag.resultSinks, sink)
+	}
+}
+
+// WithExporter publishes the results of the run with the exporter of the
+// given kind instead of Mako: InfluxDB line protocol written to the output
+// file or posted to the output URL, or CSV files in the output directory.
+func WithExporter(kind string, output string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.exporter = kind
+		ag.exporterOutput = output
+	}
+}
+
+// WithStatsdSink publishes the summary of the run as statsd metrics prefixed
+// by prefix to the given UDP address, if set.
+func WithStatsdSink(address, prefix string) AggregatorOption {
+	return func(ag *Aggregator) {
+		if address != "" {
+			ag.resultSinks = append(ag.resultSinks, NewStatsdSink(address, prefix))
+		}
+	}
+}
+
+// WithWebhookSink posts the summary of the run and whether it passed as JSON
+// to the given URL once the run is done, if set.
+func WithWebhookSink(url string) AggregatorOption {
+	return func(ag *Aggregator) {
+		if url != "" {
+			ag.resultSinks = append(ag.resultSinks, NewWebhookSink(url))
+		}
+	}
+}
+
+// WithHighPercentiles logs and publishes the given percentiles of the end to
+// end latencies in addition to the p50, p90 and p99, e.g. 99.9 and 99.99 for
+// strict tail SLOs, warning when too few events were delivered to estimate
+// them meaningfully. They're published as "d" followed by their digits, e.g.
+// "d9999", which must be declared in the benchmark config, except the 99.9th,
+// published as "dl999" with the other latency percentiles.
+func WithHighPercentiles(percentiles ...float64) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.highPercentiles = percentiles
+	}
+}
+
+// WithEWMAThroughput publishes, alongside the send and deliver throughputs, their
+// exponentially weighted averages as "ste" and "dte", keeping the given fraction
+// (0 < decay < 1) of the rate estimate after one second. A higher decay factor
+// smooths more, reacting slower to rate changes.
+func WithEWMAThroughput(decay float64) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.ewmaDecay = decay
+	}
+}
+
+// WithScenario labels the results of this aggregator with the given scenario,
+// made of letters, digits and underscores, e.g. "1kb_fanout10": it tags them in
+// Mako, names the exported files and is included in their metadata and in the
+// run summary. With suffixKeys, it is also suffixed to the published value
+// keys, e.g. "dl_1kb_fanout10", so that the scenarios reporting to the same
+// benchmark don't blend on the dashboard, which must declare these keys.
+func WithScenario(scenario string, suffixKeys bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.scenario = scenario
+		ag.scenarioSuffixKeys = suffixKeys
+	}
+}
+
+// WithDegenerateRunSkip skips publishing the latencies of the degenerate runs,
+// in which no event was delivered, publishing the "dg" (degenerate) marker
+// instead, along with the error counts.
+func WithDegenerateRunSkip(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.skipDegenerate = enabled
+	}
+}
+
+// WithUnmatchedTTL expires the accepted and received events still without sent
+// event after the given duration since their arrival, e.g. because their sender
+// crashed before uploading its records, to bound the memory of the aggregator
+// on long runs. The expired events are counted as abandoned and published as
+// the "ab" aggregate. In streaming aggregation, the sent events not accepted
+// or received after the TTL are also folded as failed as they expire. 0 keeps
+// them until the end of the run.
+func WithUnmatchedTTL(ttl time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.unmatchedTTL = ttl
+	}
+}
+
+// WithTimeline writes the steps and the failures of all the events as a single
+// stream sorted by time to the given path, one JSON object per line tagged
+// with its type, for timeline tooling.
+func WithTimeline(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.timelinePath = path
+	}
+}
+
+// WithPercentileSampleCap computes the latencies over the first n delivered
+// events by sent time only, or all of them if fewer, so that the percentiles of
+// runs of different lengths can be compared. The capped results are tagged with
+// the cap and publish the number of events used as the "sc" aggregate. 0
+// computes them over all the delivered events.
+func WithPercentileSampleCap(n int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.percentileSampleCap = n
+	}
+}
+
+// WithLatencyCDF writes the end to end latency CDF of the run to the given
+// path, at every percentile from p1 to p99 and at p99.9 and p99.99, so that it
+// can be compared with by later runs.
+func WithLatencyCDF(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.cdfPath = path
+	}
+}
+
+// WithHDRHistogramExport writes the percentile distribution of the end to end
+// latencies of the run to the given path in the text format of HdrHistogram,
+// within 0.1%, so that the tail latencies can be plotted with its tools. In
+// streaming aggregation, it is written from the histogram the latencies are
+// recorded in rather than from every latency.
+func WithHDRHistogramExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.hdrHistogramPath = path
+	}
+}
+
+// WithReferenceCDF compares the end to end latencies of the run with the CDF
+// of a reference run, as written by WithLatencyCDF, logging the delta at each
+// of its percentiles. The percentiles slower or faster than the reference by
+// more than the given relative tolerance, e.g. 0.1 for 10%, are flagged as
+// regressions or improvements.
+func WithReferenceCDF(path string, tolerance float64) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.referenceCDFPath = path
+		ag.referenceCDFTolerance = tolerance
+	}
+}
+
+// WithLatencyStream matches the sent and received events as they are recorded,
+// streaming their end to end latency to the subscribers of StreamLatencies,
+// e.g. for a live view of the latency tail. Up to buffer samples are buffered
+// for each subscriber, beyond which they are dropped and counted in the next
+// sample, so that a slow subscriber doesn't hold the recording of the events.
+func WithLatencyStream(enabled bool, buffer int) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.streamLatencies = enabled
+		ag.latencyStreamBuffer = buffer
+	}
+}
+
+// WithRecordsTimeout computes the results with the events records received so
+// far once the given timeout since the aggregator started waiting for them is
+// over, e.g. if a sender crashed and will never upload its records, instead of
+// waiting for them indefinitely. The summary of the run is then partial, and
+// the number of missing records is published as "mr". 0 waits indefinitely.
+func WithRecordsTimeout(timeout time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.recordsTimeout = timeout
+	}
+}
+
+// WithExclusionWindows excludes from the results the events sent during the
+// first warmup and the last cooldown of the run, from the first to the last
+// event sent, as "warmup" and "cooldown" windows. Unlike the other marked
+// windows, the events of the warmup and cooldown windows, including the ones
+// marked by the senders, are excluded from the published throughputs too. A
+// zero duration excludes nothing.
+func WithExclusionWindows(warmup, cooldown time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.warmupExclusion = warmup
+		ag.cooldownExclusion = cooldown
+	}
+}
+
+// WithStreamingAggregation folds the events into statistics of a fixed size as
+// soon as they are recorded as sent, accepted and received, and forgets them,
+// so that the memory of long runs is bounded by the events in flight and the
+// failed ones rather than by all of them. The results are then limited to the
+// counts, the failures, the latency percentiles, within 0.1%, and the
+// throughputs: the reports and exports of the individual events are skipped.
+func WithStreamingAggregation(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.streamingAggregation = enabled
+	}
+}
+
+// WithLiveMetrics serves the counts of the events recorded and of those not
+// accepted or received yet, and the histograms of the latencies of those
+// matched, at /metrics on the given address while the run is in progress, to
+// be scraped by Prometheus rather than waiting for the results in Mako.
+func WithLiveMetrics(addr string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.liveMetricsAddr = addr
+	}
+}
+
+// WithRecorderTimeReport measures the time spent merging each events record
+// list, waiting for the locks included, and logs and publishes its percentiles
+// as "rp50", "rp90" and "rp99": high recorder times mean the aggregator, rather
+// than the system under test, is the bottleneck.
+func WithRecorderTimeReport(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		if enabled {
+			ag.recorderTimes = &recorderTimer{}
+		} else {
+			ag.recorderTimes = nil
+		}
+	}
+}
+
+// WithSenderGini logs and publishes as "sg" the Gini coefficient of the number
+// of events sent by each sender, quantifying the load imbalance across the
+// senders with a single number: 0 when balanced, approaching 1 when a single
+// sender does everything. It's skipped with less than two identified senders.
+func WithSenderGini(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.reportSenderGini = enabled
+	}
+}
+
+// WithShardBreakdown logs the results of each sender shard, identified by the
+// source of its events records, and adds them to the run summary. It publishes
+// as "sti" and "sli" the imbalance of their throughputs and p99 end to end
+// latencies, the spread between the highest and the lowest relative to the
+// highest, to detect unbalanced load generation. The imbalance is skipped with
+// less than two identified shards.
+func WithShardBreakdown(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.shardBreakdown = enabled
+	}
+}
+
+// WithEventDeadline counts the events delivered more than the given duration
+// after being sent as deadline misses rather than successes, publishing their
+// number as "dm" and the ratio of the sent events delivered within the deadline
+// as "otr". 0 disables it.
+func WithEventDeadline(deadline time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.eventDeadline = deadline
+	}
+}
+
+// WithLatencySamplesExport dumps the sorted publish and end to end latencies
+// the percentiles are computed over to the given path, as raw little-endian
+// int64 nanoseconds after a header with their counts, for fast reloading with
+// LoadLatencySamples. The layout is described by latencySamplesHeader.
+func WithLatencySamplesExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.latencySamplesPath = path
+	}
+}
+
+// WithPeriodicityDetection looks for a periodic component in the p99 end to end
+// latencies by window of the given duration, e.g. spikes every minute from a
+// cron or GC cycle, and logs and publishes its period in seconds as "lps" and
+// its strength, the autocorrelation of the series at the period, as "lpc".
+// It's quadratic in the number of windows. 0 disables it.
+func WithPeriodicityDetection(window time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.periodicityWindow = window
+	}
+}
+
+// WithSummaryExport writes the summary of the run, as published to the result
+// sinks, as JSON to the given path, e.g. for MergeSummaries to combine the
+// results of several aggregator shards.
+func WithSummaryExport(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.summaryPath = path
+	}
+}
+
+// WithThroughputSeries writes the send, accept, receive and error throughputs,
+// as published to Mako, to the given path as a single file in the tidy format,
+// one row per timestamp, series name and value.
+func WithThroughputSeries(path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.throughputSeriesPath = path
+	}
+}
+
+// WithRecordBarrier makes Run wait, once the records are complete, finish is
+// requested, the timeout expires or the context is canceled, for all the
+// RecordEvents handlers in flight to merge their records before computing the
+// results, so that no record is lost to the shutdown, e.g. during the record delay.
+func WithRecordBarrier(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		if enabled {
+			ag.barrier = &recordBarrier{}
+		} else {
+			ag.barrier = nil
+		}
+	}
+}
+
+// WithShortSummary sets the format of the one-line summary of the run, by
+// default DefaultShortSummaryFormat, e.g. "p50:ms,p99:ms,loss:%,status", and
+// writes it to the given path, if any, e.g. to post it as a commit status.
+func WithShortSummary(format string, path string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.shortSummaryFormat = format
+		ag.shortSummaryPath = path
+	}
+}
+
+// WithTimeWeightedPercentiles reports and publishes as "tw50", "tw90" and
+// "tw99" the end to end latency percentiles with each event weighted by the
+// inverse of the send throughput when it was sent, so that steady and bursty
+// periods count as their duration rather than as their number of events.
+func WithTimeWeightedPercentiles(enabled bool) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.timeWeightedPercentiles = enabled
+	}
+}
+
+// WithGracefulStopTimeout sets how long stopping the GRPC server waits for the
+// RPCs in flight, 30s by default, before stopping it forcibly, so that the
+// aggregator terminates even if a client is wedged. 0 waits indefinitely.
+func WithGracefulStopTimeout(timeout time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.gracefulStopTimeout = timeout
+	}
+}
+
+// WithOutputDir exports all the files whose path isn't set to the given
+// directory, created if needed, with conventional names such as
+// "summary.json" or "events.ndjson", which include the run ID as usual.
+func WithOutputDir(dir string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.outputDir = dir
+	}
+}
+
+// WithTimeOfDayTable logs a table of the loss rate and the end to end latency
+// percentiles of the events by the bucket of the given duration of the period
+// they were sent in, e.g. by hour of the day, for long soak tests. The period
+// must be a multiple of the bucket, and starts at midnight UTC for a day.
+func WithTimeOfDayTable(bucket, period time.Duration) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.timeOfDayBucket = bucket
+		ag.timeOfDayPeriod = period
+	}
+}
+
+// WithAssertions sets conditions on the aggregates of the run, e.g.
+// "dl-p99 < 0.05", which must all hold for the run to pass: the aggregator
+// fails listing the ones which don't once the results are published. The blank
+// ones are ignored.
+func WithAssertions(assertions ...string) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.assertionSources = assertions
+	}
+}
+
+// WithSLOThresholds sets the limits the results of the run must stay within,
+// e.g. its p99 end to end latency, checked once the results are aggregated:
+// the violations are logged, whether the run failed is published as "fr", and
+// the aggregator fails once the results are published.
+func WithSLOThresholds(thresholds SLOThresholds) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.sloThresholds = thresholds
+	}
+}
+
+// WithStabilityWeights sets the weights of the loss rate, the coefficient of
+// variation of the end to end latency and the one of the throughput in the
+// stability score of the run, 1 each by default.
+func WithStabilityWeights(loss, latency, throughput float64) AggregatorOption {
+	return func(ag *Aggregator) {
+		ag.stabilityWeights = stabilityWeights{loss: loss, latency: latency, throughput: throughput}
+	}
+}
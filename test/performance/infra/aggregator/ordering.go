@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+)
+
+// outOfOrderCases is the number of partitions with the most out of order
+// deliveries logged.
+const outOfOrderCases = 10
+
+// partitionOrder is the outcome of the verification of the order in which the
+// events of a partition were received.
+type partitionOrder struct {
+	partition string
+	received  int
+	// number of events received after an event of the partition with a
+	// higher sequence number
+	outOfOrder int
+}
+
+// verifyOrdering checks, for each partition, that its received events were
+// received in the order of their sequence numbers, counting as out of order
+// each event received after an event of the same partition with a higher
+// sequence number. Events without partition key and sequence number are
+// ignored. It returns the partitions sorted by name.
+func verifyOrdering(events []event) []partitionOrder {
+	byPartition := make(map[string][]*event)
+	for i := range events {
+		e := &events[i]
+		if e.key == nil || !e.isReceived {
+			continue
+		}
+		byPartition[e.key.GetPartition()] = append(byPartition[e.key.GetPartition()], e)
+	}
+
+	orders := make([]partitionOrder, 0, len(byPartition))
+	for partition, received := range byPartition {
+		sort.SliceStable(received, func(x, y int) bool {
+			if received[x].received.Equal(received[y].received) {
+				// received at once, in any order
+				return received[x].key.GetSequence() < received[y].key.GetSequence()
+			}
+			return received[x].received.Before(received[y].received)
+		})
+		order := partitionOrder{partition: partition, received: len(received)}
+		highest := received[0].key.GetSequence()
+		for _, e := range received[1:] {
+			if seq := e.key.GetSequence(); seq < highest {
+				order.outOfOrder++
+			} else {
+				highest = seq
+			}
+		}
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(x, y int) bool { return orders[x].partition < orders[y].partition })
+	return orders
+}
+
+// reportOrdering logs the number of events received out of order and the
+// partitions with the most of them, returning their number, nil if no
+// received event had a partition key and a sequence number.
+func reportOrdering(orders []partitionOrder) *int {
+	if len(orders) == 0 {
+		log.Printf("No received event with a partition key and a sequence number, skipping the ordering verification")
+		return nil
+	}
+	outOfOrder, received := 0, 0
+	for _, o := range orders {
+		outOfOrder += o.outOfOrder
+		received += o.received
+	}
+	if outOfOrder == 0 {
+		log.Printf("The %d events received in %d partitions were all received in order", received, len(orders))
+		return &outOfOrder
+	}
+	log.Printf("!! %d of the %d events received in %d partitions were received out of order", outOfOrder, received, len(orders))
+	worst := append([]partitionOrder(nil), orders...)
+	sort.SliceStable(worst, func(x, y int) bool { return worst[x].outOfOrder > worst[y].outOfOrder })
+	if len(worst) > outOfOrderCases {
+		worst = worst[:outOfOrderCases]
+	}
+	for _, o := range worst {
+		if o.outOfOrder == 0 {
+			break
+		}
+		log.Printf("  partition %q: %d of %d events out of order", o.partition, o.outOfOrder, o.received)
+	}
+	return &outOfOrder
+}
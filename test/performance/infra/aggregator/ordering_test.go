@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// sequencedEvent returns an event of the partition with the given sequence
+// number, received at the given offset from start.
+func sequencedEvent(partition string, sequence uint64, start time.Time, receivedAfter time.Duration) event {
+	return event{
+		id:         fmt.Sprintf("%s-%d", partition, sequence),
+		key:        &pb.EventKey{Partition: partition, Sequence: sequence},
+		sent:       start,
+		received:   start.Add(receivedAfter),
+		isAccepted: true,
+		isReceived: true,
+	}
+}
+
+func TestVerifyOrdering(t *testing.T) {
+	start := time.Unix(1000, 0)
+	events := []event{
+		// a: 0, 2, 1, 3: 1 is out of order
+		sequencedEvent("a", 0, start, 1*time.Millisecond),
+		sequencedEvent("a", 1, start, 3*time.Millisecond),
+		sequencedEvent("a", 2, start, 2*time.Millisecond),
+		sequencedEvent("a", 3, start, 4*time.Millisecond),
+		// b: 3, 0, 1, 2: all but 3 are out of order
+		sequencedEvent("b", 0, start, 2*time.Millisecond),
+		sequencedEvent("b", 1, start, 3*time.Millisecond),
+		sequencedEvent("b", 2, start, 4*time.Millisecond),
+		sequencedEvent("b", 3, start, 1*time.Millisecond),
+		// c: received at once, in order
+		sequencedEvent("c", 1, start, time.Millisecond),
+		sequencedEvent("c", 0, start, time.Millisecond),
+		// events without key or not received are ignored
+		{id: "unkeyed", isReceived: true},
+	}
+	lost := sequencedEvent("c", 2, start, 0)
+	lost.isReceived = false
+	events = append(events, lost)
+
+	got := verifyOrdering(events)
+	want := []partitionOrder{
+		{partition: "a", received: 4, outOfOrder: 1},
+		{partition: "b", received: 4, outOfOrder: 3},
+		{partition: "c", received: 2, outOfOrder: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("verifyOrdering() = %+v, want %+v", got, want)
+	}
+	if n := reportOrdering(got); n == nil || *n != 4 {
+		t.Errorf("reportOrdering() = %v, want 4 out of order deliveries", n)
+	}
+}
+
+func TestVerifyOrderingWithoutSequences(t *testing.T) {
+	if n := reportOrdering(verifyOrdering([]event{{id: "1", isReceived: true}})); n != nil {
+		t.Errorf("reportOrdering() without sequences = %d, want nil", *n)
+	}
+}
+
+func TestRecordedSequencesAreVerified(t *testing.T) {
+	ag := newTestAggregator(t, 2, WithOrderingVerification(true))
+
+	now := time.Now()
+	sent := newRecord(pb.EventsRecord_SENT, now, "e0", "e1")
+	sent.Sequences = map[string]*pb.EventKey{
+		"e0": {Partition: "p", Sequence: 0},
+		"e1": {Partition: "p", Sequence: 1},
+	}
+	// the sequences go through the wire format of the records
+	raw, err := proto.Marshal(&pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+	if err != nil {
+		t.Fatal("proto.Marshal() =", err)
+	}
+	var sentList pb.EventsRecordList
+	if err := proto.Unmarshal(raw, &sentList); err != nil {
+		t.Fatal("proto.Unmarshal() =", err)
+	}
+	recordEvents(t, ag, &sentList)
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(2*time.Millisecond), "e0"),
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "e1"),
+	}})
+
+	orders := verifyOrdering(ag.joinEvents())
+	if want := []partitionOrder{{partition: "p", received: 2, outOfOrder: 1}}; !reflect.DeepEqual(orders, want) {
+		t.Errorf("verifyOrdering() = %+v, want %+v", orders, want)
+	}
+}
+
+func TestOrderingVerificationIsRejectedInStreamingAggregation(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithStreamingAggregation(true), WithOrderingVerification(true)); err == nil {
+		t.Error("NewAggregator() with the ordering verification in streaming aggregation succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultOutputWindow is the duration of the windows of the plot data and the
+// heatmap written to the output directory, unless configured.
+const defaultOutputWindow = time.Second
+
+// outputArtifact is an exported file and its name in the output directory.
+type outputArtifact struct {
+	path *string
+	name string
+}
+
+// outputArtifacts lists the files the aggregator can export.
+func (ag *Aggregator) outputArtifacts() []outputArtifact {
+	return []outputArtifact{
+		{&ag.summaryPath, "summary.json"},
+		{&ag.shortSummaryPath, "summary.txt"},
+		{&ag.ndjsonPath, "events.ndjson"},
+		{&ag.csvPath, "events.csv"},
+		{&ag.parquetPath, "events.parquet"},
+		{&ag.duplicatesPath, "duplicates.csv"},
+		{&ag.failedEventsPath, "failed-events.csv"},
+		{&ag.sqlitePath, "events.db"},
+		{&ag.timelinePath, "timeline.ndjson"},
+		{&ag.plotDataPath, "plot.dat"},
+		{&ag.throughputSeriesPath, "throughput.csv"},
+		{&ag.heatmapPath, "heatmap.json"},
+		{&ag.cdfPath, "cdf.dat"},
+		{&ag.hdrHistogramPath, "e2e-latency.hgrm"},
+		{&ag.latencySamplesPath, "latency-samples.bin"},
+	}
+}
+
+// applyOutputDir creates the output directory, if any, and exports there all
+// the files whose path isn't set, with their conventional names.
+func (ag *Aggregator) applyOutputDir() error {
+	if ag.outputDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(ag.outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create the output directory %s: %v", ag.outputDir, err)
+	}
+	for _, a := range ag.outputArtifacts() {
+		if *a.path == "" {
+			*a.path = filepath.Join(ag.outputDir, a.name)
+		}
+	}
+	if ag.plotDataWindow == 0 {
+		ag.plotDataWindow = defaultOutputWindow
+	}
+	if ag.heatmapWindow == 0 {
+		ag.heatmapWindow = defaultOutputWindow
+	}
+	return nil
+}
+
+// logOutputDir logs the files of the output directory and their sizes.
+func logOutputDir(dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Printf("ERROR listing the output directory: %v", err)
+		return
+	}
+	log.Printf("Output directory %s:", dir)
+	for _, f := range files {
+		log.Printf("  %-40s %d bytes", f.Name(), f.Size())
+	}
+}
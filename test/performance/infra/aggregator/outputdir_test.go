@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "artifacts")
+	summaryPath := filepath.Join(t.TempDir(), "summary.json")
+	ag := newTestAggregator(t, 1, WithRunID("out"), WithOutputDir(dir), WithSummaryExport(summaryPath))
+	done := runAggregator(context.Background(), ag)
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1")}})
+	waitForRun(t, done)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal("Failed to list the output directory:", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	// the summary has its own path
+	want := []string{"cdf-out.dat", "duplicates-out.csv", "e2e-latency-out.hgrm", "events-out.csv", "events-out.db", "events-out.ndjson", "events-out.parquet",
+		"failed-events-out.csv", "heatmap-out.json",
+		"latency-samples-out.bin", "plot-out.dat", "summary-out.txt", "throughput-out.csv", "timeline-out.ndjson"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("output directory files = %v, want %v", names, want)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(summaryPath), "summary-out.json")); err != nil {
+		t.Error("The summary wasn't written to its own path:", err)
+	}
+}
+
+func TestOutputDirCreationFailure(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal("Failed to create the file:", err)
+	}
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithOutputDir(filepath.Join(file, "artifacts"))); err == nil {
+		t.Error("NewAggregator() with an output directory which can't be created succeeded")
+	}
+}
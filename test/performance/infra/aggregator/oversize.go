@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// oversizeDetector is a GRPC stats handler counting the events record lists
+// rejected by the server because they exceed the maximum receive message size.
+// GRPC rejects them before our handler is invoked, so without it the loss of
+// these records would go unnoticed on the aggregator side, and the aggregator
+// would wait forever for them.
+type oversizeDetector struct {
+	maxRecvMsgSize int
+	rejected       uint64
+
+	// counts each rejected list as an expected record
+	records *recordsCounter
+}
+
+var _ stats.Handler = (*oversizeDetector)(nil)
+
+// count returns the number of rejected events record lists.
+func (d *oversizeDetector) count() uint64 {
+	return atomic.LoadUint64(&d.rejected)
+}
+
+func (d *oversizeDetector) HandleRPC(_ context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok || end.Error == nil {
+		return
+	}
+	st, ok := status.FromError(end.Error)
+	if !ok || st.Code() != codes.ResourceExhausted || !strings.Contains(st.Message(), "received message larger than max") {
+		return
+	}
+
+	rejected := atomic.AddUint64(&d.rejected, 1)
+	log.Printf("!! Rejected an events record list larger than the maximum receive message size of %d bytes (%d rejected so far): "+
+		"increase the limit with --max-recv-msg-size, or upload the records in chunks with --aggregator-chunk-size", d.maxRecvMsgSize, rejected)
+	d.records.add()
+}
+
+func (d *oversizeDetector) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (d *oversizeDetector) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (d *oversizeDetector) HandleConn(context.Context, stats.ConnStats) {}
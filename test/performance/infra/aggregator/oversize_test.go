@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestOversizeRecordsAreCounted(t *testing.T) {
+	// one rejected and one accepted events record lists
+	ag := newTestAggregator(t, 2, WithMaxRecvMsgSize(1024))
+	done := runAggregator(context.Background(), ag)
+
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("event-%d", i)
+	}
+	oversize := &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), ids...)}}
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	if err := client.Publish(oversize); err == nil {
+		t.Fatal("Publish() of an oversize record succeeded, want an error")
+	}
+
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "small")}})
+	waitForRun(t, done)
+
+	if got := ag.oversize.count(); got != 1 {
+		t.Errorf("oversize records count = %d, want 1", got)
+	}
+	if got := len(ag.sentEvents.Events); got != 1 {
+		t.Errorf("sent events count = %d, want the 1 event of the accepted record list", got)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// recordPanicked logs and counts a panic recovered while recording the given
+// events record list, returning the error replied to its sender. The list is
+// still counted once, like any other, so that waitForEvents doesn't wait for
+// it forever, as its sender won't retry.
+func (ag *Aggregator) recordPanicked(in *pb.EventsRecordList, r interface{}) error {
+	panics := atomic.AddUint64(&ag.recordPanics, 1)
+	log.Printf("!! Recovered from a panic recording %d events records from %q, which may be partially recorded (%d panics so far): %v\n%s",
+		len(in.Items), in.Source, panics, r, debug.Stack())
+	return status.Errorf(codes.Internal, "failed to record the events records: %v", r)
+}
+
+// reportRecordPanics logs the number of events record lists whose recording panicked.
+func (ag *Aggregator) reportRecordPanics() {
+	if panics := atomic.LoadUint64(&ag.recordPanics); panics > 0 {
+		log.Printf("!! Recording %d events record lists panicked, their events may be partially missing from the results", panics)
+	}
+}
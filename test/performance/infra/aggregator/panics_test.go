@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRecordEventsRecoversFromPanics(t *testing.T) {
+	ag := newTestAggregator(t, 2)
+	done := runAggregator(context.Background(), ag)
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+
+	now := time.Now()
+	// merging into a nil map panics mid-merge, with the lock of the sent events held
+	ag.sentEvents.Lock()
+	events := ag.sentEvents.Events
+	ag.sentEvents.Events = nil
+	ag.sentEvents.Unlock()
+	err = client.Publish(&pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+	}})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Publish() = %v, want an internal error", err)
+	}
+	ag.sentEvents.Lock()
+	ag.sentEvents.Events = events
+	ag.sentEvents.Unlock()
+
+	if got := atomic.LoadUint64(&ag.recordPanics); got != 1 {
+		t.Errorf("counted %d panics, want 1", got)
+	}
+	if got := ag.records.value(); got != 1 {
+		t.Errorf("counted %d events records after the panic, want 1", got)
+	}
+
+	// the server is still healthy and the locks released
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "2"),
+	}})
+	waitForRun(t, done)
+	if got := ag.records.value(); got != 2 {
+		t.Errorf("counted %d events records, want 2", got)
+	}
+	if _, ok := ag.sentEvents.Events["2"]; !ok {
+		t.Error("events recorded after the panic are missing")
+	}
+}
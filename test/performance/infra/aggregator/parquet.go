@@ -0,0 +1,145 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetMarshallers is the number of goroutines marshalling the rows of a
+// row group into columns.
+const parquetMarshallers = 4
+
+// parquetEvent is the row written for each event. Timestamps are nanoseconds
+// since the Unix epoch and latencies nanoseconds, null when the event didn't
+// reach the corresponding step. Partition and sequence are null unless events
+// are matched on their composite key.
+type parquetEvent struct {
+	ID        string  `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Scenario  *string `parquet:"name=scenario, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Partition *string `parquet:"name=partition, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Sequence  *int64  `parquet:"name=sequence, type=INT64, repetitiontype=OPTIONAL"`
+	Sender    *string `parquet:"name=sender, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Receiver  *string `parquet:"name=receiver, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+
+	Sent     int64  `parquet:"name=sent, type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=NANOS"`
+	Accepted *int64 `parquet:"name=accepted, type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=NANOS, repetitiontype=OPTIONAL"`
+	Received *int64 `parquet:"name=received, type=INT64, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=NANOS, repetitiontype=OPTIONAL"`
+
+	PublishLatencyNs *int64 `parquet:"name=publish_latency_ns, type=INT64, repetitiontype=OPTIONAL"`
+	E2ELatencyNs     *int64 `parquet:"name=e2e_latency_ns, type=INT64, repetitiontype=OPTIONAL"`
+
+	PublishFailed  bool `parquet:"name=publish_failed, type=BOOLEAN"`
+	DeliveryFailed bool `parquet:"name=delivery_failed, type=BOOLEAN"`
+}
+
+func newParquetEvent(e *event, scenario string) *parquetEvent {
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	out := &parquetEvent{
+		ID:             e.id,
+		Scenario:       optional(scenario),
+		Sender:         optional(e.sender),
+		Receiver:       optional(e.receiver),
+		Sent:           e.sent.UnixNano(),
+		PublishFailed:  e.outcome() == outcomePublishFailure,
+		DeliveryFailed: e.outcome() == outcomeDeliveryFailure,
+	}
+	if e.key != nil {
+		partition, sequence := e.key.GetPartition(), int64(e.key.GetSequence())
+		out.Partition, out.Sequence = &partition, &sequence
+	}
+	if e.isAccepted {
+		accepted, latency := e.accepted.UnixNano(), e.publishLatency().Nanoseconds()
+		out.Accepted, out.PublishLatencyNs = &accepted, &latency
+	}
+	if e.isReceived {
+		received, latency := e.received.UnixNano(), e.e2eLatency().Nanoseconds()
+		out.Received, out.E2ELatencyNs = &received, &latency
+	}
+	return out
+}
+
+// parquetEventWriter writes one row per event it is given, buffering them in
+// row groups. After the first error, it logs it and ignores the following
+// events.
+type parquetEventWriter struct {
+	pw     *writer.ParquetWriter
+	closer io.Closer
+	err    error
+
+	// scenario of the events, if any
+	scenario string
+}
+
+func newParquetEventWriter(w io.Writer) (*parquetEventWriter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(parquetEvent), parquetMarshallers)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetEventWriter{pw: pw}, nil
+}
+
+// createParquetEventWriter opens the Parquet export at the given path,
+// labelling the events with the scenario, if any.
+func createParquetEventWriter(path string, scenario string) (*parquetEventWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	w, err := newParquetEventWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	w.closer = f
+	w.scenario = scenario
+	return w, nil
+}
+
+// write writes the row of the given event, unless a previous write failed.
+func (w *parquetEventWriter) write(e *event) {
+	if w.err != nil {
+		return
+	}
+	if w.err = w.pw.Write(newParquetEvent(e, w.scenario)); w.err != nil {
+		log.Printf("ERROR writing the Parquet events: %v", w.err)
+	}
+}
+
+// close writes the buffered rows and the footer, and closes the file of the
+// export, if any.
+func (w *parquetEventWriter) close() error {
+	if w.err == nil {
+		w.err = w.pw.WriteStop()
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
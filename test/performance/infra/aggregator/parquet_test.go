@@ -0,0 +1,188 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// readParquetEvents reads back the rows of the Parquet export at the given
+// path.
+func readParquetEvents(t *testing.T, path string) []parquetEvent {
+	t.Helper()
+	f, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatal("Failed to open the Parquet export:", err)
+	}
+	defer f.Close()
+	pr, err := reader.NewParquetReader(f, new(parquetEvent), 1)
+	if err != nil {
+		t.Fatal("Failed to read the Parquet export:", err)
+	}
+	defer pr.ReadStop()
+	rows := make([]parquetEvent, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		t.Fatal("Failed to read the Parquet rows:", err)
+	}
+	return rows
+}
+
+// readParquetSchema reads the elements of the schema of the Parquet export at
+// the given path, as written in the file rather than as mapped to parquetEvent.
+func readParquetSchema(t *testing.T, path string) ([]*parquet.SchemaElement, []string) {
+	t.Helper()
+	f, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatal("Failed to open the Parquet export:", err)
+	}
+	defer f.Close()
+	pr, err := reader.NewParquetReader(f, nil, 1)
+	if err != nil {
+		t.Fatal("Failed to read the Parquet export:", err)
+	}
+	defer pr.ReadStop()
+	names := make([]string, len(pr.Footer.Schema))
+	for i := range names {
+		names[i] = pr.SchemaHandler.GetExName(i)
+	}
+	return pr.Footer.Schema, names
+}
+
+func TestParquetEventWriter(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	events := []event{
+		{id: "delivered", sender: "s", receiver: "r", sent: sent, accepted: sent.Add(time.Millisecond), received: sent.Add(5 * time.Millisecond), isAccepted: true, isReceived: true},
+		{id: "lost", key: &pb.EventKey{Partition: "p0", Sequence: 7}, sender: "s", sent: sent, accepted: sent.Add(2 * time.Millisecond), isAccepted: true},
+		{id: "rejected", sent: sent},
+	}
+
+	path := filepath.Join(t.TempDir(), "events.parquet")
+	w, err := createParquetEventWriter(path, "burst")
+	if err != nil {
+		t.Fatal("createParquetEventWriter() =", err)
+	}
+	for i := range events {
+		w.write(&events[i])
+	}
+	if err := w.close(); err != nil {
+		t.Fatal("close() =", err)
+	}
+
+	schema, names := readParquetSchema(t, path)
+
+	type column struct {
+		name     string
+		typ      parquet.Type
+		optional bool
+	}
+	var columns []column
+	// the first element is the root of the schema
+	for i, e := range schema[1:] {
+		columns = append(columns, column{names[i+1], e.GetType(), e.GetRepetitionType() == parquet.FieldRepetitionType_OPTIONAL})
+	}
+	wantColumns := []column{
+		{"id", parquet.Type_BYTE_ARRAY, false},
+		{"scenario", parquet.Type_BYTE_ARRAY, true},
+		{"partition", parquet.Type_BYTE_ARRAY, true},
+		{"sequence", parquet.Type_INT64, true},
+		{"sender", parquet.Type_BYTE_ARRAY, true},
+		{"receiver", parquet.Type_BYTE_ARRAY, true},
+		{"sent", parquet.Type_INT64, false},
+		{"accepted", parquet.Type_INT64, true},
+		{"received", parquet.Type_INT64, true},
+		{"publish_latency_ns", parquet.Type_INT64, true},
+		{"e2e_latency_ns", parquet.Type_INT64, true},
+		{"publish_failed", parquet.Type_BOOLEAN, false},
+		{"delivery_failed", parquet.Type_BOOLEAN, false},
+	}
+	if !reflect.DeepEqual(columns, wantColumns) {
+		t.Errorf("Parquet schema = %v, want %v", columns, wantColumns)
+	}
+	if sentType := schema[7].GetLogicalType(); !sentType.IsSetTIMESTAMP() || !sentType.TIMESTAMP.GetUnit().IsSetNANOS() {
+		t.Errorf("sent logical type = %v, want a timestamp in nanoseconds", sentType)
+	}
+
+	rows := readParquetEvents(t, path)
+	if len(rows) != len(events) {
+		t.Fatalf("Parquet export has %d rows, want %d", len(rows), len(events))
+	}
+	str := func(s string) *string { return &s }
+	i64 := func(n int64) *int64 { return &n }
+	wantRows := []parquetEvent{{
+		ID:               "delivered",
+		Scenario:         str("burst"),
+		Sender:           str("s"),
+		Receiver:         str("r"),
+		Sent:             1000000000000,
+		Accepted:         i64(1000001000000),
+		Received:         i64(1000005000000),
+		PublishLatencyNs: i64(1000000),
+		E2ELatencyNs:     i64(5000000),
+	}, {
+		ID:               "lost",
+		Scenario:         str("burst"),
+		Partition:        str("p0"),
+		Sequence:         i64(7),
+		Sender:           str("s"),
+		Sent:             1000000000000,
+		Accepted:         i64(1000002000000),
+		PublishLatencyNs: i64(2000000),
+		DeliveryFailed:   true,
+	}, {
+		ID:            "rejected",
+		Scenario:      str("burst"),
+		Sent:          1000000000000,
+		PublishFailed: true,
+	}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("Parquet rows = %+v, want %+v", rows, wantRows)
+	}
+}
+
+func TestParquetExport(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 2, WithRunID("parquet"), WithParquetExport(filepath.Join(dir, "events.parquet")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a", "b"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "a", "b"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "a"),
+	}})
+	waitForRun(t, done)
+
+	rows := readParquetEvents(t, filepath.Join(dir, "events-parquet.parquet"))
+	if len(rows) != 2 {
+		t.Errorf("Parquet export has %d rows, want 2: %+v", len(rows), rows)
+	}
+}
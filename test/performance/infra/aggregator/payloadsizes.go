@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// PayloadSizeSummary is the breakdown of the end to end latencies of a run for
+// the received events whose payload size is within a range, doubling from a
+// range to the next.
+type PayloadSizeSummary struct {
+	// range of the payload sizes in bytes, inclusive
+	MinSize  uint32
+	MaxSize  uint32
+	Received int
+	// median and 99th percentile of the end to end latencies of the events
+	E2ELatencyP50 time.Duration
+	E2ELatencyP99 time.Duration
+}
+
+// payloadSizeRange returns the range of the given payload size, from the
+// power of 2 at or below it to the next one excluded.
+func payloadSizeRange(size uint32) (uint32, uint32) {
+	min := uint32(1) << (31 - bits.LeadingZeros32(size))
+	return min, min + (min - 1)
+}
+
+// payloadSizeSummaries returns the breakdown of the end to end latencies of
+// the received events with a payload size by size range, in increasing order
+// of sizes.
+func payloadSizeSummaries(events []event) []PayloadSizeSummary {
+	byRange := make(map[uint32][]time.Duration)
+	for i := range events {
+		e := &events[i]
+		if e.payloadSize == 0 || !e.isReceived {
+			continue
+		}
+		min, _ := payloadSizeRange(e.payloadSize)
+		byRange[min] = append(byRange[min], e.received.Sub(e.sent))
+	}
+
+	summaries := make([]PayloadSizeSummary, 0, len(byRange))
+	for min, latencies := range byRange {
+		sortDurations(latencies)
+		s := PayloadSizeSummary{Received: len(latencies)}
+		s.MinSize, s.MaxSize = payloadSizeRange(min)
+		s.E2ELatencyP50 = percentile(latencies, 50)
+		s.E2ELatencyP99 = percentile(latencies, 99)
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(x, y int) bool { return summaries[x].MinSize < summaries[y].MinSize })
+	return summaries
+}
+
+// latencySizeSlope returns the slope of the linear regression of the end to end
+// latencies of the received events over their payload sizes, in microseconds
+// per KiB, false unless they have at least two different sizes.
+func latencySizeSlope(events []event) (float64, bool) {
+	var n, sumX, sumY, sumXX, sumXY float64
+	for i := range events {
+		e := &events[i]
+		if e.payloadSize == 0 || !e.isReceived {
+			continue
+		}
+		x := float64(e.payloadSize) / 1024
+		y := float64(e.received.Sub(e.sent)) / float64(time.Microsecond)
+		n++
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	variance := n*sumXX - sumX*sumX
+	// rounding errors with a single size
+	if n < 2 || variance <= 1e-9*n*sumXX {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / variance, true
+}
+
+// formatPayloadSizeTable returns the lines of the table breaking down the
+// received counts and p50 and p99 end to end latencies by payload size range.
+func formatPayloadSizeTable(summaries []PayloadSizeSummary) []string {
+	lines := []string{fmt.Sprintf("%-24s %8s %12s %12s", "payload size", "received", "p50", "p99")}
+	for _, s := range summaries {
+		lines = append(lines, fmt.Sprintf("%-24s %8d %12s %12s", fmt.Sprintf("%d-%d bytes", s.MinSize, s.MaxSize), s.Received,
+			s.E2ELatencyP50.Round(time.Microsecond), s.E2ELatencyP99.Round(time.Microsecond)))
+	}
+	return lines
+}
+
+// reportPayloadSizes logs the breakdown of the end to end latencies by
+// payload size and their slope over the sizes, returning the breakdown and
+// the slope, nil without received events with different payload sizes. It
+// logs nothing if no sender reported the payload sizes of its events.
+func reportPayloadSizes(events []event) ([]PayloadSizeSummary, *float64) {
+	summaries := payloadSizeSummaries(events)
+	if len(summaries) == 0 {
+		return nil, nil
+	}
+	log.Printf("End to end latencies by payload size:")
+	for _, line := range formatPayloadSizeTable(summaries) {
+		log.Printf("  %s", line)
+	}
+	slope, ok := latencySizeSlope(events)
+	if !ok {
+		log.Printf("No different payload sizes received, skipping the slope of the latencies over the sizes")
+		return summaries, nil
+	}
+	log.Printf("End to end latencies increase by %.3fus per KiB of payload", slope)
+	return summaries, &slope
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// sizedEvent returns a received event with the given payload size and end to
+// end latency.
+func sizedEvent(id string, size uint32, latency time.Duration) event {
+	sent := time.Unix(1000, 0)
+	return event{id: id, payloadSize: size, sent: sent, received: sent.Add(latency), isAccepted: true, isReceived: true}
+}
+
+func TestPayloadSizeRange(t *testing.T) {
+	for _, tc := range []struct {
+		size, min, max uint32
+	}{
+		{2, 2, 3},
+		{3, 2, 3},
+		{1000, 512, 1023},
+		{1024, 1024, 2047},
+	} {
+		if min, max := payloadSizeRange(tc.size); min != tc.min || max != tc.max {
+			t.Errorf("payloadSizeRange(%d) = %d-%d, want %d-%d", tc.size, min, max, tc.min, tc.max)
+		}
+	}
+}
+
+func TestPayloadSizeBreakdown(t *testing.T) {
+	events := []event{
+		// 1 ms per KiB on top of 1 ms
+		sizedEvent("a", 1024, 2*time.Millisecond),
+		sizedEvent("b", 1536, 2500*time.Microsecond),
+		sizedEvent("c", 2048, 3*time.Millisecond),
+		sizedEvent("d", 4096, 5*time.Millisecond),
+		// events without payload size or not received are ignored
+		sizedEvent("unsized", 0, time.Second),
+	}
+	lost := sizedEvent("lost", 100, 0)
+	lost.isReceived = false
+	events = append(events, lost)
+
+	summaries, slope := reportPayloadSizes(events)
+	want := []PayloadSizeSummary{
+		{MinSize: 1024, MaxSize: 2047, Received: 2, E2ELatencyP50: 2 * time.Millisecond, E2ELatencyP99: 2500 * time.Microsecond},
+		{MinSize: 2048, MaxSize: 4095, Received: 1, E2ELatencyP50: 3 * time.Millisecond, E2ELatencyP99: 3 * time.Millisecond},
+		{MinSize: 4096, MaxSize: 8191, Received: 1, E2ELatencyP50: 5 * time.Millisecond, E2ELatencyP99: 5 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Errorf("reportPayloadSizes() = %+v, want %+v", summaries, want)
+	}
+	if slope == nil || math.Abs(*slope-1000) > 1e-6 {
+		t.Errorf("latency slope = %v, want 1000us per KiB", slope)
+	}
+}
+
+func TestPayloadSizeBreakdownWithoutSizes(t *testing.T) {
+	if summaries, slope := reportPayloadSizes([]event{sizedEvent("a", 0, time.Millisecond)}); summaries != nil || slope != nil {
+		t.Errorf("reportPayloadSizes() without payload sizes = %+v, %v, want nil", summaries, slope)
+	}
+	// a single payload size has no slope
+	events := []event{sizedEvent("a", 100, time.Millisecond), sizedEvent("b", 100, 2*time.Millisecond)}
+	if summaries, slope := reportPayloadSizes(events); len(summaries) != 1 || slope != nil {
+		t.Errorf("reportPayloadSizes() with a single payload size = %+v, %v, want a single range without slope", summaries, slope)
+	}
+}
+
+func TestRecordedPayloadSizesAreJoined(t *testing.T) {
+	ag := newTestAggregator(t, 2)
+
+	now := time.Now()
+	sent := newRecord(pb.EventsRecord_SENT, now, "e0", "e1")
+	sent.PayloadSizes = map[string]uint32{"e0": 100, "e1": 5000}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "e0", "e1"),
+	}})
+
+	sizes := make(map[string]uint32)
+	for _, e := range ag.joinEvents() {
+		sizes[e.id] = e.payloadSize
+	}
+	if want := map[string]uint32{"e0": 100, "e1": 5000}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("joined payload sizes = %v, want %v", sizes, want)
+	}
+}
+
+func TestPayloadSizesRoundTripInSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+	summary := &RunSummary{RunID: "run", PayloadSizes: []PayloadSizeSummary{
+		{MinSize: 512, MaxSize: 1023, Received: 9, E2ELatencyP50: 3 * time.Millisecond, E2ELatencyP99: 8 * time.Millisecond},
+	}}
+	if err := exportRunSummary(path, summary); err != nil {
+		t.Fatal("exportRunSummary() =", err)
+	}
+	got, err := loadRunSummary(path)
+	if err != nil {
+		t.Fatal("loadRunSummary() =", err)
+	}
+	if !reflect.DeepEqual(got.PayloadSizes, summary.PayloadSizes) {
+		t.Errorf("loaded payload sizes = %+v, want %+v", got.PayloadSizes, summary.PayloadSizes)
+	}
+}
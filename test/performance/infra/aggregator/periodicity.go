@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// minPeriodicStrength is the autocorrelation of the windowed p99 latencies at
+// the dominant period above which the latency is reported as periodic.
+const minPeriodicStrength = 0.5
+
+// latencyPeriodicity is the dominant period of the windowed p99 latencies.
+type latencyPeriodicity struct {
+	period time.Duration
+	// autocorrelation of the series at the period, up to 1 for a strictly
+	// periodic latency
+	strength float64
+}
+
+// findLatencyPeriodicity returns the dominant period of the p99 latencies of
+// the given windows, sorted by start: the lag of the highest autocorrelation
+// of the series once it first turned negative, skipping the short lags at
+// which any series correlates with itself. The windows without events are
+// filled with the mean of the series. It returns false if the series never
+// decorrelates within half its length, as the period must repeat at least
+// twice to be detected.
+func findLatencyPeriodicity(windows []latencyWindow, window time.Duration) (latencyPeriodicity, bool) {
+	if len(windows) < 4 {
+		return latencyPeriodicity{}, false
+	}
+	first, last := windows[0].start, windows[len(windows)-1].start
+	n := int(last.Sub(first)/window) + 1
+	series := make([]float64, n)
+	filled := make([]bool, n)
+	var mean float64
+	for _, w := range windows {
+		i := int(w.start.Sub(first) / window)
+		series[i], filled[i] = w.p99.Seconds(), true
+		mean += series[i]
+	}
+	mean /= float64(len(windows))
+	var variance float64
+	for i := range series {
+		if !filled[i] {
+			series[i] = mean
+		}
+		series[i] -= mean
+		variance += series[i] * series[i]
+	}
+	if variance == 0 {
+		return latencyPeriodicity{}, false
+	}
+
+	autocorrelation := func(lag int) float64 {
+		var sum float64
+		for i := lag; i < n; i++ {
+			sum += series[i] * series[i-lag]
+		}
+		return sum / variance
+	}
+	decorrelated := false
+	best, bestR := 0, 0.0
+	for lag := 1; lag <= n/2; lag++ {
+		r := autocorrelation(lag)
+		if !decorrelated {
+			decorrelated = r < 0
+			continue
+		}
+		if best == 0 || r > bestR {
+			best, bestR = lag, r
+		}
+	}
+	if best == 0 {
+		return latencyPeriodicity{}, false
+	}
+	return latencyPeriodicity{period: time.Duration(best) * window, strength: bestR}, true
+}
+
+// reportLatencyPeriodicity logs the dominant period of the windowed p99
+// latencies of the events, returning it if any.
+func reportLatencyPeriodicity(events []event, window time.Duration) *latencyPeriodicity {
+	p, ok := findLatencyPeriodicity(windowedP99(events, window), window)
+	if !ok {
+		log.Printf("No period found in the p99 end to end latency by %v window", window)
+		return nil
+	}
+	if p.strength >= minPeriodicStrength {
+		log.Printf("!! The p99 end to end latency is periodic: period %v, strength %.2f", p.period, p.strength)
+	} else {
+		log.Printf("The p99 end to end latency isn't periodic: strongest period %v, strength %.2f", p.period, p.strength)
+	}
+	return &p
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// latencyEvents returns an event sent every second for the given duration,
+// delivered with the latency returned for its second.
+func latencyEvents(d time.Duration, latency func(second int) time.Duration) []event {
+	start := time.Unix(1000, 0)
+	var events []event
+	for s := 0; s < int(d/time.Second); s++ {
+		sent := start.Add(time.Duration(s) * time.Second)
+		events = append(events, event{sent: sent, received: sent.Add(latency(s)), isAccepted: true, isReceived: true})
+	}
+	return events
+}
+
+func TestLatencyPeriodicity(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	jitter := func() time.Duration {
+		return time.Duration(r.Intn(2000)) * time.Microsecond
+	}
+	// 10ms with 5s long spikes to 100ms every minute, for 10 minutes
+	events := latencyEvents(10*time.Minute, func(s int) time.Duration {
+		if s%60 < 5 {
+			return 100*time.Millisecond + jitter()
+		}
+		return 10*time.Millisecond + jitter()
+	})
+
+	p := reportLatencyPeriodicity(events, time.Second)
+	if p == nil {
+		t.Fatal("no period found, want 1m")
+	}
+	if p.period != time.Minute || p.strength < minPeriodicStrength {
+		t.Errorf("periodicity = %v, strength %.2f, want 1m, periodic", p.period, p.strength)
+	}
+
+	a := runAggregates{minSamplesForPercentiles: 1, e2eLatencies: []time.Duration{time.Millisecond}, periodicity: p}
+	q := newFakeAggregatesStore()
+	a.publish(q)
+	if q.run["lps"] != 60 || q.run["lpc"] != p.strength {
+		t.Errorf("published period %vs and periodicity %v, want 60s and %v", q.run["lps"], q.run["lpc"], p.strength)
+	}
+}
+
+func TestLatencyWithoutPeriodicity(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	events := latencyEvents(10*time.Minute, func(int) time.Duration {
+		return time.Duration(10+r.Intn(90)) * time.Millisecond
+	})
+	if p := reportLatencyPeriodicity(events, time.Second); p != nil && p.strength >= minPeriodicStrength {
+		t.Errorf("periodicity = %v, strength %.2f, want random latencies not periodic", p.period, p.strength)
+	}
+
+	constant := latencyEvents(time.Minute, func(int) time.Duration { return time.Millisecond })
+	if p := reportLatencyPeriodicity(constant, time.Second); p != nil {
+		t.Errorf("periodicity = %v of a constant latency, want none", p.period)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// latencyPhase is a phase of the pipeline and the sorted latencies of the events through it.
+type latencyPhase struct {
+	name      string
+	latencies []time.Duration
+}
+
+// latencyPhases returns the phases of the pipeline: publish, from sent to
+// accepted, delivery, from accepted to received, and end to end.
+func latencyPhases(events []event) []latencyPhase {
+	return []latencyPhase{
+		{name: "publish", latencies: publishLatencies(events)},
+		{name: "delivery", latencies: queueingDelays(events)},
+		{name: "end-to-end", latencies: e2eLatencies(events)},
+	}
+}
+
+// formatPhaseTable returns the lines of the table comparing the percentiles of
+// the latencies of the phases side by side.
+func formatPhaseTable(phases []latencyPhase) []string {
+	header := fmt.Sprintf("%-12s %8s", "phase", "samples")
+	for _, p := range reportedPercentiles {
+		header += fmt.Sprintf(" %12s", fmt.Sprintf("p%v", p))
+	}
+	lines := []string{header}
+	for _, phase := range phases {
+		line := fmt.Sprintf("%-12s %8d", phase.name, len(phase.latencies))
+		for _, p := range reportedPercentiles {
+			if len(phase.latencies) == 0 {
+				line += fmt.Sprintf(" %12s", "-")
+			} else {
+				line += fmt.Sprintf(" %12v", percentile(phase.latencies, p).Round(time.Microsecond))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// reportPhaseTable logs the table comparing the latency percentiles of the phases.
+func reportPhaseTable(events []event) {
+	log.Printf("Latency percentiles by phase:")
+	for _, line := range formatPhaseTable(latencyPhases(events)) {
+		log.Printf("  %s", line)
+	}
+}
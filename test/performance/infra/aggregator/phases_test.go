@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseTable(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	// event i is accepted after i ms and received i ms later
+	var events []event
+	for i := 1; i <= 100; i++ {
+		accepted := sent.Add(time.Duration(i) * time.Millisecond)
+		events = append(events, event{
+			id:         fmt.Sprint(i),
+			sent:       sent,
+			accepted:   accepted,
+			received:   accepted.Add(time.Duration(i) * time.Millisecond),
+			isAccepted: true,
+			isReceived: true,
+		})
+	}
+	// lost, only in the publish phase
+	events = append(events, event{id: "lost", sent: sent, accepted: sent.Add(time.Millisecond), isAccepted: true})
+
+	lines := formatPhaseTable(latencyPhases(events))
+	want := [][]string{
+		{"phase", "samples", "p50", "p90", "p99"},
+		{"publish", "101", "50ms", "90ms", "99ms"},
+		{"delivery", "100", "50ms", "90ms", "99ms"},
+		{"end-to-end", "100", "100ms", "180ms", "198ms"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for i := range want {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("line %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestPhaseTableWithoutSamples(t *testing.T) {
+	lines := formatPhaseTable(latencyPhases(nil))
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4", len(lines))
+	}
+	for _, line := range lines[1:] {
+		if got := strings.Fields(line); len(got) != 5 || got[1] != "0" || got[2] != "-" {
+			t.Errorf("line of a phase without samples = %v, want 0 samples and no percentiles", got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// plotDataHeader names the columns of the plot data file.
+const plotDataHeader = "# time sent_per_s received_per_s p50_ms p99_ms loss_rate"
+
+// plotRow holds the counts and latencies of a window of the plot data.
+type plotRow struct {
+	start time.Time
+	// events sent during the window and how many of them were lost
+	sent, lost int
+	// events received during the window
+	received int
+	// sorted end to end latencies of the delivered events sent during the window
+	e2eLatencies []time.Duration
+}
+
+// computePlotData grids the events on the windows of the given duration, from
+// the first to the last window with a sent or received event.
+func computePlotData(events []event, window time.Duration) []plotRow {
+	byStart := make(map[time.Time]*plotRow)
+	var first, last time.Time
+	row := func(t time.Time) *plotRow {
+		start := t.Truncate(window)
+		r, ok := byStart[start]
+		if !ok {
+			r = &plotRow{start: start}
+			byStart[start] = r
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if start.After(last) {
+				last = start
+			}
+		}
+		return r
+	}
+
+	for i := range events {
+		e := &events[i]
+		r := row(e.sent)
+		r.sent++
+		if !e.isReceived {
+			r.lost++
+			continue
+		}
+		r.e2eLatencies = append(r.e2eLatencies, e.e2eLatency())
+		row(e.received).received++
+	}
+
+	if len(byStart) == 0 {
+		return nil
+	}
+	var rows []plotRow
+	for start := first; !start.After(last); start = start.Add(window) {
+		if r, ok := byStart[start]; ok {
+			sortDurations(r.e2eLatencies)
+			rows = append(rows, *r)
+		} else {
+			rows = append(rows, plotRow{start: start})
+		}
+	}
+	return rows
+}
+
+// writePlotData writes the series of the rows as whitespace-delimited columns
+// with a header row: the start of the window in seconds since the Unix epoch,
+// the sent and received throughputs, the p50 and p99 end to end latencies of
+// the events sent during the window and their loss rate. Latencies and loss
+// rate are NaN for the windows without, respectively, delivered and sent events.
+// The scenario, if any, is written as a comment before the header row.
+// It can be plotted with e.g. gnuplot: plot "data" using 1:2 with lines
+func writePlotData(w io.Writer, scenario string, rows []plotRow, window time.Duration) error {
+	bw := bufio.NewWriter(w)
+	if scenario != "" {
+		fmt.Fprintln(bw, "# scenario:", scenario)
+	}
+	fmt.Fprintln(bw, plotDataHeader)
+	for i := range rows {
+		r := &rows[i]
+		p50, p99, lossRate := math.NaN(), math.NaN(), math.NaN()
+		if len(r.e2eLatencies) > 0 {
+			p50 = millis(percentile(r.e2eLatencies, 50))
+			p99 = millis(percentile(r.e2eLatencies, 99))
+		}
+		if r.sent > 0 {
+			lossRate = float64(r.lost) / float64(r.sent)
+		}
+		fmt.Fprintf(bw, "%.3f %g %g %g %g %g\n", float64(r.start.UnixNano())/float64(time.Second),
+			float64(r.sent)/window.Seconds(), float64(r.received)/window.Seconds(), p50, p99, lossRate)
+	}
+	return bw.Flush()
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// exportPlotData writes the plot data of the events to the file at the given path.
+func exportPlotData(path string, scenario string, events []event, window time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := writePlotData(f, scenario, computePlotData(events, window), window); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
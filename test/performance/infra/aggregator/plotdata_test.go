@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlotData(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	// 10 events sent in window 0, none in window 1, 4 in window 2 of which 2 are lost
+	var events []event
+	for i := 0; i < 10; i++ {
+		sent := start.Add(time.Duration(i) * time.Millisecond)
+		events = append(events, event{
+			id:         fmt.Sprintf("0-%d", i),
+			sent:       sent,
+			received:   sent.Add(10 * time.Millisecond),
+			isAccepted: true,
+			isReceived: true,
+		})
+	}
+	for i := 0; i < 4; i++ {
+		sent := start.Add(2*time.Second + time.Duration(i)*time.Millisecond)
+		events = append(events, event{
+			id:         fmt.Sprintf("2-%d", i),
+			sent:       sent,
+			received:   sent.Add(20 * time.Millisecond),
+			isAccepted: true,
+			isReceived: i%2 == 0,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := writePlotData(&buf, "", computePlotData(events, time.Second), time.Second); err != nil {
+		t.Fatal("writePlotData() =", err)
+	}
+
+	want := []string{
+		plotDataHeader,
+		"1000.000 10 10 10 10 0",
+		"1001.000 0 0 NaN NaN NaN",
+		"1002.000 4 2 20 20 0.5",
+	}
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(got), len(want), buf.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got[i], want[i])
+		}
+		if n := len(strings.Fields(got[i])); i > 0 && n != 6 {
+			t.Errorf("line %d has %d fields, want 6", i, n)
+		}
+	}
+}
+
+func TestPlotDataWithoutEvents(t *testing.T) {
+	if rows := computePlotData(nil, time.Second); len(rows) != 0 {
+		t.Errorf("computePlotData(nil) = %d rows, want 0", len(rows))
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sort"
+	"sync"
+
+	"knative.dev/pkg/test/mako"
+)
+
+// producerTags collects the Mako tags reported by the senders and receivers
+// in their records, describing how they ran, e.g. the content mode of the
+// events sent, to tag the run with.
+type producerTags struct {
+	sync.Mutex
+	tags map[string]struct{}
+}
+
+func newProducerTags() *producerTags {
+	return &producerTags{tags: make(map[string]struct{})}
+}
+
+// add collects the given tags.
+func (p *producerTags) add(tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	for _, t := range tags {
+		if t != "" {
+			p.tags[mako.EscapeTag(t)] = struct{}{}
+		}
+	}
+}
+
+// list returns the distinct tags collected, sorted.
+func (p *producerTags) list() []string {
+	p.Lock()
+	defer p.Unlock()
+	tags := make([]string, 0, len(p.tags))
+	for t := range p.tags {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// publishTo adds the tags collected to the tags of the run, logging them.
+func (p *producerTags) publishTo(exporter Exporter) {
+	tags := p.list()
+	if len(tags) == 0 {
+		return
+	}
+	log.Printf("Publishing the tags of the senders and receivers: %v", tags)
+	exporter.AddTags(tags...)
+}
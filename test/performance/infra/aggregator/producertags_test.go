@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestProducerTagsAreCollected(t *testing.T) {
+	ag := newTestAggregator(t, 3)
+
+	now := time.Now()
+	recordEvents(t, ag, &pb.EventsRecordList{
+		Items:  []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "e0")},
+		Source: "sender-0",
+		Tags:   []string{"encoding=structured"},
+	})
+	recordEvents(t, ag, &pb.EventsRecordList{
+		Items:  []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now.Add(time.Millisecond), "e1")},
+		Source: "sender-1",
+		Tags:   []string{"encoding=structured", "", "mode=a b"},
+	})
+	recordEvents(t, ag, &pb.EventsRecordList{
+		Items:  []*pb.EventsRecord{newRecord(pb.EventsRecord_RECEIVED, now.Add(2*time.Millisecond), "e0", "e1")},
+		Source: "receiver",
+	})
+
+	// deduplicated and escaped
+	if got, want := ag.producerTags.list(), []string{"encoding=structured", "mode=a_b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("producer tags = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+const (
+	// defaultProgressInterval is the interval between the progress reports
+	// when the subscriber doesn't request one
+	defaultProgressInterval = 10 * time.Second
+	// minProgressInterval bounds the rate of the reports
+	minProgressInterval = 100 * time.Millisecond
+)
+
+// progressTracker keeps the counts and the end to end latencies of the events
+// up to date as they are recorded, so that reporting the progress neither
+// locks nor matches the recorded events.
+type progressTracker struct {
+	sync.Mutex
+	// distinct events recorded, including the ones folded or expired since
+	sent, accepted, received uint64
+	// end to end latencies of the events matched so far
+	e2e *hdrHistogram
+	// events recently matched, as both their ends may be recorded
+	// concurrently, each then matching the other
+	matched *submissionCache
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{e2e: newLatencyHistogram(), matched: newSubmissionCache(streamedTTL)}
+}
+
+// observe counts the given newly recorded events of the given type, and
+// records the end to end latencies of those whose counterparts are already
+// recorded. It must be called without holding the lock of the events records.
+func (t *progressTracker) observe(ag *Aggregator, recType pb.EventsRecord_Type, added []matchedEvent) {
+	if len(added) == 0 {
+		return
+	}
+	var matches []latencyMatch
+	switch recType {
+	case pb.EventsRecord_SENT:
+		matches = matchAdded(ag.receivedEvents, added, true)
+	case pb.EventsRecord_RECEIVED:
+		matches = matchAdded(ag.sentEvents, added, false)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	switch recType {
+	case pb.EventsRecord_SENT:
+		t.sent += uint64(len(added))
+	case pb.EventsRecord_ACCEPTED:
+		t.accepted += uint64(len(added))
+	case pb.EventsRecord_RECEIVED:
+		t.received += uint64(len(added))
+	}
+	for _, m := range matches {
+		// matched at most twice, so forgotten once its duplicate is ignored
+		if t.matched.seen(m.id) {
+			t.matched.forget(m.id)
+			continue
+		}
+		t.e2e.record(m.to.Sub(m.from))
+	}
+}
+
+// progress returns the interim progress of the run.
+func (ag *Aggregator) progress(complete bool) *pb.Progress {
+	p := &pb.Progress{
+		RecordsReceived: uint64(ag.records.value()),
+		RecordsExpected: uint64(ag.expectRecords),
+		Complete:        complete,
+	}
+	p.At, _ = ptypes.TimestampProto(ag.now())
+
+	t := ag.progressTracker
+	t.Lock()
+	defer t.Unlock()
+	p.Sent, p.Accepted, p.Received = t.sent, t.accepted, t.received
+	p.Matched = uint64(t.e2e.count)
+	if t.e2e.count > 0 {
+		p.LatencyP50 = ptypes.DurationProto(t.e2e.percentile(50))
+		p.LatencyP90 = ptypes.DurationProto(t.e2e.percentile(90))
+		p.LatencyP99 = ptypes.DurationProto(t.e2e.percentile(99))
+	}
+	return p
+}
+
+// StreamProgress implements event_state.EventsRecorder, reporting the progress
+// of the run right away then at the requested interval, until all the records
+// are received, when it sends a last complete report.
+func (ag *Aggregator) StreamProgress(in *pb.ProgressRequest, stream pb.EventsRecorder_StreamProgressServer) error {
+	interval := defaultProgressInterval
+	if in.Interval != nil {
+		d, err := ptypes.Duration(in.Interval)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid progress interval: %v", err)
+		}
+		if d < minProgressInterval {
+			return status.Errorf(codes.InvalidArgument, "invalid progress interval %v, must be at least %v", d, minProgressInterval)
+		}
+		interval = d
+	}
+	log.Printf("Streaming the progress to %q every %v", in.Source, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for complete := false; ; {
+		select {
+		case <-ag.recordsComplete:
+			complete = true
+		default:
+		}
+		if err := stream.Send(ag.progress(complete)); err != nil {
+			log.Printf("!! Stopped streaming the progress to %q: %v", in.Source, err)
+			return err
+		}
+		if complete {
+			log.Printf("Received all the records, ending the stream of progress to %q", in.Source)
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ag.recordsComplete:
+		case <-stream.Context().Done():
+			log.Printf("%q stopped streaming the progress", in.Source)
+			return nil
+		}
+	}
+}
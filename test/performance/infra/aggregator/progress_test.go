@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/duration"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestProgress(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		ag := newTestAggregator(t, 3, WithStreamingAggregation(streaming))
+		ag.listener.Close()
+
+		now := time.Now()
+		recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_SENT, now, "1", "2", "3"),
+			newRecord(pb.EventsRecord_ACCEPTED, now, "1", "2", "3"),
+		}})
+		recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "1", "2"),
+		}})
+
+		p := ag.progress(false)
+		if p.RecordsReceived != 2 || p.RecordsExpected != 3 || p.Complete {
+			t.Errorf("streaming %t: got %d of %d records, complete %t, want 2 of 3, incomplete",
+				streaming, p.RecordsReceived, p.RecordsExpected, p.Complete)
+		}
+		if p.Sent != 3 || p.Accepted != 3 || p.Received != 2 || p.Matched != 2 {
+			t.Errorf("streaming %t: got %d sent, %d accepted, %d received and %d matched events, want 3, 3, 2 and 2",
+				streaming, p.Sent, p.Accepted, p.Received, p.Matched)
+		}
+		for name, d := range map[string]*duration.Duration{"p50": p.LatencyP50, "p90": p.LatencyP90, "p99": p.LatencyP99} {
+			latency, err := ptypes.Duration(d)
+			// within the precision of the histogram
+			if err != nil || latency < 10*time.Millisecond || latency > 10*time.Millisecond+10*time.Microsecond {
+				t.Errorf("streaming %t: %s latency = %v, %v, want 10ms", streaming, name, latency, err)
+			}
+		}
+	}
+}
+
+func TestProgressDoesNotLockTheEvents(t *testing.T) {
+	ag := newTestAggregator(t, 2)
+	ag.listener.Close()
+
+	now := time.Now()
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1", "2"),
+	}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "2", "1"),
+	}})
+
+	ag.sentEvents.Lock()
+	ag.receivedEvents.Lock()
+	defer ag.receivedEvents.Unlock()
+	defer ag.sentEvents.Unlock()
+	reported := make(chan *pb.Progress, 1)
+	go func() { reported <- ag.progress(false) }()
+	select {
+	case p := <-reported:
+		if p.Sent != 2 || p.Received != 2 || p.Matched != 2 {
+			t.Errorf("got %d sent, %d received and %d matched events, want 2, 2 and 2", p.Sent, p.Received, p.Matched)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress() blocked on the locked events records")
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	ag := newTestAggregator(t, 2)
+	done := runAggregator(context.Background(), ag)
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+
+	if err := client.StreamProgress(context.Background(), "operator", time.Millisecond, func(*pb.Progress) {}); err == nil {
+		t.Error("StreamProgress() with an interval below the minimum succeeded")
+	}
+
+	reports := make(chan *pb.Progress, 16)
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- client.StreamProgress(context.Background(), "operator", time.Hour, func(p *pb.Progress) {
+			reports <- p
+		})
+	}()
+	// reported right away, without waiting for the interval
+	select {
+	case p := <-reports:
+		if p.RecordsReceived != 0 || p.Complete {
+			t.Errorf("first report of %d records, complete %t, want none, incomplete", p.RecordsReceived, p.Complete)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the first progress report")
+	}
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "1"),
+	}})
+
+	select {
+	case p := <-reports:
+		if !p.Complete || p.RecordsReceived != 2 || p.Matched != 1 {
+			t.Errorf("last report of %d records and %d matched events, complete %t, want 2 and 1, complete",
+				p.RecordsReceived, p.Matched, p.Complete)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the complete progress report")
+	}
+	select {
+	case err := <-streamed:
+		if err != nil {
+			t.Error("StreamProgress() =", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the end of the stream")
+	}
+	waitForRun(t, done)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// publishLatencyCohorts returns the sorted publish latencies of the accepted
+// events whose publish attempt is known, split between the ones accepted on
+// their first attempt and the ones accepted after being retried.
+func publishLatencyCohorts(events []event) (first, retried []time.Duration) {
+	for i := range events {
+		e := &events[i]
+		if !e.isAccepted || e.publishAttempt == 0 {
+			continue
+		}
+		if e.publishAttempt == 1 {
+			first = append(first, e.publishLatency())
+		} else {
+			retried = append(retried, e.publishLatency())
+		}
+	}
+	sortDurations(first)
+	sortDurations(retried)
+	return first, retried
+}
+
+// reportPublishLatencyCohorts logs the distributions of the publish latencies
+// of the events accepted on their first attempt and of the retried ones, which
+// tell how much the backpressure of the ingress inflates the publish latency,
+// returning their percentiles keyed by Mako value key, if any accepted event
+// has a known publish attempt.
+func reportPublishLatencyCohorts(events []event) map[string]time.Duration {
+	first, retried := publishLatencyCohorts(events)
+	if len(first)+len(retried) == 0 {
+		log.Printf("Publish latency by attempt undefined without accepted events with a known publish attempt")
+		return nil
+	}
+	aggregates := make(map[string]time.Duration)
+	for k, v := range reportDistribution("Publish latency on the first attempt", "pf", first) {
+		aggregates[k] = v
+	}
+	for k, v := range reportDistribution("Publish latency after retries", "pr", retried) {
+		aggregates[k] = v
+	}
+	return aggregates
+}
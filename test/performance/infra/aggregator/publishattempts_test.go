@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestPublishLatencyCohorts(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+
+	sent := newRecord(pb.EventsRecord_SENT, now, "first", "retried-1", "retried-2", "unknown", "rejected")
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+	accepted := newRecord(pb.EventsRecord_ACCEPTED, now.Add(time.Millisecond), "first", "unknown")
+	accepted.Events["retried-1"], _ = ptypes.TimestampProto(now.Add(30 * time.Millisecond))
+	accepted.Events["retried-2"], _ = ptypes.TimestampProto(now.Add(50 * time.Millisecond))
+	accepted.Attempts = map[string]uint32{"first": 1, "retried-1": 2, "retried-2": 4, "rejected": 3}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{accepted}})
+
+	// the event of unknown attempt and the rejected one don't count
+	first, retried := publishLatencyCohorts(ag.joinEvents())
+	if want := []time.Duration{time.Millisecond}; !reflect.DeepEqual(first, want) {
+		t.Errorf("first attempt publish latencies = %v, want %v", first, want)
+	}
+	if want := []time.Duration{30 * time.Millisecond, 50 * time.Millisecond}; !reflect.DeepEqual(retried, want) {
+		t.Errorf("retried publish latencies = %v, want %v", retried, want)
+	}
+
+	q := newFakeAggregatesStore()
+	(&runAggregates{publishCohorts: reportPublishLatencyCohorts(ag.joinEvents())}).publish(q)
+	if got, want := q.run["pf99"], time.Millisecond.Seconds(); got != want {
+		t.Errorf("published pf99 = %v, want %v", got, want)
+	}
+	if got, want := q.run["pr99"], (50 * time.Millisecond).Seconds(); got != want {
+		t.Errorf("published pr99 = %v, want %v", got, want)
+	}
+}
+
+func TestPublishLatencyCohortsWithoutAttempts(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	events := []event{
+		{id: "accepted", sent: sent, accepted: sent.Add(time.Millisecond), isAccepted: true},
+		{id: "rejected", sent: sent, publishAttempt: 2},
+	}
+	if aggregates := reportPublishLatencyCohorts(events); aggregates != nil {
+		t.Errorf("publish latency cohorts without accepted events with a known attempt = %v, want none", aggregates)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"sync"
+	"time"
+)
+
+// recorderTimer collects the times RecordEvents spends merging the events
+// record lists, waiting for the locks of the events records included.
+type recorderTimer struct {
+	sync.Mutex
+	times []time.Duration
+}
+
+// observe records the time spent merging an events record list.
+func (r *recorderTimer) observe(d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.times = append(r.times, d)
+}
+
+// sorted returns the sorted times spent merging the events record lists.
+func (r *recorderTimer) sorted() []time.Duration {
+	r.Lock()
+	times := make([]time.Duration, len(r.times))
+	copy(times, r.times)
+	r.Unlock()
+	sortDurations(times)
+	return times
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRecorderTimeReport(t *testing.T) {
+	const mergeDelay = 50 * time.Millisecond
+	ag := newTestAggregator(t, 2, WithRecorderTimeReport(true))
+	now := time.Now()
+
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+	}})
+	// hold the lock of the received events, as a slow merge would
+	ag.receivedEvents.Lock()
+	recorded := make(chan struct{})
+	go func() {
+		defer close(recorded)
+		if _, err := ag.RecordEvents(context.Background(), &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_RECEIVED, now, "1"),
+		}}); err != nil {
+			t.Error("RecordEvents() =", err)
+		}
+	}()
+	time.Sleep(mergeDelay)
+	ag.receivedEvents.Unlock()
+	<-recorded
+
+	times := ag.recorderTimes.sorted()
+	if len(times) != 2 {
+		t.Fatalf("measured %d recorder times, want 2", len(times))
+	}
+	if times[1] < mergeDelay {
+		t.Errorf("slowest recorder time = %v, want at least the merge delay of %v", times[1], mergeDelay)
+	}
+
+	a := runAggregates{
+		minSamplesForPercentiles: defaultMinSamplesForPercentiles,
+		recorderTimes:            reportDistribution("Recorder processing time", "rp", times),
+	}
+	q := newFakeAggregatesStore()
+	a.publish(q)
+	if got := q.run["rp99"]; got < mergeDelay.Seconds() {
+		t.Errorf("published recorder time p99 = %vs, want at least %vs", got, mergeDelay.Seconds())
+	}
+	if _, ok := q.run["rp50"]; !ok {
+		t.Error("recorder time p50 not published")
+	}
+}
+
+func TestRecorderTimeNotMeasuredByDefault(t *testing.T) {
+	ag := newTestAggregator(t, 1)
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, time.Now(), "1"),
+	}})
+	if ag.recorderTimes != nil {
+		t.Error("recorder times measured without being enabled")
+	}
+}
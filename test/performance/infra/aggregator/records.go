@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// recordsCounter counts the events records received, waking up the waiter on
+// each of them. Counting never blocks, so that the records received before the
+// aggregator starts waiting for them don't hold the RPC handlers.
+type recordsCounter struct {
+	count uint64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// set to stop waiting whatever the count
+	interrupted bool
+}
+
+func newRecordsCounter() *recordsCounter {
+	c := &recordsCounter{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// add counts an events record.
+func (c *recordsCounter) add() {
+	atomic.AddUint64(&c.count, 1)
+	// the waiter checks the count with the lock held, so that it can't miss
+	// the broadcast between its check and its wait
+	c.mu.Lock()
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+// value returns the number of events records counted so far.
+func (c *recordsCounter) value() uint {
+	return uint(atomic.LoadUint64(&c.count))
+}
+
+// interrupt stops the waiting, whatever the count.
+func (c *recordsCounter) interrupt() {
+	c.mu.Lock()
+	c.interrupted = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+// waitFor blocks until n events records are counted or the waiting is
+// interrupted, returning the number of events records counted.
+func (c *recordsCounter) waitFor(n uint) uint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.value() < n && !c.interrupted {
+		c.cond.Wait()
+	}
+	return c.value()
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRecordsAtStartupDontBlock(t *testing.T) {
+	const senders = 50
+	ag := newTestAggregator(t, senders)
+	// serve without waiting for the records, as before waitForEvents starts
+	go ag.server.Serve(ag.listener)
+	defer ag.server.Stop()
+
+	now := time.Now()
+	uploaded := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprint(i)
+			client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+			if err != nil {
+				t.Error("Failed to create the aggregator client:", err)
+				return
+			}
+			defer client.Close()
+			if err := client.Publish(&pb.EventsRecordList{Items: []*pb.EventsRecord{
+				newRecord(pb.EventsRecord_SENT, now, id),
+				newRecord(pb.EventsRecord_ACCEPTED, now, id),
+			}}); err != nil {
+				t.Error("Failed to publish the events records:", err)
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(uploaded)
+	}()
+	select {
+	case <-uploaded:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timeout waiting for the uploads, which block until the aggregator waits for them")
+	}
+
+	if got := ag.records.value(); got != senders {
+		t.Errorf("counted %d events records, want %d", got, senders)
+	}
+	if !ag.waitForEvents() {
+		t.Error("waitForEvents() = false, want all the records received at startup counted")
+	}
+	if got := len(ag.sentEvents.Events); got != senders {
+		t.Errorf("recorded %d sent events, want %d", got, senders)
+	}
+}
+
+func TestRecordsCounterInterrupt(t *testing.T) {
+	c := newRecordsCounter()
+	c.add()
+	waited := make(chan uint)
+	go func() {
+		waited <- c.waitFor(2)
+	}()
+	select {
+	case n := <-waited:
+		t.Fatalf("waitFor(2) = %d before the second record or the interruption", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.interrupt()
+	select {
+	case n := <-waited:
+		if n != 1 {
+			t.Errorf("waitFor(2) = %d, want 1", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the interrupted waitFor")
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// sourceBatches counts the events record lists a registered source announced
+// and uploaded.
+type sourceBatches struct {
+	source   string
+	expected uint32
+	received uint32
+	// whether the source uploaded its final records
+	done bool
+}
+
+// registeredSources tracks the events record lists uploaded by the registered
+// senders and receivers, closing allDone once all of them are done. As the
+// sources which register late, or fail to, aren't known then, allDone only
+// tells the run is complete once as many sources as expected registered.
+type registeredSources struct {
+	sync.Mutex
+	sources map[string]*sourceBatches
+	allDone chan struct{}
+	closed  bool
+}
+
+func newRegisteredSources() *registeredSources {
+	return &registeredSources{
+		sources: make(map[string]*sourceBatches),
+		allDone: make(chan struct{}),
+	}
+}
+
+// register announces the source, which will upload the given number of events
+// record lists.
+func (r *registeredSources) register(source string, expected uint32) {
+	r.Lock()
+	defer r.Unlock()
+	if b, exists := r.sources[source]; exists {
+		log.Printf("!! %q registered again, expecting %d events record lists instead of %d", source, expected, b.expected)
+		b.expected = expected
+		return
+	}
+	if r.closed {
+		log.Printf("!! %q registered after all the registered sources were done", source)
+	}
+	r.sources[source] = &sourceBatches{source: source, expected: expected}
+}
+
+// count returns the number of the registered sources.
+func (r *registeredSources) count() uint {
+	r.Lock()
+	defer r.Unlock()
+	return uint(len(r.sources))
+}
+
+// record counts the given events record list, if its source registered.
+func (r *registeredSources) record(in *pb.EventsRecordList) {
+	r.Lock()
+	defer r.Unlock()
+	b, ok := r.sources[in.Source]
+	if !ok {
+		return
+	}
+	b.received++
+	if in.Final {
+		b.done = true
+	}
+	if r.closed {
+		return
+	}
+	for _, b := range r.sources {
+		if !b.done {
+			return
+		}
+	}
+	r.closed = true
+	close(r.allDone)
+}
+
+// underdelivered returns the registered sources which uploaded less events
+// record lists than they announced, or not their final ones, sorted by source.
+func (r *registeredSources) underdelivered() []sourceBatches {
+	r.Lock()
+	defer r.Unlock()
+	var under []sourceBatches
+	for _, b := range r.sources {
+		if b.received < b.expected || !b.done {
+			under = append(under, *b)
+		}
+	}
+	sort.Slice(under, func(i, j int) bool { return under[i].source < under[j].source })
+	return under
+}
+
+// reportUnderdelivered logs the registered sources which underdelivered.
+func (r *registeredSources) reportUnderdelivered() {
+	r.Lock()
+	registered := len(r.sources)
+	r.Unlock()
+	if registered == 0 {
+		return
+	}
+	under := r.underdelivered()
+	if len(under) == 0 {
+		log.Printf("All the %d registered senders and receivers uploaded their events records", registered)
+		return
+	}
+	log.Printf("!! %d of the %d registered senders and receivers underdelivered, their events may be missing from the results:",
+		len(under), registered)
+	for _, b := range under {
+		log.Printf("  %s: %d of %d events record lists, final received: %v", b.source, b.received, b.expected, b.done)
+	}
+}
+
+// Register implements event_state.EventsRecorder
+func (ag *Aggregator) Register(_ context.Context, in *pb.RegisterRequest) (*pb.RegisterReply, error) {
+	if in.Source == "" {
+		return nil, status.Error(codes.InvalidArgument, "the source is required")
+	}
+	if in.ExpectedBatches == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the number of expected events record lists must be positive")
+	}
+	if err := ag.writeCheckpoint(ag.now(), in); err != nil {
+		return nil, err
+	}
+	ag.registered.register(in.Source, in.ExpectedBatches)
+	log.Printf("Registered %q, expecting %d events record lists", in.Source, in.ExpectedBatches)
+	return &pb.RegisterReply{}, nil
+}
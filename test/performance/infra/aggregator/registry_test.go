@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRegisteredSenderUnderdelivered(t *testing.T) {
+	// more expected records than uploaded, so that only the registered sources complete the run
+	ag := newTestAggregator(t, 10)
+	done := runAggregator(context.Background(), ag)
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	for source, batches := range map[string]uint32{"sender-a": 2, "sender-b": 1, "receiver": 1} {
+		if err := client.Register(source, batches); err != nil {
+			t.Fatalf("Failed to register %s: %v", source, err)
+		}
+	}
+
+	now := time.Now()
+	// sender-a dies after its first records, which it flags as final on its way down
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "sender-a", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "a1"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "sender-b", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "b1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "b1"),
+	}})
+	select {
+	case <-ag.recordsComplete:
+		t.Fatal("completed before all the registered sources were done")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "receiver", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now, "a1", "b1"),
+	}})
+	waitForRun(t, done)
+
+	under := ag.registered.underdelivered()
+	if len(under) != 1 {
+		t.Fatalf("underdelivered = %+v, want only sender-a", under)
+	}
+	if got, want := under[0], (sourceBatches{source: "sender-a", expected: 2, received: 1, done: true}); got != want {
+		t.Errorf("underdelivered = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegisteredSendersDoneBeforeALateRegistration(t *testing.T) {
+	ag := newTestAggregator(t, 2)
+	done := runAggregator(context.Background(), ag)
+
+	client, err := pb.NewAggregatorClient(ag.listener.Addr().String())
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer client.Close()
+	if err := client.Register("sender", 1); err != nil {
+		t.Fatal("Failed to register the sender:", err)
+	}
+
+	// the receiver hasn't registered yet when the only registered sender is done
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Source: "sender", Final: true, Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1"),
+	}})
+	waitForRun(t, done)
+
+	if missing := ag.reportedMissingRecords(); missing == nil || *missing != 1 {
+		t.Errorf("reportedMissingRecords() = %v, want 1 of the records of the late receiver", missing)
+	}
+}
+
+func TestRegisterRejectsInvalidRequests(t *testing.T) {
+	ag := newTestAggregator(t, 1)
+	for _, in := range []*pb.RegisterRequest{
+		{ExpectedBatches: 1},
+		{Source: "sender"},
+	} {
+		if _, err := ag.Register(context.Background(), in); err == nil {
+			t.Errorf("Register(%v) = nil error, want the request rejected", in)
+		}
+	}
+}
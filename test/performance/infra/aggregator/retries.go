@@ -0,0 +1,55 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import "log"
+
+// retryRateKey is the Mako value key of the retry rate.
+const retryRateKey = "rr"
+
+// retryRate returns the fraction of the delivered events whose delivery
+// attempt is known which were delivered after more than one attempt, and the
+// number of such events.
+func retryRate(events []event) (float64, int) {
+	var known, retried int
+	for i := range events {
+		e := &events[i]
+		if !e.isReceived || e.deliveryAttempt == 0 {
+			continue
+		}
+		known++
+		if e.deliveryAttempt > 1 {
+			retried++
+		}
+	}
+	if known == 0 {
+		return 0, 0
+	}
+	return float64(retried) / float64(known), known
+}
+
+// reportRetryRate logs the retry rate of the events, returning it if any
+// delivered event has a known delivery attempt.
+func reportRetryRate(events []event) *float64 {
+	r, n := retryRate(events)
+	if n == 0 {
+		log.Printf("Retry rate undefined without delivered events with a known delivery attempt")
+		return nil
+	}
+	log.Printf("Retry rate: %.2f%% of %d delivered events with a known delivery attempt", r*100, n)
+	return &r
+}
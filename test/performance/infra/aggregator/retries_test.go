@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRetryRate(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+
+	sent := newRecord(pb.EventsRecord_SENT, now, "first", "retried-1", "retried-2", "unknown", "lost")
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+	received := newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "first", "retried-1", "retried-2", "unknown")
+	received.Attempts = map[string]uint32{"first": 1, "retried-1": 2, "retried-2": 5, "lost": 3}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{received}})
+
+	// the event of unknown attempt and the lost one don't count
+	rate := reportRetryRate(ag.joinEvents())
+	if rate == nil || *rate != 2.0/3 {
+		t.Fatalf("retry rate = %v, want 2/3", rate)
+	}
+	q := newFakeAggregatesStore()
+	(&runAggregates{retryRate: rate}).publish(q)
+	if got := q.run[retryRateKey]; got != 2.0/3 {
+		t.Errorf("published retry rate = %v, want 2/3", got)
+	}
+}
+
+func TestRetryRateWithoutAttempts(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	events := []event{
+		{id: "delivered", sent: sent, received: sent.Add(time.Millisecond), isReceived: true},
+		{id: "lost", sent: sent, deliveryAttempt: 2},
+	}
+	if rate := reportRetryRate(events); rate != nil {
+		t.Errorf("retry rate without delivered events with a known attempt = %v, want none", *rate)
+	}
+	q := newFakeAggregatesStore()
+	(&runAggregates{}).publish(q)
+	if _, ok := q.run[retryRateKey]; ok {
+		t.Error("published a retry rate without delivered events with a known attempt")
+	}
+}
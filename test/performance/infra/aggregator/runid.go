@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"knative.dev/pkg/test/mako"
+)
+
+// runIDTag returns the Mako tag identifying the results of the given run.
+func runIDTag(runID string) string {
+	return "run-id=" + mako.EscapeTag(runID)
+}
+
+// runFilePath inserts the run ID before the extension of the file name of the
+// given path, so that the exports of different runs don't collide.
+func runFilePath(path string, runID string) string {
+	if path == "" || runID == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + runID + ext
+}
+
+// newRunID generates a unique run ID, made of the current UTC time and a random suffix.
+func newRunID() string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		// the time alone is unique enough for runs which don't start in the same second
+		return time.Now().UTC().Format("20060102-150405")
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// applyRunID tags the published results and names the exported files with the
+// run ID, generating one if none was given.
+func (ag *Aggregator) applyRunID() {
+	if ag.runID == "" {
+		ag.runID = newRunID()
+	}
+	ag.makoTags = append(ag.makoTags, runIDTag(ag.runID))
+	ag.sqlitePath = runFilePath(ag.sqlitePath, ag.runID)
+	ag.heatmapPath = runFilePath(ag.heatmapPath, ag.runID)
+	ag.plotDataPath = runFilePath(ag.plotDataPath, ag.runID)
+	ag.timelinePath = runFilePath(ag.timelinePath, ag.runID)
+	ag.cdfPath = runFilePath(ag.cdfPath, ag.runID)
+	ag.hdrHistogramPath = runFilePath(ag.hdrHistogramPath, ag.runID)
+	ag.duplicatesPath = runFilePath(ag.duplicatesPath, ag.runID)
+	ag.latencySamplesPath = runFilePath(ag.latencySamplesPath, ag.runID)
+	ag.summaryPath = runFilePath(ag.summaryPath, ag.runID)
+	ag.throughputSeriesPath = runFilePath(ag.throughputSeriesPath, ag.runID)
+	ag.shortSummaryPath = runFilePath(ag.shortSummaryPath, ag.runID)
+	ag.csvPath = runFilePath(ag.csvPath, ag.runID)
+	ag.parquetPath = runFilePath(ag.parquetPath, ag.runID)
+	if ag.ndjsonPath != ndjsonStdout {
+		ag.ndjsonPath = runFilePath(ag.ndjsonPath, ag.runID)
+	}
+	if ag.failedEventsPath != failedEventsLog {
+		ag.failedEventsPath = runFilePath(ag.failedEventsPath, ag.runID)
+	}
+}
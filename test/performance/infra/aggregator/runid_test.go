@@ -0,0 +1,82 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunID(t *testing.T) {
+	ag := newTestAggregator(t, 1,
+		WithSQLite("/results/events.db"),
+		WithRunID("campaign-3"),
+		WithHeatmap("/results/heatmap.json", time.Second),
+	)
+	defer ag.listener.Close()
+
+	var tagged bool
+	for _, tag := range ag.makoTags {
+		if tag == "run-id=campaign-3" {
+			tagged = true
+		}
+	}
+	if !tagged {
+		t.Errorf("makoTags = %v, want the run-id=campaign-3 tag", ag.makoTags)
+	}
+	if want := "/results/events-campaign-3.db"; ag.sqlitePath != want {
+		t.Errorf("sqlitePath = %s, want %s", ag.sqlitePath, want)
+	}
+	if want := "/results/heatmap-campaign-3.json"; ag.heatmapPath != want {
+		t.Errorf("heatmapPath = %s, want %s", ag.heatmapPath, want)
+	}
+}
+
+func TestGeneratedRunID(t *testing.T) {
+	ag := newTestAggregator(t, 1, WithSQLite("/results/events.db"))
+	defer ag.listener.Close()
+	other := newTestAggregator(t, 1)
+	defer other.listener.Close()
+
+	if ag.runID == "" {
+		t.Fatal("runID is empty, want a generated one")
+	}
+	if ag.runID == other.runID {
+		t.Errorf("both aggregators generated the run ID %s, want unique ones", ag.runID)
+	}
+	if want := runIDTag(ag.runID); ag.makoTags[len(ag.makoTags)-1] != want {
+		t.Errorf("makoTags = %v, want the %s tag", ag.makoTags, want)
+	}
+	if want := "/results/events-" + ag.runID + ".db"; ag.sqlitePath != want {
+		t.Errorf("sqlitePath = %s, want %s", ag.sqlitePath, want)
+	}
+}
+
+func TestRunFilePath(t *testing.T) {
+	for _, tc := range []struct {
+		path, runID, want string
+	}{
+		{"/results/events.db", "r1", "/results/events-r1.db"},
+		{"events", "r1", "events-r1"},
+		{"/results/events.db", "", "/results/events.db"},
+		{"", "r1", ""},
+	} {
+		if got := runFilePath(tc.path, tc.runID); got != tc.want {
+			t.Errorf("runFilePath(%q, %q) = %q, want %q", tc.path, tc.runID, got, tc.want)
+		}
+	}
+}
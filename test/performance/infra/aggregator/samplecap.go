@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// percentileSampleCapTag returns the Mako tag labelling the results whose
+// percentiles are computed over at most n events.
+func percentileSampleCapTag(n int) string {
+	return fmt.Sprintf("percentile-sample-cap=%d", n)
+}
+
+// percentileSample returns the first n delivered events by sent time, then by
+// ID, over which the percentiles are computed when the sample is capped, or
+// all the delivered events if fewer.
+func percentileSample(events []event, n int) []event {
+	delivered := make([]event, 0, len(events))
+	for i := range events {
+		if events[i].isReceived {
+			delivered = append(delivered, events[i])
+		}
+	}
+	sort.Slice(delivered, func(i, j int) bool {
+		if !delivered[i].sent.Equal(delivered[j].sent) {
+			return delivered[i].sent.Before(delivered[j].sent)
+		}
+		return delivered[i].id < delivered[j].id
+	})
+	if len(delivered) > n {
+		delivered = delivered[:n]
+	}
+	return delivered
+}
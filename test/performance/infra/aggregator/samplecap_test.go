@@ -0,0 +1,69 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestPercentileSample(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+	// sent out of order, "lost" being the earliest but never delivered
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now.Add(3*time.Millisecond), "c"),
+		newRecord(pb.EventsRecord_SENT, now.Add(time.Millisecond), "b", "a"),
+		newRecord(pb.EventsRecord_SENT, now, "lost"),
+		newRecord(pb.EventsRecord_SENT, now.Add(2*time.Millisecond), "d"),
+		newRecord(pb.EventsRecord_ACCEPTED, now.Add(4*time.Millisecond), "a", "b", "c", "d", "lost"),
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(5*time.Millisecond), "a", "b", "c", "d"),
+	}})
+	events := ag.joinEvents()
+
+	for _, tc := range []struct {
+		n    int
+		want []string
+	}{
+		{n: 3, want: []string{"a", "b", "d"}},
+		{n: 10, want: []string{"a", "b", "d", "c"}},
+	} {
+		sample := percentileSample(events, tc.n)
+		var got []string
+		for _, e := range sample {
+			got = append(got, e.id)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("percentileSample(%d) = %v, want %v", tc.n, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("percentileSample(%d) = %v, want %v", tc.n, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestInvalidPercentileSampleCap(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, WithPercentileSampleCap(-1)); err == nil {
+		t.Error("NewAggregator() with a negative percentile sample cap = nil error, want it rejected")
+	}
+}
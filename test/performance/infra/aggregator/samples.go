@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// latencySamplesMagic starts the latency samples files.
+const latencySamplesMagic = "KLAT"
+
+// latencySamplesVersion is the version of the layout of the latency samples files.
+const latencySamplesVersion uint32 = 1
+
+// latencySamplesHeader is the fixed size header of the latency samples files,
+// all little-endian:
+//   - bytes 0-3: the magic "KLAT"
+//   - bytes 4-7: the version of the layout, as a uint32, 1
+//   - bytes 8-15: the number of publish latencies, as a uint64
+//   - bytes 16-23: the number of end to end latencies, as a uint64
+//
+// followed by the publish latencies then the end to end latencies, each an
+// int64 number of nanoseconds, sorted in increasing order.
+type latencySamplesHeader struct {
+	Magic      [4]byte
+	Version    uint32
+	PublishLen uint64
+	E2ELen     uint64
+}
+
+// writeLatencySamples writes the publish and end to end latencies in the
+// layout described by latencySamplesHeader.
+func writeLatencySamples(w io.Writer, publish, e2e []time.Duration) error {
+	bw := bufio.NewWriter(w)
+	header := latencySamplesHeader{
+		Version:    latencySamplesVersion,
+		PublishLen: uint64(len(publish)),
+		E2ELen:     uint64(len(e2e)),
+	}
+	copy(header.Magic[:], latencySamplesMagic)
+	if err := binary.Write(bw, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	var buf [8]byte
+	for _, latencies := range [][]time.Duration{publish, e2e} {
+		for _, d := range latencies {
+			binary.LittleEndian.PutUint64(buf[:], uint64(d.Nanoseconds()))
+			if _, err := bw.Write(buf[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// exportLatencySamples writes the publish and end to end latencies to the file
+// at the given path.
+func exportLatencySamples(path string, publish, e2e []time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := writeLatencySamples(f, publish, e2e); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// ReadLatencySamples reads the publish and end to end latencies written with
+// WithLatencySamplesExport.
+func ReadLatencySamples(r io.Reader) (publish, e2e []time.Duration, err error) {
+	br := bufio.NewReader(r)
+	var header latencySamplesHeader
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return nil, nil, fmt.Errorf("failed to read the header: %v", err)
+	}
+	if string(header.Magic[:]) != latencySamplesMagic {
+		return nil, nil, fmt.Errorf("not a latency samples file, starting with %q", header.Magic[:])
+	}
+	if header.Version != latencySamplesVersion {
+		return nil, nil, fmt.Errorf("unsupported latency samples version %d", header.Version)
+	}
+	read := func(n uint64, name string) ([]time.Duration, error) {
+		latencies := make([]time.Duration, 0, minUint64(n, 1<<20))
+		var buf [8]byte
+		for i := uint64(0); i < n; i++ {
+			if _, err := io.ReadFull(br, buf[:]); err != nil {
+				return nil, fmt.Errorf("failed to read the %s latency %d of %d: %v", name, i+1, n, err)
+			}
+			latencies = append(latencies, time.Duration(int64(binary.LittleEndian.Uint64(buf[:]))))
+		}
+		return latencies, nil
+	}
+	if publish, err = read(header.PublishLen, "publish"); err != nil {
+		return nil, nil, err
+	}
+	if e2e, err = read(header.E2ELen, "end to end"); err != nil {
+		return nil, nil, err
+	}
+	return publish, e2e, nil
+}
+
+// LoadLatencySamples reads the latency samples file at the given path.
+func LoadLatencySamples(path string) (publish, e2e []time.Duration, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	if publish, e2e, err = ReadLatencySamples(f); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return publish, e2e, nil
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
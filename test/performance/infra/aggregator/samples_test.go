@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLatencySamplesRoundTrip(t *testing.T) {
+	publish := []time.Duration{time.Millisecond, 2 * time.Millisecond}
+	e2e := []time.Duration{time.Microsecond, 3 * time.Millisecond, time.Minute}
+	path := filepath.Join(t.TempDir(), "samples.bin")
+	if err := exportLatencySamples(path, publish, e2e); err != nil {
+		t.Fatal("Failed to export the latency samples:", err)
+	}
+
+	gotPublish, gotE2E, err := LoadLatencySamples(path)
+	if err != nil {
+		t.Fatal("Failed to load the latency samples:", err)
+	}
+	if !reflect.DeepEqual(gotPublish, publish) || !reflect.DeepEqual(gotE2E, e2e) {
+		t.Errorf("loaded %v and %v, want %v and %v", gotPublish, gotE2E, publish, e2e)
+	}
+}
+
+func TestLatencySamplesLayout(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeLatencySamples(&b, []time.Duration{1}, []time.Duration{2, 3}); err != nil {
+		t.Fatal("Failed to write the latency samples:", err)
+	}
+	raw := b.Bytes()
+	if got, want := len(raw), 24+3*8; got != want {
+		t.Fatalf("wrote %d bytes, want %d", got, want)
+	}
+	if string(raw[:4]) != "KLAT" || binary.LittleEndian.Uint32(raw[4:]) != 1 ||
+		binary.LittleEndian.Uint64(raw[8:]) != 1 || binary.LittleEndian.Uint64(raw[16:]) != 2 {
+		t.Errorf("header = %x, want KLAT, version 1 and counts 1 and 2", raw[:24])
+	}
+	for i, want := range []int64{1, 2, 3} {
+		if got := int64(binary.LittleEndian.Uint64(raw[24+8*i:])); got != want {
+			t.Errorf("latency %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestInvalidLatencySamples(t *testing.T) {
+	var truncated bytes.Buffer
+	if err := writeLatencySamples(&truncated, []time.Duration{1, 2}, nil); err != nil {
+		t.Fatal("Failed to write the latency samples:", err)
+	}
+	for name, in := range map[string][]byte{
+		"empty":     nil,
+		"not ours":  []byte("{\"id\":\"1\"}\n0123456789abcdefghijklmn"),
+		"truncated": truncated.Bytes()[:truncated.Len()-1],
+	} {
+		if _, _, err := ReadLatencySamples(bytes.NewReader(in)); err == nil {
+			t.Errorf("ReadLatencySamples(%s) = nil error, want it rejected", name)
+		}
+	}
+}
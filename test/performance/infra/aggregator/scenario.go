@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/golang/protobuf/proto"
+	"knative.dev/pkg/test/mako"
+
+	tpb "github.com/google/mako/clients/proto/analyzers/threshold_analyzer_go_proto"
+)
+
+// scenarioPattern restricts the scenario labels to the characters allowed in
+// Mako value keys, as they may be suffixed to them.
+var scenarioPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// validateScenario checks that the scenario label, if any, can be suffixed to value keys.
+func validateScenario(scenario string) error {
+	if scenario != "" && !scenarioPattern.MatchString(scenario) {
+		return fmt.Errorf("invalid scenario %q, must only contain letters, digits and underscores", scenario)
+	}
+	return nil
+}
+
+// scenarioTag returns the Mako tag identifying the results of the given scenario.
+func scenarioTag(scenario string) string {
+	return "scenario=" + mako.EscapeTag(scenario)
+}
+
+// scenarioKey returns the value key of the given scenario, e.g. "dl_1kb" for
+// the deliver latency of the "1kb" scenario.
+func scenarioKey(key, scenario string) string {
+	return key + "_" + scenario
+}
+
+// resultsStore is the part of the exporter the results are published to.
+type resultsStore interface {
+	aggregatesStore
+	samplePointStore
+}
+
+// scenarioStore publishes the results under the value keys of a scenario.
+type scenarioStore struct {
+	resultsStore
+	scenario string
+}
+
+func (s scenarioStore) AddSamplePoint(xval float64, valueKeyToYVals map[string]float64) error {
+	yvals := make(map[string]float64, len(valueKeyToYVals))
+	for k, v := range valueKeyToYVals {
+		yvals[scenarioKey(k, s.scenario)] = v
+	}
+	return s.resultsStore.AddSamplePoint(xval, yvals)
+}
+
+func (s scenarioStore) AddRunAggregate(valueKey string, value float64) error {
+	return s.resultsStore.AddRunAggregate(scenarioKey(valueKey, s.scenario), value)
+}
+
+func (s scenarioStore) AddMetricAggregate(valueKey string, aggregateType string, value float64) error {
+	return s.resultsStore.AddMetricAggregate(scenarioKey(valueKey, s.scenario), aggregateType, value)
+}
+
+// scenarioAnalyzer returns a copy of the threshold analyzer watching the value
+// keys of the given scenario.
+func scenarioAnalyzer(in *tpb.ThresholdAnalyzerInput, scenario string) *tpb.ThresholdAnalyzerInput {
+	out := proto.Clone(in).(*tpb.ThresholdAnalyzerInput)
+	for _, c := range out.Configs {
+		if c.DataFilter != nil && c.DataFilter.ValueKey != nil {
+			key := scenarioKey(*c.DataFilter.ValueKey, scenario)
+			c.DataFilter.ValueKey = &key
+		}
+	}
+	return out
+}
+
+// applyScenario tags the published results and names the exported files with
+// the scenario, if any.
+func (ag *Aggregator) applyScenario() {
+	if ag.scenario == "" {
+		return
+	}
+	ag.makoTags = append(ag.makoTags, scenarioTag(ag.scenario))
+	ag.sqlitePath = runFilePath(ag.sqlitePath, ag.scenario)
+	ag.heatmapPath = runFilePath(ag.heatmapPath, ag.scenario)
+	ag.plotDataPath = runFilePath(ag.plotDataPath, ag.scenario)
+	ag.timelinePath = runFilePath(ag.timelinePath, ag.scenario)
+	ag.cdfPath = runFilePath(ag.cdfPath, ag.scenario)
+	ag.hdrHistogramPath = runFilePath(ag.hdrHistogramPath, ag.scenario)
+	ag.duplicatesPath = runFilePath(ag.duplicatesPath, ag.scenario)
+	ag.latencySamplesPath = runFilePath(ag.latencySamplesPath, ag.scenario)
+	ag.summaryPath = runFilePath(ag.summaryPath, ag.scenario)
+	ag.throughputSeriesPath = runFilePath(ag.throughputSeriesPath, ag.scenario)
+	ag.shortSummaryPath = runFilePath(ag.shortSummaryPath, ag.scenario)
+	ag.csvPath = runFilePath(ag.csvPath, ag.scenario)
+	ag.parquetPath = runFilePath(ag.parquetPath, ag.scenario)
+	if ag.ndjsonPath != ndjsonStdout {
+		ag.ndjsonPath = runFilePath(ag.ndjsonPath, ag.scenario)
+	}
+	if ag.failedEventsPath != failedEventsLog {
+		ag.failedEventsPath = runFilePath(ag.failedEventsPath, ag.scenario)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestScenarioStoreSuffixesKeys(t *testing.T) {
+	fake := newFakeAggregatesStore()
+	store := scenarioStore{resultsStore: fake, scenario: "1kb"}
+
+	aggregates := runAggregates{
+		publishErrors:            1,
+		e2eLatencies:             []time.Duration{time.Millisecond},
+		minSamplesForPercentiles: 1,
+	}
+	aggregates.publish(store)
+	if err := publishThpt([]time.Time{time.Unix(0, 0)}, store, "st", throughputSmoothing{}); err != nil {
+		t.Fatal("publishThpt() =", err)
+	}
+
+	if got, ok := fake.run["pe_1kb"]; !ok || got != 1 {
+		t.Errorf("run aggregate pe_1kb = %v (published %v), want 1", got, ok)
+	}
+	if got := fake.samples["st_1kb"]; len(got) != 1 {
+		t.Errorf("sample points of st_1kb = %v, want one", got)
+	}
+	for k := range fake.run {
+		if !strings.HasSuffix(k, "_1kb") {
+			t.Errorf("published value key %q without the scenario suffix", k)
+		}
+	}
+	for k := range fake.samples {
+		if !strings.HasSuffix(k, "_1kb") {
+			t.Errorf("published value key %q without the scenario suffix", k)
+		}
+	}
+}
+
+func TestScenarioAnalyzerWatchesSuffixedKey(t *testing.T) {
+	a := scenarioAnalyzer(pea, "1kb")
+	if got, want := *a.Configs[0].DataFilter.ValueKey, "pet_1kb"; got != want {
+		t.Errorf("analyzer value key = %q, want %q", got, want)
+	}
+	if got, want := *pea.Configs[0].DataFilter.ValueKey, "pet"; got != want {
+		t.Errorf("original analyzer value key = %q, want it unchanged %q", got, want)
+	}
+}
+
+func TestScenarioLabelsArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 1, WithRunID("x"), WithScenario("1kb", true),
+		WithNDJSONExport(filepath.Join(dir, "events.ndjson")),
+		WithHeatmap(filepath.Join(dir, "heatmap.json"), time.Second))
+
+	if got, want := ag.makoTags, []string{"scenario=1kb", "run-id=x"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Mako tags = %v, want %v", got, want)
+	}
+
+	done := runAggregator(context.Background(), ag)
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "1"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "1"),
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(time.Millisecond), "1"),
+	}})
+	waitForRun(t, done)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "events-1kb-x.ndjson"))
+	if err != nil {
+		t.Fatal("Failed to read the NDJSON export:", err)
+	}
+	var e ndjsonEvent
+	if err := json.Unmarshal(content, &e); err != nil {
+		t.Fatal("Failed to decode the NDJSON event:", err)
+	}
+	if e.Scenario != "1kb" {
+		t.Errorf("NDJSON event scenario = %q, want %q", e.Scenario, "1kb")
+	}
+
+	content, err = ioutil.ReadFile(filepath.Join(dir, "heatmap-1kb-x.json"))
+	if err != nil {
+		t.Fatal("Failed to read the heatmap:", err)
+	}
+	var hm heatmap
+	if err := json.Unmarshal(content, &hm); err != nil {
+		t.Fatal("Failed to decode the heatmap:", err)
+	}
+	if hm.Scenario != "1kb" {
+		t.Errorf("heatmap scenario = %q, want %q", hm.Scenario, "1kb")
+	}
+}
+
+func TestInvalidScenarioIsRejected(t *testing.T) {
+	for _, opt := range []AggregatorOption{
+		WithScenario("1 kb", false),
+		WithScenario("", true),
+	} {
+		if _, err := NewAggregator("127.0.0.1:0", 1, nil, false, opt); err == nil {
+			t.Error("NewAggregator() = nil error, want the scenario rejected")
+		}
+	}
+}
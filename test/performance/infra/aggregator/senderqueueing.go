@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"log"
+	"time"
+)
+
+// senderQueueingDelay is the time the event waited in its sender between being
+// sent and going on the wire.
+func (e *event) senderQueueingDelay() time.Duration {
+	return e.onWire.Sub(e.sent)
+}
+
+// netE2ELatency is the time between the event going on the wire and being
+// received, i.e. its end to end latency net of its sender queueing delay.
+func (e *event) netE2ELatency() time.Duration {
+	return e.received.Sub(e.onWire)
+}
+
+// senderQueueing returns the sorted sender queueing delays of the events whose
+// time on the wire was reported, and the sorted end to end latencies net of
+// them of the ones which were received.
+func senderQueueing(events []event) (delays, net []time.Duration) {
+	for i := range events {
+		e := &events[i]
+		if !e.hasOnWire {
+			continue
+		}
+		delays = append(delays, e.senderQueueingDelay())
+		if e.isReceived {
+			net = append(net, e.netE2ELatency())
+		}
+	}
+	sortDurations(delays)
+	sortDurations(net)
+	return delays, net
+}
+
+// reportSenderQueueing logs the distributions of the sender queueing delays and
+// of the end to end latencies net of them, so that the time the events waited
+// in the senders under high offered load isn't mistaken for latency of the
+// system under test, returning the percentiles of both keyed by Mako value key.
+func reportSenderQueueing(events []event) map[string]time.Duration {
+	delays, net := senderQueueing(events)
+	if len(delays) == 0 {
+		log.Printf("Sender queueing delay undefined without sent events reporting their time on the wire")
+		return nil
+	}
+	aggregates := reportDistribution("Sender queueing delay", "sq", delays)
+	for k, v := range reportDistribution("End to end latency net of sender queueing", "nl", net) {
+		aggregates[k] = v
+	}
+	return aggregates
+}
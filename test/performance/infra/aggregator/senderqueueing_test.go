@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestSenderQueueing(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+	onWire := func(d time.Duration) *timestamp.Timestamp {
+		ts, _ := ptypes.TimestampProto(now.Add(d))
+		return ts
+	}
+
+	sent := newRecord(pb.EventsRecord_SENT, now, "queued", "immediate", "lost", "unreported")
+	sent.OnWire = map[string]*timestamp.Timestamp{
+		"queued":    onWire(40 * time.Millisecond),
+		"immediate": onWire(0),
+		"lost":      onWire(10 * time.Millisecond),
+	}
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{sent}})
+	received := newRecord(pb.EventsRecord_RECEIVED, now.Add(50*time.Millisecond), "queued", "immediate", "unreported")
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{received}})
+
+	// the event without time on the wire doesn't count, and the lost one only
+	// counts in the sender queueing delays
+	delays, net := senderQueueing(ag.joinEvents())
+	if got, want := delays, []time.Duration{0, 10 * time.Millisecond, 40 * time.Millisecond}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sender queueing delays = %v, want %v", got, want)
+	}
+	if got, want := net, []time.Duration{10 * time.Millisecond, 50 * time.Millisecond}; !reflect.DeepEqual(got, want) {
+		t.Errorf("net end to end latencies = %v, want %v", got, want)
+	}
+
+	aggregates := reportSenderQueueing(ag.joinEvents())
+	q := newFakeAggregatesStore()
+	(&runAggregates{senderQueueing: aggregates}).publish(q)
+	if got, want := q.run["sq99"], (40 * time.Millisecond).Seconds(); got != want {
+		t.Errorf("published sq99 = %v, want %v", got, want)
+	}
+	if got, want := q.run["nl50"], (10 * time.Millisecond).Seconds(); got != want {
+		t.Errorf("published nl50 = %v, want %v", got, want)
+	}
+}
+
+func TestSenderQueueingWithoutOnWire(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	events := []event{
+		{id: "delivered", sent: sent, received: sent.Add(time.Millisecond), isReceived: true},
+	}
+	if aggregates := reportSenderQueueing(events); aggregates != nil {
+		t.Errorf("sender queueing without time on the wire = %v, want none", aggregates)
+	}
+}
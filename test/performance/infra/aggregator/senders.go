@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"knative.dev/pkg/test/mako"
+)
+
+// unknownSender is used for the events whose records don't carry the sender identity.
+const unknownSender = "unknown"
+
+// allSenders labels the single row of the sender table when no record carries
+// the sender identity.
+const allSenders = "all"
+
+// senderStats aggregates the outcome of the events produced by a single sender.
+type senderStats struct {
+	name            string
+	sent            int
+	publishFailures int
+	deliverFailures int
+	// sorted end to end latencies of the delivered events
+	e2eLatencies []time.Duration
+	// first sent and last received events of the sender
+	firstSent    time.Time
+	lastReceived time.Time
+}
+
+// failures is the number of events of the sender which were not accepted or not delivered.
+func (s *senderStats) failures() int {
+	return s.publishFailures + s.deliverFailures
+}
+
+// tailLatency is the 99th percentile of the end to end latencies of the sender.
+func (s *senderStats) tailLatency() time.Duration {
+	return percentile(s.e2eLatencies, 99)
+}
+
+// throughput is the number of events of the sender received per second, from
+// its first sent to its last received event, 0 if none was received.
+func (s *senderStats) throughput() float64 {
+	if span := s.lastReceived.Sub(s.firstSent); span > 0 {
+		return float64(len(s.e2eLatencies)) / span.Seconds()
+	}
+	return 0
+}
+
+// computeSenderStats groups the joined events by sender, sorted by sender name.
+func computeSenderStats(events []event) []*senderStats {
+	bySender := make(map[string]*senderStats)
+	for i := range events {
+		e := &events[i]
+
+		name := e.sender
+		if name == "" {
+			name = unknownSender
+		}
+		s, ok := bySender[name]
+		if !ok {
+			s = &senderStats{name: name}
+			bySender[name] = s
+		}
+
+		s.sent++
+		if s.firstSent.IsZero() || e.sent.Before(s.firstSent) {
+			s.firstSent = e.sent
+		}
+		switch {
+		case !e.isAccepted:
+			s.publishFailures++
+		case !e.isReceived:
+			s.deliverFailures++
+		default:
+			s.e2eLatencies = append(s.e2eLatencies, e.e2eLatency())
+			if e.received.After(s.lastReceived) {
+				s.lastReceived = e.received
+			}
+		}
+	}
+
+	stats := make([]*senderStats, 0, len(bySender))
+	for _, s := range bySender {
+		sortDurations(s.e2eLatencies)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(x, y int) bool { return stats[x].name < stats[y].name })
+	return stats
+}
+
+// rankByFailures returns the senders ordered by decreasing number of failures.
+func rankByFailures(stats []*senderStats) []*senderStats {
+	ranked := append([]*senderStats(nil), stats...)
+	sort.SliceStable(ranked, func(x, y int) bool { return ranked[x].failures() > ranked[y].failures() })
+	return ranked
+}
+
+// rankByTailLatency returns the senders ordered by decreasing tail latency.
+func rankByTailLatency(stats []*senderStats) []*senderStats {
+	ranked := append([]*senderStats(nil), stats...)
+	sort.SliceStable(ranked, func(x, y int) bool { return ranked[x].tailLatency() > ranked[y].tailLatency() })
+	return ranked
+}
+
+// worstSender returns the first sender of the ranking, unless it doesn't stand
+// out: its value is zero, or ties with the value of the second sender.
+func worstSender(ranked []*senderStats, value func(*senderStats) float64) (*senderStats, bool) {
+	if len(ranked) == 0 || value(ranked[0]) == 0 {
+		return nil, false
+	}
+	if len(ranked) > 1 && value(ranked[1]) == value(ranked[0]) {
+		return nil, false
+	}
+	return ranked[0], true
+}
+
+// reportWorstSenders logs the ranking of the senders by failures and by tail
+// latency, and returns the Mako tags identifying the worst sender of each
+// ranking, if any stands out.
+func reportWorstSenders(stats []*senderStats) []string {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	byFailures := rankByFailures(stats)
+	log.Printf("Senders ranking by failures:")
+	for i, s := range byFailures {
+		log.Printf("  %d. %s: %d failures (publish: %d, delivery: %d) out of %d sent",
+			i+1, s.name, s.failures(), s.publishFailures, s.deliverFailures, s.sent)
+	}
+
+	byLatency := rankByTailLatency(stats)
+	log.Printf("Senders ranking by p99 end to end latency:")
+	for i, s := range byLatency {
+		log.Printf("  %d. %s: %v (%d delivered)", i+1, s.name, s.tailLatency(), len(s.e2eLatencies))
+	}
+
+	var tags []string
+	if s, ok := worstSender(byFailures, func(s *senderStats) float64 { return float64(s.failures()) }); ok {
+		tags = append(tags, "worst-sender-failures="+mako.EscapeTag(s.name))
+	}
+	if s, ok := worstSender(byLatency, func(s *senderStats) float64 { return float64(s.tailLatency()) }); ok {
+		tags = append(tags, "worst-sender-latency="+mako.EscapeTag(s.name))
+	}
+	return tags
+}
+
+// formatSenderTable returns the lines of the table breaking down the sent and
+// received counts, loss rate and p50 and p99 end to end latencies by sender.
+func formatSenderTable(stats []*senderStats) []string {
+	lines := []string{fmt.Sprintf("%-30s %8s %8s %8s %12s %12s", "sender", "sent", "received", "loss", "p50", "p99")}
+	for _, s := range stats {
+		name := s.name
+		if name == unknownSender && len(stats) == 1 {
+			name = allSenders
+		}
+		received := len(s.e2eLatencies)
+		loss := "-"
+		if s.sent > 0 {
+			loss = fmt.Sprintf("%.2f%%", float64(s.sent-received)*100/float64(s.sent))
+		}
+		p50, p99 := "-", "-"
+		if received > 0 {
+			p50 = percentile(s.e2eLatencies, 50).Round(time.Microsecond).String()
+			p99 = s.tailLatency().Round(time.Microsecond).String()
+		}
+		lines = append(lines, fmt.Sprintf("%-30s %8d %8d %8s %12s %12s", name, s.sent, received, loss, p50, p99))
+	}
+	return lines
+}
+
+// reportSenderTable logs the table breaking down the outcome of the events by sender.
+func reportSenderTable(stats []*senderStats) {
+	log.Printf("Events by sender:")
+	for _, line := range formatSenderTable(stats) {
+		log.Printf("  %s", line)
+	}
+}
+
+// senderGini returns the Gini coefficient of the number of events sent by each
+// identified sender, from 0 when they all sent as many events to (n-1)/n when a
+// single one of the n senders sent them all. It returns false with less than
+// two identified senders.
+func senderGini(stats []*senderStats) (float64, bool) {
+	var counts []int
+	total := 0
+	for _, s := range stats {
+		if s.name == unknownSender {
+			continue
+		}
+		counts = append(counts, s.sent)
+		total += s.sent
+	}
+	if len(counts) < 2 || total == 0 {
+		return 0, false
+	}
+	sort.Ints(counts)
+	// G = 2 Σ i x_i / (n Σ x_i) - (n + 1) / n, over the counts sorted increasingly and ranked from 1
+	var weighted float64
+	for i, c := range counts {
+		weighted += float64(i+1) * float64(c)
+	}
+	n := float64(len(counts))
+	return 2*weighted/(n*float64(total)) - (n+1)/n, true
+}
+
+// reportSenderGini logs the Gini coefficient of the number of events sent by
+// each sender, returning it unless there are less than two senders.
+func reportSenderGini(stats []*senderStats) *float64 {
+	g, ok := senderGini(stats)
+	if !ok {
+		log.Printf("Less than two identified senders, skipping the Gini coefficient of their sent events")
+		return nil
+	}
+	log.Printf("Gini coefficient of the events sent by each sender: %.3f", g)
+	return &g
+}
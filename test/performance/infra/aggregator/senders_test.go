@@ -0,0 +1,242 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestReportWorstSenders(t *testing.T) {
+	start := time.Now()
+
+	var events []event
+	for _, sender := range []string{"sender-a", "sender-b", "sender-c"} {
+		for i := 0; i < 100; i++ {
+			e := event{
+				id:         fmt.Sprintf("%s-%d", sender, i),
+				sender:     sender,
+				sent:       start,
+				accepted:   start.Add(time.Millisecond),
+				received:   start.Add(10 * time.Millisecond),
+				isAccepted: true,
+				isReceived: true,
+			}
+			if sender == "sender-b" {
+				// sender-b is clearly the worst: slow and lossy
+				e.received = start.Add(time.Second)
+				switch {
+				case i%5 == 0:
+					e.isAccepted = false
+					e.isReceived = false
+				case i%5 == 1:
+					e.isReceived = false
+				}
+			}
+			events = append(events, e)
+		}
+	}
+
+	stats := computeSenderStats(events)
+	if len(stats) != 3 {
+		t.Fatalf("len(computeSenderStats()) = %d, want 3", len(stats))
+	}
+
+	worst := rankByFailures(stats)[0]
+	if worst.name != "sender-b" {
+		t.Errorf("worst sender by failures = %s, want sender-b", worst.name)
+	}
+	if worst.publishFailures != 20 || worst.deliverFailures != 20 {
+		t.Errorf("sender-b failures = (publish: %d, delivery: %d), want (publish: 20, delivery: 20)", worst.publishFailures, worst.deliverFailures)
+	}
+
+	if worst := rankByTailLatency(stats)[0]; worst.name != "sender-b" {
+		t.Errorf("worst sender by tail latency = %s, want sender-b", worst.name)
+	}
+
+	tags := reportWorstSenders(stats)
+	wantTags := []string{"worst-sender-failures=sender-b", "worst-sender-latency=sender-b"}
+	if fmt.Sprint(tags) != fmt.Sprint(wantTags) {
+		t.Errorf("reportWorstSenders() = %v, want %v", tags, wantTags)
+	}
+}
+
+func TestReportWorstSendersAllClean(t *testing.T) {
+	start := time.Now()
+
+	var events []event
+	for _, sender := range []string{"sender-a", "sender-b", "sender-c"} {
+		for i := 0; i < 10; i++ {
+			events = append(events, event{
+				id:         fmt.Sprintf("%s-%d", sender, i),
+				sender:     sender,
+				sent:       start,
+				accepted:   start.Add(time.Millisecond),
+				received:   start.Add(10 * time.Millisecond),
+				isAccepted: true,
+				isReceived: true,
+			})
+		}
+	}
+
+	if tags := reportWorstSenders(computeSenderStats(events)); len(tags) != 0 {
+		t.Errorf("reportWorstSenders() = %v, want no tag when no sender stands out", tags)
+	}
+}
+
+func TestReportWorstSendersNothingDelivered(t *testing.T) {
+	events := []event{
+		{id: "1", sender: "sender-a"},
+		{id: "2", sender: "sender-b", isAccepted: true},
+		{id: "3", sender: "sender-b", isAccepted: true},
+	}
+
+	tags := reportWorstSenders(computeSenderStats(events))
+	wantTags := []string{"worst-sender-failures=sender-b"}
+	if fmt.Sprint(tags) != fmt.Sprint(wantTags) {
+		t.Errorf("reportWorstSenders() = %v, want %v", tags, wantTags)
+	}
+}
+
+func TestComputeSenderStatsUnknownSender(t *testing.T) {
+	stats := computeSenderStats([]event{{id: "1"}})
+	if len(stats) != 1 || stats[0].name != unknownSender {
+		t.Errorf("computeSenderStats() = %v, want a single %q sender", stats, unknownSender)
+	}
+}
+
+func TestSenderTable(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	delivered := func(id, sender string, latency time.Duration) event {
+		return event{id: id, sender: sender, sent: sent, received: sent.Add(latency), isAccepted: true, isReceived: true}
+	}
+	events := []event{
+		delivered("1", "sender-a", 10*time.Millisecond),
+		delivered("2", "sender-a", 20*time.Millisecond),
+		{id: "3", sender: "sender-a", isAccepted: true},
+		{id: "4", sender: "sender-a"},
+		delivered("5", "sender-b", 5*time.Millisecond),
+	}
+
+	want := [][]string{
+		{"sender", "sent", "received", "loss", "p50", "p99"},
+		{"sender-a", "4", "2", "50.00%", "10ms", "20ms"},
+		{"sender-b", "1", "1", "0.00%", "5ms", "5ms"},
+	}
+	lines := formatSenderTable(computeSenderStats(events))
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+	}
+	for i := range want {
+		if got := strings.Fields(lines[i]); fmt.Sprint(got) != fmt.Sprint(want[i]) {
+			t.Errorf("line %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestSenderTableWithoutSenderIdentity(t *testing.T) {
+	sent := time.Unix(1000, 0)
+	events := []event{
+		{id: "1", sent: sent, received: sent.Add(time.Millisecond), isAccepted: true, isReceived: true},
+		{id: "2", isAccepted: true},
+	}
+	lines := formatSenderTable(computeSenderStats(events))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want the header and a single row", len(lines))
+	}
+	if got := strings.Fields(lines[1]); fmt.Sprint(got) != fmt.Sprint([]string{allSenders, "2", "1", "50.00%", "1ms", "1ms"}) {
+		t.Errorf("row = %v, want the %q row", got, allSenders)
+	}
+}
+
+func TestDistinctSenders(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	now := time.Now()
+	for _, rl := range []*pb.EventsRecordList{
+		{Source: "sender-a", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "a1")}},
+		{Source: "sender-a", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now, "a2")}},
+		// a sender which sent nothing still connected
+		{Source: "sender-b", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, now)}},
+		{Source: "receiver-a", Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_RECEIVED, now, "a1", "a2")}},
+	} {
+		recordEvents(t, ag, rl)
+	}
+
+	if got := len(ag.sentEvents.producers); got != 2 {
+		t.Errorf("distinct senders = %d, want 2", got)
+	}
+
+	q := newFakeAggregatesStore()
+	a := runAggregates{distinctSenders: len(ag.sentEvents.producers)}
+	a.publish(q)
+	if got := q.run["ds"]; got != 2 {
+		t.Errorf("published distinct senders = %v, want 2", got)
+	}
+}
+
+func TestSenderGini(t *testing.T) {
+	sent := func(counts map[string]int) []*senderStats {
+		var events []event
+		for sender, n := range counts {
+			for i := 0; i < n; i++ {
+				events = append(events, event{id: fmt.Sprint(sender, i), sender: sender})
+			}
+		}
+		return computeSenderStats(events)
+	}
+	for _, tc := range []struct {
+		name   string
+		counts map[string]int
+		want   float64
+	}{
+		{name: "balanced", counts: map[string]int{"a": 10, "b": 10, "c": 10, "d": 10}, want: 0},
+		{name: "single sender does everything", counts: map[string]int{"a": 0, "b": 0, "c": 0, "d": 40}, want: 0.75},
+		{name: "imbalanced", counts: map[string]int{"a": 1, "b": 3}, want: 0.25},
+		{name: "unknown sender ignored", counts: map[string]int{"a": 5, "b": 5, unknownSender: 100}, want: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats := sent(tc.counts)
+			// the senders without events are missing from the stats
+			for sender, n := range tc.counts {
+				if n == 0 {
+					stats = append(stats, &senderStats{name: sender})
+				}
+			}
+			g, ok := senderGini(stats)
+			if !ok || math.Abs(g-tc.want) > 1e-9 {
+				t.Errorf("senderGini() = %v, %v, want %v, true", g, ok, tc.want)
+			}
+		})
+	}
+}
+
+func TestSenderGiniSkippedWithOneSender(t *testing.T) {
+	for _, events := range [][]event{
+		nil,
+		{{id: "1", sender: "a"}, {id: "2", sender: "a"}},
+		{{id: "1"}, {id: "2", sender: "a"}},
+	} {
+		if g := reportSenderGini(computeSenderStats(events)); g != nil {
+			t.Errorf("Gini coefficient = %v over %d events, want it skipped", *g, len(events))
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ShardSummary is the breakdown of the results of a run for the events of a
+// single sender shard, identified by the source of its events records, e.g.
+// its pod name.
+type ShardSummary struct {
+	Shard    string
+	Sent     int
+	Received int
+	// received events per second, from the first sent to the last received
+	// event of the shard
+	Throughput float64
+	// median and 99th percentile of the end to end latencies of the shard, 0
+	// without received events
+	E2ELatencyP50 time.Duration
+	E2ELatencyP99 time.Duration
+}
+
+// shardImbalance is the spread across the shards of their throughputs and of
+// their p99 end to end latencies, relative to the highest one: 0 when all the
+// shards are alike, approaching 1 when one lags far behind the others.
+type shardImbalance struct {
+	throughput float64
+	latency    float64
+}
+
+// shardSummaries returns the breakdown of the results by shard, in the order
+// of the sender stats.
+func shardSummaries(stats []*senderStats) []ShardSummary {
+	shards := make([]ShardSummary, 0, len(stats))
+	for _, s := range stats {
+		shard := ShardSummary{
+			Shard:      s.name,
+			Sent:       s.sent,
+			Received:   len(s.e2eLatencies),
+			Throughput: s.throughput(),
+		}
+		if shard.Received > 0 {
+			shard.E2ELatencyP50 = percentile(s.e2eLatencies, 50)
+			shard.E2ELatencyP99 = s.tailLatency()
+		}
+		shards = append(shards, shard)
+	}
+	return shards
+}
+
+// relativeSpread returns (max - min) / max of the given values, 0 if they are
+// all 0.
+func relativeSpread(values []float64) float64 {
+	min, max := values[0], values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+	return (max - min) / max
+}
+
+// imbalanceOf returns the imbalance of the identified shards, false with less
+// than two of them. The latency imbalance only covers the shards with
+// received events.
+func imbalanceOf(shards []ShardSummary) (shardImbalance, bool) {
+	var throughputs, latencies []float64
+	for _, s := range shards {
+		if s.Shard == unknownSender {
+			continue
+		}
+		throughputs = append(throughputs, s.Throughput)
+		if s.Received > 0 {
+			latencies = append(latencies, float64(s.E2ELatencyP99))
+		}
+	}
+	if len(throughputs) < 2 {
+		return shardImbalance{}, false
+	}
+	imbalance := shardImbalance{throughput: relativeSpread(throughputs)}
+	if len(latencies) >= 2 {
+		imbalance.latency = relativeSpread(latencies)
+	}
+	return imbalance, true
+}
+
+// formatShardTable returns the lines of the table breaking down the sent and
+// received counts, throughput and p50 and p99 end to end latencies by shard.
+func formatShardTable(shards []ShardSummary) []string {
+	lines := []string{fmt.Sprintf("%-30s %8s %8s %12s %12s %12s", "shard", "sent", "received", "throughput", "p50", "p99")}
+	for _, s := range shards {
+		p50, p99 := "-", "-"
+		if s.Received > 0 {
+			p50 = s.E2ELatencyP50.Round(time.Microsecond).String()
+			p99 = s.E2ELatencyP99.Round(time.Microsecond).String()
+		}
+		lines = append(lines, fmt.Sprintf("%-30s %8d %8d %12.2f %12s %12s", s.Shard, s.Sent, s.Received, s.Throughput, p50, p99))
+	}
+	return lines
+}
+
+// reportShards logs the breakdown of the results by shard and their
+// imbalance, returning the breakdown and the imbalance, nil with less than
+// two identified shards.
+func reportShards(stats []*senderStats) ([]ShardSummary, *shardImbalance) {
+	shards := shardSummaries(stats)
+	log.Printf("Results by shard:")
+	for _, line := range formatShardTable(shards) {
+		log.Printf("  %s", line)
+	}
+	imbalance, ok := imbalanceOf(shards)
+	if !ok {
+		log.Printf("Less than two identified shards, skipping their imbalance")
+		return shards, nil
+	}
+	log.Printf("Imbalance across the shards: %.3f of throughput, %.3f of p99 end to end latency",
+		imbalance.throughput, imbalance.latency)
+	return shards, &imbalance
+}
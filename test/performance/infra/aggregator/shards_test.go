@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shardEvents returns n events of the shard sent every 10ms from start, each
+// delivered after the given latency.
+func shardEvents(shard string, start time.Time, n int, latency time.Duration) []event {
+	events := make([]event, n)
+	for i := range events {
+		sent := start.Add(time.Duration(i) * 10 * time.Millisecond)
+		events[i] = event{
+			id:         fmt.Sprintf("%s-%d", shard, i),
+			sender:     shard,
+			sent:       sent,
+			accepted:   sent.Add(time.Millisecond),
+			received:   sent.Add(latency),
+			isAccepted: true,
+			isReceived: true,
+		}
+	}
+	return events
+}
+
+func TestShardBreakdown(t *testing.T) {
+	start := time.Unix(1000, 0)
+	// shard-a delivers 100 events in 1s, shard-b 50 events in 1s but slower
+	events := append(shardEvents("shard-a", start, 100, 10*time.Millisecond),
+		shardEvents("shard-b", start, 50, 510*time.Millisecond)...)
+
+	shards, imbalance := reportShards(computeSenderStats(events))
+	want := []ShardSummary{
+		{Shard: "shard-a", Sent: 100, Received: 100, Throughput: 100, E2ELatencyP50: 10 * time.Millisecond, E2ELatencyP99: 10 * time.Millisecond},
+		{Shard: "shard-b", Sent: 50, Received: 50, Throughput: 50, E2ELatencyP50: 510 * time.Millisecond, E2ELatencyP99: 510 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(shards, want) {
+		t.Errorf("reportShards() = %+v, want %+v", shards, want)
+	}
+	if imbalance == nil {
+		t.Fatal("reportShards() reported no imbalance across two shards")
+	}
+	if math.Abs(imbalance.throughput-0.5) > 1e-9 {
+		t.Errorf("throughput imbalance = %v, want 0.5", imbalance.throughput)
+	}
+	if want := 500.0 / 510; math.Abs(imbalance.latency-want) > 1e-9 {
+		t.Errorf("latency imbalance = %v, want %v", imbalance.latency, want)
+	}
+
+	lines := formatShardTable(shards)
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "shard-a") || !strings.Contains(lines[2], "50.00") {
+		t.Errorf("formatShardTable() = %q, want a row per shard with its throughput", lines)
+	}
+}
+
+func TestShardImbalanceSkipped(t *testing.T) {
+	start := time.Unix(1000, 0)
+	if _, imbalance := reportShards(computeSenderStats(shardEvents("shard-a", start, 10, time.Millisecond))); imbalance != nil {
+		t.Errorf("reportShards() of a single shard = %+v, want no imbalance", *imbalance)
+	}
+	// the events of unidentified senders are not a shard
+	events := append(shardEvents("shard-a", start, 10, time.Millisecond), shardEvents("", start, 10, time.Millisecond)...)
+	if _, imbalance := reportShards(computeSenderStats(events)); imbalance != nil {
+		t.Errorf("reportShards() with an unknown sender = %+v, want no imbalance", *imbalance)
+	}
+
+	// a shard without received events has no throughput nor latency
+	lost := shardEvents("shard-b", start, 10, time.Millisecond)
+	for i := range lost {
+		lost[i].isReceived = false
+	}
+	events = append(shardEvents("shard-a", start, 10, time.Millisecond), lost...)
+	_, imbalance := reportShards(computeSenderStats(events))
+	if imbalance == nil || imbalance.throughput != 1 || imbalance.latency != 0 {
+		t.Errorf("reportShards() with a shard without received events = %+v, want a throughput imbalance of 1 only", imbalance)
+	}
+}
+
+func TestShardsRoundTripInSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+	summary := &RunSummary{RunID: "run", Shards: []ShardSummary{
+		{Shard: "shard-a", Sent: 10, Received: 9, Throughput: 4.5, E2ELatencyP50: 3 * time.Millisecond, E2ELatencyP99: 8 * time.Millisecond},
+	}}
+	if err := exportRunSummary(path, summary); err != nil {
+		t.Fatal("exportRunSummary() =", err)
+	}
+	got, err := loadRunSummary(path)
+	if err != nil {
+		t.Fatal("loadRunSummary() =", err)
+	}
+	if !reflect.DeepEqual(got.Shards, summary.Shards) {
+		t.Errorf("loaded shards = %+v, want %+v", got.Shards, summary.Shards)
+	}
+}
+
+func TestShardBreakdownIsRejectedInStreamingAggregation(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithStreamingAggregation(true), WithShardBreakdown(true)); err == nil {
+		t.Error("NewAggregator() with the shard breakdown in streaming aggregation succeeded")
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultShortSummaryFormat is the format of the short summary, unless
+// configured with WithShortSummary.
+const DefaultShortSummaryFormat = "p99:ms,loss:%,thrpt:k/s,status"
+
+// shortSummaryField is a field of the short summary and its unit.
+type shortSummaryField struct {
+	name string
+	unit string
+	// end to end latency percentile of the pNN fields
+	percentile float64
+}
+
+// shortSummaryUnits are the units of each kind of field, the first one being
+// the default.
+var shortSummaryUnits = map[string][]string{
+	"pNN":      {"ms", "s", "us"},
+	"loss":     {"%", "ratio"},
+	"thrpt":    {"/s", "k/s", "M/s"},
+	"sent":     {""},
+	"received": {""},
+	"status":   {""},
+}
+
+// parseShortSummaryFormat parses a comma-separated list of fields, each
+// optionally followed by a colon and its unit: pNN (e.g. p99 or p99.9) for an
+// end to end latency percentile, in ms, s or us; loss for the ratio of the sent
+// events which weren't received, in % or as a ratio; thrpt for the received
+// events per second, in /s, k/s or M/s; sent and received for the counts; and
+// status for OK or FAIL.
+func parseShortSummaryFormat(format string) ([]shortSummaryField, error) {
+	var fields []shortSummaryField
+	for _, spec := range strings.Split(format, ",") {
+		spec = strings.TrimSpace(spec)
+		name, unit := spec, ""
+		if i := strings.Index(spec, ":"); i >= 0 {
+			name, unit = spec[:i], spec[i+1:]
+		}
+		f := shortSummaryField{name: name}
+		kind := name
+		if strings.HasPrefix(name, "p") {
+			p, err := strconv.ParseFloat(name[1:], 64)
+			if err != nil || p <= 0 || p >= 100 {
+				return nil, fmt.Errorf("invalid short summary field %q", spec)
+			}
+			f.percentile, kind = p, "pNN"
+		}
+		units, ok := shortSummaryUnits[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown short summary field %q", spec)
+		}
+		if f.unit = units[0]; unit != "" {
+			f.unit = unit
+			if !containsString(units, unit) {
+				return nil, fmt.Errorf("invalid unit of the short summary field %q, must be one of %v", spec, units)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// compactFloat formats the value with at most 3 significant digits below 100,
+// and without decimals above.
+func compactFloat(v float64) string {
+	if v >= 100 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// formatShortSummary writes the given fields of the summary on one line, e.g.
+// "p99=42ms loss=0.3% thrpt=5k/s status=OK". The values which can't be
+// computed, e.g. the percentiles of a low sample run, are "n/a".
+func formatShortSummary(summary *RunSummary, fields []shortSummaryField) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		var value string
+		switch f.name {
+		case "loss":
+			if summary.Sent == 0 {
+				value = "n/a"
+				break
+			}
+			loss := float64(summary.Sent-summary.Received) / float64(summary.Sent)
+			if f.unit == "%" {
+				value = compactFloat(loss*100) + "%"
+			} else {
+				value = compactFloat(loss)
+			}
+		case "thrpt":
+			scale := map[string]float64{"/s": 1, "k/s": 1e3, "M/s": 1e6}[f.unit]
+			value = compactFloat(summary.Throughput/scale) + f.unit
+		case "sent":
+			value = strconv.Itoa(summary.Sent)
+		case "received":
+			value = strconv.Itoa(summary.Received)
+		case "status":
+			value = "FAIL"
+			if summary.Passed {
+				value = "OK"
+			}
+		default:
+			latency, ok := summary.E2ELatencies[f.percentile]
+			if !ok {
+				value = "n/a"
+				break
+			}
+			scale := map[string]time.Duration{"ms": time.Millisecond, "s": time.Second, "us": time.Microsecond}[f.unit]
+			value = compactFloat(float64(latency)/float64(scale)) + f.unit
+		}
+		parts = append(parts, f.name+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeShortSummary writes the one-line summary to the file at the given path.
+func writeShortSummary(path string, summary string) error {
+	if err := ioutil.WriteFile(path, []byte(summary+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// ShortSummary returns the summary of the run on one line in the default
+// format, e.g. "p99=42ms loss=0.3% thrpt=5k/s status=OK", for tight spaces
+// like commit statuses and chat notifications.
+func (s *RunSummary) ShortSummary() string {
+	fields, _ := parseShortSummaryFormat(DefaultShortSummaryFormat)
+	return formatShortSummary(s, fields)
+}
+
+// ShortSummary returns the summary of the last run on one line, in the format
+// configured with WithShortSummary, or an empty string until Run returns.
+func (ag *Aggregator) ShortSummary() string {
+	return ag.shortSummary
+}
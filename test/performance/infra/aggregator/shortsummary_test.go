@@ -0,0 +1,83 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestShortSummary(t *testing.T) {
+	summary := &RunSummary{
+		Passed:       true,
+		Sent:         1000,
+		Received:     997,
+		Throughput:   5000,
+		E2ELatencies: map[float64]time.Duration{50: 1500 * time.Microsecond, 99: 42 * time.Millisecond},
+	}
+	if got, want := summary.ShortSummary(), "p99=42ms loss=0.3% thrpt=5k/s status=OK"; got != want {
+		t.Errorf("ShortSummary() = %q, want %q", got, want)
+	}
+
+	fields, err := parseShortSummaryFormat("p50:us, p99.9, loss:ratio, thrpt, sent, received, status")
+	if err != nil {
+		t.Fatal("Failed to parse the short summary format:", err)
+	}
+	if got, want := formatShortSummary(summary, fields), "p50=1500us p99.9=n/a loss=0.003 thrpt=5000/s sent=1000 received=997 status=OK"; got != want {
+		t.Errorf("short summary = %q, want %q", got, want)
+	}
+
+	// a failed low sample run
+	summary = &RunSummary{Sent: 10, Received: 9, Throughput: 1.5, LowSample: true}
+	if got, want := summary.ShortSummary(), "p99=n/a loss=10% thrpt=0.0015k/s status=FAIL"; got != want {
+		t.Errorf("ShortSummary() of a low sample run = %q, want %q", got, want)
+	}
+}
+
+func TestShortSummaryOfRun(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 1, WithRunID("short"), WithShortSummary("sent,status", filepath.Join(dir, "summary.txt")))
+	done := runAggregator(context.Background(), ag)
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1")}})
+	waitForRun(t, done)
+
+	// never accepted, the event failed to be published
+	const want = "sent=1 status=FAIL"
+	if got := ag.ShortSummary(); got != want {
+		t.Errorf("ShortSummary() = %q, want %q", got, want)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "summary-short.txt"))
+	if err != nil {
+		t.Fatal("Failed to read the short summary:", err)
+	}
+	if got := string(content); got != want+"\n" {
+		t.Errorf("written short summary = %q, want %q", got, want+"\n")
+	}
+}
+
+func TestInvalidShortSummaryFormatIsRejected(t *testing.T) {
+	for _, format := range []string{"p99:min", "p100", "latency", "status,"} {
+		if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithShortSummary(format, "")); err == nil {
+			t.Errorf("NewAggregator() with the short summary format %q succeeded", format)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ResultSink receives the summary of each run, in addition to the exporter.
+type ResultSink interface {
+	// Name identifies the sink in the logs.
+	Name() string
+	// Publish sends the summary of the run to the sink.
+	Publish(ctx context.Context, summary *RunSummary) error
+}
+
+// RunSummary is the summary of a run published to the result sinks.
+type RunSummary struct {
+	RunID string
+	// Scenario labels the results of the run, if any.
+	Scenario string
+	// Passed is whether no event failed to be published or delivered, as
+	// checked by the threshold analyzers of Mako, and all the assertions hold.
+	Passed bool
+
+	Sent             int
+	Accepted         int
+	Received         int
+	PublishFailures  int
+	DeliveryFailures int
+
+	// ratios of the sent events which were not accepted, and of the accepted
+	// events which were not received
+	PublishFailureRate  float64
+	DeliveryFailureRate float64
+
+	// received events per second, from the first sent to the last received event
+	Throughput float64
+
+	// whether too few events were delivered for the percentiles to be
+	// meaningful, in which case they are not set
+	LowSample bool
+	// whether the results were computed without all the expected events
+	// records, on timeout or on a finish request, and how many were missing
+	Partial        bool
+	MissingRecords int
+	// percentiles of the publish and end to end latencies, keyed by percentile
+	PublishLatencies map[float64]time.Duration
+	E2ELatencies     map[float64]time.Duration
+	// results of each sender shard, if broken down
+	Shards []ShardSummary
+	// end to end latencies by payload size, if the senders vary them
+	PayloadSizes []PayloadSizeSummary
+}
+
+// setMissingRecords marks the summary partial if expected events records were
+// missing from the results.
+func (s *RunSummary) setMissingRecords(missing *int) {
+	if missing != nil && *missing > 0 {
+		s.Partial = true
+		s.MissingRecords = *missing
+	}
+}
+
+// newRunSummary summarizes the run from its events and aggregates.
+func newRunSummary(runID string, scenario string, events []event, aggregates *runAggregates) *RunSummary {
+	s := &RunSummary{
+		RunID:            runID,
+		Scenario:         scenario,
+		Sent:             len(events),
+		PublishFailures:  aggregates.publishErrors,
+		DeliveryFailures: aggregates.deliverErrors,
+		LowSample:        aggregates.lowSample(),
+		Passed:           aggregates.publishErrors == 0 && aggregates.deliverErrors == 0,
+	}
+	s.setMissingRecords(aggregates.missingRecords)
+	s.Shards = aggregates.shards
+	s.PayloadSizes = aggregates.payloadSizes
+	var first, last time.Time
+	for i := range events {
+		if events[i].isAccepted {
+			s.Accepted++
+		}
+		if events[i].isReceived {
+			s.Received++
+			if last.IsZero() || events[i].received.After(last) {
+				last = events[i].received
+			}
+		}
+		if first.IsZero() || events[i].sent.Before(first) {
+			first = events[i].sent
+		}
+	}
+	if span := last.Sub(first); span > 0 {
+		s.Throughput = float64(s.Received) / span.Seconds()
+	}
+	if s.Sent > 0 {
+		s.PublishFailureRate = float64(s.PublishFailures) / float64(s.Sent)
+	}
+	if s.Accepted > 0 {
+		s.DeliveryFailureRate = float64(s.DeliveryFailures) / float64(s.Accepted)
+	}
+	if !s.LowSample {
+		s.PublishLatencies = make(map[float64]time.Duration, len(reportedPercentiles))
+		s.E2ELatencies = make(map[float64]time.Duration, len(reportedPercentiles))
+		for _, p := range reportedPercentiles {
+			if len(aggregates.publishLatencies) > 0 {
+				s.PublishLatencies[p] = percentile(aggregates.publishLatencies, p)
+			}
+			s.E2ELatencies[p] = percentile(aggregates.e2eLatencies, p)
+		}
+		for p, v := range aggregates.highPercentiles {
+			s.E2ELatencies[p] = v
+		}
+	}
+	return s
+}
+
+// publishToSinks sends the summary of the run to each result sink, logging
+// the failures.
+func publishToSinks(ctx context.Context, sinks []ResultSink, summary *RunSummary) {
+	for _, sink := range sinks {
+		log.Printf("Publishing the results to %s", sink.Name())
+		if err := sink.Publish(ctx, summary); err != nil {
+			log.Printf("ERROR publishing the results to %s: %v", sink.Name(), err)
+		}
+	}
+}
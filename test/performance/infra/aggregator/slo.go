@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"sort"
+	"time"
+)
+
+// SLOConfig are the latency thresholds of the SLOs the latencies are evaluated
+// against. The thresholds which are zero are not evaluated.
+type SLOConfig struct {
+	// ApdexTarget is the latency within which an event satisfies its user: the
+	// events are tolerated up to 4 times it, and frustrate beyond.
+	ApdexTarget time.Duration
+	// Deadline is the latency beyond which an event misses its deadline.
+	Deadline time.Duration
+	// Thresholds are the latencies to evaluate the fraction of the events
+	// within, e.g. 20ms and 50ms.
+	Thresholds []time.Duration
+}
+
+// SLOResult are the SLO metrics of the latencies. A latency equal to a
+// threshold is within it.
+type SLOResult struct {
+	// Count is the number of latencies the metrics are computed over.
+	Count int
+
+	// Apdex is the ratio of the satisfied events plus half the tolerated ones
+	// to all of them, from 0 to 1, and the counts of each, if ApdexTarget is set.
+	Apdex                            float64
+	Satisfied, Tolerated, Frustrated int
+
+	// Good is the number of events within the deadline, i.e. the goodput, and
+	// DeadlineMisses the number of events beyond it, if Deadline is set.
+	Good           int
+	DeadlineMisses int
+	// OnTimeRate is the fraction of the events within the deadline.
+	OnTimeRate float64
+
+	// Within is the fraction of the events within each of the Thresholds.
+	Within map[time.Duration]float64
+}
+
+// EvaluateSLOs computes the SLO metrics of the given latencies under the given
+// thresholds, e.g. the end to end latencies read with LoadLatencySamples, so
+// that the SLOs can be evaluated under different thresholds without joining
+// the events again. The latencies are copied and sorted if they aren't sorted
+// already, so that each threshold then costs a binary search: sorting them
+// beforehand makes sweeping thresholds cheap. The metrics are fractions of the
+// given latencies, which don't include the events that weren't delivered.
+func EvaluateSLOs(latencies []time.Duration, cfg SLOConfig) SLOResult {
+	sorted := latencies
+	if !sort.SliceIsSorted(sorted, func(x, y int) bool { return sorted[x] < sorted[y] }) {
+		sorted = make([]time.Duration, len(latencies))
+		copy(sorted, latencies)
+		sortDurations(sorted)
+	}
+	// within returns the number of latencies lower than or equal to the threshold
+	within := func(threshold time.Duration) int {
+		return sort.Search(len(sorted), func(i int) bool { return sorted[i] > threshold })
+	}
+	fraction := func(n int) float64 {
+		if len(sorted) == 0 {
+			return 0
+		}
+		return float64(n) / float64(len(sorted))
+	}
+
+	r := SLOResult{Count: len(sorted)}
+	if cfg.ApdexTarget > 0 {
+		r.Satisfied = within(cfg.ApdexTarget)
+		r.Tolerated = within(4*cfg.ApdexTarget) - r.Satisfied
+		r.Frustrated = len(sorted) - r.Satisfied - r.Tolerated
+		r.Apdex = fraction(2*r.Satisfied+r.Tolerated) / 2
+	}
+	if cfg.Deadline > 0 {
+		r.Good = within(cfg.Deadline)
+		r.DeadlineMisses = len(sorted) - r.Good
+		r.OnTimeRate = fraction(r.Good)
+	}
+	if len(cfg.Thresholds) > 0 {
+		r.Within = make(map[time.Duration]float64, len(cfg.Thresholds))
+		for _, t := range cfg.Thresholds {
+			if t > 0 {
+				r.Within[t] = fraction(within(t))
+			}
+		}
+	}
+	return r
+}
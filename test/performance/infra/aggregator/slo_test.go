@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluateSLOs(t *testing.T) {
+	ms := time.Millisecond
+	// unsorted, which must not be modified
+	latencies := []time.Duration{80 * ms, 5 * ms, 50 * ms, 10 * ms, 20 * ms, 30 * ms, 41 * ms, 10 * ms}
+	original := append([]time.Duration(nil), latencies...)
+
+	got := EvaluateSLOs(latencies, SLOConfig{
+		ApdexTarget: 10 * ms,
+		Deadline:    30 * ms,
+		Thresholds:  []time.Duration{20 * ms, 50 * ms, 0},
+	})
+	want := SLOResult{
+		Count: 8,
+		// 3 satisfied within 10ms, 2 tolerated within 40ms
+		Apdex:      (3 + 2.0/2) / 8,
+		Satisfied:  3,
+		Tolerated:  2,
+		Frustrated: 3,
+		Good:       5,
+		// 41, 50 and 80ms
+		DeadlineMisses: 3,
+		OnTimeRate:     5.0 / 8,
+		Within:         map[time.Duration]float64{20 * ms: 4.0 / 8, 50 * ms: 7.0 / 8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EvaluateSLOs() = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(latencies, original) {
+		t.Errorf("EvaluateSLOs() modified the latencies: %v", latencies)
+	}
+
+	// sweeping the deadline over the sorted latencies
+	sortDurations(latencies)
+	for _, c := range []struct {
+		deadline time.Duration
+		misses   int
+	}{{5 * ms, 7}, {40 * ms, 3}, {100 * ms, 0}} {
+		if r := EvaluateSLOs(latencies, SLOConfig{Deadline: c.deadline}); r.DeadlineMisses != c.misses || r.Apdex != 0 || r.Within != nil {
+			t.Errorf("EvaluateSLOs() with a deadline of %v = %+v, want %d misses only", c.deadline, r, c.misses)
+		}
+	}
+}
+
+func TestEvaluateSLOsOfLoadedSamples(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeLatencySamples(&buf, nil, latencies(100)); err != nil {
+		t.Fatal("Failed to write the latency samples:", err)
+	}
+	_, e2e, err := ReadLatencySamples(&buf)
+	if err != nil {
+		t.Fatal("Failed to read the latency samples:", err)
+	}
+	if r := EvaluateSLOs(e2e, SLOConfig{ApdexTarget: 20 * time.Millisecond}); r.Apdex != (20+60.0/2)/100 {
+		t.Errorf("Apdex of 1 to 100ms with a target of 20ms = %v, want 0.5", r.Apdex)
+	}
+}
+
+func TestEvaluateSLOsWithoutLatencies(t *testing.T) {
+	r := EvaluateSLOs(nil, SLOConfig{ApdexTarget: time.Millisecond, Deadline: time.Millisecond, Thresholds: []time.Duration{time.Millisecond}})
+	if r.Count != 0 || r.Apdex != 0 || r.OnTimeRate != 0 || r.Within[time.Millisecond] != 0 {
+		t.Errorf("EvaluateSLOs() without latencies = %+v, want zero metrics", r)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// failedRunKey is the key of the aggregate telling whether the run violated
+// its SLO thresholds, 1 if it did and 0 otherwise.
+const failedRunKey = "fr"
+
+// SLOThresholds are the limits the results of the run must stay within for it
+// to pass, checked once the results are aggregated. The aggregator fails once
+// the results are published if any is violated, so that CI pipelines can gate
+// on them from the exit code. The zero ones are not checked.
+type SLOThresholds struct {
+	// maximum median and 99th percentile of the end to end latencies
+	MaxP50Latency time.Duration
+	MaxP99Latency time.Duration
+	// maximum ratio of the sent events which failed to be published or
+	// delivered, nil not to check it
+	MaxErrorRate *float64
+}
+
+// isSet tells whether any threshold is checked.
+func (t SLOThresholds) isSet() bool {
+	return t.MaxP50Latency > 0 || t.MaxP99Latency > 0 || t.MaxErrorRate != nil
+}
+
+func (t SLOThresholds) validate() error {
+	if t.MaxP50Latency < 0 || t.MaxP99Latency < 0 {
+		return fmt.Errorf("invalid maximum p50 and p99 latencies %v and %v, must not be negative",
+			t.MaxP50Latency, t.MaxP99Latency)
+	}
+	if t.MaxErrorRate != nil && (*t.MaxErrorRate < 0 || *t.MaxErrorRate > 1) {
+		return fmt.Errorf("invalid maximum error rate %v, must be between 0 and 1", *t.MaxErrorRate)
+	}
+	return nil
+}
+
+// violations returns the descriptions of the thresholds the run violates. A
+// latency threshold is violated when too few events were delivered to compute
+// the percentile, and the error rate one when no event was sent, as the run
+// can't be told to meet them.
+func (t SLOThresholds) violations(summary *RunSummary) []string {
+	var violations []string
+	checkLatency := func(p float64, max time.Duration) {
+		if max <= 0 {
+			return
+		}
+		latency, ok := summary.E2ELatencies[p]
+		switch {
+		case !ok:
+			violations = append(violations, fmt.Sprintf("p%v end to end latency unknown, too few events delivered to check it is at most %v", p, max))
+		case latency > max:
+			violations = append(violations, fmt.Sprintf("p%v end to end latency %v exceeds %v", p, latency, max))
+		}
+	}
+	checkLatency(50, t.MaxP50Latency)
+	checkLatency(99, t.MaxP99Latency)
+
+	if t.MaxErrorRate != nil {
+		if summary.Sent == 0 {
+			violations = append(violations, fmt.Sprintf("error rate unknown, no event sent to check it is at most %v", *t.MaxErrorRate))
+		} else if rate := errorRate(summary); rate > *t.MaxErrorRate {
+			violations = append(violations, fmt.Sprintf("error rate %v (%d publish and %d delivery failures of %d sent events) exceeds %v",
+				rate, summary.PublishFailures, summary.DeliveryFailures, summary.Sent, *t.MaxErrorRate))
+		}
+	}
+	return violations
+}
+
+// errorRate returns the ratio of the sent events which failed to be published
+// or delivered.
+func errorRate(summary *RunSummary) float64 {
+	return float64(summary.PublishFailures+summary.DeliveryFailures) / float64(summary.Sent)
+}
+
+// reportSLOViolations logs the violated SLO thresholds, if any.
+func reportSLOViolations(violations []string) {
+	if len(violations) == 0 {
+		log.Printf("The run meets its SLO thresholds")
+		return
+	}
+	for _, v := range violations {
+		log.Printf("!! SLO violated: %s", v)
+	}
+}
+
+// runFailures are the reasons the run failed once its results are aggregated.
+type runFailures struct {
+	// descriptions of the violated SLO thresholds and of the failed assertions
+	sloViolations     []string
+	assertionFailures []string
+}
+
+// message describes the failures of the run, empty if it didn't fail, given
+// the number of assertions.
+func (f runFailures) message(assertions int) string {
+	var msg string
+	if len(f.sloViolations) > 0 {
+		msg = fmt.Sprintf("%d SLO thresholds violated:\n  %s", len(f.sloViolations), strings.Join(f.sloViolations, "\n  "))
+	}
+	if len(f.assertionFailures) > 0 {
+		if msg != "" {
+			msg += "\n"
+		}
+		msg += fmt.Sprintf("%d of %d assertions failed:\n  %s", len(f.assertionFailures), assertions,
+			strings.Join(f.assertionFailures, "\n  "))
+	}
+	return msg
+}
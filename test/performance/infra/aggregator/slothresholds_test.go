@@ -0,0 +1,180 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestSLOThresholdViolations(t *testing.T) {
+	zero, tenth, fifth := 0.0, 0.1, 0.2
+	summary := &RunSummary{
+		Sent:             10,
+		PublishFailures:  1,
+		DeliveryFailures: 1,
+		E2ELatencies:     map[float64]time.Duration{50: 10 * time.Millisecond, 99: 80 * time.Millisecond},
+	}
+	for _, tc := range []struct {
+		name       string
+		thresholds SLOThresholds
+		summary    *RunSummary
+		want       []string
+	}{{
+		name:       "met",
+		thresholds: SLOThresholds{MaxP50Latency: 10 * time.Millisecond, MaxP99Latency: 100 * time.Millisecond, MaxErrorRate: &fifth},
+		summary:    summary,
+	}, {
+		name:       "p99 latency",
+		thresholds: SLOThresholds{MaxP50Latency: 20 * time.Millisecond, MaxP99Latency: 50 * time.Millisecond},
+		summary:    summary,
+		want:       []string{"p99 end to end latency 80ms exceeds 50ms"},
+	}, {
+		name:       "error rate",
+		thresholds: SLOThresholds{MaxErrorRate: &tenth},
+		summary:    summary,
+		want:       []string{"error rate 0.2 (1 publish and 1 delivery failures of 10 sent events) exceeds 0.1"},
+	}, {
+		name:       "no error allowed",
+		thresholds: SLOThresholds{MaxErrorRate: &zero},
+		summary:    &RunSummary{Sent: 10, DeliveryFailures: 1},
+		want:       []string{"error rate 0.1 (0 publish and 1 delivery failures of 10 sent events) exceeds 0"},
+	}, {
+		name:       "low sample",
+		thresholds: SLOThresholds{MaxP50Latency: time.Second, MaxErrorRate: &zero},
+		summary:    &RunSummary{LowSample: true},
+		want: []string{
+			"p50 end to end latency unknown, too few events delivered to check it is at most 1s",
+			"error rate unknown, no event sent to check it is at most 0",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.thresholds.violations(tc.summary)
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("violations() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSLOThresholdsValidation(t *testing.T) {
+	negative, above := -0.1, 1.5
+	for _, thresholds := range []SLOThresholds{
+		{MaxP99Latency: -time.Millisecond},
+		{MaxErrorRate: &negative},
+		{MaxErrorRate: &above},
+	} {
+		if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithSLOThresholds(thresholds)); err == nil {
+			t.Errorf("NewAggregator() with SLO thresholds %+v succeeded", thresholds)
+		}
+	}
+}
+
+func TestViolatedSLOsFailTheRun(t *testing.T) {
+	var fatal fatalRecorder
+	zero := 0.0
+	ag := newTestAggregator(t, 2, WithMinSamplesForPercentiles(1), WithFatalHandler(fatal.fatalf),
+		WithSLOThresholds(SLOThresholds{MaxP99Latency: time.Second, MaxErrorRate: &zero}),
+		WithAssertions("fr == 0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "delivered", "lost"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "delivered", "lost"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "delivered"),
+	}})
+	waitForRun(t, done)
+
+	errs := fatal.reported()
+	if len(errs) != 1 {
+		t.Fatalf("reported fatal errors %v, want one", errs)
+	}
+	// the failed run aggregate is set before the assertions are checked
+	if want := "1 SLO thresholds violated:\n  error rate 0.5"; !strings.HasPrefix(errs[0], want) ||
+		!strings.Contains(errs[0], "1 of 1 assertions failed:\n  fr == 0: 1 == 0 is false") {
+		t.Errorf("reported fatal error %q, want %s followed by the failed assertion", errs[0], want)
+	}
+	if !strings.Contains(ag.shortSummary, "status=FAIL") {
+		t.Errorf("short summary = %q, want it failed", ag.shortSummary)
+	}
+}
+
+func TestLowSampleFailedRunPublishesFailure(t *testing.T) {
+	var fatal fatalRecorder
+	// with the default minimum samples for percentiles, a single delivered
+	// event leaves the p99 latency unknown
+	ag := newTestAggregator(t, 2, WithFatalHandler(fatal.fatalf),
+		WithSLOThresholds(SLOThresholds{MaxP99Latency: time.Second}),
+		WithAssertions("fr == 0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := runAggregator(ctx, ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "delivered"),
+		newRecord(pb.EventsRecord_ACCEPTED, now, "delivered"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "delivered"),
+	}})
+	waitForRun(t, done)
+
+	errs := fatal.reported()
+	if len(errs) != 1 {
+		t.Fatalf("reported fatal errors %v, want one", errs)
+	}
+	if want := "p99 end to end latency unknown"; !strings.Contains(errs[0], want) ||
+		!strings.Contains(errs[0], "fr == 0: 1 == 0 is false") {
+		t.Errorf("reported fatal error %q, want %s and the failed assertion", errs[0], want)
+	}
+
+	failed := true
+	for _, a := range []runAggregates{
+		{minSamplesForPercentiles: defaultMinSamplesForPercentiles, e2eLatencies: []time.Duration{10 * time.Millisecond}, failedRun: &failed},
+		{degenerate: &failed, failedRun: &failed},
+	} {
+		values := make(aggregateValues)
+		a.publish(values)
+		if v, ok := values[failedRunKey]; !ok || v != 1 {
+			t.Errorf("published %s = %v, %t in a low sample or degenerate run, want 1", failedRunKey, v, ok)
+		}
+	}
+}
+
+func TestMetSLOsPublishPassedRun(t *testing.T) {
+	failed := false
+	values := make(aggregateValues)
+	(&runAggregates{failedRun: &failed}).publish(values)
+	if v, ok := values[failedRunKey]; !ok || v != 0 {
+		t.Errorf("published %s = %v, %t, want 0", failedRunKey, v, ok)
+	}
+	if msg := (runFailures{}).message(0); msg != "" {
+		t.Errorf("message() without failures = %q, want none", msg)
+	}
+}
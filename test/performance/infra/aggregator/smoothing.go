@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Modes computing the throughput published at the time of each event.
+const (
+	// number of events during the preceding second, the default
+	throughputWindowed = ""
+	// average number of events per second during the preceding lookback
+	throughputRolling = "rolling"
+	// exponentially weighted average of the event rate, with the lookback as
+	// time constant
+	throughputEWMA = "ewma"
+)
+
+// throughputSmoothing is the mode computing the throughput and its lookback.
+type throughputSmoothing struct {
+	mode     string
+	lookback time.Duration
+}
+
+// ewmaSmoothing returns the exponentially weighted average keeping the given
+// fraction of the rate estimate after one second, i.e. whose time constant is
+// -1/ln(decay) seconds, e.g. 1s for a decay of 1/e.
+func ewmaSmoothing(decay float64) throughputSmoothing {
+	return throughputSmoothing{
+		mode:     throughputEWMA,
+		lookback: time.Duration(-float64(time.Second) / math.Log(decay)),
+	}
+}
+
+// validate returns an error if the mode is unknown or its lookback isn't positive.
+func (s throughputSmoothing) validate() error {
+	switch s.mode {
+	case throughputWindowed:
+		return nil
+	case throughputRolling, throughputEWMA:
+		if s.lookback <= 0 {
+			return fmt.Errorf("invalid throughput lookback %v, must be positive", s.lookback)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown throughput mode %q, must be %s or %s", s.mode, throughputRolling, throughputEWMA)
+	}
+}
+
+// series returns the throughput at the time of each of the sorted timestamps
+// but the first one.
+func (s throughputSmoothing) series(timestamps []time.Time) []float64 {
+	values := make([]float64, 0, len(timestamps))
+	switch s.mode {
+	case throughputRolling:
+		var i int
+		for j, t := range timestamps[1:] {
+			// timestamps[i:j+2] are the events during the lookback ending at t
+			for i <= j && t.Sub(timestamps[i]) > s.lookback {
+				i++
+			}
+			values = append(values, float64(j+2-i)/s.lookback.Seconds())
+		}
+	case throughputEWMA:
+		// each event adds an impulse of 1/lookback, decaying exponentially
+		rate := 1 / s.lookback.Seconds()
+		for j, t := range timestamps[1:] {
+			decay := math.Exp(-t.Sub(timestamps[j]).Seconds() / s.lookback.Seconds())
+			rate = rate*decay + 1/s.lookback.Seconds()
+			values = append(values, rate)
+		}
+	default:
+		var i, thpt int
+		for j, t := range timestamps[1:] {
+			thpt++
+			for i < j && t.Sub(timestamps[i]) > time.Second {
+				i++
+				thpt--
+			}
+			values = append(values, float64(thpt))
+		}
+	}
+	return values
+}
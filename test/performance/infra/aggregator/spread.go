@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// redeliverySpreadKey is the value key of the p99 redelivery spread.
+const redeliverySpreadKey = "rs99"
+
+// timeSpread is the earliest and the latest timestamps of an event recorded
+// several times.
+type timeSpread struct {
+	earliest time.Time
+	latest   time.Time
+}
+
+// trackSpread widens the spread of the timestamps of the event with the given
+// ID with its recorded timestamp and the duplicate one, whichever the collapse
+// policy keeps. It must be called with the lock held.
+func (rec *eventsRecord) trackSpread(id string, recorded, duplicate *timestamp.Timestamp) {
+	d, err := ptypes.Timestamp(duplicate)
+	if err != nil {
+		return
+	}
+	s, ok := rec.spreads[id]
+	if !ok {
+		r, err := ptypes.Timestamp(recorded)
+		if err != nil {
+			return
+		}
+		s = &timeSpread{earliest: r, latest: r}
+		rec.spreads[id] = s
+	}
+	if d.Before(s.earliest) {
+		s.earliest = d
+	}
+	if d.After(s.latest) {
+		s.latest = d
+	}
+}
+
+// redeliverySpreads returns the sorted spreads between the earliest and the
+// latest deliveries of the events delivered several times, which is how much
+// the redeliveries added to their delivery.
+func redeliverySpreads(events []event) []time.Duration {
+	var spreads []time.Duration
+	for i := range events {
+		if events[i].isReceived && events[i].redeliverySpread != nil {
+			spreads = append(spreads, *events[i].redeliverySpread)
+		}
+	}
+	sort.Slice(spreads, func(i, j int) bool { return spreads[i] < spreads[j] })
+	return spreads
+}
+
+// reportRedeliverySpreads logs the distribution of the redelivery spreads,
+// returning their p99, nil without redelivered events.
+func reportRedeliverySpreads(spreads []time.Duration) *time.Duration {
+	if len(spreads) == 0 {
+		return nil
+	}
+	p99 := reportDistribution("Redelivery spread", "rs", spreads)[redeliverySpreadKey]
+	return &p99
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestRedeliverySpread(t *testing.T) {
+	// the spread doesn't depend on which timestamp the collapse policy keeps
+	for _, policy := range []string{"", collapseFirst, collapseLast, collapseAll} {
+		t.Run("collapse "+policy, func(t *testing.T) {
+			ag := newTestAggregator(t, 0, WithReceivedCollapse(policy))
+			now := time.Now()
+
+			recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+				newRecord(pb.EventsRecord_SENT, now, "once", "twice", "thrice"),
+				newRecord(pb.EventsRecord_ACCEPTED, now, "once", "twice", "thrice"),
+			}})
+			for _, delay := range []time.Duration{20 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond} {
+				ids := []string{"thrice"}
+				if delay != 50*time.Millisecond {
+					ids = append(ids, "twice")
+				}
+				if delay == 10*time.Millisecond {
+					ids = append(ids, "once")
+				}
+				recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+					newRecord(pb.EventsRecord_RECEIVED, now.Add(delay), ids...),
+				}})
+			}
+
+			spreads := redeliverySpreads(ag.joinEvents())
+			want := []time.Duration{10 * time.Millisecond, 40 * time.Millisecond}
+			if len(spreads) != len(want) || spreads[0] != want[0] || spreads[1] != want[1] {
+				t.Fatalf("redelivery spreads = %v, want %v", spreads, want)
+			}
+
+			a := runAggregates{
+				e2eLatencies:             []time.Duration{time.Millisecond},
+				minSamplesForPercentiles: 1,
+				redeliverySpread:         reportRedeliverySpreads(spreads),
+			}
+			q := newFakeAggregatesStore()
+			a.publish(q)
+			if got, want := q.run[redeliverySpreadKey], (40 * time.Millisecond).Seconds(); got != want {
+				t.Errorf("published redelivery spread p99 = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNoRedeliverySpreadWithoutDuplicates(t *testing.T) {
+	if p99 := reportRedeliverySpreads(nil); p99 != nil {
+		t.Errorf("redelivery spread p99 = %v without redelivered events, want nil", *p99)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// sqliteSchema creates the tables of the SQLite database. Timestamps are
+// nanoseconds since the Unix epoch and latencies are nanoseconds, NULL when
+// the event didn't reach the corresponding step. Partition and sequence are
+// NULL unless events are matched on their composite key.
+const sqliteSchema = `CREATE TABLE run (
+  key TEXT PRIMARY KEY,
+  value TEXT NOT NULL
+);
+CREATE TABLE events (
+  id TEXT PRIMARY KEY,
+  partition TEXT,
+  sequence INTEGER,
+  sender TEXT,
+  sent INTEGER NOT NULL,
+  accepted INTEGER,
+  received INTEGER,
+  publish_latency INTEGER,
+  e2e_latency INTEGER,
+  outcome TEXT NOT NULL
+);
+CREATE INDEX events_sent ON events (sent);
+CREATE INDEX events_partition ON events (partition);
+CREATE INDEX events_sender ON events (sender);
+CREATE INDEX events_outcome ON events (outcome);
+`
+
+// exportSQLite writes the joined events to the events table of a SQLite
+// database created at the given path, replacing any previous one. The
+// scenario, if any, is written to the run table.
+func exportSQLite(path string, scenario string, events []event) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	if err := writeSQLite(db, scenario, events); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return db.Close()
+}
+
+// writeSQLite creates the tables in the given database and inserts the joined
+// events in a single transaction.
+func writeSQLite(db *sql.DB, scenario string, events []event) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	if scenario != "" {
+		if _, err := tx.Exec("INSERT INTO run VALUES('scenario', ?)", scenario); err != nil {
+			return err
+		}
+	}
+
+	insert, err := tx.Prepare("INSERT INTO events VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for i := range events {
+		e := &events[i]
+
+		var partition, sequence, sender, accepted, received, publishLatency, e2eLatency interface{}
+		if e.key != nil {
+			partition, sequence = e.key.GetPartition(), e.key.GetSequence()
+		}
+		if e.sender != "" {
+			sender = e.sender
+		}
+		if e.isAccepted {
+			accepted = e.accepted.UnixNano()
+			publishLatency = e.publishLatency().Nanoseconds()
+		}
+		if e.isReceived {
+			received = e.received.UnixNano()
+			e2eLatency = e.e2eLatency().Nanoseconds()
+		}
+
+		if _, err := insert.Exec(e.id, partition, sequence, sender, e.sent.UnixNano(), accepted, received,
+			publishLatency, e2eLatency, e.outcome()); err != nil {
+			return fmt.Errorf("event %s: %v", e.id, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func testSQLiteEvents() []event {
+	sent := time.Unix(100, 0)
+	return []event{{
+		id:         "it's-1",
+		sender:     "sender-a",
+		sent:       sent,
+		accepted:   sent.Add(time.Millisecond),
+		received:   sent.Add(10 * time.Millisecond),
+		isAccepted: true,
+		isReceived: true,
+	}, {
+		id:   "2",
+		sent: sent,
+	}, {
+		id:         `"p0"/3`,
+		key:        &pb.EventKey{Partition: "p0", Sequence: 3},
+		sent:       sent,
+		accepted:   sent.Add(time.Millisecond),
+		isAccepted: true,
+	}, {
+		id:         `"p0"/4`,
+		key:        &pb.EventKey{Partition: "p0", Sequence: 4},
+		sent:       sent,
+		isAccepted: false,
+	}}
+}
+
+func TestExportSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	// written twice, replacing the first database
+	for i := 0; i < 2; i++ {
+		if err := exportSQLite(path, "burst", testSQLiteEvents()); err != nil {
+			t.Fatal("exportSQLite() =", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal("Failed to open the database:", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		t.Fatal("Failed to count the events:", err)
+	}
+	if count != 4 {
+		t.Errorf("events count = %d, want 4", count)
+	}
+
+	var (
+		sender                                 sql.NullString
+		sent, accepted, received, publish, e2e sql.NullInt64
+		outcome                                string
+	)
+	if err := db.QueryRow("SELECT sender, sent, accepted, received, publish_latency, e2e_latency, outcome FROM events WHERE id = ?", "it's-1").
+		Scan(&sender, &sent, &accepted, &received, &publish, &e2e, &outcome); err != nil {
+		t.Fatal("Failed to query the delivered event:", err)
+	}
+	if sender.String != "sender-a" || sent.Int64 != 100000000000 || accepted.Int64 != 100001000000 ||
+		received.Int64 != 100010000000 || publish.Int64 != 1000000 || e2e.Int64 != 10000000 || outcome != "delivered" {
+		t.Errorf("delivered event = %v, %v, %v, %v, %v, %v, %s", sender, sent, accepted, received, publish, e2e, outcome)
+	}
+
+	if err := db.QueryRow("SELECT sender, accepted, e2e_latency, outcome FROM events WHERE id = '2'").
+		Scan(&sender, &accepted, &e2e, &outcome); err != nil {
+		t.Fatal("Failed to query the failed event:", err)
+	}
+	if sender.Valid || accepted.Valid || e2e.Valid || outcome != "publish-failure" {
+		t.Errorf("failed event = %v, %v, %v, %s, want NULL, NULL, NULL, publish-failure", sender, accepted, e2e, outcome)
+	}
+
+	var partition string
+	var events, failures int
+	if err := db.QueryRow("SELECT partition, COUNT(*), SUM(outcome = 'publish-failure') FROM events WHERE partition IS NOT NULL GROUP BY partition").
+		Scan(&partition, &events, &failures); err != nil {
+		t.Fatal("Failed to group the events by partition:", err)
+	}
+	if partition != "p0" || events != 2 || failures != 1 {
+		t.Errorf("events grouped by partition = %s, %d, %d, want p0, 2, 1", partition, events, failures)
+	}
+
+	var scenario string
+	if err := db.QueryRow("SELECT value FROM run WHERE key = 'scenario'").Scan(&scenario); err != nil || scenario != "burst" {
+		t.Errorf("scenario = %q, %v, want burst", scenario, err)
+	}
+
+	// queries on the sent time use its index
+	var id, parent, unused int
+	var plan string
+	if err := db.QueryRow("EXPLAIN QUERY PLAN SELECT id FROM events WHERE sent > 0").Scan(&id, &parent, &unused, &plan); err != nil {
+		t.Fatal("Failed to explain the query:", err)
+	}
+	if want := "events_sent"; !strings.Contains(plan, want) {
+		t.Errorf("query plan %q doesn't use the %s index", plan, want)
+	}
+}
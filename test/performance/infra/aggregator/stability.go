@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// stabilityKey is the Mako value key of the stability score.
+const stabilityKey = "ss"
+
+// Values of the loss rate and of the coefficients of variation of the latency
+// and of the throughput at and above which they count as the worst in the
+// stability score.
+const (
+	stabilityLossLimit = 0.01
+	stabilityCVLimit   = 1.0
+)
+
+// stabilityWeights are the weights of the loss rate, the latency coefficient
+// of variation and the throughput coefficient of variation in the stability score.
+type stabilityWeights struct {
+	loss, latency, throughput float64
+}
+
+// defaultStabilityWeights weight the three components equally.
+var defaultStabilityWeights = stabilityWeights{loss: 1, latency: 1, throughput: 1}
+
+// validate returns an error if a weight is negative or all of them are zero.
+func (w stabilityWeights) validate() error {
+	if w.loss < 0 || w.latency < 0 || w.throughput < 0 || w.loss+w.latency+w.throughput == 0 {
+		return fmt.Errorf("invalid stability weights %v, %v and %v, must not be negative nor all zero",
+			w.loss, w.latency, w.throughput)
+	}
+	return nil
+}
+
+// coefficientOfVariation returns the ratio of the standard deviation to the
+// mean of the values, false if there are less than two values or their mean is zero.
+func coefficientOfVariation(values []float64) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return 0, false
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(squares/float64(len(values))) / mean, true
+}
+
+// deliveryRates returns the number of events received during each complete
+// second since the first received event.
+func deliveryRates(events []event) []float64 {
+	var received []time.Time
+	for i := range events {
+		if events[i].isReceived {
+			received = append(received, events[i].received)
+		}
+	}
+	if len(received) == 0 {
+		return nil
+	}
+	sort.Slice(received, func(x, y int) bool { return received[x].Before(received[y]) })
+	rates := make([]float64, int(received[len(received)-1].Sub(received[0])/time.Second))
+	for _, t := range received {
+		if i := int(t.Sub(received[0]) / time.Second); i < len(rates) {
+			rates[i]++
+		}
+	}
+	return rates
+}
+
+// stabilityScore is the stability score of a run and its components.
+type stabilityScore struct {
+	lossRate     float64
+	latencyCV    float64
+	throughputCV float64
+	// whether the coefficients of variation could be computed
+	hasLatencyCV    bool
+	hasThroughputCV bool
+	score           float64
+}
+
+// computeStabilityScore combines the loss rate of the events, the coefficient
+// of variation of their end to end latencies and the one of the number of
+// events received each second into a score from 0, the least stable, to 100.
+// Each component is normalized to [0, 1] as its ratio to its limit, capped at
+// 1, a coefficient of variation which can't be computed counting as 1, and the
+// score is 100 times 1 minus the weighted average of the normalized
+// components. It returns false without sent events.
+func computeStabilityScore(events []event, e2eLatencies []time.Duration, weights stabilityWeights) (stabilityScore, bool) {
+	var s stabilityScore
+	if len(events) == 0 {
+		return s, false
+	}
+	var received int
+	for i := range events {
+		if events[i].isReceived {
+			received++
+		}
+	}
+	s.lossRate = 1 - float64(received)/float64(len(events))
+
+	latencies := make([]float64, len(e2eLatencies))
+	for i, d := range e2eLatencies {
+		latencies[i] = d.Seconds()
+	}
+	s.latencyCV, s.hasLatencyCV = coefficientOfVariation(latencies)
+	s.throughputCV, s.hasThroughputCV = coefficientOfVariation(deliveryRates(events))
+
+	normalized := func(v, limit float64, ok bool) float64 {
+		if !ok {
+			return 1
+		}
+		return math.Min(v/limit, 1)
+	}
+	penalty := weights.loss*normalized(s.lossRate, stabilityLossLimit, true) +
+		weights.latency*normalized(s.latencyCV, stabilityCVLimit, s.hasLatencyCV) +
+		weights.throughput*normalized(s.throughputCV, stabilityCVLimit, s.hasThroughputCV)
+	s.score = 100 * (1 - penalty/(weights.loss+weights.latency+weights.throughput))
+	return s, true
+}
+
+// reportStabilityScore logs the stability score of the run and its components,
+// returning the score if any event was sent.
+func reportStabilityScore(events []event, e2eLatencies []time.Duration, weights stabilityWeights) *float64 {
+	s, ok := computeStabilityScore(events, e2eLatencies, weights)
+	if !ok {
+		log.Printf("Stability score undefined without sent events")
+		return nil
+	}
+	cv := func(v float64, ok bool) string {
+		if !ok {
+			return "undefined"
+		}
+		return fmt.Sprintf("%.3f", v)
+	}
+	log.Printf("Stability score: %.1f (loss rate %.2f%%, latency CV %s, throughput CV %s, weighted %v, %v and %v)",
+		s.score, s.lossRate*100, cv(s.latencyCV, s.hasLatencyCV), cv(s.throughputCV, s.hasThroughputCV),
+		weights.loss, weights.latency, weights.throughput)
+	return &s.score
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// steadyEvents returns events sent every 100ms for the given duration,
+// delivered with the given latencies in turn, the last lost ones not delivered.
+func steadyEvents(duration time.Duration, lost int, latencies ...time.Duration) []event {
+	start := time.Unix(1000, 0)
+	var events []event
+	for i := 0; time.Duration(i)*100*time.Millisecond < duration; i++ {
+		sent := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		events = append(events, event{sent: sent, received: sent.Add(latencies[i%len(latencies)]), isAccepted: true, isReceived: true})
+	}
+	for i := len(events) - lost; i < len(events); i++ {
+		events[i].isReceived = false
+	}
+	return events
+}
+
+func TestStabilityScore(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		events  []event
+		weights stabilityWeights
+		want    float64
+	}{{
+		name:    "steady",
+		events:  steadyEvents(10*time.Second, 0, 10*time.Millisecond),
+		weights: defaultStabilityWeights,
+		want:    100,
+	}, {
+		// a loss rate of 0.5% is half the limit
+		name:    "lossy",
+		events:  steadyEvents(20*time.Second, 1, 10*time.Millisecond),
+		weights: stabilityWeights{loss: 1},
+		want:    50,
+	}, {
+		// latencies of 10 and 30ms have a coefficient of variation of 0.5
+		name:    "jittery",
+		events:  steadyEvents(10*time.Second, 0, 10*time.Millisecond, 30*time.Millisecond),
+		weights: stabilityWeights{loss: 1, latency: 1},
+		want:    75,
+	}, {
+		// all the components count as the worst
+		name:    "nothing delivered",
+		events:  steadyEvents(10*time.Second, 100, 10*time.Millisecond),
+		weights: defaultStabilityWeights,
+		want:    0,
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			s, ok := computeStabilityScore(c.events, e2eLatencies(c.events), c.weights)
+			if !ok {
+				t.Fatal("stability score undefined")
+			}
+			if math.Abs(s.score-c.want) > 1e-6 {
+				t.Errorf("stability score = %v (%+v), want %v", s.score, s, c.want)
+			}
+		})
+	}
+
+	if _, ok := computeStabilityScore(nil, nil, defaultStabilityWeights); ok {
+		t.Error("stability score defined without sent events")
+	}
+}
+
+func TestStabilityThroughputVariation(t *testing.T) {
+	// 10 events received during the first second, none during the second and
+	// one during the third, incomplete one
+	events := steadyEvents(time.Second, 0, 10*time.Millisecond)
+	late := time.Unix(1002, 500*int64(time.Millisecond))
+	events = append(events, event{sent: late, received: late, isReceived: true})
+	if got, want := deliveryRates(events), []float64{10, 0}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("delivery rates = %v, want %v", got, want)
+	}
+	s, _ := computeStabilityScore(events, e2eLatencies(events), stabilityWeights{throughput: 1})
+	if !s.hasThroughputCV || s.throughputCV != 1 || s.score != 0 {
+		t.Errorf("stability score = %+v, want a throughput CV of 1 scoring 0", s)
+	}
+}
+
+func TestInvalidStabilityWeightsAreRejected(t *testing.T) {
+	for _, w := range []stabilityWeights{{}, {loss: -1, latency: 2}} {
+		if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithStabilityWeights(w.loss, w.latency, w.throughput)); err == nil {
+			t.Errorf("NewAggregator() with the stability weights %+v succeeded", w)
+		}
+	}
+}
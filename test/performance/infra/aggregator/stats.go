@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the buckets of the latency histograms.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// histogram counts durations into buckets of increasing upper bounds. The last
+// count is the overflow bucket, holding the durations above the last bound.
+type histogram struct {
+	bounds []time.Duration
+	counts []uint64
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// add counts the given duration in the first bucket whose bound is greater or equal to it.
+func (h *histogram) add(d time.Duration) {
+	h.counts[sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })]++
+}
+
+// bucketLabel returns a human readable label for the i-th bucket.
+func (h *histogram) bucketLabel(i int) string {
+	if i == len(h.bounds) {
+		return fmt.Sprintf("> %v", h.bounds[len(h.bounds)-1])
+	}
+	return fmt.Sprintf("<= %v", h.bounds[i])
+}
+
+// sortDurations sorts the given durations in increasing order.
+func sortDurations(d []time.Duration) {
+	sort.Slice(d, func(x, y int) bool { return d[x] < d[y] })
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the given sorted
+// durations, using the nearest-rank method. It returns 0 for an empty slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[percentileRank(len(sorted), p)-1]
+}
+
+// percentileRank returns the 1-based nearest rank of the p-th percentile of n
+// samples. The rank is rounded down within a tolerance, as fractional
+// percentiles such as 99.9 aren't exact in floating point.
+func percentileRank(n int, p float64) int {
+	rank := int(math.Ceil(p/100*float64(n) - 1e-9))
+	if rank < 1 {
+		return 1
+	} else if rank > n {
+		return n
+	}
+	return rank
+}
+
+// defaultMinSamplesForPercentiles is the default number of delivered events
+// below which percentiles are too unreliable to be published.
+const defaultMinSamplesForPercentiles = 100
+
+// reportedPercentiles are the percentiles logged and published for the latency distributions.
+var reportedPercentiles = []float64{50, 90, 99}
+
+// reportDistribution logs the percentiles and the histogram of the given
+// sorted durations, and returns the percentiles keyed by "<prefix><percentile>".
+func reportDistribution(name, prefix string, sorted []time.Duration) map[string]time.Duration {
+	log.Printf("%s distribution over %d samples:", name, len(sorted))
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	aggregates := make(map[string]time.Duration, len(reportedPercentiles))
+	for _, p := range reportedPercentiles {
+		v := percentile(sorted, p)
+		log.Printf("  p%v: %v", p, v)
+		aggregates[fmt.Sprintf("%s%v", prefix, p)] = v
+	}
+
+	h := newHistogram(latencyBuckets)
+	for _, d := range sorted {
+		h.add(d)
+	}
+	for i, c := range h.counts {
+		log.Printf("  %-10s %d", h.bucketLabel(i), c)
+	}
+
+	return aggregates
+}
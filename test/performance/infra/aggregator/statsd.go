@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// statsdSink emits the summary of the run as statsd gauges and timers over UDP.
+type statsdSink struct {
+	address string
+	prefix  string
+}
+
+// NewStatsdSink returns a result sink emitting the counts and rates of the run
+// as statsd gauges, and its latency percentiles as timers in milliseconds, to
+// the given UDP address, with the metric names prefixed by prefix, followed by
+// the scenario of the run, if any.
+func NewStatsdSink(address, prefix string) ResultSink {
+	return &statsdSink{address: address, prefix: prefix}
+}
+
+func (s *statsdSink) Name() string {
+	return "statsd at " + s.address
+}
+
+func (s *statsdSink) Publish(ctx context.Context, summary *RunSummary) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", s.address, err)
+	}
+	defer conn.Close()
+	// one metric per datagram, which all statsd implementations accept
+	for _, line := range s.lines(summary) {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return fmt.Errorf("failed to send %q: %v", line, err)
+		}
+	}
+	return nil
+}
+
+// lines returns the statsd lines of the summary.
+func (s *statsdSink) lines(summary *RunSummary) []string {
+	prefix := s.prefix
+	if summary.Scenario != "" {
+		prefix += summary.Scenario + "."
+	}
+	gauge := func(name string, v float64) string {
+		return fmt.Sprintf("%s%s:%s|g", prefix, name, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	timers := func(name string, percentiles map[float64]time.Duration) []string {
+		ps := make([]float64, 0, len(percentiles))
+		for p := range percentiles {
+			ps = append(ps, p)
+		}
+		sort.Float64s(ps)
+		lines := make([]string, 0, len(ps))
+		for _, p := range ps {
+			ms := float64(percentiles[p]) / float64(time.Millisecond)
+			lines = append(lines, fmt.Sprintf("%s%s.p%v:%s|ms", prefix, name, p, strconv.FormatFloat(ms, 'f', -1, 64)))
+		}
+		return lines
+	}
+
+	lines := []string{
+		gauge("sent", float64(summary.Sent)),
+		gauge("accepted", float64(summary.Accepted)),
+		gauge("received", float64(summary.Received)),
+		gauge("publish_failures", float64(summary.PublishFailures)),
+		gauge("delivery_failures", float64(summary.DeliveryFailures)),
+		gauge("publish_failure_rate", summary.PublishFailureRate),
+		gauge("delivery_failure_rate", summary.DeliveryFailureRate),
+	}
+	lines = append(lines, timers("publish_latency", summary.PublishLatencies)...)
+	lines = append(lines, timers("e2e_latency", summary.E2ELatencies)...)
+	return lines
+}
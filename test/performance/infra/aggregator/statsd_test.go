@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsdSink(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	defer listener.Close()
+
+	// 100 events delivered with end to end latencies of 1 to 100ms, 2 lost and 1 not accepted
+	sent := time.Unix(1000, 0)
+	var events []event
+	for i := 1; i <= 100; i++ {
+		events = append(events, event{
+			id: fmt.Sprint(i), sent: sent,
+			accepted: sent.Add(time.Millisecond), received: sent.Add(time.Duration(i) * time.Millisecond),
+			isAccepted: true, isReceived: true,
+		})
+	}
+	events = append(events,
+		event{id: "lost-1", sent: sent, accepted: sent.Add(time.Millisecond), isAccepted: true},
+		event{id: "lost-2", sent: sent, accepted: sent.Add(time.Millisecond), isAccepted: true},
+		event{id: "rejected", sent: sent},
+	)
+	aggregates := runAggregates{
+		publishErrors:            1,
+		deliverErrors:            2,
+		publishLatencies:         publishLatencies(events),
+		e2eLatencies:             e2eLatencies(events),
+		minSamplesForPercentiles: defaultMinSamplesForPercentiles,
+	}
+	summary := newRunSummary("run", "", events, &aggregates)
+
+	sink := NewStatsdSink(listener.LocalAddr().String(), "perf.")
+	if err := sink.Publish(context.Background(), summary); err != nil {
+		t.Fatal("Publish() =", err)
+	}
+
+	want := []string{
+		"perf.sent:103|g",
+		"perf.accepted:102|g",
+		"perf.received:100|g",
+		"perf.publish_failures:1|g",
+		"perf.delivery_failures:2|g",
+		fmt.Sprintf("perf.publish_failure_rate:%v|g", 1.0/103),
+		fmt.Sprintf("perf.delivery_failure_rate:%v|g", 2.0/102),
+		"perf.publish_latency.p50:1|ms",
+		"perf.publish_latency.p90:1|ms",
+		"perf.publish_latency.p99:1|ms",
+		"perf.e2e_latency.p50:50|ms",
+		"perf.e2e_latency.p90:90|ms",
+		"perf.e2e_latency.p99:99|ms",
+	}
+	buf := make([]byte, 1024)
+	for _, w := range want {
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to receive %q: %v", w, err)
+		}
+		if got := string(buf[:n]); got != w {
+			t.Errorf("received %q, want %q", got, w)
+		}
+	}
+}
+
+func TestStatsdSinkLowSample(t *testing.T) {
+	aggregates := runAggregates{minSamplesForPercentiles: defaultMinSamplesForPercentiles}
+	sink := NewStatsdSink("127.0.0.1:0", "").(*statsdSink)
+	for _, line := range sink.lines(newRunSummary("run", "", nil, &aggregates)) {
+		if line[len(line)-3:] == "|ms" {
+			t.Errorf("got timer %q in a low sample run, want no percentile", line)
+		}
+	}
+}
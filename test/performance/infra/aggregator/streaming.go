@@ -0,0 +1,373 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"knative.dev/pkg/test/mako"
+)
+
+// maxStreamedErrorTimestamps bounds the sent times of the failed events kept
+// in streaming aggregation for each kind of failure, the others only being
+// counted.
+const maxStreamedErrorTimestamps = 100000
+
+// streamingStats are the statistics the events are folded into in streaming
+// aggregation, whose size doesn't depend on the number of events: their
+// counts, the histograms of their latencies and the number of events sent and
+// received each second.
+type streamingStats struct {
+	sync.Mutex
+
+	// number of events folded once recorded at all their steps, and once
+	// expired before that
+	folded, expired int
+
+	sent, accepted, received int
+	publish, e2e             *hdrHistogram
+	// number of events sent and received each second, keyed by Unix time
+	sentPerSecond, receivedPerSecond map[int64]int
+	// time the first event was sent and the last one received
+	firstSent, lastReceived time.Time
+
+	// number of events which failed to be published and delivered, and the
+	// sent times of the first maxStreamedErrorTimestamps of each
+	publishErrors, deliverErrors                   int
+	publishErrorTimestamps, deliverErrorTimestamps []time.Time
+	// number of the sent times of the failed events beyond the bound
+	droppedErrorTimestamps int
+}
+
+func newStreamingStats() *streamingStats {
+	return &streamingStats{
+		publish:           newLatencyHistogram(),
+		e2e:               newLatencyHistogram(),
+		sentPerSecond:     make(map[int64]int),
+		receivedPerSecond: make(map[int64]int),
+	}
+}
+
+// fold adds the event to the statistics. It must be called with the lock held.
+func (s *streamingStats) fold(e *event) {
+	switch e.outcome() {
+	case outcomePublishFailure:
+		s.publishErrors++
+		s.publishErrorTimestamps = s.appendErrorTimestamp(s.publishErrorTimestamps, e.sent)
+	case outcomeDeliveryFailure:
+		s.deliverErrors++
+		s.deliverErrorTimestamps = s.appendErrorTimestamp(s.deliverErrorTimestamps, e.sent)
+	}
+	s.sent++
+	s.sentPerSecond[e.sent.Unix()]++
+	if s.firstSent.IsZero() || e.sent.Before(s.firstSent) {
+		s.firstSent = e.sent
+	}
+	if e.isAccepted {
+		s.accepted++
+		s.publish.record(e.publishLatency())
+	}
+	if e.isReceived {
+		s.received++
+		s.e2e.record(e.e2eLatency())
+		s.receivedPerSecond[e.received.Unix()]++
+		if e.received.After(s.lastReceived) {
+			s.lastReceived = e.received
+		}
+	}
+}
+
+// appendErrorTimestamp appends the sent time of a failed event to the given
+// ones, unless they reached the bound. It must be called with the lock held.
+func (s *streamingStats) appendErrorTimestamp(timestamps []time.Time, sent time.Time) []time.Time {
+	if len(timestamps) >= maxStreamedErrorTimestamps {
+		s.droppedErrorTimestamps++
+		return timestamps
+	}
+	return append(timestamps, sent)
+}
+
+// foldComplete folds the given newly recorded events which are now recorded as
+// sent, accepted and received, and forgets them, so that only the events
+// which may still be matched are kept in memory. It must be called without
+// holding the lock of the events records.
+func (ag *Aggregator) foldComplete(added []matchedEvent) {
+	if len(added) == 0 {
+		return
+	}
+	var complete []event
+	// in the same order as the other functions locking several records
+	ag.sentEvents.Lock()
+	ag.acceptedEvents.Lock()
+	ag.receivedEvents.Lock()
+	for _, a := range added {
+		sent, ok := ag.sentEvents.Events[a.id]
+		if !ok {
+			continue
+		}
+		accepted, ok := ag.acceptedEvents.Events[a.id]
+		if !ok {
+			continue
+		}
+		received, ok := ag.receivedEvents.Events[a.id]
+		if !ok {
+			continue
+		}
+		e := event{id: a.id, isAccepted: true, isReceived: true}
+		e.sent, _ = ptypes.Timestamp(sent)
+		e.accepted, _ = ptypes.Timestamp(accepted)
+		e.received, _ = ptypes.Timestamp(received)
+		complete = append(complete, e)
+		ag.sentEvents.remove(a.id)
+		ag.acceptedEvents.remove(a.id)
+		ag.receivedEvents.remove(a.id)
+	}
+	ag.receivedEvents.Unlock()
+	ag.acceptedEvents.Unlock()
+	ag.sentEvents.Unlock()
+
+	if len(complete) == 0 {
+		return
+	}
+	ag.streaming.Lock()
+	defer ag.streaming.Unlock()
+	for i := range complete {
+		ag.streaming.fold(&complete[i])
+	}
+	ag.streaming.folded += len(complete)
+}
+
+// foldExpired folds the sent events recorded for longer than the unmatched TTL
+// without being accepted and received as failed, and forgets them, so that the
+// events which never complete don't accumulate until the end of the run. It
+// returns the number of events folded.
+func (ag *Aggregator) foldExpired() int {
+	now := ag.now()
+	var expired []event
+	// in the same order as the other functions locking several records
+	ag.sentEvents.Lock()
+	ag.acceptedEvents.Lock()
+	ag.receivedEvents.Lock()
+	for id, at := range ag.sentEvents.inserted {
+		if now.Sub(at) < ag.unmatchedTTL {
+			continue
+		}
+		e := event{id: id}
+		e.sent, _ = ptypes.Timestamp(ag.sentEvents.Events[id])
+		if accepted, ok := ag.acceptedEvents.Events[id]; ok {
+			e.isAccepted = true
+			e.accepted, _ = ptypes.Timestamp(accepted)
+		}
+		if received, ok := ag.receivedEvents.Events[id]; ok {
+			e.isReceived = true
+			e.received, _ = ptypes.Timestamp(received)
+		}
+		expired = append(expired, e)
+		ag.sentEvents.remove(id)
+		ag.acceptedEvents.remove(id)
+		ag.receivedEvents.remove(id)
+	}
+	ag.receivedEvents.Unlock()
+	ag.acceptedEvents.Unlock()
+	ag.sentEvents.Unlock()
+
+	if len(expired) == 0 {
+		return 0
+	}
+	ag.streaming.Lock()
+	defer ag.streaming.Unlock()
+	for i := range expired {
+		ag.streaming.fold(&expired[i])
+	}
+	ag.streaming.expired += len(expired)
+	return len(expired)
+}
+
+// publishPerSecond publishes the number of events of each second as the
+// throughput, in events per second.
+func publishPerSecond(counts map[int64]int, q samplePointStore, metricName string) error {
+	seconds := make([]int64, 0, len(counts))
+	for s := range counts {
+		seconds = append(seconds, s)
+	}
+	sort.Slice(seconds, func(x, y int) bool { return seconds[x] < seconds[y] })
+	for _, s := range seconds {
+		if err := q.AddSamplePoint(mako.XTime(time.Unix(s, 0)), map[string]float64{metricName: float64(counts[s])}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamingResults folds the events left once all the records are received,
+// which are those not recorded at all their steps, and returns the aggregates
+// and the summary of the run, along with the sent times of the events which
+// failed to be published and delivered, up to maxStreamedErrorTimestamps each.
+func (ag *Aggregator) streamingResults(distinctSenders int) (*runAggregates, *RunSummary, []time.Time, []time.Time) {
+	s := ag.streaming
+	s.Lock()
+	defer s.Unlock()
+
+	if windows := ag.windows.closeAll(ag.now()); len(windows) > 0 {
+		log.Printf("!! %d windows were marked during the run, their events aren't excluded in streaming aggregation", len(windows))
+	}
+
+	events := ag.joinEvents()
+	for i := range events {
+		s.fold(&events[i])
+	}
+	log.Printf("Streaming aggregation: %d events folded as they were recorded, %d as they expired, %d left at the end",
+		s.folded, s.expired, len(events))
+	log.Printf("Publish failure count: %d", s.publishErrors)
+	log.Printf("Delivery failure count: %d", s.deliverErrors)
+	if s.droppedErrorTimestamps > 0 {
+		log.Printf("!! The sent times of %d failed events beyond the first %d of each kind of failure are not published",
+			s.droppedErrorTimestamps, maxStreamedErrorTimestamps)
+	}
+	if ag.failedEventsPath != "" {
+		// the events left at the end are the failed ones, but for the expired ones
+		if err := exportFailedEvents(ag.failedEventsPath, events); err != nil {
+			log.Printf("ERROR writing the failed events: %v", err)
+		}
+	}
+
+	aggregates := &runAggregates{
+		publishErrors:            s.publishErrors,
+		deliverErrors:            s.deliverErrors,
+		oversizeRejected:         ag.oversize.count(),
+		distinctSenders:          distinctSenders,
+		publishHistogram:         s.publish,
+		e2eHistogram:             s.e2e,
+		minSamplesForPercentiles: ag.minSamplesForPercentiles,
+		duplicates:               ag.collectDuplicates(),
+	}
+	if aggregates.lowSample() {
+		log.Printf("!! Only %d events were delivered, less than the %d required to publish percentiles",
+			s.e2e.count, ag.minSamplesForPercentiles)
+	} else {
+		aggregates.latencyPercentiles = reportHistogramPercentiles(s.publish, s.e2e)
+		if len(ag.highPercentiles) > 0 {
+			aggregates.highPercentiles = reportHighPercentilesOf(int(s.e2e.count), s.e2e.percentile, ag.highPercentiles)
+		}
+	}
+
+	summary := &RunSummary{
+		RunID:            ag.runID,
+		Scenario:         ag.scenario,
+		Sent:             s.sent,
+		Accepted:         s.accepted,
+		Received:         s.received,
+		PublishFailures:  aggregates.publishErrors,
+		DeliveryFailures: aggregates.deliverErrors,
+		LowSample:        aggregates.lowSample(),
+		Passed:           aggregates.publishErrors == 0 && aggregates.deliverErrors == 0,
+	}
+	if span := s.lastReceived.Sub(s.firstSent); span > 0 {
+		summary.Throughput = float64(s.received) / span.Seconds()
+	}
+	if s.sent > 0 {
+		summary.PublishFailureRate = float64(summary.PublishFailures) / float64(s.sent)
+	}
+	if s.accepted > 0 {
+		summary.DeliveryFailureRate = float64(summary.DeliveryFailures) / float64(s.accepted)
+	}
+	if !summary.LowSample {
+		summary.PublishLatencies = make(map[float64]time.Duration, len(reportedPercentiles))
+		summary.E2ELatencies = make(map[float64]time.Duration, len(reportedPercentiles))
+		for _, p := range reportedPercentiles {
+			if s.publish.count > 0 {
+				summary.PublishLatencies[p] = s.publish.percentile(p)
+			}
+			summary.E2ELatencies[p] = s.e2e.percentile(p)
+		}
+	}
+	return aggregates, summary, s.publishErrorTimestamps, s.deliverErrorTimestamps
+}
+
+// publishStreamingResults summarizes and publishes the results of the run in
+// streaming aggregation, which are limited to the counts, the latency
+// percentiles and the throughputs, as the events were forgotten once folded.
+// It returns the reasons the run failed.
+func (ag *Aggregator) publishStreamingResults(ctx context.Context, exporter Exporter, store resultsStore,
+	fatalf func(string, ...interface{}), distinctSenders int, memStats *memStatsSampler) runFailures {
+	aggregates, summary, publishErrorTimestamps, deliverErrorTimestamps := ag.streamingResults(distinctSenders)
+	if ag.unmatchedTTL > 0 {
+		abandoned := atomic.LoadUint64(&ag.abandoned)
+		log.Printf("Abandoned events: %d accepted or received events without sent event expired after %v", abandoned, ag.unmatchedTTL)
+		aggregates.abandoned = &abandoned
+	}
+	aggregates.missingRecords = ag.reportedMissingRecords()
+	summary.setMissingRecords(aggregates.missingRecords)
+	if memStats != nil {
+		m := memStats.finish()
+		log.Printf("Aggregator memory: peak heap %.1fMiB, %d GCs paused %v",
+			float64(m.peakHeapBytes)/(1024*1024), m.gcCount, m.gcPause)
+		aggregates.memory = &m
+	}
+	if ag.hdrHistogramPath != "" {
+		log.Printf("Writing the end to end latency histogram to %s", ag.hdrHistogramPath)
+		if err := exportHDRHistogram(ag.hdrHistogramPath, ag.streaming.e2e); err != nil {
+			log.Printf("ERROR writing the end to end latency histogram: %v", err)
+		}
+	}
+	failures := ag.summarize(ctx, aggregates, summary)
+	if !ag.publishResults {
+		return failures
+	}
+
+	log.Printf("Publishing errors")
+	for _, t := range publishErrorTimestamps {
+		if qerr := exporter.AddError(mako.XTime(t), publishFailureMessage); qerr != nil {
+			log.Printf("ERROR AddError for publish-failure: %v", qerr)
+		}
+	}
+	for _, t := range deliverErrorTimestamps {
+		if qerr := exporter.AddError(mako.XTime(t), deliverFailureMessage); qerr != nil {
+			log.Printf("ERROR AddError for deliver-failure: %v", qerr)
+		}
+	}
+
+	log.Printf("Publishing throughputs")
+	if err := publishPerSecond(ag.streaming.sentPerSecond, store, "st"); err != nil {
+		log.Printf("ERROR AddSamplePoint for send-throughput: %v", err)
+	}
+	if err := publishPerSecond(ag.streaming.receivedPerSecond, store, "dt"); err != nil {
+		log.Printf("ERROR AddSamplePoint for deliver-throughput: %v", err)
+	}
+	if err := publishThpt(publishErrorTimestamps, store, "pet", ag.throughputSmoothing); err != nil {
+		log.Printf("ERROR AddSamplePoint for publish-failure-throughput: %v", err)
+	}
+	if err := publishThpt(deliverErrorTimestamps, store, "det", ag.throughputSmoothing); err != nil {
+		log.Printf("ERROR AddSamplePoint for deliver-failure-throughput: %v", err)
+	}
+
+	log.Printf("Publishing aggregates")
+	aggregates.publish(store)
+	ag.producerTags.publishTo(exporter)
+
+	log.Printf("Flushing the results to %s", exporter.Name())
+	if err := exporter.Flush(ctx); err != nil {
+		fatalf("Failed to store data and handle the result: %v\n", err)
+	}
+	return failures
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestStreamingAggregationFoldsCompleteEvents(t *testing.T) {
+	ag := newTestAggregator(t, 3, WithStreamingAggregation(true))
+
+	now := time.Now()
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a", "b", "c", "d"),
+	}})
+	// received before accepted: the events are complete once accepted
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "a", "b"),
+	}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_ACCEPTED, now.Add(2*time.Millisecond), "a", "c"),
+	}})
+
+	for _, rec := range []struct {
+		name string
+		r    *eventsRecord
+		want int
+	}{
+		{"sent", ag.sentEvents, 3},
+		{"accepted", ag.acceptedEvents, 1},
+		{"received", ag.receivedEvents, 1},
+	} {
+		if got := len(rec.r.Events); got != rec.want {
+			t.Errorf("%d %s events kept, want %d", got, rec.name, rec.want)
+		}
+	}
+	if _, ok := ag.sentEvents.Events["a"]; ok {
+		t.Error("The complete event is still kept")
+	}
+
+	s := ag.streaming
+	if s.folded != 1 || s.sent != 1 || s.accepted != 1 || s.received != 1 {
+		t.Errorf("folded %d events, %d sent, %d accepted, %d received, want 1 of each", s.folded, s.sent, s.accepted, s.received)
+	}
+	if got := s.e2e.max; got != 10*time.Millisecond {
+		t.Errorf("folded end to end latency = %v, want 10ms", got)
+	}
+}
+
+func TestStreamingAggregationRun(t *testing.T) {
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 2, WithStreamingAggregation(true), WithMinSamplesForPercentiles(1),
+		WithSummaryExport(filepath.Join(dir, "summary.json")), WithHDRHistogramExport(filepath.Join(dir, "e2e.hgrm")))
+	done := runAggregator(context.Background(), ag)
+
+	now := time.Now()
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, now, "a", "b", "c", "d"),
+		newRecord(pb.EventsRecord_ACCEPTED, now.Add(2*time.Millisecond), "a", "b", "c"),
+	}})
+	publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, now.Add(10*time.Millisecond), "a", "b"),
+	}})
+	waitForRun(t, done)
+
+	// the run ID is added to the path of the summary
+	summary, err := loadRunSummary(ag.summaryPath)
+	if err != nil {
+		t.Fatal("loadRunSummary() =", err)
+	}
+	if summary.Sent != 4 || summary.Accepted != 3 || summary.Received != 2 {
+		t.Errorf("summary counts %d sent, %d accepted, %d received, want 4, 3 and 2",
+			summary.Sent, summary.Accepted, summary.Received)
+	}
+	if summary.PublishFailures != 1 || summary.DeliveryFailures != 1 {
+		t.Errorf("summary counts %d publish and %d delivery failures, want 1 of each",
+			summary.PublishFailures, summary.DeliveryFailures)
+	}
+	if got := summary.PublishLatencies[99]; got != 2*time.Millisecond {
+		t.Errorf("p99 publish latency = %v, want 2ms", got)
+	}
+	if got := summary.E2ELatencies[50]; got != 10*time.Millisecond {
+		t.Errorf("p50 end to end latency = %v, want 10ms", got)
+	}
+
+	// the histogram the latencies were folded into
+	content, err := ioutil.ReadFile(ag.hdrHistogramPath)
+	if err != nil {
+		t.Fatal("Failed to read the histogram:", err)
+	}
+	if want := "#[Max     =       10.000, Total count    =            2]"; !strings.Contains(string(content), want) {
+		t.Errorf("histogram lacks %q:\n%s", want, content)
+	}
+}
+
+func TestStreamingAggregationFoldsExpiredEvents(t *testing.T) {
+	ag := newTestAggregator(t, 0, WithStreamingAggregation(true), WithUnmatchedTTL(time.Minute))
+	clock := time.Now()
+	ag.now = func() time.Time { return clock }
+
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_SENT, clock, "delivered", "not-received", "not-accepted"),
+		newRecord(pb.EventsRecord_ACCEPTED, clock.Add(2*time.Millisecond), "delivered", "not-received"),
+	}})
+	recordEvents(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, clock.Add(10*time.Millisecond), "delivered"),
+	}})
+
+	clock = clock.Add(30 * time.Second)
+	if folded := ag.foldExpired(); folded != 0 {
+		t.Errorf("folded %d events before the TTL, want 0", folded)
+	}
+	clock = clock.Add(31 * time.Second)
+	if folded := ag.foldExpired(); folded != 2 {
+		t.Errorf("folded %d events past the TTL, want 2", folded)
+	}
+	if len(ag.sentEvents.Events) != 0 || len(ag.acceptedEvents.Events) != 0 {
+		t.Errorf("kept %d sent and %d accepted events, want none", len(ag.sentEvents.Events), len(ag.acceptedEvents.Events))
+	}
+
+	s := ag.streaming
+	if s.folded != 1 || s.expired != 2 || s.sent != 3 || s.accepted != 2 || s.received != 1 {
+		t.Errorf("folded %d complete and %d expired events, %d sent, %d accepted, %d received, want 1, 2, 3, 2 and 1",
+			s.folded, s.expired, s.sent, s.accepted, s.received)
+	}
+	if s.publishErrors != 1 || s.deliverErrors != 1 {
+		t.Errorf("counted %d publish and %d delivery failures, want 1 of each", s.publishErrors, s.deliverErrors)
+	}
+}
+
+func TestStreamingErrorTimestampsAreBounded(t *testing.T) {
+	s := newStreamingStats()
+	sent := time.Now()
+	for i := 0; i < maxStreamedErrorTimestamps+10; i++ {
+		s.fold(&event{id: "failed", sent: sent})
+	}
+	if s.publishErrors != maxStreamedErrorTimestamps+10 {
+		t.Errorf("counted %d publish failures, want %d", s.publishErrors, maxStreamedErrorTimestamps+10)
+	}
+	if len(s.publishErrorTimestamps) != maxStreamedErrorTimestamps || s.droppedErrorTimestamps != 10 {
+		t.Errorf("kept %d sent times and dropped %d, want %d and 10",
+			len(s.publishErrorTimestamps), s.droppedErrorTimestamps, maxStreamedErrorTimestamps)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultSubmissionTTL is how long the submission IDs are remembered by
+// default: the retries of an upload happen within seconds.
+const defaultSubmissionTTL = 10 * time.Minute
+
+// submissionCache remembers the IDs of the submissions of records seen
+// recently, to record the retries of an upload once, with a bounded memory on
+// long runs. IDs are kept in two time buckets, the current one and the previous
+// one, rotated every TTL, so that an ID is remembered for at least the TTL and
+// at most twice the TTL. A retry arriving later is recorded as a new submission.
+type submissionCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	rotated time.Time
+
+	current  map[string]struct{}
+	previous map[string]struct{}
+}
+
+func newSubmissionCache(ttl time.Duration) *submissionCache {
+	return &submissionCache{
+		ttl:      ttl,
+		now:      time.Now,
+		rotated:  time.Now(),
+		current:  make(map[string]struct{}),
+		previous: make(map[string]struct{}),
+	}
+}
+
+// seen remembers the given submission ID, telling whether it was already seen.
+func (c *submissionCache) seen(id string) bool {
+	c.Lock()
+	defer c.Unlock()
+	c.rotate()
+	if _, ok := c.current[id]; ok {
+		return true
+	}
+	_, ok := c.previous[id]
+	c.current[id] = struct{}{}
+	return ok
+}
+
+// forget drops the given submission ID, so that it's recorded when seen again,
+// e.g. retried after failing to be recorded.
+func (c *submissionCache) forget(id string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.current, id)
+	delete(c.previous, id)
+}
+
+// rotate evicts the bucket older than the TTL. It must be called with the lock held.
+func (c *submissionCache) rotate() {
+	now := c.now()
+	elapsed := now.Sub(c.rotated)
+	if elapsed < c.ttl {
+		return
+	}
+	if elapsed < 2*c.ttl {
+		c.previous = c.current
+	} else {
+		c.previous = make(map[string]struct{})
+	}
+	c.current = make(map[string]struct{})
+	c.rotated = now
+}
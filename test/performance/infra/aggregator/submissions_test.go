@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestSubmissionCacheExpires(t *testing.T) {
+	const ttl = time.Minute
+	now := time.Unix(1000, 0)
+	c := newSubmissionCache(ttl)
+	c.now = func() time.Time { return now }
+	c.rotated = now
+
+	if c.seen("a") {
+		t.Error("seen(a) = true for a new submission")
+	}
+	now = now.Add(ttl / 2)
+	if !c.seen("a") {
+		t.Error("seen(a) = false for a retry within the TTL")
+	}
+	// a is rotated to the previous bucket, still remembered
+	now = now.Add(ttl)
+	if !c.seen("a") {
+		t.Error("seen(a) = false for a retry within twice the TTL of its last attempt")
+	}
+
+	// evicts everything
+	now = now.Add(2 * ttl)
+	if c.seen("a") {
+		t.Error("seen(a) = true for a retry arriving after twice the TTL")
+	}
+
+	// the memory is bounded by the submissions of the last two TTLs
+	for i := 0; i < 100; i++ {
+		now = now.Add(ttl / 10)
+		c.seen(fmt.Sprintf("s-%d", i))
+	}
+	if n := len(c.current) + len(c.previous); n > 20 {
+		t.Errorf("remembering %d submissions, want at most 20", n)
+	}
+}
+
+func TestDuplicateSubmissionIsRecordedOnce(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	rl := &pb.EventsRecordList{
+		Source:       "sender",
+		SubmissionId: "submission",
+		Items:        []*pb.EventsRecord{newRecord(pb.EventsRecord_SENT, time.Now(), "1", "2")},
+	}
+	recordEvents(t, ag, rl)
+
+	// the retry doesn't notify, as it doesn't count as a record
+	reply, err := ag.RecordEvents(context.Background(), rl)
+	if err != nil {
+		t.Fatal("RecordEvents() of the retry =", err)
+	}
+	if !reply.GetDuplicateSubmission() {
+		t.Error("RecordEvents() of the retry replied without duplicate_submission")
+	}
+	if counts := ag.sentEvents.duplicateCounts(); counts.attempts != 2 {
+		t.Errorf("recorded %d sent events, want 2", counts.attempts)
+	}
+}
+
+func TestInvalidSubmissionTTLIsRejected(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithSubmissionTTL(0)); err == nil {
+		t.Error("NewAggregator() with a zero submission TTL succeeded")
+	}
+}
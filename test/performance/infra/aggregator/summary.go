@@ -0,0 +1,237 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runSummaryJSON is the JSON form of the summary of a run, as posted to the
+// webhook and written to the summary file.
+type runSummaryJSON struct {
+	RunID    string `json:"runId"`
+	Scenario string `json:"scenario,omitempty"`
+	Passed   bool   `json:"passed"`
+
+	Sent                int     `json:"sent"`
+	Accepted            int     `json:"accepted"`
+	Received            int     `json:"received"`
+	PublishFailures     int     `json:"publishFailures"`
+	DeliveryFailures    int     `json:"deliveryFailures"`
+	PublishFailureRate  float64 `json:"publishFailureRate"`
+	DeliveryFailureRate float64 `json:"deliveryFailureRate"`
+	// received events per second
+	Throughput float64 `json:"throughput"`
+
+	LowSample bool `json:"lowSample"`
+	// whether expected events records were missing from the results, and how many
+	Partial        bool `json:"partial,omitempty"`
+	MissingRecords int  `json:"missingRecords,omitempty"`
+	// latency percentiles in milliseconds, keyed by "p" followed by the
+	// percentile, e.g. "p99"
+	PublishLatencyMs map[string]float64 `json:"publishLatencyMs,omitempty"`
+	E2ELatencyMs     map[string]float64 `json:"e2eLatencyMs,omitempty"`
+	// results of each sender shard, if broken down
+	Shards []shardSummaryJSON `json:"shards,omitempty"`
+	// end to end latencies by payload size, if the senders vary them
+	PayloadSizes []payloadSizeSummaryJSON `json:"payloadSizes,omitempty"`
+}
+
+// shardSummaryJSON is the JSON form of the results of a sender shard.
+type shardSummaryJSON struct {
+	Shard      string  `json:"shard"`
+	Sent       int     `json:"sent"`
+	Received   int     `json:"received"`
+	Throughput float64 `json:"throughput"`
+	// end to end latency percentiles in milliseconds
+	E2ELatencyP50Ms float64 `json:"e2eLatencyP50Ms"`
+	E2ELatencyP99Ms float64 `json:"e2eLatencyP99Ms"`
+}
+
+// payloadSizeSummaryJSON is the JSON form of the end to end latencies of the
+// events within a payload size range.
+type payloadSizeSummaryJSON struct {
+	MinSize  uint32 `json:"minSize"`
+	MaxSize  uint32 `json:"maxSize"`
+	Received int    `json:"received"`
+	// end to end latency percentiles in milliseconds
+	E2ELatencyP50Ms float64 `json:"e2eLatencyP50Ms"`
+	E2ELatencyP99Ms float64 `json:"e2eLatencyP99Ms"`
+}
+
+func newRunSummaryJSON(summary *RunSummary) *runSummaryJSON {
+	millisByPercentile := func(percentiles map[float64]time.Duration) map[string]float64 {
+		if len(percentiles) == 0 {
+			return nil
+		}
+		ms := make(map[string]float64, len(percentiles))
+		for p, v := range percentiles {
+			ms[fmt.Sprintf("p%v", p)] = millis(v)
+		}
+		return ms
+	}
+	var shards []shardSummaryJSON
+	for _, s := range summary.Shards {
+		shards = append(shards, shardSummaryJSON{
+			Shard:           s.Shard,
+			Sent:            s.Sent,
+			Received:        s.Received,
+			Throughput:      s.Throughput,
+			E2ELatencyP50Ms: millis(s.E2ELatencyP50),
+			E2ELatencyP99Ms: millis(s.E2ELatencyP99),
+		})
+	}
+	var payloadSizes []payloadSizeSummaryJSON
+	for _, s := range summary.PayloadSizes {
+		payloadSizes = append(payloadSizes, payloadSizeSummaryJSON{
+			MinSize:         s.MinSize,
+			MaxSize:         s.MaxSize,
+			Received:        s.Received,
+			E2ELatencyP50Ms: millis(s.E2ELatencyP50),
+			E2ELatencyP99Ms: millis(s.E2ELatencyP99),
+		})
+	}
+	return &runSummaryJSON{
+		RunID:               summary.RunID,
+		Scenario:            summary.Scenario,
+		Passed:              summary.Passed,
+		Sent:                summary.Sent,
+		Accepted:            summary.Accepted,
+		Received:            summary.Received,
+		PublishFailures:     summary.PublishFailures,
+		DeliveryFailures:    summary.DeliveryFailures,
+		PublishFailureRate:  summary.PublishFailureRate,
+		DeliveryFailureRate: summary.DeliveryFailureRate,
+		Throughput:          summary.Throughput,
+		LowSample:           summary.LowSample,
+		Partial:             summary.Partial,
+		MissingRecords:      summary.MissingRecords,
+		PublishLatencyMs:    millisByPercentile(summary.PublishLatencies),
+		E2ELatencyMs:        millisByPercentile(summary.E2ELatencies),
+		Shards:              shards,
+		PayloadSizes:        payloadSizes,
+	}
+}
+
+// runSummary converts the JSON form back to the summary of the run.
+func (j *runSummaryJSON) runSummary() (*RunSummary, error) {
+	durationByPercentile := func(ms map[string]float64) (map[float64]time.Duration, error) {
+		if len(ms) == 0 {
+			return nil, nil
+		}
+		percentiles := make(map[float64]time.Duration, len(ms))
+		for k, v := range ms {
+			p, err := strconv.ParseFloat(strings.TrimPrefix(k, "p"), 64)
+			if err != nil || !strings.HasPrefix(k, "p") {
+				return nil, fmt.Errorf("invalid percentile %q", k)
+			}
+			percentiles[p] = time.Duration(math.Round(v * float64(time.Millisecond)))
+		}
+		return percentiles, nil
+	}
+	publish, err := durationByPercentile(j.PublishLatencyMs)
+	if err != nil {
+		return nil, err
+	}
+	e2e, err := durationByPercentile(j.E2ELatencyMs)
+	if err != nil {
+		return nil, err
+	}
+	fromMillis := func(ms float64) time.Duration {
+		return time.Duration(math.Round(ms * float64(time.Millisecond)))
+	}
+	var shards []ShardSummary
+	for _, s := range j.Shards {
+		shards = append(shards, ShardSummary{
+			Shard:         s.Shard,
+			Sent:          s.Sent,
+			Received:      s.Received,
+			Throughput:    s.Throughput,
+			E2ELatencyP50: fromMillis(s.E2ELatencyP50Ms),
+			E2ELatencyP99: fromMillis(s.E2ELatencyP99Ms),
+		})
+	}
+	var payloadSizes []PayloadSizeSummary
+	for _, s := range j.PayloadSizes {
+		payloadSizes = append(payloadSizes, PayloadSizeSummary{
+			MinSize:       s.MinSize,
+			MaxSize:       s.MaxSize,
+			Received:      s.Received,
+			E2ELatencyP50: fromMillis(s.E2ELatencyP50Ms),
+			E2ELatencyP99: fromMillis(s.E2ELatencyP99Ms),
+		})
+	}
+	return &RunSummary{
+		RunID:               j.RunID,
+		Scenario:            j.Scenario,
+		Passed:              j.Passed,
+		Sent:                j.Sent,
+		Accepted:            j.Accepted,
+		Received:            j.Received,
+		PublishFailures:     j.PublishFailures,
+		DeliveryFailures:    j.DeliveryFailures,
+		PublishFailureRate:  j.PublishFailureRate,
+		DeliveryFailureRate: j.DeliveryFailureRate,
+		Throughput:          j.Throughput,
+		LowSample:           j.LowSample,
+		Partial:             j.Partial,
+		MissingRecords:      j.MissingRecords,
+		PublishLatencies:    publish,
+		E2ELatencies:        e2e,
+		Shards:              shards,
+		PayloadSizes:        payloadSizes,
+	}, nil
+}
+
+// exportRunSummary writes the summary of the run as JSON to the file at the given path.
+func exportRunSummary(path string, summary *RunSummary) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(newRunSummaryJSON(summary)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// loadRunSummary reads the summary of a run written by exportRunSummary.
+func loadRunSummary(path string) (*RunSummary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	var j runSummaryJSON
+	if err := json.NewDecoder(f).Decode(&j); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	summary, err := j.runSummary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTeardownTimeout is how long the teardown verification waits for the
+// aggregator goroutines to terminate.
+const defaultTeardownTimeout = 5 * time.Second
+
+// defaultGracefulStopTimeout is how long stopping the GRPC server waits for
+// the RPCs in flight before stopping it forcibly.
+const defaultGracefulStopTimeout = 30 * time.Second
+
+// stopServer stops the GRPC server gracefully, waiting for the RPCs in flight,
+// and forcibly once the graceful stop timeout expires, so that a wedged client
+// can't hang the shutdown.
+func (ag *Aggregator) stopServer() {
+	stopped := make(chan struct{})
+	ag.spawn(func() {
+		ag.server.GracefulStop()
+		close(stopped)
+	})
+	if ag.gracefulStopTimeout <= 0 {
+		<-stopped
+		log.Printf("Events recorder server stopped gracefully")
+		return
+	}
+	select {
+	case <-stopped:
+		log.Printf("Events recorder server stopped gracefully")
+	case <-time.After(ag.gracefulStopTimeout):
+		log.Printf("!! Events recorder server still handling RPCs after %v, forcing its stop", ag.gracefulStopTimeout)
+		ag.server.Stop()
+		<-stopped
+	}
+}
+
+// spawn runs f in a new goroutine, tracked by the teardown verification.
+func (ag *Aggregator) spawn(f func()) {
+	atomic.AddInt32(&ag.goroutines, 1)
+	go func() {
+		defer atomic.AddInt32(&ag.goroutines, -1)
+		f()
+	}()
+}
+
+// verifyTeardown checks that the aggregator released all its resources: the
+// GRPC server is stopped, the listener is closed and no goroutine spawned by
+// the aggregator is still running after the teardown timeout.
+func (ag *Aggregator) verifyTeardown() error {
+	deadline := time.Now().Add(ag.teardownTimeout)
+	for atomic.LoadInt32(&ag.goroutines) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&ag.goroutines); n > 0 {
+		return fmt.Errorf("%d aggregator goroutines still running after %v", n, ag.teardownTimeout)
+	}
+
+	// The server stopped serving once its goroutine returned, only the listener is left to check
+	if conn, err := net.DialTimeout("tcp", ag.listener.Addr().String(), time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("listener on %s is still accepting connections", ag.listener.Addr())
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// throughputSeriesHeader names the columns of the throughput series file.
+const throughputSeriesHeader = "timestamp,series_name,value"
+
+// throughputTimestamps are the timestamps of the events of a throughput series.
+type throughputTimestamps struct {
+	name       string
+	timestamps []time.Time
+}
+
+// throughputPoint is a value of a throughput series, at a time in milliseconds
+// since the Unix epoch as published to Mako.
+type throughputPoint struct {
+	xval   float64
+	series string
+	value  float64
+}
+
+// throughputSeriesStore collects the sample points published by publishThpt.
+type throughputSeriesStore struct {
+	points []throughputPoint
+}
+
+func (s *throughputSeriesStore) AddSamplePoint(xval float64, valueKeyToYVals map[string]float64) error {
+	keys := make([]string, 0, len(valueKeyToYVals))
+	for k := range valueKeyToYVals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s.points = append(s.points, throughputPoint{xval: xval, series: k, value: valueKeyToYVals[k]})
+	}
+	return nil
+}
+
+// computeThroughputSeries computes the throughputs of the given series as
+// published to Mako, one series after the other.
+func computeThroughputSeries(series []throughputTimestamps, smoothing throughputSmoothing) ([]throughputPoint, error) {
+	var store throughputSeriesStore
+	for _, s := range series {
+		if err := publishThpt(s.timestamps, &store, s.name, smoothing); err != nil {
+			return nil, err
+		}
+	}
+	return store.points, nil
+}
+
+// writeThroughputSeries writes the points in the tidy format, as comma-separated
+// columns with a header row: the time in seconds since the Unix epoch, the name
+// of the series and the throughput, so that all the series can be plotted in
+// one command, e.g. with pandas and seaborn:
+// sns.lineplot(data=pd.read_csv("data"), x="timestamp", y="value", hue="series_name")
+func writeThroughputSeries(w io.Writer, points []throughputPoint) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, throughputSeriesHeader)
+	for _, p := range points {
+		fmt.Fprintf(bw, "%.3f,%s,%g\n", p.xval/1000, p.series, p.value)
+	}
+	return bw.Flush()
+}
+
+// exportThroughputSeries writes the throughput series to the file at the given path.
+func exportThroughputSeries(path string, series []throughputTimestamps, smoothing throughputSmoothing) error {
+	points, err := computeThroughputSeries(series, smoothing)
+	if err != nil {
+		return fmt.Errorf("failed to compute the throughputs: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := writeThroughputSeries(f, points); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThroughputSeries(t *testing.T) {
+	at := func(ms ...int) []time.Time {
+		var ts []time.Time
+		for _, m := range ms {
+			ts = append(ts, time.Unix(1000, 0).Add(time.Duration(m)*time.Millisecond))
+		}
+		return ts
+	}
+	series := []throughputTimestamps{
+		// unsorted, as collected from the records
+		{"send", at(200, 0, 100, 1500)},
+		{"accept", at(0, 100)},
+		{"receive", at(300, 400, 500)},
+		{"publish-error", at(50)},
+		{"deliver-error", at(150, 1250)},
+	}
+	path := filepath.Join(t.TempDir(), "thpt.csv")
+	if err := exportThroughputSeries(path, series, throughputSmoothing{}); err != nil {
+		t.Fatal("Failed to export the throughput series:", err)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Failed to read the throughput series:", err)
+	}
+
+	want := []string{
+		"timestamp,series_name,value",
+		"1000.100,send,1",
+		"1000.200,send,2",
+		// the events more than a second before are out of the window
+		"1001.500,send,1",
+		"1000.100,accept,1",
+		"1000.400,receive,1",
+		"1000.500,receive,2",
+		"1000.050,publish-error,1",
+		"1001.250,deliver-error,1",
+	}
+	if got := strings.Split(strings.TrimSpace(string(raw)), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("throughput series =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
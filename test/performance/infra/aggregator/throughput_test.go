@@ -0,0 +1,173 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGoodputBelowSendThroughput(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	// 100 events sent per second for 3 seconds, every other one delivered
+	var sent, delivered []time.Time
+	for i := 0; i < 300; i++ {
+		ts := start.Add(time.Duration(i) * 10 * time.Millisecond)
+		sent = append(sent, ts)
+		if i%2 == 0 {
+			delivered = append(delivered, ts)
+		}
+	}
+
+	q := newFakeAggregatesStore()
+	if err := publishThpt(sent, q, "st", throughputSmoothing{}); err != nil {
+		t.Fatal("publishThpt(st) =", err)
+	}
+	if err := publishThpt(delivered, q, "gt", throughputSmoothing{}); err != nil {
+		t.Fatal("publishThpt(gt) =", err)
+	}
+
+	maxOf := func(values []float64) float64 {
+		var m float64
+		for _, v := range values {
+			if v > m {
+				m = v
+			}
+		}
+		return m
+	}
+	sendMax, goodputMax := maxOf(q.samples["st"]), maxOf(q.samples["gt"])
+	if goodputMax >= sendMax {
+		t.Errorf("max goodput = %v, want below the max send throughput %v", goodputMax, sendMax)
+	}
+	if want := sendMax / 2; goodputMax < want-1 || goodputMax > want+1 {
+		t.Errorf("max goodput = %v, want about half the max send throughput %v", goodputMax, sendMax)
+	}
+}
+
+func TestThroughputSmoothing(t *testing.T) {
+	start := time.Unix(1000, 0)
+
+	// bursts of 150 events every one and a half second, i.e. 100 events per second
+	var timestamps []time.Time
+	for burst := 0; burst < 40; burst++ {
+		for i := 0; i < 150; i++ {
+			timestamps = append(timestamps, start.Add(time.Duration(burst)*1500*time.Millisecond+time.Duration(i)*time.Millisecond))
+		}
+	}
+
+	spread := func(values []float64) (min, max float64) {
+		min, max = math.Inf(1), math.Inf(-1)
+		for _, v := range values {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		return min, max
+	}
+
+	for _, tc := range []struct {
+		smoothing throughputSmoothing
+		// tolerance around 100 events per second once past the lookback
+		tolerance float64
+	}{
+		{smoothing: throughputSmoothing{mode: throughputRolling, lookback: 15 * time.Second}, tolerance: 15},
+		{smoothing: throughputSmoothing{mode: throughputEWMA, lookback: 15 * time.Second}, tolerance: 15},
+	} {
+		t.Run(tc.smoothing.mode, func(t *testing.T) {
+			if err := tc.smoothing.validate(); err != nil {
+				t.Fatal("validate() =", err)
+			}
+			values := tc.smoothing.series(timestamps)
+			if len(values) != len(timestamps)-1 {
+				t.Fatalf("got %d values, want %d", len(values), len(timestamps)-1)
+			}
+			// past the first 45 seconds, once the exponentially weighted average converged
+			min, max := spread(values[4500:])
+			if min < 100-tc.tolerance || max > 100+tc.tolerance {
+				t.Errorf("throughput within [%v, %v], want 100±%v", min, max, tc.tolerance)
+			}
+		})
+	}
+
+	// the count of the events during the preceding second follows the bursts
+	min, max := spread(throughputSmoothing{}.series(timestamps)[4500:])
+	if max-min < 100 {
+		t.Errorf("windowed throughput within [%v, %v], want it to vary with the bursts", min, max)
+	}
+}
+
+func TestInvalidThroughputSmoothing(t *testing.T) {
+	for _, s := range []throughputSmoothing{
+		{mode: "median", lookback: time.Second},
+		{mode: throughputRolling},
+		{mode: throughputEWMA, lookback: -time.Second},
+	} {
+		if err := s.validate(); err == nil {
+			t.Errorf("validate() of %+v = nil, want an error", s)
+		}
+	}
+}
+
+func TestEWMAThroughputStep(t *testing.T) {
+	start := time.Unix(1000, 0)
+	step := start.Add(20 * time.Second)
+
+	// 10 events per second for 20 seconds, then 100 events per second for 10 seconds
+	var timestamps []time.Time
+	for ts := start; ts.Before(step); ts = ts.Add(100 * time.Millisecond) {
+		timestamps = append(timestamps, ts)
+	}
+	for ts := step; ts.Before(step.Add(10 * time.Second)); ts = ts.Add(10 * time.Millisecond) {
+		timestamps = append(timestamps, ts)
+	}
+
+	// time constant of 1s
+	s := ewmaSmoothing(1 / math.E)
+	if d := s.lookback - time.Second; d < -time.Millisecond || d > time.Millisecond {
+		t.Fatalf("time constant = %v, want 1s", s.lookback)
+	}
+	values := s.series(timestamps)
+	at := func(d time.Duration) float64 {
+		t := step.Add(d)
+		for i, ts := range timestamps[1:] {
+			if !ts.Before(t) {
+				return values[i]
+			}
+		}
+		return values[len(values)-1]
+	}
+
+	if v := at(-time.Millisecond); math.Abs(v-10) > 1 {
+		t.Errorf("throughput before the step = %v, want about 10", v)
+	}
+	// approaches the new rate exponentially: 10 + 90 * (1 - 1/e) after one time constant
+	if v, want := at(time.Second), 10+90*(1-1/math.E); math.Abs(v-want) > 3 {
+		t.Errorf("throughput one time constant after the step = %v, want about %v", v, want)
+	}
+	if v := at(5 * time.Second); math.Abs(v-100) > 2 {
+		t.Errorf("throughput five time constants after the step = %v, want about 100", v)
+	}
+}
+
+func TestInvalidEWMADecayIsRejected(t *testing.T) {
+	for _, decay := range []float64{-0.5, 1, 2} {
+		if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithEWMAThroughput(decay)); err == nil {
+			t.Errorf("NewAggregator() with a decay factor of %v succeeded", decay)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Types of the entries of the timeline, in the order of the entries at the same time.
+const (
+	timelineSent            = "sent"
+	timelineAccepted        = "accepted"
+	timelineReceived        = "received"
+	timelinePublishFailure  = outcomePublishFailure
+	timelineDeliveryFailure = outcomeDeliveryFailure
+)
+
+var timelineTypeRanks = map[string]int{
+	timelineSent:            0,
+	timelineAccepted:        1,
+	timelineReceived:        2,
+	timelinePublishFailure:  3,
+	timelineDeliveryFailure: 4,
+}
+
+// timelineEntry is the JSON object written for each step of each event, and
+// each of its failures, which are at the time the event was sent.
+type timelineEntry struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	ID       string    `json:"id"`
+	Scenario string    `json:"scenario,omitempty"`
+}
+
+// computeTimeline merges the steps and the failures of the events into a
+// single stream sorted by time, then by type and ID.
+func computeTimeline(events []event) []timelineEntry {
+	entries := make([]timelineEntry, 0, 3*len(events))
+	for i := range events {
+		e := &events[i]
+		entries = append(entries, timelineEntry{Time: e.sent, Type: timelineSent, ID: e.id})
+		if !e.isAccepted {
+			entries = append(entries, timelineEntry{Time: e.sent, Type: timelinePublishFailure, ID: e.id})
+			continue
+		}
+		entries = append(entries, timelineEntry{Time: e.accepted, Type: timelineAccepted, ID: e.id})
+		if !e.isReceived {
+			entries = append(entries, timelineEntry{Time: e.sent, Type: timelineDeliveryFailure, ID: e.id})
+			continue
+		}
+		entries = append(entries, timelineEntry{Time: e.received, Type: timelineReceived, ID: e.id})
+		for _, t := range e.redelivered {
+			entries = append(entries, timelineEntry{Time: t, Type: timelineReceived, ID: e.id})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := &entries[i], &entries[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Type != b.Type {
+			return timelineTypeRanks[a.Type] < timelineTypeRanks[b.Type]
+		}
+		return a.ID < b.ID
+	})
+	return entries
+}
+
+// writeTimeline writes the entries as one JSON object per line, labelled with
+// the scenario, if any.
+func writeTimeline(w io.Writer, scenario string, entries []timelineEntry) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i := range entries {
+		entries[i].Scenario = scenario
+		if err := enc.Encode(&entries[i]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// exportTimeline writes the timeline of the events to the file at the given path.
+func exportTimeline(path string, scenario string, events []event) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	if err := writeTimeline(f, scenario, computeTimeline(events)); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return f.Close()
+}
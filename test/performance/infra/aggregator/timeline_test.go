@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimelineIsSortedAndTagged(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+	events := []event{{
+		id: "delivered", sent: ms(0), accepted: ms(5), received: ms(30),
+		isAccepted: true, isReceived: true, redelivered: []time.Time{ms(50)},
+	}, {
+		id: "rejected", sent: ms(10),
+	}, {
+		id: "lost", sent: ms(2), accepted: ms(4), isAccepted: true,
+	}}
+
+	var buf bytes.Buffer
+	if err := writeTimeline(&buf, "1kb", computeTimeline(events)); err != nil {
+		t.Fatal("writeTimeline() =", err)
+	}
+
+	var got []timelineEntry
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e timelineEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("Failed to decode %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e)
+	}
+
+	want := []timelineEntry{
+		{Time: ms(0), Type: timelineSent, ID: "delivered"},
+		{Time: ms(2), Type: timelineSent, ID: "lost"},
+		{Time: ms(2), Type: timelineDeliveryFailure, ID: "lost"},
+		{Time: ms(4), Type: timelineAccepted, ID: "lost"},
+		{Time: ms(5), Type: timelineAccepted, ID: "delivered"},
+		{Time: ms(10), Type: timelineSent, ID: "rejected"},
+		{Time: ms(10), Type: timelinePublishFailure, ID: "rejected"},
+		{Time: ms(30), Type: timelineReceived, ID: "delivered"},
+		{Time: ms(50), Type: timelineReceived, ID: "delivered"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("timeline has %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Type != want[i].Type || got[i].ID != want[i].ID || got[i].Scenario != "1kb" {
+			t.Errorf("entry %d = %+v, want %+v in scenario 1kb", i, got[i], want[i])
+		}
+		if i > 0 && got[i].Time.Before(got[i-1].Time) {
+			t.Errorf("entry %d at %v is before the previous one at %v", i, got[i].Time, got[i-1].Time)
+		}
+	}
+}
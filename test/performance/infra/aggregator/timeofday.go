@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// defaultTimeOfDayPeriod is the period of the time of day buckets, unless configured.
+const defaultTimeOfDayPeriod = 24 * time.Hour
+
+// timeOfDayBucket holds the counts and latencies of the events sent during a
+// bucket of the period, over all its repetitions during the run.
+type timeOfDayBucket struct {
+	// offset of the start of the bucket from the start of the period
+	start time.Duration
+	// events sent during the bucket and how many of them were delivered
+	sent, delivered int
+	// sorted end to end latencies of the delivered events
+	e2eLatencies []time.Duration
+}
+
+// validateTimeOfDayBuckets returns an error if the period isn't divided in
+// buckets of the given duration.
+func validateTimeOfDayBuckets(bucket, period time.Duration) error {
+	if bucket < 0 {
+		return fmt.Errorf("invalid time of day bucket %v, must not be negative", bucket)
+	}
+	if bucket > 0 && (period <= 0 || period%bucket != 0) {
+		return fmt.Errorf("invalid time of day period %v, must be a multiple of the bucket %v", period, bucket)
+	}
+	return nil
+}
+
+// timeOfDayBuckets groups the events by the bucket of the period their sent
+// time falls in, the periods starting at the Unix epoch, e.g. at midnight UTC
+// for a period of a day. Only the buckets with sent events are returned.
+func timeOfDayBuckets(events []event, bucket, period time.Duration) []timeOfDayBucket {
+	byStart := make(map[time.Duration]*timeOfDayBucket)
+	for i := range events {
+		e := &events[i]
+		offset := time.Duration(e.sent.UnixNano() % int64(period))
+		if offset < 0 {
+			offset += period
+		}
+		start := offset - offset%bucket
+		b, ok := byStart[start]
+		if !ok {
+			b = &timeOfDayBucket{start: start}
+			byStart[start] = b
+		}
+		b.sent++
+		if e.isReceived {
+			b.delivered++
+			b.e2eLatencies = append(b.e2eLatencies, e.e2eLatency())
+		}
+	}
+
+	buckets := make([]timeOfDayBucket, 0, len(byStart))
+	for _, b := range byStart {
+		sortDurations(b.e2eLatencies)
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(x, y int) bool { return buckets[x].start < buckets[y].start })
+	return buckets
+}
+
+// formatTimeOfDay formats the offset from the start of the period as the time
+// of day in UTC, prefixed by the day within the period for periods longer than a day.
+func formatTimeOfDay(offset, period time.Duration) string {
+	clock := time.Unix(0, 0).UTC().Add(offset).Format("15:04")
+	if period <= 24*time.Hour {
+		return clock
+	}
+	return fmt.Sprintf("d%d %s", offset/(24*time.Hour), clock)
+}
+
+// formatTimeOfDayTable returns the lines of the table of the counts, loss rate
+// and end to end latency percentiles by time of day bucket.
+func formatTimeOfDayTable(buckets []timeOfDayBucket, period time.Duration) []string {
+	header := fmt.Sprintf("%-10s %8s %10s %8s", "bucket", "sent", "delivered", "loss")
+	for _, p := range reportedPercentiles {
+		header += fmt.Sprintf(" %12s", fmt.Sprintf("p%v", p))
+	}
+	lines := []string{header}
+	for _, b := range buckets {
+		line := fmt.Sprintf("%-10s %8d %10d %7.2f%%", formatTimeOfDay(b.start, period), b.sent, b.delivered,
+			float64(b.sent-b.delivered)/float64(b.sent)*100)
+		for _, p := range reportedPercentiles {
+			if len(b.e2eLatencies) == 0 {
+				line += fmt.Sprintf(" %12s", "-")
+			} else {
+				line += fmt.Sprintf(" %12v", percentile(b.e2eLatencies, p).Round(time.Microsecond))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// reportTimeOfDayTable logs the table of the loss rate and the latencies of
+// the events by the time of day they were sent, to reveal the time of day
+// effects of long soak tests that the aggregates of the whole run average out.
+func reportTimeOfDayTable(events []event, bucket, period time.Duration) {
+	log.Printf("Events by time of day (UTC), in buckets of %v over a period of %v:", bucket, period)
+	for _, line := range formatTimeOfDayTable(timeOfDayBuckets(events, bucket, period), period) {
+		log.Printf("  %s", line)
+	}
+}
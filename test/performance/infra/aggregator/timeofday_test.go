@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeOfDayBuckets(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	delivered := func(sent time.Time, latency time.Duration) event {
+		return event{sent: sent, received: sent.Add(latency), isAccepted: true, isReceived: true}
+	}
+	// two days of a soak test, in another time zone than UTC
+	events := []event{
+		delivered(at(1, 14, 10).In(time.FixedZone("UTC+2", 2*60*60)), 10*time.Millisecond),
+		delivered(at(2, 14, 50), 30*time.Millisecond),
+		{sent: at(1, 3, 0), isAccepted: true},
+		delivered(at(2, 3, 20), 5*time.Millisecond),
+	}
+
+	buckets := timeOfDayBuckets(events, time.Hour, 24*time.Hour)
+	if len(buckets) != 2 {
+		t.Fatalf("got %d buckets, want 2: %+v", len(buckets), buckets)
+	}
+	if b := buckets[0]; b.start != 3*time.Hour || b.sent != 2 || b.delivered != 1 {
+		t.Errorf("first bucket = %+v, want 2 events sent at 03:00 and 1 delivered", b)
+	}
+	if b := buckets[1]; b.start != 14*time.Hour || b.sent != 2 || b.delivered != 2 ||
+		percentile(b.e2eLatencies, 50) != 10*time.Millisecond || percentile(b.e2eLatencies, 99) != 30*time.Millisecond {
+		t.Errorf("second bucket = %+v, want 2 events sent at 14:00 delivered within 10 and 30ms", b)
+	}
+
+	lines := formatTimeOfDayTable(buckets, 24*time.Hour)
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "03:00") || !strings.Contains(lines[1], "50.00%") ||
+		!strings.HasPrefix(lines[2], "14:00") || !strings.HasSuffix(lines[2], "30ms") {
+		t.Errorf("time of day table =\n%s", strings.Join(lines, "\n"))
+	}
+
+	// the days of a two days period are told apart
+	buckets = timeOfDayBuckets(events, time.Hour, 48*time.Hour)
+	var starts []string
+	for _, b := range buckets {
+		starts = append(starts, formatTimeOfDay(b.start, 48*time.Hour))
+	}
+	if got, want := strings.Join(starts, ","), "d0 03:00,d0 14:00,d1 03:00,d1 14:00"; got != want {
+		t.Errorf("buckets of the two days period = %s, want %s", got, want)
+	}
+}
+
+func TestInvalidTimeOfDayBucketsAreRejected(t *testing.T) {
+	for _, c := range []struct{ bucket, period time.Duration }{
+		{-time.Hour, 24 * time.Hour},
+		{time.Hour, 0},
+		{7 * time.Hour, 24 * time.Hour},
+	} {
+		if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithTimeOfDayTable(c.bucket, c.period)); err == nil {
+			t.Errorf("NewAggregator() with a time of day bucket of %v over %v succeeded", c.bucket, c.period)
+		}
+	}
+}
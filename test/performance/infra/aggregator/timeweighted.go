@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// weightedLatency is an end to end latency and the weight of its event.
+type weightedLatency struct {
+	latency time.Duration
+	weight  float64
+}
+
+// localSendRates estimates the send rate at the time each event was sent, as
+// the send throughput published at that time.
+func localSendRates(events []event, smoothing throughputSmoothing) []float64 {
+	rates := make([]float64, len(events))
+	if len(events) < 2 {
+		for i := range rates {
+			rates[i] = 1
+		}
+		return rates
+	}
+	bySent := make([]int, len(events))
+	for i := range bySent {
+		bySent[i] = i
+	}
+	sort.SliceStable(bySent, func(x, y int) bool { return events[bySent[x]].sent.Before(events[bySent[y]].sent) })
+	timestamps := make([]time.Time, len(events))
+	for k, i := range bySent {
+		timestamps[k] = events[i].sent
+	}
+	// the throughput at the time of each timestamp but the first one, which
+	// takes the throughput of the second
+	series := smoothing.series(timestamps)
+	for k, i := range bySent {
+		if k == 0 {
+			rates[i] = series[0]
+		} else {
+			rates[i] = series[k-1]
+		}
+	}
+	return rates
+}
+
+// weightedPercentile returns the p-th percentile of the latencies sorted in
+// increasing order, each counting as its weight rather than as one sample,
+// given the sum of the weights.
+func weightedPercentile(sorted []weightedLatency, total float64, p float64) time.Duration {
+	// tolerance of the rounding errors of the cumulated weights
+	target := p/100*total - 1e-9*total
+	var cumulated float64
+	for _, l := range sorted {
+		cumulated += l.weight
+		if cumulated >= target {
+			return l.latency
+		}
+	}
+	return sorted[len(sorted)-1].latency
+}
+
+// reportTimeWeightedPercentiles logs the percentiles of the end to end
+// latencies of the delivered events, each weighted by the inverse of the send
+// rate when it was sent, so that each period counts as its duration rather
+// than as its number of events, and bursts don't dominate the percentiles.
+// They are keyed by "tw" followed by the percentile, e.g. "tw99".
+func reportTimeWeightedPercentiles(events []event, smoothing throughputSmoothing) map[string]time.Duration {
+	rates := localSendRates(events, smoothing)
+	var latencies []weightedLatency
+	var total float64
+	for i := range events {
+		if !events[i].isReceived {
+			continue
+		}
+		l := weightedLatency{latency: events[i].e2eLatency(), weight: 1 / rates[i]}
+		latencies = append(latencies, l)
+		total += l.weight
+	}
+	if len(latencies) == 0 {
+		return nil
+	}
+	sort.Slice(latencies, func(x, y int) bool { return latencies[x].latency < latencies[y].latency })
+
+	log.Printf("Time-weighted end to end latency over %d events:", len(latencies))
+	percentiles := make(map[string]time.Duration, len(reportedPercentiles))
+	for _, p := range reportedPercentiles {
+		v := weightedPercentile(latencies, total, p)
+		log.Printf("  p%v: %v", p, v)
+		percentiles[fmt.Sprintf("tw%v", p)] = v
+	}
+	return percentiles
+}
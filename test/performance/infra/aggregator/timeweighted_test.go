@@ -0,0 +1,58 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeWeightedPercentiles(t *testing.T) {
+	start := time.Unix(1000, 0)
+	delivered := func(id int, sent time.Time, latency time.Duration) event {
+		return event{id: fmt.Sprint(id), sent: sent, received: sent.Add(latency), isAccepted: true, isReceived: true}
+	}
+
+	// 30s of steady events, one per second, delivered within 10ms, and then a
+	// burst of 100 events within 100ms delivered within 500ms
+	var events []event
+	for i := 0; i < 30; i++ {
+		events = append(events, delivered(i, start.Add(time.Duration(i)*time.Second), 10*time.Millisecond))
+	}
+	for i := 0; i < 100; i++ {
+		events = append(events, delivered(30+i, start.Add(40*time.Second+time.Duration(i)*time.Millisecond), 500*time.Millisecond))
+	}
+
+	if got := percentile(e2eLatencies(events), 50); got != 500*time.Millisecond {
+		t.Fatalf("unweighted p50 = %v, want the latency of the burst", got)
+	}
+	weighted := reportTimeWeightedPercentiles(events, throughputSmoothing{})
+	if got, want := weighted["tw50"], 10*time.Millisecond; got != want {
+		t.Errorf("time-weighted p50 = %v, want the steady latency %v", got, want)
+	}
+	if got, want := weighted["tw99"], 500*time.Millisecond; got != want {
+		t.Errorf("time-weighted p99 = %v, want the burst latency %v", got, want)
+	}
+}
+
+func TestTimeWeightedPercentilesWithoutDeliveredEvents(t *testing.T) {
+	events := []event{{id: "lost", sent: time.Unix(1000, 0)}}
+	if weighted := reportTimeWeightedPercentiles(events, throughputSmoothing{}); weighted != nil {
+		t.Errorf("time-weighted percentiles without delivered events = %v, want none", weighted)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// testCert is a certificate and its key, signed by its parent or self-signed.
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate the key:", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal("Failed to create the certificate:", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal("Failed to parse the certificate:", err)
+	}
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal("Failed to marshal the key:", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	if err != nil {
+		t.Fatal("Failed to load the key pair:", err)
+	}
+	return cert
+}
+
+func writeTestFile(t *testing.T, path string, content []byte) string {
+	t.Helper()
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal("Failed to write", path, err)
+	}
+	return path
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCert(t, "test-ca", true, nil)
+	server := newTestCert(t, "aggregator", false, ca)
+	validClient := newTestCert(t, "sender", false, ca)
+	// signed by a CA the aggregator doesn't trust
+	invalidClient := newTestCert(t, "intruder", false, newTestCert(t, "other-ca", true, nil))
+
+	dir := t.TempDir()
+	ag := newTestAggregator(t, 0, WithTLS(
+		writeTestFile(t, filepath.Join(dir, "server.crt"), server.certPEM()),
+		writeTestFile(t, filepath.Join(dir, "server.key"), server.keyPEM(t)),
+		writeTestFile(t, filepath.Join(dir, "ca.crt"), ca.certPEM()),
+	))
+	go ag.server.Serve(ag.listener)
+	defer ag.server.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	record := func(clientCerts ...tls.Certificate) error {
+		creds := credentials.NewTLS(&tls.Config{RootCAs: roots, Certificates: clientCerts})
+		conn, err := grpc.Dial(ag.listener.Addr().String(), grpc.WithTransportCredentials(creds))
+		if err != nil {
+			t.Fatal("Failed to connect to the aggregator:", err)
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = pb.NewEventsRecorderClient(conn).RecordEvents(ctx, &pb.EventsRecordList{Source: "sender"})
+		return err
+	}
+
+	if err := record(validClient.tlsCertificate(t)); err != nil {
+		t.Error("RecordEvents() with a valid client certificate =", err)
+	}
+	if err := record(invalidClient.tlsCertificate(t)); err == nil {
+		t.Error("RecordEvents() with a client certificate signed by an untrusted CA succeeded")
+	}
+	if err := record(); err == nil {
+		t.Error("RecordEvents() without client certificate succeeded")
+	}
+}
+
+func TestMutualTLSAggregatorClient(t *testing.T) {
+	ca := newTestCert(t, "test-ca", true, nil)
+	server := newTestCert(t, "aggregator", false, ca)
+	client := newTestCert(t, "receiver", false, ca)
+
+	dir := t.TempDir()
+	caFile := writeTestFile(t, filepath.Join(dir, "ca.crt"), ca.certPEM())
+	ag := newTestAggregator(t, 1, WithTLS(
+		writeTestFile(t, filepath.Join(dir, "server.crt"), server.certPEM()),
+		writeTestFile(t, filepath.Join(dir, "server.key"), server.keyPEM(t)),
+		caFile,
+	))
+	done := runAggregator(context.Background(), ag)
+
+	config, err := pb.ClientTLSConfig(caFile,
+		writeTestFile(t, filepath.Join(dir, "client.crt"), client.certPEM()),
+		writeTestFile(t, filepath.Join(dir, "client.key"), client.keyPEM(t)))
+	if err != nil {
+		t.Fatal("ClientTLSConfig() =", err)
+	}
+	aggregatorClient, err := pb.NewTLSAggregatorClient(ag.listener.Addr().String(), config)
+	if err != nil {
+		t.Fatal("Failed to create the aggregator client:", err)
+	}
+	defer aggregatorClient.Close()
+	if err := aggregatorClient.Publish(&pb.EventsRecordList{Items: []*pb.EventsRecord{
+		newRecord(pb.EventsRecord_RECEIVED, time.Now(), "1"),
+	}}); err != nil {
+		t.Error("Publish() over mutual TLS =", err)
+	}
+	waitForRun(t, done)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	if config, err := pb.ClientTLSConfig("", "", ""); config != nil || err != nil {
+		t.Errorf("ClientTLSConfig() without files = %v, %v, want plaintext", config, err)
+	}
+	if _, err := pb.ClientTLSConfig("", "client.crt", ""); err == nil {
+		t.Error("ClientTLSConfig() with a certificate but no key succeeded")
+	}
+	if _, err := pb.ClientTLSConfig(filepath.Join(t.TempDir(), "missing.crt"), "", ""); err == nil {
+		t.Error("ClientTLSConfig() with a missing CA file succeeded")
+	}
+}
+
+func TestTLSRequiresCertificateAndKey(t *testing.T) {
+	if _, err := NewAggregator("127.0.0.1:0", 0, nil, false, WithTLS("", "", "ca.crt")); err == nil {
+		t.Error("NewAggregator() with a client CA but no certificate succeeded")
+	}
+}
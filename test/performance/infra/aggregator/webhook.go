@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// defaultWebhookTimeout bounds the call to the webhook, so that an
+// unresponsive endpoint doesn't hold the end of the run.
+const defaultWebhookTimeout = 10 * time.Second
+
+// webhookSink posts the summary of the run as JSON to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a result sink posting the summary of the run, along
+// with whether it passed, as a JSON object to the given URL, e.g. to notify
+// a chat or an incident management system.
+func NewWebhookSink(url string) ResultSink {
+	return &webhookSink{url: url, client: &http.Client{Timeout: defaultWebhookTimeout}}
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook at " + s.url
+}
+
+func (s *webhookSink) Publish(ctx context.Context, summary *RunSummary) error {
+	body, err := json.Marshal(newRunSummaryJSON(summary))
+	if err != nil {
+		return fmt.Errorf("failed to encode the payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %v", s.url, err)
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook at %s answered %s", s.url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestWebhookSink(t *testing.T) {
+	payloads := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got a %s request of %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error("Failed to decode the payload:", err)
+		}
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	// 100 events delivered with end to end latencies of 1 to 100ms, and 1 lost
+	sent := time.Unix(1000, 0)
+	var events []event
+	for i := 1; i <= 100; i++ {
+		events = append(events, event{
+			id: fmt.Sprint(i), sent: sent,
+			accepted: sent.Add(time.Millisecond), received: sent.Add(time.Duration(i) * time.Millisecond),
+			isAccepted: true, isReceived: true,
+		})
+	}
+	events = append(events, event{id: "lost", sent: sent, accepted: sent.Add(time.Millisecond), isAccepted: true})
+	aggregates := runAggregates{
+		deliverErrors:            1,
+		publishLatencies:         publishLatencies(events),
+		e2eLatencies:             e2eLatencies(events),
+		minSamplesForPercentiles: defaultMinSamplesForPercentiles,
+	}
+
+	sink := NewWebhookSink(server.URL)
+	if err := sink.Publish(context.Background(), newRunSummary("run", "1kb", events, &aggregates)); err != nil {
+		t.Fatal("Publish() =", err)
+	}
+	got := <-payloads
+	want := map[string]interface{}{
+		"runId":               "run",
+		"scenario":            "1kb",
+		"passed":              false,
+		"sent":                101.0,
+		"accepted":            101.0,
+		"received":            100.0,
+		"publishFailures":     0.0,
+		"deliveryFailures":    1.0,
+		"publishFailureRate":  0.0,
+		"deliveryFailureRate": 1.0 / 101,
+		"throughput":          1000.0,
+		"lowSample":           false,
+		"publishLatencyMs":    map[string]interface{}{"p50": 1.0, "p90": 1.0, "p99": 1.0},
+		"e2eLatencyMs":        map[string]interface{}{"p50": 50.0, "p90": 90.0, "p99": 99.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payload = %v, want %v", got, want)
+	}
+}
+
+func TestWebhookSinkFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	aggregates := runAggregates{minSamplesForPercentiles: defaultMinSamplesForPercentiles}
+	if err := NewWebhookSink(server.URL).Publish(context.Background(), newRunSummary("run", "", nil, &aggregates)); err == nil {
+		t.Error("Publish() = nil error on an internal server error, want it reported")
+	}
+}
+
+func TestWebhookOnlyCalledWhenConfigured(t *testing.T) {
+	called := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called <- struct{}{}
+	}))
+	defer server.Close()
+
+	for _, url := range []string{"", server.URL} {
+		ag := newTestAggregator(t, 1, WithWebhookSink(url))
+		done := runAggregator(context.Background(), ag)
+		now := time.Now()
+		publishRecords(t, ag, &pb.EventsRecordList{Items: []*pb.EventsRecord{
+			newRecord(pb.EventsRecord_SENT, now, "1"),
+		}})
+		waitForRun(t, done)
+
+		select {
+		case <-called:
+			if url == "" {
+				t.Error("webhook called without being configured")
+			}
+		default:
+			if url != "" {
+				t.Error("webhook not called once the run was done")
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+// markedWindow is a window of the run marked live, e.g. by the broker during
+// its maintenance.
+type markedWindow struct {
+	kind        string
+	start, stop time.Time
+}
+
+// contains tells whether t is within the window.
+func (w *markedWindow) contains(t time.Time) bool {
+	return !t.Before(w.start) && t.Before(w.stop)
+}
+
+// markedWindows are the windows marked during the run, by kind of the open ones.
+type markedWindows struct {
+	sync.Mutex
+	open   map[string]time.Time
+	closed []markedWindow
+}
+
+func newMarkedWindows() *markedWindows {
+	return &markedWindows{open: make(map[string]time.Time)}
+}
+
+// mark opens or closes the window of the given kind at the given time. Once
+// the edge is validated, the given journal function, if any, is called before
+// marking it, which fails if it fails.
+func (m *markedWindows) mark(kind string, edge pb.WindowRequest_Edge, at time.Time, journal func() error) error {
+	m.Lock()
+	defer m.Unlock()
+	start, open := m.open[kind]
+	switch edge {
+	case pb.WindowRequest_START:
+		if open {
+			return status.Errorf(codes.FailedPrecondition, "a %s window is already open since %v", kind, start)
+		}
+	case pb.WindowRequest_STOP:
+		if !open {
+			return status.Errorf(codes.FailedPrecondition, "no %s window is open", kind)
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown window edge %v", edge)
+	}
+	if journal != nil {
+		if err := journal(); err != nil {
+			return err
+		}
+	}
+	if edge == pb.WindowRequest_START {
+		m.open[kind] = at
+	} else {
+		delete(m.open, kind)
+		m.closed = append(m.closed, markedWindow{kind: kind, start: start, stop: at})
+	}
+	return nil
+}
+
+// add adds the given closed windows.
+func (m *markedWindows) add(windows ...markedWindow) {
+	m.Lock()
+	defer m.Unlock()
+	m.closed = append(m.closed, windows...)
+}
+
+// closeAll closes the windows still open at the given time, and returns all
+// the windows sorted by start.
+func (m *markedWindows) closeAll(at time.Time) []markedWindow {
+	m.Lock()
+	defer m.Unlock()
+	for kind, start := range m.open {
+		log.Printf("!! The %s window opened at %v was never stopped, closing it at the end of the run", kind, start)
+		m.closed = append(m.closed, markedWindow{kind: kind, start: start, stop: at})
+		delete(m.open, kind)
+	}
+	windows := append([]markedWindow(nil), m.closed...)
+	sort.Slice(windows, func(x, y int) bool { return windows[x].start.Before(windows[y].start) })
+	return windows
+}
+
+// splitByWindows separates the events sent outside of the windows from the
+// ones sent during each kind of window. An event sent during overlapping
+// windows counts in the first one.
+func splitByWindows(events []event, windows []markedWindow) (outside []event, during map[string][]event) {
+	if len(windows) == 0 {
+		return events, nil
+	}
+	outside = make([]event, 0, len(events))
+	during = make(map[string][]event)
+	for _, e := range events {
+		var kind string
+		for i := range windows {
+			if windows[i].contains(e.sent) {
+				kind = windows[i].kind
+				break
+			}
+		}
+		if kind == "" {
+			outside = append(outside, e)
+		} else {
+			during[kind] = append(during[kind], e)
+		}
+	}
+	return outside, during
+}
+
+// reportWindows logs the marked windows and the breakdown of the events sent
+// during each kind of window.
+func reportWindows(windows []markedWindow, during map[string][]event) {
+	log.Printf("%d windows marked during the run:", len(windows))
+	for _, w := range windows {
+		log.Printf("  %s: %v to %v (%v)", w.kind, w.start, w.stop, w.stop.Sub(w.start))
+	}
+	kinds := make([]string, 0, len(during))
+	for kind := range during {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		events := during[kind]
+		var delivered int
+		for i := range events {
+			if events[i].isReceived {
+				delivered++
+			}
+		}
+		log.Printf("Excluding the %d events sent during %s, %d of them delivered", len(events), kind, delivered)
+		reportDistribution("During "+kind+" end to end latency", "", e2eLatencies(events))
+	}
+}
+
+// MarkWindow implements event_state.EventsRecorder
+func (ag *Aggregator) MarkWindow(_ context.Context, in *pb.WindowRequest) (*pb.WindowReply, error) {
+	at := ag.now()
+	if in.At != nil {
+		t, err := ptypes.Timestamp(in.At)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid window time: %v", err)
+		}
+		at = t
+	}
+	if in.Kind == "" {
+		return nil, status.Error(codes.InvalidArgument, "the kind of the window is required")
+	}
+	// checkpointed with its time once validated, so that it's marked at the
+	// same time when replayed after a restart
+	marked := &pb.WindowRequest{Kind: in.Kind, Edge: in.Edge, Source: in.Source}
+	marked.At, _ = ptypes.TimestampProto(at)
+	journal := func() error { return ag.writeCheckpoint(at, marked) }
+	if err := ag.windows.mark(in.Kind, in.Edge, at, journal); err != nil {
+		log.Printf("!! Ignoring the %s of the %s window from %q: %v", in.Edge, in.Kind, in.Source, err)
+		return nil, err
+	}
+	log.Printf("Marked the %s of the %s window at %v, from %q", in.Edge, in.Kind, at, in.Source)
+	return &pb.WindowReply{}, nil
+}
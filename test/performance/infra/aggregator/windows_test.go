@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "knative.dev/eventing/test/performance/infra/event_state"
+)
+
+func TestMarkedWindowsExcludeEvents(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	start := time.Unix(1000, 0)
+
+	mark := func(edge pb.WindowRequest_Edge, at time.Time) error {
+		ts, _ := ptypes.TimestampProto(at)
+		_, err := ag.MarkWindow(context.Background(), &pb.WindowRequest{Kind: "maintenance", Edge: edge, At: ts, Source: "broker"})
+		return err
+	}
+	if err := mark(pb.WindowRequest_START, start.Add(time.Second)); err != nil {
+		t.Fatal("MarkWindow(START) =", err)
+	}
+	if err := mark(pb.WindowRequest_START, start.Add(time.Second)); err == nil {
+		t.Error("MarkWindow(START) of an open window succeeded")
+	}
+	if err := mark(pb.WindowRequest_STOP, start.Add(2*time.Second)); err != nil {
+		t.Fatal("MarkWindow(STOP) =", err)
+	}
+	if err := mark(pb.WindowRequest_STOP, start.Add(3*time.Second)); err == nil {
+		t.Error("MarkWindow(STOP) of a closed window succeeded")
+	}
+	// still open at the end of the run
+	if err := mark(pb.WindowRequest_START, start.Add(4*time.Second)); err != nil {
+		t.Fatal("MarkWindow(START) =", err)
+	}
+
+	windows := ag.windows.closeAll(start.Add(5 * time.Second))
+	if len(windows) != 2 {
+		t.Fatalf("got %d windows, want 2", len(windows))
+	}
+
+	events := []event{
+		{id: "before", sent: start},
+		{id: "during", sent: start.Add(1500 * time.Millisecond)},
+		{id: "between", sent: start.Add(2 * time.Second)},
+		{id: "during-unstopped", sent: start.Add(4500 * time.Millisecond)},
+		{id: "after", sent: start.Add(5 * time.Second)},
+	}
+	outside, during := splitByWindows(events, windows)
+	var outsideIDs, duringIDs []string
+	for _, e := range outside {
+		outsideIDs = append(outsideIDs, e.id)
+	}
+	for _, e := range during["maintenance"] {
+		duringIDs = append(duringIDs, e.id)
+	}
+	if got, want := fmt.Sprint(outsideIDs), "[before between after]"; got != want {
+		t.Errorf("events outside the windows = %v, want %v", got, want)
+	}
+	if got, want := fmt.Sprint(duringIDs), "[during during-unstopped]"; got != want {
+		t.Errorf("events during maintenance = %v, want %v", got, want)
+	}
+}
+
+func TestMarkWindowRequiresKind(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	if _, err := ag.MarkWindow(context.Background(), &pb.WindowRequest{Edge: pb.WindowRequest_START}); err == nil {
+		t.Error("MarkWindow() without kind succeeded")
+	}
+}
+
+func TestRejectedWindowMarkIsNotCheckpointed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aggregator.checkpoint")
+	ag := newTestAggregator(t, 0, WithCheckpoint(path))
+	ag.listener.Close()
+	if err := ag.restoreCheckpoint(); err != nil {
+		t.Fatal("restoreCheckpoint() of a new checkpoint =", err)
+	}
+
+	for _, tc := range []struct {
+		edge pb.WindowRequest_Edge
+		code codes.Code
+	}{
+		{pb.WindowRequest_STOP, codes.FailedPrecondition},
+		{pb.WindowRequest_Edge(42), codes.InvalidArgument},
+	} {
+		_, err := ag.MarkWindow(context.Background(), &pb.WindowRequest{Kind: "maintenance", Edge: tc.edge})
+		if status.Code(err) != tc.code {
+			t.Errorf("MarkWindow(%v) = %v, want a %v status", tc.edge, err, tc.code)
+		}
+	}
+	if _, err := ag.MarkWindow(context.Background(), &pb.WindowRequest{Kind: "maintenance", Edge: pb.WindowRequest_START}); err != nil {
+		t.Fatal("MarkWindow(START) =", err)
+	}
+
+	_, entries, err := openCheckpoint(path)
+	if err != nil {
+		t.Fatal("openCheckpoint() =", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("checkpointed %d window marks, want only the accepted one", len(entries))
+	}
+}
+
+func TestWindowsUseTheAggregatorClock(t *testing.T) {
+	ag := newTestAggregator(t, 0)
+	clock := time.Unix(1000, 0)
+	ag.now = func() time.Time { return clock }
+
+	if _, err := ag.MarkWindow(context.Background(), &pb.WindowRequest{Kind: "maintenance", Edge: pb.WindowRequest_START}); err != nil {
+		t.Fatal("MarkWindow(START) =", err)
+	}
+	clock = clock.Add(time.Second)
+	windows := ag.windows.closeAll(ag.now())
+	if len(windows) != 1 || !windows[0].start.Equal(time.Unix(1000, 0)) || !windows[0].stop.Equal(time.Unix(1001, 0)) {
+		t.Errorf("windows = %v, want the maintenance window from 1000s to 1001s", windows)
+	}
+}
+
+func TestSplitByWindowsWithoutWindows(t *testing.T) {
+	events := []event{{id: "1"}}
+	if outside, during := splitByWindows(events, nil); len(outside) != 1 || during != nil {
+		t.Errorf("splitByWindows() = %v, %v, want all the events outside", outside, during)
+	}
+}
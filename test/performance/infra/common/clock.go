@@ -0,0 +1,42 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// processStart is the origin of the monotonic clock readings of this process.
+var processStart = time.Now()
+
+// MonotonicClock identifies the monotonic clock of this process: its readings
+// can only be compared with readings of the same clock.
+var MonotonicClock = monotonicClockID()
+
+func monotonicClockID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d/%d", host, os.Getpid(), processStart.UnixNano())
+}
+
+// MonotonicNow returns the current reading of the monotonic clock of this
+// process, in nanoseconds since its start. Unlike the wall clock, it isn't
+// affected by clock adjustments.
+func MonotonicNow() int64 {
+	return int64(time.Since(processStart))
+}
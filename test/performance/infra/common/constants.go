@@ -28,3 +28,17 @@ const (
 	WaitForReceiverGC = 3 * time.Second
 	WaitAfterWarmup   = 5 * time.Second
 )
+
+// Kinds of the windows at the beginning and the end of the benchmark, whose
+// events the aggregator excludes from the results.
+const (
+	WarmupWindowKind   = "warmup"
+	CooldownWindowKind = "cooldown"
+)
+
+// CloudEvents extensions of the partition key and of the sequence number of
+// the events, when their sender numbers them monotonically within partitions.
+const (
+	PartitionKeyExtension = "partitionkey"
+	SequenceExtension     = "sequence"
+)
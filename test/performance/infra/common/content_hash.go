@@ -0,0 +1,40 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+// ChecksumExtension is the CloudEvents extension carrying the checksum of the
+// payload of an event, computed by its sender and verified by its receiver.
+const ChecksumExtension = "checksum"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ContentHash returns the hash of the content of an event, computed the same
+// way by senders and receivers to verify the integrity of its delivery.
+func ContentHash(data []byte) uint32 {
+	return crc32.Checksum(data, castagnoli)
+}
+
+// FormatChecksum formats the hash of the content of an event as the value of
+// its ChecksumExtension.
+func FormatChecksum(hash uint32) string {
+	return fmt.Sprintf("%08x", hash)
+}
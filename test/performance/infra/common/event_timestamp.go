@@ -22,4 +22,20 @@ import "github.com/golang/protobuf/ptypes/timestamp"
 type EventTimestamp struct {
 	EventId string
 	At      *timestamp.Timestamp
+	// reading of the MonotonicClock paired with At
+	Monotonic int64
+	// ContentHash of the content of the event, if HasContentHash
+	ContentHash    uint32
+	HasContentHash bool
+	// whether the content of the received event didn't match the checksum computed by its sender
+	ChecksumMismatch bool
+	// time the sent event actually went on the wire, reported separately from
+	// the time it was sent At, if set
+	OnWire *timestamp.Timestamp
+	// partition key and sequence number of the sent event within it, if HasSequence
+	Partition   string
+	Sequence    uint64
+	HasSequence bool
+	// size in bytes of the payload of the sent event, 0 if unknown
+	PayloadSize uint32
 }
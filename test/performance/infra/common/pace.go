@@ -28,6 +28,8 @@ const defaultPaceDuration = 10 * time.Second
 type PaceSpec struct {
 	Rps      int
 	Duration time.Duration
+	// Profile shapes the rate of the pace, if set, Rps being its peak rate
+	Profile *LoadProfile
 }
 
 // We need those estimates to allocate memory before benchmark starts
@@ -67,7 +69,7 @@ func ParsePaceSpec(pace string) ([]PaceSpec, error) {
 			duration = time.Second * time.Duration(durationSec)
 		}
 
-		pacerSpecs = append(pacerSpecs, PaceSpec{rps, duration})
+		pacerSpecs = append(pacerSpecs, PaceSpec{Rps: rps, Duration: duration})
 	}
 
 	return pacerSpecs, nil
@@ -0,0 +1,158 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Distributions of the payload sizes.
+const (
+	// a single size
+	FixedPayload = "fixed"
+	// uniformly distributed within a range
+	UniformPayload = "uniform"
+	// lognormally distributed around a median, up to a maximum
+	LognormalPayload = "lognormal"
+)
+
+// MinPayloadSize is the smallest payload, a quoted empty string.
+const MinPayloadSize = 2
+
+// PayloadSize is the distribution of the sizes in bytes of the payloads of
+// the events.
+type PayloadSize struct {
+	Distribution string
+
+	// fixed: size of the payloads
+	Size uint
+	// uniform: range of the sizes
+	// lognormal: maximum size, bounding the long tail of the distribution
+	Min, Max uint
+	// lognormal: median size and standard deviation of the logarithm of the
+	// sizes
+	Median uint
+	Sigma  float64
+}
+
+// FixedPayloadSize returns the distribution of payloads all of the given size.
+func FixedPayloadSize(size uint) PayloadSize {
+	return PayloadSize{Distribution: FixedPayload, Size: size}
+}
+
+// ParsePayloadSize parses a distribution of the payload sizes, made of its
+// name followed by its parameters, separated by colons:
+//
+//	fixed:<size>
+//	uniform:<min>:<max>
+//	lognormal:<median>:<sigma>:<max>
+//
+// with the sizes in bytes, e.g. lognormal:1024:0.8:65536.
+func ParsePayloadSize(spec string) (PayloadSize, error) {
+	parts := strings.Split(spec, ":")
+	size := func(i int) (uint, error) {
+		v, err := strconv.ParseUint(parts[i], 10, 32)
+		if err != nil || v < MinPayloadSize {
+			return 0, fmt.Errorf("invalid payload size %q: invalid size %q, must be an integer of at least %d", spec, parts[i], MinPayloadSize)
+		}
+		return uint(v), nil
+	}
+	want := map[string]int{FixedPayload: 2, UniformPayload: 3, LognormalPayload: 4}
+	n, ok := want[parts[0]]
+	if !ok {
+		return PayloadSize{}, fmt.Errorf("invalid payload size %q: unknown distribution %q, must be one of %s, %s or %s",
+			spec, parts[0], FixedPayload, UniformPayload, LognormalPayload)
+	}
+	if len(parts) != n {
+		return PayloadSize{}, fmt.Errorf("invalid payload size %q: want %d parameters for the %s distribution", spec, n-1, parts[0])
+	}
+
+	p := PayloadSize{Distribution: parts[0]}
+	var err error
+	switch p.Distribution {
+	case FixedPayload:
+		p.Size, err = size(1)
+	case UniformPayload:
+		if p.Min, err = size(1); err == nil {
+			p.Max, err = size(2)
+		}
+		if err == nil && p.Min > p.Max {
+			err = fmt.Errorf("invalid payload size %q: the minimum size exceeds the maximum one", spec)
+		}
+	case LognormalPayload:
+		if p.Median, err = size(1); err == nil {
+			p.Max, err = size(3)
+		}
+		if err != nil {
+			break
+		}
+		if p.Sigma, err = strconv.ParseFloat(parts[2], 64); err != nil || p.Sigma < 0 || math.IsInf(p.Sigma, 0) {
+			err = fmt.Errorf("invalid payload size %q: invalid sigma %q, must be a non-negative number", spec, parts[2])
+		} else if p.Median > p.Max {
+			err = fmt.Errorf("invalid payload size %q: the median size exceeds the maximum one", spec)
+		}
+		p.Min = MinPayloadSize
+	}
+	if err != nil {
+		return PayloadSize{}, err
+	}
+	return p, nil
+}
+
+// Varies tells whether the payloads may have different sizes.
+func (p PayloadSize) Varies() bool {
+	switch p.Distribution {
+	case UniformPayload:
+		return p.Min != p.Max
+	case LognormalPayload:
+		return p.Sigma > 0 && p.Min < p.Max
+	}
+	return false
+}
+
+// Sample returns the size of a payload drawn from the distribution. It's safe
+// for concurrent use.
+func (p PayloadSize) Sample() uint {
+	switch p.Distribution {
+	case UniformPayload:
+		return p.Min + uint(rand.Int63n(int64(p.Max-p.Min)+1))
+	case LognormalPayload:
+		size := math.Round(float64(p.Median) * math.Exp(p.Sigma*rand.NormFloat64()))
+		if size < float64(p.Min) {
+			return p.Min
+		}
+		if size > float64(p.Max) {
+			return p.Max
+		}
+		return uint(size)
+	}
+	return p.Size
+}
+
+func (p PayloadSize) String() string {
+	switch p.Distribution {
+	case UniformPayload:
+		return fmt.Sprintf("%s:%d:%d", p.Distribution, p.Min, p.Max)
+	case LognormalPayload:
+		return fmt.Sprintf("%s:%d:%g:%d", p.Distribution, p.Median, p.Sigma, p.Max)
+	}
+	return fmt.Sprintf("%s:%d", p.Distribution, p.Size)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParsePayloadSize(t *testing.T) {
+	for _, tc := range []struct {
+		spec   string
+		want   PayloadSize
+		varies bool
+	}{
+		{"fixed:100", PayloadSize{Distribution: FixedPayload, Size: 100}, false},
+		{"uniform:10:1000", PayloadSize{Distribution: UniformPayload, Min: 10, Max: 1000}, true},
+		{"uniform:10:10", PayloadSize{Distribution: UniformPayload, Min: 10, Max: 10}, false},
+		{"lognormal:1024:0.8:65536", PayloadSize{Distribution: LognormalPayload, Min: MinPayloadSize, Median: 1024, Sigma: 0.8, Max: 65536}, true},
+		{"lognormal:1024:0:65536", PayloadSize{Distribution: LognormalPayload, Min: MinPayloadSize, Median: 1024, Max: 65536}, false},
+	} {
+		got, err := ParsePayloadSize(tc.spec)
+		if err != nil {
+			t.Errorf("ParsePayloadSize(%q) = %v", tc.spec, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParsePayloadSize(%q) = %+v, want %+v", tc.spec, got, tc.want)
+		}
+		if got.Varies() != tc.varies {
+			t.Errorf("%q varies = %v, want %v", tc.spec, got.Varies(), tc.varies)
+		}
+		if got.String() != tc.spec {
+			t.Errorf("%q formatted as %q", tc.spec, got.String())
+		}
+	}
+}
+
+func TestParseInvalidPayloadSize(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"normal:100",
+		"fixed",
+		"fixed:1",
+		"fixed:-5",
+		"uniform:100",
+		"uniform:1000:10",
+		"lognormal:1024:0.8",
+		"lognormal:1024:-1:65536",
+		"lognormal:1024:sigma:65536",
+		"lognormal:100000:0.8:65536",
+	} {
+		if _, err := ParsePayloadSize(spec); err == nil {
+			t.Errorf("ParsePayloadSize(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestSamplePayloadSize(t *testing.T) {
+	if got := FixedPayloadSize(100).Sample(); got != 100 {
+		t.Errorf("fixed sample = %d, want 100", got)
+	}
+
+	uniform := PayloadSize{Distribution: UniformPayload, Min: 10, Max: 12}
+	seen := make(map[uint]bool)
+	for i := 0; i < 1000; i++ {
+		size := uniform.Sample()
+		if size < 10 || size > 12 {
+			t.Fatalf("uniform sample = %d, want between 10 and 12", size)
+		}
+		seen[size] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("uniform samples = %v, want all the sizes of the range", seen)
+	}
+
+	lognormal := PayloadSize{Distribution: LognormalPayload, Min: MinPayloadSize, Median: 1000, Sigma: 1, Max: 4000}
+	sizes := make([]int, 10001)
+	for i := range sizes {
+		size := lognormal.Sample()
+		if size < MinPayloadSize || size > 4000 {
+			t.Fatalf("lognormal sample = %d, want between %d and 4000", size, MinPayloadSize)
+		}
+		sizes[i] = int(size)
+	}
+	sort.Ints(sizes)
+	if median := sizes[len(sizes)/2]; median < 900 || median > 1100 {
+		t.Errorf("lognormal median = %d, want about 1000", median)
+	}
+	if sizes[len(sizes)-1] != 4000 {
+		t.Errorf("largest lognormal sample = %d, want the tail bounded at 4000", sizes[len(sizes)-1])
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shapes of the load profiles.
+const (
+	// from a rate to another, linearly
+	RampProfile = "ramp"
+	// from a rate, increased by a step at regular intervals
+	StepProfile = "step"
+	// oscillating around a mean rate
+	SineProfile = "sine"
+	// at a base rate, with bursts at a peak rate at regular intervals
+	BurstProfile = "burst"
+)
+
+// LoadProfile is a load whose rate changes over its duration, following a
+// shape. Rates are in events per second, the other parameters in seconds.
+type LoadProfile struct {
+	Shape    string
+	Duration time.Duration
+
+	// ramp: rate at the beginning and at the end
+	// step: rate at the beginning, increased by Step every Every
+	From, To int
+	Step     int
+	// step and burst: interval of the steps and of the bursts
+	Every time.Duration
+
+	// sine: mean rate, amplitude of the oscillations and their period
+	Mean, Amplitude int
+	Period          time.Duration
+
+	// burst: rate outside and during the bursts, lasting Length
+	Base, Peak int
+	Length     time.Duration
+}
+
+// profileParams are the names of the parameters of each shape, in order.
+var profileParams = map[string][]string{
+	RampProfile:  {"from", "to", "duration"},
+	StepProfile:  {"from", "step", "every", "duration"},
+	SineProfile:  {"mean", "amplitude", "period", "duration"},
+	BurstProfile: {"base", "peak", "every", "length", "duration"},
+}
+
+// ParseLoadProfile parses a load profile, made of its shape followed by its
+// parameters, separated by colons:
+//
+//	ramp:<from rps>:<to rps>:<duration>
+//	step:<from rps>:<step rps>:<every>:<duration>
+//	sine:<mean rps>:<amplitude rps>:<period>:<duration>
+//	burst:<base rps>:<peak rps>:<every>:<length>:<duration>
+//
+// with the durations in seconds, e.g. ramp:100:1000:60.
+func ParseLoadProfile(profile string) (*LoadProfile, error) {
+	parts := strings.Split(profile, ":")
+	names, ok := profileParams[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("invalid load profile %q: unknown shape %q, must be one of %s, %s, %s or %s",
+			profile, parts[0], RampProfile, StepProfile, SineProfile, BurstProfile)
+	}
+	if len(parts)-1 != len(names) {
+		return nil, fmt.Errorf("invalid load profile %q: want %s:%s", profile, parts[0], strings.Join(names, ":"))
+	}
+	values := make(map[string]int, len(names))
+	for i, name := range names {
+		v, err := strconv.Atoi(parts[i+1])
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid load profile %q: invalid %s %q, must be a non-negative integer", profile, name, parts[i+1])
+		}
+		values[name] = v
+	}
+	seconds := func(name string) time.Duration {
+		return time.Duration(values[name]) * time.Second
+	}
+
+	p := &LoadProfile{Shape: parts[0], Duration: seconds("duration")}
+	switch p.Shape {
+	case RampProfile:
+		p.From, p.To = values["from"], values["to"]
+	case StepProfile:
+		p.From, p.Step, p.Every = values["from"], values["step"], seconds("every")
+	case SineProfile:
+		p.Mean, p.Amplitude, p.Period = values["mean"], values["amplitude"], seconds("period")
+	case BurstProfile:
+		p.Base, p.Peak, p.Every, p.Length = values["base"], values["peak"], seconds("every"), seconds("length")
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid load profile %q: %v", profile, err)
+	}
+	return p, nil
+}
+
+// validate checks the consistency of the parameters of the profile.
+func (p *LoadProfile) validate() error {
+	if p.Duration <= 0 {
+		return errors.New("the duration must be positive")
+	}
+	switch p.Shape {
+	case StepProfile:
+		if p.Every <= 0 {
+			return errors.New("the interval of the steps must be positive")
+		}
+	case SineProfile:
+		if p.Period <= 0 {
+			return errors.New("the period must be positive")
+		}
+		if p.Amplitude > p.Mean {
+			return errors.New("the amplitude can't exceed the mean rate")
+		}
+	case BurstProfile:
+		if p.Every <= 0 || p.Length > p.Every {
+			return errors.New("the interval of the bursts must be positive and not shorter than the bursts")
+		}
+	}
+	return nil
+}
+
+// Rate is the rate of the profile, in events per second, after the given
+// time since its beginning.
+func (p *LoadProfile) Rate(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	switch p.Shape {
+	case RampProfile:
+		return float64(p.From) + float64(p.To-p.From)*t/p.Duration.Seconds()
+	case StepProfile:
+		return float64(p.From) + float64(p.Step)*math.Floor(t/p.Every.Seconds())
+	case SineProfile:
+		return float64(p.Mean) + float64(p.Amplitude)*math.Sin(2*math.Pi*t/p.Period.Seconds())
+	case BurstProfile:
+		if math.Mod(t, p.Every.Seconds()) < p.Length.Seconds() {
+			return float64(p.Peak)
+		}
+		return float64(p.Base)
+	}
+	return 0
+}
+
+// Hits is the number of events the profile sends in the given time since its
+// beginning, the integral of its rate.
+func (p *LoadProfile) Hits(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	switch p.Shape {
+	case RampProfile:
+		return float64(p.From)*t + float64(p.To-p.From)*t*t/(2*p.Duration.Seconds())
+	case StepProfile:
+		every := p.Every.Seconds()
+		steps := math.Floor(t / every)
+		// the steps completed, then the current one
+		return float64(p.From)*t + float64(p.Step)*(every*steps*(steps-1)/2+steps*(t-steps*every))
+	case SineProfile:
+		period := p.Period.Seconds()
+		return float64(p.Mean)*t + float64(p.Amplitude)*period/(2*math.Pi)*(1-math.Cos(2*math.Pi*t/period))
+	case BurstProfile:
+		every, length := p.Every.Seconds(), p.Length.Seconds()
+		cycles := math.Floor(t / every)
+		hits := cycles * (float64(p.Peak)*length + float64(p.Base)*(every-length))
+		if within := t - cycles*every; within < length {
+			hits += float64(p.Peak) * within
+		} else {
+			hits += float64(p.Peak)*length + float64(p.Base)*(within-length)
+		}
+		return hits
+	}
+	return 0
+}
+
+// PeakRate is the highest rate of the profile, in events per second.
+func (p *LoadProfile) PeakRate() int {
+	switch p.Shape {
+	case RampProfile:
+		if p.To > p.From {
+			return p.To
+		}
+		return p.From
+	case StepProfile:
+		steps := int((p.Duration - 1) / p.Every)
+		if p.Step > 0 {
+			return p.From + p.Step*steps
+		}
+		return p.From
+	case SineProfile:
+		return p.Mean + p.Amplitude
+	case BurstProfile:
+		if p.Length > 0 && p.Peak > p.Base {
+			return p.Peak
+		}
+		return p.Base
+	}
+	return 0
+}
+
+// PaceSpecs returns the single pace following the profile, whose rate is the
+// peak rate of the profile for the memory estimates.
+func (p *LoadProfile) PaceSpecs() []PaceSpec {
+	return []PaceSpec{{Rps: p.PeakRate(), Duration: p.Duration, Profile: p}}
+}
+
+// ParsePaces parses the paces of the benchmark, either a pace array or a load
+// profile, which are exclusive.
+func ParsePaces(pace string, profile string) ([]PaceSpec, error) {
+	if profile == "" {
+		return ParsePaceSpec(pace)
+	}
+	if pace != "" {
+		return nil, errors.New("the pace array and the load profile are exclusive")
+	}
+	p, err := ParseLoadProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	return p.PaceSpecs(), nil
+}
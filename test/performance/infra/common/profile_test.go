@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	for _, tc := range []struct {
+		profile string
+		want    LoadProfile
+		peak    int
+	}{
+		{"ramp:100:1000:60", LoadProfile{Shape: RampProfile, From: 100, To: 1000, Duration: time.Minute}, 1000},
+		{"ramp:500:0:10", LoadProfile{Shape: RampProfile, From: 500, Duration: 10 * time.Second}, 500},
+		{"step:100:50:10:60", LoadProfile{Shape: StepProfile, From: 100, Step: 50, Every: 10 * time.Second, Duration: time.Minute}, 350},
+		{"sine:500:300:30:120", LoadProfile{Shape: SineProfile, Mean: 500, Amplitude: 300, Period: 30 * time.Second, Duration: 2 * time.Minute}, 800},
+		{"burst:100:1000:20:2:60", LoadProfile{Shape: BurstProfile, Base: 100, Peak: 1000, Every: 20 * time.Second, Length: 2 * time.Second, Duration: time.Minute}, 1000},
+	} {
+		p, err := ParseLoadProfile(tc.profile)
+		if err != nil {
+			t.Errorf("ParseLoadProfile(%q) = %v", tc.profile, err)
+			continue
+		}
+		if !reflect.DeepEqual(*p, tc.want) {
+			t.Errorf("ParseLoadProfile(%q) = %+v, want %+v", tc.profile, *p, tc.want)
+		}
+		if got := p.PeakRate(); got != tc.peak {
+			t.Errorf("%q peak rate = %d, want %d", tc.profile, got, tc.peak)
+		}
+	}
+}
+
+func TestParseInvalidLoadProfile(t *testing.T) {
+	for _, profile := range []string{
+		"",
+		"square:1:2:3",
+		"ramp:100:1000",
+		"ramp:100:-1:60",
+		"ramp:100:1000:0",
+		"step:100:50:0:60",
+		"sine:100:200:30:60",
+		"sine:500:300:0:60",
+		"burst:100:1000:2:5:60",
+	} {
+		if _, err := ParseLoadProfile(profile); err == nil {
+			t.Errorf("ParseLoadProfile(%q) succeeded", profile)
+		}
+	}
+}
+
+func TestLoadProfileHitsIntegrateTheRate(t *testing.T) {
+	for _, profile := range []string{"ramp:100:1000:60", "step:100:50:10:60", "sine:500:300:30:60", "burst:100:1000:20:2:60"} {
+		p, err := ParseLoadProfile(profile)
+		if err != nil {
+			t.Fatalf("ParseLoadProfile(%q) = %v", profile, err)
+		}
+		const step = time.Millisecond
+		var integral float64
+		for elapsed := time.Duration(0); elapsed < p.Duration; elapsed += step {
+			integral += p.Rate(elapsed+step/2) * step.Seconds()
+			if at := elapsed + step; at%(5*time.Second) == 0 {
+				if got := p.Hits(at); math.Abs(got-integral) > 1 {
+					t.Errorf("%q: Hits(%v) = %v, want the integral of the rate %v", profile, at, got, integral)
+				}
+			}
+		}
+	}
+}
+
+func TestParsePaces(t *testing.T) {
+	paces, err := ParsePaces("", "ramp:100:1000:60")
+	if err != nil {
+		t.Fatal("ParsePaces() =", err)
+	}
+	if len(paces) != 1 || paces[0].Rps != 1000 || paces[0].Duration != time.Minute || paces[0].Profile == nil {
+		t.Errorf("ParsePaces() = %+v, want a single pace following the profile", paces)
+	}
+	if paces, err := ParsePaces("100:10,200:5", ""); err != nil || len(paces) != 2 {
+		t.Errorf("ParsePaces() without profile = %+v, %v, want the 2 paces", paces, err)
+	}
+	if _, err := ParsePaces("100:10", "ramp:100:1000:60"); err == nil {
+		t.Error("ParsePaces() with both a pace array and a profile succeeded")
+	}
+}
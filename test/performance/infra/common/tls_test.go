@@ -0,0 +1,48 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClientTLSConfigNamesThePeer(t *testing.T) {
+	_, err := ClientTLSConfig("sink", filepath.Join(t.TempDir(), "missing.crt"), "", "")
+	if err == nil || !strings.Contains(err.Error(), "sink CA") {
+		t.Errorf("ClientTLSConfig() with a missing CA file = %v, want an error about the sink CA", err)
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "ca.crt")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal("Failed to write the CA file:", err)
+	}
+	if _, err := ClientTLSConfig("sink", notPEM, "", ""); err == nil {
+		t.Error("ClientTLSConfig() without PEM certificates succeeded")
+	}
+}
+
+func TestServerTLSConfigRequiresCertificate(t *testing.T) {
+	if _, err := ServerTLSConfig("", "", ""); err == nil {
+		t.Error("ServerTLSConfig() without certificate succeeded")
+	}
+	if _, err := ServerTLSConfig(filepath.Join(t.TempDir(), "missing.crt"), filepath.Join(t.TempDir(), "missing.key"), ""); err == nil {
+		t.Error("ServerTLSConfig() with missing files succeeded")
+	}
+}
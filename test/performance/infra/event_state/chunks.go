@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event_state
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// SplitChunks splits the events record list into chunks of about maxSize
+// bytes each, for lists larger than the maximum message size. Each chunk is
+// an events record list with the metadata of the list and the same items, by
+// type, holding a part of their events. It returns the list itself if it
+// doesn't exceed maxSize.
+func SplitChunks(rl *EventsRecordList, maxSize int) []*EventsRecordList {
+	size := proto.Size(rl)
+	if maxSize <= 0 || size <= maxSize {
+		return []*EventsRecordList{rl}
+	}
+	n := (size + maxSize - 1) / maxSize
+
+	chunks := make([]*EventsRecordList, n)
+	for c := range chunks {
+		chunk := &EventsRecordList{
+			Items:          make([]*EventsRecord, len(rl.Items)),
+			Source:         rl.Source,
+			PublishedAt:    rl.PublishedAt,
+			MonotonicClock: rl.MonotonicClock,
+			SubmissionId:   rl.SubmissionId,
+			Final:          rl.Final,
+			Tags:           rl.Tags,
+		}
+		for i, rec := range rl.Items {
+			chunk.Items[i] = &EventsRecord{Type: rec.Type}
+		}
+		chunks[c] = chunk
+	}
+
+	for i, rec := range rl.Items {
+		ids := recordIDs(rec)
+		for k, id := range ids {
+			copyEvent(chunks[k*n/len(ids)].Items[i], rec, id)
+		}
+		for k, e := range rec.KeyedEvents {
+			part := chunks[k*n/len(rec.KeyedEvents)].Items[i]
+			part.KeyedEvents = append(part.KeyedEvents, e)
+		}
+	}
+	return chunks
+}
+
+// MergeChunks merges the chunks of an events record list, split by
+// SplitChunks, back into the list.
+func MergeChunks(chunks []*EventsRecordList) (*EventsRecordList, error) {
+	if len(chunks) == 0 {
+		return nil, errors.New("no chunk of the events record list")
+	}
+	rl := chunks[0]
+	for c, chunk := range chunks[1:] {
+		if len(chunk.Items) != len(rl.Items) {
+			return nil, fmt.Errorf("chunk %d of the events record list has %d items, want %d", c+1, len(chunk.Items), len(rl.Items))
+		}
+		for i, part := range chunk.Items {
+			rec := rl.Items[i]
+			if part.Type != rec.Type {
+				return nil, fmt.Errorf("item %d of chunk %d of the events record list is of type %s, want %s", i, c+1, part.Type, rec.Type)
+			}
+			for _, id := range recordIDs(part) {
+				copyEvent(rec, part, id)
+			}
+			rec.KeyedEvents = append(rec.KeyedEvents, part.KeyedEvents...)
+		}
+	}
+	return rl, nil
+}
+
+// recordIDs returns the IDs of the events of the record, in any of its maps.
+func recordIDs(rec *EventsRecord) []string {
+	seen := make(map[string]struct{}, len(rec.Events))
+	var ids []string
+	add := func(id string) {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	for id := range rec.Events {
+		add(id)
+	}
+	for id := range rec.Monotonic {
+		add(id)
+	}
+	for id := range rec.ContentHashes {
+		add(id)
+	}
+	for id := range rec.ChecksumMismatches {
+		add(id)
+	}
+	for id := range rec.Attempts {
+		add(id)
+	}
+	for id := range rec.OnWire {
+		add(id)
+	}
+	for id := range rec.Sequences {
+		add(id)
+	}
+	for id := range rec.PayloadSizes {
+		add(id)
+	}
+	return ids
+}
+
+// copyEvent copies the entries of the event of the given ID from a record to
+// another of the same type.
+func copyEvent(to, from *EventsRecord, id string) {
+	if v, ok := from.Events[id]; ok {
+		if to.Events == nil {
+			to.Events = make(map[string]*timestamp.Timestamp)
+		}
+		to.Events[id] = v
+	}
+	if v, ok := from.Monotonic[id]; ok {
+		if to.Monotonic == nil {
+			to.Monotonic = make(map[string]int64)
+		}
+		to.Monotonic[id] = v
+	}
+	if v, ok := from.ContentHashes[id]; ok {
+		if to.ContentHashes == nil {
+			to.ContentHashes = make(map[string]uint32)
+		}
+		to.ContentHashes[id] = v
+	}
+	if v, ok := from.ChecksumMismatches[id]; ok {
+		if to.ChecksumMismatches == nil {
+			to.ChecksumMismatches = make(map[string]bool)
+		}
+		to.ChecksumMismatches[id] = v
+	}
+	if v, ok := from.Attempts[id]; ok {
+		if to.Attempts == nil {
+			to.Attempts = make(map[string]uint32)
+		}
+		to.Attempts[id] = v
+	}
+	if v, ok := from.OnWire[id]; ok {
+		if to.OnWire == nil {
+			to.OnWire = make(map[string]*timestamp.Timestamp)
+		}
+		to.OnWire[id] = v
+	}
+	if v, ok := from.Sequences[id]; ok {
+		if to.Sequences == nil {
+			to.Sequences = make(map[string]*EventKey)
+		}
+		to.Sequences[id] = v
+	}
+	if v, ok := from.PayloadSizes[id]; ok {
+		if to.PayloadSizes == nil {
+			to.PayloadSizes = make(map[string]uint32)
+		}
+		to.PayloadSizes[id] = v
+	}
+}
@@ -18,42 +18,319 @@ package event_state
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"log"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	// registers the gzip compressor, also decompressing the uploads on the
+	// aggregator side
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
 )
 
-const publishTimeout = 1 * time.Minute
+const (
+	publishTimeout = 1 * time.Minute
+	// attempts to upload the records before giving up, with the same submission
+	// ID so that the aggregator doesn't record them twice
+	publishAttempts = 3
+	retryDelay      = 5 * time.Second
+)
 
 type AggregatorClient struct {
 	conn   *grpc.ClientConn
 	aggCli EventsRecorderClient
+
+	upload UploadOptions
+}
+
+// UploadOptions configures how the events record lists are uploaded to the
+// aggregator, for lists approaching the maximum message size.
+type UploadOptions struct {
+	// compress the lists with gzip
+	Compress bool
+	// if positive, upload the lists larger than this size in bytes in chunks
+	// of about this size
+	ChunkSize int
+}
+
+// HandshakeOptions configures what the senders and receivers do with the
+// aggregator before the benchmark.
+type HandshakeOptions struct {
+	// register with the aggregator, announcing the events record lists to upload
+	Register bool
+	// answer the pings of the aggregator measuring the offset of the clock
+	SyncClock bool
 }
 
 func NewAggregatorClient(aggregAddr string) (*AggregatorClient, error) {
+	return dialAggregator(aggregAddr, grpc.WithInsecure())
+}
+
+// NewTLSAggregatorClient connects to the aggregator over TLS with the given
+// configuration, e.g. from ClientTLSConfig, or in plaintext if it's nil.
+func NewTLSAggregatorClient(aggregAddr string, config *tls.Config) (*AggregatorClient, error) {
+	if config == nil {
+		return NewAggregatorClient(aggregAddr)
+	}
+	return dialAggregator(aggregAddr, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+}
+
+func dialAggregator(aggregAddr string, creds grpc.DialOption) (*AggregatorClient, error) {
 	// create a connection to the aggregator
-	conn, err := grpc.Dial(aggregAddr, grpc.WithInsecure())
+	conn, err := grpc.Dial(aggregAddr, creds)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to connect to the aggregator: %v", err)
 	}
 
 	aggCli := NewEventsRecorderClient(conn)
 
-	return &AggregatorClient{conn, aggCli}, nil
+	return &AggregatorClient{conn: conn, aggCli: aggCli}, nil
+}
+
+// SetUploadOptions configures how the next events record lists are uploaded.
+func (ac *AggregatorClient) SetUploadOptions(upload UploadOptions) {
+	ac.upload = upload
 }
 
 func (ac *AggregatorClient) Publish(rl *EventsRecordList) error {
-	return ac.publishWithTimeout(publishTimeout, rl)
+	if rl.SubmissionId == "" {
+		id, err := newSubmissionID()
+		if err != nil {
+			return err
+		}
+		rl.SubmissionId = id
+	}
+	var err error
+	for attempt := 1; attempt <= publishAttempts; attempt++ {
+		err = ac.publishWithTimeout(publishTimeout, rl)
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		if attempt < publishAttempts {
+			log.Printf("Failed to publish the events records (attempt %d of %d), retrying: %v", attempt, publishAttempts, err)
+			time.Sleep(retryDelay)
+		}
+	}
+	return err
 }
 
 func (ac *AggregatorClient) publishWithTimeout(timeout time.Duration, rl *EventsRecordList) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	_, err := ac.aggCli.RecordEvents(ctx, rl)
+	rl.PublishedAt = ptypes.TimestampNow()
+	var opts []grpc.CallOption
+	if ac.upload.Compress {
+		opts = append(opts, grpc.UseCompressor(gzip.Name))
+	}
+	var reply *RecordReply
+	var err error
+	if chunks := SplitChunks(rl, ac.upload.ChunkSize); len(chunks) > 1 {
+		log.Printf("Uploading the events records in %d chunks", len(chunks))
+		reply, err = ac.publishChunks(ctx, chunks, opts)
+	} else {
+		reply, err = ac.aggCli.RecordEvents(ctx, rl, opts...)
+	}
+	if err != nil {
+		return err
+	}
+	if reply.GetDuplicateSubmission() {
+		log.Printf("The aggregator had already recorded the events records of submission %s", rl.SubmissionId)
+	}
+	if reply.GetAfterCompletion() {
+		log.Printf("!! The aggregator received the events records after all the ones it expected, they are not counted")
+	}
+	return nil
+}
+
+// publishChunks uploads the chunks of an events record list in a single
+// stream, which the aggregator records once all of them are received.
+func (ac *AggregatorClient) publishChunks(ctx context.Context, chunks []*EventsRecordList, opts []grpc.CallOption) (*RecordReply, error) {
+	stream, err := ac.aggCli.RecordEventsChunks(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		if err := stream.Send(chunk); err == io.EOF {
+			// the aggregator closed the stream, its status tells why
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// Finish tells the aggregator that no more records are coming, so that it
+// publishes the results without waiting for the remaining expected records.
+func (ac *AggregatorClient) Finish(source string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	reply, err := ac.aggCli.Finish(ctx, &FinishRequest{Source: source})
+	if err != nil {
+		return err
+	}
+	if reply.GetAlreadyComplete() {
+		log.Printf("The aggregator had already received all the events records it expected")
+	}
+	return nil
+}
+
+// MarkWindow marks the start or the stop of a window of the given kind, e.g. a
+// maintenance of the broker, whose events the aggregator excludes from the results.
+func (ac *AggregatorClient) MarkWindow(kind string, edge WindowRequest_Edge, source string) error {
+	return ac.MarkWindowAt(kind, edge, source, time.Now())
+}
+
+// MarkWindowAt marks the start or the stop of a window of the given kind at the
+// given time, e.g. once the benchmark is over.
+func (ac *AggregatorClient) MarkWindowAt(kind string, edge WindowRequest_Edge, source string, at time.Time) error {
+	ts, err := ptypes.TimestampProto(at)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	_, err = ac.aggCli.MarkWindow(ctx, &WindowRequest{Kind: kind, Edge: edge, At: ts, Source: source})
 	return err
 }
 
+// Handshake registers the sender or receiver of the given source, which will
+// upload the given number of events record lists, and syncs its clock, as
+// enabled by the options. It only logs the failures, as neither is required
+// for the aggregator to record the events.
+func (ac *AggregatorClient) Handshake(source string, expectedBatches uint32, opts HandshakeOptions) {
+	if !opts.Register && !opts.SyncClock {
+		return
+	}
+	if source == "" {
+		log.Printf("!! Can't register with the aggregator nor sync the clock without a source")
+		return
+	}
+	if opts.Register {
+		if err := ac.Register(source, expectedBatches); err != nil {
+			log.Printf("Failed to register with the aggregator: %v", err)
+		}
+	}
+	if opts.SyncClock {
+		if err := ac.SyncClock(source); err != nil {
+			log.Printf("Failed to sync the clock with the aggregator: %v", err)
+		}
+	}
+}
+
+// Register announces the sender or receiver of the given source to the
+// aggregator, with the number of events record lists it will upload, so that
+// the aggregator can tell whether it underdelivered. As it's sent before the
+// benchmark, it waits for the aggregator to be up.
+func (ac *AggregatorClient) Register(source string, expectedBatches uint32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	_, err := ac.aggCli.Register(ctx, &RegisterRequest{Source: source, ExpectedBatches: expectedBatches}, grpc.WaitForReady(true))
+	return err
+}
+
+// SyncClock answers the pings of the aggregator measuring the offset of the
+// clock of the sender or receiver of the given source, until the aggregator
+// ends the handshake, right away if it doesn't correct the clock skew. Like
+// Register, it waits for the aggregator to be up.
+func (ac *AggregatorClient) SyncClock(source string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	stream, err := ac.aggCli.SyncClock(ctx, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+	// io.EOF once the aggregator ended the stream, whose status the next
+	// receive returns
+	if err := stream.Send(&ClockPong{Source: source}); err != nil && err != io.EOF {
+		return err
+	}
+	for {
+		ping, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&ClockPong{Seq: ping.Seq, At: ptypes.TimestampNow()}); err != nil && err != io.EOF {
+			return err
+		}
+	}
+}
+
+// StreamLatencies subscribes to the end to end latencies streamed by the
+// aggregator as the events are matched, calling handle with each of them until
+// the aggregator received all the records or the context is done.
+func (ac *AggregatorClient) StreamLatencies(ctx context.Context, source string, handle func(*LatencySample)) error {
+	stream, err := ac.aggCli.StreamLatencies(ctx, &StreamRequest{Source: source})
+	if err != nil {
+		return err
+	}
+	for {
+		sample, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		handle(sample)
+	}
+}
+
+// StreamProgress subscribes to the progress of the run reported by the
+// aggregator at the given interval, its default one if 0, calling handle with
+// each report until the aggregator received all the records, the last report
+// being complete, or the context is done.
+func (ac *AggregatorClient) StreamProgress(ctx context.Context, source string, interval time.Duration, handle func(*Progress)) error {
+	req := &ProgressRequest{Source: source}
+	if interval != 0 {
+		req.Interval = ptypes.DurationProto(interval)
+	}
+	stream, err := ac.aggCli.StreamProgress(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		progress, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		handle(progress)
+	}
+}
+
+// isTransient tells whether the upload failed for a reason which retrying may fix.
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// newSubmissionID generates a random submission ID.
+func newSubmissionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate the submission ID: %v", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
 func (ac *AggregatorClient) Close() {
 	_ = ac.conn.Close()
 }
@@ -9,6 +9,7 @@ import (
 	math "math"
 
 	proto "github.com/golang/protobuf/proto"
+	duration "github.com/golang/protobuf/ptypes/duration"
 	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
@@ -57,12 +58,67 @@ func (EventsRecord_Type) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_de3fba9d879b76ae, []int{0, 0}
 }
 
+type WindowRequest_Edge int32
+
+const (
+	WindowRequest_UNKNOWN WindowRequest_Edge = 0
+	WindowRequest_START   WindowRequest_Edge = 1
+	WindowRequest_STOP    WindowRequest_Edge = 2
+)
+
+var WindowRequest_Edge_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "START",
+	2: "STOP",
+}
+
+var WindowRequest_Edge_value = map[string]int32{
+	"UNKNOWN": 0,
+	"START":   1,
+	"STOP":    2,
+}
+
+func (x WindowRequest_Edge) String() string {
+	return proto.EnumName(WindowRequest_Edge_name, int32(x))
+}
+
+func (WindowRequest_Edge) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{7, 0}
+}
+
 type EventsRecord struct {
-	Events               map[string]*timestamp.Timestamp `protobuf:"bytes,1,rep,name=Events,proto3" json:"Events,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	Type                 EventsRecord_Type               `protobuf:"varint,2,opt,name=type,proto3,enum=event_state.EventsRecord_Type" json:"type,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                        `json:"-"`
-	XXX_unrecognized     []byte                          `json:"-"`
-	XXX_sizecache        int32                           `json:"-"`
+	Events map[string]*timestamp.Timestamp `protobuf:"bytes,1,rep,name=Events,proto3" json:"Events,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Type   EventsRecord_Type               `protobuf:"varint,2,opt,name=type,proto3,enum=event_state.EventsRecord_Type" json:"type,omitempty"`
+	// Events identified by a composite key instead of an ID, for senders which
+	// can't guarantee globally unique IDs.
+	KeyedEvents []*KeyedEvent `protobuf:"bytes,3,rep,name=keyed_events,json=keyedEvents,proto3" json:"keyed_events,omitempty"`
+	// Monotonic clock readings of the events identified by ID, in nanoseconds
+	// since an arbitrary origin, paired with their wall clock timestamps.
+	Monotonic map[string]int64 `protobuf:"bytes,4,rep,name=monotonic,proto3" json:"monotonic,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// Hashes of the content of the events identified by ID, as sent or as
+	// received, to verify the integrity of their delivery.
+	ContentHashes map[string]uint32 `protobuf:"bytes,5,rep,name=content_hashes,json=contentHashes,proto3" json:"content_hashes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// Events identified by ID whose received payload didn't match the checksum
+	// computed by their sender, as verified by the receiver.
+	ChecksumMismatches map[string]bool `protobuf:"bytes,6,rep,name=checksum_mismatches,json=checksumMismatches,proto3" json:"checksum_mismatches,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// Attempts of the events identified by ID, counting from 1, when known: the
+	// delivery attempt of the received events, or the publish attempt of the
+	// accepted events.
+	Attempts map[string]uint32 `protobuf:"bytes,7,rep,name=attempts,proto3" json:"attempts,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// Times the sent events identified by ID actually went on the wire, after
+	// waiting in their sender since their timestamp in Events, e.g. for a
+	// connection to the sink.
+	OnWire map[string]*timestamp.Timestamp `protobuf:"bytes,8,rep,name=on_wire,json=onWire,proto3" json:"on_wire,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Partition keys and sequence numbers of the events identified by ID, for
+	// senders numbering their events monotonically within each partition, to
+	// verify the order in which they are received.
+	Sequences map[string]*EventKey `protobuf:"bytes,9,rep,name=sequences,proto3" json:"sequences,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Sizes in bytes of the payloads of the sent events identified by ID, for
+	// senders varying them, to break the latencies down by payload size.
+	PayloadSizes         map[string]uint32 `protobuf:"bytes,10,rep,name=payload_sizes,json=payloadSizes,proto3" json:"payload_sizes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
 }
 
 func (m *EventsRecord) Reset()         { *m = EventsRecord{} }
@@ -104,18 +160,186 @@ func (m *EventsRecord) GetType() EventsRecord_Type {
 	return EventsRecord_UNKNOWN
 }
 
+func (m *EventsRecord) GetKeyedEvents() []*KeyedEvent {
+	if m != nil {
+		return m.KeyedEvents
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetMonotonic() map[string]int64 {
+	if m != nil {
+		return m.Monotonic
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetContentHashes() map[string]uint32 {
+	if m != nil {
+		return m.ContentHashes
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetChecksumMismatches() map[string]bool {
+	if m != nil {
+		return m.ChecksumMismatches
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetAttempts() map[string]uint32 {
+	if m != nil {
+		return m.Attempts
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetOnWire() map[string]*timestamp.Timestamp {
+	if m != nil {
+		return m.OnWire
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetSequences() map[string]*EventKey {
+	if m != nil {
+		return m.Sequences
+	}
+	return nil
+}
+
+func (m *EventsRecord) GetPayloadSizes() map[string]uint32 {
+	if m != nil {
+		return m.PayloadSizes
+	}
+	return nil
+}
+
+// Composite key of an event, unique within its partition.
+type EventKey struct {
+	Partition            string   `protobuf:"bytes,1,opt,name=partition,proto3" json:"partition,omitempty"`
+	Sequence             uint64   `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EventKey) Reset()         { *m = EventKey{} }
+func (m *EventKey) String() string { return proto.CompactTextString(m) }
+func (*EventKey) ProtoMessage()    {}
+func (*EventKey) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{1}
+}
+
+func (m *EventKey) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventKey.Unmarshal(m, b)
+}
+func (m *EventKey) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventKey.Marshal(b, m, deterministic)
+}
+func (m *EventKey) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventKey.Merge(m, src)
+}
+func (m *EventKey) XXX_Size() int {
+	return xxx_messageInfo_EventKey.Size(m)
+}
+func (m *EventKey) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventKey.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventKey proto.InternalMessageInfo
+
+func (m *EventKey) GetPartition() string {
+	if m != nil {
+		return m.Partition
+	}
+	return ""
+}
+
+func (m *EventKey) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+type KeyedEvent struct {
+	Key                  *EventKey            `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	At                   *timestamp.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *KeyedEvent) Reset()         { *m = KeyedEvent{} }
+func (m *KeyedEvent) String() string { return proto.CompactTextString(m) }
+func (*KeyedEvent) ProtoMessage()    {}
+func (*KeyedEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{2}
+}
+
+func (m *KeyedEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_KeyedEvent.Unmarshal(m, b)
+}
+func (m *KeyedEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_KeyedEvent.Marshal(b, m, deterministic)
+}
+func (m *KeyedEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_KeyedEvent.Merge(m, src)
+}
+func (m *KeyedEvent) XXX_Size() int {
+	return xxx_messageInfo_KeyedEvent.Size(m)
+}
+func (m *KeyedEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_KeyedEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_KeyedEvent proto.InternalMessageInfo
+
+func (m *KeyedEvent) GetKey() *EventKey {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *KeyedEvent) GetAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.At
+	}
+	return nil
+}
+
 type EventsRecordList struct {
-	Items                []*EventsRecord `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	Items []*EventsRecord `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	// Identity (e.g. the pod name) of the sender or receiver producing the records.
+	Source string `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	// Time at which the records were published, by the clock of their producer.
+	PublishedAt *timestamp.Timestamp `protobuf:"bytes,3,opt,name=published_at,json=publishedAt,proto3" json:"published_at,omitempty"`
+	// Identity of the monotonic clock of the readings of the records: readings
+	// can only be compared with the readings of the same clock.
+	MonotonicClock string `protobuf:"bytes,4,opt,name=monotonic_clock,json=monotonicClock,proto3" json:"monotonic_clock,omitempty"`
+	// Identifier of the submission of the records, the same across the retries
+	// of their upload, so that the aggregator records them once.
+	SubmissionId string `protobuf:"bytes,5,opt,name=submission_id,json=submissionId,proto3" json:"submission_id,omitempty"`
+	// Whether these are the last records of their source, which is done. The
+	// aggregator completes once all the expected sources are done, even if it
+	// didn't count all the expected records.
+	Final bool `protobuf:"varint,6,opt,name=final,proto3" json:"final,omitempty"`
+	// Mako tags describing how the producer ran, e.g. the content mode of the
+	// events it sent, added to the tags of the run.
+	Tags                 []string `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *EventsRecordList) Reset()         { *m = EventsRecordList{} }
 func (m *EventsRecordList) String() string { return proto.CompactTextString(m) }
 func (*EventsRecordList) ProtoMessage()    {}
 func (*EventsRecordList) Descriptor() ([]byte, []int) {
-	return fileDescriptor_de3fba9d879b76ae, []int{1}
+	return fileDescriptor_de3fba9d879b76ae, []int{3}
 }
 
 func (m *EventsRecordList) XXX_Unmarshal(b []byte) error {
@@ -143,8 +367,56 @@ func (m *EventsRecordList) GetItems() []*EventsRecord {
 	return nil
 }
 
+func (m *EventsRecordList) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *EventsRecordList) GetPublishedAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.PublishedAt
+	}
+	return nil
+}
+
+func (m *EventsRecordList) GetMonotonicClock() string {
+	if m != nil {
+		return m.MonotonicClock
+	}
+	return ""
+}
+
+func (m *EventsRecordList) GetSubmissionId() string {
+	if m != nil {
+		return m.SubmissionId
+	}
+	return ""
+}
+
+func (m *EventsRecordList) GetFinal() bool {
+	if m != nil {
+		return m.Final
+	}
+	return false
+}
+
+func (m *EventsRecordList) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
 type RecordReply struct {
-	Count                uint32   `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Count uint32 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	// Whether the records arrived after the aggregator received all the
+	// records it expected, so that they are not counted.
+	AfterCompletion bool `protobuf:"varint,2,opt,name=after_completion,json=afterCompletion,proto3" json:"after_completion,omitempty"`
+	// Whether the records were already recorded in a previous attempt of the
+	// same submission, so that they are not counted again.
+	DuplicateSubmission  bool     `protobuf:"varint,3,opt,name=duplicate_submission,json=duplicateSubmission,proto3" json:"duplicate_submission,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -154,7 +426,7 @@ func (m *RecordReply) Reset()         { *m = RecordReply{} }
 func (m *RecordReply) String() string { return proto.CompactTextString(m) }
 func (*RecordReply) ProtoMessage()    {}
 func (*RecordReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_de3fba9d879b76ae, []int{2}
+	return fileDescriptor_de3fba9d879b76ae, []int{4}
 }
 
 func (m *RecordReply) XXX_Unmarshal(b []byte) error {
@@ -182,117 +454,1267 @@ func (m *RecordReply) GetCount() uint32 {
 	return 0
 }
 
-func init() {
-	proto.RegisterEnum("event_state.EventsRecord_Type", EventsRecord_Type_name, EventsRecord_Type_value)
-	proto.RegisterType((*EventsRecord)(nil), "event_state.EventsRecord")
-	proto.RegisterMapType((map[string]*timestamp.Timestamp)(nil), "event_state.EventsRecord.EventsEntry")
-	proto.RegisterType((*EventsRecordList)(nil), "event_state.EventsRecordList")
-	proto.RegisterType((*RecordReply)(nil), "event_state.RecordReply")
+func (m *RecordReply) GetAfterCompletion() bool {
+	if m != nil {
+		return m.AfterCompletion
+	}
+	return false
 }
 
-func init() { proto.RegisterFile("event_state.proto", fileDescriptor_de3fba9d879b76ae) }
+func (m *RecordReply) GetDuplicateSubmission() bool {
+	if m != nil {
+		return m.DuplicateSubmission
+	}
+	return false
+}
 
-var fileDescriptor_de3fba9d879b76ae = []byte{
-	// 328 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x50, 0x4f, 0x4b, 0xfb, 0x40,
-	0x10, 0x6d, 0xd2, 0x3f, 0xbf, 0xfe, 0x66, 0x6b, 0x59, 0x17, 0x0f, 0x31, 0xa0, 0x96, 0x88, 0xd0,
-	0x53, 0x2a, 0xf1, 0xa2, 0x82, 0x07, 0x49, 0xf7, 0x50, 0x94, 0x28, 0x6b, 0xaa, 0x07, 0x0f, 0xd2,
-	0xd6, 0xb1, 0x14, 0xdb, 0x6e, 0x48, 0xb6, 0x85, 0x7c, 0x0e, 0xbf, 0xb0, 0x24, 0x9b, 0xc2, 0x7a,
-	0xe8, 0x6d, 0xde, 0xcc, 0x7b, 0xf3, 0xe6, 0x0d, 0x1c, 0xe2, 0x16, 0xd7, 0xea, 0x23, 0x53, 0x13,
-	0x85, 0x7e, 0x92, 0x4a, 0x25, 0x19, 0x31, 0x5a, 0xee, 0xd9, 0x5c, 0xca, 0xf9, 0x12, 0x07, 0xe5,
-	0x68, 0xba, 0xf9, 0x1a, 0xa8, 0xc5, 0x0a, 0x33, 0x35, 0x59, 0x25, 0x9a, 0xed, 0xfd, 0xd8, 0xd0,
-	0xe1, 0x85, 0x20, 0x13, 0x38, 0x93, 0xe9, 0x27, 0xbb, 0x83, 0x96, 0xc6, 0x8e, 0xd5, 0xab, 0xf7,
-	0x49, 0x70, 0xe1, 0x9b, 0x16, 0x26, 0xb5, 0x02, 0x7c, 0xad, 0xd2, 0x5c, 0x54, 0x22, 0x16, 0x40,
-	0x43, 0xe5, 0x09, 0x3a, 0x76, 0xcf, 0xea, 0x77, 0x83, 0xd3, 0xfd, 0xe2, 0x38, 0x4f, 0x50, 0x94,
-	0x5c, 0x77, 0x0c, 0xc4, 0x58, 0xc5, 0x28, 0xd4, 0xbf, 0x31, 0x77, 0xac, 0x9e, 0xd5, 0xff, 0x2f,
-	0x8a, 0x92, 0x5d, 0x42, 0x73, 0x3b, 0x59, 0x6e, 0xf4, 0x56, 0x12, 0xb8, 0xbe, 0x4e, 0xe5, 0xef,
-	0x52, 0xf9, 0xf1, 0x2e, 0x95, 0xd0, 0xc4, 0x5b, 0xfb, 0xda, 0xf2, 0x6e, 0xa0, 0x51, 0x98, 0x30,
-	0x02, 0xff, 0xc6, 0xd1, 0x43, 0xf4, 0xf4, 0x16, 0xd1, 0x1a, 0x6b, 0x43, 0xe3, 0x85, 0x47, 0x31,
-	0xb5, 0x58, 0x07, 0xda, 0xf7, 0x61, 0xc8, 0x9f, 0x63, 0x3e, 0xa4, 0x76, 0x81, 0x04, 0x0f, 0xf9,
-	0xe8, 0x95, 0x0f, 0x69, 0xdd, 0x0b, 0x81, 0x9a, 0xc7, 0x3e, 0x2e, 0x32, 0xc5, 0x06, 0xd0, 0x5c,
-	0x28, 0x5c, 0xed, 0xfe, 0x72, 0xbc, 0x37, 0x9a, 0xd0, 0x3c, 0xef, 0x1c, 0x48, 0xd5, 0xc0, 0x64,
-	0x99, 0xb3, 0x23, 0x68, 0xce, 0xe4, 0x66, 0xad, 0xca, 0x60, 0x07, 0x42, 0x83, 0xe0, 0x1d, 0xba,
-	0xa6, 0x16, 0x53, 0x36, 0x82, 0x8e, 0xae, 0xab, 0x8f, 0x9e, 0xec, 0x35, 0x2a, 0xce, 0x72, 0x9d,
-	0x3f, 0x63, 0xc3, 0xd0, 0xab, 0x4d, 0x5b, 0xe5, 0x83, 0xae, 0x7e, 0x03, 0x00, 0x00, 0xff, 0xff,
-	0x1f, 0xfa, 0x30, 0x3b, 0x26, 0x02, 0x00, 0x00,
+type FinishRequest struct {
+	// Identity of the orchestrator requesting the aggregator to finish.
+	Source               string   `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *FinishRequest) Reset()         { *m = FinishRequest{} }
+func (m *FinishRequest) String() string { return proto.CompactTextString(m) }
+func (*FinishRequest) ProtoMessage()    {}
+func (*FinishRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{5}
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *FinishRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FinishRequest.Unmarshal(m, b)
+}
+func (m *FinishRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FinishRequest.Marshal(b, m, deterministic)
+}
+func (m *FinishRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FinishRequest.Merge(m, src)
+}
+func (m *FinishRequest) XXX_Size() int {
+	return xxx_messageInfo_FinishRequest.Size(m)
+}
+func (m *FinishRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FinishRequest.DiscardUnknown(m)
+}
 
-// EventsRecorderClient is the client API for EventsRecorder service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type EventsRecorderClient interface {
-	RecordEvents(ctx context.Context, in *EventsRecordList, opts ...grpc.CallOption) (*RecordReply, error)
+var xxx_messageInfo_FinishRequest proto.InternalMessageInfo
+
+func (m *FinishRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
 }
 
-type eventsRecorderClient struct {
-	cc *grpc.ClientConn
+type FinishReply struct {
+	// Whether the aggregator had already received all the records it expected.
+	AlreadyComplete      bool     `protobuf:"varint,1,opt,name=already_complete,json=alreadyComplete,proto3" json:"already_complete,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func NewEventsRecorderClient(cc *grpc.ClientConn) EventsRecorderClient {
-	return &eventsRecorderClient{cc}
+func (m *FinishReply) Reset()         { *m = FinishReply{} }
+func (m *FinishReply) String() string { return proto.CompactTextString(m) }
+func (*FinishReply) ProtoMessage()    {}
+func (*FinishReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{6}
 }
 
-func (c *eventsRecorderClient) RecordEvents(ctx context.Context, in *EventsRecordList, opts ...grpc.CallOption) (*RecordReply, error) {
-	out := new(RecordReply)
-	err := c.cc.Invoke(ctx, "/event_state.EventsRecorder/RecordEvents", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (m *FinishReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FinishReply.Unmarshal(m, b)
+}
+func (m *FinishReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FinishReply.Marshal(b, m, deterministic)
+}
+func (m *FinishReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FinishReply.Merge(m, src)
+}
+func (m *FinishReply) XXX_Size() int {
+	return xxx_messageInfo_FinishReply.Size(m)
+}
+func (m *FinishReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_FinishReply.DiscardUnknown(m)
 }
 
-// EventsRecorderServer is the server API for EventsRecorder service.
-type EventsRecorderServer interface {
-	RecordEvents(context.Context, *EventsRecordList) (*RecordReply, error)
+var xxx_messageInfo_FinishReply proto.InternalMessageInfo
+
+func (m *FinishReply) GetAlreadyComplete() bool {
+	if m != nil {
+		return m.AlreadyComplete
+	}
+	return false
 }
 
-// UnimplementedEventsRecorderServer can be embedded to have forward compatible implementations.
-type UnimplementedEventsRecorderServer struct {
+type WindowRequest struct {
+	// Kind of the window, e.g. "maintenance".
+	Kind string             `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Edge WindowRequest_Edge `protobuf:"varint,2,opt,name=edge,proto3,enum=event_state.WindowRequest_Edge" json:"edge,omitempty"`
+	// Time of the start or stop of the window, by the clock of the caller. The
+	// aggregator uses the arrival time of the request when unset.
+	At *timestamp.Timestamp `protobuf:"bytes,3,opt,name=at,proto3" json:"at,omitempty"`
+	// Identity of the broker or operator marking the window.
+	Source               string   `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (*UnimplementedEventsRecorderServer) RecordEvents(ctx context.Context, req *EventsRecordList) (*RecordReply, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method RecordEvents not implemented")
+func (m *WindowRequest) Reset()         { *m = WindowRequest{} }
+func (m *WindowRequest) String() string { return proto.CompactTextString(m) }
+func (*WindowRequest) ProtoMessage()    {}
+func (*WindowRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{7}
 }
 
-func RegisterEventsRecorderServer(s *grpc.Server, srv EventsRecorderServer) {
-	s.RegisterService(&_EventsRecorder_serviceDesc, srv)
+func (m *WindowRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WindowRequest.Unmarshal(m, b)
+}
+func (m *WindowRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WindowRequest.Marshal(b, m, deterministic)
+}
+func (m *WindowRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WindowRequest.Merge(m, src)
+}
+func (m *WindowRequest) XXX_Size() int {
+	return xxx_messageInfo_WindowRequest.Size(m)
+}
+func (m *WindowRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WindowRequest.DiscardUnknown(m)
 }
 
-func _EventsRecorder_RecordEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(EventsRecordList)
-	if err := dec(in); err != nil {
-		return nil, err
+var xxx_messageInfo_WindowRequest proto.InternalMessageInfo
+
+func (m *WindowRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
 	}
-	if interceptor == nil {
-		return srv.(EventsRecorderServer).RecordEvents(ctx, in)
+	return ""
+}
+
+func (m *WindowRequest) GetEdge() WindowRequest_Edge {
+	if m != nil {
+		return m.Edge
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/event_state.EventsRecorder/RecordEvents",
+	return WindowRequest_UNKNOWN
+}
+
+func (m *WindowRequest) GetAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.At
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(EventsRecorderServer).RecordEvents(ctx, req.(*EventsRecordList))
+	return nil
+}
+
+func (m *WindowRequest) GetSource() string {
+	if m != nil {
+		return m.Source
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-var _EventsRecorder_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "event_state.EventsRecorder",
-	HandlerType: (*EventsRecorderServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "RecordEvents",
-			Handler:    _EventsRecorder_RecordEvents_Handler,
+type WindowReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WindowReply) Reset()         { *m = WindowReply{} }
+func (m *WindowReply) String() string { return proto.CompactTextString(m) }
+func (*WindowReply) ProtoMessage()    {}
+func (*WindowReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{8}
+}
+
+func (m *WindowReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WindowReply.Unmarshal(m, b)
+}
+func (m *WindowReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WindowReply.Marshal(b, m, deterministic)
+}
+func (m *WindowReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WindowReply.Merge(m, src)
+}
+func (m *WindowReply) XXX_Size() int {
+	return xxx_messageInfo_WindowReply.Size(m)
+}
+func (m *WindowReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_WindowReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WindowReply proto.InternalMessageInfo
+
+type RegisterRequest struct {
+	// Identity of the sender or receiver, as set in the source of its records.
+	Source string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	// Number of events record lists it will upload, the last one final.
+	ExpectedBatches      uint32   `protobuf:"varint,2,opt,name=expected_batches,json=expectedBatches,proto3" json:"expected_batches,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()    {}
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{9}
+}
+
+func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterRequest.Unmarshal(m, b)
+}
+func (m *RegisterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterRequest.Merge(m, src)
+}
+func (m *RegisterRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterRequest.Size(m)
+}
+func (m *RegisterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterRequest proto.InternalMessageInfo
+
+func (m *RegisterRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetExpectedBatches() uint32 {
+	if m != nil {
+		return m.ExpectedBatches
+	}
+	return 0
+}
+
+type RegisterReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterReply) Reset()         { *m = RegisterReply{} }
+func (m *RegisterReply) String() string { return proto.CompactTextString(m) }
+func (*RegisterReply) ProtoMessage()    {}
+func (*RegisterReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{10}
+}
+
+func (m *RegisterReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterReply.Unmarshal(m, b)
+}
+func (m *RegisterReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterReply.Marshal(b, m, deterministic)
+}
+func (m *RegisterReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterReply.Merge(m, src)
+}
+func (m *RegisterReply) XXX_Size() int {
+	return xxx_messageInfo_RegisterReply.Size(m)
+}
+func (m *RegisterReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterReply proto.InternalMessageInfo
+
+type StreamRequest struct {
+	// Identity of the subscriber, e.g. a dashboard.
+	Source               string   `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamRequest) Reset()         { *m = StreamRequest{} }
+func (m *StreamRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamRequest) ProtoMessage()    {}
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{11}
+}
+
+func (m *StreamRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamRequest.Unmarshal(m, b)
+}
+func (m *StreamRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamRequest.Marshal(b, m, deterministic)
+}
+func (m *StreamRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamRequest.Merge(m, src)
+}
+func (m *StreamRequest) XXX_Size() int {
+	return xxx_messageInfo_StreamRequest.Size(m)
+}
+func (m *StreamRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamRequest proto.InternalMessageInfo
+
+func (m *StreamRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+type LatencySample struct {
+	// ID of the event, or its composite ID when matching on composite keys.
+	Id      string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Sent    *timestamp.Timestamp `protobuf:"bytes,2,opt,name=sent,proto3" json:"sent,omitempty"`
+	Latency *duration.Duration   `protobuf:"bytes,3,opt,name=latency,proto3" json:"latency,omitempty"`
+	// Number of samples dropped since the previous one, as the subscriber
+	// didn't keep up with them.
+	Dropped              uint64   `protobuf:"varint,4,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LatencySample) Reset()         { *m = LatencySample{} }
+func (m *LatencySample) String() string { return proto.CompactTextString(m) }
+func (*LatencySample) ProtoMessage()    {}
+func (*LatencySample) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{12}
+}
+
+func (m *LatencySample) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LatencySample.Unmarshal(m, b)
+}
+func (m *LatencySample) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LatencySample.Marshal(b, m, deterministic)
+}
+func (m *LatencySample) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LatencySample.Merge(m, src)
+}
+func (m *LatencySample) XXX_Size() int {
+	return xxx_messageInfo_LatencySample.Size(m)
+}
+func (m *LatencySample) XXX_DiscardUnknown() {
+	xxx_messageInfo_LatencySample.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LatencySample proto.InternalMessageInfo
+
+func (m *LatencySample) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *LatencySample) GetSent() *timestamp.Timestamp {
+	if m != nil {
+		return m.Sent
+	}
+	return nil
+}
+
+func (m *LatencySample) GetLatency() *duration.Duration {
+	if m != nil {
+		return m.Latency
+	}
+	return nil
+}
+
+func (m *LatencySample) GetDropped() uint64 {
+	if m != nil {
+		return m.Dropped
+	}
+	return 0
+}
+
+type ClockPing struct {
+	// Sequence number of the ping, starting at 1.
+	Seq                  uint64   `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ClockPing) Reset()         { *m = ClockPing{} }
+func (m *ClockPing) String() string { return proto.CompactTextString(m) }
+func (*ClockPing) ProtoMessage()    {}
+func (*ClockPing) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{13}
+}
+
+func (m *ClockPing) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClockPing.Unmarshal(m, b)
+}
+func (m *ClockPing) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClockPing.Marshal(b, m, deterministic)
+}
+func (m *ClockPing) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClockPing.Merge(m, src)
+}
+func (m *ClockPing) XXX_Size() int {
+	return xxx_messageInfo_ClockPing.Size(m)
+}
+func (m *ClockPing) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClockPing.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClockPing proto.InternalMessageInfo
+
+func (m *ClockPing) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+type ClockPong struct {
+	// Identity of the sender or receiver, as set in the source of its records.
+	Source string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	// Sequence number of the answered ping, 0 in the first pong.
+	Seq uint64 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Time the ping was answered, by the clock of the worker.
+	At                   *timestamp.Timestamp `protobuf:"bytes,3,opt,name=at,proto3" json:"at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *ClockPong) Reset()         { *m = ClockPong{} }
+func (m *ClockPong) String() string { return proto.CompactTextString(m) }
+func (*ClockPong) ProtoMessage()    {}
+func (*ClockPong) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{14}
+}
+
+func (m *ClockPong) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClockPong.Unmarshal(m, b)
+}
+func (m *ClockPong) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClockPong.Marshal(b, m, deterministic)
+}
+func (m *ClockPong) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClockPong.Merge(m, src)
+}
+func (m *ClockPong) XXX_Size() int {
+	return xxx_messageInfo_ClockPong.Size(m)
+}
+func (m *ClockPong) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClockPong.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClockPong proto.InternalMessageInfo
+
+func (m *ClockPong) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *ClockPong) GetSeq() uint64 {
+	if m != nil {
+		return m.Seq
+	}
+	return 0
+}
+
+func (m *ClockPong) GetAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.At
+	}
+	return nil
+}
+
+type ProgressRequest struct {
+	// Identity of the subscriber, e.g. an operator watching the run.
+	Source string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	// Interval between the reports, 10s when unset.
+	Interval             *duration.Duration `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *ProgressRequest) Reset()         { *m = ProgressRequest{} }
+func (m *ProgressRequest) String() string { return proto.CompactTextString(m) }
+func (*ProgressRequest) ProtoMessage()    {}
+func (*ProgressRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{15}
+}
+
+func (m *ProgressRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProgressRequest.Unmarshal(m, b)
+}
+func (m *ProgressRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProgressRequest.Marshal(b, m, deterministic)
+}
+func (m *ProgressRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProgressRequest.Merge(m, src)
+}
+func (m *ProgressRequest) XXX_Size() int {
+	return xxx_messageInfo_ProgressRequest.Size(m)
+}
+func (m *ProgressRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProgressRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProgressRequest proto.InternalMessageInfo
+
+func (m *ProgressRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *ProgressRequest) GetInterval() *duration.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+type Progress struct {
+	// Time of the report, by the clock of the aggregator.
+	At *timestamp.Timestamp `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
+	// Number of events record lists received so far, and expected.
+	RecordsReceived uint64 `protobuf:"varint,2,opt,name=records_received,json=recordsReceived,proto3" json:"records_received,omitempty"`
+	RecordsExpected uint64 `protobuf:"varint,3,opt,name=records_expected,json=recordsExpected,proto3" json:"records_expected,omitempty"`
+	// Number of distinct events recorded so far as sent, accepted and received.
+	Sent     uint64 `protobuf:"varint,4,opt,name=sent,proto3" json:"sent,omitempty"`
+	Accepted uint64 `protobuf:"varint,5,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Received uint64 `protobuf:"varint,6,opt,name=received,proto3" json:"received,omitempty"`
+	// Number of received events whose sent event is recorded, and interim
+	// percentiles of their end to end latencies.
+	Matched    uint64             `protobuf:"varint,7,opt,name=matched,proto3" json:"matched,omitempty"`
+	LatencyP50 *duration.Duration `protobuf:"bytes,8,opt,name=latency_p50,json=latencyP50,proto3" json:"latency_p50,omitempty"`
+	LatencyP90 *duration.Duration `protobuf:"bytes,9,opt,name=latency_p90,json=latencyP90,proto3" json:"latency_p90,omitempty"`
+	LatencyP99 *duration.Duration `protobuf:"bytes,10,opt,name=latency_p99,json=latencyP99,proto3" json:"latency_p99,omitempty"`
+	// Whether the aggregator received all the records, in the last report.
+	Complete             bool     `protobuf:"varint,11,opt,name=complete,proto3" json:"complete,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Progress) Reset()         { *m = Progress{} }
+func (m *Progress) String() string { return proto.CompactTextString(m) }
+func (*Progress) ProtoMessage()    {}
+func (*Progress) Descriptor() ([]byte, []int) {
+	return fileDescriptor_de3fba9d879b76ae, []int{16}
+}
+
+func (m *Progress) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Progress.Unmarshal(m, b)
+}
+func (m *Progress) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Progress.Marshal(b, m, deterministic)
+}
+func (m *Progress) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Progress.Merge(m, src)
+}
+func (m *Progress) XXX_Size() int {
+	return xxx_messageInfo_Progress.Size(m)
+}
+func (m *Progress) XXX_DiscardUnknown() {
+	xxx_messageInfo_Progress.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Progress proto.InternalMessageInfo
+
+func (m *Progress) GetAt() *timestamp.Timestamp {
+	if m != nil {
+		return m.At
+	}
+	return nil
+}
+
+func (m *Progress) GetRecordsReceived() uint64 {
+	if m != nil {
+		return m.RecordsReceived
+	}
+	return 0
+}
+
+func (m *Progress) GetRecordsExpected() uint64 {
+	if m != nil {
+		return m.RecordsExpected
+	}
+	return 0
+}
+
+func (m *Progress) GetSent() uint64 {
+	if m != nil {
+		return m.Sent
+	}
+	return 0
+}
+
+func (m *Progress) GetAccepted() uint64 {
+	if m != nil {
+		return m.Accepted
+	}
+	return 0
+}
+
+func (m *Progress) GetReceived() uint64 {
+	if m != nil {
+		return m.Received
+	}
+	return 0
+}
+
+func (m *Progress) GetMatched() uint64 {
+	if m != nil {
+		return m.Matched
+	}
+	return 0
+}
+
+func (m *Progress) GetLatencyP50() *duration.Duration {
+	if m != nil {
+		return m.LatencyP50
+	}
+	return nil
+}
+
+func (m *Progress) GetLatencyP90() *duration.Duration {
+	if m != nil {
+		return m.LatencyP90
+	}
+	return nil
+}
+
+func (m *Progress) GetLatencyP99() *duration.Duration {
+	if m != nil {
+		return m.LatencyP99
+	}
+	return nil
+}
+
+func (m *Progress) GetComplete() bool {
+	if m != nil {
+		return m.Complete
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterEnum("event_state.EventsRecord_Type", EventsRecord_Type_name, EventsRecord_Type_value)
+	proto.RegisterEnum("event_state.WindowRequest_Edge", WindowRequest_Edge_name, WindowRequest_Edge_value)
+	proto.RegisterType((*EventsRecord)(nil), "event_state.EventsRecord")
+	proto.RegisterMapType((map[string]uint32)(nil), "event_state.EventsRecord.AttemptsEntry")
+	proto.RegisterMapType((map[string]bool)(nil), "event_state.EventsRecord.ChecksumMismatchesEntry")
+	proto.RegisterMapType((map[string]uint32)(nil), "event_state.EventsRecord.ContentHashesEntry")
+	proto.RegisterMapType((map[string]*timestamp.Timestamp)(nil), "event_state.EventsRecord.EventsEntry")
+	proto.RegisterMapType((map[string]int64)(nil), "event_state.EventsRecord.MonotonicEntry")
+	proto.RegisterMapType((map[string]*timestamp.Timestamp)(nil), "event_state.EventsRecord.OnWireEntry")
+	proto.RegisterMapType((map[string]uint32)(nil), "event_state.EventsRecord.PayloadSizesEntry")
+	proto.RegisterMapType((map[string]*EventKey)(nil), "event_state.EventsRecord.SequencesEntry")
+	proto.RegisterType((*EventKey)(nil), "event_state.EventKey")
+	proto.RegisterType((*KeyedEvent)(nil), "event_state.KeyedEvent")
+	proto.RegisterType((*EventsRecordList)(nil), "event_state.EventsRecordList")
+	proto.RegisterType((*RecordReply)(nil), "event_state.RecordReply")
+	proto.RegisterType((*FinishRequest)(nil), "event_state.FinishRequest")
+	proto.RegisterType((*FinishReply)(nil), "event_state.FinishReply")
+	proto.RegisterType((*WindowRequest)(nil), "event_state.WindowRequest")
+	proto.RegisterType((*WindowReply)(nil), "event_state.WindowReply")
+	proto.RegisterType((*RegisterRequest)(nil), "event_state.RegisterRequest")
+	proto.RegisterType((*RegisterReply)(nil), "event_state.RegisterReply")
+	proto.RegisterType((*StreamRequest)(nil), "event_state.StreamRequest")
+	proto.RegisterType((*LatencySample)(nil), "event_state.LatencySample")
+	proto.RegisterType((*ClockPing)(nil), "event_state.ClockPing")
+	proto.RegisterType((*ClockPong)(nil), "event_state.ClockPong")
+	proto.RegisterType((*ProgressRequest)(nil), "event_state.ProgressRequest")
+	proto.RegisterType((*Progress)(nil), "event_state.Progress")
+}
+
+func init() { proto.RegisterFile("event_state.proto", fileDescriptor_de3fba9d879b76ae) }
+
+var fileDescriptor_de3fba9d879b76ae = []byte{
+	// 1370 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x5b, 0x6e, 0xdb, 0x46,
+	0x17, 0x0e, 0x25, 0xda, 0x96, 0x8e, 0xae, 0x99, 0xdc, 0x18, 0x22, 0x37, 0xf0, 0xc7, 0x0f, 0x3b,
+	0x4d, 0xa1, 0x38, 0x0e, 0x02, 0xc4, 0x69, 0xd3, 0xc6, 0x95, 0x15, 0xd4, 0x48, 0xec, 0xa8, 0x94,
+	0xd2, 0x3c, 0xaa, 0x63, 0x72, 0x22, 0x0f, 0x24, 0x91, 0x0c, 0x67, 0xe4, 0x54, 0x79, 0xeb, 0x16,
+	0xba, 0x80, 0x2e, 0xa6, 0x0f, 0xdd, 0x44, 0x5f, 0xba, 0x94, 0x62, 0x2e, 0xa4, 0x48, 0xd9, 0xaa,
+	0x1c, 0xb4, 0x6f, 0x9c, 0x73, 0xbe, 0xf3, 0xcd, 0xb9, 0x0f, 0xe1, 0x32, 0x39, 0x25, 0x01, 0x1f,
+	0x30, 0x8e, 0x39, 0x69, 0x45, 0x71, 0xc8, 0x43, 0x54, 0xc9, 0x88, 0xec, 0x3b, 0xc3, 0x30, 0x1c,
+	0x8e, 0xc9, 0x43, 0xa9, 0x3a, 0x9e, 0xbe, 0x7f, 0xe8, 0x4f, 0x63, 0xcc, 0x69, 0x18, 0x28, 0xb0,
+	0x7d, 0x77, 0x51, 0xcf, 0xe9, 0x84, 0x30, 0x8e, 0x27, 0x91, 0x02, 0x38, 0x7f, 0x01, 0x54, 0x3b,
+	0x82, 0x90, 0xb9, 0xc4, 0x0b, 0x63, 0x1f, 0x3d, 0x87, 0x75, 0x75, 0xb6, 0x8c, 0x7b, 0xc5, 0xad,
+	0xca, 0xce, 0xff, 0x5b, 0x59, 0x17, 0xb2, 0x50, 0x7d, 0xe8, 0x04, 0x3c, 0x9e, 0xb9, 0xda, 0x08,
+	0xed, 0x80, 0xc9, 0x67, 0x11, 0xb1, 0x0a, 0xf7, 0x8c, 0xad, 0xfa, 0xce, 0x9d, 0xe5, 0xc6, 0xfd,
+	0x59, 0x44, 0x5c, 0x89, 0x45, 0xcf, 0xa0, 0x3a, 0x22, 0x33, 0xe2, 0x0f, 0x88, 0xba, 0xb8, 0x28,
+	0x2f, 0xbe, 0x91, 0xb3, 0x7d, 0x25, 0x00, 0x92, 0xc0, 0xad, 0x8c, 0xd2, 0x6f, 0x86, 0x5e, 0x42,
+	0x79, 0x12, 0x06, 0x21, 0x0f, 0x03, 0xea, 0x59, 0xa6, 0x34, 0xdc, 0x5a, 0x7e, 0xe9, 0x61, 0x02,
+	0x55, 0x4e, 0xcf, 0x4d, 0x51, 0x0f, 0xea, 0x5e, 0x18, 0x70, 0x61, 0x77, 0x82, 0xd9, 0x09, 0x61,
+	0xd6, 0x9a, 0x24, 0xfb, 0x72, 0x39, 0x59, 0x5b, 0xe1, 0xbf, 0x97, 0x70, 0x45, 0x58, 0xf3, 0xb2,
+	0x32, 0x74, 0x0c, 0x57, 0xbc, 0x13, 0xe2, 0x8d, 0xd8, 0x74, 0x32, 0x98, 0x50, 0x36, 0xc1, 0xdc,
+	0x13, 0xcc, 0xeb, 0x92, 0xf9, 0xd1, 0x3f, 0x30, 0x6b, 0xa3, 0xc3, 0xd4, 0x46, 0xd1, 0x23, 0xef,
+	0x8c, 0x02, 0xb5, 0xa1, 0x84, 0x39, 0x27, 0x93, 0x88, 0x33, 0x6b, 0x43, 0x12, 0x6f, 0x2e, 0x27,
+	0xde, 0xd3, 0x48, 0x45, 0x97, 0x1a, 0xa2, 0x6f, 0x60, 0x23, 0x0c, 0x06, 0x1f, 0x69, 0x4c, 0xac,
+	0xd2, 0xaa, 0xaa, 0xbf, 0x09, 0xde, 0xd1, 0x98, 0xe8, 0xaa, 0x87, 0xf2, 0x20, 0xaa, 0xc0, 0xc8,
+	0x87, 0x29, 0x09, 0x3c, 0xc2, 0xac, 0xf2, 0xaa, 0x2a, 0xf4, 0x12, 0xa8, 0xae, 0x42, 0x6a, 0x8a,
+	0xba, 0x50, 0x8b, 0xf0, 0x6c, 0x1c, 0x62, 0x7f, 0xc0, 0xe8, 0x27, 0xc2, 0x2c, 0x90, 0x5c, 0x0f,
+	0x96, 0x73, 0x75, 0x15, 0xbc, 0x47, 0x3f, 0x25, 0x74, 0xd5, 0x28, 0x23, 0xb2, 0xdf, 0x42, 0x25,
+	0xd3, 0xa6, 0xa8, 0x09, 0xc5, 0x11, 0x99, 0x59, 0xc6, 0x3d, 0x63, 0xab, 0xec, 0x8a, 0x4f, 0xb4,
+	0x0d, 0x6b, 0xa7, 0x78, 0x3c, 0x55, 0x1d, 0x5b, 0xd9, 0xb1, 0x5b, 0x6a, 0x62, 0x5a, 0xc9, 0xc4,
+	0xb4, 0xfa, 0xc9, 0xc4, 0xb8, 0x0a, 0xf8, 0xac, 0xf0, 0xd4, 0xb0, 0xbf, 0x86, 0x7a, 0xbe, 0x97,
+	0xce, 0x61, 0xbe, 0x9a, 0x65, 0x2e, 0x66, 0xad, 0x5f, 0x00, 0x3a, 0xdb, 0x3c, 0xab, 0x18, 0x6a,
+	0x59, 0x86, 0x0e, 0xdc, 0x58, 0xd2, 0x24, 0xab, 0x68, 0x4a, 0x59, 0x9a, 0xaf, 0xa0, 0x96, 0x6b,
+	0x89, 0xcf, 0xf2, 0xe1, 0x2d, 0x54, 0x32, 0xbd, 0xf0, 0x9f, 0xa5, 0xb6, 0x07, 0xf5, 0x7c, 0x83,
+	0x9c, 0xc3, 0xfc, 0x20, 0xcf, 0x7c, 0xed, 0x6c, 0x7f, 0xbc, 0x22, 0xb3, 0x2c, 0xe9, 0xb7, 0x70,
+	0xf9, 0x4c, 0xa7, 0x7c, 0x4e, 0xb0, 0xce, 0x2e, 0x98, 0x62, 0x63, 0xa1, 0x0a, 0x6c, 0xbc, 0x3d,
+	0x7a, 0x75, 0xf4, 0xe6, 0xdd, 0x51, 0xf3, 0x12, 0x2a, 0x81, 0xd9, 0xeb, 0x1c, 0xf5, 0x9b, 0x06,
+	0xaa, 0x42, 0x69, 0xaf, 0xdd, 0xee, 0x74, 0xfb, 0x9d, 0xfd, 0x66, 0x41, 0x9c, 0xdc, 0x4e, 0xbb,
+	0x73, 0xf0, 0x63, 0x67, 0xbf, 0x59, 0x74, 0xf6, 0xa1, 0x94, 0xb8, 0x84, 0x6e, 0x41, 0x39, 0xc2,
+	0x31, 0xa7, 0x62, 0x45, 0xeb, 0x8b, 0xe7, 0x02, 0x64, 0x43, 0x29, 0x99, 0x05, 0xe9, 0x81, 0xe9,
+	0xa6, 0x67, 0x07, 0x03, 0xcc, 0x77, 0x20, 0xda, 0x9c, 0xbb, 0xbe, 0x34, 0x7c, 0x19, 0xd1, 0x17,
+	0x50, 0xc0, 0xfc, 0x02, 0x05, 0x28, 0x60, 0xee, 0xfc, 0x5a, 0x80, 0x66, 0x76, 0xb8, 0x5e, 0x53,
+	0xc6, 0xd1, 0x43, 0x58, 0xa3, 0x9c, 0x4c, 0x92, 0xe7, 0xe0, 0xe6, 0xd2, 0x51, 0x74, 0x15, 0x0e,
+	0x5d, 0x87, 0x75, 0x16, 0x4e, 0x63, 0x1d, 0x42, 0xd9, 0xd5, 0x27, 0xf4, 0x1c, 0xaa, 0xd1, 0xf4,
+	0x78, 0x4c, 0xd9, 0x09, 0xf1, 0x07, 0x98, 0x5b, 0xc5, 0x95, 0x3e, 0x55, 0x52, 0xfc, 0x9e, 0x88,
+	0xb8, 0x91, 0x6e, 0xeb, 0x81, 0x37, 0x0e, 0xbd, 0x91, 0x65, 0x4a, 0xfe, 0x7a, 0x2a, 0x6e, 0x0b,
+	0x29, 0xfa, 0x1f, 0xd4, 0xd8, 0xf4, 0x78, 0x42, 0x19, 0xa3, 0x61, 0x30, 0xa0, 0xbe, 0xb5, 0x26,
+	0x61, 0xd5, 0xb9, 0xf0, 0xc0, 0x17, 0x85, 0x7e, 0x4f, 0x03, 0x3c, 0xb6, 0xd6, 0xd5, 0x48, 0xc8,
+	0x03, 0x42, 0x60, 0x72, 0x3c, 0x54, 0x7b, 0xb4, 0xec, 0xca, 0x6f, 0xe7, 0x17, 0x03, 0x2a, 0x3a,
+	0x40, 0x12, 0x8d, 0x65, 0x8b, 0x78, 0xe1, 0x34, 0xe0, 0x32, 0xf7, 0x35, 0x57, 0x1d, 0xd0, 0x7d,
+	0x68, 0xe2, 0xf7, 0x9c, 0xc4, 0x03, 0x2f, 0x9c, 0x44, 0x63, 0x22, 0xcb, 0xab, 0xa6, 0xad, 0x21,
+	0xe5, 0xed, 0x54, 0x8c, 0x1e, 0xc1, 0x55, 0x7f, 0x1a, 0x8d, 0xa9, 0x87, 0x39, 0x19, 0xcc, 0x9d,
+	0x92, 0xf9, 0x28, 0xb9, 0x57, 0x52, 0x5d, 0x2f, 0x55, 0x39, 0x9b, 0x50, 0x7b, 0x49, 0x03, 0xca,
+	0x4e, 0x5c, 0xd1, 0x0d, 0x8c, 0x67, 0x72, 0x6c, 0x64, 0x73, 0xec, 0x3c, 0x85, 0x4a, 0x02, 0x14,
+	0xbe, 0x0a, 0xaf, 0xc6, 0x31, 0xc1, 0xfe, 0x2c, 0xf1, 0x4b, 0x19, 0x08, 0xaf, 0x94, 0x5c, 0xfb,
+	0x45, 0x9c, 0x3f, 0x0c, 0xa8, 0xbd, 0xa3, 0x81, 0x1f, 0x7e, 0x4c, 0xee, 0x40, 0x60, 0x8e, 0x68,
+	0xe0, 0xeb, 0x1b, 0xe4, 0x37, 0x7a, 0x0c, 0x26, 0xf1, 0x87, 0xc9, 0xeb, 0x7e, 0x37, 0xd7, 0x0b,
+	0x39, 0xeb, 0x56, 0xc7, 0x1f, 0x12, 0x57, 0x82, 0x75, 0x0b, 0x16, 0x2f, 0xd2, 0x82, 0x99, 0xc0,
+	0xcc, 0x5c, 0x60, 0x5b, 0x60, 0x0a, 0xc6, 0xfc, 0xf8, 0x95, 0x61, 0xad, 0xd7, 0xdf, 0x73, 0xc5,
+	0xfc, 0x89, 0x49, 0xec, 0xbf, 0xe9, 0x36, 0x0b, 0x4e, 0x0d, 0x2a, 0x89, 0x27, 0xd1, 0x78, 0xe6,
+	0xf4, 0xa1, 0xe1, 0x92, 0x21, 0x65, 0x9c, 0xc4, 0x2b, 0x92, 0x27, 0xb2, 0x45, 0x7e, 0x8e, 0x88,
+	0xc7, 0x89, 0x3f, 0x38, 0xd6, 0x4f, 0xb5, 0xda, 0x03, 0x8d, 0x44, 0xfe, 0x9d, 0x12, 0x3b, 0x0d,
+	0xa8, 0xcd, 0x59, 0xc5, 0x35, 0x9b, 0x50, 0xeb, 0xf1, 0x98, 0xe0, 0xc9, 0xaa, 0x0a, 0xfd, 0x66,
+	0x40, 0xed, 0x35, 0xe6, 0x24, 0xf0, 0x66, 0x3d, 0x2c, 0x72, 0x8f, 0xea, 0x50, 0xa0, 0x49, 0x96,
+	0x0b, 0xd4, 0x47, 0x2d, 0x30, 0x19, 0x09, 0x2e, 0x32, 0xb3, 0x12, 0x87, 0x1e, 0xc3, 0xc6, 0x58,
+	0x11, 0xea, 0x1c, 0xdf, 0x3c, 0x63, 0xb2, 0xaf, 0x7f, 0x0a, 0xdd, 0x04, 0x89, 0x2c, 0xd8, 0xf0,
+	0xe3, 0x30, 0x8a, 0x88, 0x2f, 0x13, 0x6d, 0xba, 0xc9, 0xd1, 0xb9, 0x0d, 0x65, 0x39, 0x47, 0x5d,
+	0x1a, 0x0c, 0xc5, 0x86, 0x64, 0xe4, 0x83, 0x74, 0xce, 0x74, 0xc5, 0xa7, 0x83, 0x13, 0x75, 0x18,
+	0x0c, 0x97, 0x66, 0x52, 0x9b, 0x15, 0x52, 0xb3, 0xcf, 0xe9, 0x01, 0xe7, 0x27, 0x68, 0x74, 0xe3,
+	0x70, 0x18, 0x13, 0xc6, 0x56, 0x95, 0xec, 0x09, 0x94, 0x68, 0xc0, 0x49, 0x7c, 0x8a, 0xc7, 0x56,
+	0x61, 0x55, 0xf0, 0x29, 0xd4, 0xf9, 0xbd, 0x08, 0xa5, 0xe4, 0x0a, 0xed, 0x9a, 0x71, 0xa1, 0xf6,
+	0xbc, 0x0f, 0xcd, 0x58, 0xee, 0x02, 0x36, 0x88, 0x89, 0x47, 0xe8, 0x29, 0xf1, 0x75, 0x94, 0x0d,
+	0x2d, 0x77, 0xb5, 0x38, 0x0b, 0x4d, 0xba, 0xc7, 0x2a, 0xe6, 0xa0, 0x1d, 0x2d, 0x16, 0x93, 0x26,
+	0x2b, 0xae, 0x2a, 0x21, 0xbf, 0xc5, 0x53, 0x80, 0x3d, 0x8f, 0x44, 0x9c, 0xa8, 0x05, 0x66, 0xba,
+	0xe9, 0x59, 0xe8, 0xd2, 0xdb, 0xd7, 0x95, 0x2e, 0x39, 0x8b, 0xc2, 0xaa, 0xbf, 0x01, 0xdf, 0xda,
+	0x50, 0x85, 0xd5, 0x47, 0xf4, 0x0c, 0x2a, 0xba, 0xfa, 0x83, 0xe8, 0xc9, 0xb6, 0x55, 0x5a, 0x95,
+	0x2e, 0xd0, 0xe8, 0xee, 0x93, 0xed, 0x9c, 0xed, 0xee, 0xb6, 0x55, 0xbe, 0xb0, 0xed, 0xee, 0x82,
+	0xed, 0xae, 0x05, 0x17, 0xb7, 0xdd, 0x15, 0x91, 0xa6, 0x8b, 0xab, 0x22, 0x17, 0x57, 0x7a, 0xde,
+	0xf9, 0xd3, 0x84, 0x7a, 0xf6, 0xfd, 0x21, 0x31, 0x3a, 0x80, 0xaa, 0xfa, 0x56, 0x72, 0x74, 0x7b,
+	0xe9, 0x63, 0x25, 0x9e, 0x36, 0xdb, 0xca, 0xa9, 0x33, 0x4b, 0xde, 0xb9, 0x84, 0x5e, 0xc0, 0xba,
+	0xda, 0xa4, 0xc8, 0xce, 0xa1, 0x72, 0x7b, 0xd8, 0xb6, 0xce, 0xd5, 0x29, 0x86, 0x7d, 0x80, 0x43,
+	0x1c, 0x8f, 0xd4, 0x32, 0x5a, 0x60, 0xc9, 0xed, 0x4a, 0xdb, 0x3a, 0x57, 0xa7, 0x58, 0x5e, 0x42,
+	0x29, 0xd9, 0x34, 0xe8, 0xd6, 0x82, 0xbf, 0xb9, 0xb5, 0x66, 0xdb, 0x4b, 0xb4, 0x8a, 0xe7, 0x10,
+	0x1a, 0x6a, 0x41, 0xa9, 0xe5, 0x43, 0x09, 0x5b, 0x70, 0x29, 0xb7, 0xbe, 0x16, 0xc8, 0x72, 0x0b,
+	0xcb, 0xb9, 0xb4, 0x6d, 0xa0, 0x1f, 0x00, 0x65, 0x33, 0xdd, 0x3e, 0x99, 0x06, 0xa3, 0x7f, 0x93,
+	0xef, 0x2d, 0x03, 0xed, 0x41, 0xb9, 0x37, 0x0b, 0xf4, 0x23, 0x7e, 0x3d, 0x07, 0x4d, 0x37, 0x8e,
+	0x7d, 0x9e, 0x9c, 0x06, 0x43, 0x41, 0xb0, 0x6d, 0xa0, 0x03, 0xa8, 0xab, 0x30, 0xd2, 0xe1, 0xce,
+	0xa7, 0x6c, 0x61, 0xad, 0xd8, 0xd7, 0xce, 0xd5, 0x8a, 0x00, 0x8f, 0xd7, 0x65, 0x63, 0x3e, 0xfe,
+	0x7b, 0x00, 0x73, 0xf8, 0x97, 0xe7, 0x81, 0x0f, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// EventsRecorderClient is the client API for EventsRecorder service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type EventsRecorderClient interface {
+	RecordEvents(ctx context.Context, in *EventsRecordList, opts ...grpc.CallOption) (*RecordReply, error)
+	// Finish tells the aggregator that no more records are coming, so that it
+	// publishes the results with the records received so far.
+	Finish(ctx context.Context, in *FinishRequest, opts ...grpc.CallOption) (*FinishReply, error)
+	// MarkWindow marks the start or the stop of a window of the run, e.g. a
+	// maintenance of the broker, whose events are excluded from the results.
+	MarkWindow(ctx context.Context, in *WindowRequest, opts ...grpc.CallOption) (*WindowReply, error)
+	// Register announces a sender or a receiver and the number of events record
+	// lists it will upload, so that the aggregator can tell which ones
+	// underdelivered, and complete once all the registered ones are done.
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterReply, error)
+	// StreamLatencies pushes the end to end latency of each event as soon as
+	// both its sent and received timestamps are recorded, until the aggregator
+	// received all the records, when it streams latencies.
+	StreamLatencies(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (EventsRecorder_StreamLatenciesClient, error)
+	// RecordEventsChunks records an events record list uploaded in chunks,
+	// each an events record list with the same items holding a part of their
+	// events, so that lists larger than the maximum message size get through.
+	RecordEventsChunks(ctx context.Context, opts ...grpc.CallOption) (EventsRecorder_RecordEventsChunksClient, error)
+	// SyncClock measures the offset of the clock of a sender or receiver from
+	// the clock of the aggregator: the worker identifies itself with a first
+	// pong, then answers each ping of the aggregator with its current time,
+	// until the aggregator ends the stream.
+	SyncClock(ctx context.Context, opts ...grpc.CallOption) (EventsRecorder_SyncClockClient, error)
+	// StreamProgress reports the progress of the run at the requested
+	// interval, with the interim counts and latencies, until the aggregator
+	// received all the records, when it sends a last complete report.
+	StreamProgress(ctx context.Context, in *ProgressRequest, opts ...grpc.CallOption) (EventsRecorder_StreamProgressClient, error)
+}
+
+type eventsRecorderClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewEventsRecorderClient(cc *grpc.ClientConn) EventsRecorderClient {
+	return &eventsRecorderClient{cc}
+}
+
+func (c *eventsRecorderClient) RecordEvents(ctx context.Context, in *EventsRecordList, opts ...grpc.CallOption) (*RecordReply, error) {
+	out := new(RecordReply)
+	err := c.cc.Invoke(ctx, "/event_state.EventsRecorder/RecordEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventsRecorderClient) Finish(ctx context.Context, in *FinishRequest, opts ...grpc.CallOption) (*FinishReply, error) {
+	out := new(FinishReply)
+	err := c.cc.Invoke(ctx, "/event_state.EventsRecorder/Finish", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventsRecorderClient) MarkWindow(ctx context.Context, in *WindowRequest, opts ...grpc.CallOption) (*WindowReply, error) {
+	out := new(WindowReply)
+	err := c.cc.Invoke(ctx, "/event_state.EventsRecorder/MarkWindow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventsRecorderClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterReply, error) {
+	out := new(RegisterReply)
+	err := c.cc.Invoke(ctx, "/event_state.EventsRecorder/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eventsRecorderClient) StreamLatencies(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (EventsRecorder_StreamLatenciesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_EventsRecorder_serviceDesc.Streams[0], "/event_state.EventsRecorder/StreamLatencies", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsRecorderStreamLatenciesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EventsRecorder_StreamLatenciesClient interface {
+	Recv() (*LatencySample, error)
+	grpc.ClientStream
+}
+
+type eventsRecorderStreamLatenciesClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsRecorderStreamLatenciesClient) Recv() (*LatencySample, error) {
+	m := new(LatencySample)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsRecorderClient) RecordEventsChunks(ctx context.Context, opts ...grpc.CallOption) (EventsRecorder_RecordEventsChunksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_EventsRecorder_serviceDesc.Streams[1], "/event_state.EventsRecorder/RecordEventsChunks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsRecorderRecordEventsChunksClient{stream}
+	return x, nil
+}
+
+type EventsRecorder_RecordEventsChunksClient interface {
+	Send(*EventsRecordList) error
+	CloseAndRecv() (*RecordReply, error)
+	grpc.ClientStream
+}
+
+type eventsRecorderRecordEventsChunksClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsRecorderRecordEventsChunksClient) Send(m *EventsRecordList) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *eventsRecorderRecordEventsChunksClient) CloseAndRecv() (*RecordReply, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(RecordReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsRecorderClient) SyncClock(ctx context.Context, opts ...grpc.CallOption) (EventsRecorder_SyncClockClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_EventsRecorder_serviceDesc.Streams[2], "/event_state.EventsRecorder/SyncClock", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsRecorderSyncClockClient{stream}
+	return x, nil
+}
+
+type EventsRecorder_SyncClockClient interface {
+	Send(*ClockPong) error
+	Recv() (*ClockPing, error)
+	grpc.ClientStream
+}
+
+type eventsRecorderSyncClockClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsRecorderSyncClockClient) Send(m *ClockPong) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *eventsRecorderSyncClockClient) Recv() (*ClockPing, error) {
+	m := new(ClockPing)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsRecorderClient) StreamProgress(ctx context.Context, in *ProgressRequest, opts ...grpc.CallOption) (EventsRecorder_StreamProgressClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_EventsRecorder_serviceDesc.Streams[3], "/event_state.EventsRecorder/StreamProgress", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsRecorderStreamProgressClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EventsRecorder_StreamProgressClient interface {
+	Recv() (*Progress, error)
+	grpc.ClientStream
+}
+
+type eventsRecorderStreamProgressClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsRecorderStreamProgressClient) Recv() (*Progress, error) {
+	m := new(Progress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EventsRecorderServer is the server API for EventsRecorder service.
+type EventsRecorderServer interface {
+	RecordEvents(context.Context, *EventsRecordList) (*RecordReply, error)
+	// Finish tells the aggregator that no more records are coming, so that it
+	// publishes the results with the records received so far.
+	Finish(context.Context, *FinishRequest) (*FinishReply, error)
+	// MarkWindow marks the start or the stop of a window of the run, e.g. a
+	// maintenance of the broker, whose events are excluded from the results.
+	MarkWindow(context.Context, *WindowRequest) (*WindowReply, error)
+	// Register announces a sender or a receiver and the number of events record
+	// lists it will upload, so that the aggregator can tell which ones
+	// underdelivered, and complete once all the registered ones are done.
+	Register(context.Context, *RegisterRequest) (*RegisterReply, error)
+	// StreamLatencies pushes the end to end latency of each event as soon as
+	// both its sent and received timestamps are recorded, until the aggregator
+	// received all the records, when it streams latencies.
+	StreamLatencies(*StreamRequest, EventsRecorder_StreamLatenciesServer) error
+	// RecordEventsChunks records an events record list uploaded in chunks,
+	// each an events record list with the same items holding a part of their
+	// events, so that lists larger than the maximum message size get through.
+	RecordEventsChunks(EventsRecorder_RecordEventsChunksServer) error
+	// SyncClock measures the offset of the clock of a sender or receiver from
+	// the clock of the aggregator: the worker identifies itself with a first
+	// pong, then answers each ping of the aggregator with its current time,
+	// until the aggregator ends the stream.
+	SyncClock(EventsRecorder_SyncClockServer) error
+	// StreamProgress reports the progress of the run at the requested
+	// interval, with the interim counts and latencies, until the aggregator
+	// received all the records, when it sends a last complete report.
+	StreamProgress(*ProgressRequest, EventsRecorder_StreamProgressServer) error
+}
+
+// UnimplementedEventsRecorderServer can be embedded to have forward compatible implementations.
+type UnimplementedEventsRecorderServer struct {
+}
+
+func (*UnimplementedEventsRecorderServer) RecordEvents(ctx context.Context, req *EventsRecordList) (*RecordReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordEvents not implemented")
+}
+func (*UnimplementedEventsRecorderServer) Finish(ctx context.Context, req *FinishRequest) (*FinishReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Finish not implemented")
+}
+func (*UnimplementedEventsRecorderServer) MarkWindow(ctx context.Context, req *WindowRequest) (*WindowReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkWindow not implemented")
+}
+func (*UnimplementedEventsRecorderServer) Register(ctx context.Context, req *RegisterRequest) (*RegisterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (*UnimplementedEventsRecorderServer) StreamLatencies(req *StreamRequest, srv EventsRecorder_StreamLatenciesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLatencies not implemented")
+}
+func (*UnimplementedEventsRecorderServer) RecordEventsChunks(srv EventsRecorder_RecordEventsChunksServer) error {
+	return status.Errorf(codes.Unimplemented, "method RecordEventsChunks not implemented")
+}
+func (*UnimplementedEventsRecorderServer) SyncClock(srv EventsRecorder_SyncClockServer) error {
+	return status.Errorf(codes.Unimplemented, "method SyncClock not implemented")
+}
+func (*UnimplementedEventsRecorderServer) StreamProgress(req *ProgressRequest, srv EventsRecorder_StreamProgressServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamProgress not implemented")
+}
+
+func RegisterEventsRecorderServer(s *grpc.Server, srv EventsRecorderServer) {
+	s.RegisterService(&_EventsRecorder_serviceDesc, srv)
+}
+
+func _EventsRecorder_RecordEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EventsRecordList)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventsRecorderServer).RecordEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event_state.EventsRecorder/RecordEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventsRecorderServer).RecordEvents(ctx, req.(*EventsRecordList))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventsRecorder_Finish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventsRecorderServer).Finish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event_state.EventsRecorder/Finish",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventsRecorderServer).Finish(ctx, req.(*FinishRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventsRecorder_MarkWindow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WindowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventsRecorderServer).MarkWindow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event_state.EventsRecorder/MarkWindow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventsRecorderServer).MarkWindow(ctx, req.(*WindowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventsRecorder_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventsRecorderServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event_state.EventsRecorder/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventsRecorderServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EventsRecorder_StreamLatencies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsRecorderServer).StreamLatencies(m, &eventsRecorderStreamLatenciesServer{stream})
+}
+
+type EventsRecorder_StreamLatenciesServer interface {
+	Send(*LatencySample) error
+	grpc.ServerStream
+}
+
+type eventsRecorderStreamLatenciesServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsRecorderStreamLatenciesServer) Send(m *LatencySample) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _EventsRecorder_RecordEventsChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EventsRecorderServer).RecordEventsChunks(&eventsRecorderRecordEventsChunksServer{stream})
+}
+
+type EventsRecorder_RecordEventsChunksServer interface {
+	SendAndClose(*RecordReply) error
+	Recv() (*EventsRecordList, error)
+	grpc.ServerStream
+}
+
+type eventsRecorderRecordEventsChunksServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsRecorderRecordEventsChunksServer) SendAndClose(m *RecordReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *eventsRecorderRecordEventsChunksServer) Recv() (*EventsRecordList, error) {
+	m := new(EventsRecordList)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _EventsRecorder_SyncClock_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EventsRecorderServer).SyncClock(&eventsRecorderSyncClockServer{stream})
+}
+
+type EventsRecorder_SyncClockServer interface {
+	Send(*ClockPing) error
+	Recv() (*ClockPong, error)
+	grpc.ServerStream
+}
+
+type eventsRecorderSyncClockServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsRecorderSyncClockServer) Send(m *ClockPing) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *eventsRecorderSyncClockServer) Recv() (*ClockPong, error) {
+	m := new(ClockPong)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _EventsRecorder_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProgressRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsRecorderServer).StreamProgress(m, &eventsRecorderStreamProgressServer{stream})
+}
+
+type EventsRecorder_StreamProgressServer interface {
+	Send(*Progress) error
+	grpc.ServerStream
+}
+
+type eventsRecorderStreamProgressServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsRecorderStreamProgressServer) Send(m *Progress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _EventsRecorder_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "event_state.EventsRecorder",
+	HandlerType: (*EventsRecorderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RecordEvents",
+			Handler:    _EventsRecorder_RecordEvents_Handler,
+		},
+		{
+			MethodName: "Finish",
+			Handler:    _EventsRecorder_Finish_Handler,
+		},
+		{
+			MethodName: "MarkWindow",
+			Handler:    _EventsRecorder_MarkWindow_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _EventsRecorder_Register_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLatencies",
+			Handler:       _EventsRecorder_StreamLatencies_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RecordEventsChunks",
+			Handler:       _EventsRecorder_RecordEventsChunks_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SyncClock",
+			Handler:       _EventsRecorder_SyncClock_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _EventsRecorder_StreamProgress_Handler,
+			ServerStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "event_state.proto",
 }
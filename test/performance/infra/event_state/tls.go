@@ -0,0 +1,32 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event_state
+
+import (
+	"crypto/tls"
+
+	"knative.dev/eventing/test/performance/infra/common"
+)
+
+// ClientTLSConfig returns the TLS configuration of the connection to the
+// aggregator, verifying its certificate against the CAs of the caFile, or
+// the system ones if it's empty, and presenting the client certificate of
+// certFile and keyFile, if set, for mutual TLS. It returns nil if none of the
+// files is set, to connect in plaintext.
+func ClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	return common.ClientTLSConfig("aggregator", caFile, certFile, keyFile)
+}
@@ -18,17 +18,21 @@ package infra
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"knative.dev/pkg/signals"
 	pkgtest "knative.dev/pkg/test"
 
 	"knative.dev/eventing/test/performance/infra/aggregator"
 	"knative.dev/eventing/test/performance/infra/common"
+	pb "knative.dev/eventing/test/performance/infra/event_state"
 	"knative.dev/eventing/test/performance/infra/receiver"
 	"knative.dev/eventing/test/performance/infra/sender"
 )
@@ -41,15 +45,131 @@ var (
 	// role=sender
 	aggregAddr    string
 	msgSize       uint
+	payloadSize   string
 	paceFlag      string
+	profileFlag   string
 	warmupSeconds uint
 	fixedBody     bool
+	markWarmup    time.Duration
+	markCooldown  time.Duration
+
+	// role=sender or receiver, to connect to the aggregator over TLS
+	aggregatorCAFile   string
+	aggregatorCertFile string
+	aggregatorKeyFile  string
+	// role=sender or receiver, to upload the events records
+	aggregatorGzip      bool
+	aggregatorChunkSize int
+	// role=sender or receiver, to register and sync the clock with the aggregator
+	aggregatorRegister  bool
+	aggregatorSyncClock bool
+
+	// role=receiver, to receive the events over HTTPS
+	receiverCertFile     string
+	receiverKeyFile      string
+	receiverClientCAFile string
 
 	// role=aggregator
 	expectRecords uint
+	expectSenders int
+	linger        time.Duration
 	listenAddr    string
 	makoTags      string
 	publish       bool
+
+	excludeWarmup         time.Duration
+	excludeCooldown       time.Duration
+	reportWorstSender     bool
+	reportQueueingDelay   bool
+	reportPhaseTable      bool
+	reportSenderTable     bool
+	detectBimodality      bool
+	reportGoodput         bool
+	checkTeardown         bool
+	maxRecvMsgSize        int
+	compositeKeys         bool
+	sqlitePath            string
+	heatmapPath           string
+	ndjsonPath            string
+	csvPath               string
+	parquetPath           string
+	duplicatesPath        string
+	failedEventsPath      string
+	heatmapWindow         time.Duration
+	plotDataPath          string
+	plotDataWindow        time.Duration
+	clockSkewCases        int
+	minSamples            int
+	bootstrapResamples    int
+	runID                 string
+	acceptanceWindow      time.Duration
+	anomalyWindow         time.Duration
+	anomalyBaseline       int
+	anomalyFactor         float64
+	reportMemory          bool
+	anchorOnArrival       bool
+	clockSyncRounds       int
+	duplicateRateInterval time.Duration
+	tlsCertFile           string
+	tlsKeyFile            string
+	tlsClientCAFile       string
+	receivedCollapse      string
+	submissionTTL         time.Duration
+	throughputMode        string
+	throughputLookback    time.Duration
+	recordDelay           time.Duration
+	detectSequenceGaps    bool
+	verifyOrdering        bool
+	sequenceIDPattern     string
+	statsdAddress         string
+	statsdPrefix          string
+	webhookURL            string
+	exporter              string
+	exporterOutput        string
+	highPercentiles       string
+	ewmaDecay             float64
+	scenario              string
+	scenarioSuffixKeys    bool
+	skipDegenerate        bool
+	unmatchedTTL          time.Duration
+	recordsTimeout        time.Duration
+	checkpointPath        string
+	timelinePath          string
+	percentileSampleCap   int
+	cdfPath               string
+	hdrHistogramPath      string
+	referenceCDFPath      string
+	referenceCDFTolerance float64
+	streamLatencies       bool
+	liveMetricsAddr       string
+	streamingAggregation  bool
+	latencyStreamBuffer   int
+	reportRecorderTime    bool
+	reportSenderGini      bool
+	shardBreakdown        bool
+	eventDeadline         time.Duration
+	latencySamplesPath    string
+	periodicityWindow     time.Duration
+	summaryPath           string
+	throughputSeriesPath  string
+	recordBarrier         bool
+	shortSummaryFormat    string
+	shortSummaryPath      string
+	timeWeighted          bool
+	gracefulStopTimeout   time.Duration
+	outputDir             string
+	duplicateBatchWindow  time.Duration
+	timeOfDayBucket       time.Duration
+	timeOfDayPeriod       time.Duration
+	reportSenderQueueing  bool
+	assertions            string
+	maxP50Latency         time.Duration
+	maxP99Latency         time.Duration
+	maxErrorRate          float64
+
+	stabilityLossWeight       float64
+	stabilityLatencyWeight    float64
+	stabilityThroughputWeight float64
 )
 
 const (
@@ -62,18 +182,126 @@ func DeclareFlags() {
 
 	// receiver & sender flags
 	flag.StringVar(&paceFlag, "pace", "", "Pace array comma separated. Format rps[:duration=10s]. Example 100,200:4,100:1,500:60")
+	flag.StringVar(&profileFlag, "profile", "", "Load profile shaping the rate of a single pace, instead of --pace. Format shape:params with durations in seconds: ramp:from:to:duration, step:from:step:every:duration, sine:mean:amplitude:period:duration or burst:base:peak:every:length:duration. Example ramp:100:1000:60")
 
 	// sender flags
 	flag.StringVar(&aggregAddr, "aggregator", "", "The aggregator address for sending events records.")
 	flag.UintVar(&msgSize, "msg-size", 100, "The size in bytes of each message we want to send. Generate random strings to avoid caching.")
 	flag.UintVar(&warmupSeconds, "warmup", 10, "Duration in seconds of warmup phase. During warmup latencies are not recorded. 0 means no warmup")
+	flag.StringVar(&payloadSize, "payload-size", "", "Distribution of the sizes in bytes of the payloads, instead of --msg-size, recorded for the aggregator to break the latencies down by size when they vary. Format fixed:size, uniform:min:max or lognormal:median:sigma:max. Example lognormal:1024:0.8:65536")
 	flag.BoolVar(&fixedBody, "generate-payload-on-each-request", true, "Produce unique body contents for each call")
+	flag.StringVar(&aggregatorCAFile, "aggregator-ca", "", "If set, upload the events records to the aggregator over TLS, verifying its certificate against the PEM CAs of this file.")
+	flag.StringVar(&aggregatorCertFile, "aggregator-cert", "", "If set, present this PEM client certificate to the aggregator, for mutual TLS.")
+	flag.StringVar(&aggregatorKeyFile, "aggregator-key", "", "PEM key of the --aggregator-cert certificate.")
+	flag.BoolVar(&aggregatorGzip, "aggregator-gzip", false, "Compress the events records uploaded to the aggregator with gzip.")
+	flag.IntVar(&aggregatorChunkSize, "aggregator-chunk-size", 256*1024*1024, "Upload the events records larger than this size in bytes to the aggregator in chunks of about this size, below its --max-recv-msg-size. 0 uploads them in a single message.")
+	flag.BoolVar(&aggregatorRegister, "aggregator-register", false, "Register with the aggregator before the benchmark, announcing the uploads of events records, for it to report the senders and receivers which underdelivered. Requires POD_NAME on the receivers.")
+	flag.BoolVar(&aggregatorSyncClock, "aggregator-sync-clock", false, "Answer the pings of the aggregator measuring the offset of the clock before the benchmark, for it to correct the clock skew with --clock-sync-rounds. Requires POD_NAME on the receivers.")
+	flag.StringVar(&receiverCertFile, "receiver-tls-cert", "", "If set, receive the events over HTTPS with this PEM certificate, for TLS-enabled brokers and channels.")
+	flag.StringVar(&receiverKeyFile, "receiver-tls-key", "", "PEM key of the --receiver-tls-cert certificate.")
+	flag.StringVar(&receiverClientCAFile, "receiver-tls-client-ca", "", "If set, require the SUT to present a client certificate signed by one of the PEM CAs of this file to deliver the events.")
+	flag.DurationVar(&markWarmup, "mark-warmup", 0, "If set, mark the first duration of the benchmark as a warmup window, whose events the aggregator excludes from the latencies and throughputs.")
+	flag.DurationVar(&markCooldown, "mark-cooldown", 0, "If set, mark the last duration of the benchmark as a cooldown window, whose events the aggregator excludes from the latencies and throughputs.")
 
 	// aggregator flags
 	flag.StringVar(&listenAddr, "listen-address", ":10000", "Network address the aggregator listens on.")
 	flag.UintVar(&expectRecords, "expect-records", 2, "Number of expected events records before aggregating data.")
+	flag.IntVar(&expectSenders, "expect-senders", 0, "If set, warn when the number of distinct senders of the events records differs from it.")
+	flag.DurationVar(&excludeWarmup, "exclude-warmup", 0, "If set, exclude the events sent during this first duration of the run from the latencies and throughputs.")
+	flag.DurationVar(&excludeCooldown, "exclude-cooldown", 0, "If set, exclude the events sent during this last duration of the run from the latencies and throughputs.")
+	flag.DurationVar(&linger, "linger", 0, "How long to keep accepting the late events records once all the expected ones have been received, replying that they are not counted.")
+	flag.DurationVar(&gracefulStopTimeout, "graceful-stop-timeout", 30*time.Second, "How long to wait for the events records uploads in flight when stopping the server, before stopping it forcibly. 0 waits indefinitely.")
+	flag.StringVar(&receivedCollapse, "received-collapse", "", "How to collapse the multiple received timestamps of an event: first, last or all. By default the duplicates are dropped.")
+	flag.DurationVar(&submissionTTL, "submission-ttl", 10*time.Minute, "How long to remember the IDs of the submissions of events records, to record the retries of an upload once.")
+	flag.DurationVar(&duplicateBatchWindow, "duplicate-batch-window", 0, "If set, how long to remember the events records uploads, to ignore the ones whose records are identical to an upload received meanwhile.")
+	flag.StringVar(&throughputMode, "throughput-mode", "", "If set, compute the published throughputs as a rolling average (rolling) or an exponentially weighted average (ewma) over --throughput-lookback instead of the number of events during the preceding second.")
+	flag.DurationVar(&throughputLookback, "throughput-lookback", 5*time.Second, "Lookback of the rolling average, or time constant of the exponentially weighted average, of the throughputs.")
+	flag.BoolVar(&timeWeighted, "time-weighted-percentiles", false, "Report the end to end latency percentiles with each event weighted by the inverse of the send throughput when it was sent, so that bursts don't dominate them.")
+	flag.DurationVar(&recordDelay, "record-delay", 0, "If set, delay the handling of each events records upload by this duration, to simulate a slow aggregator.")
+	flag.BoolVar(&recordBarrier, "record-barrier", false, "Wait for all the events records uploads in flight at the shutdown to be recorded before computing the results.")
+	flag.BoolVar(&detectSequenceGaps, "detect-sequence-gaps", false, "Report the sequence numbers missing from the IDs of the events, for senders using sequential IDs.")
+	flag.BoolVar(&verifyOrdering, "verify-ordering", false, "Verify that the events numbered within partitions by their sender are received in order, publishing the number of out of order deliveries.")
+	flag.StringVar(&sequenceIDPattern, "sequence-id-pattern", "", "Regular expression matching the sequential event IDs, with a \"sequence\" named group and optionally a \"partition\" one. By default, a sequence number optionally prefixed by a partition and a dash.")
+	flag.StringVar(&statsdAddress, "statsd-address", "", "If set, emit the counts, rates and latency percentiles of the run as statsd metrics to this UDP address.")
+	flag.StringVar(&statsdPrefix, "statsd-prefix", "eventing.performance.", "Prefix of the names of the statsd metrics.")
+	flag.StringVar(&webhookURL, "webhook-url", "", "If set, post the summary of the run and whether it passed as JSON to this URL once done.")
+	flag.StringVar(&shortSummaryFormat, "short-summary-format", aggregator.DefaultShortSummaryFormat, "Comma-separated fields of the one-line summary of the run, each optionally followed by a colon and its unit: pNN (ms, s or us), loss (% or ratio), thrpt (/s, k/s or M/s), sent, received and status.")
+	flag.StringVar(&shortSummaryPath, "short-summary-path", "", "If set, write the one-line summary of the run to this path, e.g. to post it as a commit status.")
+	flag.StringVar(&assertions, "assertions", "", "Comma separated list of conditions on the aggregates which must hold for the run to pass, e.g. \"dl-p99 < 0.05,dlr <= 0.001\", failing the aggregator otherwise.")
+	flag.DurationVar(&maxP50Latency, "max-p50-latency", 0, "If set, fail the run if the median end to end latency exceeds it.")
+	flag.DurationVar(&maxP99Latency, "max-p99-latency", 0, "If set, fail the run if the p99 end to end latency exceeds it.")
+	flag.Float64Var(&maxErrorRate, "max-error-rate", -1, "If not negative, fail the run if the ratio of the sent events which failed to be published or delivered exceeds it.")
+	flag.StringVar(&highPercentiles, "high-percentiles", "", "Comma separated list of additional percentiles of the end to end latencies to publish, e.g. 99.9,99.99.")
+	flag.Float64Var(&ewmaDecay, "ewma-decay", 0, "If set, also publish the exponentially weighted averages of the throughputs, keeping this fraction of the rate estimate after one second.")
+	flag.StringVar(&scenario, "scenario", "", "Label of the scenario of this aggregator, added as a Mako tag and included in the exports and the summary.")
+	flag.BoolVar(&scenarioSuffixKeys, "scenario-suffix-keys", false, "Suffix the scenario to the published value keys, which the benchmark config must declare.")
+	flag.BoolVar(&skipDegenerate, "skip-degenerate-latencies", false, "Skip publishing the latencies of the runs without any delivered event, flagging them with the degenerate aggregate instead.")
+	flag.DurationVar(&recordsTimeout, "records-timeout", 0, "If set, compute the results with the events records received so far once this duration has passed without all the expected ones, publishing the number of missing records.")
+	flag.StringVar(&checkpointPath, "checkpoint-path", "", "If set, checkpoint the events records received by the aggregator to this file, e.g. on a mounted volume, to resume the run from it after a restart.")
+	flag.DurationVar(&unmatchedTTL, "unmatched-ttl", 0, "If set, expire the accepted and received events still without sent event after this duration, counting them as abandoned. With --streaming-aggregation, the sent events not accepted or received after it are also folded as failed.")
+	flag.StringVar(&timelinePath, "timeline-path", "", "If set, write the steps and failures of all the events as a single time-sorted NDJSON stream to this path.")
+	flag.IntVar(&percentileSampleCap, "percentile-sample-cap", 0, "If positive, compute the latencies over the first N delivered events by sent time only, for comparing the percentiles of runs of different lengths.")
+	flag.StringVar(&cdfPath, "cdf-path", "", "If set, write the end to end latency CDF of the run to this path.")
+	flag.StringVar(&hdrHistogramPath, "hdr-histogram-path", "", "If set, write the percentile distribution of the end to end latencies of the run to this path in the HdrHistogram text format.")
+	flag.StringVar(&latencySamplesPath, "latency-samples-path", "", "If set, dump the publish and end to end latencies as raw little-endian int64 nanoseconds to this path.")
+	flag.StringVar(&summaryPath, "summary-path", "", "If set, write the summary of the run as JSON to this path, e.g. to merge the results of several aggregator shards.")
+	flag.StringVar(&referenceCDFPath, "reference-cdf-path", "", "If set, compare the end to end latencies with the CDF of a reference run at this path, as written with --cdf-path.")
+	flag.Float64Var(&referenceCDFTolerance, "reference-cdf-tolerance", 0.1, "Relative delta against the reference CDF above which a percentile is flagged as regressed or improved.")
+	flag.BoolVar(&streamLatencies, "stream-latencies", false, "If set, stream the end to end latency of each event to the subscribers of the StreamLatencies RPC as soon as it's matched.")
+	flag.IntVar(&latencyStreamBuffer, "stream-latencies-buffer", 1024, "Number of latency samples buffered for each subscriber, beyond which they are dropped.")
+	flag.StringVar(&liveMetricsAddr, "metrics-address", "", "If set, serve the counts and latencies of the events recorded so far in the Prometheus format at /metrics on this network address.")
+	flag.BoolVar(&streamingAggregation, "streaming-aggregation", false, "If set, fold the events into statistics of a fixed size as soon as they are recorded as sent, accepted and received, bounding the memory of long runs, at the cost of the reports and exports of the individual events.")
+	flag.DurationVar(&eventDeadline, "event-deadline", 0, "If positive, count the events delivered more than this duration after being sent as deadline misses, and publish the on-time rate.")
+	flag.DurationVar(&periodicityWindow, "periodicity-window", 0, "If positive, look for a periodic component in the p99 end to end latencies by window of this duration.")
+	flag.StringVar(&outputDir, "output-dir", "", "If set, write all the exported files whose path isn't set to this directory, with conventional names including the run ID.")
 	flag.StringVar(&makoTags, "mako-tags", "", "Comma separated list of benchmark specific Mako tags.")
 	flag.BoolVar(&publish, "publish", true, "Publish the results to mako-stub (default true)")
+	flag.StringVar(&exporter, "exporter", aggregator.MakoExporter, "Exporter the results are published with when --publish is set: mako, influxdb to write InfluxDB line protocol to the --exporter-output file or post it to the --exporter-output URL, or file to write CSV files in the --exporter-output directory.")
+	flag.StringVar(&exporterOutput, "exporter-output", "", "Destination of the influxdb and file exporters, e.g. http://influxdb:8086/write?db=eventing.")
+	flag.BoolVar(&reportWorstSender, "report-worst-sender", false, "Report the senders with the most failures and the highest tail latency.")
+	flag.BoolVar(&reportQueueingDelay, "report-queueing-delay", false, "Report the distribution of the delays between events being accepted and received.")
+	flag.BoolVar(&reportSenderQueueing, "report-sender-queueing", false, "Report the distributions of the delays the events waited in their senders before going on the wire, and of the end to end latencies net of them.")
+	flag.BoolVar(&reportRecorderTime, "report-recorder-time", false, "Report the distribution of the times the aggregator spends merging each events record list.")
+	flag.BoolVar(&reportSenderTable, "report-sender-table", false, "Report a table breaking down the counts, loss rate and end to end latencies by sender.")
+	flag.BoolVar(&reportSenderGini, "report-sender-gini", false, "Report the Gini coefficient of the events sent by each sender, quantifying their load imbalance.")
+	flag.BoolVar(&shardBreakdown, "shard-breakdown", false, "Report the results of each sender shard and publish the imbalance of their throughputs and latencies.")
+	flag.BoolVar(&reportPhaseTable, "report-phase-table", false, "Report a table comparing the latency percentiles of the publish, delivery and end to end phases.")
+	flag.DurationVar(&timeOfDayBucket, "time-of-day-bucket", 0, "If set, report a table of the loss rate and end to end latencies of the events by the bucket of this duration of --time-of-day-period they were sent in, e.g. 1h for the hour of the day.")
+	flag.DurationVar(&timeOfDayPeriod, "time-of-day-period", 24*time.Hour, "Period of the time of day buckets, starting at midnight UTC for a day.")
+	flag.BoolVar(&reportGoodput, "report-goodput", false, "Publish the throughput of the delivered events by their sent time, next to the send throughput.")
+	flag.BoolVar(&detectBimodality, "detect-bimodality", false, "Detect bimodal end to end latency distributions and publish their two modes.")
+	flag.IntVar(&maxRecvMsgSize, "max-recv-msg-size", 1024*1024*1024, "Maximum size in bytes of the events records the aggregator accepts from a single sender or receiver.")
+	flag.BoolVar(&compositeKeys, "match-composite-keys", false, "Match events on their (partition, sequence) composite key rather than on their ID.")
+	flag.StringVar(&sqlitePath, "sqlite-path", "", "If set, write the joined events to an indexed events table of a SQLite database created at this path.")
+	flag.StringVar(&ndjsonPath, "ndjson-path", "", `If set, stream the joined events as newline-delimited JSON to this path, "-" for the standard output.`)
+	flag.StringVar(&duplicatesPath, "duplicates-path", "", "If set, write each delivery of the events received several times as CSV to this path.")
+	flag.StringVar(&failedEventsPath, "failed-events-path", "", "If set, write the events which were not accepted or not received as CSV to this path, or log them with -.")
+	flag.StringVar(&csvPath, "csv-path", "", "If set, write the joined events with their timestamps and latencies as CSV to this path.")
+	flag.StringVar(&parquetPath, "parquet-path", "", "If set, write the joined events with their timestamps, latencies and failure flags as Parquet to this path.")
+	flag.StringVar(&heatmapPath, "heatmap-path", "", "If set, write the end to end latency histogram of each time window as JSON to this path.")
+	flag.DurationVar(&heatmapWindow, "heatmap-window", time.Second, "Duration of the time windows of the latency heatmap.")
+	flag.StringVar(&plotDataPath, "plot-data-path", "", "If set, write the throughputs, latency percentiles and loss rate of each time window as a whitespace-delimited data file to this path.")
+	flag.DurationVar(&plotDataWindow, "plot-data-window", time.Second, "Duration of the time windows of the plot data.")
+	flag.StringVar(&throughputSeriesPath, "throughput-series-path", "", "If set, write the send, accept, receive and error throughputs as a single tidy comma-separated file of timestamp, series name and value to this path.")
+	flag.IntVar(&clockSkewCases, "clock-skew-cases", 10, "Number of the worst events accepted or received before being sent to detail in the logs, for each type of records.")
+	flag.IntVar(&minSamples, "min-samples-for-percentiles", 100, "Number of delivered events below which percentiles aren't published, the run being flagged with the low-sample aggregate instead.")
+	flag.IntVar(&bootstrapResamples, "bootstrap-resamples", 0, "If set, publish the bootstrap confidence intervals of the end to end latency percentiles, estimated over this number of resamples.")
+	flag.StringVar(&runID, "run-id", "", "Run ID tagging the published results and inserted in the names of the exported files, generated by the aggregator if not set. Senders and receivers log it.")
+	flag.DurationVar(&anomalyWindow, "anomaly-window", 0, "If set, flag the windows of this duration whose p99 end to end latency exceeds the baseline of the preceding windows.")
+	flag.IntVar(&anomalyBaseline, "anomaly-baseline-windows", 10, "Number of preceding windows whose median p99 end to end latency is the baseline of the anomaly detection.")
+	flag.Float64Var(&anomalyFactor, "anomaly-factor", 2, "Factor of the baseline above which the p99 end to end latency of a window is anomalous.")
+	flag.DurationVar(&acceptanceWindow, "acceptance-rate-window", 0, "If set, publish the acceptance rate of the events sent during each window of this duration.")
+	flag.Float64Var(&stabilityLossWeight, "stability-loss-weight", 1, "Weight of the loss rate in the stability score of the run.")
+	flag.Float64Var(&stabilityLatencyWeight, "stability-latency-weight", 1, "Weight of the coefficient of variation of the end to end latency in the stability score of the run.")
+	flag.Float64Var(&stabilityThroughputWeight, "stability-throughput-weight", 1, "Weight of the coefficient of variation of the throughput in the stability score of the run.")
+	flag.BoolVar(&reportMemory, "report-memory", false, "Report the peak heap and the total GC pause time of the aggregator.")
+	flag.DurationVar(&duplicateRateInterval, "duplicate-rate-interval", 0, "If set, log the duplicate rate of each type of events at this interval while waiting for the records.")
+	flag.BoolVar(&anchorOnArrival, "anchor-on-arrival", false, "Report the end to end latencies corrected by the clock offsets of the senders and receivers, estimated from the arrival time of their records.")
+	flag.IntVar(&clockSyncRounds, "clock-sync-rounds", 0, "If positive, ping each sender and receiver this many times to measure the offset of its clock, and correct the deliver latencies by the skew between the clocks of the receiver and the sender of each event.")
+	flag.StringVar(&tlsCertFile, "tls-cert", "", "If set, serve the events records over TLS with this PEM certificate.")
+	flag.StringVar(&tlsKeyFile, "tls-key", "", "PEM key of the --tls-cert certificate.")
+	flag.StringVar(&tlsClientCAFile, "tls-client-ca", "", "If set, require the senders and receivers to present a client certificate signed by one of the PEM CAs of this file.")
+	flag.BoolVar(&checkTeardown, "check-teardown", false, "Fail if the aggregator leaks goroutines or leaves its server running once done.")
 }
 
 func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor receiver.TypeExtractor, idExtractor receiver.IdExtractor) {
@@ -84,11 +312,23 @@ func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor re
 		panic("--roles not set!")
 	}
 
+	if runID != "" {
+		log.Printf("==== Run ID: %s ====", runID)
+	}
+
 	var execs []common.Executor
 
+	// nil to connect to the aggregator in plaintext
+	aggregatorTLS, err := pb.ClientTLSConfig(aggregatorCAFile, aggregatorCertFile, aggregatorKeyFile)
+	if err != nil {
+		panic(err)
+	}
+	upload := pb.UploadOptions{Compress: aggregatorGzip, ChunkSize: aggregatorChunkSize}
+	handshake := pb.HandshakeOptions{Register: aggregatorRegister, SyncClock: aggregatorSyncClock}
+
 	if strings.Contains(roles, "receiver") {
-		if paceFlag == "" {
-			panic("--pace not set!")
+		if paceFlag == "" && profileFlag == "" {
+			panic("--pace or --profile not set!")
 		}
 		if aggregAddr == "" {
 			panic("--aggregator not set!")
@@ -96,7 +336,18 @@ func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor re
 
 		log.Println("Creating a receiver")
 
-		receiver, err := receiver.NewReceiver(paceFlag, aggregAddr, warmupSeconds, typeExtractor, idExtractor)
+		// nil to receive the events over plain HTTP
+		var receiverTLS *tls.Config
+		if receiverCertFile != "" || receiverKeyFile != "" {
+			receiverTLS, err = common.ServerTLSConfig(receiverCertFile, receiverKeyFile, receiverClientCAFile)
+			if err != nil {
+				panic(err)
+			}
+		} else if receiverClientCAFile != "" {
+			panic("--receiver-tls-client-ca requires --receiver-tls-cert and --receiver-tls-key")
+		}
+
+		receiver, err := receiver.NewReceiver(paceFlag, profileFlag, aggregAddr, warmupSeconds, typeExtractor, idExtractor, aggregatorTLS, upload, handshake, receiverTLS)
 		if err != nil {
 			panic(err)
 		}
@@ -105,8 +356,8 @@ func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor re
 	}
 
 	if strings.Contains(roles, "sender") {
-		if paceFlag == "" {
-			panic("--pace not set!")
+		if paceFlag == "" && profileFlag == "" {
+			panic("--pace or --profile not set!")
 		}
 		if aggregAddr == "" {
 			panic("--aggregator not set!")
@@ -114,7 +365,7 @@ func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor re
 
 		log.Println("Creating a sender")
 
-		sender, err := sender.NewSender(factory, aggregAddr, msgSize, warmupSeconds, paceFlag, fixedBody)
+		sender, err := sender.NewSender(factory, aggregAddr, msgSize, payloadSize, warmupSeconds, paceFlag, profileFlag, fixedBody, markWarmup, markCooldown, aggregatorTLS, upload, handshake)
 		if err != nil {
 			panic(err)
 		}
@@ -125,7 +376,87 @@ func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor re
 	if strings.Contains(roles, "aggregator") {
 		log.Println("Creating an aggregator")
 
-		aggr, err := aggregator.NewAggregator(listenAddr, expectRecords, strings.Split(makoTags, ","), publish)
+		percentiles, err := parsePercentiles(highPercentiles)
+		if err != nil {
+			panic(err)
+		}
+
+		aggr, err := aggregator.NewAggregator(listenAddr, expectRecords, strings.Split(makoTags, ","), publish,
+			aggregator.WithWorstSenderReport(reportWorstSender),
+			aggregator.WithQueueingDelayReport(reportQueueingDelay),
+			aggregator.WithSenderQueueingReport(reportSenderQueueing),
+			aggregator.WithRecorderTimeReport(reportRecorderTime),
+			aggregator.WithPhaseTable(reportPhaseTable),
+			aggregator.WithSenderTable(reportSenderTable),
+			aggregator.WithSenderGini(reportSenderGini),
+			aggregator.WithShardBreakdown(shardBreakdown),
+			aggregator.WithBimodalityDetection(detectBimodality),
+			aggregator.WithGoodputReport(reportGoodput),
+			aggregator.WithTeardownCheck(checkTeardown),
+			aggregator.WithLinger(linger),
+			aggregator.WithExclusionWindows(excludeWarmup, excludeCooldown),
+			aggregator.WithReceivedCollapse(receivedCollapse),
+			aggregator.WithSubmissionTTL(submissionTTL),
+			aggregator.WithUnmatchedTTL(unmatchedTTL),
+			aggregator.WithRecordsTimeout(recordsTimeout),
+			aggregator.WithCheckpoint(checkpointPath),
+			aggregator.WithThroughputSmoothing(throughputMode, throughputLookback),
+			aggregator.WithEWMAThroughput(ewmaDecay),
+			aggregator.WithScenario(scenario, scenarioSuffixKeys),
+			aggregator.WithRecordDelay(recordDelay),
+			aggregator.WithRecordBarrier(recordBarrier),
+			aggregator.WithSequenceGapDetection(detectSequenceGaps, sequenceIDPattern),
+			aggregator.WithOrderingVerification(verifyOrdering),
+			aggregator.WithStatsdSink(statsdAddress, statsdPrefix),
+			aggregator.WithWebhookSink(webhookURL),
+			aggregator.WithExporter(exporter, exporterOutput),
+			aggregator.WithShortSummary(shortSummaryFormat, shortSummaryPath),
+			aggregator.WithAssertions(strings.Split(assertions, ",")...),
+			aggregator.WithSLOThresholds(sloThresholds()),
+			aggregator.WithStabilityWeights(stabilityLossWeight, stabilityLatencyWeight, stabilityThroughputWeight),
+			aggregator.WithTimeWeightedPercentiles(timeWeighted),
+			aggregator.WithGracefulStopTimeout(gracefulStopTimeout),
+			aggregator.WithOutputDir(outputDir),
+			aggregator.WithDuplicateBatchWindow(duplicateBatchWindow),
+			aggregator.WithTimeOfDayTable(timeOfDayBucket, timeOfDayPeriod),
+			aggregator.WithHighPercentiles(percentiles...),
+			aggregator.WithExpectedSenders(expectSenders),
+			aggregator.WithMaxRecvMsgSize(maxRecvMsgSize),
+			aggregator.WithCompositeKeyMatching(compositeKeys),
+			aggregator.WithSQLite(sqlitePath),
+			aggregator.WithNDJSONExport(ndjsonPath),
+			aggregator.WithCSVExport(csvPath),
+			aggregator.WithParquetExport(parquetPath),
+			aggregator.WithDuplicateDeliveriesExport(duplicatesPath),
+			aggregator.WithFailedEventsExport(failedEventsPath),
+			aggregator.WithHeatmap(heatmapPath, heatmapWindow),
+			aggregator.WithPlotData(plotDataPath, plotDataWindow),
+			aggregator.WithTimeline(timelinePath),
+			aggregator.WithPercentileSampleCap(percentileSampleCap),
+			aggregator.WithLatencyCDF(cdfPath),
+			aggregator.WithHDRHistogramExport(hdrHistogramPath),
+			aggregator.WithLatencySamplesExport(latencySamplesPath),
+			aggregator.WithSummaryExport(summaryPath),
+			aggregator.WithThroughputSeries(throughputSeriesPath),
+			aggregator.WithReferenceCDF(referenceCDFPath, referenceCDFTolerance),
+			aggregator.WithLatencyStream(streamLatencies, latencyStreamBuffer),
+			aggregator.WithLiveMetrics(liveMetricsAddr),
+			aggregator.WithStreamingAggregation(streamingAggregation),
+			aggregator.WithEventDeadline(eventDeadline),
+			aggregator.WithPeriodicityDetection(periodicityWindow),
+			aggregator.WithClockSkewCases(clockSkewCases),
+			aggregator.WithMinSamplesForPercentiles(minSamples),
+			aggregator.WithDegenerateRunSkip(skipDegenerate),
+			aggregator.WithPercentileConfidenceIntervals(bootstrapResamples),
+			aggregator.WithRunID(runID),
+			aggregator.WithAcceptanceRateReport(acceptanceWindow),
+			aggregator.WithLatencyAnomalyDetection(anomalyWindow, anomalyBaseline, anomalyFactor),
+			aggregator.WithMemoryReport(reportMemory),
+			aggregator.WithArrivalAnchoredLatency(anchorOnArrival),
+			aggregator.WithClockSync(clockSyncRounds),
+			aggregator.WithDuplicateRateReport(duplicateRateInterval),
+			aggregator.WithTLS(tlsCertFile, tlsKeyFile, tlsClientCAFile),
+		)
 		if err != nil {
 			panic(err)
 		}
@@ -147,6 +478,31 @@ func StartPerformanceImage(factory sender.LoadGeneratorFactory, typeExtractor re
 	log.Println("Performance image completed")
 }
 
+// parsePercentiles parses the comma separated list of percentiles.
+func parsePercentiles(list string) ([]float64, error) {
+	var percentiles []float64
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile %q: %v", s, err)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// sloThresholds returns the SLO thresholds set by the flags.
+func sloThresholds() aggregator.SLOThresholds {
+	t := aggregator.SLOThresholds{MaxP50Latency: maxP50Latency, MaxP99Latency: maxP99Latency}
+	if maxErrorRate >= 0 {
+		t.MaxErrorRate = &maxErrorRate
+	}
+	return t
+}
+
 func testNamespace() string {
 	if pn := os.Getenv(podNamespaceEnvVar); pn != "" {
 		return pn
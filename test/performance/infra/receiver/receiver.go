@@ -18,13 +18,16 @@ package receiver
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"runtime"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/types"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
 
@@ -32,7 +35,10 @@ import (
 	pb "knative.dev/eventing/test/performance/infra/event_state"
 )
 
-const shutdownWaitTime = time.Second * 5
+const (
+	shutdownWaitTime = time.Second * 5
+	podNameEnvVar    = "POD_NAME"
+)
 
 // Receiver records the received events and sends to the aggregator.
 // Since sender implementations can put id and type of event inside the event payload,
@@ -48,19 +54,25 @@ type Receiver struct {
 
 	// aggregator GRPC client
 	aggregatorClient *pb.AggregatorClient
+	handshake        pb.HandshakeOptions
+
+	// nil to receive the events over plain HTTP
+	servingTLS *tls.Config
 }
 
-func NewReceiver(paceFlag string, aggregAddr string, warmupSeconds uint, typeExtractor TypeExtractor, idExtractor IdExtractor) (common.Executor, error) {
-	pace, err := common.ParsePaceSpec(paceFlag)
+func NewReceiver(paceFlag string, profileFlag string, aggregAddr string, warmupSeconds uint, typeExtractor TypeExtractor, idExtractor IdExtractor,
+	aggregatorTLS *tls.Config, upload pb.UploadOptions, handshake pb.HandshakeOptions, servingTLS *tls.Config) (common.Executor, error) {
+	pace, err := common.ParsePaces(paceFlag, profileFlag)
 	if err != nil {
 		return nil, err
 	}
 
 	// create a connection to the aggregator
-	aggregatorClient, err := pb.NewAggregatorClient(aggregAddr)
+	aggregatorClient, err := pb.NewTLSAggregatorClient(aggregAddr, aggregatorTLS)
 	if err != nil {
 		return nil, err
 	}
+	aggregatorClient.SetUploadOptions(upload)
 
 	channelSize, totalMessages := common.CalculateMemoryConstraintsForPaceSpecs(pace)
 
@@ -85,14 +97,22 @@ func NewReceiver(paceFlag string, aggregAddr string, warmupSeconds uint, typeExt
 		receivedCh:    make(chan common.EventTimestamp, channelSize),
 		endCh:         make(chan struct{}, 1),
 		receivedEvents: &pb.EventsRecord{
-			Type:   pb.EventsRecord_RECEIVED,
-			Events: make(map[string]*timestamp.Timestamp, totalMessages),
+			Type:               pb.EventsRecord_RECEIVED,
+			Events:             make(map[string]*timestamp.Timestamp, totalMessages),
+			Monotonic:          make(map[string]int64, totalMessages),
+			ContentHashes:      make(map[string]uint32, totalMessages),
+			ChecksumMismatches: make(map[string]bool),
 		},
 		aggregatorClient: aggregatorClient,
+		handshake:        handshake,
+		servingTLS:       servingTLS,
 	}, nil
 }
 
 func (r *Receiver) Run(ctx context.Context) {
+	// a single events record list is uploaded, once the end message is received
+	r.aggregatorClient.Handshake(os.Getenv(podNameEnvVar), 1, r.handshake)
+
 	// Wait the port before starting the ce receiver
 	waitForPortAvailable(common.CEReceiverPort)
 
@@ -125,7 +145,7 @@ func (r *Receiver) Run(ctx context.Context) {
 
 	if err := r.aggregatorClient.Publish(&pb.EventsRecordList{Items: []*pb.EventsRecord{
 		r.receivedEvents,
-	}}); err != nil {
+	}, Source: os.Getenv(podNameEnvVar), MonotonicClock: common.MonotonicClock, Final: true}); err != nil {
 		log.Fatalf("Failed to send events record: %v\n", err)
 	}
 
@@ -140,6 +160,13 @@ func (r *Receiver) processEvents() {
 				return
 			}
 			r.receivedEvents.Events[e.EventId] = e.At
+			r.receivedEvents.Monotonic[e.EventId] = e.Monotonic
+			if e.HasContentHash {
+				r.receivedEvents.ContentHashes[e.EventId] = e.ContentHash
+			}
+			if e.ChecksumMismatch {
+				r.receivedEvents.ChecksumMismatches[e.EventId] = true
+			}
 		case <-r.endCh:
 			return
 		}
@@ -147,21 +174,51 @@ func (r *Receiver) processEvents() {
 }
 
 func (r *Receiver) startCloudEventsReceiver(ctx context.Context) error {
-	cli, err := cloudevents.NewDefaultClient()
+	cli, err := r.newCloudEventsClient()
 	if err != nil {
 		return fmt.Errorf("failed to create CloudEvents client: %v", err)
 	}
 
-	log.Printf("CloudEvents receiver started")
+	if r.servingTLS != nil {
+		log.Printf("CloudEvents receiver started over HTTPS")
+	} else {
+		log.Printf("CloudEvents receiver started")
+	}
 	return cli.StartReceiver(ctx, r.processReceiveEvent)
 }
 
+// newCloudEventsClient returns the client receiving the events on the
+// receiver port, over HTTPS if the receiver has a serving TLS configuration.
+func (r *Receiver) newCloudEventsClient() (cloudevents.Client, error) {
+	if r.servingTLS == nil {
+		return cloudevents.NewDefaultClient()
+	}
+	listener, err := net.Listen("tcp", ":"+common.CEReceiverPort)
+	if err != nil {
+		return nil, err
+	}
+	p, err := cloudevents.NewHTTP(cloudevents.WithListener(tls.NewListener(listener, r.servingTLS)))
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return cloudevents.NewClientObserved(p, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
+}
+
 // processReceiveEvent processes the event received by the CloudEvents receiver.
 func (r *Receiver) processReceiveEvent(event cloudevents.Event) {
 	t := r.typeExtractor(event)
 	switch t {
 	case common.MeasureEventType:
-		r.receivedCh <- common.EventTimestamp{EventId: r.idExtractor(event), At: ptypes.TimestampNow()}
+		hash := common.ContentHash(event.Data())
+		r.receivedCh <- common.EventTimestamp{
+			EventId:          r.idExtractor(event),
+			At:               ptypes.TimestampNow(),
+			Monotonic:        common.MonotonicNow(),
+			ContentHash:      hash,
+			HasContentHash:   true,
+			ChecksumMismatch: checksumMismatch(event, hash),
+		}
 	case common.GCEventType:
 		runtime.GC()
 	case common.EndEventType:
@@ -173,6 +230,17 @@ func (r *Receiver) processReceiveEvent(event cloudevents.Event) {
 	}
 }
 
+// checksumMismatch tells whether the event carries a checksum computed by its
+// sender which doesn't match the hash of its received content.
+func checksumMismatch(event cloudevents.Event, hash uint32) bool {
+	v, ok := event.Extensions()[common.ChecksumExtension]
+	if !ok {
+		return false
+	}
+	checksum, err := types.ToString(v)
+	return err != nil || checksum != common.FormatChecksum(hash)
+}
+
 // waitForPortAvailable waits until the given TCP port is available.
 func waitForPortAvailable(port string) {
 	var free bool
@@ -18,13 +18,19 @@ package sender
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/uuid"
 	"github.com/rogpeppe/fastuuid"
@@ -37,13 +43,30 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// Content modes of the events sent over HTTP.
+const (
+	// attributes in the Ce- headers and data in the body
+	BinaryEncoding = "binary"
+	// attributes and data together in a JSON body
+	StructuredEncoding = "structured"
+)
+
 type CloudEventsTargeter struct {
 	sinkUrl     string
-	msgSize     uint
+	payloadSize common.PayloadSize
 	eventType   string
 	eventSource string
-	body        []byte
-	fixedBody   bool
+	// body of all the events, if fixed
+	body      []byte
+	fixedBody bool
+	// checksum of the fixed body
+	checksum []string
+	// number of partitions the events are numbered within, if any, and the
+	// counter of the events numbered so far
+	partitions uint
+	numbered   *uint64
+	// whether to send the events in structured content mode
+	structured bool
 }
 
 var letterBytes = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -61,22 +84,44 @@ func generateRandStringPayload(length uint) []byte {
 	return b
 }
 
-func NewCloudEventsTargeter(sinkUrl string, msgSize uint, eventType string, eventSource string, fixedBody bool) CloudEventsTargeter {
+// NewCloudEventsTargeter returns a targeter of events whose payload sizes
+// follow the given distribution. With fixedBody, all the events have the same
+// body, unless their payload sizes vary.
+func NewCloudEventsTargeter(sinkUrl string, payloadSize common.PayloadSize, eventType string, eventSource string, fixedBody bool) CloudEventsTargeter {
 	var body []byte
+	var checksum []string
 
+	fixedBody = fixedBody && !payloadSize.Varies()
 	if fixedBody {
-		body = generateRandStringPayload(msgSize)
+		body = generateRandStringPayload(payloadSize.Sample())
+		checksum = []string{common.FormatChecksum(common.ContentHash(body))}
 	}
 	return CloudEventsTargeter{
 		sinkUrl:     sinkUrl,
-		msgSize:     msgSize,
+		payloadSize: payloadSize,
 		eventType:   eventType,
 		eventSource: eventSource,
 		fixedBody:   fixedBody,
 		body:        body,
+		checksum:    checksum,
 	}
 }
 
+// sequencedIn numbers the events monotonically within the given number of
+// partitions, round robin, counting them with the given counter so that the
+// numbering carries on across targeters.
+func (cet CloudEventsTargeter) sequencedIn(partitions uint, numbered *uint64) CloudEventsTargeter {
+	cet.partitions = partitions
+	cet.numbered = numbered
+	return cet
+}
+
+// encodedIn sends the events in the given content mode, binary by default.
+func (cet CloudEventsTargeter) encodedIn(encoding string) CloudEventsTargeter {
+	cet.structured = encoding == StructuredEncoding
+	return cet
+}
+
 func (cet CloudEventsTargeter) VegetaTargeter() vegeta.Targeter {
 	uuidGen := fastuuid.MustNewGenerator()
 
@@ -85,11 +130,15 @@ func (cet CloudEventsTargeter) VegetaTargeter() vegeta.Targeter {
 	ceSpecVersion := []string{cloudevents.VersionV1}
 	ceContentType := []string{cloudevents.ApplicationJSON}
 
+	if cet.structured {
+		return cet.structuredTargeter(uuidGen)
+	}
+
 	return func(t *vegeta.Target) error {
 		t.Method = http.MethodPost
 		t.URL = cet.sinkUrl
 
-		t.Header = make(http.Header, 5)
+		t.Header = make(http.Header, 6)
 
 		t.Header["Ce-Id"] = []string{uuidGen.Hex128()}
 
@@ -97,16 +146,89 @@ func (cet CloudEventsTargeter) VegetaTargeter() vegeta.Targeter {
 		t.Header["Ce-Source"] = ceSource
 		t.Header["Ce-Specversion"] = ceSpecVersion
 		t.Header["Content-Type"] = ceContentType
-		if cet.fixedBody {
-			t.Body = cet.body
-		} else {
-			t.Body = generateRandStringPayload(cet.msgSize)
+		t.Body, t.Header["Ce-Checksum"] = cet.payload()
+		if partition, sequence, ok := cet.nextSequence(); ok {
+			t.Header["Ce-Partitionkey"] = []string{partition}
+			t.Header["Ce-Sequence"] = []string{sequence}
+		}
+
+		return nil
+	}
+}
+
+// payload returns the payload of the next event and its checksum.
+func (cet CloudEventsTargeter) payload() ([]byte, []string) {
+	if cet.fixedBody {
+		return cet.body, cet.checksum
+	}
+	body := generateRandStringPayload(cet.payloadSize.Sample())
+	return body, []string{common.FormatChecksum(common.ContentHash(body))}
+}
+
+// nextSequence returns the partition key and the sequence number of the next
+// event, false if the events aren't numbered.
+func (cet CloudEventsTargeter) nextSequence() (string, string, bool) {
+	if cet.partitions == 0 {
+		return "", "", false
+	}
+	// the targeter is called concurrently by the workers
+	n := atomic.AddUint64(cet.numbered, 1) - 1
+	partition := n % uint64(cet.partitions)
+	return fmt.Sprintf("%s-%d", cet.eventSource, partition), strconv.FormatUint(n/uint64(cet.partitions), 10), true
+}
+
+// structuredEventAttributesSize is enough room in the JSON body of an event
+// for its own attributes, its ID, checksum and sequence.
+const structuredEventAttributesSize = 160
+
+// structuredTargeter returns the targeter of the events in structured content
+// mode, whose attributes and data are together in their JSON body.
+func (cet CloudEventsTargeter) structuredTargeter(uuidGen *fastuuid.Generator) vegeta.Targeter {
+	// the attributes common to all the events, encoded once, without the
+	// closing brace
+	attributes, _ := json.Marshal(map[string]string{
+		"specversion":     cloudevents.VersionV1,
+		"type":            cet.eventType,
+		"source":          cet.eventSource,
+		"datacontenttype": cloudevents.ApplicationJSON,
+	})
+	attributes = attributes[:len(attributes)-1]
+	contentType := []string{cloudevents.ApplicationCloudEventsJSON}
+
+	return func(t *vegeta.Target) error {
+		t.Method = http.MethodPost
+		t.URL = cet.sinkUrl
+
+		t.Header = make(http.Header, 1)
+		t.Header["Content-Type"] = contentType
+
+		payload, checksum := cet.payload()
+		body := make([]byte, 0, len(attributes)+len(payload)+structuredEventAttributesSize)
+		body = append(body, attributes...)
+		body = appendAttribute(body, "id", uuidGen.Hex128())
+		body = appendAttribute(body, common.ChecksumExtension, checksum[0])
+		if partition, sequence, ok := cet.nextSequence(); ok {
+			body = appendAttribute(body, common.PartitionKeyExtension, partition)
+			body = appendAttribute(body, common.SequenceExtension, sequence)
 		}
+		// the payload is a JSON string
+		body = append(body, `,"data":`...)
+		body = append(body, payload...)
+		t.Body = append(body, '}')
 
 		return nil
 	}
 }
 
+// appendAttribute appends a string attribute to the JSON object being encoded.
+func appendAttribute(body []byte, name string, value string) []byte {
+	encoded, _ := json.Marshal(value)
+	body = append(body, `,"`...)
+	body = append(body, name...)
+	body = append(body, `":`...)
+	return append(body, encoded...)
+}
+
 type httpLoadGenerator struct {
 	eventSource string
 	sinkUrl     string
@@ -117,13 +239,33 @@ type httpLoadGenerator struct {
 	warmupAttacker *vegeta.Attacker
 	paceAttacker   *vegeta.Attacker
 	ceClient       cloudevents.Client
+
+	// number of partitions the measured events are numbered within, and the
+	// counter of the events numbered so far, across the paces
+	partitions uint
+	numbered   *uint64
+	// content mode of the events
+	encoding string
 }
 
-func NewHTTPLoadGeneratorFactory(sinkUrl string, minWorkers uint64) LoadGeneratorFactory {
+// NewHTTPLoadGeneratorFactory returns a factory of load generators sending the
+// events to the sink. If recordOnWire is set, they also report the time the
+// events went on the wire, after waiting in the sender for a connection. With
+// sequencePartitions, they number the measured events monotonically within as
+// many partitions of the sender, with the partitionkey and sequence
+// extensions, for the aggregator to verify the order in which they are
+// received. The events are sent in the given content mode, binary or
+// structured, tagging the run. If sinkTLS is set, https sinks are verified
+// with it, else their certificates aren't verified.
+func NewHTTPLoadGeneratorFactory(sinkUrl string, minWorkers uint64, recordOnWire bool, sequencePartitions uint, encoding string,
+	sinkTLS *tls.Config) LoadGeneratorFactory {
 	return func(eventSource string, sentCh chan common.EventTimestamp, acceptedCh chan common.EventTimestamp) (generator LoadGenerator, e error) {
 		if sinkUrl == "" {
 			panic("Missing --sink flag")
 		}
+		if encoding != BinaryEncoding && encoding != StructuredEncoding {
+			return nil, fmt.Errorf("invalid encoding %q, must be %s or %s", encoding, BinaryEncoding, StructuredEncoding)
+		}
 
 		loadGen := &httpLoadGenerator{
 			eventSource: eventSource,
@@ -131,33 +273,48 @@ func NewHTTPLoadGeneratorFactory(sinkUrl string, minWorkers uint64) LoadGenerato
 
 			sentCh:     sentCh,
 			acceptedCh: acceptedCh,
+
+			partitions: sequencePartitions,
+			numbered:   new(uint64),
+			encoding:   encoding,
+		}
+
+		interceptor := requestInterceptor{
+			before: func(request *http.Request) string {
+				e := sentEvent(request)
+				loadGen.sentCh <- e
+				return e.EventId
+			},
+			transport: vegetaAttackerTransport(sinkTLS),
+			after: func(id string, response *http.Response, e error) {
+				t := ptypes.TimestampNow()
+				if e == nil && response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices {
+					loadGen.acceptedCh <- common.EventTimestamp{EventId: id, At: t}
+				}
+			},
+		}
+		if recordOnWire {
+			interceptor.onWire = func(id string) {
+				loadGen.sentCh <- common.EventTimestamp{EventId: id, OnWire: ptypes.TimestampNow()}
+			}
 		}
 
-		loadGen.warmupAttacker = vegeta.NewAttacker(vegeta.Workers(minWorkers))
+		warmupOpts := []func(*vegeta.Attacker){vegeta.Workers(minWorkers)}
+		if sinkTLS != nil {
+			warmupOpts = append(warmupOpts, vegeta.TLSConfig(sinkTLS))
+		}
+		loadGen.warmupAttacker = vegeta.NewAttacker(warmupOpts...)
 		loadGen.paceAttacker = vegeta.NewAttacker(
 			vegeta.Client(&http.Client{
-				Timeout: vegeta.DefaultTimeout,
-				Transport: requestInterceptor{
-					before: func(request *http.Request) {
-						id := request.Header.Get("Ce-Id")
-						loadGen.sentCh <- common.EventTimestamp{EventId: id, At: ptypes.TimestampNow()}
-					},
-					transport: vegetaAttackerTransport(),
-					after: func(request *http.Request, response *http.Response, e error) {
-						id := request.Header.Get("Ce-Id")
-						t := ptypes.TimestampNow()
-						if e == nil && response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices {
-							loadGen.acceptedCh <- common.EventTimestamp{EventId: id, At: t}
-						}
-					},
-				},
+				Timeout:   vegeta.DefaultTimeout,
+				Transport: interceptor,
 			}),
 			vegeta.Workers(minWorkers),
 			vegeta.MaxBody(0),
 		)
 
 		var err error
-		loadGen.ceClient, err = newCloudEventsClient(sinkUrl)
+		loadGen.ceClient, err = newCloudEventsClient(sinkUrl, sinkTLS)
 		if err != nil {
 			return nil, err
 		}
@@ -166,29 +323,120 @@ func NewHTTPLoadGeneratorFactory(sinkUrl string, minWorkers uint64) LoadGenerato
 	}
 }
 
+// sentEvent returns the event sent by the request, with the time it was sent.
+func sentEvent(request *http.Request) common.EventTimestamp {
+	e := common.EventTimestamp{At: ptypes.TimestampNow(), Monotonic: common.MonotonicNow()}
+	if request.Header.Get("Content-Type") == cloudevents.ApplicationCloudEventsJSON {
+		setStructuredEvent(&e, request)
+		return e
+	}
+
+	e.EventId = request.Header.Get("Ce-Id")
+	e.ContentHash, e.HasContentHash = requestContentHash(request)
+	if request.ContentLength > 0 {
+		e.PayloadSize = uint32(request.ContentLength)
+	}
+	setSequence(&e, request.Header.Get("Ce-Partitionkey"), request.Header.Get("Ce-Sequence"))
+	return e
+}
+
+// structuredEvent is the part of the JSON body of an event in structured
+// content mode the sender records.
+type structuredEvent struct {
+	ID           string          `json:"id"`
+	PartitionKey string          `json:"partitionkey"`
+	Sequence     string          `json:"sequence"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// setStructuredEvent sets the ID, the hash and the size of the payload and the
+// sequence of the event in structured content mode sent by the request.
+func setStructuredEvent(e *common.EventTimestamp, request *http.Request) {
+	body, ok := requestBody(request)
+	if !ok {
+		return
+	}
+	var se structuredEvent
+	if err := json.Unmarshal(body, &se); err != nil {
+		return
+	}
+	e.EventId = se.ID
+	if len(se.Data) > 0 {
+		e.ContentHash, e.HasContentHash = common.ContentHash(se.Data), true
+		e.PayloadSize = uint32(len(se.Data))
+	}
+	setSequence(e, se.PartitionKey, se.Sequence)
+}
+
+// setSequence sets the partition key and the sequence number of the event, if
+// it's numbered.
+func setSequence(e *common.EventTimestamp, partition string, sequence string) {
+	if partition == "" {
+		return
+	}
+	if n, err := strconv.ParseUint(sequence, 10, 64); err == nil {
+		e.Partition, e.Sequence, e.HasSequence = partition, n, true
+	}
+}
+
+// requestContentHash returns the hash of the body of the request, without consuming it.
+func requestContentHash(request *http.Request) (uint32, bool) {
+	body, ok := requestBody(request)
+	if !ok {
+		return 0, false
+	}
+	return common.ContentHash(body), true
+}
+
+// requestBody returns the body of the request, without consuming it.
+func requestBody(request *http.Request) ([]byte, bool) {
+	if request.GetBody == nil {
+		return nil, false
+	}
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
 // Since we need to add an interceptor to keep track of timestamps before and after sending events,
 // we need to have our own Transport implementation.
 // At the same time we still need to use the one implemented in Vegeta, which is optimized to being able to generate
 // high loads. But since the function is not exported, we need to add it here in order to use it.
 // The below function is mostly copied from https://github.com/tsenart/vegeta/blob/44a49c878dd6f28f04b9b5ce5751490b0dce1e18/lib/attack.go#L80
-func vegetaAttackerTransport() *http.Transport {
+// Like vegeta, it doesn't verify the certificates of the sink unless tlsConfig is set.
+func vegetaAttackerTransport(tlsConfig *tls.Config) *http.Transport {
 	dialer := &net.Dialer{
 		LocalAddr: &net.TCPAddr{IP: vegeta.DefaultLocalAddr.IP, Zone: vegeta.DefaultLocalAddr.Zone},
 		KeepAlive: 30 * time.Second,
 	}
 
+	if tlsConfig == nil {
+		tlsConfig = vegeta.DefaultTLSConfig
+	}
 	return &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		Dial:                dialer.Dial,
-		TLSClientConfig:     vegeta.DefaultTLSConfig,
+		TLSClientConfig:     tlsConfig,
 		MaxIdleConnsPerHost: vegeta.DefaultConnections,
 	}
 }
 
-func newCloudEventsClient(sinkUrl string) (cloudevents.Client, error) {
-	t, err := cloudevents.NewHTTP(
-		cloudevents.WithTarget(sinkUrl),
-	)
+func newCloudEventsClient(sinkUrl string, sinkTLS *tls.Config) (cloudevents.Client, error) {
+	opts := []cehttp.Option{cloudevents.WithTarget(sinkUrl)}
+	if sinkTLS != nil {
+		opts = append(opts, cloudevents.WithRoundTripper(&http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: sinkTLS,
+		}))
+	}
+	t, err := cloudevents.NewHTTP(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
@@ -196,20 +444,26 @@ func newCloudEventsClient(sinkUrl string) (cloudevents.Client, error) {
 	return cloudevents.NewClient(t)
 }
 
-func (h httpLoadGenerator) Warmup(pace common.PaceSpec, msgSize uint, fixedBody bool) {
-	targeter := NewCloudEventsTargeter(h.sinkUrl, msgSize, common.WarmupEventType, defaultEventSource, fixedBody).VegetaTargeter()
+func (h httpLoadGenerator) Warmup(pace common.PaceSpec, payloadSize common.PayloadSize, fixedBody bool) {
+	targeter := NewCloudEventsTargeter(h.sinkUrl, payloadSize, common.WarmupEventType, defaultEventSource, fixedBody).
+		encodedIn(h.encoding).VegetaTargeter()
 	vegetaResults := h.warmupAttacker.Attack(targeter, vegeta.ConstantPacer{Freq: pace.Rps, Per: time.Second}, pace.Duration, common.WarmupEventType+"-attack")
 	for range vegetaResults {
 	}
 }
 
-func (h httpLoadGenerator) RunPace(i int, pace common.PaceSpec, msgSize uint, fixedBody bool) {
-	targeter := NewCloudEventsTargeter(h.sinkUrl, msgSize, common.MeasureEventType, eventsSource(), fixedBody).VegetaTargeter()
-	res := h.paceAttacker.Attack(targeter, vegeta.ConstantPacer{Freq: pace.Rps, Per: time.Second}, pace.Duration, fmt.Sprintf("%s-attack-%d", h.eventSource, i))
+func (h httpLoadGenerator) RunPace(i int, pace common.PaceSpec, payloadSize common.PayloadSize, fixedBody bool) {
+	targeter := NewCloudEventsTargeter(h.sinkUrl, payloadSize, common.MeasureEventType, eventsSource(), fixedBody).
+		sequencedIn(h.partitions, h.numbered).encodedIn(h.encoding).VegetaTargeter()
+	res := h.paceAttacker.Attack(targeter, pacerOf(pace), pace.Duration, fmt.Sprintf("%s-attack-%d", h.eventSource, i))
 	for range res {
 	}
 }
 
+func (h httpLoadGenerator) Tags() []string {
+	return []string{"encoding=" + h.encoding}
+}
+
 func (h httpLoadGenerator) SendGCEvent() {
 	event := cloudevents.NewEvent(cloudevents.VersionV1)
 	event.SetID(uuid.New().String())
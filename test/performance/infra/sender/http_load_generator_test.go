@@ -14,9 +14,23 @@ package sender
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
 	"testing"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	vegeta "github.com/tsenart/vegeta/lib"
+
+	"knative.dev/eventing/test/performance/infra/common"
 )
 
 func TestGenerateRandStringPayload(t *testing.T) {
@@ -43,7 +57,7 @@ func TestVegetaTargeter(t *testing.T) {
 	for _, fixedPayload := range []bool{false, true} {
 		expectedEventType := "test.event.type"
 		expectedEventSource := "my.event.source"
-		cet := NewCloudEventsTargeter("https://foo/bar", sizeRandomPayload, expectedEventType, expectedEventSource, fixedPayload)
+		cet := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(sizeRandomPayload), expectedEventType, expectedEventSource, fixedPayload)
 		targeter := cet.VegetaTargeter()
 
 		target1 := vegeta.Target{}
@@ -51,7 +65,7 @@ func TestVegetaTargeter(t *testing.T) {
 			t.Fatal("Targeter call returned error:", err)
 		}
 
-		nonEmptyHeaders := []string{"Ce-Id", "Ce-Type", "Ce-Source", "Ce-Specversion", "Content-Type"}
+		nonEmptyHeaders := []string{"Ce-Id", "Ce-Type", "Ce-Source", "Ce-Specversion", "Content-Type", "Ce-Checksum"}
 		for _, header := range nonEmptyHeaders {
 			val, found := target1.Header[header]
 			if !found {
@@ -77,6 +91,11 @@ func TestVegetaTargeter(t *testing.T) {
 		} else if !fixedPayload && bytes.Equal(target1.Body, target2.Body) {
 			t.Error("Target bodies unexpectedly equal:", target1.Body)
 		}
+		for _, target := range []vegeta.Target{target1, target2} {
+			if got, want := target.Header.Get("Ce-Checksum"), common.FormatChecksum(common.ContentHash(target.Body)); got != want {
+				t.Errorf("Checksum = %s, want %s", got, want)
+			}
+		}
 		ceID1 := target1.Header["Ce-Id"]
 		ceID2 := target2.Header["Ce-Id"]
 		if len(ceID1) == 1 && len(ceID2) == 1 {
@@ -92,7 +111,7 @@ func TestVegetaTargeter(t *testing.T) {
 func BenchmarkTargeterFixedPayload(b *testing.B) {
 	const sizeRandomPayload = 100
 
-	cet := NewCloudEventsTargeter("https://foo/bar", sizeRandomPayload, "test.event.type", "my.event.source", true)
+	cet := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(sizeRandomPayload), "test.event.type", "my.event.source", true)
 
 	targeter := cet.VegetaTargeter()
 
@@ -107,7 +126,7 @@ func BenchmarkTargeterFixedPayload(b *testing.B) {
 func BenchmarkTargeterRandomPayload(b *testing.B) {
 	const sizeRandomPayload = 100
 
-	cet := NewCloudEventsTargeter("https://foo/bar", sizeRandomPayload, "test.event.type", "my.event.source", false)
+	cet := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(sizeRandomPayload), "test.event.type", "my.event.source", false)
 
 	targeter := cet.VegetaTargeter()
 
@@ -118,3 +137,179 @@ func BenchmarkTargeterRandomPayload(b *testing.B) {
 		}
 	}
 }
+
+func TestRequestContentHash(t *testing.T) {
+	target := vegeta.Target{Method: "POST", URL: "https://foo/bar", Body: []byte(`"payload"`)}
+	request, err := target.Request()
+	if err != nil {
+		t.Fatal("Failed to create the request:", err)
+	}
+
+	hash, ok := requestContentHash(request)
+	if !ok {
+		t.Fatal("requestContentHash() found no body")
+	}
+	if want := common.ContentHash(target.Body); hash != want {
+		t.Errorf("requestContentHash() = %x, want %x", hash, want)
+	}
+	// the body must still be readable by the transport
+	if body, _ := ioutil.ReadAll(request.Body); string(body) != `"payload"` {
+		t.Errorf("request body after hashing = %q, want %q", body, `"payload"`)
+	}
+}
+
+func TestVegetaTargeterSequencedInPartitions(t *testing.T) {
+	numbered := new(uint64)
+	want := [][2]string{{"src-0", "0"}, {"src-1", "0"}, {"src-0", "1"}, {"src-1", "1"}, {"src-0", "2"}}
+	for i, w := range want {
+		// the numbering carries on across the targeters
+		targeter := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(10), "test.event.type", "src", true).sequencedIn(2, numbered).VegetaTargeter()
+		target := vegeta.Target{}
+		if err := targeter(&target); err != nil {
+			t.Fatal("Targeter call returned error:", err)
+		}
+		partition, sequence := target.Header.Get("Ce-Partitionkey"), target.Header.Get("Ce-Sequence")
+		if partition != w[0] || sequence != w[1] {
+			t.Errorf("event %d: partition %q and sequence %q, want %q and %q", i, partition, sequence, w[0], w[1])
+		}
+	}
+
+	target := vegeta.Target{}
+	if err := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(10), "test.event.type", "src", true).VegetaTargeter()(&target); err != nil {
+		t.Fatal("Targeter call returned error:", err)
+	}
+	if _, found := target.Header["Ce-Sequence"]; found {
+		t.Error("Unpartitioned targeter set the sequence header")
+	}
+}
+
+func TestVegetaTargeterVaryingPayloadSizes(t *testing.T) {
+	payloadSize := common.PayloadSize{Distribution: common.UniformPayload, Min: 10, Max: 20}
+	// the body can't be fixed with varying sizes
+	targeter := NewCloudEventsTargeter("https://foo/bar", payloadSize, "test.event.type", "src", true).VegetaTargeter()
+	sizes := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		target := vegeta.Target{}
+		if err := targeter(&target); err != nil {
+			t.Fatal("Targeter call returned error:", err)
+		}
+		if len(target.Body) < 10 || len(target.Body) > 20 {
+			t.Fatalf("Body size = %d, want between 10 and 20", len(target.Body))
+		}
+		if got, want := target.Header.Get("Ce-Checksum"), common.FormatChecksum(common.ContentHash(target.Body)); got != want {
+			t.Errorf("Checksum = %s, want %s", got, want)
+		}
+		sizes[len(target.Body)] = true
+	}
+	if len(sizes) < 2 {
+		t.Errorf("Body sizes = %v, want varying sizes", sizes)
+	}
+}
+
+func TestVegetaTargeterStructuredEncoding(t *testing.T) {
+	numbered := new(uint64)
+	targeter := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(10), "test.event.type", "src", false).
+		sequencedIn(2, numbered).encodedIn(StructuredEncoding).VegetaTargeter()
+	for i, w := range [][2]string{{"src-0", "0"}, {"src-1", "0"}} {
+		target := vegeta.Target{}
+		if err := targeter(&target); err != nil {
+			t.Fatal("Targeter call returned error:", err)
+		}
+		if got := target.Header.Get("Content-Type"); got != cloudevents.ApplicationCloudEventsJSON {
+			t.Errorf("Content-Type = %s, want %s", got, cloudevents.ApplicationCloudEventsJSON)
+		}
+		if _, found := target.Header["Ce-Id"]; found {
+			t.Error("Structured event with binary headers")
+		}
+
+		event := cloudevents.NewEvent()
+		if err := json.Unmarshal(target.Body, &event); err != nil {
+			t.Fatalf("Invalid structured event %s: %v", target.Body, err)
+		}
+		if event.ID() == "" || event.Type() != "test.event.type" || event.Source() != "src" || len(event.Data()) != 10 {
+			t.Errorf("Structured event = %v, want an event of type test.event.type from src with 10 bytes of data", event)
+		}
+		extensions := event.Extensions()
+		if got, want := extensions[common.ChecksumExtension], common.FormatChecksum(common.ContentHash(event.Data())); got != want {
+			t.Errorf("Checksum = %v, want %s", got, want)
+		}
+		if partition, sequence := extensions[common.PartitionKeyExtension], extensions[common.SequenceExtension]; partition != w[0] || sequence != w[1] {
+			t.Errorf("event %d: partition %v and sequence %v, want %s and %s", i, partition, sequence, w[0], w[1])
+		}
+
+		// the sender records the event as the receiver sees it
+		request, err := target.Request()
+		if err != nil {
+			t.Fatal("Failed to create the request:", err)
+		}
+		sent := sentEvent(request)
+		if sent.EventId != event.ID() || !sent.HasContentHash || sent.ContentHash != common.ContentHash(event.Data()) || sent.PayloadSize != 10 {
+			t.Errorf("sentEvent() = %+v, want the ID, content hash and payload size of %v", sent, event)
+		}
+		if !sent.HasSequence || sent.Partition != w[0] || strconv.FormatUint(sent.Sequence, 10) != w[1] {
+			t.Errorf("sentEvent() sequence = %q %d, want %s %s", sent.Partition, sent.Sequence, w[0], w[1])
+		}
+	}
+}
+
+func TestSentEventInBinaryEncoding(t *testing.T) {
+	target := vegeta.Target{}
+	if err := NewCloudEventsTargeter("https://foo/bar", common.FixedPayloadSize(10), "test.event.type", "src", true).
+		VegetaTargeter()(&target); err != nil {
+		t.Fatal("Targeter call returned error:", err)
+	}
+	request, err := target.Request()
+	if err != nil {
+		t.Fatal("Failed to create the request:", err)
+	}
+	sent := sentEvent(request)
+	if sent.EventId != target.Header.Get("Ce-Id") || sent.ContentHash != common.ContentHash(target.Body) || sent.PayloadSize != 10 || sent.HasSequence {
+		t.Errorf("sentEvent() = %+v, want the ID, content hash and payload size of the target", sent)
+	}
+}
+
+func TestHTTPSSinkWithCABundle(t *testing.T) {
+	var received int32
+	sink := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer sink.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: sink.Certificate().Raw}), 0600); err != nil {
+		t.Fatal("Failed to write the CA bundle:", err)
+	}
+	sinkTLS, err := common.ClientTLSConfig("sink", caFile, "", "")
+	if err != nil {
+		t.Fatal("ClientTLSConfig() =", err)
+	}
+
+	request, _ := http.NewRequest(http.MethodPost, sink.URL, nil)
+	response, err := vegetaAttackerTransport(sinkTLS).RoundTrip(request)
+	if err != nil {
+		t.Fatal("RoundTrip() with the CA bundle =", err)
+	}
+	response.Body.Close()
+
+	ceClient, err := newCloudEventsClient(sink.URL, sinkTLS)
+	if err != nil {
+		t.Fatal("newCloudEventsClient() =", err)
+	}
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetType(common.EndEventType)
+	event.SetSource(defaultEventSource)
+	if result := ceClient.Send(context.Background(), event); !cloudevents.IsACK(result) {
+		t.Fatal("Send() with the CA bundle =", result)
+	}
+	if got := atomic.LoadInt32(&received); got != 2 {
+		t.Errorf("Sink received %d requests, want 2", got)
+	}
+
+	// the certificate of the sink isn't trusted by an unrelated CA bundle
+	request, _ = http.NewRequest(http.MethodPost, sink.URL, nil)
+	if _, err := vegetaAttackerTransport(&tls.Config{RootCAs: x509.NewCertPool()}).RoundTrip(request); err == nil {
+		t.Error("RoundTrip() without the CA of the sink succeeded")
+	}
+}
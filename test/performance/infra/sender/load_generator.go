@@ -22,12 +22,15 @@ import (
 
 type LoadGenerator interface {
 	// This method blocks till the warmup is complete
-	Warmup(pace common.PaceSpec, msgSize uint, fixedBody bool)
+	Warmup(pace common.PaceSpec, payloadSize common.PayloadSize, fixedBody bool)
 
 	// This method blocks till the pace is complete
-	RunPace(i int, pace common.PaceSpec, msgSize uint, fixedBody bool)
+	RunPace(i int, pace common.PaceSpec, payloadSize common.PayloadSize, fixedBody bool)
 	SendGCEvent()
 	SendEndEvent()
+
+	// Tags returns the Mako tags describing how the events are sent
+	Tags() []string
 }
 
 type LoadGeneratorFactory func(eventSource string, sentCh chan common.EventTimestamp,
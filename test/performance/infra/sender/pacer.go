@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sender
+
+import (
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+
+	"knative.dev/eventing/test/performance/infra/common"
+)
+
+// idleProfileWait is how long the profile pacer waits before checking the
+// rate again while it's zero, e.g. between bursts without base rate.
+const idleProfileWait = 10 * time.Millisecond
+
+// profilePacer paces the events following a load profile.
+type profilePacer struct {
+	profile *common.LoadProfile
+}
+
+var _ vegeta.Pacer = profilePacer{}
+
+// Pace returns how long to wait before sending the next event, once the
+// events sent so far caught up with the profile.
+func (p profilePacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	if elapsed >= p.profile.Duration {
+		return 0, true
+	}
+	expected := p.profile.Hits(elapsed)
+	if float64(hits) < expected {
+		// running behind, send the next event immediately
+		return 0, false
+	}
+	rate := p.profile.Rate(elapsed)
+	if rate <= 0 {
+		return idleProfileWait, false
+	}
+	// at the current rate, until the next event is due
+	return time.Duration((float64(hits) + 1 - expected) / rate * float64(time.Second)), false
+}
+
+// pacerOf returns the pacer of the given pace, following its profile if any.
+func pacerOf(pace common.PaceSpec) vegeta.Pacer {
+	if pace.Profile != nil {
+		return profilePacer{profile: pace.Profile}
+	}
+	return vegeta.ConstantPacer{Freq: pace.Rps, Per: time.Second}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sender
+
+import (
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+
+	"knative.dev/eventing/test/performance/infra/common"
+)
+
+// simulatePacer sends the events at the times paced by the pacer, returning
+// the number of events sent during each second of the given duration.
+func simulatePacer(t *testing.T, pacer vegeta.Pacer, duration time.Duration) []int {
+	perSecond := make([]int, duration/time.Second)
+	var elapsed time.Duration
+	var hits uint64
+	for i := 0; ; i++ {
+		if i > 1e6 {
+			t.Fatal("The pacer never stopped")
+		}
+		wait, stop := pacer.Pace(elapsed, hits)
+		if stop {
+			return perSecond
+		}
+		if wait > 0 {
+			elapsed += wait
+			continue
+		}
+		perSecond[elapsed/time.Second]++
+		hits++
+	}
+}
+
+func TestProfilePacerFollowsTheProfile(t *testing.T) {
+	for _, tc := range []struct {
+		profile string
+		want    []int
+	}{
+		{"ramp:100:300:4", []int{125, 175, 225, 275}},
+		{"step:100:50:2:6", []int{100, 100, 150, 150, 200, 200}},
+		// no event sent between the bursts
+		{"burst:0:500:2:1:4", []int{500, 0, 500, 0}},
+	} {
+		p, err := common.ParseLoadProfile(tc.profile)
+		if err != nil {
+			t.Fatalf("ParseLoadProfile(%q) = %v", tc.profile, err)
+		}
+		got := simulatePacer(t, pacerOf(p.PaceSpecs()[0]), p.Duration)
+		for i := range got {
+			// an event may be sent right at the end of a second
+			if d := got[i] - tc.want[i]; d < -1 || d > 1 {
+				t.Errorf("%q: events sent per second = %v, want %v", tc.profile, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPacerOfConstantPace(t *testing.T) {
+	pacer := pacerOf(common.PaceSpec{Rps: 100, Duration: time.Second})
+	if want := (vegeta.ConstantPacer{Freq: 100, Per: time.Second}); pacer != want {
+		t.Errorf("pacerOf() = %v, want %v", pacer, want)
+	}
+}
@@ -16,21 +16,43 @@ limitations under the License.
 
 package sender
 
-import "net/http"
+import (
+	"net/http"
+	"net/http/httptrace"
+)
 
 type requestInterceptor struct {
-	before    func(*http.Request)
+	// before returns the ID of the event sent by the request, passed to onWire
+	// and after
+	before func(*http.Request) string
+	// onWire, if set, is called once the transport got a connection to write
+	// the request on, i.e. when the request stops waiting in the sender
+	onWire    func(id string)
 	transport http.RoundTripper
-	after     func(*http.Request, *http.Response, error)
+	after     func(id string, response *http.Response, err error)
 }
 
 func (r requestInterceptor) RoundTrip(request *http.Request) (*http.Response, error) {
+	var id string
 	if r.before != nil {
-		r.before(request)
+		id = r.before(request)
 	}
-	res, err := r.transport.RoundTrip(request)
+	traced := request
+	if r.onWire != nil {
+		// the transport gets another connection when retrying the request
+		var onWire bool
+		traced = request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) {
+				if !onWire {
+					onWire = true
+					r.onWire(id)
+				}
+			},
+		}))
+	}
+	res, err := r.transport.RoundTrip(traced)
 	if r.after != nil {
-		r.after(request, res, err)
+		r.after(id, res, err)
 	}
 	return res, err
 }
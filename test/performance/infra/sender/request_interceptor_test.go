@@ -19,6 +19,7 @@ package sender
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -29,12 +30,16 @@ func TestRequestInterceptor(t *testing.T) {
 	var calledBefore, calledAfter bool
 
 	ti := requestInterceptor{
-		before: func(*http.Request) {
+		before: func(*http.Request) string {
 			calledBefore = true
+			return "event-id"
 		},
 		transport: http.DefaultTransport,
-		after: func(*http.Request, *http.Response, error) {
+		after: func(id string, _ *http.Response, _ error) {
 			calledAfter = true
+			if id != "event-id" {
+				t.Errorf("after called with ID %q, want the ID returned by before", id)
+			}
 		},
 	}
 
@@ -48,3 +53,36 @@ func TestRequestInterceptor(t *testing.T) {
 		t.Errorf("Expected calls to before and after funcs (before: %t, after: %t)", calledBefore, calledAfter)
 	}
 }
+
+func TestRequestInterceptorOnWire(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	var calls []string
+
+	ti := requestInterceptor{
+		before: func(*http.Request) string {
+			calls = append(calls, "before")
+			return ""
+		},
+		onWire: func(string) {
+			calls = append(calls, "onWire")
+		},
+		transport: http.DefaultTransport,
+		after: func(string, *http.Response, error) {
+			calls = append(calls, "after")
+		},
+	}
+
+	tc := http.Client{Transport: ti}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tc.Get(ts.URL); err != nil {
+			t.Fatal("Failed to send request to mock server:", err)
+		}
+	}
+
+	if got, want := strings.Join(calls, ","), "before,onWire,after,before,onWire,after"; got != want {
+		t.Errorf("Calls = %s, want %s", got, want)
+	}
+}
@@ -18,6 +18,7 @@ package sender
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"os"
@@ -38,10 +39,14 @@ const (
 
 type Sender struct {
 	paceSpecs     []common.PaceSpec
-	msgSize       uint
+	payloadSize   common.PayloadSize
 	warmupSeconds uint
 	fixedBody     bool
 
+	// durations at the beginning and the end of the benchmark marked as
+	// warmup and cooldown windows, 0 not to mark them
+	warmupExclusion, cooldownExclusion time.Duration
+
 	// EventTimestamp channels
 	sentCh     chan common.EventTimestamp
 	acceptedCh chan common.EventTimestamp
@@ -55,19 +60,31 @@ type Sender struct {
 
 	// aggregator GRPC client
 	aggregatorClient *pb.AggregatorClient
+	handshake        pb.HandshakeOptions
 }
 
-func NewSender(loadGeneratorFactory LoadGeneratorFactory, aggregAddr string, msgSize uint, warmupSeconds uint, paceFlag string, fixedBody bool) (common.Executor, error) {
-	pacerSpecs, err := common.ParsePaceSpec(paceFlag)
+// NewSender returns a sender of events of msgSize bytes, or whose sizes follow
+// the distribution of payloadSizeFlag if set.
+func NewSender(loadGeneratorFactory LoadGeneratorFactory, aggregAddr string, msgSize uint, payloadSizeFlag string, warmupSeconds uint, paceFlag string, profileFlag string, fixedBody bool,
+	warmupExclusion, cooldownExclusion time.Duration, aggregatorTLS *tls.Config, upload pb.UploadOptions, handshake pb.HandshakeOptions) (common.Executor, error) {
+	pacerSpecs, err := common.ParsePaces(paceFlag, profileFlag)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse pace spec: %v", err)
 	}
 
+	payloadSize := common.FixedPayloadSize(msgSize)
+	if payloadSizeFlag != "" {
+		if payloadSize, err = common.ParsePayloadSize(payloadSizeFlag); err != nil {
+			return nil, err
+		}
+	}
+
 	// create a connection to the aggregator
-	aggregatorClient, err := pb.NewAggregatorClient(aggregAddr)
+	aggregatorClient, err := pb.NewTLSAggregatorClient(aggregAddr, aggregatorTLS)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the aggregator: %v", err)
 	}
+	aggregatorClient.SetUploadOptions(upload)
 
 	// We need those estimates to allocate memory before benchmark starts
 	estimatedNumberOfMessagesInsideAChannel, estimatedNumberOfTotalMessages := common.CalculateMemoryConstraintsForPaceSpecs(pacerSpecs)
@@ -79,17 +96,25 @@ func NewSender(loadGeneratorFactory LoadGeneratorFactory, aggregAddr string, msg
 	// not what happens after
 
 	executor := &Sender{
-		msgSize:       msgSize,
+		payloadSize:   payloadSize,
 		warmupSeconds: warmupSeconds,
 		paceSpecs:     pacerSpecs,
 		fixedBody:     fixedBody,
 
+		warmupExclusion:   warmupExclusion,
+		cooldownExclusion: cooldownExclusion,
+
 		sentCh:     make(chan common.EventTimestamp, estimatedNumberOfMessagesInsideAChannel),
 		acceptedCh: make(chan common.EventTimestamp, estimatedNumberOfMessagesInsideAChannel),
 
 		sentEvents: &pb.EventsRecord{
-			Type:   pb.EventsRecord_SENT,
-			Events: make(map[string]*timestamp.Timestamp, estimatedNumberOfTotalMessages),
+			Type:          pb.EventsRecord_SENT,
+			Events:        make(map[string]*timestamp.Timestamp, estimatedNumberOfTotalMessages),
+			Monotonic:     make(map[string]int64, estimatedNumberOfTotalMessages),
+			ContentHashes: make(map[string]uint32, estimatedNumberOfTotalMessages),
+			OnWire:        make(map[string]*timestamp.Timestamp),
+			Sequences:     make(map[string]*pb.EventKey),
+			PayloadSizes:  make(map[string]uint32),
 		},
 		acceptedEvents: &pb.EventsRecord{
 			Type:   pb.EventsRecord_ACCEPTED,
@@ -97,6 +122,7 @@ func NewSender(loadGeneratorFactory LoadGeneratorFactory, aggregAddr string, msg
 		},
 
 		aggregatorClient: aggregatorClient,
+		handshake:        handshake,
 	}
 
 	executor.loadGenerator, err = loadGeneratorFactory(eventsSource(), executor.sentCh, executor.acceptedCh)
@@ -108,6 +134,9 @@ func NewSender(loadGeneratorFactory LoadGeneratorFactory, aggregAddr string, msg
 }
 
 func (s *Sender) Run(ctx context.Context) {
+	// a single events record list is uploaded, once the benchmark completes
+	s.aggregatorClient.Handshake(eventsSource(), 1, s.handshake)
+
 	// --- Warmup phase
 	log.Printf("--- BEGIN WARMUP ---")
 	if s.warmupSeconds > 0 {
@@ -128,13 +157,20 @@ func (s *Sender) Run(ctx context.Context) {
 	// Clean mess before starting
 	runtime.GC()
 
-	log.Println("Starting benchmark")
+	log.Printf("Starting benchmark, with payload sizes %v", s.payloadSize)
 
 	// Run all pace configurations
 	benchmarkBeginning := time.Now()
+	// end of the last pace, before waiting for the flush
+	var sendingEnd time.Time
 	for i, pace := range s.paceSpecs {
-		log.Printf("Starting pace %d° at %v rps for %v seconds", i+1, pace.Rps, pace.Duration)
-		s.loadGenerator.RunPace(i, pace, s.msgSize, s.fixedBody)
+		if pace.Profile != nil {
+			log.Printf("Starting pace %d° following the %s profile, up to %v rps, for %v", i+1, pace.Profile.Shape, pace.Rps, pace.Duration)
+		} else {
+			log.Printf("Starting pace %d° at %v rps for %v seconds", i+1, pace.Rps, pace.Duration)
+		}
+		s.loadGenerator.RunPace(i, pace, s.payloadSize, s.fixedBody)
+		sendingEnd = time.Now()
 
 		// Wait for flush
 		time.Sleep(common.WaitForFlush)
@@ -156,24 +192,62 @@ func (s *Sender) Run(ctx context.Context) {
 
 	log.Println("---- END BENCHMARK ----")
 
+	s.markExclusionWindows(benchmarkBeginning, sendingEnd)
+
 	log.Println("Sending collected data to the aggregator")
 
 	log.Printf("%-15s: %d", "Sent count", len(s.sentEvents.Events))
 	log.Printf("%-15s: %d", "Accepted count", len(s.acceptedEvents.Events))
 
-	err := s.aggregatorClient.Publish(&pb.EventsRecordList{Items: []*pb.EventsRecord{
-		s.sentEvents,
-		s.acceptedEvents,
-	}})
+	err := s.aggregatorClient.Publish(&pb.EventsRecordList{
+		Items: []*pb.EventsRecord{
+			s.sentEvents,
+			s.acceptedEvents,
+		},
+		Source:         eventsSource(),
+		MonotonicClock: common.MonotonicClock,
+		Final:          true,
+		Tags:           s.loadGenerator.Tags(),
+	})
 	if err != nil {
 		log.Fatalf("Failed to send events record: %v\n", err)
 	}
 }
 
+// markExclusionWindows marks the first and the last durations of the sending,
+// from the beginning of the benchmark to the end of its last pace, as warmup
+// and cooldown windows, whose events the aggregator excludes from the results.
+func (s *Sender) markExclusionWindows(begin, end time.Time) {
+	mark := func(kind string, start, stop time.Time) {
+		log.Printf("Marking the %s window from %v to %v", kind, start, stop)
+		if err := s.aggregatorClient.MarkWindowAt(kind, pb.WindowRequest_START, eventsSource(), start); err != nil {
+			log.Printf("Failed to mark the start of the %s window: %v", kind, err)
+			return
+		}
+		if err := s.aggregatorClient.MarkWindowAt(kind, pb.WindowRequest_STOP, eventsSource(), stop); err != nil {
+			log.Printf("Failed to mark the stop of the %s window: %v", kind, err)
+		}
+	}
+	if s.warmupExclusion > 0 {
+		stop := begin.Add(s.warmupExclusion)
+		if stop.After(end) {
+			stop = end
+		}
+		mark(common.WarmupWindowKind, begin, stop)
+	}
+	if s.cooldownExclusion > 0 {
+		start := end.Add(-s.cooldownExclusion)
+		if start.Before(begin) {
+			start = begin
+		}
+		mark(common.CooldownWindowKind, start, end)
+	}
+}
+
 func (s *Sender) warmup(ctx context.Context, warmupSeconds uint) error {
 	log.Println("Starting warmup")
 
-	s.loadGenerator.Warmup(common.PaceSpec{Rps: warmupRps, Duration: time.Duration(warmupSeconds) * time.Second}, s.msgSize, s.fixedBody)
+	s.loadGenerator.Warmup(common.PaceSpec{Rps: warmupRps, Duration: time.Duration(warmupSeconds) * time.Second}, s.payloadSize, s.fixedBody)
 
 	// give the channel some time to drain the events it may still have enqueued
 	time.Sleep(common.WaitAfterWarmup)
@@ -198,7 +272,23 @@ func (s *Sender) processEvents() {
 			if !ok {
 				continue
 			}
+			if e.OnWire != nil {
+				// reported after the event was sent
+				s.sentEvents.OnWire[e.EventId] = e.OnWire
+				continue
+			}
 			s.sentEvents.Events[e.EventId] = e.At
+			s.sentEvents.Monotonic[e.EventId] = e.Monotonic
+			if e.HasContentHash {
+				s.sentEvents.ContentHashes[e.EventId] = e.ContentHash
+			}
+			if e.HasSequence {
+				s.sentEvents.Sequences[e.EventId] = &pb.EventKey{Partition: e.Partition, Sequence: e.Sequence}
+			}
+			if e.PayloadSize > 0 && s.payloadSize.Varies() {
+				// only worth breaking the latencies down by varying sizes
+				s.sentEvents.PayloadSizes[e.EventId] = e.PayloadSize
+			}
 
 		case e, ok := <-s.acceptedCh:
 			if !ok {
@@ -18,14 +18,22 @@ package main
 
 import (
 	"flag"
+	"log"
 
 	"knative.dev/eventing/test/performance/infra"
+	"knative.dev/eventing/test/performance/infra/common"
 	"knative.dev/eventing/test/performance/infra/receiver"
 	"knative.dev/eventing/test/performance/infra/sender"
 )
 
 var minWorkers uint64
 var sinkURL string
+var recordOnWire bool
+var sequencePartitions uint
+var encoding string
+var sinkCAFile string
+var sinkCertFile string
+var sinkKeyFile string
 
 func init() {
 	infra.DeclareFlags()
@@ -33,10 +41,23 @@ func init() {
 	// Specific to http load generator
 	flag.Uint64Var(&minWorkers, "min-workers", 10, "Number of vegeta workers")
 	flag.StringVar(&sinkURL, "sink", "", "The sink URL for the event destination.")
+	flag.BoolVar(&recordOnWire, "record-on-wire", false, "Also record the time each event goes on the wire, after waiting in the sender for a connection.")
+	flag.UintVar(&sequencePartitions, "sequence-partitions", 0, "Number the events monotonically within as many partitions of each sender, for the aggregator to verify the order in which they are received.")
+	flag.StringVar(&encoding, "encoding", sender.BinaryEncoding, "Content mode of the events sent, binary or structured, tagging the Mako run as encoding=<mode>.")
+	flag.StringVar(&sinkCAFile, "sink-ca", "", "If set, verify the certificate of an https --sink against the PEM CAs of this file, instead of not verifying it.")
+	flag.StringVar(&sinkCertFile, "sink-cert", "", "If set, present this PEM client certificate to an https --sink, for mutual TLS.")
+	flag.StringVar(&sinkKeyFile, "sink-key", "", "PEM key of the --sink-cert certificate.")
 }
 
 func main() {
 	flag.Parse()
 
-	infra.StartPerformanceImage(sender.NewHTTPLoadGeneratorFactory(sinkURL, minWorkers), receiver.EventTypeExtractor, receiver.EventIdExtractor)
+	// nil to not verify the certificate of an https sink
+	sinkTLS, err := common.ClientTLSConfig("sink", sinkCAFile, sinkCertFile, sinkKeyFile)
+	if err != nil {
+		log.Fatalf("Invalid sink TLS configuration: %v", err)
+	}
+
+	infra.StartPerformanceImage(sender.NewHTTPLoadGeneratorFactory(sinkURL, minWorkers, recordOnWire, sequencePartitions, encoding, sinkTLS),
+		receiver.EventTypeExtractor, receiver.EventIdExtractor)
 }